@@ -19,9 +19,13 @@ func main() {
 	rootCmd.AddCommand(cli.RunCmd())
 	rootCmd.AddCommand(cli.TestCmd())
 	rootCmd.AddCommand(cli.BenchCmd())
+	rootCmd.AddCommand(cli.CoverageCmd())
 	rootCmd.AddCommand(cli.CleanCmd())
 	rootCmd.AddCommand(cli.NewCmd())
+	rootCmd.AddCommand(cli.TemplateCmd())
+	rootCmd.AddCommand(cli.InitCmd())
 	rootCmd.AddCommand(cli.AddCmd())
+	rootCmd.AddCommand(cli.UndoCmd())
 	rootCmd.AddCommand(cli.RemoveCmd())
 	rootCmd.AddCommand(cli.ListCmd())
 	rootCmd.AddCommand(cli.SearchCmd())
@@ -34,11 +38,21 @@ func main() {
 
 	rootCmd.AddCommand(cli.DocCmd())
 	rootCmd.AddCommand(cli.ReleaseCmd())
+	rootCmd.AddCommand(cli.PackageCmd())
+	rootCmd.AddCommand(cli.CMakePackageCmd())
+	rootCmd.AddCommand(cli.InstallCmd())
+	rootCmd.AddCommand(cli.CompdbCmd())
+	rootCmd.AddCommand(cli.IdeCmd())
+	rootCmd.AddCommand(cli.PublishCmd())
 	rootCmd.AddCommand(cli.UpgradeCmd())
 	rootCmd.AddCommand(cli.ConfigCmd())
 	rootCmd.AddCommand(cli.WorkflowCmd())
 	rootCmd.AddCommand(cli.HooksCmd())
 	rootCmd.AddCommand(cli.UpdateCmd())
+	rootCmd.AddCommand(cli.DoctorCmd())
+	rootCmd.AddCommand(cli.EnvCmd())
+	rootCmd.AddCommand(cli.ExecCmd())
+	rootCmd.AddCommand(cli.CICmd())
 
 	// Toolchain, Runner management (simplified design)
 	rootCmd.AddCommand(cli.AddToolchainCmd())
@@ -46,8 +60,13 @@ func main() {
 	rootCmd.AddCommand(cli.RmToolchainCmd())
 	rootCmd.AddCommand(cli.RmRunnerCmd())
 
+	// cpx-<name> executables on PATH, after the built-in commands they never replace
+	rootCmd.AddCommand(cli.PluginCmds(rootCmd)...)
+
+	cli.ApplyColorPreference()
+
 	// Handle vcpkg passthrough for specific commands only,
-	// Only forward: install, remove, add-port
+	// Only forward: remove, add-port (install is cpx install)
 	if len(os.Args) > 1 {
 		command := os.Args[1]
 		// Skip version/help flags - cobra handles these
@@ -64,7 +83,7 @@ func main() {
 			// If not found, check if it's a whitelisted vcpkg command
 			if !found {
 				// Only allow specific vcpkg commands to be forwarded
-				allowedVcpkgCommands := []string{"install", "remove", "add-port"}
+				allowedVcpkgCommands := []string{"remove", "add-port"}
 				if slices.Contains(allowedVcpkgCommands, command) {
 					// Use temporary builder to run vcpkg command
 					// Initialize without error check as it might just need PATH
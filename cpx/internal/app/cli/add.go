@@ -2,12 +2,21 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/ozacod/cpx/internal/app/cli/tui"
 	"github.com/ozacod/cpx/internal/pkg/build/bazel"
+	"github.com/ozacod/cpx/internal/pkg/build/cmake"
 	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
 	"github.com/ozacod/cpx/internal/pkg/build/meson"
 	"github.com/ozacod/cpx/internal/pkg/build/vcpkg"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
 	"github.com/ozacod/cpx/pkg/config"
 	"github.com/spf13/cobra"
 )
@@ -15,7 +24,7 @@ import (
 func AddCmd() *cobra.Command {
 	// Set the BCR path provider for bazel builder
 	bazel.SetBCRPathProvider(func() string {
-		cfg, err := config.LoadGlobal()
+		cfg, err := config.Load()
 		if err != nil {
 			return ""
 		}
@@ -27,19 +36,34 @@ func AddCmd() *cobra.Command {
 		Short: "Add a dependency",
 		Long: `Add a dependency to your project.
 
-For vcpkg projects: passes through to 'vcpkg add port' and prints usage info.
+For vcpkg projects: passes through to 'vcpkg add port', prints usage info and
+links the package to the CMake target(s) you pick.
 For Bazel projects: fetches the latest version from BCR and updates MODULE.bazel.
 For Meson projects: uses 'meson wrap install' to add from WrapDB.`,
+		Example: `  cpx add fmt            # add fmt and link it to a CMake target
+  cpx add --dev gtest    # test-only dependency (vcpkg "tests" feature / Bazel dev_dependency)
+  cpx add --undo         # revert the last 'cpx add'`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runAdd(cmd, args)
 		},
-		Args: cobra.MinimumNArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if undo, _ := cmd.Flags().GetBool("undo"); undo {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 	}
+	cmd.Flags().Bool("undo", false, "Revert the last 'cpx add' (same as 'cpx undo')")
+	cmd.Flags().Bool("dev", false, "Add as a test-only dependency, linked only to test targets")
 
 	return cmd
 }
 
-func runAdd(_ *cobra.Command, args []string) error {
+func runAdd(cmd *cobra.Command, args []string) error {
+	if undo, _ := cmd.Flags().GetBool("undo"); undo {
+		return undoLastAdd(false)
+	}
+
 	projectType, err := RequireProject("cpx add")
 	if err != nil {
 		return err
@@ -63,5 +87,151 @@ func runAdd(_ *cobra.Command, args []string) error {
 		return fmt.Errorf("unsupported project type")
 	}
 
-	return builder.AddDependency(context.Background(), name, version)
+	dev, _ := cmd.Flags().GetBool("dev")
+
+	tracked := addTrackedFiles(name)
+	before := takeSnapshot(tracked)
+
+	if dev {
+		devAdder, ok := builder.(build.DevDependencyAdder)
+		if !ok {
+			return fmt.Errorf("--dev is not supported for %s projects", builder.Name())
+		}
+		if err := devAdder.AddDevDependency(context.Background(), name, version); err != nil {
+			return err
+		}
+	} else if err := builder.AddDependency(context.Background(), name, version); err != nil {
+		return err
+	}
+
+	if vb, ok := builder.(*vcpkg.Builder); ok {
+		if err := smartAdd(vb, name, dev); err != nil {
+			// The package is added anyway, so it can still be undone
+			return errors.Join(err, recordAdd(name, before, tracked))
+		}
+	}
+
+	return recordAdd(name, before, tracked)
+}
+
+// smartAdd links a freshly added vcpkg package into CMakeLists.txt.
+// The find_package/target_link_libraries calls are taken from the port's usage file;
+// when several targets are declared the user picks which ones receive the link entry.
+// Dev dependencies are only offered to the targets registered with CTest.
+func smartAdd(vb *vcpkg.Builder, name string, dev bool) error {
+	findPackages, libraries := vcpkg.ParseUsage(vb.UsageInfo(name))
+	if len(libraries) == 0 {
+		return nil
+	}
+
+	cmakePath, content, targets := linkCandidates(dev)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var selected []string
+	switch {
+	case len(targets) == 1:
+		selected = []string{targets[0].Name}
+	case !isInteractive():
+		fmt.Printf("%sMultiple CMake targets found; add to the target(s) that need %s:%s\n", colors.Yellow, name, colors.Reset)
+		fmt.Printf("  target_link_libraries(<target> PRIVATE %s)\n", strings.Join(libraries, " "))
+		return nil
+	default:
+		items := make([]tui.LinkTargetItem, 0, len(targets))
+		for _, t := range targets {
+			items = append(items, tui.LinkTargetItem{Name: t.Name, Kind: t.Kind})
+		}
+		var err error
+		selected, err = tui.RunLinkTargetSelection(items, name)
+		if err != nil {
+			return fmt.Errorf("TUI error: %w", err)
+		}
+	}
+
+	if len(selected) == 0 {
+		fmt.Printf("%sSkipped linking %s; %s unchanged%s\n", colors.Gray, name, cmakePath, colors.Reset)
+		return nil
+	}
+
+	updated := cmake.AddLinkLibraries(content, selected, findPackages, libraries)
+	if updated == content {
+		return nil
+	}
+	if err := os.WriteFile(cmakePath, []byte(updated), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", cmakePath, err)
+	}
+
+	fmt.Printf("%s✓ Linked %s to %s in %s%s\n", colors.Green, name, strings.Join(selected, ", "), cmakePath, colors.Reset)
+	return nil
+}
+
+// linkCandidates returns the CMakeLists.txt to edit, its content and the targets
+// that may receive a new link entry. For dev dependencies tests/CMakeLists.txt is
+// preferred and only the targets registered with CTest are returned.
+func linkCandidates(dev bool) (string, string, []cmake.Target) {
+	paths := []string{"CMakeLists.txt"}
+	var registered map[string]bool
+	if dev {
+		paths = []string{filepath.Join("tests", "CMakeLists.txt"), "CMakeLists.txt"}
+		registered = testTargets(paths)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		content := string(data)
+
+		targets := cmake.ParseTargets(content)
+		if dev {
+			targets = slices.DeleteFunc(targets, func(t cmake.Target) bool { return !registered[t.Name] })
+		}
+		if len(targets) > 0 {
+			return path, content, targets
+		}
+	}
+	return "", "", nil
+}
+
+// testTargets returns the targets registered with CTest: by the CMakeLists.txt
+// files at paths, and in the configured native builds, whose tests ctest lists
+// (tests discovered from a test binary, e.g. by gtest_discover_tests, only show
+// up once it is built)
+func testTargets(paths []string) map[string]bool {
+	registered := make(map[string]bool)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, name := range cmake.ParseTestTargets(string(data)) {
+			registered[name] = true
+		}
+	}
+	testFiles, _ := filepath.Glob(filepath.Join(".cache", "native", "*", "CTestTestfile.cmake"))
+	for _, testFile := range testFiles {
+		out, err := exec.Command("ctest", "--show-only=json-v1", "--test-dir", filepath.Dir(testFile)).Output()
+		if err != nil {
+			continue
+		}
+		names, err := cmake.ParseCTestTargets(out)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			registered[name] = true
+		}
+	}
+	return registered
+}
+
+// isInteractive reports whether stdin is attached to a terminal
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
@@ -1,42 +1,313 @@
 package cli
 
 import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
 	"github.com/ozacod/cpx/internal/pkg/build/vcpkg"
 	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
 	"github.com/spf13/cobra"
 )
 
+// analyzeOutputs are the default report files of the analyze formats
+var analyzeOutputs = map[string]string{
+	"html":       "analyze.html",
+	"junit":      "analyze-junit.xml",
+	"checkstyle": "analyze-checkstyle.xml",
+	"github":     "",
+	"json":       "analyze.json",
+}
+
 func AnalyzeCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "analyze",
-		Short: "Run comprehensive code analysis and generate HTML report",
-		Long:  "Run comprehensive code analysis using cppcheck, clang-tidy, and flawfinder. Generates a combined HTML report (analyze.html).",
+		Short: "Run comprehensive code analysis and generate a report",
+		Long: `Run comprehensive code analysis using cppcheck, clang-tidy, flawfinder, include-what-you-use, Semgrep, cpplint and code metrics. Generates a combined report.
+
+include-what-you-use runs over compile_commands.json (of 'cpx compdb' or the
+debug build) and reports the includes to add and remove; --iwyu-fix applies
+them with fix_includes.py.
+
+Semgrep runs the C/C++ security rules bundled with cpx, or the rulesets of
+--semgrep-config (or semgrep_rules): rule files, directories or registry
+names such as p/c; 'cpx' names the bundled rules.
+
+Code metrics are measured by cpx itself: the cyclomatic complexity and lines
+of code of each function, as lizard counts them, the lines of code of each
+file and blocks of tokens duplicated elsewhere. Functions and files past the
+thresholds of metrics: (complexity 15, function_lines 100, file_lines 1000,
+duplicate_tokens 100 by default; a negative one disables its check) and
+duplicated blocks are findings, and the report summarizes the metrics.
+
+.cpx-quality.yaml sets the defaults of these flags for a project:
+
+  enable: [cppcheck, clang-tidy, semgrep, vera++]   # default: all
+  disable: [flawfinder]
+  args:
+    cppcheck: [--std=c++20, --suppress=missingIncludeSystem]
+    clang-tidy: [--checks=-modernize-*]
+  semgrep_rules: [cpx, .semgrep/]
+  include: [src/**, include/**]
+  exclude: [src/generated/]
+  severity:
+    modernize-*: style
+    readability-*: info
+  metrics:
+    complexity: 10
+    function_lines: 60
+    duplicate_tokens: -1
+  reports:
+    - format: html
+      output: reports/analyze.html
+    - format: junit
+      output: reports/analyze-junit.xml
+  tools:
+    - name: vera++
+      command: vera++ --show-rule
+      pattern: '^(?P<file>[^:]+):(?P<line>\d+): \((?P<rule>[^)]+)\) (?P<message>.*)$'
+      severity: style
+    - name: house-rules
+      command: ./tools/check.py --sarif
+      format: sarif
+
+Analyzers are named cppcheck, clang-tidy, flawfinder, iwyu, semgrep, cpplint,
+metrics or by their tools: entry. tools: are in-house checkers, each a command run
+with the files to check and the format of its output: regex (lines a pattern
+with the named groups file, line and message, and optionally column, severity
+and rule, matches), sarif, or jsonl (a JSON object with file, line, column,
+severity, message and rule per line). Findings are reported only in files
+that match include and not exclude; severity overrides the severity of
+findings by rule glob. reports are written unless --format or --output is
+given.
+
+--format selects the report: html (analyze.html), junit (analyze-junit.xml), a
+test suite per tool with a failing test case per finding for CI test result
+views, checkstyle (analyze-checkstyle.xml) for code quality widgets such as
+Jenkins' warnings plugin, github, which prints workflow commands that
+annotate the findings' lines in GitHub Actions, or json (analyze.json), the
+findings as data.
+
+--tui browses the findings in the terminal after the analysis, as
+'cpx analyze view analyze.json' does those of a JSON report: t and s filter
+them by tool and severity, / by file, and Enter opens the selected one's file
+at its line in $EDITOR.
+
+--github-review also posts the findings on lines a pull request changes as a
+review of it, with GITHUB_TOKEN or GH_TOKEN. The pull request is the one of the
+workflow's pull_request event, or --pr.
+
+As a CI gate, --fail-on exits non-zero when there is a finding of that
+severity or worse (error, warning, style or info) and --max-warnings when
+there are more warnings than it allows. The report is written either way.
+
+--write-baseline records the current findings in .cpx-baseline.json; later
+runs leave them out and report only new findings. Findings are matched by
+tool, rule, file and the code on their line, so edits elsewhere keep them
+matched. A '// cpx-ignore(rule, ...)' comment on a finding's line or the line
+above suppresses it ('// cpx-ignore' suppresses all), as does a line
+'<rule glob> [<file glob or directory>]' in .cpx-suppressions.
+
+The analyzers run at once, each reporting when it is done, and clang-tidy
+analyzes --jobs files in parallel (one per CPU by default). With --iwyu-fix,
+include-what-you-use runs after the others, as its fixes edit the sources.
+
+Each analysis is recorded in .cpx/analysis/ by commit (--no-history skips
+it); 'cpx analyze trend' shows how the finding counts changed over them, and
+'cpx analyze diff --against <commit>' which findings are new since a commit.`,
+		Example: `  cpx analyze
+  cpx analyze --tui
+  cpx analyze --format json && cpx analyze view analyze.json
+  cpx analyze --format junit
+  cpx analyze --format checkstyle --output reports/checkstyle.xml src
+  cpx analyze --format github --github-review
+  cpx analyze --fail-on error --max-warnings 20
+  cpx analyze --write-baseline
+  cpx analyze trend --by tool
+  cpx analyze diff --against origin/main --fail-on-new
+  cpx analyze --skip-cppcheck --skip-lint --skip-flawfinder --iwyu-fix
+  cpx analyze --skip-cpplint --skip-metrics
+  cpx analyze -j 16
+  cpx analyze --semgrep-config cpx --semgrep-config .semgrep/`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runAnalyze(cmd, args)
 		},
 		Args: cobra.ArbitraryArgs,
 	}
 
-	cmd.Flags().String("output", "analyze.html", "Output HTML file path")
+	addAnalyzeFlags(cmd)
+	cmd.Flags().Bool("tui", false, "Browse the findings interactively after the analysis")
+	cmd.Flags().IntP("jobs", "j", 0, "Files clang-tidy analyzes in parallel (default: number of CPUs)")
+
+	cmd.AddCommand(analyzeViewCmd())
+	cmd.AddCommand(analyzeTrendCmd())
+	cmd.AddCommand(analyzeDiffCmd())
+
+	return cmd
+}
+
+// addAnalyzeFlags adds the flags of analyze, shared by ci analyze
+func addAnalyzeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("output", "", "Output report file path (default: analyze.html, analyze-junit.xml, analyze-checkstyle.xml or analyze.json)")
+	cmd.Flags().String("format", "html", "Report format: html, junit, checkstyle, github or json")
+	cmd.Flags().Bool("github-review", false, "Post findings on changed lines as a GitHub pull request review")
+	cmd.Flags().Int("pr", 0, "Pull request to review (default: the one of the GitHub Actions event)")
+	cmd.Flags().String("fail-on", "", "Fail when a finding has this severity or worse: error, warning, style or info")
+	cmd.Flags().Int("max-warnings", -1, "Fail when there are more warnings than this; -1 for no limit")
+	cmd.Flags().Bool("write-baseline", false, "Record the current findings in the baseline file so later runs report only new ones")
+	cmd.Flags().Bool("no-history", false, "Do not record the analysis in .cpx/analysis/ for 'cpx analyze trend'")
+	cmd.Flags().String("baseline", quality.DefaultBaselineFile, "Baseline file of accepted findings")
+	cmd.Flags().String("suppressions", quality.DefaultSuppressionsFile, "Suppressions file of rules to ignore, by file")
 	cmd.Flags().Bool("skip-cppcheck", false, "Skip Cppcheck analysis")
 	cmd.Flags().Bool("skip-lint", false, "Skip clang-tidy analysis")
 	cmd.Flags().Bool("skip-flawfinder", false, "Skip Flawfinder analysis")
-
-	return cmd
+	cmd.Flags().Bool("skip-iwyu", false, "Skip include-what-you-use analysis")
+	cmd.Flags().Bool("iwyu-fix", false, "Apply include-what-you-use's suggestions with fix_includes.py")
+	cmd.Flags().Bool("skip-semgrep", false, "Skip Semgrep analysis")
+	cmd.Flags().StringSlice("semgrep-config", nil, "Semgrep ruleset: a rule file, directory, registry name such as p/c, or cpx for the bundled rules (repeatable)")
+	cmd.Flags().Bool("skip-cpplint", false, "Skip cpplint analysis")
+	cmd.Flags().Bool("skip-metrics", false, "Skip code metrics (complexity, length and duplication)")
 }
 
 func runAnalyze(cmd *cobra.Command, args []string) error {
-	output, _ := cmd.Flags().GetString("output")
-	skipCppcheck, _ := cmd.Flags().GetBool("skip-cppcheck")
-	skipLint, _ := cmd.Flags().GetBool("skip-lint")
-	skipFlawfinder, _ := cmd.Flags().GetBool("skip-flawfinder")
+	opts, err := analyzeOptions(cmd, args)
+	if err != nil {
+		return err
+	}
+	browse, _ := cmd.Flags().GetBool("tui")
+	return runAnalysis(cmd, opts, browse)
+}
+
+// analyzeOptions returns the analysis options of the analyze flags
+func analyzeOptions(cmd *cobra.Command, args []string) (quality.AnalyzeOptions, error) {
+	opts := quality.AnalyzeOptions{}
+	opts.Output, _ = cmd.Flags().GetString("output")
+	opts.Format, _ = cmd.Flags().GetString("format")
+	opts.SkipCppcheck, _ = cmd.Flags().GetBool("skip-cppcheck")
+	opts.SkipLint, _ = cmd.Flags().GetBool("skip-lint")
+	opts.SkipFlawfinder, _ = cmd.Flags().GetBool("skip-flawfinder")
+	opts.SkipIWYU, _ = cmd.Flags().GetBool("skip-iwyu")
+	opts.IWYUFix, _ = cmd.Flags().GetBool("iwyu-fix")
+	// Only analyze has --jobs; ci analyze runs in a container
+	opts.Jobs, _ = cmd.Flags().GetInt("jobs")
+	opts.SkipCpplint, _ = cmd.Flags().GetBool("skip-cpplint")
+	opts.SkipSemgrep, _ = cmd.Flags().GetBool("skip-semgrep")
+	opts.SkipMetrics, _ = cmd.Flags().GetBool("skip-metrics")
+	opts.WriteBaseline, _ = cmd.Flags().GetBool("write-baseline")
+	opts.NoHistory, _ = cmd.Flags().GetBool("no-history")
+	opts.Baseline, _ = cmd.Flags().GetString("baseline")
+	opts.Suppressions, _ = cmd.Flags().GetString("suppressions")
+	if opts.Output == "" {
+		opts.Output = analyzeOutputs[opts.Format]
+	}
+	if failOn, _ := cmd.Flags().GetString("fail-on"); failOn != "" && !slices.Contains(quality.FailOnSeverities, failOn) {
+		return opts, fmt.Errorf("--fail-on must be %s, not '%s'", strings.Join(quality.FailOnSeverities, ", "), failOn)
+	}
+
+	qualityCfg, err := config.LoadQuality(config.FindQualityConfig())
+	if err != nil {
+		return opts, err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return opts, fmt.Errorf("failed to load config: %w", err)
+	}
+	for _, key := range qualityCfg.MigrateDeprecated(cfg) {
+		replacement := key
+		switch key {
+		case "analyze_skip":
+			replacement = "disable"
+		case "analyzers":
+			replacement = "tools"
+		}
+		fmt.Printf("%sWarning: %s in the cpx config is deprecated; set %s in %s instead%s\n", colors.Yellow, key, replacement, config.QualityConfigFile, colors.Reset)
+	}
+	if err := applyQualityConfig(&opts, qualityCfg); err != nil {
+		return opts, err
+	}
+	if !cmd.Flags().Changed("format") && !cmd.Flags().Changed("output") {
+		for _, r := range qualityCfg.Reports {
+			opts.Reports = append(opts.Reports, quality.Report{Format: r.Format, Output: cmp.Or(r.Output, analyzeOutputs[r.Format])})
+		}
+	}
+	if cmd.Flags().Changed("semgrep-config") {
+		opts.SemgrepRulesets, _ = cmd.Flags().GetStringSlice("semgrep-config")
+	}
 
 	// Get remaining args as target directories (default to current directory)
-	targets := args
-	if len(targets) == 0 {
-		targets = []string{"."}
+	opts.Targets = args
+	if len(opts.Targets) == 0 {
+		opts.Targets = []string{"."}
 	}
+	return opts, nil
+}
+
+// applyQualityConfig applies a .cpx-quality.yaml to opts: its tools, the
+// analyzers it enables and disables, their arguments, file globs and
+// severity overrides
+func applyQualityConfig(opts *quality.AnalyzeOptions, cfg *config.QualityConfig) error {
+	for _, t := range cfg.Tools {
+		tool, err := quality.NewCommandTool(t.Name, t.Command, t.Format, t.Pattern, t.Severity)
+		if err != nil {
+			return err
+		}
+		opts.Tools = append(opts.Tools, tool)
+	}
+
+	names := slices.Concat(cfg.Enable, cfg.Disable, slices.Collect(maps.Keys(cfg.Args)))
+	for _, name := range names {
+		isTool := slices.ContainsFunc(opts.Tools, func(t quality.CommandTool) bool { return t.Name == name })
+		if !isTool && !slices.Contains(quality.AnalyzerNames, name) {
+			return fmt.Errorf("unknown analyzer '%s' in %s: use %s or a tool's name", name, config.QualityConfigFile, strings.Join(quality.AnalyzerNames, ", "))
+		}
+	}
+	enabled := func(name string) bool {
+		return (len(cfg.Enable) == 0 || slices.Contains(cfg.Enable, name)) && !slices.Contains(cfg.Disable, name)
+	}
+	opts.SkipCppcheck = opts.SkipCppcheck || !enabled("cppcheck")
+	opts.SkipLint = opts.SkipLint || !enabled("clang-tidy")
+	opts.SkipFlawfinder = opts.SkipFlawfinder || !enabled("flawfinder")
+	opts.SkipIWYU = opts.SkipIWYU || !enabled("iwyu")
+	opts.SkipSemgrep = opts.SkipSemgrep || !enabled("semgrep")
+	opts.SkipCpplint = opts.SkipCpplint || !enabled("cpplint")
+	opts.SkipMetrics = opts.SkipMetrics || !enabled("metrics")
+	opts.Tools = slices.DeleteFunc(opts.Tools, func(t quality.CommandTool) bool { return !enabled(t.Name) })
+
+	opts.Args = cfg.Args
+	opts.SemgrepRulesets = cfg.SemgrepRules
+	opts.Include = cfg.Include
+	opts.Exclude = cfg.Exclude
+	opts.Severities = cfg.Severity
+	opts.Metrics = quality.MetricsThresholds(cfg.Metrics)
+	return nil
+}
+
+// runAnalysis runs an analysis, posts its review, browses its findings when
+// browse is set and checks the thresholds of the analyze flags
+func runAnalysis(cmd *cobra.Command, opts quality.AnalyzeOptions, browse bool) error {
+	review, _ := cmd.Flags().GetBool("github-review")
+	pr, _ := cmd.Flags().GetInt("pr")
+	failOn, _ := cmd.Flags().GetString("fail-on")
+	maxWarnings, _ := cmd.Flags().GetInt("max-warnings")
 
 	// quality package needs update too, but for now passing builder logic inside quality
-	return quality.RunComprehensiveAnalysis(output, skipCppcheck, skipLint, skipFlawfinder, targets, vcpkg.New())
+	analysis, err := quality.RunComprehensiveAnalysis(opts, vcpkg.New())
+	if err != nil {
+		return err
+	}
+	if review {
+		if err := postAnalysisReview(analysis, pr); err != nil {
+			return err
+		}
+	}
+	if browse {
+		if err := browseAnalysis(analysis, "Analysis findings"); err != nil {
+			return err
+		}
+	}
+	return analysis.CheckThresholds(failOn, maxWarnings)
 }
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/internal/pkg/utils/git"
+	"github.com/spf13/cobra"
+)
+
+func analyzeDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [old.json] [new.json]",
+		Short: "Compare the findings of two analyses",
+		Long: `Compare the findings of two JSON analysis reports (of 'cpx analyze --format
+json' or recorded in .cpx/analysis/) and list the new, fixed and persisting
+ones.
+
+--against <commit> compares with the analysis of that commit recorded in
+.cpx/analysis/ instead of old.json; new.json then defaults to the latest
+recorded analysis. Findings are matched by fingerprint, their tool, rule,
+file and the code on their line, so findings whose lines moved persist.
+
+--fail-on-new exits non-zero when there are new findings, for "no new
+warnings" checks of pull requests.`,
+		Example: `  cpx analyze diff main.json analyze.json
+  cpx analyze && cpx analyze diff --against origin/main --fail-on-new
+  cpx analyze diff --against v1.2.0 --json`,
+		Args: cobra.RangeArgs(0, 2),
+		RunE: runAnalyzeDiff,
+	}
+	cmd.Flags().String("against", "", "Compare with the recorded analysis of this commit, branch or tag")
+	cmd.Flags().Bool("fail-on-new", false, "Fail when there are new findings")
+	cmd.Flags().Bool("json", false, "Print the new, fixed and persisting findings as a JSON object")
+	return cmd
+}
+
+func runAnalyzeDiff(cmd *cobra.Command, args []string) error {
+	against, _ := cmd.Flags().GetString("against")
+	failOnNew, _ := cmd.Flags().GetBool("fail-on-new")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	older, newer, err := diffInputs(against, args)
+	if err != nil {
+		return err
+	}
+	diff := quality.DiffAnalyses(older, newer)
+
+	if asJSON {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode diff: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		printAnalysisDiff(os.Stdout, diff)
+	}
+	if failOnNew && len(diff.New) > 0 {
+		return fmt.Errorf("%d new finding(s)", len(diff.New))
+	}
+	return nil
+}
+
+// diffInputs returns the older and newer analyses of the diff arguments
+func diffInputs(against string, args []string) (quality.ComprehensiveAnalysis, quality.ComprehensiveAnalysis, error) {
+	var older, newer quality.ComprehensiveAnalysis
+	var err error
+	if against == "" {
+		if len(args) != 2 {
+			return older, newer, fmt.Errorf("give two reports to compare, or --against <commit>")
+		}
+		if older, err = quality.LoadAnalysis(args[0]); err != nil {
+			return older, newer, err
+		}
+		newer, err = quality.LoadAnalysis(args[1])
+		return older, newer, err
+	}
+
+	if len(args) > 1 {
+		return older, newer, fmt.Errorf("--against compares with a single report")
+	}
+	commit, err := git.ResolveCommit(".", against)
+	if err != nil {
+		return older, newer, err
+	}
+	if older, err = quality.LoadCommitAnalysis(".", commit); err != nil {
+		return older, newer, err
+	}
+	if len(args) == 1 {
+		newer, err = quality.LoadAnalysis(args[0])
+		return older, newer, err
+	}
+	runs, err := quality.LoadHistory(".")
+	if err != nil {
+		return older, newer, err
+	}
+	if len(runs) == 0 {
+		return older, newer, fmt.Errorf("no analyses recorded in %s; run 'cpx analyze' first", quality.HistoryDir)
+	}
+	return older, runs[len(runs)-1], nil
+}
+
+// printAnalysisDiff prints the new and fixed findings of a diff and the
+// counts of each kind
+func printAnalysisDiff(w io.Writer, diff quality.AnalysisDiff) {
+	for _, section := range []struct {
+		title    string
+		color    string
+		sign     string
+		findings []quality.AnalysisResult
+	}{
+		{"New findings", colors.Red, "+", diff.New},
+		{"Fixed findings", colors.Green, "-", diff.Fixed},
+	} {
+		if len(section.findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s%s:%s\n", section.color, section.title, colors.Reset)
+		for _, r := range section.findings {
+			rule := r.Tool
+			if r.Rule != "" {
+				rule += " " + r.Rule
+			}
+			fmt.Fprintf(w, "  %s%s%s %s:%d: %s: %s [%s]\n", section.color, section.sign, colors.Reset, r.File, r.Line, r.Severity, r.Message, rule)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d new, %d fixed, %d persisting\n", len(diff.New), len(diff.Fixed), len(diff.Persisting))
+}
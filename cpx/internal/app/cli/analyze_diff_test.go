@@ -0,0 +1,21 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintAnalysisDiff(t *testing.T) {
+	var out bytes.Buffer
+	printAnalysisDiff(&out, quality.AnalysisDiff{
+		New:        []quality.AnalysisResult{{Tool: "clang-tidy", Severity: "warning", File: "src/a.cpp", Line: 3, Message: "use auto", Rule: "modernize-use-auto"}},
+		Persisting: []quality.AnalysisResult{{Tool: "Cppcheck"}, {Tool: "Cppcheck"}},
+	})
+	assert.Contains(t, out.String(), "New findings:")
+	assert.Contains(t, out.String(), "src/a.cpp:3: warning: use auto [clang-tidy modernize-use-auto]")
+	assert.NotContains(t, out.String(), "Fixed findings:")
+	assert.Contains(t, out.String(), "1 new, 0 fixed, 2 persisting")
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyQualityConfig(t *testing.T) {
+	cfg := &config.QualityConfig{
+		Enable:  []string{"cppcheck", "clang-tidy", "semgrep", "house"},
+		Disable: []string{"semgrep"},
+		Args:    map[string][]string{"cppcheck": {"--std=c++20"}},
+		Metrics: config.QualityMetrics{Complexity: 10, DuplicateTokens: -1},
+		Tools: []config.QualityTool{
+			{Name: "house", Command: "house --sarif", Format: "sarif"},
+			{Name: "other", Command: "other", Format: "jsonl"},
+		},
+	}
+	opts := quality.AnalyzeOptions{SkipLint: true}
+	require.NoError(t, applyQualityConfig(&opts, cfg))
+	assert.False(t, opts.SkipCppcheck)
+	assert.True(t, opts.SkipLint)
+	assert.True(t, opts.SkipFlawfinder)
+	assert.True(t, opts.SkipIWYU)
+	assert.True(t, opts.SkipSemgrep)
+	assert.True(t, opts.SkipCpplint)
+	assert.True(t, opts.SkipMetrics)
+	assert.Equal(t, quality.MetricsThresholds{Complexity: 10, DuplicateTokens: -1}, opts.Metrics)
+	require.Len(t, opts.Tools, 1)
+	assert.Equal(t, "house", opts.Tools[0].Name)
+	assert.Equal(t, []string{"--std=c++20"}, opts.Args["cppcheck"])
+
+	cfg = &config.QualityConfig{Disable: []string{"cppchek"}}
+	assert.EqualError(t, applyQualityConfig(&quality.AnalyzeOptions{}, cfg),
+		"unknown analyzer 'cppchek' in .cpx-quality.yaml: use cppcheck, clang-tidy, flawfinder, iwyu, semgrep, cpplint, metrics or a tool's name")
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+// trendBarWidth is the width of the bar of the largest total of the trend
+const trendBarWidth = 30
+
+func analyzeTrendCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trend",
+		Short: "Show finding counts of past analyses over time",
+		Long: `Show how the finding counts of the project changed over the analyses
+recorded in .cpx/analysis/, by severity or tool, with a bar of each run's total.
+
+Each 'cpx analyze' records its findings there, as a JSON report per commit
+(later analyses of a commit replace earlier ones; commit-dirty for a working
+tree with changes), unless --no-history is given. The counts are of the
+findings reported, without suppressed and baselined ones.`,
+		Example: `  cpx analyze trend
+  cpx analyze trend --by tool --last 10`,
+		Args: cobra.NoArgs,
+		RunE: runAnalyzeTrend,
+	}
+	cmd.Flags().String("by", "severity", "Group finding counts by severity or tool")
+	cmd.Flags().Int("last", 20, "Show the last N analyses; 0 for all")
+	return cmd
+}
+
+func runAnalyzeTrend(cmd *cobra.Command, _ []string) error {
+	by, _ := cmd.Flags().GetString("by")
+	last, _ := cmd.Flags().GetInt("last")
+	if !slices.Contains(quality.TrendBy, by) {
+		return fmt.Errorf("--by must be %s, not '%s'", strings.Join(quality.TrendBy, " or "), by)
+	}
+
+	runs, err := quality.LoadHistory(".")
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No analyses recorded in %s yet. Run 'cpx analyze' to record one.\n", quality.HistoryDir)
+		return nil
+	}
+	if last > 0 && len(runs) > last {
+		runs = runs[len(runs)-last:]
+	}
+	printTrend(os.Stdout, runs, by)
+	return nil
+}
+
+// printTrend prints a table of the finding counts of runs by severity or
+// tool, with a bar of each run's total, and the change of the total
+func printTrend(w io.Writer, runs []quality.ComprehensiveAnalysis, by string) {
+	series := quality.TrendSeries(runs, by)
+	widths := make([]int, len(series))
+	for i, name := range series {
+		widths[i] = max(len(name), 5)
+	}
+	maxTotal := 0
+	for _, run := range runs {
+		maxTotal = max(maxTotal, run.Summary.TotalFindings)
+	}
+
+	fmt.Fprintf(w, "%-16s  %-13s  %5s", "Date", "Commit", "Total")
+	for i, name := range series {
+		fmt.Fprintf(w, "  %*s", widths[i], name)
+	}
+	fmt.Fprintln(w)
+
+	for _, run := range runs {
+		commit := "-"
+		if run.Commit != "" {
+			commit = run.Commit[:min(len(run.Commit), 7)]
+			if run.Dirty {
+				commit += "-dirty"
+			}
+		}
+		fmt.Fprintf(w, "%-16s  %-13s  %5d", run.Timestamp.Local().Format("2006-01-02 15:04"), commit, run.Summary.TotalFindings)
+		counts := quality.TrendCounts(run, by)
+		for i, name := range series {
+			fmt.Fprintf(w, "  %*d", widths[i], counts[name])
+		}
+		bar := 0
+		if maxTotal > 0 {
+			bar = (run.Summary.TotalFindings*trendBarWidth + maxTotal - 1) / maxTotal
+		}
+		fmt.Fprintf(w, "  %s%s%s\n", colors.Cyan, strings.Repeat("█", bar), colors.Reset)
+	}
+
+	if len(runs) > 1 {
+		first, latest := runs[0].Summary.TotalFindings, runs[len(runs)-1].Summary.TotalFindings
+		change, color := "no change", colors.Reset
+		switch {
+		case latest < first:
+			change, color = fmt.Sprintf("%d fewer", first-latest), colors.Green
+		case latest > first:
+			change, color = fmt.Sprintf("%d more", latest-first), colors.Red
+		}
+		fmt.Fprintf(w, "\n%sFindings: %d → %d (%s) over %d analyses%s\n", color, first, latest, change, len(runs), colors.Reset)
+	}
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintTrend(t *testing.T) {
+	run := func(at time.Time, commit string, bySeverity map[string]int) quality.ComprehensiveAnalysis {
+		analysis := quality.ComprehensiveAnalysis{Timestamp: at, Commit: commit}
+		analysis.Summary.BySeverity = bySeverity
+		for _, n := range bySeverity {
+			analysis.Summary.TotalFindings += n
+		}
+		return analysis
+	}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	runs := []quality.ComprehensiveAnalysis{
+		run(start, "1111111111", map[string]int{"error": 2, "warning": 8}),
+		run(start.Add(24*time.Hour), "2222222222", map[string]int{"warning": 4}),
+	}
+
+	var out bytes.Buffer
+	printTrend(&out, runs, "severity")
+	lines := bytes.Split(out.Bytes(), []byte("\n"))
+	assert.Contains(t, string(lines[0]), "Total  error  warning")
+	assert.Contains(t, string(lines[1]), "2026-03-01 12:00  1111111           10      2        8")
+	assert.Contains(t, string(lines[2]), "2026-03-02 12:00  2222222            4      0        4")
+	assert.Contains(t, out.String(), "Findings: 10 → 4 (6 fewer) over 2 analyses")
+}
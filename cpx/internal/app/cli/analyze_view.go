@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ozacod/cpx/internal/app/cli/tui"
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/spf13/cobra"
+)
+
+func analyzeViewCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "view <report.json>",
+		Short: "Browse the findings of a JSON analysis report",
+		Long: `Browse the findings of a report of 'cpx analyze --format json' in the terminal.
+
+The list is filtered by tool (t), severity (s) and file (/, a substring or a
+glob; c clears the filters). The pane below shows the source around the
+selected finding, and Enter or e opens its file at its line in $VISUAL or
+$EDITOR.`,
+		Example: `  cpx analyze --format json
+  cpx analyze view analyze.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			analysis, err := quality.LoadAnalysis(args[0])
+			if err != nil {
+				return err
+			}
+			return browseAnalysis(analysis, args[0])
+		},
+	}
+}
+
+// browseAnalysis browses the findings of an analysis in the terminal
+func browseAnalysis(analysis quality.ComprehensiveAnalysis, title string) error {
+	var findings []tui.Finding
+	for _, tool := range analysis.Tools {
+		for _, r := range tool.Results {
+			findings = append(findings, tui.Finding{
+				Tool:     r.Tool,
+				Severity: r.Severity,
+				File:     r.File,
+				Line:     r.Line,
+				Column:   r.Column,
+				Message:  r.Message,
+				Rule:     r.Rule,
+			})
+		}
+	}
+	if len(findings) == 0 {
+		fmt.Println("No findings to browse.")
+		return nil
+	}
+	if err := tui.RunAnalysisBrowser(findings, title); err != nil {
+		return fmt.Errorf("failed to run the findings browser: %w", err)
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/ozacod/cpx/internal/pkg/build/meson"
 	"github.com/ozacod/cpx/internal/pkg/build/vcpkg"
 	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
 	"github.com/spf13/cobra"
 )
 
@@ -27,6 +28,7 @@ func BuildCmd() *cobra.Command {
   cpx build --clean      # Clean rebuild
   cpx build --asan       # Build with AddressSanitizer
   cpx build --tsan       # Build with ThreadSanitizer
+  cpx build --hardening  # Build with FORTIFY_SOURCE, stack protector, PIE, RELRO
   cpx build all          # Build all toolchains (Docker)`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runBuild(cmd, args)
@@ -44,6 +46,7 @@ func BuildCmd() *cobra.Command {
 	cmd.Flags().Bool("tsan", false, "Build with ThreadSanitizer")
 	cmd.Flags().Bool("msan", false, "Build with MemorySanitizer")
 	cmd.Flags().Bool("ubsan", false, "Build with UndefinedBehaviorSanitizer")
+	cmd.Flags().Bool("hardening", false, "Build with hardening flags and audit the resulting binaries")
 	cmd.Flags().Bool("list", false, "List available build targets")
 
 	//todo: all should be tested
@@ -78,6 +81,7 @@ func runBuild(cmd *cobra.Command, _ []string) error {
 	clean, _ := cmd.Flags().GetBool("clean")
 	optLevel, _ := cmd.Flags().GetString("opt")
 	verbose, _ := cmd.Flags().GetBool("verbose")
+	hardening, _ := cmd.Flags().GetBool("hardening")
 
 	if toolchain != "" {
 		return runToolchainBuild(ToolchainBuildOptions{
@@ -117,6 +121,10 @@ func runBuild(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("only one sanitizer can be used at a time (got %d)", sanitizerCount)
 	}
 
+	if err := applyBuildDefaults(cmd, &release, &jobs); err != nil {
+		return err
+	}
+
 	projectType := DetectProjectType()
 
 	WarnMissingBuildTools(projectType)
@@ -143,6 +151,7 @@ func runBuild(cmd *cobra.Command, _ []string) error {
 		Release:   release,
 		OptLevel:  optLevel,
 		Sanitizer: sanitizer,
+		Hardening: hardening,
 		Target:    "",
 		Jobs:      jobs,
 		Clean:     clean,
@@ -167,3 +176,23 @@ func runBuild(cmd *cobra.Command, _ []string) error {
 
 	return builder.Build(context.Background(), buildOpts)
 }
+
+// applyBuildDefaults sets release and jobs, unless given as flags, from the
+// configured build_type and jobs (.cpx.yaml, then the global config)
+func applyBuildDefaults(cmd *cobra.Command, release *bool, jobs *int) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	flagSet := func(name string) bool {
+		f := cmd.Flags().Lookup(name)
+		return f != nil && f.Changed
+	}
+	if cfg.BuildType != "" && !flagSet("release") && !flagSet("debug") {
+		*release = cfg.BuildType == "release"
+	}
+	if jobs != nil && cfg.Jobs > 0 && !flagSet("jobs") {
+		*jobs = cfg.Jobs
+	}
+	return nil
+}
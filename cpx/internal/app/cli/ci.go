@@ -2,14 +2,20 @@ package cli
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/ozacod/cpx/internal/pkg/build/bazel"
 	"github.com/ozacod/cpx/internal/pkg/build/cmake"
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
 	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
 	"github.com/ozacod/cpx/internal/pkg/build/meson"
 	"github.com/ozacod/cpx/internal/pkg/build/vcpkg"
@@ -19,40 +25,277 @@ import (
 
 type ToolchainBuildOptions struct {
 	ToolchainName     string
+	Tags              []string // build only toolchains with one of these tags
+	ExcludeTags       []string // skip toolchains with one of these tags
 	Rebuild           bool
+	Force             bool   // build even if the artifact cache holds the toolchain's artifacts
+	ChangedOnly       bool   // build only toolchains affected by changes since Base
+	Base              string // git ref for ChangedOnly (default: cpx-ci.yaml's changes.base)
+	DryRun            bool   // print the commands and build scripts instead of running them
+	Upload            bool   // push the artifacts to cpx-ci.yaml's upload destinations after a successful build
+	KeepGoing         bool   // build the remaining toolchains after a failure (parallel builds always do)
+	Resume            bool   // skip the toolchains an interrupted build of the same sources completed
+	Profile           string // cpx-ci.yaml profile applied to every toolchain
 	ExecuteAfterBuild bool
 	RunTests          bool
+	Coverage          bool // measure the coverage of the tests of CMake Docker toolchains
 	RunBenchmarks     bool
 	Verbose           bool
+	Parallel          int                 // Docker toolchains built concurrently; 0 uses cpx-ci.yaml's parallel
+	Cache             *config.CacheConfig // cpx-ci.yaml's compiler cache
+	Vcpkg             *config.VcpkgConfig // cpx-ci.yaml's vcpkg settings
+	Hooks             *config.Hooks       // cpx-ci.yaml's hooks run around every toolchain
+
+	state *runState // progress of this build, for --resume
 }
 
 func runToolchainBuild(options ToolchainBuildOptions) error {
+	_, err := buildToolchains(options)
+	return err
+}
+
+// buildToolchains builds the selected toolchains, with those a selected
+// toolchain depends on, after their dependencies and returns the result of
+// each. Sequential builds stop at the first failure, the toolchains after it
+// reported as skipped, unless they keep going; then only the toolchains
+// depending on a failed one are skipped.
+func buildToolchains(options ToolchainBuildOptions) ([]toolchainResult, error) {
 	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
 	if err != nil {
-		return fmt.Errorf("failed to load cpx-ci.yaml: %w\n  Create cpx-ci.yaml file or run 'cpx build' for local builds", err)
+		return nil, fmt.Errorf("failed to load cpx-ci.yaml: %w\n  Create cpx-ci.yaml file or run 'cpx build' for local builds", err)
+	}
+
+	// Resolve inheritance (extends) before selecting toolchains
+	allToolchains, err := ciConfig.ResolveToolchains()
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+	}
+	if options.Profile != "" {
+		if allToolchains, err = ciConfig.ApplyProfile(allToolchains, options.Profile); err != nil {
+			return nil, err
+		}
 	}
 
 	// Get toolchains to run
-	toolchains := ciConfig.Toolchains
-	if options.ToolchainName != "" {
+	toolchains, err := selectToolchains(allToolchains, options.ToolchainName)
+	if err != nil {
+		return nil, err
+	}
+	if toolchains, err = filterToolchainsByTags(toolchains, options.Tags, options.ExcludeTags); err != nil {
+		return nil, err
+	}
+	if options.ToolchainName != "" || len(options.Tags) > 0 || len(options.ExcludeTags) > 0 {
+		toolchains = config.WithDependencies(toolchains, allToolchains)
+	}
+
+	outputDir := ciConfig.GetOutputDir()
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project root: %w", err)
+	}
+
+	if options.ChangedOnly {
+		toolchains, err = changedToolchains(ciConfig, toolchains, projectRoot, options.Base)
+		if err != nil {
+			return nil, err
+		}
+		if len(toolchains) == 0 {
+			fmt.Printf("%s Nothing to build: no relevant changes%s\n", colors.Green, colors.Reset)
+			return nil, nil
+		}
+	}
+
+	if toolchains, err = config.SortByDependencies(toolchains, allToolchains); err != nil {
+		return nil, fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+	}
+
+	if options.Upload && !hasUploadDestination(ciConfig, toolchains) {
+		return nil, fmt.Errorf("--upload needs an 'upload:' section in cpx-ci.yaml")
+	}
+
+	cacheDir := ciCacheDir(projectRoot)
+	options.KeepGoing = options.KeepGoing || ciConfig.KeepGoing
+	options.Cache = ciConfig.Cache
+	options.Vcpkg = ciConfig.Vcpkg
+	options.Hooks = ciConfig.Hooks
+	if options.DryRun {
+		return nil, dryRunToolchains(ciConfig, toolchains, projectRoot, cacheDir, outputDir, options)
+	}
+	if len(toolchains) > 1 {
+		key, err := runStateKey(projectRoot, outputDir, cacheDir, options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash sources: %w", err)
+		}
+		options.state = loadRunState(cacheDir, key, options.Resume)
+	}
+	var pending []config.Toolchain
+	for _, tc := range toolchains {
+		if !options.state.done(tc.Name, outputDir) {
+			pending = append(pending, tc)
+		}
+	}
+	if options.Resume {
+		if resumed := len(toolchains) - len(pending); resumed > 0 {
+			fmt.Printf("%s Resuming: %d of %d toolchain(s) already built%s\n", colors.Cyan, resumed, len(toolchains), colors.Reset)
+		} else {
+			fmt.Printf("%sNothing to resume: building every toolchain%s\n", colors.Yellow, colors.Reset)
+		}
+	}
+	if err := clearNamedOutputs(pending, outputDir); err != nil {
+		return nil, err
+	}
+	_, version := getProjectInfo()
+
+	fmt.Printf("%s Building %d toolchain(s)...%s\n", colors.Cyan, len(toolchains), colors.Reset)
+
+	parallel := options.Parallel
+	if parallel == 0 {
+		parallel = ciConfig.Parallel
+	}
+	if parallel > 1 && len(toolchains) > 1 && !options.ExecuteAfterBuild {
+		return runParallelToolchainBuild(ciConfig, toolchains, projectRoot, cacheDir, outputDir, version, options, parallel)
+	}
+
+	var results []toolchainResult
+	var buildErr error
+	checkedMounts := false
+	failed := make(map[string]bool) // toolchains that failed or were skipped, with --keep-going
+
+	for i, tc := range toolchains {
+		if options.state.done(tc.Name, outputDir) {
+			fmt.Printf("\n%s[%d/%d] Already built: %s%s\n", colors.Green, i+1, len(toolchains), tc.Name, colors.Reset)
+			results = append(results, resumedResult(tc, outputDir))
+			continue
+		}
+		if dep := failedDependency(tc, failed); dep != "" {
+			fmt.Printf("\n%s[%d/%d] Skipped: %s ('%s' failed)%s\n", colors.Yellow, i+1, len(toolchains), tc.Name, dep, colors.Reset)
+			results = append(results, toolchainResult{Name: tc.Name, Runner: tc.Runner, Skipped: true})
+			failed[tc.Name] = true
+			continue
+		}
+
+		// Resolve runner (contains compiler settings too)
+		runner := ciConfig.FindRunner(tc.Runner)
+		if runner == nil && tc.Runner != "" {
+			buildErr = fmt.Errorf("runner '%s' not found for toolchain '%s'", tc.Runner, tc.Name)
+			if options.KeepGoing {
+				results = append(results, toolchainResult{Name: tc.Name, Runner: tc.Runner, Err: buildErr})
+				failed[tc.Name] = true
+				continue
+			}
+			results = skipToolchains(append(results, toolchainResult{Name: tc.Name, Runner: tc.Runner, Err: buildErr}), toolchains[i+1:])
+			break
+		}
+
+		runnerType := describeRunner(runner)
+		if options.ExecuteAfterBuild {
+			fmt.Printf("\n%s[%d/%d] Building and running: %s (%s)%s\n", colors.Cyan, i+1, len(toolchains), tc.Name, runnerType, colors.Reset)
+		} else {
+			fmt.Printf("\n%s[%d/%d] Building: %s (%s)%s\n", colors.Cyan, i+1, len(toolchains), tc.Name, runnerType, colors.Reset)
+		}
+
+		start := time.Now()
+		result := toolchainResult{Name: tc.Name, Runner: tc.Runner}
+		r, err := newRunner(runner)
+		if err == nil {
+			b := &runnerBuild{tc: tc, runner: runner, projectRoot: projectRoot, cacheDir: cacheDir, outputDir: outputDir, options: options, result: &result, checkedMounts: &checkedMounts}
+			if err = r.Prepare(b); err == nil {
+				err = buildOnRunner(r, b)
+			}
+		}
+		result.Duration = time.Since(start)
+		if err == nil {
+			err = finishToolchain(ciConfig, tc, result, projectRoot, cacheDir, outputDir, version)
+		}
+		if err != nil {
+			result.Err = fmt.Errorf("failed to build '%s': %w", tc.Name, err)
+			buildErr = result.Err
+			if options.KeepGoing {
+				fmt.Printf("%s Build '%s' failed, continuing%s\n", colors.Red, tc.Name, colors.Reset)
+				results = append(results, result)
+				failed[tc.Name] = true
+				continue
+			}
+			results = skipToolchains(append(results, result), toolchains[i+1:])
+			break
+		}
+		result.Artifacts = listArtifacts(filepath.Join(outputDir, tc.Name))
+		results = append(results, result)
+		options.state.complete(tc.Name)
+
+		if !options.ExecuteAfterBuild {
+			fmt.Printf("%s Build '%s' succeeded%s\n", colors.Green, tc.Name, colors.Reset)
+		}
+	}
+
+	if options.ExecuteAfterBuild {
+		return results, buildErr
+	}
+	failures := printToolchainSummary(results)
+	if buildErr != nil {
+		if options.KeepGoing {
+			return results, fmt.Errorf("%d of %d toolchain(s) failed", failures, len(results))
+		}
+		return results, buildErr
+	}
+	fmt.Printf("\n%s All builds completed successfully!%s\n", colors.Green, colors.Reset)
+	fmt.Printf("   Artifacts are in: %s\n", outputDir)
+	if err := finishArtifacts(ciConfig, outputDir, toolchains, version, options.Upload); err != nil {
+		return results, err
+	}
+	options.state.clear()
+	return results, nil
+}
+
+// resumedResult returns the result of a toolchain an interrupted build completed
+func resumedResult(tc config.Toolchain, outputDir string) toolchainResult {
+	return toolchainResult{Name: tc.Name, Runner: tc.Runner, Resumed: true, Artifacts: listArtifacts(filepath.Join(outputDir, tc.Name))}
+}
+
+// failedDependency returns a toolchain tc depends on that failed, if any
+func failedDependency(tc config.Toolchain, failed map[string]bool) string {
+	for _, dep := range tc.DependsOn {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+// skipToolchains appends the toolchains a sequential build skips after a failure to results
+func skipToolchains(results []toolchainResult, rest []config.Toolchain) []toolchainResult {
+	for _, tc := range rest {
+		results = append(results, toolchainResult{Name: tc.Name, Runner: tc.Runner, Skipped: true})
+	}
+	return results
+}
+
+// selectToolchains returns the named toolchain, or every active toolchain when name is empty
+func selectToolchains(allToolchains []config.Toolchain, name string) ([]config.Toolchain, error) {
+	toolchains := allToolchains
+	if name != "" {
 		found := false
-		for _, t := range ciConfig.Toolchains {
-			if t.Name == options.ToolchainName {
+		for _, t := range allToolchains {
+			if t.Name == name {
 				toolchains = []config.Toolchain{t}
 				found = true
 				if !t.IsActive() {
-					fmt.Printf("%sWarning: Toolchain '%s' is marked as inactive%s\n", colors.Yellow, options.ToolchainName, colors.Reset)
+					fmt.Printf("%sWarning: Toolchain '%s' is marked as inactive%s\n", colors.Yellow, name, colors.Reset)
 				}
 				break
 			}
 		}
 		if !found {
-			return fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", options.ToolchainName)
+			return nil, fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", name)
 		}
 	} else {
 		var activeToolchains []config.Toolchain
 		var skippedCount int
-		for _, t := range ciConfig.Toolchains {
+		for _, t := range allToolchains {
 			if t.IsActive() {
 				activeToolchains = append(activeToolchains, t)
 			} else {
@@ -66,125 +309,256 @@ func runToolchainBuild(options ToolchainBuildOptions) error {
 	}
 
 	if len(toolchains) == 0 {
-		return fmt.Errorf("no active toolchains defined in cpx-ci.yaml")
+		return nil, fmt.Errorf("no active toolchains defined in cpx-ci.yaml")
 	}
+	return toolchains, nil
+}
 
-	outputDir := ciConfig.GetOutputDir()
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+// filterToolchainsByTags keeps the toolchains with one of tags, when given,
+// and none of exclude
+func filterToolchainsByTags(toolchains []config.Toolchain, tags, exclude []string) ([]config.Toolchain, error) {
+	if len(tags) == 0 && len(exclude) == 0 {
+		return toolchains, nil
 	}
+	var filtered []config.Toolchain
+	for _, t := range toolchains {
+		if (len(tags) == 0 || t.HasAnyTag(tags)) && !t.HasAnyTag(exclude) {
+			filtered = append(filtered, t)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no toolchains match the selected tags")
+	}
+	return filtered, nil
+}
 
-	fmt.Printf("%s Building %d toolchain(s)...%s\n", colors.Cyan, len(toolchains), colors.Reset)
-
-	projectRoot, err := findProjectRoot()
-	if err != nil {
-		return fmt.Errorf("failed to get project root: %w", err)
+// runnerEndpoint returns the Docker engine a runner builds on
+func runnerEndpoint(runner *config.Runner) (docker.Endpoint, error) {
+	host, dockerContext := runnerEngine(runner)
+	if host == "" && dockerContext == "" {
+		return docker.Endpoint{}, nil
 	}
+	return docker.ResolveEndpoint(host, dockerContext)
+}
 
-	for i, tc := range toolchains {
-		// Resolve runner (contains compiler settings too)
-		runner := ciConfig.FindRunner(tc.Runner)
-		if runner == nil && tc.Runner != "" {
-			return fmt.Errorf("runner '%s' not found for toolchain '%s'", tc.Runner, tc.Name)
-		}
+// runnerEngine returns the DOCKER_HOST value or CLI context of a runner, by
+// default those of the configuration (`cpx config set docker_host`)
+func runnerEngine(runner *config.Runner) (host, dockerContext string) {
+	if runner.DockerHost != "" || runner.DockerContext != "" {
+		return runner.DockerHost, runner.DockerContext
+	}
+	if cfg, err := config.Load(); err == nil {
+		return cfg.DockerHost, cfg.DockerContext
+	}
+	return "", ""
+}
 
-		// Determine runner type
-		runnerType := "native"
-		if runner != nil && runner.Type != "" {
-			runnerType = runner.Type
-		}
+// remoteUploadExcludes are project paths not streamed to remote Docker engines
+var remoteUploadExcludes = []string{"./.git", "./.cache", "./.cpx", "./.bin", "./bazel-*"}
 
-		if options.ExecuteAfterBuild {
-			fmt.Printf("\n%s[%d/%d] Building and running: %s (%s)%s\n", colors.Cyan, i+1, len(toolchains), tc.Name, runnerType, colors.Reset)
-		} else {
-			fmt.Printf("\n%s[%d/%d] Building: %s (%s)%s\n", colors.Cyan, i+1, len(toolchains), tc.Name, runnerType, colors.Reset)
+// runDockerToolchain runs a Docker build. Remote engines cannot bind mount
+// host paths, so the project is streamed into a volume before the build and
+// the artifacts are streamed back afterwards. Services the build uses run
+// next to it until it ends.
+func runDockerToolchain(ctx context.Context, builder build.DockerBuilder, opts build.DockerBuildOptions) error {
+	if opts.UsesServices() {
+		stop, err := build.StartServices(&opts)
+		if err != nil {
+			return err
 		}
+		defer stop()
+	}
+	if !opts.Endpoint.Remote {
+		return builder.RunDockerBuild(ctx, opts)
+	}
 
-		// Build environment with compiler settings from runner
-		env := tc.Env
-		if env == nil {
-			env = make(map[string]string)
+	projectRoot, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+	fmt.Fprintf(opts.Stdout(), "  %s Uploading project to %s...%s\n", colors.Yellow, opts.Endpoint, colors.Reset)
+	if err := opts.Endpoint.Upload(projectRoot, opts.ImageName, remoteUploadExcludes...); err != nil {
+		return fmt.Errorf("failed to upload project: %w", err)
+	}
+	// Meson mounts subprojects/ separately so wraps downloaded earlier are reused
+	if _, err := os.Stat(filepath.Join(projectRoot, "meson.build")); err == nil {
+		subprojects := filepath.Join(projectRoot, "subprojects")
+		if err := os.MkdirAll(subprojects, 0755); err != nil {
+			return fmt.Errorf("failed to create subprojects directory: %w", err)
 		}
-		if runner != nil {
-			if runner.CC != "" {
-				env["CC"] = runner.CC
-			}
-			if runner.CXX != "" {
-				env["CXX"] = runner.CXX
-			}
+		if err := opts.Endpoint.Upload(subprojects, opts.ImageName); err != nil {
+			return fmt.Errorf("failed to upload subprojects: %w", err)
 		}
+	}
 
-		// Get CMake toolchain file if specified in runner
-		cmakeToolchainFile := ""
-		if runner != nil && runner.CMakeToolchainFile != "" {
-			cmakeToolchainFile = runner.CMakeToolchainFile
-		}
+	if err := builder.RunDockerBuild(ctx, opts); err != nil {
+		return err
+	}
 
-		if runner == nil || runner.IsNative() {
-			if err := runNativeBuildNew(tc, runner, projectRoot, outputDir, options.RunTests, options.RunBenchmarks); err != nil {
-				return fmt.Errorf("failed to build '%s': %w", tc.Name, err)
-			}
-		} else if runner.IsDocker() {
-			imageName, err := resolveDockerImageNew(runner)
-			if err != nil {
-				return fmt.Errorf("failed to resolve Docker image for '%s': %w", tc.Name, err)
-			}
+	fmt.Fprintf(opts.Stdout(), "  %s Downloading artifacts...%s\n", colors.Yellow, colors.Reset)
+	outputDir := filepath.Join(projectRoot, opts.OutputDir)
+	if err := opts.Endpoint.Download(outputDir, opts.ImageName); err != nil {
+		return fmt.Errorf("failed to download artifacts: %w", err)
+	}
+	if opts.Hardening {
+		return build.FprintHardeningAudit(opts.Stdout(), filepath.Join(outputDir, opts.TargetName))
+	}
+	return nil
+}
 
-			var dockerBuilder build.DockerBuilder
-			if _, err := os.Stat(filepath.Join(projectRoot, "MODULE.bazel")); err == nil {
-				dockerBuilder = bazel.New()
-			} else if _, err := os.Stat(filepath.Join(projectRoot, "meson.build")); err == nil {
-				dockerBuilder = meson.New()
-			} else {
-				dockerBuilder = vcpkg.New()
-			}
+// dockerBuilderFor picks the Docker builder matching the project's build system
+func dockerBuilderFor(projectRoot string) build.DockerBuilder {
+	if _, err := os.Stat(filepath.Join(projectRoot, "MODULE.bazel")); err == nil {
+		return bazel.New()
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, "meson.build")); err == nil {
+		return meson.New()
+	}
+	return vcpkg.New()
+}
 
-			// Set defaults for optimization and jobs if not specified in toolchain
-			optLevel := tc.Optimization
-			if optLevel == "" {
-				optLevel = "2"
-			}
-			jobs := tc.Jobs
-
-			opts := build.DockerBuildOptions{
-				ImageName:         imageName,
-				ProjectRoot:       projectRoot,
-				OutputDir:         outputDir,
-				BuildType:         tc.BuildType,
-				Optimization:      optLevel,
-				CMakeArgs:         tc.CMakeOptions,
-				BuildArgs:         tc.BuildOptions,
-				Jobs:              jobs,
-				Env:               env,
-				ExecuteAfterBuild: options.ExecuteAfterBuild,
-				RunTests:          options.RunTests,
-				RunBenchmarks:     options.RunBenchmarks,
-				TargetName:        tc.Name,
-				Verbose:           options.Verbose,
-			}
+// dockerJobs returns the parallel jobs of a toolchain's Docker build: its
+// jobs, else its CPU limit rounded up, since build tools inside a limited
+// container still see every host CPU.
+func dockerJobs(tc config.Toolchain) int {
+	if tc.Jobs == 0 && tc.Resources != nil && tc.Resources.CPUs > 0 {
+		return int(math.Ceil(tc.Resources.CPUs))
+	}
+	return tc.Jobs
+}
 
-			// Add toolchain file to CMake args if specified
-			if cmakeToolchainFile != "" {
-				opts.CMakeArgs = append(opts.CMakeArgs, "-DCMAKE_TOOLCHAIN_FILE="+cmakeToolchainFile)
-			}
+// containerUser returns the uid:gid a runner's build containers run as: its
+// docker_user, or the host user for a local engine on Linux, where files the
+// container writes into the mounted cache and output directories would
+// otherwise belong to root. Docker Desktop maps ownership itself, and remote
+// engines build in volumes.
+func containerUser(runner *config.Runner, endpoint docker.Endpoint) string {
+	switch {
+	case runner.DockerUser == "root":
+		return ""
+	case runner.DockerUser != "":
+		return runner.DockerUser
+	case runtime.GOOS != "linux" || endpoint.Remote || os.Getuid() == 0:
+		return ""
+	default:
+		return fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	}
+}
 
-			if err := dockerBuilder.RunDockerBuild(context.Background(), opts); err != nil {
-				return fmt.Errorf("failed to build '%s': %w", tc.Name, err)
-			}
-		} else if runner.IsSSH() {
-			return fmt.Errorf("SSH runner not yet implemented for toolchain '%s'", tc.Name)
-		}
+// toolchainDockerOptions builds the Docker build options for a toolchain on a Docker runner
+func toolchainDockerOptions(tc config.Toolchain, runner *config.Runner, endpoint docker.Endpoint, imageName, projectRoot, cacheDir, outputDir string, options ToolchainBuildOptions) build.DockerBuildOptions {
+	// Build environment with compiler settings from runner
+	env := make(map[string]string, len(tc.Env)+2)
+	for k, v := range tc.Env {
+		env[k] = v
+	}
+	cc, cxx := runnerCompilers(runner)
+	if cc != "" {
+		env["CC"] = cc
+	}
+	if cxx != "" {
+		env["CXX"] = cxx
+	}
 
-		if !options.ExecuteAfterBuild {
-			fmt.Printf("%s Build '%s' succeeded%s\n", colors.Green, tc.Name, colors.Reset)
-		}
+	// Set defaults for optimization if not specified in toolchain
+	optLevel := tc.Optimization
+	if optLevel == "" {
+		optLevel = "2"
 	}
 
-	if !options.ExecuteAfterBuild {
-		fmt.Printf("\n%s All builds completed successfully!%s\n", colors.Green, colors.Reset)
-		fmt.Printf("   Artifacts are in: %s\n", outputDir)
+	var resources config.Resources
+	if tc.Resources != nil {
+		resources = *tc.Resources
+	}
+
+	opts := build.DockerBuildOptions{
+		ImageName:         imageName,
+		ProjectRoot:       projectRoot,
+		OutputDir:         outputDir,
+		CacheDir:          cacheDir,
+		BuildType:         tc.BuildType,
+		Optimization:      optLevel,
+		CMakeArgs:         slices.Clone(tc.CMakeOptions),
+		BuildArgs:         tc.BuildOptions,
+		Jobs:              dockerJobs(tc),
+		Env:               env,
+		ExecuteAfterBuild: options.ExecuteAfterBuild,
+		RunTests:          options.RunTests,
+		RunBenchmarks:     options.RunBenchmarks,
+		Platform:          runnerPlatform(runner),
+		TargetName:        tc.Name,
+		Verbose:           options.Verbose,
+		Hardening:         tc.Hardening,
+		CCache:            tc.CCache,
+		User:              containerUser(runner, endpoint),
+		CPUs:              resources.CPUs,
+		Memory:            resources.Memory,
+		Network:           runner.Network,
+		ExtraHosts:        runner.ExtraHosts,
+		DNS:               runner.DNS,
+		// Toolchains built in parallel on a remote engine each get their own
+		// project and output volumes
+		Endpoint: endpoint.Scoped(tc.Name),
+	}
+	for _, svc := range tc.Services {
+		opts.Services = append(opts.Services, build.Service{
+			Name:      svc.Name,
+			Image:     svc.Image,
+			Env:       svc.Env,
+			Ports:     svc.Ports,
+			Command:   svc.Command,
+			HealthCmd: svc.HealthCmd,
+		})
+	}
+
+	// Target presets configure the cross toolchain; the hardening flags and
+	// audit only apply to dynamically linked ELF binaries
+	if target, _ := runnerTarget(runner); target != nil {
+		opts.CrossTarget = target.Name
+		opts.CMakeArgs = append(slices.Clone(target.CMakeArgs), opts.CMakeArgs...)
+		opts.Triplet = target.Triplet
+		opts.ArtifactPatterns = target.Artifacts
+		opts.ConfigureWrapper = target.ConfigureWrapper
+		opts.Hardening = opts.Hardening && target.Hardening
+		opts.VerifyStatic = target.VerifyStatic
+	}
+	// The toolchain's own triplet wins over the preset's, so the build and
+	// 'cpx ci prefetch' install dependencies for the same one
+	if triplet := tc.Env["VCPKG_DEFAULT_TRIPLET"]; triplet != "" {
+		opts.Triplet = triplet
+	}
+	if triplet := cmakeDefinition(tc.CMakeOptions, "VCPKG_TARGET_TRIPLET"); triplet != "" {
+		opts.Triplet = triplet
+	}
+	if options.RunTests && options.Coverage {
+		opts.Coverage = coverageCompiler(runner)
+	}
+	opts.PassEnv = append(slices.Clone(tc.EnvPassthrough), tc.Secrets...)
+	opts.Secrets = tc.Secrets
+	applyCompilerCache(&opts, options.Cache)
+	applyBinarySources(&opts, options.Vcpkg)
+
+	// Add toolchain file to CMake args if specified
+	if runner.CMakeToolchainFile != "" {
+		opts.CMakeArgs = append(opts.CMakeArgs, "-DCMAKE_TOOLCHAIN_FILE="+runner.CMakeToolchainFile)
+	}
+	return opts
+}
+
+// cmakeDefinition returns the value the last -D<name>[:<type>]=<value> of
+// args defines name to, or "" if none does
+func cmakeDefinition(args []string, name string) string {
+	value := ""
+	for _, arg := range args {
+		def, ok := strings.CutPrefix(arg, "-D"+name)
+		if !ok || !strings.HasPrefix(def, "=") && !strings.HasPrefix(def, ":") {
+			continue
+		}
+		if _, v, ok := strings.Cut(def, "="); ok {
+			value = v
+		}
 	}
-	return nil
+	return value
 }
 
 func findProjectRoot() (string, error) {
@@ -211,55 +585,178 @@ func findProjectRoot() (string, error) {
 	return cwd, nil
 }
 
-// resolveDockerImageNew verifies the Docker image exists locally
-func resolveDockerImageNew(runner *config.Runner) (string, error) {
-	if runner.Image == "" {
-		return "", fmt.Errorf("Docker runner '%s' has no image specified", runner.Name)
+// ciCacheDir returns the host directory for CI build caches. Under WSL2 with the
+// project on a Windows drive, the 9p mount makes builds very slow, so the user is
+// offered to keep caches on the ext4 filesystem instead (the answer is remembered).
+func ciCacheDir(projectRoot string) string {
+	defaultDir := filepath.Join(projectRoot, ".cache", "ci")
+	if !docker.IsWSL2() || !docker.OnWindowsMount(projectRoot) {
+		return defaultDir
 	}
-	imageName := runner.Image
 
-	// Check if image exists locally
-	cmd := exec.Command("docker", "images", "-q", imageName)
-	output, err := cmd.Output()
-	if err != nil || len(output) == 0 {
-		return "", fmt.Errorf("Docker image '%s' not found locally. Use 'docker pull %s' to download it first", imageName, imageName)
+	fmt.Printf("%s⚠ %s is on a Windows drive; builds over the WSL 9p mount are slow%s\n", colors.Yellow, projectRoot, colors.Reset)
+	fmt.Printf("  %shint: move the project under your WSL home directory for the best performance%s\n", colors.Gray, colors.Reset)
+
+	cfg, err := config.LoadGlobal()
+	if err != nil {
+		return defaultDir
+	}
+	if cfg.RelocateWSLCaches == nil {
+		if !isInteractive() {
+			fmt.Printf("  %shint: set 'relocate_wsl_caches: true' in the cpx config to keep caches on ext4%s\n", colors.Gray, colors.Reset)
+			return defaultDir
+		}
+		fmt.Printf("  Keep build caches on the WSL filesystem (~/.cache/cpx) instead? [Y/n] ")
+		var answer string
+		_, _ = fmt.Scanln(&answer)
+		relocate := answer == "" || strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+		cfg.RelocateWSLCaches = &relocate
+		if err := config.SaveGlobal(cfg); err != nil {
+			fmt.Printf("  %sWarning: failed to save choice: %v%s\n", colors.Yellow, err, colors.Reset)
+		}
+	}
+	if !*cfg.RelocateWSLCaches {
+		return defaultDir
 	}
 
-	fmt.Printf("  %s Using Docker image: %s%s\n", colors.Green, imageName, colors.Reset)
-	return imageName, nil
+	dir := wslCacheDir(projectRoot)
+	if dir == "" {
+		return defaultDir
+	}
+	fmt.Printf("  %sUsing build caches in %s%s\n", colors.Gray, dir, colors.Reset)
+	return dir
 }
 
-// runNativeBuildNew runs a native CMake build with new config structure
-func runNativeBuildNew(tc config.Toolchain, runner *config.Runner, projectRoot, outputDir string, runTests bool, runBenchmarks bool) error {
-	projectType := DetectProjectType()
-	missing := WarnMissingBuildTools(projectType)
-	if len(missing) > 0 {
-		fmt.Printf("  %sNote: Native build may fail due to missing tools%s\n", colors.Yellow, colors.Reset)
+// configuredCICacheDir returns the CI cache directory without prompting,
+// honouring a saved relocate_wsl_caches choice.
+func configuredCICacheDir(projectRoot string) string {
+	if docker.IsWSL2() && docker.OnWindowsMount(projectRoot) {
+		if cfg, err := config.Load(); err == nil && cfg.RelocateWSLCaches != nil && *cfg.RelocateWSLCaches {
+			if dir := wslCacheDir(projectRoot); dir != "" {
+				return dir
+			}
+		}
 	}
+	return filepath.Join(projectRoot, ".cache", "ci")
+}
 
-	targetOutputDir := filepath.Join(outputDir, tc.Name)
-	if err := os.MkdirAll(targetOutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create target output directory: %w", err)
+// wslCacheDir returns the per-project cache directory on the WSL ext4 filesystem
+func wslCacheDir(projectRoot string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
+	sum := sha256.Sum256([]byte(projectRoot))
+	return filepath.Join(home, ".cache", "cpx", fmt.Sprintf("%s-%x", filepath.Base(projectRoot), sum[:4]), "ci")
+}
 
-	hostBuildDir := filepath.Join(projectRoot, ".cache", "ci", tc.Name)
-	if err := os.MkdirAll(hostBuildDir, 0755); err != nil {
-		return fmt.Errorf("failed to create build directory: %w", err)
+// warnUnsharedPaths warns when bind-mounted paths are outside the folders the
+// container engine's VM shares with the host (Colima, Rancher Desktop, Podman
+// machine); such mounts appear empty inside the container.
+func warnUnsharedPaths(paths ...string) {
+	rt := docker.DetectRuntime()
+	for _, p := range rt.Unshared(paths...) {
+		fmt.Fprintf(os.Stderr, "%s⚠ %s is not shared with the %s VM; it will be empty inside containers%s\n", colors.Yellow, p, rt.Name, colors.Reset)
+		fmt.Fprintf(os.Stderr, "  %shint: add it to the VM mounts or move the project under %s%s\n", colors.Gray, rt.SharedPaths[0], colors.Reset)
 	}
+}
 
-	absBuildDir, err := filepath.Abs(hostBuildDir)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path for build directory: %w", err)
+// describePlatform returns "docker, <platform>, native|emulated" for a Docker
+// runner, or "docker@<endpoint>, <platform>" for a remote engine. Without an
+// explicit platform the image decides, as in warnEmulatedPlatform, and it is
+// not resolved yet, so the host's platform is not assumed.
+func describePlatform(runner *config.Runner) string {
+	platform := runnerPlatform(runner)
+	host, dockerContext := runnerEngine(runner)
+	if endpoint := (docker.Endpoint{Host: host, Context: dockerContext}); endpoint.String() != "" {
+		if platform == "" {
+			platform = "engine platform"
+		}
+		return "docker@" + endpoint.String() + ", " + platform
 	}
-	absProjectRoot, err := filepath.Abs(projectRoot)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	if platform == "" {
+		return "docker, image platform"
 	}
-	absOutputDir, err := filepath.Abs(targetOutputDir)
+	return "docker, " + docker.DescribePlatform(platform)
+}
+
+// warnEmulatedPlatform warns when a Docker runner will run under emulation on
+// this host (e.g. linux/amd64 images on Apple Silicon). Without an explicit
+// platform, the architecture of the local image decides. Remote engines run
+// on their own hardware and are not checked.
+func warnEmulatedPlatform(endpoint docker.Endpoint, runner *config.Runner, imageName string) {
+	if endpoint.Remote {
+		return
+	}
+	platform := runnerPlatform(runner)
+	if platform == "" {
+		platform = endpoint.ImagePlatform(imageName)
+	}
+	if !docker.IsEmulated(platform) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "  %s⚠ %s runs emulated on this %s host (%s); expect builds to be several times slower%s\n",
+		colors.Yellow, platform, docker.HostPlatform(), docker.Emulator(), colors.Reset)
+	if runner.Platform == "" && runnerPlatform(runner) != "" {
+		// The target preset has no native alternative
+		return
+	}
+	fmt.Fprintf(os.Stderr, "  %shint: use a multi-arch image and set 'platform: %s' on runner '%s', or build on a remote %s engine with 'docker_host'%s\n",
+		colors.Gray, docker.HostPlatform(), runner.Name, platform, colors.Reset)
+}
+
+// checkQEMU fails early when a runner's platform needs emulation this host's
+// kernel has no QEMU handler for; images and builds would otherwise fail with
+// "exec format error".
+func checkQEMU(endpoint docker.Endpoint, runner *config.Runner) error {
+	platform := runnerPlatform(runner)
+	if endpoint.Remote || !docker.IsEmulated(platform) {
+		return nil
+	}
+	if registered, known := docker.BinfmtRegistered(platform); known && !registered {
+		return fmt.Errorf("no QEMU handler is registered for %s; run 'cpx ci setup-qemu' first", platform)
+	}
+	return nil
+}
+
+// resolveDockerImageNew verifies the Docker image exists locally. Build-mode
+// runners get their image built when no image with the current hash tag exists.
+func resolveDockerImageNew(endpoint docker.Endpoint, projectRoot string, runner *config.Runner, verbose bool) (string, error) {
+	if runner.Image == "" && !runnerBuildsImage(runner) {
+		return "", fmt.Errorf("Docker runner '%s' has no image specified", runner.Name)
+	}
+	if err := checkQEMU(endpoint, runner); err != nil {
+		return "", err
+	}
+	imageName, err := runnerImage(projectRoot, runner)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path for output directory: %w", err)
+		return "", err
+	}
+
+	// Check if image exists on the engine
+	cmd := endpoint.Command("images", "-q", imageName)
+	output, err := cmd.Output()
+	if err != nil || len(output) == 0 {
+		if !runnerBuildsImage(runner) {
+			if endpoint.Context != "" {
+				return "", fmt.Errorf("Docker image '%s' not found on %s. Use 'docker --context %s pull %s' to download it first", imageName, endpoint, endpoint.Context, imageName)
+			}
+			if endpoint.Host != "" {
+				return "", fmt.Errorf("Docker image '%s' not found on %s. Use 'DOCKER_HOST=%s docker pull %s' to download it first", imageName, endpoint, endpoint.Host, imageName)
+			}
+			return "", fmt.Errorf("Docker image '%s' not found locally. Use 'docker pull %s' to download it first", imageName, imageName)
+		}
+		if err := pullOrBuildRunnerImage(endpoint, projectRoot, runner, imageName, verbose); err != nil {
+			return "", err
+		}
 	}
 
+	fmt.Fprintf(os.Stderr, "  %s Using Docker image: %s%s\n", colors.Green, imageName, colors.Reset)
+	return imageName, nil
+}
+
+// nativeCMakeArgs returns the CMake configure arguments for a native toolchain build
+func nativeCMakeArgs(tc config.Toolchain, runner *config.Runner, absProjectRoot, absBuildDir string, runTests, runBenchmarks bool) []string {
 	buildType := tc.BuildType
 	if buildType == "" {
 		buildType = "Release"
@@ -275,8 +772,29 @@ func runNativeBuildNew(tc config.Toolchain, runner *config.Runner, projectRoot,
 		"-B", absBuildDir,
 		"-S", absProjectRoot,
 		"-DCMAKE_BUILD_TYPE=" + buildType,
-		"-DCMAKE_CXX_FLAGS=-O" + optLevel,
+		"-DCMAKE_EXPORT_COMPILE_COMMANDS=ON", // for cpx compdb
+	}
+
+	cxxFlags := "-O" + optLevel
+	if tc.Hardening {
+		var cc, cxx string
+		if runner != nil {
+			cc, cxx = runnerCompilers(runner)
+		}
+		msvc := build.UsesMSVCFlags(cxx, cc, tc.Env["CXX"], tc.Env["CC"])
+		compile, link := build.HardeningFlags(msvc, !strings.EqualFold(buildType, "Debug") && optLevel != "0")
+		compileFlags := strings.Join(compile, " ")
+		linkFlags := strings.Join(link, " ")
+		cxxFlags += " " + compileFlags
+		cmakeArgs = append(cmakeArgs,
+			"-DCMAKE_C_FLAGS="+compileFlags,
+			"-DCMAKE_EXE_LINKER_FLAGS="+linkFlags,
+			"-DCMAKE_SHARED_LINKER_FLAGS="+linkFlags)
+	} else {
+		// The build directory is reused: drop an earlier hardened build's flags
+		cmakeArgs = append(cmakeArgs, cmake.UnsetFlagArgs...)
 	}
+	cmakeArgs = append(cmakeArgs, "-DCMAKE_CXX_FLAGS="+cxxFlags)
 
 	// Add toolchain file if specified in runner
 	if runner != nil && runner.CMakeToolchainFile != "" {
@@ -292,6 +810,63 @@ func runNativeBuildNew(tc config.Toolchain, runner *config.Runner, projectRoot,
 	}
 
 	cmakeArgs = append(cmakeArgs, tc.CMakeOptions...)
+	return cmakeArgs
+}
+
+// nativeBuildArgs returns the `cmake --build` arguments for a native toolchain build
+func nativeBuildArgs(tc config.Toolchain, projectRoot, absBuildDir string, runBenchmarks bool) []string {
+	buildType := tc.BuildType
+	if buildType == "" {
+		buildType = "Release"
+	}
+	buildArgs := []string{"--build", absBuildDir, "--config", buildType}
+	if tc.Jobs > 0 {
+		buildArgs = append(buildArgs, "--parallel", fmt.Sprintf("%d", tc.Jobs))
+	}
+	buildArgs = append(buildArgs, tc.BuildOptions...)
+	if runBenchmarks {
+		projectName := cmake.GetProjectNameFromCMakeLists()
+		if projectName == "" {
+			projectName = filepath.Base(projectRoot)
+		}
+		buildArgs = append(buildArgs, "--target", "all", projectName+"_bench")
+	}
+	return buildArgs
+}
+
+// runNativeBuildNew runs a native CMake build with new config structure
+func runNativeBuildNew(tc config.Toolchain, runner *config.Runner, projectRoot, cacheDir, outputDir string, runTests bool, runBenchmarks bool) error {
+	projectType := DetectProjectType()
+	missing := WarnMissingBuildTools(projectType)
+	if len(missing) > 0 {
+		fmt.Printf("  %sNote: Native build may fail due to missing tools%s\n", colors.Yellow, colors.Reset)
+	}
+
+	targetOutputDir := filepath.Join(outputDir, tc.Name)
+	if err := os.MkdirAll(targetOutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create target output directory: %w", err)
+	}
+
+	hostBuildDir := filepath.Join(cacheDir, tc.Name)
+	if err := os.MkdirAll(hostBuildDir, 0755); err != nil {
+		return fmt.Errorf("failed to create build directory: %w", err)
+	}
+
+	absBuildDir, err := filepath.Abs(hostBuildDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for build directory: %w", err)
+	}
+	absProjectRoot, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+	absOutputDir, err := filepath.Abs(targetOutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for output directory: %w", err)
+	}
+
+	cmakeArgs := nativeCMakeArgs(tc, runner, absProjectRoot, absBuildDir, runTests, runBenchmarks)
+	build.StartPhases(absBuildDir)
 
 	// Set environment variables
 	env := os.Environ()
@@ -308,6 +883,7 @@ func runNativeBuildNew(tc config.Toolchain, runner *config.Runner, projectRoot,
 	}
 
 	fmt.Printf("  %s Configuring CMake (Ninja)...%s\n", colors.Yellow, colors.Reset)
+	build.RecordPhase(absBuildDir, "configure")
 	cmd := exec.Command("cmake", cmakeArgs...)
 	cmd.Env = env
 	cmd.Stdout = os.Stdout
@@ -317,19 +893,8 @@ func runNativeBuildNew(tc config.Toolchain, runner *config.Runner, projectRoot,
 	}
 
 	fmt.Printf("  %s Building...%s\n", colors.Cyan, colors.Reset)
-	buildArgs := []string{"--build", absBuildDir, "--config", buildType}
-	if tc.Jobs > 0 {
-		buildArgs = append(buildArgs, "--parallel", fmt.Sprintf("%d", tc.Jobs))
-	}
-	buildArgs = append(buildArgs, tc.BuildOptions...)
-
-	if runBenchmarks {
-		projectName := cmake.GetProjectNameFromCMakeLists()
-		if projectName == "" {
-			projectName = filepath.Base(projectRoot)
-		}
-		buildArgs = append(buildArgs, "--target", "all", projectName+"_bench")
-	}
+	buildArgs := nativeBuildArgs(tc, projectRoot, absBuildDir, runBenchmarks)
+	build.RecordPhase(absBuildDir, "build")
 
 	cmd = exec.Command("cmake", buildArgs...)
 	cmd.Env = env
@@ -341,6 +906,7 @@ func runNativeBuildNew(tc config.Toolchain, runner *config.Runner, projectRoot,
 
 	// Copy outputs
 	fmt.Printf("  %s Copying artifacts...%s\n", colors.Yellow, colors.Reset)
+	build.RecordPhase(absBuildDir, "copy")
 
 	// Find executable
 	entries, err := os.ReadDir(absBuildDir)
@@ -365,6 +931,13 @@ func runNativeBuildNew(tc config.Toolchain, runner *config.Runner, projectRoot,
 			}
 		}
 	}
+	build.RecordPhase(absBuildDir, "end")
+
+	if tc.Hardening {
+		if err := build.PrintHardeningAudit(absOutputDir); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
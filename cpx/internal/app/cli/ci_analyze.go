@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/spf13/cobra"
+)
+
+func runCIAnalyze(cmd *cobra.Command, args []string) error {
+	target, _ := cmd.Flags().GetString("target")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	opts, err := analyzeOptions(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	var compileDbDir string
+	switch detectBuildSystem() {
+	case "vcpkg":
+		compileDbDir = "/tmp/build"
+	case "meson":
+		compileDbDir = "/tmp/builddir"
+	default:
+		return fmt.Errorf("ci analyze needs a CMake or Meson project; Bazel builds write no compilation database")
+	}
+
+	builder, dockerOpts, err := toolchainShellOptions(target, verbose)
+	if err != nil {
+		return err
+	}
+	buildDir := filepath.Join(dockerOpts.CacheRoot(), dockerOpts.TargetName)
+	if !dockerOpts.Endpoint.Remote && !CheckFileExists(filepath.Join(buildDir, compdbFile)) {
+		return fmt.Errorf("no compilation database for '%s'\n  hint: run 'cpx ci build --toolchain %s' first", target, target)
+	}
+	projectRoot, err := filepath.Abs(dockerOpts.ProjectRoot)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+
+	opts.Container = &quality.ContainerAnalysis{
+		CompileDbDir: compileDbDir,
+		Run: func(script string) (string, error) {
+			var output bytes.Buffer
+			runOpts := dockerOpts
+			runOpts.ShellCommand = []string{"bash", "-c", script}
+			runOpts.NoStdin = true
+			runOpts.Output = &output
+			err := runDockerToolchain(context.Background(), builder, runOpts)
+			return output.String(), err
+		},
+		Rewrite: containerPathRewriter(map[string]string{
+			"/workspace": projectRoot,
+			compileDbDir: buildDir,
+		}),
+	}
+	return runAnalysis(cmd, opts, false)
+}
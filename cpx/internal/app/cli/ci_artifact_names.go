@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// clearNamedOutputs empties the output directories of the toolchains with an
+// artifact_name template, so only the files of this build get renamed
+func clearNamedOutputs(toolchains []config.Toolchain, outputDir string) error {
+	for _, tc := range toolchains {
+		if tc.ArtifactName == "" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(outputDir, tc.Name)); err != nil {
+			return fmt.Errorf("failed to clear output directory of '%s': %w", tc.Name, err)
+		}
+	}
+	return nil
+}
+
+// nameArtifacts renames the files directly in a toolchain's output directory
+// after its artifact_name template. Result directories such as test-results
+// are left alone.
+func nameArtifacts(tc config.Toolchain, dir, version string) error {
+	if tc.ArtifactName == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	names := make(map[string]string) // new name -> file
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := tc.ArtifactFileName(e.Name(), version)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("artifact_name gives '%s' and '%s' the same name '%s'", other, e.Name(), name)
+		}
+		names[name] = e.Name()
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		if file := names[name]; file != name {
+			if err := os.Rename(filepath.Join(dir, file), filepath.Join(dir, name)); err != nil {
+				return fmt.Errorf("failed to rename artifact '%s': %w", file, err)
+			}
+		}
+	}
+	return nil
+}
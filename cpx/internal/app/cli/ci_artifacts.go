@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// artifactCacheDir is the directory under the CI cache holding artifacts by key
+const artifactCacheDir = "artifacts"
+
+// runCachedDockerToolchain skips a Docker build whose inputs are unchanged:
+// artifacts built before from the same sources, configuration and image are
+// restored from the artifact cache instead. Builds that also run tests,
+// benchmarks or the executable always run, as does --force. The cache use and
+// the phases of a build that ran are recorded in result.
+func runCachedDockerToolchain(builder build.DockerBuilder, opts build.DockerBuildOptions, tc config.Toolchain, runner *config.Runner, options ToolchainBuildOptions, result *toolchainResult) error {
+	if options.Force || options.RunTests || options.RunBenchmarks || options.ExecuteAfterBuild {
+		return runTimedDockerToolchain(builder, opts, tc, result)
+	}
+
+	imageID := opts.Endpoint.ImageID(opts.ImageName)
+	if imageID == "" {
+		return runTimedDockerToolchain(builder, opts, tc, result)
+	}
+	key, err := artifactKey(opts, tc, runner, imageID)
+	if err != nil {
+		fmt.Fprintf(opts.Stdout(), "  %sWarning: artifact cache disabled: %v%s\n", colors.Yellow, err, colors.Reset)
+		return runTimedDockerToolchain(builder, opts, tc, result)
+	}
+
+	cached := filepath.Join(opts.CacheRoot(), artifactCacheDir, key)
+	outputDir := filepath.Join(opts.ProjectRoot, opts.OutputDir, opts.TargetName)
+	if _, err := os.Stat(cached); err == nil {
+		if err := os.RemoveAll(outputDir); err != nil {
+			return fmt.Errorf("failed to clear output directory: %w", err)
+		}
+		if err := copyTree(cached, outputDir); err != nil {
+			return fmt.Errorf("failed to restore cached artifacts: %w", err)
+		}
+		result.Cache = cacheHit
+		fmt.Fprintf(opts.Stdout(), "  %s Unchanged, restored artifacts from cache (%s)%s\n", colors.Green, key[:12], colors.Reset)
+		return nil
+	}
+
+	result.Cache = cacheMiss
+	if err := runTimedDockerToolchain(builder, opts, tc, result); err != nil {
+		return err
+	}
+
+	// Stage and rename so an interrupted copy never looks like a cache hit
+	staging := cached + ".tmp"
+	_ = os.RemoveAll(staging)
+	if err := copyTree(outputDir, staging); err == nil {
+		err = os.Rename(staging, cached)
+	}
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		fmt.Fprintf(opts.Stdout(), "  %sWarning: failed to cache artifacts: %v%s\n", colors.Yellow, err, colors.Reset)
+	}
+	return nil
+}
+
+// runTimedDockerToolchain runs a Docker build and records the phases its
+// script timed. On remote engines the build directory is a volume the phases
+// cannot be read from.
+func runTimedDockerToolchain(builder build.DockerBuilder, opts build.DockerBuildOptions, tc config.Toolchain, result *toolchainResult) error {
+	err := runDockerAttempts(builder, opts, tc)
+	if !opts.Endpoint.Remote {
+		result.Phases = build.ReadPhases(filepath.Join(opts.CacheRoot(), opts.TargetName))
+	}
+	return err
+}
+
+// runDockerAttempts runs a Docker build up to 1+retries times. With a
+// timeout, each attempt's container is named so it can be killed once the
+// attempt outlasts it.
+func runDockerAttempts(builder build.DockerBuilder, opts build.DockerBuildOptions, tc config.Toolchain) error {
+	timeout := tc.BuildTimeout()
+	attempts := tc.Retries + 1
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			fmt.Fprintf(opts.Stdout(), "  %s %v; retrying (attempt %d of %d)...%s\n", colors.Yellow, err, attempt, attempts, colors.Reset)
+		}
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			opts.ContainerName = build.ContainerName(opts.TargetName, attempt)
+		}
+		err = runDockerToolchain(ctx, builder, opts)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// artifactKey returns the content address of a toolchain's artifacts: a hash
+// of the source tree (which includes vcpkg.json) without the build's output and
+// cache directories, the resolved toolchain and runner configuration, the
+// values of the variables passed through from the host, and the ID of the
+// build image. Secrets stay out of the key.
+func artifactKey(opts build.DockerBuildOptions, tc config.Toolchain, runner *config.Runner, imageID string) (string, error) {
+	h := sha256.New()
+	if err := hashSourceTree(h, opts.ProjectRoot, opts.OutputDir, opts.CacheRoot()); err != nil {
+		return "", err
+	}
+	cfg, err := json.Marshal(struct {
+		Toolchain config.Toolchain
+		Runner    *config.Runner
+	}{tc, runner})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "\nconfig %s\nimage %s\n", cfg, imageID)
+	for _, name := range tc.EnvPassthrough {
+		value, set := os.LookupEnv(name)
+		fmt.Fprintf(h, "env %s %t %q\n", name, set, value)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// hashSourceTree writes the path, mode and content of every project file to h,
+// skipping the paths remote engines do not receive either (VCS, caches, outputs),
+// the excluded directories (relative to the project root or absolute) and
+// in-tree build directories, such as a build/ configured by CMake or Meson
+func hashSourceTree(h io.Writer, projectRoot string, excludes ...string) error {
+	excluded := make(map[string]bool, len(excludes))
+	for _, dir := range excludes {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(projectRoot, dir)
+		}
+		excluded[filepath.Clean(dir)] = true
+	}
+	return filepath.WalkDir(projectRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(projectRoot, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." && (excludedFromSources(rel) || excluded[filepath.Clean(path)] || isBuildTree(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "\n%s %o ", filepath.ToSlash(rel), info.Mode())
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(h, target)
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(h, f)
+		return err
+	})
+}
+
+// isBuildTree reports whether a directory is a configured CMake or Meson build directory
+func isBuildTree(dir string) bool {
+	for _, marker := range []string{"CMakeCache.txt", "meson-private"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedFromSources reports whether a project directory is left out of the
+// source hash, matching remoteUploadExcludes
+func excludedFromSources(rel string) bool {
+	for _, pattern := range remoteUploadExcludes {
+		if ok, _ := filepath.Match(strings.TrimPrefix(pattern, "./"), filepath.ToSlash(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// copyTree copies the files under src to dst, keeping their modes
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
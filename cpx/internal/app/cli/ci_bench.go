@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// benchComparisonFile is the comparison table cpx ci bench writes to the output directory
+const benchComparisonFile = "bench-comparison.md"
+
+// benchmarkOutput is the part of a Google Benchmark JSON result
+// (--benchmark_out_format=json) that is compared
+type benchmarkOutput struct {
+	Benchmarks []struct {
+		Name          string  `json:"name"`
+		RunName       string  `json:"run_name"`
+		RunType       string  `json:"run_type"`
+		AggregateName string  `json:"aggregate_name"`
+		RealTime      float64 `json:"real_time"`
+		TimeUnit      string  `json:"time_unit"`
+	} `json:"benchmarks"`
+}
+
+// benchTimeUnits converts Google Benchmark time units to nanoseconds
+var benchTimeUnits = map[string]float64{"ns": 1, "us": 1e3, "ms": 1e6, "s": 1e9}
+
+func runCIBench(cmd *cobra.Command, _ []string) error {
+	toolchainName, _ := cmd.Flags().GetString("toolchain")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	excludeTags, _ := cmd.Flags().GetStringSlice("exclude-tags")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	if jobs < 0 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+
+	results, err := buildToolchains(ToolchainBuildOptions{
+		ToolchainName: toolchainName,
+		Tags:          tags,
+		ExcludeTags:   excludeTags,
+		Verbose:       verbose,
+		Parallel:      jobs,
+		RunBenchmarks: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	outputDir := ciConfig.GetOutputDir()
+
+	var toolchains []string
+	times := make(map[string]map[string]float64)
+	for _, r := range results {
+		dir := filepath.Join(outputDir, r.Name, build.BenchResultsDir)
+		tcTimes, err := readBenchResults(dir)
+		if err != nil {
+			return err
+		}
+		if len(tcTimes) == 0 {
+			continue
+		}
+		toolchains = append(toolchains, r.Name)
+		times[r.Name] = tcTimes
+	}
+	if len(toolchains) == 0 {
+		fmt.Printf("\n%sNo Google Benchmark results to compare%s\n", colors.Yellow, colors.Reset)
+		return nil
+	}
+
+	table := benchComparison(toolchains, times)
+	fmt.Printf("\n%sBenchmarks%s\n%s", colors.Bold, colors.Reset, table)
+	path := filepath.Join(outputDir, benchComparisonFile)
+	if err := os.WriteFile(path, []byte(table), 0644); err != nil {
+		return fmt.Errorf("failed to write benchmark comparison: %w", err)
+	}
+	fmt.Printf("   Results are in: %s\n", filepath.Join(outputDir, "<toolchain>", build.BenchResultsDir))
+	fmt.Printf("   Comparison written to %s\n", path)
+	return nil
+}
+
+// readBenchResults returns the real time per benchmark, in nanoseconds, of the
+// Google Benchmark JSON files in dir. The mean of repeated benchmarks is used.
+func readBenchResults(dir string) (map[string]float64, error) {
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	times := make(map[string]float64)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read benchmark results: %w", err)
+		}
+		var out benchmarkOutput
+		if err := json.Unmarshal(data, &out); err != nil {
+			return nil, fmt.Errorf("failed to parse benchmark results %s: %w", path, err)
+		}
+		means := make(map[string]float64)
+		for _, b := range out.Benchmarks {
+			ns := b.RealTime * benchTimeUnits[b.TimeUnit]
+			switch {
+			case b.RunType == "aggregate" && b.AggregateName == "mean":
+				means[b.RunName] = ns
+			case b.RunType != "aggregate":
+				if _, ok := times[b.Name]; !ok {
+					times[b.Name] = ns
+				}
+			}
+		}
+		for name, ns := range means {
+			times[name] = ns
+		}
+	}
+	return times, nil
+}
+
+// benchComparison renders the benchmark times of toolchains as a Markdown
+// table with a row per benchmark and a column per toolchain. Times are
+// relative to the first toolchain that ran the benchmark.
+func benchComparison(toolchains []string, times map[string]map[string]float64) string {
+	var names []string
+	for _, tc := range toolchains {
+		for name := range times[tc] {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	rows := [][]string{append([]string{"Benchmark"}, toolchains...)}
+	for _, name := range names {
+		row := []string{name}
+		base := 0.0
+		for _, tc := range toolchains {
+			ns, ok := times[tc][name]
+			switch {
+			case !ok:
+				row = append(row, "-")
+			case base == 0:
+				base = ns
+				row = append(row, formatBenchTime(ns))
+			default:
+				row = append(row, fmt.Sprintf("%s (%.2fx)", formatBenchTime(ns), ns/base))
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len([]rune(cell)))
+		}
+	}
+	var s strings.Builder
+	writeRow := func(row []string) {
+		s.WriteString("|")
+		for i, cell := range row {
+			fmt.Fprintf(&s, " %s%s |", cell, strings.Repeat(" ", widths[i]-len([]rune(cell))))
+		}
+		s.WriteString("\n")
+	}
+	writeRow(rows[0])
+	s.WriteString("|")
+	for _, w := range widths {
+		s.WriteString(strings.Repeat("-", w+2) + "|")
+	}
+	s.WriteString("\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return s.String()
+}
+
+// formatBenchTime renders nanoseconds with the largest unit below the value, e.g. "12.30 µs"
+func formatBenchTime(ns float64) string {
+	switch {
+	case ns >= 1e9:
+		return fmt.Sprintf("%.2f s", ns/1e9)
+	case ns >= 1e6:
+		return fmt.Sprintf("%.2f ms", ns/1e6)
+	case ns >= 1e3:
+		return fmt.Sprintf("%.2f µs", ns/1e3)
+	default:
+		return fmt.Sprintf("%.2f ns", ns)
+	}
+}
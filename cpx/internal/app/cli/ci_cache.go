@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"slices"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// applyCompilerCache enables cpx-ci.yaml's cache backend for a Docker build
+func applyCompilerCache(opts *build.DockerBuildOptions, cache *config.CacheConfig) {
+	if cache == nil {
+		return
+	}
+	switch cache.Backend {
+	case "ccache":
+		opts.CCache = true
+	case "sccache":
+		opts.SCCache = sccacheOptions(cache)
+	}
+}
+
+// sccacheOptions maps the sccache storage settings to sccache's environment.
+// Credentials are never part of cpx-ci.yaml; they are forwarded from the host.
+func sccacheOptions(cache *config.CacheConfig) *build.SCCache {
+	s := &build.SCCache{Env: map[string]string{}}
+	set := func(key, value string) {
+		if value != "" {
+			s.Env[key] = value
+		}
+	}
+
+	switch {
+	case cache.S3 != nil:
+		set("SCCACHE_BUCKET", cache.S3.Bucket)
+		set("SCCACHE_REGION", cache.S3.Region)
+		set("SCCACHE_ENDPOINT", cache.S3.Endpoint)
+		set("SCCACHE_S3_KEY_PREFIX", cache.S3.KeyPrefix)
+		s.PassEnv = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"}
+	case cache.GCS != nil:
+		set("SCCACHE_GCS_BUCKET", cache.GCS.Bucket)
+		set("SCCACHE_GCS_KEY_PREFIX", cache.GCS.KeyPrefix)
+		// sccache defaults to read-only for GCS
+		if cache.GCS.ReadOnly {
+			set("SCCACHE_GCS_RW_MODE", "READ_ONLY")
+		} else {
+			set("SCCACHE_GCS_RW_MODE", "READ_WRITE")
+		}
+		s.PassEnv = []string{"CPX_GCS_KEY"}
+	case cache.Redis != nil:
+		set("SCCACHE_REDIS_ENDPOINT", cache.Redis.Endpoint)
+		set("SCCACHE_REDIS_KEY_PREFIX", cache.Redis.KeyPrefix)
+		s.PassEnv = []string{"SCCACHE_REDIS_USERNAME", "SCCACHE_REDIS_PASSWORD"}
+	}
+	return s
+}
+
+// applyBinarySources adds cpx-ci.yaml's remote vcpkg binary caches to a
+// Docker build, forwarding each source's credentials from the host
+func applyBinarySources(opts *build.DockerBuildOptions, vcpkg *config.VcpkgConfig) {
+	if vcpkg == nil {
+		return
+	}
+	for _, src := range vcpkg.BinarySources {
+		opts.BinarySources = append(opts.BinarySources, build.BinarySource{
+			Type:   src.Type,
+			URL:    src.URL,
+			Mode:   src.Mode,
+			Header: src.Header,
+		})
+		switch src.Type {
+		case "gha":
+			opts.PassEnv = append(opts.PassEnv, "ACTIONS_CACHE_URL", "ACTIONS_RUNTIME_TOKEN")
+		case "nuget":
+			opts.PassEnv = append(opts.PassEnv, "NUGET_USERNAME", "NUGET_PASSWORD")
+		}
+		opts.PassEnv = append(opts.PassEnv, src.Env...)
+	}
+	slices.Sort(opts.PassEnv)
+	opts.PassEnv = slices.Compact(opts.PassEnv)
+}
+
+// describeCompilerCache returns the cache a toolchain's Docker builds use
+func describeCompilerCache(tc config.Toolchain, cache *config.CacheConfig) string {
+	switch {
+	case cache != nil && cache.Backend == "sccache":
+		switch {
+		case cache.S3 != nil:
+			return "sccache (s3://" + cache.S3.Bucket + ")"
+		case cache.GCS != nil:
+			return "sccache (gs://" + cache.GCS.Bucket + ")"
+		case cache.Redis != nil:
+			return "sccache (" + cache.Redis.Endpoint + ")"
+		}
+		return "sccache (local disk)"
+	case tc.CCache || cache != nil && cache.Backend == "ccache":
+		return "ccache"
+	}
+	return ""
+}
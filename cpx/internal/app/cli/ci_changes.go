@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/internal/pkg/utils/git"
+	"github.com/ozacod/cpx/internal/pkg/utils/glob"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+const defaultChangesBase = "origin/HEAD"
+
+// defaultChangesIgnore are changes that never need a rebuild unless
+// cpx-ci.yaml's changes.ignore says otherwise
+var defaultChangesIgnore = []string{"docs/**", "**/*.md"}
+
+// changedToolchains returns the toolchains affected by the changes since the
+// base ref (--base, else cpx-ci.yaml's changes.base, else origin/HEAD)
+func changedToolchains(ciConfig *config.ToolchainConfig, toolchains []config.Toolchain, projectRoot, base string) ([]config.Toolchain, error) {
+	ignore := defaultChangesIgnore
+	if ciConfig.Changes != nil {
+		if base == "" {
+			base = ciConfig.Changes.Base
+		}
+		if ciConfig.Changes.Ignore != nil {
+			ignore = ciConfig.Changes.Ignore
+		}
+	}
+	if base == "" {
+		base = defaultChangesBase
+	}
+
+	changed, err := git.ChangedFiles(projectRoot, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect changes: %w", err)
+	}
+	fmt.Printf("%s %d file(s) changed since %s%s\n", colors.Cyan, len(changed), base, colors.Reset)
+
+	affected, skipped := filterChangedToolchains(toolchains, changed, ignore)
+	if len(skipped) > 0 {
+		fmt.Printf("%sSkipping %d unchanged toolchain(s): %s%s\n", colors.Yellow, len(skipped), strings.Join(skipped, ", "), colors.Reset)
+	}
+	return affected, nil
+}
+
+// filterChangedToolchains splits toolchains into those affected by the changed
+// files and the names of the others. Toolchains with paths: build when a
+// changed file matches one of them; the rest build on any change that is not
+// ignored. A changed cpx-ci.yaml affects every toolchain.
+func filterChangedToolchains(toolchains []config.Toolchain, changed, ignore []string) ([]config.Toolchain, []string) {
+	if slices.Contains(changed, "cpx-ci.yaml") {
+		return toolchains, nil
+	}
+
+	relevant := false
+	for _, file := range changed {
+		if !glob.MatchAny(ignore, file) {
+			relevant = true
+			break
+		}
+	}
+
+	hits := make(map[string]bool)
+	for _, tc := range toolchains {
+		hits[tc.Name] = relevant
+		if len(tc.Paths) > 0 {
+			hits[tc.Name] = slices.ContainsFunc(changed, func(file string) bool { return glob.MatchAny(tc.Paths, file) })
+		}
+	}
+	for spread := true; spread; {
+		spread = false
+		for _, tc := range toolchains {
+			if !hits[tc.Name] && slices.ContainsFunc(tc.DependsOn, func(dep string) bool { return hits[dep] }) {
+				hits[tc.Name], spread = true, true
+			}
+		}
+	}
+
+	var affected []config.Toolchain
+	var skipped []string
+	for _, tc := range toolchains {
+		if hits[tc.Name] {
+			affected = append(affected, tc)
+		} else {
+			skipped = append(skipped, tc.Name)
+		}
+	}
+	return affected, skipped
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// checksumsFile is the name of the sha256sum-compatible manifests of CI artifacts
+const checksumsFile = "SHA256SUMS"
+
+// writeChecksums writes <outputDir>/SHA256SUMS covering every file under the
+// output directory and, with checksums.per_toolchain, a SHA256SUMS in the
+// output directory of each built toolchain
+func writeChecksums(ciConfig *config.ToolchainConfig, outputDir string, toolchains []config.Toolchain) error {
+	if ciConfig.Checksums != nil && ciConfig.Checksums.PerToolchain {
+		for _, tc := range toolchains {
+			dir := filepath.Join(outputDir, tc.Name)
+			if _, err := os.Stat(dir); err != nil {
+				continue
+			}
+			if _, err := writeManifest(dir); err != nil {
+				return err
+			}
+		}
+	}
+	count, err := writeManifest(outputDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("   Checksums of %d file(s) are in: %s\n", count, filepath.Join(outputDir, checksumsFile))
+	return nil
+}
+
+// writeManifest writes dir/SHA256SUMS for every file under dir, except that
+// manifest and its signature, and returns the number of files it covers
+func writeManifest(dir string) (int, error) {
+	files, err := manifestFiles(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list artifacts: %w", err)
+	}
+	var manifest strings.Builder
+	for _, rel := range files {
+		sum, err := fileSHA256(filepath.Join(dir, rel))
+		if err != nil {
+			return 0, fmt.Errorf("failed to hash %s: %w", rel, err)
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, rel)
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksumsFile), []byte(manifest.String()), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", checksumsFile, err)
+	}
+	return len(files), nil
+}
+
+// manifestFiles returns the slash-separated paths of the files under dir,
+// sorted, leaving out dir's own SHA256SUMS and its signature files
+func manifestFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel != checksumsFile && !strings.HasPrefix(rel, checksumsFile+".") {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	slices.Sort(files)
+	return files, err
+}
+
+// fileSHA256 returns the hex SHA-256 of a file's content
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// artifactDigest is a built file and its SHA-256
+type artifactDigest struct {
+	Name   string
+	SHA256 string
+}
+
+// artifactDigests returns the built files directly in a toolchain's output
+// directory with their SHA-256, leaving out the checksums, signatures, SBOMs
+// and provenance written next to them
+func artifactDigests(dir string) []artifactDigest {
+	entries, _ := os.ReadDir(dir)
+	var digests []artifactDigest
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || name == checksumsFile || name == provenanceFile || hasSignatureExt(name) || slices.Contains(slices.Collect(maps.Values(sbomFiles)), name) {
+			continue
+		}
+		if sum, err := fileSHA256(filepath.Join(dir, name)); err == nil {
+			digests = append(digests, artifactDigest{Name: name, SHA256: sum})
+		}
+	}
+	return digests
+}
+
+// manifestCheck is the result of checking a SHA256SUMS against its directory
+type manifestCheck struct {
+	OK        int
+	Failed    []string // files whose content changed
+	Missing   []string // files listed but gone
+	Unlisted  []string // files present but not listed
+	Malformed int      // lines that are not "<sha256>  <path>"
+}
+
+// verifyManifest checks the files a SHA256SUMS lists, relative to its directory
+func verifyManifest(path string) (manifestCheck, error) {
+	var check manifestCheck
+	f, err := os.Open(path)
+	if err != nil {
+		return check, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	listed := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sum, rel, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok || len(sum) != 64 {
+			if strings.TrimSpace(scanner.Text()) != "" {
+				check.Malformed++
+			}
+			continue
+		}
+		rel = strings.TrimPrefix(rel, "*") // binary mode marker of sha256sum
+		listed[rel] = true
+		actual, err := fileSHA256(filepath.Join(dir, filepath.FromSlash(rel)))
+		switch {
+		case os.IsNotExist(err):
+			check.Missing = append(check.Missing, rel)
+		case err != nil:
+			return check, fmt.Errorf("failed to hash %s: %w", rel, err)
+		case !strings.EqualFold(actual, sum):
+			check.Failed = append(check.Failed, rel)
+		default:
+			check.OK++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return check, err
+	}
+
+	files, err := manifestFiles(dir)
+	if err != nil {
+		return check, err
+	}
+	for _, rel := range files {
+		if !listed[rel] {
+			check.Unlisted = append(check.Unlisted, rel)
+		}
+	}
+	return check, nil
+}
+
+func runCIVerify(_ *cobra.Command, args []string) error {
+	var path string
+	if len(args) > 0 {
+		path = args[0]
+	} else {
+		ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+		if err != nil {
+			return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+		}
+		path = filepath.Join(ciConfig.GetOutputDir(), checksumsFile)
+	}
+
+	check, err := verifyManifest(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s not found; run 'cpx ci build' first", path)
+		}
+		return fmt.Errorf("failed to verify %s: %w", path, err)
+	}
+
+	for _, rel := range check.Failed {
+		fmt.Printf("  %s✗ %s: checksum mismatch%s\n", colors.Red, rel, colors.Reset)
+	}
+	for _, rel := range check.Missing {
+		fmt.Printf("  %s✗ %s: missing%s\n", colors.Red, rel, colors.Reset)
+	}
+	for _, rel := range check.Unlisted {
+		fmt.Printf("  %s- %s: not in %s%s\n", colors.Yellow, rel, filepath.Base(path), colors.Reset)
+	}
+	if check.Malformed > 0 {
+		fmt.Printf("  %sWarning: %d malformed line(s) in %s%s\n", colors.Yellow, check.Malformed, path, colors.Reset)
+	}
+
+	if bad := len(check.Failed) + len(check.Missing); bad > 0 {
+		return fmt.Errorf("%d of %d file(s) in %s failed verification", bad, bad+check.OK, path)
+	}
+	fmt.Printf("%s✓ %d file(s) match %s%s\n", colors.Green, check.OK, path, colors.Reset)
+	return nil
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// vcpkgCacheDirName is the directory in a toolchain's build directory holding its vcpkg caches
+const vcpkgCacheDirName = ".vcpkg_cache"
+
+func runCIClean(cmd *cobra.Command, _ []string) error {
+	toolchainName, _ := cmd.Flags().GetString("toolchain")
+	all, _ := cmd.Flags().GetBool("all")
+	vcpkgCaches, _ := cmd.Flags().GetBool("vcpkg")
+	images, _ := cmd.Flags().GetBool("images")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+
+	var toolchains []config.Toolchain
+	if toolchainName != "" {
+		tc, err := ciConfig.ResolveToolchain(toolchainName)
+		if err != nil {
+			return fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+		}
+		if tc == nil {
+			return fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", toolchainName)
+		}
+		toolchains = []config.Toolchain{*tc}
+	} else if toolchains, err = ciConfig.ResolveToolchains(); err != nil {
+		return fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+	}
+
+	paths := cleanPaths(toolchains, configuredCICacheDir(projectRoot), ciConfig.GetOutputDir(), toolchainName == "", all, all || vcpkgCaches)
+
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+	var freed int64
+	removedImages := 0
+	for _, path := range paths {
+		size := treeSize(path)
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				if errors.Is(err, fs.ErrPermission) {
+					return fmt.Errorf("failed to remove %s: %w (written by a build container running as root; remove it with sudo once)", path, err)
+				}
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+		freed += size
+		fmt.Printf("  %s %s (%s)\n", verb, path, formatSize(size))
+	}
+
+	if all || images {
+		if removedImages, err = pruneStaleImages(ciConfig, projectRoot, verb, dryRun); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case len(paths) == 0 && removedImages == 0:
+		fmt.Printf("%s Nothing to clean%s\n", colors.Green, colors.Reset)
+	case !dryRun:
+		fmt.Printf("%s Freed %s of build files and %d image(s)%s\n", colors.Green, formatSize(freed), removedImages, colors.Reset)
+	}
+	return nil
+}
+
+// cleanPaths returns the existing paths `cpx ci clean` removes: the build
+// directories and artifacts of toolchains, without their vcpkg caches unless
+// vcpkg is set, and the artifact cache when every toolchain is cleaned. With
+// all set as well, the whole CI cache and output directories are removed,
+// including those of toolchains that no longer exist.
+func cleanPaths(toolchains []config.Toolchain, cacheDir, outputDir string, everyToolchain, all, vcpkg bool) []string {
+	var paths []string
+	add := func(path string) {
+		if _, err := os.Lstat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	if everyToolchain && all {
+		add(cacheDir)
+		add(outputDir)
+		return paths
+	}
+
+	for _, tc := range toolchains {
+		buildDir := filepath.Join(cacheDir, tc.Name)
+		entries, _ := os.ReadDir(buildDir)
+		for _, e := range entries {
+			if e.Name() != vcpkgCacheDirName || vcpkg {
+				add(filepath.Join(buildDir, e.Name()))
+			}
+		}
+		add(filepath.Join(outputDir, tc.Name))
+	}
+	if everyToolchain {
+		add(filepath.Join(cacheDir, artifactCacheDir))
+	}
+	return paths
+}
+
+// treeSize returns the total size of the files under path
+func treeSize(path string) int64 {
+	var total int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,984 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// CICmd creates the ci command
+func CICmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ci",
+		Short: "Inspect and run cpx-ci.yaml toolchains",
+		Long:  "Inspect, prepare and build the toolchains defined in cpx-ci.yaml.",
+	}
+
+	buildCmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build cpx-ci.yaml toolchains",
+		Long: `Build every active toolchain in cpx-ci.yaml, or a single one with --toolchain.
+--tags builds only the toolchains with one of the given tags and --exclude-tags
+skips those with one of them; toolchains they depend on are built too.
+With --jobs N (or 'parallel: N' in cpx-ci.yaml), up to N Docker toolchains build
+concurrently; their output is prefixed with the toolchain name and a status
+summary is printed at the end.
+
+Docker toolchains whose sources (vcpkg.json included), configuration and image
+are unchanged since an earlier build restore their artifacts from
+.cache/ci/artifacts instead of building; --force builds them anyway.
+
+With --changed-only, only toolchains affected by the git changes since --base
+(committed, uncommitted and untracked) are built: toolchains with 'paths:'
+globs when a matching file changed, the others on any change outside
+'changes.ignore' (default: docs/** and **/*.md). --since <ref> is short for
+--changed-only --base <ref>.
+
+A failed toolchain stops the build unless --keep-going is given (or
+'keep_going: true' is set in cpx-ci.yaml): then the other toolchains are
+built, those depending on a failed one are skipped, and every failure is
+listed in the summary. Parallel builds always keep going.
+
+Each toolchain that builds successfully is recorded in .cache/ci/run-state.json,
+keyed by the sources (cpx-ci.yaml included). After an interrupted or failed
+build, --resume skips the toolchains already built from the same sources.
+
+--profile applies an entry of cpx-ci.yaml's 'profiles:' to every toolchain:
+its build type and optimization replace theirs, its cmake and build options
+are appended and its env is merged over theirs, e.g. an 'asan' profile for a
+sanitizer run of all targets without a second list of toolchains.
+
+With --dry-run, nothing is built: the docker build and run commands, with their
+mounts, forwarded variables and generated build scripts, are printed instead
+(the native and SSH commands for other runners), ready to paste into a shell
+or a bug report.
+
+With --json, the build output goes to stderr and a JSON report goes to stdout:
+the status, duration, image, artifact paths and error of every toolchain.
+
+With --upload, the artifacts of a successful build are pushed to the 'upload:'
+destination of each toolchain (or the global one): an S3 or GCS bucket
+through the aws or gcloud CLI, or an HTTP server taking PUT requests.`,
+		Example: `  cpx ci build
+  cpx ci build --jobs 4
+  cpx ci build --toolchain linux-release
+  cpx ci build --tags release --exclude-tags nightly
+  cpx ci build --force
+  cpx ci build --changed-only --base origin/main
+  cpx ci build --since v1.2.0
+  cpx ci build --keep-going
+  cpx ci build --resume
+  cpx ci build --profile asan
+  cpx ci build --dry-run --toolchain linux-release
+  cpx ci build --json > report.json
+  cpx ci build --upload`,
+		RunE: runCIBuild,
+		Args: cobra.NoArgs,
+	}
+	buildCmd.Flags().String("toolchain", "", "Build only a specific toolchain (default: all active)")
+	buildCmd.Flags().StringSlice("tags", nil, "Build only toolchains with one of these tags")
+	buildCmd.Flags().StringSlice("exclude-tags", nil, "Skip toolchains with one of these tags")
+	buildCmd.Flags().IntP("jobs", "j", 0, "Docker toolchains to build concurrently (default: cpx-ci.yaml 'parallel', else 1)")
+	buildCmd.Flags().Bool("verbose", false, "Show full build output")
+	buildCmd.Flags().Bool("force", false, "Build even if the artifacts of unchanged toolchains are cached")
+	buildCmd.Flags().Bool("changed-only", false, "Build only toolchains affected by git changes since --base")
+	buildCmd.Flags().String("base", "", "Git ref --changed-only compares against (default: cpx-ci.yaml changes.base, else origin/HEAD)")
+	buildCmd.Flags().String("since", "", "Build only toolchains affected by git changes since this ref (--changed-only --base <ref>)")
+	buildCmd.Flags().Bool("keep-going", false, "Build the remaining toolchains after one fails (default: cpx-ci.yaml keep_going)")
+	buildCmd.Flags().Bool("resume", false, "Skip the toolchains an interrupted build of the same sources completed")
+	buildCmd.Flags().String("profile", "", "Apply a cpx-ci.yaml profile (build type, cmake options, env) to every toolchain")
+	buildCmd.Flags().Bool("dry-run", false, "Print the commands and build scripts without running them")
+	buildCmd.Flags().Bool("json", false, "Write a JSON report of the results to stdout (build output goes to stderr)")
+	buildCmd.Flags().Bool("upload", false, "Upload the artifacts to cpx-ci.yaml's upload destinations after a successful build")
+	cmd.AddCommand(buildCmd)
+
+	testCmd := &cobra.Command{
+		Use:   "test",
+		Short: "Build and run the tests of cpx-ci.yaml toolchains",
+		Long: `Build every active toolchain in cpx-ci.yaml, or a single one with --toolchain,
+and run the test suite where it was built (ctest, meson test or bazel test inside
+the Docker container). Docker toolchains collect the JUnit XML results into
+<output>/<toolchain>/test-results, also when tests fail.
+
+With --coverage, CMake Docker toolchains build the tests with coverage
+instrumentation (clang's when the runner's compiler is clang, else gcc's) and
+collect it in the container with gcov or llvm-cov into
+<output>/<toolchain>/coverage. The coverage of every toolchain is mapped to
+the project's paths and merged into one report, <output>/coverage/coverage.lcov
+with an HTML report when genhtml is installed, so a multi-architecture run
+gives one coverage number. The thresholds in .cpx-quality.yaml's coverage:
+section apply to it.`,
+		Example: `  cpx ci test
+  cpx ci test --toolchain linux-arm64
+  cpx ci test --jobs 4
+  cpx ci test --profile asan
+  cpx ci test --coverage`,
+		RunE: runCITest,
+		Args: cobra.NoArgs,
+	}
+	testCmd.Flags().String("toolchain", "", "Test only a specific toolchain (default: all active)")
+	testCmd.Flags().StringSlice("tags", nil, "Test only toolchains with one of these tags")
+	testCmd.Flags().StringSlice("exclude-tags", nil, "Skip toolchains with one of these tags")
+	testCmd.Flags().IntP("jobs", "j", 0, "Docker toolchains to test concurrently (default: cpx-ci.yaml 'parallel', else 1)")
+	testCmd.Flags().Bool("verbose", false, "Show full build output")
+	testCmd.Flags().String("profile", "", "Apply a cpx-ci.yaml profile (build type, cmake options, env) to every toolchain")
+	testCmd.Flags().Bool("coverage", false, "Measure the tests' coverage and merge it across toolchains")
+	cmd.AddCommand(testCmd)
+
+	benchCmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Build and run the benchmarks of cpx-ci.yaml toolchains",
+		Long: `Build every active toolchain in cpx-ci.yaml, or a single one with --toolchain,
+and run its benchmarks in the Docker container. Google Benchmark executables write
+JSON results to <output>/<toolchain>/bench-results; a table comparing every
+benchmark across toolchains is printed and written to <output>/bench-comparison.md.`,
+		Example: `  cpx ci bench
+  cpx ci bench --toolchain linux-arm64
+  cpx ci bench --jobs 2`,
+		RunE: runCIBench,
+		Args: cobra.NoArgs,
+	}
+	benchCmd.Flags().String("toolchain", "", "Benchmark only a specific toolchain (default: all active)")
+	benchCmd.Flags().StringSlice("tags", nil, "Benchmark only toolchains with one of these tags")
+	benchCmd.Flags().StringSlice("exclude-tags", nil, "Skip toolchains with one of these tags")
+	benchCmd.Flags().IntP("jobs", "j", 0, "Docker toolchains to benchmark concurrently (default: cpx-ci.yaml 'parallel', else 1)")
+	benchCmd.Flags().Bool("verbose", false, "Show full build output")
+	cmd.AddCommand(benchCmd)
+
+	cleanCmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove CI build directories, caches, artifacts and stale images",
+		Long: `Remove what cpx ci build leaves behind: the toolchains' build directories in
+.cache/ci (compiler caches included), their artifacts in the output directory and
+the artifact cache. vcpkg caches are kept unless --vcpkg is given, since
+rebuilding packages is slow.
+
+--images removes the runner images built from an earlier Dockerfile: the
+content-hash tags (<repository>:<hash>) that no runner's current Dockerfile,
+build args and platform produce anymore.
+
+--all removes everything: the whole CI cache and output directories (including
+directories of removed toolchains), vcpkg caches and stale images.`,
+		Example: `  cpx ci clean
+  cpx ci clean --toolchain linux-arm64 --vcpkg
+  cpx ci clean --images
+  cpx ci clean --all --dry-run`,
+		RunE: runCIClean,
+		Args: cobra.NoArgs,
+	}
+	cleanCmd.Flags().String("toolchain", "", "Clean only a specific toolchain (default: all)")
+	cleanCmd.Flags().Bool("all", false, "Remove all CI caches, artifacts and stale images")
+	cleanCmd.Flags().Bool("vcpkg", false, "Also remove vcpkg caches")
+	cleanCmd.Flags().Bool("images", false, "Also remove stale content-hash tagged runner images")
+	cleanCmd.Flags().Bool("dry-run", false, "Print what would be removed without removing it")
+	cmd.AddCommand(cleanCmd)
+
+	imagesCmd := &cobra.Command{
+		Use:   "images",
+		Short: "List the runner images cpx built",
+		Long: `List the content-hash tagged images (<repository>:<hash>) of the runners that
+build their image from a Dockerfile, with their size, creation date and whether
+the hash still matches the runner's current Dockerfile, build args and platform.
+Each change to a Dockerfile builds a new tag; 'prune' removes the stale ones.
+Only the images this project built (labeled dev.cpx.project) are listed, so
+projects sharing a repository never prune each other's images.`,
+		Example: `  cpx ci images
+  cpx ci images prune --dry-run`,
+		RunE: runCIImages,
+		Args: cobra.NoArgs,
+	}
+	imagesPruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove stale runner images",
+		Long: `Remove the content-hash tagged runner images that no runner's current
+Dockerfile, build args and platform produce anymore.`,
+		Example: `  cpx ci images prune`,
+		RunE:    runCIImagesPrune,
+		Args:    cobra.NoArgs,
+	}
+	imagesPruneCmd.Flags().Bool("dry-run", false, "Print the stale images without removing them")
+	imagesCmd.AddCommand(imagesPruneCmd)
+	cmd.AddCommand(imagesCmd)
+
+	imageCmd := &cobra.Command{
+		Use:   "image",
+		Short: "Build a runtime image around a toolchain's executable",
+		Long: `Wrap the executable a toolchain built into a minimal runtime image tagged
+<repository>:<version>, with its shared libraries in /usr/local/lib. The base
+(distroless with the C/C++ runtime by default, or e.g. alpine for musl
+builds), repository and entrypoint come from the flags or the runtime_image
+section of cpx-ci.yaml. The image is built for the toolchain's platform on its
+runner's Docker engine, and pushed with --push.`,
+		Example: `  cpx ci image --toolchain linux-amd64
+  cpx ci image --toolchain linux-arm64 --base alpine:3.20 --push
+  cpx ci image --toolchain linux-amd64 --repository ghcr.io/acme/server --executable server`,
+		RunE: runCIImage,
+		Args: cobra.NoArgs,
+	}
+	imageCmd.Flags().StringP("toolchain", "t", "", "Toolchain whose executable to package (required)")
+	imageCmd.Flags().String("base", "", "Base image (default: cpx-ci.yaml runtime_image.base, else gcr.io/distroless/cc-debian12)")
+	imageCmd.Flags().String("repository", "", "Image repository (default: cpx-ci.yaml runtime_image.repository, else the project name)")
+	imageCmd.Flags().String("executable", "", "Entrypoint when the toolchain builds several executables")
+	imageCmd.Flags().Bool("push", false, "Push the image after building it")
+	cmd.AddCommand(imageCmd)
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Generate a CI pipeline from cpx-ci.yaml",
+		Long: `Generate the pipeline of a hosted CI service from cpx-ci.yaml, with a job per
+active toolchain running 'cpx ci build --toolchain <name>', a cache of its
+build directory and its artifacts kept, so cpx-ci.yaml stays the single source
+of truth. Re-run it after changing cpx-ci.yaml.`,
+	}
+	for _, platform := range []struct {
+		name, short, long, output string
+	}{
+		{"github", "Generate a GitHub Actions workflow", `Write a GitHub Actions workflow building each active toolchain in a matrix
+job: Docker toolchains on ubuntu-latest (with QEMU for other architectures),
+native ones on the runner their name points to (windows-*, macos-*), caching
+.cache/ci/<toolchain> with actions/cache and uploading the artifacts with
+actions/upload-artifact.`, ".github/workflows/cpx.yml"},
+		{"gitlab", "Generate a GitLab CI pipeline", `Write a GitLab CI pipeline with a job per active toolchain: Docker toolchains
+in a docker image with a Docker-in-Docker service, native ones on GitLab's
+Linux, Windows or macOS runners, with a cache of .cache/ci/<toolchain> keyed
+on cpx-ci.yaml and vcpkg.json and the output directory kept as artifacts.`, ".gitlab-ci.yml"},
+		{"azure", "Generate an Azure Pipelines pipeline", `Write an Azure Pipelines pipeline building each active toolchain in a matrix
+job on a Microsoft-hosted agent (ubuntu, windows or macos), caching
+.cache/ci/<toolchain> with the Cache task and publishing the output directory
+as a pipeline artifact.`, "azure-pipelines.yml"},
+	} {
+		exportPlatformCmd := &cobra.Command{
+			Use:     platform.name,
+			Short:   platform.short,
+			Long:    platform.long,
+			Example: fmt.Sprintf("  cpx ci export %s\n  cpx ci export %s --stdout", platform.name, platform.name),
+			RunE:    runCIExport,
+			Args:    cobra.NoArgs,
+		}
+		exportPlatformCmd.Flags().StringP("output", "o", platform.output, "Pipeline file, relative to the project root")
+		exportPlatformCmd.Flags().Bool("stdout", false, "Print the pipeline instead of writing it")
+		exportCmd.AddCommand(exportPlatformCmd)
+	}
+	cmd.AddCommand(exportCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "verify [SHA256SUMS]",
+		Short: "Verify CI artifacts against their SHA256SUMS",
+		Long: `Check the artifacts in the CI output directory against the SHA256SUMS that
+cpx ci build writes after a successful build, or against the given manifest
+(e.g. a toolchain's own with checksums.per_toolchain). Changed and missing files
+fail the verification; files the manifest does not list are reported.`,
+		Example: `  cpx ci verify
+  cpx ci verify .bin/ci/linux-release/SHA256SUMS`,
+		RunE: runCIVerify,
+		Args: cobra.MaximumNArgs(1),
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate [file]",
+		Short: "Check cpx-ci.yaml for errors",
+		Long: `Check cpx-ci.yaml (or the given file) without building anything: unknown
+keys, missing required fields, values of the wrong type or outside their
+allowed set, duplicate names, unknown runners, extends and depends_on
+targets, dependency cycles, and Docker and SSH runners missing their image or
+host. Every problem is reported with its line and column.`,
+		Example: `  cpx ci validate
+  cpx ci validate ci/cpx-ci.yaml`,
+		RunE: runCIValidate,
+		Args: cobra.MaximumNArgs(1),
+	})
+
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of cpx-ci.yaml",
+		Long: `Print a JSON Schema of cpx-ci.yaml, or write it to a file with --output, for
+editors with a YAML language server (VS Code's YAML extension, yaml-language-server
+in Neovim, ...). It describes the same keys, types, required fields and allowed
+values cpx checks when loading the file. Reference it from cpx-ci.yaml with
+
+  # yaml-language-server: $schema=.cpx/cpx-ci.schema.json`,
+		Example: `  cpx ci schema
+  cpx ci schema --output .cpx/cpx-ci.schema.json`,
+		RunE: runCISchema,
+		Args: cobra.NoArgs,
+	}
+	schemaCmd.Flags().StringP("output", "o", "", "Write the schema to this file instead of stdout")
+	cmd.AddCommand(schemaCmd)
+
+	explainCmd := &cobra.Command{
+		Use:   "explain <toolchain>",
+		Short: "Print the effective configuration of a toolchain",
+		Long: `Print the fully resolved configuration of a cpx-ci.yaml toolchain: inheritance,
+runner, image, defaults that were applied and the final build commands. The
+host values of env_passthrough and secrets variables are masked.`,
+		Example: `  cpx ci explain linux-release
+  cpx ci explain linux-debug --test`,
+		RunE: runCIExplain,
+		Args: cobra.ExactArgs(1),
+	}
+	explainCmd.Flags().Bool("test", false, "Show the commands used when running tests")
+	explainCmd.Flags().Bool("bench", false, "Show the commands used when running benchmarks")
+	cmd.AddCommand(explainCmd)
+
+	shellCmd := &cobra.Command{
+		Use:   "shell <toolchain>",
+		Short: "Open a shell in a toolchain's build container",
+		Long: `Start an interactive bash in the Docker container a toolchain builds in, with
+the same image, mounts (project, build directory, caches, output) and
+environment as 'cpx ci build', to reproduce and debug build failures by hand.
+The build commands are printed and kept in the shell history.`,
+		Example: `  cpx ci shell linux-release`,
+		RunE:    runCIShell,
+		Args:    cobra.ExactArgs(1),
+	}
+	shellCmd.Flags().Bool("verbose", false, "Show image build output")
+	cmd.AddCommand(shellCmd)
+
+	execCmd := &cobra.Command{
+		Use:   "exec --toolchain <name> -- <command> [args...]",
+		Short: "Run a command in a toolchain's build container",
+		Long: `Run a command in the Docker container a toolchain builds in, with the same
+image, mounts (project at /workspace, build directory, caches, output) and
+environment as 'cpx ci build'. Useful to inspect a toolchain or run ad-hoc
+scripts; stdin is passed through and the command's exit status is returned.`,
+		Example: `  cpx ci exec --toolchain linux-arm64 -- cmake --version
+  cpx ci exec --toolchain linux-release -- ls /tmp/build
+  cpx ci exec --toolchain linux-release -- bash -s < scripts/check.sh`,
+		RunE: runCIExec,
+		Args: cobra.MinimumNArgs(1),
+	}
+	execCmd.Flags().String("toolchain", "", "Toolchain whose container runs the command")
+	execCmd.Flags().Bool("verbose", false, "Show image build output")
+	_ = execCmd.MarkFlagRequired("toolchain")
+	cmd.AddCommand(execCmd)
+
+	analyzeCmd := &cobra.Command{
+		Use:   "analyze --target <toolchain> [paths...]",
+		Short: "Run code analysis in a toolchain's build container",
+		Long: `Run cppcheck, clang-tidy, flawfinder and include-what-you-use inside the
+Docker container a toolchain builds in, with its compilation database,
+compilers and system headers, so code behind platform conditionals
+(#ifdef __aarch64__, _WIN32, ...) is analyzed as that toolchain compiles
+it. The image needs the analyzers; those it lacks are skipped. Build the
+toolchain with 'cpx ci build' first.
+
+Paths in the report are those of the host. The flags are those of
+'cpx analyze': report formats, baselines, suppressions and CI gates.`,
+		Example: `  cpx ci analyze --target linux-arm64
+  cpx ci analyze --target linux-arm64 --format github --fail-on error`,
+		RunE: runCIAnalyze,
+		Args: cobra.ArbitraryArgs,
+	}
+	analyzeCmd.Flags().StringP("target", "t", "", "Toolchain whose container runs the analyzers")
+	analyzeCmd.Flags().Bool("verbose", false, "Show image build output")
+	addAnalyzeFlags(analyzeCmd)
+	_ = analyzeCmd.MarkFlagRequired("target")
+	cmd.AddCommand(analyzeCmd)
+
+	prefetchCmd := &cobra.Command{
+		Use:   "prefetch",
+		Short: "Pull toolchain images and download dependencies",
+		Long: `Pull the Docker images of all active toolchains and download their dependencies
+(vcpkg install, bazel fetch, meson subprojects download) into the per-toolchain
+caches without compiling the project, so the first build is fast.`,
+		Example: `  cpx ci prefetch
+  cpx ci prefetch --toolchain linux-release`,
+		RunE: runCIPrefetch,
+		Args: cobra.NoArgs,
+	}
+	prefetchCmd.Flags().String("toolchain", "", "Prefetch a single toolchain")
+	prefetchCmd.Flags().Bool("verbose", false, "Show dependency tool output")
+	cmd.AddCommand(prefetchCmd)
+
+	bakeCmd := &cobra.Command{
+		Use:   "bake",
+		Short: "Generate docker-bake.hcl for runner images",
+		Long: `Generate a docker-bake.hcl with a target for every Docker runner that builds its
+image from a Dockerfile (Dockerfile, platform, build args and content-hash tag), so
+all runner images can be built concurrently with a shared layer cache:
+  docker buildx bake --load`,
+		Example: `  cpx ci bake
+  cpx ci bake --output ci/docker-bake.hcl
+  cpx ci bake --stdout | docker buildx bake -f - --load`,
+		RunE: runCIBake,
+		Args: cobra.NoArgs,
+	}
+	bakeCmd.Flags().StringP("output", "o", "docker-bake.hcl", "File to write")
+	bakeCmd.Flags().Bool("stdout", false, "Print to stdout instead of writing a file")
+	cmd.AddCommand(bakeCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "setup-qemu",
+		Short: "Register QEMU handlers for emulated Docker platforms",
+		Long: `Register QEMU binfmt_misc handlers in the Docker engine's kernel (using the
+` + docker.QEMUImage + ` image), so containers for other architectures
+(arm64, riscv64, s390x, ppc64le, ...) run under emulation. Docker Desktop ships
+with these handlers; plain Docker on Linux needs this once per boot.`,
+		Example: `  cpx ci setup-qemu`,
+		RunE:    runCISetupQEMU,
+		Args:    cobra.NoArgs,
+	})
+
+	return cmd
+}
+
+func runCIBuild(cmd *cobra.Command, _ []string) error {
+	toolchainName, _ := cmd.Flags().GetString("toolchain")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	excludeTags, _ := cmd.Flags().GetStringSlice("exclude-tags")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	force, _ := cmd.Flags().GetBool("force")
+	changedOnly, _ := cmd.Flags().GetBool("changed-only")
+	base, _ := cmd.Flags().GetString("base")
+	since, _ := cmd.Flags().GetString("since")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	upload, _ := cmd.Flags().GetBool("upload")
+	keepGoing, _ := cmd.Flags().GetBool("keep-going")
+	resume, _ := cmd.Flags().GetBool("resume")
+	profile, _ := cmd.Flags().GetString("profile")
+	if jobs < 0 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+	if since != "" {
+		if base != "" && base != since {
+			return fmt.Errorf("--since and --base name different refs")
+		}
+		changedOnly, base = true, since
+	}
+	if asJSON && dryRun {
+		return fmt.Errorf("--json cannot be combined with --dry-run")
+	}
+	options := ToolchainBuildOptions{
+		ToolchainName: toolchainName,
+		Tags:          tags,
+		ExcludeTags:   excludeTags,
+		Verbose:       verbose,
+		Parallel:      jobs,
+		Force:         force,
+		ChangedOnly:   changedOnly,
+		Base:          base,
+		DryRun:        dryRun,
+		Upload:        upload,
+		KeepGoing:     keepGoing,
+		Resume:        resume,
+		Profile:       profile,
+	}
+	if asJSON {
+		return runToolchainBuildJSON(options)
+	}
+	return runToolchainBuild(options)
+}
+
+func runCITest(cmd *cobra.Command, _ []string) error {
+	toolchainName, _ := cmd.Flags().GetString("toolchain")
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	excludeTags, _ := cmd.Flags().GetStringSlice("exclude-tags")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	profile, _ := cmd.Flags().GetString("profile")
+	withCoverage, _ := cmd.Flags().GetBool("coverage")
+	if jobs < 0 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+	ciConfig, loadErr := config.LoadToolchains("cpx-ci.yaml")
+	if withCoverage && loadErr == nil {
+		if err := clearCICoverage(ciConfig.GetOutputDir()); err != nil {
+			return err
+		}
+	}
+
+	results, err := buildToolchains(ToolchainBuildOptions{
+		ToolchainName: toolchainName,
+		Tags:          tags,
+		ExcludeTags:   excludeTags,
+		Verbose:       verbose,
+		Parallel:      jobs,
+		Profile:       profile,
+		RunTests:      true,
+		Coverage:      withCoverage,
+	})
+	if loadErr != nil {
+		return err
+	}
+	printTestResults(ciConfig.GetOutputDir(), results)
+	if withCoverage {
+		projectRoot, wdErr := os.Getwd()
+		if wdErr != nil {
+			return wdErr
+		}
+		if coverageErr := reportCICoverage(projectRoot, ciConfig.GetOutputDir(), results); err == nil {
+			err = coverageErr
+		}
+	}
+	return err
+}
+
+// printTestResults lists the test result directories the toolchains produced
+func printTestResults(outputDir string, results []toolchainResult) {
+	first := true
+	for _, r := range results {
+		dir := filepath.Join(outputDir, r.Name, build.TestResultsDir)
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) == 0 {
+			continue
+		}
+		if first {
+			fmt.Printf("\n%sTest results%s\n", colors.Bold, colors.Reset)
+			first = false
+		}
+		fmt.Printf("  %s: %s\n", r.Name, dir)
+	}
+}
+
+func runCIExplain(cmd *cobra.Command, args []string) error {
+	runTests, _ := cmd.Flags().GetBool("test")
+	runBenchmarks, _ := cmd.Flags().GetBool("bench")
+	name := args[0]
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	tc, err := ciConfig.ResolveToolchain(name)
+	if err != nil {
+		return fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+	}
+	if tc == nil {
+		return fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", name)
+	}
+	runner := ciConfig.FindRunner(tc.Runner)
+	if runner == nil && tc.Runner != "" {
+		return fmt.Errorf("runner '%s' not found for toolchain '%s'", tc.Runner, tc.Name)
+	}
+	// The host values of forwarded variables are masked like in build output
+	forwarded := append(slices.Clone(tc.EnvPassthrough), tc.Secrets...)
+	w := build.DockerBuildOptions{Output: cmd.OutOrStdout(), Secrets: forwarded}.Stdout()
+	defer build.FlushOutput(w)
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	cacheDir := configuredCICacheDir(projectRoot)
+	outputDir := ciConfig.GetOutputDir()
+
+	fmt.Fprintf(w, "%sToolchain%s %s\n", colors.Bold, colors.Reset, tc.Name)
+	if chain := ciConfig.InheritanceChain(tc.Name); len(chain) > 1 {
+		printExplainField(w, "Extends", strings.Join(chain, " -> "), "")
+	}
+	active := "yes"
+	if !tc.IsActive() {
+		active = "no (skipped unless selected with --toolchain)"
+	}
+	printExplainField(w, "Active", active, "")
+
+	fmt.Fprintf(w, "\n%sRunner%s\n", colors.Bold, colors.Reset)
+	switch {
+	case runner == nil:
+		printExplainField(w, "Type", "native", "no runner set")
+	case runner.IsDocker():
+		printExplainField(w, "Name", runner.Name, "")
+		printExplainField(w, "Type", "docker", "")
+		image, err := runnerImage(projectRoot, runner)
+		if err != nil {
+			return err
+		}
+		// The tag docker resolves an untagged image to
+		if _, tag := splitImageRef(image); tag == "" && !strings.Contains(image, "@") {
+			image += ":latest"
+		}
+		target, _ := runnerTarget(runner)
+		if target != nil {
+			printExplainField(w, "Target", target.Name, target.Description)
+			printExplainField(w, "vcpkg triplet", target.Triplet, "")
+			if target.VerifyStatic {
+				printExplainField(w, "Static check", "ldd", "fails the build on dynamic dependencies")
+			}
+		}
+		switch {
+		case presetDockerfile(runner) != nil:
+			printExplainField(w, "Image", image, "built from the "+target.Name+" preset Dockerfile")
+		case runner.Build != nil:
+			dockerfile, buildContext := runnerDockerfile(projectRoot, runner)
+			printExplainField(w, "Image", image, "built from "+dockerfile)
+			printExplainField(w, "Build context", buildContext, "")
+		default:
+			printExplainField(w, "Image", image, "")
+		}
+		if runnerPushesImage(runner) {
+			printExplainField(w, "Push", "yes", "pulled before building, pushed after")
+		}
+		endpoint, err := runnerEndpoint(runner)
+		if err != nil {
+			return fmt.Errorf("invalid Docker endpoint for runner '%s': %w", runner.Name, err)
+		}
+		if endpoint.String() != "" {
+			note := "local, bind mounts"
+			if endpoint.Remote {
+				note = "remote, project streamed into volumes"
+			}
+			printExplainField(w, "Docker engine", endpoint.String(), note)
+		}
+		if user := containerUser(runner, endpoint); user != "" {
+			printExplainField(w, "Container user", user, "set docker_user: root to build as root")
+		}
+		if runner.Network != "" {
+			printExplainField(w, "Network", runner.Network, "")
+		}
+		if len(runner.ExtraHosts) > 0 {
+			printExplainField(w, "Extra hosts", strings.Join(runner.ExtraHosts, ", "), "")
+		}
+		if len(runner.DNS) > 0 {
+			printExplainField(w, "DNS", strings.Join(runner.DNS, ", "), "")
+		}
+		if id := endpoint.ImageID(image); id != "" {
+			printExplainField(w, "Image ID", id, "")
+		} else if runnerBuildsImage(runner) {
+			printExplainField(w, "Image ID", "not built yet", "cpx ci bake")
+		} else {
+			printExplainField(w, "Image ID", "not found locally", "docker pull "+image)
+		}
+		platform := runnerPlatform(runner)
+		platformNote := ""
+		if platform == "" {
+			platformNote = "image default"
+			platform = endpoint.ImagePlatform(image)
+		}
+		printExplainField(w, "Platform", docker.DescribePlatform(platform), platformNote)
+	default:
+		printExplainField(w, "Name", runner.Name, "")
+		printExplainField(w, "Type", runner.Type, "")
+		if runner.IsSSH() {
+			printExplainField(w, "Host", sshHost(runner).Destination(), "")
+			if runner.Port != 0 {
+				printExplainField(w, "Port", fmt.Sprintf("%d", runner.Port), "")
+			}
+			printExplainField(w, "Work dir", sshWorkDir(projectRoot, runner), "")
+		}
+		if runner.IsPlugin() {
+			printExplainField(w, "Plugin", runner.Plugin, "")
+		}
+	}
+	if runner != nil {
+		cc, cxx := runnerCompilers(runner)
+		printExplainField(w, "CC", cc, "compiler default")
+		printExplainField(w, "CXX", cxx, "compiler default")
+		printExplainField(w, "CMake toolchain", runner.CMakeToolchainFile, "none")
+	}
+
+	fmt.Fprintf(w, "\n%sBuild%s\n", colors.Bold, colors.Reset)
+	printExplainField(w, "Build type", tc.BuildType, "")
+	if tc.Optimization == "" {
+		printExplainField(w, "Optimization", "-O2", "default")
+	} else {
+		printExplainField(w, "Optimization", "-O"+tc.Optimization, "")
+	}
+	if tc.Jobs > 0 {
+		printExplainField(w, "Jobs", fmt.Sprintf("%d", tc.Jobs), "")
+	} else if jobs := dockerJobs(*tc); jobs > 0 && runner != nil && runner.IsDocker() {
+		printExplainField(w, "Jobs", fmt.Sprintf("%d", jobs), "from resources.cpus")
+	} else {
+		printExplainField(w, "Jobs", "", "build tool default")
+	}
+	if tc.Resources != nil && runner != nil && runner.IsDocker() {
+		if tc.Resources.CPUs > 0 {
+			printExplainField(w, "CPU limit", strconv.FormatFloat(tc.Resources.CPUs, 'f', -1, 64), "docker run --cpus")
+		}
+		printExplainField(w, "Memory limit", tc.Resources.Memory, "none")
+	}
+	for _, svc := range tc.Services {
+		printExplainField(w, "Service", svc.Name, svc.Image+", started for tests, benchmarks and runs")
+	}
+	if target, _ := runnerTarget(runner); tc.Hardening && target != nil && !target.Hardening {
+		printExplainField(w, "Hardening", "false", "not supported for "+target.Name)
+	} else {
+		printExplainField(w, "Hardening", fmt.Sprintf("%t", tc.Hardening), "")
+	}
+	if len(tc.Env) > 0 {
+		keys := make([]string, 0, len(tc.Env))
+		for k := range tc.Env {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		fmt.Fprintf(w, "  Env:\n")
+		for _, k := range keys {
+			fmt.Fprintf(w, "    %s=%s\n", k, tc.Env[k])
+		}
+	}
+
+	if len(forwarded) > 0 {
+		fmt.Fprintf(w, "  Forwarded:\n")
+		for _, name := range forwarded {
+			note := "from the host"
+			if slices.Contains(tc.Secrets, name) {
+				note = "secret, masked in output"
+			}
+			if os.Getenv(name) == "" {
+				fmt.Fprintf(w, "    %s %s(not set on the host)%s\n", name, colors.Gray, colors.Reset)
+				continue
+			}
+			fmt.Fprintf(w, "    %s=*** %s(%s)%s\n", name, colors.Gray, note, colors.Reset)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%sPaths%s\n", colors.Bold, colors.Reset)
+	printExplainField(w, "Build cache", filepath.Join(cacheDir, tc.Name), "")
+	if cache := describeCompilerCache(*tc, ciConfig.Cache); cache != "" {
+		switch {
+		case runner == nil || !runner.IsDocker():
+			printExplainField(w, "Compiler cache", "", "Docker runners only")
+		case cache == "ccache":
+			printExplainField(w, "Compiler cache", cache, filepath.Join(cacheDir, tc.Name, ".ccache"))
+		default:
+			printExplainField(w, "Compiler cache", cache, "")
+		}
+	}
+	if ciConfig.Vcpkg != nil && len(ciConfig.Vcpkg.BinarySources) > 0 && runner != nil && runner.IsDocker() {
+		sources := []string{"local"}
+		for _, src := range ciConfig.Vcpkg.BinarySources {
+			sources = append(sources, strings.TrimSpace(src.Type+" "+src.URL))
+		}
+		printExplainField(w, "Binary caches", strings.Join(sources, ", "), "vcpkg")
+	}
+	printExplainField(w, "Artifacts", filepath.Join(outputDir, tc.Name), "")
+
+	fmt.Fprintf(w, "\n%sCommands%s\n", colors.Bold, colors.Reset)
+	for _, line := range explainCommands(*tc, runner, ciConfig.Cache, projectRoot, cacheDir, outputDir, runTests, runBenchmarks) {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+	return nil
+}
+
+// printExplainField prints one "Label: value" line, falling back to a gray note
+// when the value is empty and appending it otherwise
+func printExplainField(w io.Writer, label, value, note string) {
+	switch {
+	case value == "":
+		fmt.Fprintf(w, "  %-16s %s(%s)%s\n", label+":", colors.Gray, note, colors.Reset)
+	case note != "":
+		fmt.Fprintf(w, "  %-16s %s %s(%s)%s\n", label+":", value, colors.Gray, note, colors.Reset)
+	default:
+		fmt.Fprintf(w, "  %-16s %s\n", label+":", value)
+	}
+}
+
+// explainCommands returns the build commands runToolchainBuild runs for a toolchain
+func explainCommands(tc config.Toolchain, runner *config.Runner, cache *config.CacheConfig, projectRoot, cacheDir, outputDir string, runTests, runBenchmarks bool) []string {
+	if runner == nil || runner.IsNative() {
+		absBuildDir, _ := filepath.Abs(filepath.Join(cacheDir, tc.Name))
+		absProjectRoot, _ := filepath.Abs(projectRoot)
+		return []string{
+			"cmake " + strings.Join(nativeCMakeArgs(tc, runner, absProjectRoot, absBuildDir, runTests, runBenchmarks), " "),
+			"cmake " + strings.Join(nativeBuildArgs(tc, projectRoot, absBuildDir, runBenchmarks), " "),
+		}
+	}
+	if runner.IsSSH() {
+		host := sshHost(runner)
+		work := sshWorkDir(projectRoot, runner)
+		lines := []string{fmt.Sprintf("rsync %s/ -> %s:%s/src/", projectRoot, host.Destination(), work)}
+		script := sshBuildScript(tc, runner, projectRoot, work, DetectProjectType(), runTests, runBenchmarks)
+		for _, line := range strings.Split(strings.TrimSpace(script), "\n") {
+			lines = append(lines, "ssh> "+line)
+		}
+		return append(lines, fmt.Sprintf("rsync %s:%s/out/%s/ -> %s/", host.Destination(), work, tc.Name, filepath.Join(outputDir, tc.Name)))
+	}
+	if runner.IsPlugin() {
+		var lines []string
+		for _, step := range []string{"prepare", "build", "collect"} {
+			lines = append(lines, runner.Plugin+" "+step)
+		}
+		return lines
+	}
+	if !runner.IsDocker() {
+		return []string{fmt.Sprintf("(%s runners are not supported yet)", runner.Type)}
+	}
+
+	image, _ := runnerImage(projectRoot, runner)
+	endpoint, _ := runnerEndpoint(runner)
+	opts := toolchainDockerOptions(tc, runner, endpoint, image, projectRoot, cacheDir, outputDir, ToolchainBuildOptions{
+		RunTests:      runTests,
+		RunBenchmarks: runBenchmarks,
+		Cache:         cache,
+	})
+	describer, ok := dockerBuilderFor(projectRoot).(build.DockerBuildDescriber)
+	if !ok {
+		return []string{"(build commands not available for this build system)"}
+	}
+	return describer.DescribeDockerBuild(opts)
+}
+
+func runCIPrefetch(cmd *cobra.Command, _ []string) error {
+	toolchainName, _ := cmd.Flags().GetString("toolchain")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	allToolchains, err := ciConfig.ResolveToolchains()
+	if err != nil {
+		return fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+	}
+	toolchains, err := selectToolchains(allToolchains, toolchainName)
+	if err != nil {
+		return err
+	}
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	cacheDir := ciCacheDir(projectRoot)
+	outputDir := ciConfig.GetOutputDir()
+
+	prefetcher, _ := dockerBuilderFor(projectRoot).(build.DockerPrefetcher)
+	pulled := make(map[string]bool)
+
+	for i, tc := range toolchains {
+		runner := ciConfig.FindRunner(tc.Runner)
+		if runner == nil && tc.Runner != "" {
+			return fmt.Errorf("runner '%s' not found for toolchain '%s'", tc.Runner, tc.Name)
+		}
+		fmt.Printf("\n%s[%d/%d] Prefetching: %s%s\n", colors.Cyan, i+1, len(toolchains), tc.Name, colors.Reset)
+
+		if runner == nil || !runner.IsDocker() {
+			fmt.Printf("  %sSkipped: only Docker toolchains are prefetched%s\n", colors.Gray, colors.Reset)
+			continue
+		}
+		if runner.Image == "" && !runnerBuildsImage(runner) {
+			return fmt.Errorf("Docker runner '%s' has no image specified", runner.Name)
+		}
+		image, err := runnerImage(projectRoot, runner)
+		if err != nil {
+			return err
+		}
+		endpoint, err := runnerEndpoint(runner)
+		if err != nil {
+			return fmt.Errorf("invalid Docker endpoint for '%s': %w", tc.Name, err)
+		}
+
+		// Runners are often shared, so each image/platform pair is fetched once
+		platform := runnerPlatform(runner)
+		key := image + "|" + platform + "|" + endpoint.String()
+		if !pulled[key] {
+			if err := checkQEMU(endpoint, runner); err != nil {
+				return fmt.Errorf("cannot prefetch '%s': %w", tc.Name, err)
+			}
+			if runnerBuildsImage(runner) {
+				if endpoint.ImageID(image) == "" {
+					if err := pullOrBuildRunnerImage(endpoint, projectRoot, runner, image, verbose); err != nil {
+						return fmt.Errorf("failed to build image for '%s': %w", tc.Name, err)
+					}
+				}
+			} else {
+				fmt.Printf("  %s Pulling %s (%s)...%s\n", colors.Yellow, image, docker.DescribePlatform(platform), colors.Reset)
+				if err := pullImage(endpoint, image, platform, verbose); err != nil {
+					return fmt.Errorf("failed to pull image for '%s': %w", tc.Name, err)
+				}
+			}
+			pulled[key] = true
+		}
+
+		if prefetcher == nil {
+			continue
+		}
+		fmt.Printf("  %s Downloading dependencies...%s\n", colors.Yellow, colors.Reset)
+		opts := toolchainDockerOptions(tc, runner, endpoint, image, projectRoot, cacheDir, outputDir, ToolchainBuildOptions{Verbose: verbose, Vcpkg: ciConfig.Vcpkg})
+		if endpoint.Remote {
+			if err := opts.Endpoint.Upload(projectRoot, image, remoteUploadExcludes...); err != nil {
+				return fmt.Errorf("failed to upload project for '%s': %w", tc.Name, err)
+			}
+		}
+		if err := prefetcher.PrefetchDockerDependencies(context.Background(), opts); err != nil {
+			return fmt.Errorf("failed to prefetch '%s': %w", tc.Name, err)
+		}
+		fmt.Printf("%s Prefetched '%s'%s\n", colors.Green, tc.Name, colors.Reset)
+	}
+
+	fmt.Printf("\n%s Prefetch complete%s\n", colors.Green, colors.Reset)
+	return nil
+}
+
+// pullImage pulls a Docker image onto endpoint, optionally for a specific platform
+func pullImage(endpoint docker.Endpoint, image, platform string, verbose bool) error {
+	args := []string{"pull"}
+	if platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	if !verbose {
+		args = append(args, "--quiet")
+	}
+	args = append(args, image)
+
+	cmd := endpoint.Command(args...)
+	if verbose {
+		cmd.Stdout = os.Stdout
+	}
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func runCIBake(cmd *cobra.Command, _ []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	toStdout, _ := cmd.Flags().GetBool("stdout")
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+
+	content, err := generateBakeFile(projectRoot, configuredCICacheDir(projectRoot), ciConfig.Runners)
+	if err != nil {
+		return err
+	}
+	if toStdout {
+		fmt.Print(content)
+		return nil
+	}
+
+	if err := os.WriteFile(output, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Printf("%s Wrote %s%s\n", colors.Green, output, colors.Reset)
+	fmt.Printf("  Build all runner images with: docker buildx bake -f %s --load\n", output)
+	return nil
+}
+
+func runCISetupQEMU(_ *cobra.Command, _ []string) error {
+	fmt.Printf("%s Registering QEMU handlers with %s...%s\n", colors.Cyan, docker.QEMUImage, colors.Reset)
+	cmd := docker.Command(docker.SetupQEMUArgs()...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to register QEMU handlers (the engine must allow --privileged containers): %w", err)
+	}
+
+	for _, platform := range []string{"linux/amd64", "linux/arm64", "linux/riscv64", "linux/s390x", "linux/ppc64le"} {
+		if !docker.IsEmulated(platform) {
+			continue
+		}
+		if registered, known := docker.BinfmtRegistered(platform); known && !registered {
+			fmt.Printf("  %s⚠ %s: no handler registered%s\n", colors.Yellow, platform, colors.Reset)
+		} else if known {
+			fmt.Printf("  %s✓ %s%s\n", colors.Green, platform, colors.Reset)
+		}
+	}
+	fmt.Printf("%s QEMU handlers registered%s\n", colors.Green, colors.Reset)
+	return nil
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/coverage"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// ciCoverageDir is the directory of the output directory receiving the
+// combined coverage of `cpx ci test --coverage`
+const ciCoverageDir = "coverage"
+
+// containerProjectRoot is where Docker toolchains mount the project, the
+// root the paths of their coverage are relative to
+const containerProjectRoot = "/workspace"
+
+// coverageCompiler returns the compiler family instrumenting a Docker
+// toolchain's tests: clang when the C++ compiler of the runner or its target
+// is clang, else gcc
+func coverageCompiler(runner *config.Runner) string {
+	cc, cxx := runnerCompilers(runner)
+	if strings.Contains(filepath.Base(cmp.Or(cxx, cc)), "clang") {
+		return build.CoverageClang
+	}
+	return build.CoverageGCC
+}
+
+// clearCICoverage removes the coverage earlier runs collected in the
+// toolchains' output directories, so a toolchain that fails to build or is
+// skipped contributes none
+func clearCICoverage(outputDir string) error {
+	dirs, _ := filepath.Glob(filepath.Join(outputDir, "*", build.CoverageResultsDir))
+	for _, dir := range append(dirs, filepath.Join(outputDir, ciCoverageDir)) {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove old coverage: %w", err)
+		}
+	}
+	return nil
+}
+
+// toolchainCoverage is the coverage of a toolchain's tests
+type toolchainCoverage struct {
+	Name    string
+	Summary coverage.Summary
+	Found   bool
+}
+
+// mergeCICoverage reads the coverage each toolchain's container collected,
+// normalizes its paths from the container's project root, writes it as the
+// toolchain's lcov report and merges it into the combined report, written
+// with its HTML report to the output directory's coverage directory
+func mergeCICoverage(projectRoot, outputDir string, results []toolchainResult) (coverage.Report, []toolchainCoverage, error) {
+	combined := coverage.Report{}
+	var toolchains []toolchainCoverage
+	for _, r := range results {
+		if r.Skipped || r.Resumed {
+			continue
+		}
+		dir := filepath.Join(outputDir, r.Name, build.CoverageResultsDir)
+		raw, found, err := coverage.LoadResults(dir)
+		if err != nil {
+			return combined, toolchains, err
+		}
+		if !found {
+			toolchains = append(toolchains, toolchainCoverage{Name: r.Name})
+			continue
+		}
+		report := raw.Normalize(containerProjectRoot)
+		if err := report.SaveLcov(filepath.Join(dir, coverage.LcovFile)); err != nil {
+			return combined, toolchains, err
+		}
+		toolchains = append(toolchains, toolchainCoverage{Name: r.Name, Summary: report.Summary(), Found: true})
+		combined.Merge(report)
+	}
+	if len(combined.Files) == 0 {
+		return combined, toolchains, nil
+	}
+	opts := coverage.Options{Root: projectRoot, OutputDir: filepath.Join(outputDir, ciCoverageDir), HTML: true}
+	return combined, toolchains, opts.Write(combined)
+}
+
+// printCICoverage prints the coverage of each toolchain and the combined
+// coverage
+func printCICoverage(w io.Writer, toolchains []toolchainCoverage, combined coverage.Summary) {
+	width := len("combined")
+	for _, tc := range toolchains {
+		width = max(width, len(tc.Name))
+	}
+	row := func(name string, s coverage.Summary) {
+		fmt.Fprintf(w, "  %-*s", width, name)
+		for _, kind := range coverage.Kinds {
+			if counts := s.Counts(kind); counts.Found > 0 {
+				fmt.Fprintf(w, "  %s %5.1f%%", kind, counts.Percent())
+			} else {
+				fmt.Fprintf(w, "  %s %6s", kind, "n/a")
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "\n%sCoverage%s\n", colors.Bold, colors.Reset)
+	for _, tc := range toolchains {
+		if !tc.Found {
+			fmt.Fprintf(w, "  %-*s  no coverage (collected from CMake Docker toolchains)\n", width, tc.Name)
+			continue
+		}
+		row(tc.Name, tc.Summary)
+	}
+	row("combined", combined)
+}
+
+// reportCICoverage merges and prints the coverage of a `cpx ci test
+// --coverage` run and checks the combined coverage against the thresholds
+// of .cpx-quality.yaml
+func reportCICoverage(projectRoot, outputDir string, results []toolchainResult) error {
+	combined, toolchains, err := mergeCICoverage(projectRoot, outputDir, results)
+	if err != nil {
+		return fmt.Errorf("failed to merge coverage: %w", err)
+	}
+	if len(combined.Files) == 0 {
+		fmt.Printf("\n%sNo coverage was collected%s\n", colors.Yellow, colors.Reset)
+		return nil
+	}
+	printCICoverage(os.Stdout, toolchains, combined.Summary())
+	fmt.Printf("   Combined report: %s\n", filepath.Join(outputDir, ciCoverageDir, coverage.LcovFile))
+
+	thresholds, err := qualityCoverageThresholds()
+	if err != nil {
+		return err
+	}
+	shortfalls := combined.Summary().Check(thresholds)
+	if len(shortfalls) == 0 {
+		return nil
+	}
+	printCoverageShortfalls(os.Stdout, combined, shortfalls)
+	return coverageGateError(shortfalls)
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/build/remote"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// dryRunToolchains prints the commands building each toolchain would run,
+// without running them
+func dryRunToolchains(ciConfig *config.ToolchainConfig, toolchains []config.Toolchain, projectRoot, cacheDir, outputDir string, options ToolchainBuildOptions) error {
+	fmt.Printf("%s Dry run of %d toolchain(s): nothing is built%s\n", colors.Cyan, len(toolchains), colors.Reset)
+
+	for i, tc := range toolchains {
+		runner := ciConfig.FindRunner(tc.Runner)
+		if runner == nil && tc.Runner != "" {
+			return fmt.Errorf("runner '%s' not found for toolchain '%s'", tc.Runner, tc.Name)
+		}
+		fmt.Printf("\n%s[%d/%d] %s%s\n", colors.Cyan, i+1, len(toolchains), tc.Name, colors.Reset)
+
+		hooks := config.MergeHooks(options.Hooks, tc.Hooks)
+		if hooks != nil {
+			fprintHooks(os.Stdout, "pre_build", hooks.PreBuild)
+		}
+		r, err := newRunner(runner)
+		if err != nil {
+			return err
+		}
+		if d, ok := r.(runnerDescriber); ok {
+			err = d.DryRun(os.Stdout, &runnerBuild{tc: tc, runner: runner, projectRoot: projectRoot, cacheDir: cacheDir, outputDir: outputDir, options: options})
+		} else {
+			fmt.Printf("%s# %s runners cannot describe their builds%s\n", colors.Gray, runner.Type, colors.Reset)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to describe '%s': %w", tc.Name, err)
+		}
+		if hooks != nil {
+			fprintHooks(os.Stdout, "post_build", hooks.PostBuild)
+			fprintHooks(os.Stdout, "on_failure", hooks.OnFailure)
+		}
+	}
+	return nil
+}
+
+// dryRunDocker prints the image build, if the image is missing, and the
+// docker run command with its build script
+func dryRunDocker(w io.Writer, tc config.Toolchain, runner *config.Runner, projectRoot, cacheDir, outputDir string, options ToolchainBuildOptions) error {
+	endpoint, err := runnerEndpoint(runner)
+	if err != nil {
+		return fmt.Errorf("invalid Docker endpoint: %w", err)
+	}
+	if runner.Image == "" && !runnerBuildsImage(runner) {
+		return fmt.Errorf("Docker runner '%s' has no image specified", runner.Name)
+	}
+	imageName, err := runnerImage(projectRoot, runner)
+	if err != nil {
+		return err
+	}
+
+	if output, err := endpoint.Command("images", "-q", imageName).Output(); err != nil || len(output) == 0 {
+		if !runnerBuildsImage(runner) {
+			fmt.Fprintf(w, "%s# image %s is not present; it must be pulled first%s\n", colors.Yellow, imageName, colors.Reset)
+		} else {
+			args, stdin, err := runnerImageBuildArgs(projectRoot, runner, imageName, options.Verbose)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s# image %s is built first%s\n", colors.Gray, imageName, colors.Reset)
+			build.FprintDockerCommand(w, endpoint, args)
+			if stdin != nil {
+				fmt.Fprintf(w, "%s# with the Dockerfile of target preset '%s' on stdin%s\n", colors.Gray, runner.Target, colors.Reset)
+			}
+		}
+	}
+
+	opts := toolchainDockerOptions(tc, runner, endpoint, imageName, projectRoot, cacheDir, outputDir, options)
+	opts.DryRun = true
+	opts.Output = w
+	if endpoint.Remote {
+		fmt.Fprintf(w, "%s# the project is uploaded to volume %s on %s first; artifacts are downloaded afterwards%s\n",
+			colors.Gray, opts.Endpoint.Volume(projectRoot), endpoint, colors.Reset)
+	}
+	fmt.Fprintf(w, "%s# build%s\n", colors.Gray, colors.Reset)
+	return dockerBuilderFor(projectRoot).RunDockerBuild(context.Background(), opts)
+}
+
+// dryRunNative prints the CMake commands of a native build with the
+// environment they run in
+func dryRunNative(w io.Writer, tc config.Toolchain, runner *config.Runner, projectRoot, cacheDir string, options ToolchainBuildOptions) error {
+	absBuildDir, err := filepath.Abs(filepath.Join(cacheDir, tc.Name))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for build directory: %w", err)
+	}
+	absProjectRoot, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+
+	var env []string
+	if runner != nil {
+		if runner.CC != "" {
+			env = append(env, "CC="+runner.CC)
+		}
+		if runner.CXX != "" {
+			env = append(env, "CXX="+runner.CXX)
+		}
+	}
+	var tcEnv []string
+	for k, v := range tc.Env {
+		tcEnv = append(tcEnv, k+"="+v)
+	}
+	slices.Sort(tcEnv)
+	env = append(env, tcEnv...)
+
+	configure := append(append(slices.Clone(env), "cmake"), nativeCMakeArgs(tc, runner, absProjectRoot, absBuildDir, options.RunTests, options.RunBenchmarks)...)
+	compile := append(append(slices.Clone(env), "cmake"), nativeBuildArgs(tc, projectRoot, absBuildDir, options.RunBenchmarks)...)
+	fmt.Fprintf(w, "%s# configure%s\n", colors.Gray, colors.Reset)
+	build.FprintCommand(w, configure)
+	fmt.Fprintf(w, "%s# build%s\n", colors.Gray, colors.Reset)
+	build.FprintCommand(w, compile)
+	return nil
+}
+
+// dryRunSSH prints the sync, build and copy-back commands of an SSH build
+func dryRunSSH(w io.Writer, tc config.Toolchain, runner *config.Runner, projectRoot, outputDir string, options ToolchainBuildOptions) error {
+	if runner.Host == "" {
+		return fmt.Errorf("SSH runner '%s' has no host specified", runner.Name)
+	}
+	host := sshHost(runner)
+	work := sshWorkDir(projectRoot, runner)
+	localOut, err := filepath.Abs(filepath.Join(outputDir, tc.Name))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for output directory: %w", err)
+	}
+
+	fmt.Fprintf(w, "%s# sync%s\n", colors.Gray, colors.Reset)
+	build.FprintCommand(w, host.Command("mkdir -p "+remote.Quote(work+"/src")).Args)
+	build.FprintCommand(w, host.Push(projectRoot, work+"/src", sshExcludes...).Args)
+	fmt.Fprintf(w, "%s# build%s\n", colors.Gray, colors.Reset)
+	build.FprintCommand(w, host.Command(sshBuildScript(tc, runner, projectRoot, work, DetectProjectType(), options.RunTests, options.RunBenchmarks)).Args)
+	fmt.Fprintf(w, "%s# copy artifacts%s\n", colors.Gray, colors.Reset)
+	build.FprintCommand(w, host.Pull(work+"/out/"+tc.Name, localOut).Args)
+	return nil
+}
@@ -0,0 +1,358 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/packaging"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// exportHeader starts every exported pipeline
+const exportHeader = "# Generated by 'cpx ci export' from cpx-ci.yaml; re-run it after changing cpx-ci.yaml.\n"
+
+// installCPXWindows are the PowerShell commands installing cpx on Windows CI machines
+const installCPXWindows = `New-Item -ItemType Directory -Force -Path "$env:USERPROFILE\bin" | Out-Null
+Invoke-WebRequest "https://github.com/ozacod/cpx/releases/latest/download/cpx-windows-amd64.exe" -OutFile "$env:USERPROFILE\bin\cpx.exe"`
+
+// installCPX is the shell command installing cpx on Linux and macOS CI machines
+const installCPX = "curl -fsSL https://raw.githubusercontent.com/ozacod/cpx/main/install.sh | sh"
+
+// exportJob is a toolchain built by its own job of an exported pipeline
+type exportJob struct {
+	Toolchain string
+	OS        string // linux, windows or darwin: the machine the job runs on
+	Docker    bool   // built in a Docker container
+	QEMU      bool   // a Docker toolchain for another architecture than amd64
+}
+
+// exportJobs returns a job for each active toolchain, with extends applied.
+// Docker toolchains run on Linux machines; native toolchains on a machine of
+// the OS their name or runner names, Linux by default.
+func exportJobs(ciConfig *config.ToolchainConfig) ([]exportJob, error) {
+	toolchains, err := ciConfig.ResolveToolchains()
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+	}
+	var jobs []exportJob
+	for _, tc := range toolchains {
+		if !tc.IsActive() {
+			continue
+		}
+		job := exportJob{Toolchain: tc.Name, OS: "linux"}
+		runner := ciConfig.FindRunner(tc.Runner)
+		if runner != nil && runner.IsDocker() {
+			job.Docker = true
+			job.QEMU = packaging.Arch(runnerPlatform(runner), tc.Name, "amd64") != "amd64"
+		} else if jobOS := packaging.OS("", tc.Name, "linux"); jobOS == "windows" || jobOS == "darwin" {
+			job.OS = jobOS
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func runCIExport(cmd *cobra.Command, _ []string) error {
+	platform := cmd.Name()
+	output, _ := cmd.Flags().GetString("output")
+	stdout, _ := cmd.Flags().GetBool("stdout")
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	jobs, err := exportJobs(ciConfig)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no active toolchains in cpx-ci.yaml")
+	}
+
+	var content string
+	switch platform {
+	case "github":
+		content = githubWorkflow(jobs, filepath.ToSlash(ciConfig.GetOutputDir()))
+	case "gitlab":
+		content = gitlabPipeline(jobs, filepath.ToSlash(ciConfig.GetOutputDir()))
+	case "azure":
+		content = azurePipeline(jobs, filepath.ToSlash(ciConfig.GetOutputDir()))
+	default:
+		return fmt.Errorf("unknown CI platform '%s'", platform)
+	}
+	if stdout {
+		fmt.Print(content)
+		return nil
+	}
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	path := filepath.Join(projectRoot, filepath.FromSlash(output))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Printf("%s✓ Wrote %s with %d toolchain job(s)%s\n", colors.Green, output, len(jobs), colors.Reset)
+	return nil
+}
+
+// githubRunsOn maps the OS of a job to a GitHub-hosted runner
+var githubRunsOn = map[string]string{
+	"linux":   "ubuntu-latest",
+	"windows": "windows-latest",
+	"darwin":  "macos-latest",
+}
+
+// githubWorkflow returns a GitHub Actions workflow building each toolchain
+// in a matrix job, caching its build directory and uploading its artifacts
+func githubWorkflow(jobs []exportJob, outputDir string) string {
+	var b strings.Builder
+	b.WriteString(exportHeader)
+	b.WriteString(`name: cpx
+
+on:
+  push:
+    branches: [main, master]
+    tags: ["v*"]
+  pull_request:
+
+jobs:
+  build:
+    name: ${{ matrix.toolchain }}
+    runs-on: ${{ matrix.os }}
+    strategy:
+      fail-fast: false
+      matrix:
+        include:
+`)
+	qemu := false
+	for _, job := range jobs {
+		fmt.Fprintf(&b, "          - toolchain: %q\n", job.Toolchain)
+		fmt.Fprintf(&b, "            os: %s\n", githubRunsOn[job.OS])
+		qemu = qemu || job.QEMU
+	}
+
+	b.WriteString(`    steps:
+      - uses: actions/checkout@v4
+
+      - name: Install cpx
+        if: runner.os != 'Windows'
+        run: |
+          ` + installCPX + `
+          echo "$HOME/.local/bin" >> "$GITHUB_PATH"
+
+      - name: Install cpx (Windows)
+        if: runner.os == 'Windows'
+        shell: pwsh
+        run: |
+` + indent(installCPXWindows, 10) + `
+          "$env:USERPROFILE\bin" | Out-File -Append -Encoding utf8 $env:GITHUB_PATH
+
+      - uses: docker/setup-buildx-action@v3
+        if: runner.os == 'Linux'
+`)
+	if qemu {
+		b.WriteString(`
+      - uses: docker/setup-qemu-action@v3
+        if: runner.os == 'Linux'
+`)
+	}
+	fmt.Fprintf(&b, `
+      - uses: actions/cache@v4
+        with:
+          path: .cache/ci/${{ matrix.toolchain }}
+          key: cpx-${{ matrix.toolchain }}-${{ hashFiles('cpx-ci.yaml', 'vcpkg.json', '**/CMakeLists.txt') }}
+          restore-keys: cpx-${{ matrix.toolchain }}-
+
+      - name: Build
+        run: cpx ci build --toolchain ${{ matrix.toolchain }}
+
+      - uses: actions/upload-artifact@v4
+        with:
+          name: ${{ matrix.toolchain }}
+          path: %s/${{ matrix.toolchain }}
+          if-no-files-found: error
+`, outputDir)
+	return b.String()
+}
+
+// gitlabRunnerTags select GitLab's hosted runners for native Windows and macOS jobs
+var gitlabRunnerTags = map[string]string{
+	"windows": "saas-windows-medium-amd64",
+	"darwin":  "saas-macos-medium-m1",
+}
+
+// gitlabPipeline returns a GitLab CI pipeline with a job per toolchain.
+// Docker toolchains build through a Docker-in-Docker service.
+func gitlabPipeline(jobs []exportJob, outputDir string) string {
+	var b strings.Builder
+	b.WriteString(exportHeader)
+	b.WriteString(`stages: [build]
+
+.cpx:
+  stage: build
+  cache:
+    key:
+      files: [cpx-ci.yaml, vcpkg.json]
+      prefix: $CPX_TOOLCHAIN
+    paths:
+      - .cache/ci/$CPX_TOOLCHAIN
+  artifacts:
+    paths:
+      - ` + outputDir + `/$CPX_TOOLCHAIN
+    expire_in: 1 week
+
+.cpx-docker:
+  extends: .cpx
+  image: docker:27
+  services:
+    - docker:27-dind
+  variables:
+    DOCKER_HOST: tcp://docker:2376
+    DOCKER_TLS_CERTDIR: /certs
+    DOCKER_TLS_VERIFY: "1"
+    DOCKER_CERT_PATH: /certs/client
+  before_script:
+    - apk add --no-cache bash curl git
+    - ` + installCPX + `
+    - export PATH="$HOME/.local/bin:$PATH"
+`)
+	for _, job := range jobs {
+		if job.QEMU {
+			b.WriteString("    - docker run --privileged --rm tonistiigi/binfmt --install all\n")
+			break
+		}
+	}
+	for _, job := range jobs {
+		fmt.Fprintf(&b, "\n%q:\n", job.Toolchain)
+		switch {
+		case job.Docker:
+			b.WriteString("  extends: .cpx-docker\n")
+		case job.OS == "windows":
+			fmt.Fprintf(&b, "  extends: .cpx\n  tags: [%s]\n  before_script:\n", gitlabRunnerTags[job.OS])
+			for _, line := range strings.Split(installCPXWindows, "\n") {
+				fmt.Fprintf(&b, "    - %s\n", yamlQuote(line))
+			}
+			b.WriteString("    - '$env:PATH = \"$env:USERPROFILE\\bin;$env:PATH\"'\n")
+		default:
+			b.WriteString("  extends: .cpx\n")
+			if tag, ok := gitlabRunnerTags[job.OS]; ok {
+				fmt.Fprintf(&b, "  tags: [%s]\n", tag)
+			} else {
+				b.WriteString("  image: ubuntu:24.04\n")
+			}
+			b.WriteString("  before_script:\n")
+			if job.OS == "linux" {
+				b.WriteString("    - apt-get update && apt-get install -y --no-install-recommends ca-certificates curl git build-essential cmake ninja-build\n")
+			}
+			fmt.Fprintf(&b, "    - %s\n", installCPX)
+			b.WriteString("    - export PATH=\"$HOME/.local/bin:$PATH\"\n")
+		}
+		fmt.Fprintf(&b, "  variables:\n    CPX_TOOLCHAIN: %q\n", job.Toolchain)
+		if job.OS == "windows" && !job.Docker {
+			// PowerShell reads variables from $env:
+			b.WriteString("  script:\n    - cpx ci build --toolchain \"$env:CPX_TOOLCHAIN\"\n")
+		} else {
+			b.WriteString("  script:\n    - cpx ci build --toolchain \"$CPX_TOOLCHAIN\"\n")
+		}
+	}
+	return b.String()
+}
+
+// azureVMImages map the OS of a job to a Microsoft-hosted agent image
+var azureVMImages = map[string]string{
+	"linux":   "ubuntu-latest",
+	"windows": "windows-latest",
+	"darwin":  "macos-latest",
+}
+
+var azureMatrixKey = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// azurePipeline returns an Azure Pipelines pipeline building each toolchain
+// in a matrix job
+func azurePipeline(jobs []exportJob, outputDir string) string {
+	var b strings.Builder
+	b.WriteString(exportHeader)
+	b.WriteString(`trigger:
+  branches:
+    include: [main, master]
+  tags:
+    include: ["v*"]
+
+pr:
+  branches:
+    include: ["*"]
+
+jobs:
+  - job: build
+    strategy:
+      matrix:
+`)
+	qemu := false
+	for _, job := range jobs {
+		fmt.Fprintf(&b, "        %s:\n", azureMatrixKey.ReplaceAllString(job.Toolchain, "_"))
+		fmt.Fprintf(&b, "          toolchain: %q\n", job.Toolchain)
+		fmt.Fprintf(&b, "          vmImage: %s\n", azureVMImages[job.OS])
+		qemu = qemu || job.QEMU
+	}
+	b.WriteString(`    pool:
+      vmImage: $(vmImage)
+    steps:
+      - checkout: self
+
+      - script: |
+          ` + installCPX + `
+          echo "##vso[task.prependpath]$HOME/.local/bin"
+        displayName: Install cpx
+        condition: ne(variables['Agent.OS'], 'Windows_NT')
+
+      - pwsh: |
+` + indent(installCPXWindows, 10) + `
+          Write-Host "##vso[task.prependpath]$env:USERPROFILE\bin"
+        displayName: Install cpx (Windows)
+        condition: eq(variables['Agent.OS'], 'Windows_NT')
+`)
+	if qemu {
+		b.WriteString(`
+      - script: docker run --privileged --rm tonistiigi/binfmt --install all
+        displayName: Set up QEMU
+        condition: eq(variables['Agent.OS'], 'Linux')
+`)
+	}
+	fmt.Fprintf(&b, `
+      - task: Cache@2
+        inputs:
+          key: 'cpx | "$(toolchain)" | cpx-ci.yaml'
+          restoreKeys: 'cpx | "$(toolchain)"'
+          path: .cache/ci/$(toolchain)
+        displayName: Cache build directory
+
+      - script: cpx ci build --toolchain $(toolchain)
+        displayName: Build
+
+      - publish: %s/$(toolchain)
+        artifact: $(toolchain)
+        displayName: Publish artifacts
+`, outputDir)
+	return b.String()
+}
+
+// indent prefixes each line of s with n spaces
+func indent(s string, n int) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// yamlQuote returns s as a single-quoted YAML scalar
+func yamlQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// toolchainHooks runs the hooks of one toolchain build
+type toolchainHooks struct {
+	hooks       *config.Hooks
+	tc          config.Toolchain
+	projectRoot string
+	outputDir   string
+	out         io.Writer
+	// container runs a command in the build container, nil for runners
+	// without one (see containerRunner)
+	container func(command string, env map[string]string) error
+}
+
+// newToolchainHooks returns the hooks of tc: the global ones, then its own
+func newToolchainHooks(tc config.Toolchain, options ToolchainBuildOptions, projectRoot, outputDir string, out io.Writer) *toolchainHooks {
+	return &toolchainHooks{
+		hooks:       config.MergeHooks(options.Hooks, tc.Hooks),
+		tc:          tc,
+		projectRoot: projectRoot,
+		outputDir:   outputDir,
+		out:         out,
+	}
+}
+
+// run runs the pre_build hooks, then build, then the post_build hooks. Once
+// any of them failed, the on_failure hooks run instead, with the error in
+// CPX_ERROR; their own failures are only reported.
+func (h *toolchainHooks) run(build func() error) error {
+	if h.hooks == nil {
+		return build()
+	}
+	err := h.runPhase("pre_build", h.hooks.PreBuild, nil)
+	if err == nil {
+		err = build()
+	}
+	if err == nil {
+		err = h.runPhase("post_build", h.hooks.PostBuild, nil)
+	}
+	if err != nil {
+		if hookErr := h.runPhase("on_failure", h.hooks.OnFailure, err); hookErr != nil {
+			fmt.Fprintf(h.out, "  %sWarning: %v%s\n", colors.Yellow, hookErr, colors.Reset)
+		}
+	}
+	return err
+}
+
+// runPhase runs the hooks of a phase in order, stopping at the first failure
+func (h *toolchainHooks) runPhase(phase string, hooks []config.Hook, buildErr error) error {
+	for _, hook := range hooks {
+		fmt.Fprintf(h.out, "  %s Running %s hook: %s%s\n", colors.Cyan, phase, hook.Run, colors.Reset)
+		var err error
+		if hook.InContainer() {
+			err = h.runInContainer(phase, hook, buildErr)
+		} else {
+			err = h.runOnHost(phase, hook, buildErr)
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook '%s' failed: %w", phase, hook.Run, err)
+		}
+	}
+	return nil
+}
+
+// runOnHost runs a hook with sh -c in the project root
+func (h *toolchainHooks) runOnHost(phase string, hook config.Hook, buildErr error) error {
+	outputDir, err := filepath.Abs(filepath.Join(h.outputDir, h.tc.Name))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for output directory: %w", err)
+	}
+	projectRoot, err := filepath.Abs(h.projectRoot)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+	cmd := exec.Command("sh", "-c", hook.Run)
+	cmd.Dir = projectRoot
+	cmd.Stdout, cmd.Stderr = h.out, h.out
+	cmd.Env = os.Environ()
+	for k, v := range hookEnv(h.tc, phase, projectRoot, outputDir, buildErr) {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	return cmd.Run()
+}
+
+// runInContainer runs a hook in the build container, where the project is
+// mounted read-only at /workspace and the artifacts are in /output/<name>
+func (h *toolchainHooks) runInContainer(phase string, hook config.Hook, buildErr error) error {
+	if h.container == nil {
+		return fmt.Errorf("toolchain '%s' does not build in Docker", h.tc.Name)
+	}
+	return h.container(hook.Run, hookEnv(h.tc, phase, "/workspace", "/output/"+h.tc.Name, buildErr))
+}
+
+// hookEnv returns the variables describing a build to its hooks
+func hookEnv(tc config.Toolchain, phase, projectRoot, outputDir string, buildErr error) map[string]string {
+	env := map[string]string{
+		"CPX_HOOK":         phase,
+		"CPX_TOOLCHAIN":    tc.Name,
+		"CPX_BUILD_TYPE":   tc.BuildType,
+		"CPX_PROJECT_ROOT": projectRoot,
+		"CPX_OUTPUT_DIR":   outputDir,
+	}
+	if buildErr != nil {
+		env["CPX_ERROR"] = buildErr.Error()
+	}
+	return env
+}
+
+// fprintHooks prints the hooks of a phase for a dry run
+func fprintHooks(w io.Writer, phase string, hooks []config.Hook) {
+	for _, hook := range hooks {
+		where := "host"
+		if hook.InContainer() {
+			where = "container"
+		}
+		fmt.Fprintf(w, "%s# %s hook (%s)%s\n", colors.Gray, phase, where, colors.Reset)
+		build.FprintCommand(w, []string{"sh", "-c", hook.Run})
+	}
+}
@@ -0,0 +1,383 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/cross"
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// runnerImage returns the image a Docker runner uses: its image as written,
+// or the content-hash tag of the image built from its Dockerfile or from its
+// target preset's.
+func runnerImage(projectRoot string, runner *config.Runner) (string, error) {
+	if _, err := runnerTarget(runner); err != nil {
+		return "", err
+	}
+	if target := presetDockerfile(runner); target != nil {
+		repo := target.Image()
+		if runner.Image != "" {
+			repo = builderRepository(runner)
+		}
+		var args map[string]string
+		if runner.Build != nil {
+			args = runner.Build.Args
+		}
+		return imageTag(repo, []byte(target.Dockerfile()), args, runnerPlatform(runner)), nil
+	}
+	if runner.Build != nil {
+		return builderImageTag(projectRoot, runner)
+	}
+	return runner.Image, nil
+}
+
+// runnerTarget returns a runner's cross-compilation preset, or nil if it sets none
+func runnerTarget(runner *config.Runner) (*cross.Target, error) {
+	if runner == nil || runner.Target == "" {
+		return nil, nil
+	}
+	target, err := cross.Lookup(runner.Target)
+	if err != nil {
+		return nil, fmt.Errorf("runner '%s': %w", runner.Name, err)
+	}
+	return &target, nil
+}
+
+// runnerBuildsImage reports whether a runner's image is built rather than
+// pulled: from its Dockerfile, or from its target preset's if it names no image
+func runnerBuildsImage(runner *config.Runner) bool {
+	return runner.Build != nil || presetDockerfile(runner) != nil
+}
+
+// presetDockerfile returns the target preset whose Dockerfile builds a
+// runner's image: a target runner with neither an image nor a Dockerfile of
+// its own. Its build section, if any, supplies build args.
+func presetDockerfile(runner *config.Runner) *cross.Target {
+	target, _ := runnerTarget(runner)
+	if target == nil {
+		return nil
+	}
+	if runner.Build != nil && runner.Build.Dockerfile == "" {
+		return target
+	}
+	if runner.Build == nil && runner.Image == "" {
+		return target
+	}
+	return nil
+}
+
+// sortedBuildArgs returns a build-mode runner's --build-arg values as KEY=value, sorted by key
+func sortedBuildArgs(runner *config.Runner) []string {
+	if runner.Build == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(runner.Build.Args))
+	for k := range runner.Build.Args {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	args := make([]string, 0, len(keys))
+	for _, k := range keys {
+		args = append(args, k+"="+runner.Build.Args[k])
+	}
+	return args
+}
+
+// runnerPlatform returns the Docker platform of a runner, falling back to its target preset's
+func runnerPlatform(runner *config.Runner) string {
+	if runner.Platform != "" {
+		return runner.Platform
+	}
+	if target, _ := runnerTarget(runner); target != nil {
+		return target.Platform
+	}
+	return ""
+}
+
+// runnerCompilers returns the C and C++ compilers of a runner, falling back to its target preset's
+func runnerCompilers(runner *config.Runner) (cc, cxx string) {
+	cc, cxx = runner.CC, runner.CXX
+	if target, _ := runnerTarget(runner); target != nil {
+		if cc == "" {
+			cc = target.CC
+		}
+		if cxx == "" {
+			cxx = target.CXX
+		}
+	}
+	return cc, cxx
+}
+
+// runnerDockerfile returns the Dockerfile and build context paths of a build-mode runner
+func runnerDockerfile(projectRoot string, runner *config.Runner) (dockerfile, buildContext string) {
+	dockerfile = runner.Build.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	buildContext = runner.Build.Context
+	if buildContext == "" {
+		buildContext = "."
+	}
+	return filepath.Join(projectRoot, dockerfile), filepath.Join(projectRoot, buildContext)
+}
+
+// builderImageTag returns <repository>:<hash> for a build-mode runner. The hash
+// covers the Dockerfile, build args and platform, so editing any of them
+// produces a new tag and the image is rebuilt.
+func builderImageTag(projectRoot string, runner *config.Runner) (string, error) {
+	dockerfile, _ := runnerDockerfile(projectRoot, runner)
+	content, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Dockerfile for runner '%s': %w", runner.Name, err)
+	}
+
+	return imageTag(builderRepository(runner), content, runner.Build.Args, runnerPlatform(runner)), nil
+}
+
+// imageTag returns <repository>:<hash of the Dockerfile, build args and platform>
+func imageTag(repository string, dockerfile []byte, args map[string]string, platform string) string {
+	h := sha256.New()
+	h.Write(dockerfile)
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "\narg %s=%s", k, args[k])
+	}
+	fmt.Fprintf(h, "\nplatform %s", platform)
+	return fmt.Sprintf("%s:%x", repository, h.Sum(nil)[:6])
+}
+
+// projectLabel labels the runner images cpx builds with the project that
+// built them, so pruning the images of one project leaves other projects'
+// images in a shared repository alone
+const projectLabel = "dev.cpx.project"
+
+// projectID returns the value of projectLabel for a project: a hash of its root
+func projectID(projectRoot string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(projectRoot)))[:12]
+}
+
+// builderRepository returns the repository built images are tagged into:
+// the runner's image without its tag, or cpx-<runner name>
+func builderRepository(runner *config.Runner) string {
+	if runner.Image == "" {
+		return "cpx-" + strings.ToLower(runner.Name)
+	}
+	repo, _ := splitImageRef(runner.Image)
+	return repo
+}
+
+// runnerPushesImage reports whether a runner shares its built image through its repository
+func runnerPushesImage(runner *config.Runner) bool {
+	return runner.Build != nil && runner.Build.Push
+}
+
+// pullOrBuildRunnerImage provides a runner's missing image on endpoint. A
+// pushing runner first pulls the tag from its repository, where another
+// machine may have pushed it, and pushes the image it had to build.
+func pullOrBuildRunnerImage(endpoint docker.Endpoint, projectRoot string, runner *config.Runner, tag string, verbose bool) error {
+	if runnerPushesImage(runner) {
+		if runner.Image == "" {
+			return fmt.Errorf("runner '%s' sets build.push but no image repository to push to", runner.Name)
+		}
+		fmt.Printf("  %s Pulling Docker image: %s%s\n", colors.Yellow, tag, colors.Reset)
+		args := []string{"pull", "--quiet"}
+		if platform := runnerPlatform(runner); platform != "" {
+			args = append(args, "--platform", platform)
+		}
+		if err := endpoint.Command(append(args, tag)...).Run(); err == nil {
+			return nil
+		}
+	}
+
+	fmt.Printf("  %s Building Docker image: %s (%s)%s\n", colors.Yellow, tag, docker.DescribePlatform(runnerPlatform(runner)), colors.Reset)
+	fmt.Printf("  %shint: run 'cpx ci bake' to build all runner images concurrently%s\n", colors.Gray, colors.Reset)
+	if err := buildRunnerImage(endpoint, projectRoot, runner, tag, verbose); err != nil {
+		return err
+	}
+	if !runnerPushesImage(runner) {
+		return nil
+	}
+
+	fmt.Printf("  %s Pushing Docker image: %s%s\n", colors.Yellow, tag, colors.Reset)
+	cmd := endpoint.Command("push", "--quiet", tag)
+	if verbose {
+		cmd = endpoint.Command("push", tag)
+		cmd.Stdout = os.Stdout
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker push failed: %w", err)
+	}
+	return nil
+}
+
+// buildRunnerImage builds a runner's image on endpoint and tags it.
+func buildRunnerImage(endpoint docker.Endpoint, projectRoot string, runner *config.Runner, tag string, verbose bool) error {
+	args, stdin, err := runnerImageBuildArgs(projectRoot, runner, tag, verbose)
+	if err != nil {
+		return err
+	}
+
+	cmd := endpoint.Command(args...)
+	cmd.Stdin = stdin
+	if verbose {
+		cmd.Stdout = os.Stdout
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker build failed: %w", err)
+	}
+	return nil
+}
+
+// runnerImageBuildArgs returns the docker build arguments for a runner's
+// image. Target presets have no build context; their Dockerfile is returned
+// for stdin.
+func runnerImageBuildArgs(projectRoot string, runner *config.Runner, tag string, verbose bool) ([]string, io.Reader, error) {
+	args := []string{"build", "-t", tag, "--label", projectLabel + "=" + projectID(projectRoot)}
+	if platform := runnerPlatform(runner); platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	if !verbose {
+		args = append(args, "--quiet")
+	}
+	// RUN steps reach package mirrors the same way as the build containers
+	if runner.Network != "" {
+		args = append(args, "--network", runner.Network)
+	}
+	for _, host := range runner.ExtraHosts {
+		args = append(args, "--add-host", host)
+	}
+
+	for _, arg := range sortedBuildArgs(runner) {
+		args = append(args, "--build-arg", arg)
+	}
+	if runner.Build != nil {
+		for _, from := range runner.Build.CacheFrom {
+			args = append(args, "--cache-from", from)
+		}
+		for _, to := range runner.Build.CacheTo {
+			args = append(args, "--cache-to", to)
+		}
+		// Exporting a cache needs a buildx builder that does not load images by itself
+		if len(runner.Build.CacheTo) > 0 {
+			args = append(args, "--load")
+		}
+	}
+
+	if target := presetDockerfile(runner); target != nil {
+		return append(args, "-"), strings.NewReader(target.Dockerfile()), nil
+	}
+	if runner.Build != nil {
+		dockerfile, buildContext := runnerDockerfile(projectRoot, runner)
+		return append(args, "-f", docker.HostPath(dockerfile), docker.HostPath(buildContext)), nil, nil
+	}
+	return nil, nil, fmt.Errorf("runner '%s' has no Dockerfile to build", runner.Name)
+}
+
+var bakeNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// generateBakeFile renders a docker-bake.hcl with one target per runner whose
+// image is built. Targets share a local layer cache under cacheDir, relative to the
+// project root, so `docker buildx bake` builds them concurrently.
+func generateBakeFile(projectRoot, cacheDir string, runners []config.Runner) (string, error) {
+	relCache, err := filepath.Rel(projectRoot, filepath.Join(cacheDir, "buildx"))
+	if err != nil || strings.HasPrefix(relCache, "..") {
+		relCache = filepath.Join(cacheDir, "buildx")
+	}
+	relCache = filepath.ToSlash(relCache)
+
+	var names []string
+	runnerOf := map[string]string{}
+	var targets strings.Builder
+	for i := range runners {
+		runner := &runners[i]
+		if !runner.IsDocker() || !runnerBuildsImage(runner) {
+			continue
+		}
+		tag, err := runnerImage(projectRoot, runner)
+		if err != nil {
+			return "", err
+		}
+
+		name := bakeNameInvalid.ReplaceAllString(runner.Name, "-")
+		if other, ok := runnerOf[name]; ok {
+			return "", fmt.Errorf("runners '%s' and '%s' both become bake target '%s'; rename one of them", other, runner.Name, name)
+		}
+		runnerOf[name] = runner.Name
+		names = append(names, fmt.Sprintf("%q", name))
+
+		fmt.Fprintf(&targets, "\ntarget %q {\n", name)
+		if target := presetDockerfile(runner); target != nil {
+			// HCL interpolates ${...} and %{...} even in heredocs
+			inline := strings.NewReplacer("${", "$${", "%{", "%%{").Replace(target.Dockerfile())
+			fmt.Fprintf(&targets, "  dockerfile-inline = <<EOT\n%sEOT\n", inline)
+		} else {
+			dockerfile, buildContext := runnerDockerfile(projectRoot, runner)
+			relDockerfile, _ := filepath.Rel(projectRoot, dockerfile)
+			relContext, _ := filepath.Rel(projectRoot, buildContext)
+			fmt.Fprintf(&targets, "  context    = %q\n", filepath.ToSlash(relContext))
+			fmt.Fprintf(&targets, "  dockerfile = %q\n", filepath.ToSlash(relDockerfile))
+		}
+		fmt.Fprintf(&targets, "  tags       = [%q]\n", tag)
+		fmt.Fprintf(&targets, "  labels     = { %q = %q }\n", projectLabel, projectID(projectRoot))
+		if platform := runnerPlatform(runner); platform != "" {
+			fmt.Fprintf(&targets, "  platforms  = [%q]\n", platform)
+		}
+		if runner.Network != "" {
+			fmt.Fprintf(&targets, "  network    = %q\n", runner.Network)
+		}
+		if buildArgs := sortedBuildArgs(runner); len(buildArgs) > 0 {
+			targets.WriteString("  args = {\n")
+			for _, arg := range buildArgs {
+				k, v, _ := strings.Cut(arg, "=")
+				fmt.Fprintf(&targets, "    %s = %q\n", k, v)
+			}
+			targets.WriteString("  }\n")
+		}
+		cacheFrom := []string{"type=local,src=" + relCache}
+		cacheTo := []string{"type=local,dest=" + relCache + ",mode=max"}
+		if runner.Build != nil {
+			cacheFrom = append(cacheFrom, runner.Build.CacheFrom...)
+			if len(runner.Build.CacheTo) > 0 {
+				cacheTo = runner.Build.CacheTo
+			}
+		}
+		fmt.Fprintf(&targets, "  cache-from = [%s]\n", quoteList(cacheFrom))
+		fmt.Fprintf(&targets, "  cache-to   = [%s]\n", quoteList(cacheTo))
+		targets.WriteString("}\n")
+	}
+
+	if len(names) == 0 {
+		return "", fmt.Errorf("no Docker runners with a 'build' section or target preset in cpx-ci.yaml")
+	}
+
+	var out strings.Builder
+	out.WriteString("# Generated by cpx ci bake from cpx-ci.yaml; do not edit.\n")
+	out.WriteString("# Build all runner images: docker buildx bake --load\n\n")
+	fmt.Fprintf(&out, "group \"default\" {\n  targets = [%s]\n}\n", strings.Join(names, ", "))
+	out.WriteString(targets.String())
+	return out.String(), nil
+}
+
+// quoteList renders values as a comma-separated list of quoted HCL strings
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
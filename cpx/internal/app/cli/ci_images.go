@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// imageHashTag matches the content-hash tags imageTag gives built runner images
+var imageHashTag = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+// imageRepository is a repository cpx builds runner images into, on one engine
+type imageRepository struct {
+	Endpoint docker.Endpoint
+	Name     string
+	Project  string   // projectID of the project, whose label its images have
+	Current  []string // tags the current Dockerfiles, build args and platforms hash to
+	Runners  []string
+}
+
+// managedImage is a content-hash tagged runner image cpx built
+type managedImage struct {
+	Ref     string
+	ID      string
+	Size    string
+	Created string
+	Current bool // still the tag of a runner's current Dockerfile
+}
+
+func runCIImages(_ *cobra.Command, _ []string) error {
+	ciConfig, projectRoot, err := loadImagesConfig()
+	if err != nil {
+		return err
+	}
+	repos, err := imageRepositories(ciConfig, projectRoot)
+	if err != nil {
+		return err
+	}
+	if len(repos) == 0 {
+		fmt.Printf("%sNo runner in cpx-ci.yaml builds its image%s\n", colors.Yellow, colors.Reset)
+		return nil
+	}
+
+	stale := 0
+	for _, repo := range repos {
+		images, err := listManagedImages(repo)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n%s%s%s (runners: %s)\n", colors.Bold, repo.Name, colors.Reset, strings.Join(repo.Runners, ", "))
+		if len(images) == 0 {
+			fmt.Println("  no images built yet")
+			continue
+		}
+		fmt.Printf("  %-12s  %-12s  %-10s  %-16s  %s\n", "TAG", "IMAGE ID", "SIZE", "CREATED", "STATUS")
+		for _, image := range images {
+			_, tag := splitImageRef(image.Ref)
+			status := colors.Green + "current" + colors.Reset
+			if !image.Current {
+				status = colors.Yellow + "stale" + colors.Reset
+				stale++
+			}
+			fmt.Printf("  %-12s  %-12s  %-10s  %-16s  %s\n", tag, image.ID, image.Size, image.Created, status)
+		}
+	}
+	if stale > 0 {
+		fmt.Printf("\n%d stale image(s); remove them with: cpx ci images prune\n", stale)
+	}
+	return nil
+}
+
+func runCIImagesPrune(cmd *cobra.Command, _ []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	ciConfig, projectRoot, err := loadImagesConfig()
+	if err != nil {
+		return err
+	}
+
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+	removed, err := pruneStaleImages(ciConfig, projectRoot, verb, dryRun)
+	if err != nil {
+		return err
+	}
+	switch {
+	case removed == 0:
+		fmt.Printf("%s No stale images%s\n", colors.Green, colors.Reset)
+	case !dryRun:
+		fmt.Printf("%s Removed %d stale image(s)%s\n", colors.Green, removed, colors.Reset)
+	}
+	return nil
+}
+
+func loadImagesConfig() (*config.ToolchainConfig, string, error) {
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get project root: %w", err)
+	}
+	return ciConfig, projectRoot, nil
+}
+
+// pruneStaleImages removes the images of the runners' repositories whose tag no
+// current Dockerfile hashes to, printing each with verb, and returns how many
+// there were. Nothing is removed with dryRun.
+func pruneStaleImages(ciConfig *config.ToolchainConfig, projectRoot, verb string, dryRun bool) (int, error) {
+	repos, err := imageRepositories(ciConfig, projectRoot)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, repo := range repos {
+		images, err := listManagedImages(repo)
+		if err != nil {
+			return removed, err
+		}
+		for _, image := range images {
+			if image.Current {
+				continue
+			}
+			if !dryRun {
+				if err := removeImage(repo.Endpoint, image.Ref); err != nil {
+					return removed, err
+				}
+			}
+			removed++
+			fmt.Printf("  %s image %s (%s)\n", verb, image.Ref, image.Size)
+		}
+	}
+	return removed, nil
+}
+
+// imageRepositories returns the repositories of the runners that build their
+// image, one per repository and engine
+func imageRepositories(ciConfig *config.ToolchainConfig, projectRoot string) ([]*imageRepository, error) {
+	var repos []*imageRepository
+	for i := range ciConfig.Runners {
+		runner := &ciConfig.Runners[i]
+		if !runner.IsDocker() || !runnerBuildsImage(runner) {
+			continue
+		}
+		endpoint, err := runnerEndpoint(runner)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Docker endpoint for runner '%s': %w", runner.Name, err)
+		}
+		image, err := runnerImage(projectRoot, runner)
+		if err != nil {
+			return nil, err
+		}
+		name, tag := splitImageRef(image)
+
+		idx := slices.IndexFunc(repos, func(r *imageRepository) bool { return r.Name == name && r.Endpoint == endpoint })
+		if idx < 0 {
+			repos = append(repos, &imageRepository{Endpoint: endpoint, Name: name, Project: projectID(projectRoot)})
+			idx = len(repos) - 1
+		}
+		repos[idx].Current = append(repos[idx].Current, tag)
+		repos[idx].Runners = append(repos[idx].Runners, runner.Name)
+	}
+	return repos, nil
+}
+
+// splitImageRef splits an image reference into its repository and tag. A
+// registry port (host:5000/name) stays in the repository.
+func splitImageRef(ref string) (string, string) {
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// listManagedImages returns the content-hash tagged images of repo on its
+// engine that the project built
+func listManagedImages(repo *imageRepository) ([]managedImage, error) {
+	filter := "label=" + projectLabel + "=" + repo.Project
+	out, err := repo.Endpoint.Command("images", "--filter", filter, "--format", "{{.Tag}}\t{{.ID}}\t{{.Size}}\t{{.CreatedSince}}", repo.Name).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images of %s: %w", repo.Name, err)
+	}
+	var images []managedImage
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 || !imageHashTag.MatchString(fields[0]) {
+			continue
+		}
+		images = append(images, managedImage{
+			Ref:     repo.Name + ":" + fields[0],
+			ID:      fields[1],
+			Size:    fields[2],
+			Created: fields[3],
+			Current: slices.Contains(repo.Current, fields[0]),
+		})
+	}
+	return images, nil
+}
+
+// removeImage removes an image from an engine
+func removeImage(endpoint docker.Endpoint, ref string) error {
+	if out, err := endpoint.Command("rmi", ref).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove %s: %s", ref, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+)
+
+// buildLogsDir holds the complete output of each Docker and SSH toolchain
+// build, relative to the project root
+var buildLogsDir = filepath.Join(".cpx", "logs")
+
+// keptBuildLogs is the number of logs kept per toolchain; older ones are removed
+const keptBuildLogs = 10
+
+// buildLogTimeFormat stamps log file names so they sort chronologically
+const buildLogTimeFormat = "20060102-150405"
+
+// ansiEscape matches the terminal color codes stripped from logs
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+// buildLog is the log file of one toolchain build. Writes are serialized, as
+// a container's stdout and stderr are copied concurrently, and stripped of
+// color codes. A log that cannot be written never fails the build.
+type buildLog struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// openBuildLog creates the log of a toolchain build started at start, as
+// .cpx/logs/<toolchain>-<timestamp>.log under projectRoot, and removes the
+// toolchain's logs beyond the newest keptBuildLogs.
+func openBuildLog(projectRoot, name string, start time.Time) (*buildLog, error) {
+	dir := filepath.Join(projectRoot, buildLogsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create logs directory: %w", err)
+	}
+	path := filepath.Join(dir, name+"-"+start.Format(buildLogTimeFormat)+".log")
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create build log: %w", err)
+	}
+	pruneBuildLogs(dir, name)
+	return &buildLog{file: file, path: path}, nil
+}
+
+func (l *buildLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.file.Write(ansiEscape.ReplaceAll(p, nil))
+	return len(p), nil
+}
+
+// Close closes the log file. A nil log has nothing to close.
+func (l *buildLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// pruneBuildLogs removes the oldest logs of a toolchain in dir, keeping keptBuildLogs
+func pruneBuildLogs(dir, name string) {
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `-\d{8}-\d{6}\.log$`)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var logs []string
+	for _, e := range entries {
+		if !e.IsDir() && pattern.MatchString(e.Name()) {
+			logs = append(logs, e.Name())
+		}
+	}
+	// ReadDir sorts by name, which is chronological for one toolchain
+	for len(logs) > keptBuildLogs {
+		_ = os.Remove(filepath.Join(dir, logs[0]))
+		logs = logs[1:]
+	}
+}
+
+// startBuildLog opens the log of the build recorded in result and stores its
+// path there. Without a log, which is only warned about on w, the build runs
+// as before and nil is returned.
+func startBuildLog(result *toolchainResult, projectRoot string, w io.Writer) *buildLog {
+	log, err := openBuildLog(projectRoot, result.Name, time.Now())
+	if err != nil {
+		fmt.Fprintf(w, "  %sWarning: %v%s\n", colors.Yellow, err, colors.Reset)
+		return nil
+	}
+	fmt.Fprintf(log, "cpx build log of '%s', started %s\n\n", result.Name, time.Now().Format(time.RFC3339))
+	result.Log = log.path
+	return log
+}
+
+// logWriter returns log as a writer, or nil without one
+func logWriter(log *buildLog) io.Writer {
+	if log == nil {
+		return nil
+	}
+	return log
+}
@@ -0,0 +1,409 @@
+package cli
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// toolchainResult is the outcome of one toolchain build
+type toolchainResult struct {
+	Name      string
+	Runner    string
+	Image     string // Docker image the toolchain built in
+	Duration  time.Duration
+	Phases    []build.Phase // timed steps of the build, if it recorded them
+	Cache     string        // artifact cache use: cacheHit, cacheMiss or "" when not used
+	Artifacts []string      // files in the toolchain's output directory after a successful build
+	Log       string        // file holding the build's complete output, if it has one
+	Err       error
+	Skipped   bool // not built because an earlier toolchain or a dependency failed
+	Resumed   bool // not built because an interrupted build completed it (--resume)
+}
+
+// Artifact cache uses of a toolchain build
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+// prefixWriter prefixes every complete line with a toolchain label so the
+// output of concurrent builds stays readable. Writers sharing mu never
+// interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing line that has no newline yet
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(w.out, "%s%s", w.prefix, bytes.TrimRight(line, "\r"))
+	fmt.Fprintln(w.out)
+}
+
+// parallelJob is a toolchain build that is ready to run concurrently
+type parallelJob struct {
+	result    toolchainResult // Name, Runner and Image; the build fills in the rest
+	output    *prefixWriter
+	dependsOn []string
+	run       func(result *toolchainResult) error
+}
+
+// toolchainDone is closed when a toolchain of a parallel build has finished,
+// after ok is set, so the jobs depending on it can start or be skipped
+type toolchainDone struct {
+	ch chan struct{}
+	ok bool
+}
+
+// runParallelToolchainBuild builds Docker, SSH and plugin toolchains
+// concurrently, up to parallel at a time. Runners are prepared (e.g. images
+// resolved) and native toolchains built first, one at a time, since both use
+// the host directly; native toolchains with dependencies run as jobs, still
+// one at a time. A job starts once the toolchains it depends on succeeded and
+// is skipped if one failed. Every other toolchain runs even if another fails;
+// a summary is printed at the end.
+func runParallelToolchainBuild(ciConfig *config.ToolchainConfig, toolchains []config.Toolchain, projectRoot, cacheDir, outputDir, version string, options ToolchainBuildOptions, parallel int) ([]toolchainResult, error) {
+	width := 0
+	for _, tc := range toolchains {
+		width = max(width, len(tc.Name))
+	}
+
+	var results []toolchainResult
+	var jobs []parallelJob
+	var mu, nativeMu sync.Mutex
+	checkedMounts := false
+	done := make(map[string]*toolchainDone, len(toolchains))
+	for _, tc := range toolchains {
+		done[tc.Name] = &toolchainDone{ch: make(chan struct{})}
+	}
+
+	for _, tc := range toolchains {
+		if options.state.done(tc.Name, outputDir) {
+			fmt.Printf("\n%sAlready built: %s%s\n", colors.Green, tc.Name, colors.Reset)
+			results = append(results, resumedResult(tc, outputDir))
+			continue
+		}
+		runner := ciConfig.FindRunner(tc.Runner)
+		if runner == nil && tc.Runner != "" {
+			return nil, fmt.Errorf("runner '%s' not found for toolchain '%s'", tc.Runner, tc.Name)
+		}
+
+		r, err := newRunner(runner)
+		if err != nil {
+			results = append(results, toolchainResult{Name: tc.Name, Runner: tc.Runner, Err: err})
+			continue
+		}
+		b := &runnerBuild{tc: tc, runner: runner, projectRoot: projectRoot, cacheDir: cacheDir, outputDir: outputDir, options: options, checkedMounts: &checkedMounts}
+		native := runner == nil || runner.IsNative()
+
+		switch {
+		case native && len(tc.DependsOn) > 0:
+			jobs = append(jobs, parallelJob{result: toolchainResult{Name: tc.Name, Runner: tc.Runner}, output: newJobOutput(&mu, tc.Name, width), dependsOn: tc.DependsOn, run: func(result *toolchainResult) error {
+				nativeMu.Lock()
+				defer nativeMu.Unlock()
+				b.result = result
+				if err := r.Prepare(b); err != nil {
+					return err
+				}
+				return buildOnRunner(r, b)
+			}})
+		case native:
+			fmt.Printf("\n%sBuilding: %s (native)%s\n", colors.Cyan, tc.Name, colors.Reset)
+			start := time.Now()
+			result := toolchainResult{Name: tc.Name, Runner: tc.Runner}
+			b.result = &result
+			err := r.Prepare(b)
+			if err == nil {
+				err = buildOnRunner(r, b)
+			}
+			result.Duration, result.Err = time.Since(start), err
+			results = append(results, result)
+		default:
+			fmt.Printf("\n%sPreparing: %s (%s)%s\n", colors.Cyan, tc.Name, describeRunner(runner), colors.Reset)
+			output := newJobOutput(&mu, tc.Name, width)
+			prepared := toolchainResult{Name: tc.Name, Runner: tc.Runner}
+			b.result, b.output = &prepared, output
+			if err := r.Prepare(b); err != nil {
+				results = append(results, toolchainResult{Name: tc.Name, Runner: tc.Runner, Err: err})
+				continue
+			}
+			jobs = append(jobs, parallelJob{result: prepared, output: output, dependsOn: tc.DependsOn, run: func(result *toolchainResult) error {
+				b.result = result
+				return buildOnRunner(r, b)
+			}})
+		}
+	}
+
+	if len(jobs) > 0 {
+		fmt.Printf("\n%s Building %d toolchain(s), %d at a time...%s\n", colors.Cyan, len(jobs), min(parallel, len(jobs)), colors.Reset)
+	}
+
+	// The toolchains that failed to prepare or were built natively are finished
+	for _, r := range results {
+		done[r.Name].ok = r.Err == nil
+		close(done[r.Name].ch)
+	}
+
+	jobResults := make([]toolchainResult, len(jobs))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			finished := done[job.result.Name]
+			defer close(finished.ch)
+			jobResults[i] = job.result
+			for _, dep := range job.dependsOn {
+				if d := done[dep]; d != nil {
+					if <-d.ch; !d.ok {
+						fmt.Fprintf(job.output, "%sSkipped: '%s' failed%s\n", colors.Yellow, dep, colors.Reset)
+						job.output.Flush()
+						jobResults[i].Skipped = true
+						return
+					}
+				}
+			}
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			err := job.run(&job.result)
+			job.output.Flush()
+			job.result.Duration = time.Since(start)
+			if err == nil {
+				err = finishToolchain(ciConfig, findToolchain(toolchains, job.result.Name), job.result, projectRoot, cacheDir, outputDir, version)
+			}
+			if job.result.Err = err; err == nil {
+				job.result.Artifacts = listArtifacts(filepath.Join(outputDir, job.result.Name))
+				options.state.complete(job.result.Name)
+			}
+			jobResults[i] = job.result
+			finished.ok = err == nil
+		}()
+	}
+	wg.Wait()
+	// The native toolchains built before the jobs are finished here
+	for i := range results {
+		if results[i].Resumed || results[i].Err != nil {
+			continue
+		}
+		results[i].Err = finishToolchain(ciConfig, findToolchain(toolchains, results[i].Name), results[i], projectRoot, cacheDir, outputDir, version)
+		if results[i].Err == nil {
+			results[i].Artifacts = listArtifacts(filepath.Join(outputDir, results[i].Name))
+			options.state.complete(results[i].Name)
+		}
+	}
+	results = append(results, jobResults...)
+
+	failed := printToolchainSummary(results)
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d toolchain(s) failed", failed, len(results))
+	}
+	fmt.Printf("   Artifacts are in: %s\n", outputDir)
+	if err := finishArtifacts(ciConfig, outputDir, toolchains, version, options.Upload); err != nil {
+		return results, err
+	}
+	options.state.clear()
+	return results, nil
+}
+
+// findToolchain returns the toolchain of a build named name
+func findToolchain(toolchains []config.Toolchain, name string) config.Toolchain {
+	return toolchains[slices.IndexFunc(toolchains, func(t config.Toolchain) bool { return t.Name == name })]
+}
+
+// newJobOutput returns a writer prefixing lines with the toolchain name padded to width
+func newJobOutput(mu *sync.Mutex, name string, width int) *prefixWriter {
+	return &prefixWriter{
+		mu:     mu,
+		out:    os.Stdout,
+		prefix: fmt.Sprintf("%s%-*s │%s ", colors.Cyan, width, name, colors.Reset),
+	}
+}
+
+// summaryPhases are the build phases shown in the summary, in build order
+var summaryPhases = []string{"configure", "build", "test", "bench", "copy"}
+
+// printToolchainSummary prints a table with the status, wall time, phase
+// times, artifact cache use and artifacts of every toolchain, followed by
+// the errors, and returns the number of failures
+func printToolchainSummary(results []toolchainResult) int {
+	// Only the phases and cache column that some toolchain has are shown
+	var phases []string
+	showCache := false
+	for _, name := range summaryPhases {
+		for _, r := range results {
+			if slices.ContainsFunc(r.Phases, func(p build.Phase) bool { return p.Name == name }) {
+				phases = append(phases, name)
+				break
+			}
+		}
+	}
+	for _, r := range results {
+		showCache = showCache || r.Cache != ""
+	}
+
+	header := append([]string{"TOOLCHAIN", "STATUS", "TIME"}, upper(phases)...)
+	if showCache {
+		header = append(header, "CACHE")
+	}
+	header = append(header, "ARTIFACTS")
+
+	rows := [][]string{header}
+	failed := 0
+	for _, r := range results {
+		status := "success"
+		switch {
+		case r.Skipped:
+			status = "skipped"
+		case r.Resumed:
+			status = "resumed"
+		case r.Err != nil:
+			status = "failed"
+			failed++
+		}
+		row := []string{r.Name, status, formatDuration(r.Duration)}
+		for _, name := range phases {
+			cell := "-"
+			for _, p := range r.Phases {
+				if p.Name == name {
+					cell = formatDuration(p.Duration)
+				}
+			}
+			row = append(row, cell)
+		}
+		if showCache {
+			row = append(row, cmp.Or(r.Cache, "-"))
+		}
+		artifacts := "-"
+		if status == "success" || status == "resumed" {
+			artifacts = fmt.Sprintf("%d (%s)", len(r.Artifacts), formatSize(artifactsSize(r.Artifacts)))
+		}
+		rows = append(rows, append(row, artifacts))
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	fmt.Printf("\n%sSummary%s\n", colors.Bold, colors.Reset)
+	for i, row := range rows {
+		mark, color := " ", colors.Gray
+		if i > 0 {
+			switch row[1] {
+			case "success", "resumed":
+				mark, color = "✓", colors.Green
+			case "failed":
+				mark, color = "✗", colors.Red
+			default:
+				mark = "-"
+			}
+		}
+		var line strings.Builder
+		fmt.Fprintf(&line, "  %s%s %-*s%s", color, mark, widths[0], row[0], colors.Reset)
+		for j, cell := range row[1:] {
+			fmt.Fprintf(&line, "  %-*s", widths[j+1], cell)
+		}
+		fmt.Println(strings.TrimRight(line.String(), " "))
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("  %s✗ %s: %s%s\n", colors.Red, r.Name, firstLine(r.Err.Error()), colors.Reset)
+			if r.Log != "" {
+				fmt.Printf("    full log: %s\n", r.Log)
+			}
+		}
+	}
+	return failed
+}
+
+// upper returns names in upper case
+func upper(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = strings.ToUpper(n)
+	}
+	return out
+}
+
+// formatDuration rounds d for the summary, or returns "-" for none
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// artifactsSize returns the total size of the files at paths
+func artifactsSize(paths []string) int64 {
+	var total int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// formatSize renders a byte count with a binary unit, e.g. "12.4 MB"
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
+
+// firstLine returns s up to its first newline
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/git"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// provenanceFile is the SLSA provenance statement in a toolchain's output directory
+const provenanceFile = "provenance.intoto.json"
+
+// provenanceBuildType identifies cpx ci builds in SLSA provenance
+const provenanceBuildType = "https://github.com/ozacod/cpx/ci-build/v1"
+
+// inTotoStatement is an in-toto v1 statement about the artifacts of a build
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     slsaProvenance  `json:"predicate"`
+}
+
+// inTotoSubject is an artifact a statement is about
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// slsaProvenance is a SLSA v1 provenance predicate
+type slsaProvenance struct {
+	BuildDefinition slsaBuildDefinition `json:"buildDefinition"`
+	RunDetails      slsaRunDetails      `json:"runDetails"`
+}
+
+type slsaBuildDefinition struct {
+	BuildType            string                   `json:"buildType"`
+	ExternalParameters   map[string]any           `json:"externalParameters"`
+	InternalParameters   map[string]any           `json:"internalParameters,omitempty"`
+	ResolvedDependencies []slsaResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+type slsaResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type slsaRunDetails struct {
+	Builder  slsaBuilder  `json:"builder"`
+	Metadata slsaMetadata `json:"metadata"`
+}
+
+type slsaBuilder struct {
+	ID string `json:"id"`
+}
+
+type slsaMetadata struct {
+	StartedOn  string `json:"startedOn,omitempty"`
+	FinishedOn string `json:"finishedOn,omitempty"`
+}
+
+// collectProvenance describes how the artifacts in a toolchain's output
+// directory were built: the toolchain's build flags, the git commit of the
+// sources, the build image and the Dockerfile it was built from
+func collectProvenance(ciConfig *config.ToolchainConfig, tc config.Toolchain, result toolchainResult, projectRoot, dir string) inTotoStatement {
+	finished := time.Now().UTC()
+	statement := inTotoStatement{
+		Type:          "https://in-toto.io/Statement/v1",
+		Subject:       []inTotoSubject{},
+		PredicateType: "https://slsa.dev/provenance/v1",
+		Predicate: slsaProvenance{
+			BuildDefinition: slsaBuildDefinition{
+				BuildType:          provenanceBuildType,
+				ExternalParameters: provenanceParameters(tc, ciConfig.FindRunner(tc.Runner)),
+				InternalParameters: map[string]any{"cpx_version": Version},
+			},
+			RunDetails: slsaRunDetails{
+				Builder: slsaBuilder{ID: "https://github.com/ozacod/cpx@v" + Version},
+				Metadata: slsaMetadata{
+					StartedOn:  finished.Add(-result.Duration).Format(time.RFC3339),
+					FinishedOn: finished.Format(time.RFC3339),
+				},
+			},
+		},
+	}
+	for _, a := range artifactDigests(dir) {
+		statement.Subject = append(statement.Subject, inTotoSubject{Name: a.Name, Digest: map[string]string{"sha256": a.SHA256}})
+	}
+
+	def := &statement.Predicate.BuildDefinition
+	if commit, dirty, err := git.HeadCommit(projectRoot); err == nil {
+		source := slsaResourceDescriptor{Name: "source", Digest: map[string]string{"gitCommit": commit}}
+		if remote := git.RemoteURL(projectRoot); remote != "" {
+			source.URI = "git+" + remote
+		}
+		def.ResolvedDependencies = append(def.ResolvedDependencies, source)
+		def.InternalParameters["source_dirty"] = dirty
+	}
+	if result.Image != "" {
+		image := slsaResourceDescriptor{Name: "image", URI: "docker-image://" + result.Image}
+		if runner := ciConfig.FindRunner(tc.Runner); runner != nil {
+			if endpoint, err := runnerEndpoint(runner); err == nil {
+				if algo, digest, ok := strings.Cut(endpoint.ImageID(result.Image), ":"); ok {
+					image.Digest = map[string]string{algo: digest}
+				}
+			}
+		}
+		def.ResolvedDependencies = append(def.ResolvedDependencies, image)
+	}
+	if dockerfile, ok := runnerDockerfileDescriptor(projectRoot, ciConfig.FindRunner(tc.Runner)); ok {
+		def.ResolvedDependencies = append(def.ResolvedDependencies, dockerfile)
+	}
+	return statement
+}
+
+// provenanceParameters returns the build flags of a toolchain. Only the names
+// of its environment variables are recorded, since their values may be secret.
+func provenanceParameters(tc config.Toolchain, runner *config.Runner) map[string]any {
+	params := map[string]any{
+		"toolchain":  tc.Name,
+		"build_type": tc.BuildType,
+	}
+	if tc.Optimization != "" {
+		params["optimization"] = tc.Optimization
+	}
+	if len(tc.CMakeOptions) > 0 {
+		params["cmake_options"] = tc.CMakeOptions
+	}
+	if len(tc.BuildOptions) > 0 {
+		params["build_options"] = tc.BuildOptions
+	}
+	if tc.Hardening {
+		params["hardening"] = true
+	}
+	if len(tc.Env) > 0 {
+		params["env"] = slices.Sorted(maps.Keys(tc.Env))
+	}
+	if runner != nil {
+		params["runner"] = runner.Name
+		if platform := runnerPlatform(runner); platform != "" {
+			params["platform"] = platform
+		}
+		if runner.Target != "" {
+			params["target"] = runner.Target
+		}
+		cc, cxx := runnerCompilers(runner)
+		if cc != "" {
+			params["cc"] = cc
+		}
+		if cxx != "" {
+			params["cxx"] = cxx
+		}
+		if args := sortedBuildArgs(runner); len(args) > 0 {
+			params["build_args"] = args
+		}
+	}
+	return params
+}
+
+// runnerDockerfileDescriptor returns the Dockerfile a runner's image is built
+// from with its SHA-256: the project's, or the target preset's
+func runnerDockerfileDescriptor(projectRoot string, runner *config.Runner) (slsaResourceDescriptor, bool) {
+	if runner == nil || !runner.IsDocker() {
+		return slsaResourceDescriptor{}, false
+	}
+	if target := presetDockerfile(runner); target != nil {
+		sum := sha256.Sum256([]byte(target.Dockerfile()))
+		return slsaResourceDescriptor{Name: "Dockerfile", URI: "cpx:target/" + runner.Target, Digest: map[string]string{"sha256": fmt.Sprintf("%x", sum)}}, true
+	}
+	if runner.Build == nil {
+		return slsaResourceDescriptor{}, false
+	}
+	dockerfile, _ := runnerDockerfile(projectRoot, runner)
+	sum, err := fileSHA256(dockerfile)
+	if err != nil {
+		return slsaResourceDescriptor{}, false
+	}
+	rel, err := filepath.Rel(projectRoot, dockerfile)
+	if err != nil {
+		rel = dockerfile
+	}
+	return slsaResourceDescriptor{Name: "Dockerfile", URI: "file:" + filepath.ToSlash(rel), Digest: map[string]string{"sha256": sum}}, true
+}
+
+// writeProvenance writes a provenance statement to a toolchain's output directory
+func writeProvenance(statement inTotoStatement, dir string) error {
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode provenance: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, provenanceFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write provenance: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// buildReport is the JSON result of `cpx ci build --json`
+type buildReport struct {
+	Success    bool              `json:"success"`
+	Error      string            `json:"error,omitempty"`
+	Toolchains []toolchainReport `json:"toolchains"`
+}
+
+// toolchainReport is the JSON result of one toolchain build
+type toolchainReport struct {
+	Name      string        `json:"name"`
+	Runner    string        `json:"runner,omitempty"`
+	Status    string        `json:"status"` // success, resumed, failed or skipped
+	Duration  float64       `json:"duration_seconds"`
+	Phases    []phaseReport `json:"phases,omitempty"`
+	Cache     string        `json:"cache,omitempty"` // artifact cache: hit or miss
+	Image     string        `json:"image,omitempty"`
+	Artifacts []string      `json:"artifacts"`
+	Log       string        `json:"log,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// phaseReport is the JSON duration of a build phase
+type phaseReport struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+// runToolchainBuildJSON builds like runToolchainBuild, but sends the progress
+// output to stderr and writes a JSON report of the results to stdout.
+func runToolchainBuildJSON(options ToolchainBuildOptions) error {
+	stdout := os.Stdout
+	results, err := buildToolchainsToStderr(options)
+	if writeErr := writeBuildReport(stdout, newBuildReport(results, err)); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// buildToolchainsToStderr runs buildToolchains with os.Stdout pointed at
+// stderr, keeping the progress builders and docker print off the report.
+func buildToolchainsToStderr(options ToolchainBuildOptions) ([]toolchainResult, error) {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+	return buildToolchains(options)
+}
+
+// newBuildReport converts the results of a build to its report
+func newBuildReport(results []toolchainResult, err error) buildReport {
+	report := buildReport{Success: err == nil, Toolchains: []toolchainReport{}}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	for _, r := range results {
+		tr := toolchainReport{
+			Name:      r.Name,
+			Runner:    r.Runner,
+			Status:    "success",
+			Duration:  r.Duration.Seconds(),
+			Cache:     r.Cache,
+			Image:     r.Image,
+			Artifacts: r.Artifacts,
+			Log:       r.Log,
+		}
+		if tr.Artifacts == nil {
+			tr.Artifacts = []string{}
+		}
+		for _, p := range r.Phases {
+			tr.Phases = append(tr.Phases, phaseReport{Name: p.Name, Duration: p.Duration.Seconds()})
+		}
+		switch {
+		case r.Skipped:
+			tr.Status = "skipped"
+		case r.Resumed:
+			tr.Status = "resumed"
+		case r.Err != nil:
+			tr.Status = "failed"
+			tr.Error = r.Err.Error()
+		}
+		report.Toolchains = append(report.Toolchains, tr)
+	}
+	return report
+}
+
+// listArtifacts returns the paths of the files under dir, or none if it is missing
+func listArtifacts(dir string) []string {
+	var artifacts []string
+	_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			artifacts = append(artifacts, filepath.ToSlash(path))
+		}
+		return nil
+	})
+	return artifacts
+}
+
+// writeBuildReport writes report to w as indented JSON
+func writeBuildReport(w io.Writer, report buildReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to encode build report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+)
+
+// runStateFile is the file under the CI cache recording the progress of a build
+const runStateFile = "run-state.json"
+
+// runState records the toolchains of a build that finished, keyed by the
+// sources (cpx-ci.yaml included) and the build's options, so an interrupted
+// build of the same tree can skip them with --resume
+type runState struct {
+	mu        sync.Mutex
+	path      string
+	Key       string   `json:"key"`
+	Completed []string `json:"completed"`
+}
+
+// runStateKey returns the key of a build of the project's current sources
+// with options, leaving out the build's output and cache directories
+func runStateKey(projectRoot, outputDir, cacheDir string, options ToolchainBuildOptions) (string, error) {
+	h := sha256.New()
+	if err := hashSourceTree(h, projectRoot, outputDir, cacheDir); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "\noptions tests=%t coverage=%t bench=%t run=%t profile=%s\n", options.RunTests, options.Coverage, options.RunBenchmarks, options.ExecuteAfterBuild, options.Profile)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// loadRunState returns the state of a build with key. With resume, the
+// toolchains an earlier build with the same key completed are kept;
+// otherwise, or when the key differs, the build starts from scratch.
+func loadRunState(cacheDir, key string, resume bool) *runState {
+	state := &runState{path: filepath.Join(cacheDir, runStateFile), Key: key}
+	if !resume {
+		return state
+	}
+	var saved runState
+	data, err := os.ReadFile(state.path)
+	if err == nil && json.Unmarshal(data, &saved) == nil && saved.Key == key {
+		state.Completed = saved.Completed
+	}
+	return state
+}
+
+// done reports whether an earlier build completed the toolchain and its
+// artifacts are still there
+func (s *runState) done(name, outputDir string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.Completed, name) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(outputDir, name))
+	return err == nil
+}
+
+// complete records that a toolchain built successfully
+func (s *runState) complete(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.Completed, name) {
+		s.Completed = append(s.Completed, name)
+	}
+	data, err := json.Marshal(s)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(s.path, data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("%sWarning: failed to save build progress: %v%s\n", colors.Yellow, err, colors.Reset)
+	}
+}
+
+// clear removes the state once a build completed, leaving nothing to resume
+func (s *runState) clear() {
+	if s != nil {
+		_ = os.Remove(s.path)
+	}
+}
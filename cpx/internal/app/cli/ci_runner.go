@@ -0,0 +1,361 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Runner builds toolchains in one kind of execution environment. A build
+// calls Prepare, one toolchain at a time, then Build, Run when it runs the
+// executable (`cpx run --toolchain`) and Collect, possibly concurrently with
+// other toolchains. The built-in runners run the executable and collect the
+// artifacts as part of Build.
+type Runner interface {
+	// Prepare readies the environment, e.g. resolving a Docker image
+	Prepare(b *runnerBuild) error
+	// Build compiles the toolchain, with its tests and benchmarks when asked
+	Build(b *runnerBuild) error
+	// Run runs the executable the build produced
+	Run(b *runnerBuild) error
+	// Collect puts the artifacts into the toolchain's output directory
+	Collect(b *runnerBuild) error
+}
+
+// runnerDescriber is implemented by runners that can print the commands
+// building a toolchain for --dry-run
+type runnerDescriber interface {
+	DryRun(w io.Writer, b *runnerBuild) error
+}
+
+// containerRunner is implemented by runners whose builds run in a container
+// that hooks with `in: container` can run in
+type containerRunner interface {
+	Exec(b *runnerBuild, command string, env map[string]string) error
+}
+
+// runnerTypes makes the Runner of each runner type
+var runnerTypes = map[string]func() Runner{
+	"":       func() Runner { return &nativeRunner{} },
+	"native": func() Runner { return &nativeRunner{} },
+	"local":  func() Runner { return &nativeRunner{} },
+	"docker": func() Runner { return &dockerRunner{} },
+	"ssh":    func() Runner { return &sshRunner{} },
+	"plugin": func() Runner { return &pluginRunner{} },
+}
+
+// newRunner returns the Runner of a runner's type; toolchains without a
+// runner build natively
+func newRunner(runner *config.Runner) (Runner, error) {
+	typ := ""
+	if runner != nil {
+		typ = runner.Type
+	}
+	newFn, ok := runnerTypes[typ]
+	if !ok {
+		return nil, fmt.Errorf("%s runners are not supported yet", typ)
+	}
+	return newFn(), nil
+}
+
+// runnerBuild is one toolchain build on a Runner
+type runnerBuild struct {
+	tc          config.Toolchain
+	runner      *config.Runner // nil for native builds without a runner
+	projectRoot string
+	cacheDir    string
+	outputDir   string
+	options     ToolchainBuildOptions
+	result      *toolchainResult
+	// output receives the build's output; nil for the standard output,
+	// which native builds always write to
+	output io.Writer
+	// checkedMounts is set once the shared directories of local Docker
+	// engines were checked, which is done once per run
+	checkedMounts *bool
+}
+
+// stdout returns the writer of the build's output
+func (b *runnerBuild) stdout() io.Writer {
+	if b.output != nil {
+		return b.output
+	}
+	return os.Stdout
+}
+
+// buildOnRunner builds a prepared toolchain with its hooks around the Build,
+// Run and Collect steps
+func buildOnRunner(r Runner, b *runnerBuild) error {
+	hooks := newToolchainHooks(b.tc, b.options, b.projectRoot, b.outputDir, b.stdout())
+	if c, ok := r.(containerRunner); ok {
+		hooks.container = func(command string, env map[string]string) error {
+			return c.Exec(b, command, env)
+		}
+	}
+	return hooks.run(func() error {
+		if err := r.Build(b); err != nil {
+			return err
+		}
+		if b.options.ExecuteAfterBuild {
+			if err := r.Run(b); err != nil {
+				return err
+			}
+		}
+		return r.Collect(b)
+	})
+}
+
+// describeRunner returns how a runner is shown next to a toolchain's name
+func describeRunner(runner *config.Runner) string {
+	switch {
+	case runner == nil || runner.IsNative():
+		return "native"
+	case runner.IsDocker():
+		return describePlatform(runner)
+	case runner.IsSSH():
+		return "ssh, " + sshHost(runner).Destination()
+	case runner.IsPlugin():
+		return "plugin, " + runner.Plugin
+	default:
+		return runner.Type
+	}
+}
+
+// nativeRunner builds with CMake on the host
+type nativeRunner struct{}
+
+func (nativeRunner) Prepare(*runnerBuild) error { return nil }
+
+func (nativeRunner) Build(b *runnerBuild) error {
+	err := runNativeBuildNew(b.tc, b.runner, b.projectRoot, b.cacheDir, b.outputDir, b.options.RunTests, b.options.RunBenchmarks)
+	b.result.Phases = build.ReadPhases(filepath.Join(b.cacheDir, b.tc.Name))
+	return err
+}
+
+func (nativeRunner) Run(*runnerBuild) error { return nil }
+
+func (nativeRunner) Collect(*runnerBuild) error { return nil }
+
+func (nativeRunner) DryRun(w io.Writer, b *runnerBuild) error {
+	return dryRunNative(w, b.tc, b.runner, b.projectRoot, b.cacheDir, b.options)
+}
+
+// dockerRunner builds in a container of the runner's image, on a local or
+// remote engine. The build script runs the executable.
+type dockerRunner struct {
+	builder build.DockerBuilder
+	opts    build.DockerBuildOptions
+}
+
+// Prepare resolves the image, building or pulling it if needed
+func (d *dockerRunner) Prepare(b *runnerBuild) error {
+	endpoint, err := runnerEndpoint(b.runner)
+	if err != nil {
+		return fmt.Errorf("invalid Docker endpoint: %w", err)
+	}
+	if !*b.checkedMounts && !endpoint.Remote {
+		warnUnsharedPaths(b.projectRoot, b.cacheDir)
+		*b.checkedMounts = true
+	}
+	imageName, err := resolveDockerImageNew(endpoint, b.projectRoot, b.runner, b.options.Verbose)
+	if err != nil {
+		return fmt.Errorf("failed to resolve Docker image: %w", err)
+	}
+	b.result.Image = imageName
+	warnEmulatedPlatform(endpoint, b.runner, imageName)
+
+	d.builder = dockerBuilderFor(b.projectRoot)
+	d.opts = toolchainDockerOptions(b.tc, b.runner, endpoint, imageName, b.projectRoot, b.cacheDir, b.outputDir, b.options)
+	d.opts.Output = b.output
+	// The toolchain's secrets are masked in everything the build prints
+	b.output = d.opts.Stdout()
+	return nil
+}
+
+// Build runs the build, or restores its artifacts from the artifact cache
+func (d *dockerRunner) Build(b *runnerBuild) error {
+	log := startBuildLog(b.result, b.projectRoot, d.opts.Stdout())
+	defer log.Close()
+	opts := d.opts
+	opts.Log = logWriter(log)
+	return runCachedDockerToolchain(d.builder, opts, b.tc, b.runner, b.options, b.result)
+}
+
+func (d *dockerRunner) Run(*runnerBuild) error { return nil }
+
+func (d *dockerRunner) Collect(*runnerBuild) error { return nil }
+
+// Exec runs a command in a Shell container of the build's image, with its
+// mounts and environment but not the terminal's stdin, which parallel hooks
+// would compete for
+func (d *dockerRunner) Exec(_ *runnerBuild, command string, env map[string]string) error {
+	shell := d.opts
+	shell.Shell = true
+	shell.ShellCommand = []string{"sh", "-c", command}
+	shell.NoStdin = true
+	shell.Env = maps.Clone(d.opts.Env)
+	if shell.Env == nil {
+		shell.Env = make(map[string]string, len(env))
+	}
+	maps.Copy(shell.Env, env)
+	return runDockerToolchain(context.Background(), d.builder, shell)
+}
+
+func (d *dockerRunner) DryRun(w io.Writer, b *runnerBuild) error {
+	return dryRunDocker(w, b.tc, b.runner, b.projectRoot, b.cacheDir, b.outputDir, b.options)
+}
+
+// sshRunner builds on a remote host over SSH, copying the artifacts back
+type sshRunner struct{}
+
+func (sshRunner) Prepare(b *runnerBuild) error {
+	if b.runner.Host == "" {
+		return fmt.Errorf("SSH runner '%s' has no host specified", b.runner.Name)
+	}
+	return nil
+}
+
+func (sshRunner) Build(b *runnerBuild) error {
+	log := startBuildLog(b.result, b.projectRoot, b.stdout())
+	defer log.Close()
+	return runSSHBuild(b.tc, b.runner, b.projectRoot, b.outputDir, DetectProjectType(), b.options, b.stdout(), logWriter(log))
+}
+
+func (sshRunner) Run(*runnerBuild) error { return nil }
+
+func (sshRunner) Collect(*runnerBuild) error { return nil }
+
+func (sshRunner) DryRun(w io.Writer, b *runnerBuild) error {
+	return dryRunSSH(w, b.tc, b.runner, b.projectRoot, b.outputDir, b.options)
+}
+
+// pluginRunner hands each step to an external executable: `<plugin> prepare`,
+// `build`, `run` and `collect`, run in the project root with the build
+// described in its environment (see pluginRunnerEnv). A step a plugin has
+// nothing to do for should exit 0.
+type pluginRunner struct {
+	path string
+	spec string // file holding the resolved toolchain and runner
+}
+
+// Prepare finds the plugin and writes the build's description for it
+func (p *pluginRunner) Prepare(b *runnerBuild) error {
+	name := b.runner.Plugin
+	if name == "" {
+		return fmt.Errorf("plugin runner '%s' has no plugin specified", b.runner.Name)
+	}
+	if strings.ContainsRune(name, '/') && !filepath.IsAbs(name) {
+		name = filepath.Join(b.projectRoot, name)
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("runner plugin '%s' not found: %w", b.runner.Plugin, err)
+	}
+	p.path = path
+
+	buildDir := filepath.Join(b.cacheDir, b.tc.Name)
+	if err := os.MkdirAll(buildDir, 0755); err != nil {
+		return fmt.Errorf("failed to create build directory: %w", err)
+	}
+	data, err := yaml.Marshal(struct {
+		Toolchain config.Toolchain `yaml:"toolchain"`
+		Runner    config.Runner    `yaml:"runner"`
+	}{b.tc, *b.runner})
+	if err != nil {
+		return fmt.Errorf("failed to encode toolchain: %w", err)
+	}
+	if p.spec, err = filepath.Abs(filepath.Join(buildDir, "runner.yaml")); err != nil {
+		return fmt.Errorf("failed to get absolute path for build directory: %w", err)
+	}
+	if err := os.WriteFile(p.spec, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", p.spec, err)
+	}
+	return p.step(b, "prepare")
+}
+
+func (p *pluginRunner) Build(b *runnerBuild) error {
+	log := startBuildLog(b.result, b.projectRoot, b.stdout())
+	defer log.Close()
+	out := b.stdout()
+	if log != nil {
+		out = io.MultiWriter(out, log)
+	}
+	return p.stepTo(b, "build", out)
+}
+
+func (p *pluginRunner) Run(b *runnerBuild) error { return p.step(b, "run") }
+
+func (p *pluginRunner) Collect(b *runnerBuild) error { return p.step(b, "collect") }
+
+// DryRun prints the plugin's steps without running them
+func (p *pluginRunner) DryRun(w io.Writer, b *runnerBuild) error {
+	steps := []string{"prepare", "build"}
+	if b.options.ExecuteAfterBuild {
+		steps = append(steps, "run")
+	}
+	for _, step := range append(steps, "collect") {
+		fmt.Fprintf(w, "%s# %s%s\n", colors.Gray, step, colors.Reset)
+		build.FprintCommand(w, []string{b.runner.Plugin, step})
+	}
+	return nil
+}
+
+func (p *pluginRunner) step(b *runnerBuild, step string) error {
+	return p.stepTo(b, step, b.stdout())
+}
+
+// stepTo runs one step of the plugin with its output going to out
+func (p *pluginRunner) stepTo(b *runnerBuild, step string, out io.Writer) error {
+	env, err := pluginRunnerEnv(b, p.spec)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(p.path, step)
+	cmd.Dir = b.projectRoot
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("runner plugin %s %s failed: %w", b.runner.Plugin, step, err)
+	}
+	return nil
+}
+
+// pluginRunnerEnv returns the variables describing a build to a runner
+// plugin; spec holds the resolved toolchain and runner as YAML
+func pluginRunnerEnv(b *runnerBuild, spec string) ([]string, error) {
+	projectRoot, err := filepath.Abs(b.projectRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+	buildDir, err := filepath.Abs(filepath.Join(b.cacheDir, b.tc.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for build directory: %w", err)
+	}
+	outputDir, err := filepath.Abs(filepath.Join(b.outputDir, b.tc.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for output directory: %w", err)
+	}
+	return []string{
+		"CPX_RUNNER=" + b.runner.Name,
+		"CPX_TOOLCHAIN=" + b.tc.Name,
+		"CPX_TOOLCHAIN_FILE=" + spec,
+		"CPX_BUILD_TYPE=" + b.tc.BuildType,
+		"CPX_PROJECT_ROOT=" + projectRoot,
+		"CPX_BUILD_DIR=" + buildDir,
+		"CPX_OUTPUT_DIR=" + outputDir,
+		"CPX_RUN_TESTS=" + strconv.FormatBool(b.options.RunTests),
+		"CPX_RUN_BENCHMARKS=" + strconv.FormatBool(b.options.RunBenchmarks),
+		"CPX_VERBOSE=" + strconv.FormatBool(b.options.Verbose),
+	}, nil
+}
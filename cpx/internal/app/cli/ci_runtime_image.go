@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
+	"github.com/ozacod/cpx/internal/pkg/packaging"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// runtimeImageSpec is what goes into a runtime image
+type runtimeImageSpec struct {
+	Base       string
+	Executable string   // file name, installed to /usr/local/bin
+	Libraries  []string // shared library file names, installed to /usr/local/lib
+	Title      string
+	Version    string
+}
+
+func runCIImage(cmd *cobra.Command, _ []string) error {
+	toolchainName, _ := cmd.Flags().GetString("toolchain")
+	base, _ := cmd.Flags().GetString("base")
+	repository, _ := cmd.Flags().GetString("repository")
+	executable, _ := cmd.Flags().GetString("executable")
+	push, _ := cmd.Flags().GetBool("push")
+	if toolchainName == "" {
+		return fmt.Errorf("--toolchain is required")
+	}
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	// The runner, and so the platform, may be inherited through extends
+	tc, err := ciConfig.ResolveToolchain(toolchainName)
+	if err != nil {
+		return fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+	}
+	if tc == nil {
+		return fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", toolchainName)
+	}
+	settings := ciConfig.RuntimeImage
+	if settings == nil {
+		settings = &config.RuntimeImageConfig{}
+	}
+	if base == "" {
+		base = settings.GetBase()
+	}
+	if repository == "" {
+		repository = settings.Repository
+	}
+	if executable == "" {
+		executable = settings.Executable
+	}
+	push = push || settings.Push
+
+	name, version := getProjectInfo()
+	if repository == "" {
+		repository = strings.ToLower(name)
+		if cfg, err := config.Load(); err == nil && cfg.Registry != "" {
+			repository = strings.TrimSuffix(cfg.Registry, "/") + "/" + repository
+		}
+	}
+	tag := repository + ":" + version
+
+	outputDir := ciConfig.GetOutputDir()
+	target, err := collectPackageTarget(ciConfig, *tc, outputDir, name)
+	if err != nil {
+		return err
+	}
+	if target.OS != "linux" {
+		return fmt.Errorf("toolchain '%s' builds for %s; runtime images need Linux executables", tc.Name, target.OS)
+	}
+	spec, err := runtimeImageContents(target.Files, executable)
+	if err != nil {
+		return fmt.Errorf("toolchain '%s': %w", tc.Name, err)
+	}
+	spec.Base, spec.Title, spec.Version = base, name, version
+
+	contextDir, err := os.MkdirTemp("", "cpx-image-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(contextDir)
+	for _, f := range target.Files {
+		if f.Path != "bin/"+spec.Executable && !slices.Contains(spec.Libraries, strings.TrimPrefix(f.Path, "lib/")) {
+			continue
+		}
+		dest := filepath.Join(contextDir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := copyFile(f.Source, dest); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte(runtimeDockerfile(spec)), 0644); err != nil {
+		return err
+	}
+
+	platform := fmt.Sprintf("linux/%s", target.Arch)
+	runner := ciConfig.FindRunner(tc.Runner)
+	if runner == nil || !runner.IsDocker() {
+		runner = &config.Runner{} // the default engine
+	} else if p := runnerPlatform(runner); p != "" {
+		platform = p
+	}
+	endpoint, err := runnerEndpoint(runner)
+	if err != nil {
+		return fmt.Errorf("invalid Docker endpoint: %w", err)
+	}
+
+	fmt.Printf("%s Building %s from %s (%s)...%s\n", colors.Cyan, tag, base, platform, colors.Reset)
+	build := endpoint.Command("build", "-t", tag, "--platform", platform, docker.HostPath(contextDir))
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return fmt.Errorf("docker build failed: %w", err)
+	}
+	if push {
+		fmt.Printf("%s Pushing %s...%s\n", colors.Cyan, tag, colors.Reset)
+		pushCmd := endpoint.Command("push", tag)
+		pushCmd.Stdout = os.Stdout
+		pushCmd.Stderr = os.Stderr
+		if err := pushCmd.Run(); err != nil {
+			return fmt.Errorf("docker push failed: %w", err)
+		}
+	}
+	fmt.Printf("%s✓ Built %s running %s%s\n", colors.Green, tag, spec.Executable, colors.Reset)
+	return nil
+}
+
+// runtimeImageContents picks the entrypoint and the shared libraries from a
+// toolchain's artifacts, laid out as by packagePath. Without a named
+// executable, the toolchain must have built exactly one.
+func runtimeImageContents(files []packaging.File, executable string) (runtimeImageSpec, error) {
+	var spec runtimeImageSpec
+	var executables []string
+	for _, f := range files {
+		dir, file := path.Split(f.Path)
+		switch {
+		case dir == "bin/":
+			executables = append(executables, file)
+		case dir == "lib/" && (strings.HasSuffix(file, ".so") || strings.Contains(file, ".so.")):
+			spec.Libraries = append(spec.Libraries, file)
+		}
+	}
+	switch {
+	case executable != "":
+		for _, e := range executables {
+			if e == executable {
+				spec.Executable = e
+			}
+		}
+		if spec.Executable == "" {
+			return spec, fmt.Errorf("executable '%s' not found in its artifacts", executable)
+		}
+	case len(executables) == 1:
+		spec.Executable = executables[0]
+	case len(executables) == 0:
+		return spec, fmt.Errorf("no executable in its artifacts; run 'cpx ci build' first")
+	default:
+		return spec, fmt.Errorf("several executables (%s); choose one with --executable", strings.Join(executables, ", "))
+	}
+	return spec, nil
+}
+
+// runtimeDockerfile returns the Dockerfile of a runtime image
+func runtimeDockerfile(spec runtimeImageSpec) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", spec.Base)
+	fmt.Fprintf(&b, "COPY bin/%s /usr/local/bin/%s\n", spec.Executable, spec.Executable)
+	if len(spec.Libraries) > 0 {
+		for _, lib := range spec.Libraries {
+			fmt.Fprintf(&b, "COPY lib/%s /usr/local/lib/%s\n", lib, lib)
+		}
+		b.WriteString("ENV LD_LIBRARY_PATH=/usr/local/lib\n")
+	}
+	fmt.Fprintf(&b, "LABEL org.opencontainers.image.title=%q org.opencontainers.image.version=%q\n", spec.Title, spec.Version)
+	fmt.Fprintf(&b, "ENTRYPOINT [\"/usr/local/bin/%s\"]\n", spec.Executable)
+	return b.String()
+}
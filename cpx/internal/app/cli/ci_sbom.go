@@ -0,0 +1,370 @@
+package cli
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// sbomFiles are the SBOM file names by format, in a toolchain's output directory
+var sbomFiles = map[string]string{
+	"spdx":      "sbom.spdx.json",
+	"cyclonedx": "sbom.cdx.json",
+}
+
+// sbomInfo is what an SBOM records about a toolchain build
+type sbomInfo struct {
+	Project   string
+	Version   string
+	Toolchain config.Toolchain
+	Runner    *config.Runner
+	Image     string // Docker builds: the image and its ID
+	ImageID   string
+	Packages  []vcpkgPackage
+	Installed bool // Packages come from the installed tree, not vcpkg.json
+	Artifacts []artifactDigest
+}
+
+// vcpkgPackage is a vcpkg port that went into a build
+type vcpkgPackage struct {
+	Name    string
+	Version string // empty when only declared in vcpkg.json without a version
+	Triplet string
+}
+
+// finishToolchain runs the steps after a toolchain built successfully: naming
+// its artifacts and writing its SBOM and provenance
+func finishToolchain(ciConfig *config.ToolchainConfig, tc config.Toolchain, result toolchainResult, projectRoot, cacheDir, outputDir, version string) error {
+	dir := filepath.Join(outputDir, tc.Name)
+	if err := nameArtifacts(tc, dir, version); err != nil {
+		return err
+	}
+	if ciConfig.SBOM != nil {
+		info := collectSBOM(ciConfig, tc, result, projectRoot, filepath.Join(cacheDir, tc.Name), dir)
+		if err := writeSBOM(ciConfig.SBOM.GetFormat(), info, dir); err != nil {
+			return err
+		}
+	}
+	if ciConfig.Provenance {
+		return writeProvenance(collectProvenance(ciConfig, tc, result, projectRoot, dir), dir)
+	}
+	return nil
+}
+
+// collectSBOM gathers the packages, environment and artifacts of a toolchain
+// build. Packages come from the vcpkg installed tree in the toolchain's build
+// directory, or from vcpkg.json when there is none (SSH builds).
+func collectSBOM(ciConfig *config.ToolchainConfig, tc config.Toolchain, result toolchainResult, projectRoot, buildDir, dir string) sbomInfo {
+	info := sbomInfo{Toolchain: tc, Runner: ciConfig.FindRunner(tc.Runner), Image: result.Image}
+	info.Project, info.Version = getProjectInfo()
+	if info.Image != "" && info.Runner != nil {
+		if endpoint, err := runnerEndpoint(info.Runner); err == nil {
+			info.ImageID = endpoint.ImageID(info.Image)
+		}
+	}
+
+	for _, status := range []string{
+		filepath.Join(buildDir, vcpkgCacheDirName, "installed", "vcpkg", "status"),
+		filepath.Join(buildDir, "vcpkg_installed", "vcpkg", "status"),
+	} {
+		if packages, err := installedVcpkgPackages(status); err == nil {
+			info.Packages, info.Installed = packages, true
+			break
+		}
+	}
+	if !info.Installed {
+		info.Packages = manifestVcpkgPackages(filepath.Join(projectRoot, "vcpkg.json"))
+	}
+	info.Artifacts = artifactDigests(dir)
+	return info
+}
+
+// installedVcpkgPackages reads the installed ports from a vcpkg status file
+// (<installed>/vcpkg/status), whose paragraphs describe a port or a feature
+func installedVcpkgPackages(path string) ([]vcpkgPackage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var packages []vcpkgPackage
+	for _, paragraph := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n\n") {
+		fields := make(map[string]string)
+		scanner := bufio.NewScanner(strings.NewReader(paragraph))
+		for scanner.Scan() {
+			if key, value, ok := strings.Cut(scanner.Text(), ":"); ok {
+				fields[key] = strings.TrimSpace(value)
+			}
+		}
+		if fields["Package"] == "" || fields["Feature"] != "" || !strings.HasSuffix(fields["Status"], " installed") {
+			continue
+		}
+		version := fields["Version"]
+		if pv := fields["Port-Version"]; pv != "" && pv != "0" {
+			version += "#" + pv
+		}
+		packages = append(packages, vcpkgPackage{Name: fields["Package"], Version: version, Triplet: fields["Architecture"]})
+	}
+	slices.SortFunc(packages, func(a, b vcpkgPackage) int { return strings.Compare(a.Name+":"+a.Triplet, b.Name+":"+b.Triplet) })
+	return packages, nil
+}
+
+// manifestVcpkgPackages returns the dependencies a vcpkg.json declares, with
+// their minimum version when it has one
+func manifestVcpkgPackages(path string) []vcpkgPackage {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var manifest struct {
+		Dependencies []json.RawMessage `json:"dependencies"`
+	}
+	if json.Unmarshal(data, &manifest) != nil {
+		return nil
+	}
+	var packages []vcpkgPackage
+	for _, raw := range manifest.Dependencies {
+		var name string
+		if json.Unmarshal(raw, &name) == nil {
+			packages = append(packages, vcpkgPackage{Name: name})
+			continue
+		}
+		var dep struct {
+			Name    string `json:"name"`
+			Version string `json:"version>="`
+		}
+		if json.Unmarshal(raw, &dep) == nil && dep.Name != "" {
+			packages = append(packages, vcpkgPackage{Name: dep.Name, Version: dep.Version})
+		}
+	}
+	return packages
+}
+
+// writeSBOM writes the SBOM of a toolchain build to its output directory
+func writeSBOM(format string, info sbomInfo, dir string) error {
+	var doc any
+	switch format {
+	case "cyclonedx":
+		doc = cycloneDXDocument(info, time.Now().UTC())
+	default:
+		doc = spdxDocument(info, time.Now().UTC())
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode SBOM: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, sbomFiles[format]), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %w", err)
+	}
+	return nil
+}
+
+// sbomProperties describes the build environment as name/value pairs
+func sbomProperties(info sbomInfo) [][2]string {
+	props := [][2]string{{"cpx:toolchain", info.Toolchain.Name}, {"cpx:build_type", info.Toolchain.BuildType}}
+	if info.Runner != nil {
+		props = append(props, [2]string{"cpx:runner", info.Runner.Name}, [2]string{"cpx:runner_type", cmp.Or(info.Runner.Type, "native")})
+		if p := runnerPlatform(info.Runner); p != "" {
+			props = append(props, [2]string{"cpx:platform", p})
+		}
+		cc, cxx := runnerCompilers(info.Runner)
+		props = append(props, [2]string{"cpx:cc", cc}, [2]string{"cpx:cxx", cxx})
+	}
+	if info.Image != "" {
+		props = append(props, [2]string{"cpx:image", info.Image})
+	}
+	if info.ImageID != "" {
+		props = append(props, [2]string{"cpx:image_id", info.ImageID})
+	}
+	source := "vcpkg.json"
+	if info.Installed {
+		source = "vcpkg installed tree"
+	}
+	props = append(props, [2]string{"cpx:cpx_version", Version}, [2]string{"cpx:dependencies_from", source})
+	return slices.DeleteFunc(props, func(p [2]string) bool { return p[1] == "" })
+}
+
+// spdxDocument returns an SPDX 2.3 document for a toolchain build
+func spdxDocument(info sbomInfo, created time.Time) map[string]any {
+	rootID := "SPDXRef-Package-" + spdxID(info.Project)
+	var comment []string
+	for _, p := range sbomProperties(info) {
+		comment = append(comment, p[0]+"="+p[1])
+	}
+	packages := []map[string]any{{
+		"SPDXID":           rootID,
+		"name":             info.Project,
+		"versionInfo":      info.Version,
+		"downloadLocation": "NOASSERTION",
+		"filesAnalyzed":    false,
+		"comment":          strings.Join(comment, "\n"),
+	}}
+	relationships := []map[string]any{{
+		"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": rootID,
+	}}
+
+	if info.Image != "" {
+		imageID := "SPDXRef-Image-" + spdxID(info.Image)
+		image := map[string]any{
+			"SPDXID":                imageID,
+			"name":                  info.Image,
+			"downloadLocation":      "NOASSERTION",
+			"filesAnalyzed":         false,
+			"primaryPackagePurpose": "CONTAINER",
+		}
+		if algo, digest, ok := strings.Cut(info.ImageID, ":"); ok {
+			image["checksums"] = []map[string]string{{"algorithm": strings.ToUpper(algo), "checksumValue": digest}}
+		}
+		packages = append(packages, image)
+		relationships = append(relationships, map[string]any{
+			"spdxElementId": imageID, "relationshipType": "BUILD_TOOL_OF", "relatedSpdxElement": rootID,
+		})
+	}
+
+	for _, pkg := range info.Packages {
+		id := "SPDXRef-Package-vcpkg-" + spdxID(pkg.Name+"-"+pkg.Triplet)
+		packages = append(packages, map[string]any{
+			"SPDXID":           id,
+			"name":             pkg.Name,
+			"versionInfo":      cmp.Or(pkg.Version, "NOASSERTION"),
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed":    false,
+			"supplier":         "NOASSERTION",
+			"comment":          strings.TrimSpace("vcpkg port " + pkg.Triplet),
+		})
+		relationships = append(relationships, map[string]any{
+			"spdxElementId": rootID, "relationshipType": "DEPENDS_ON", "relatedSpdxElement": id,
+		})
+	}
+
+	var files []map[string]any
+	for _, a := range info.Artifacts {
+		id := "SPDXRef-File-" + spdxID(a.Name)
+		files = append(files, map[string]any{
+			"SPDXID":    id,
+			"fileName":  "./" + a.Name,
+			"checksums": []map[string]string{{"algorithm": "SHA256", "checksumValue": a.SHA256}},
+		})
+		relationships = append(relationships, map[string]any{
+			"spdxElementId": id, "relationshipType": "GENERATED_FROM", "relatedSpdxElement": rootID,
+		})
+	}
+
+	doc := map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              info.Project + "-" + info.Toolchain.Name,
+		"documentNamespace": fmt.Sprintf("https://spdx.org/spdxdocs/%s-%s-%s", spdxID(info.Project), spdxID(info.Toolchain.Name), randomHex(16)),
+		"creationInfo": map[string]any{
+			"created":  created.Format(time.RFC3339),
+			"creators": []string{"Tool: cpx-" + Version},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+	if len(files) > 0 {
+		doc["files"] = files
+	}
+	return doc
+}
+
+// cycloneDXDocument returns a CycloneDX 1.5 document for a toolchain build
+func cycloneDXDocument(info sbomInfo, created time.Time) map[string]any {
+	var properties []map[string]string
+	for _, p := range sbomProperties(info) {
+		properties = append(properties, map[string]string{"name": p[0], "value": p[1]})
+	}
+	rootRef := "project:" + info.Project
+
+	var components []map[string]any
+	var dependsOn []string
+	for _, pkg := range info.Packages {
+		ref := "vcpkg:" + pkg.Name
+		if pkg.Triplet != "" {
+			ref += ":" + pkg.Triplet
+		}
+		component := map[string]any{"type": "library", "bom-ref": ref, "name": pkg.Name}
+		if pkg.Version != "" {
+			component["version"] = pkg.Version
+		}
+		if pkg.Triplet != "" {
+			component["properties"] = []map[string]string{{"name": "vcpkg:triplet", "value": pkg.Triplet}}
+		}
+		components = append(components, component)
+		dependsOn = append(dependsOn, ref)
+	}
+	if info.Image != "" {
+		image := map[string]any{"type": "container", "bom-ref": "image:" + info.Image, "name": info.Image, "scope": "excluded"}
+		if algo, digest, ok := strings.Cut(info.ImageID, ":"); ok {
+			image["hashes"] = []map[string]string{{"alg": cycloneDXAlg(algo), "content": digest}}
+		}
+		components = append(components, image)
+	}
+	for _, a := range info.Artifacts {
+		components = append(components, map[string]any{
+			"type":    "file",
+			"bom-ref": "file:" + a.Name,
+			"name":    a.Name,
+			"hashes":  []map[string]string{{"alg": "SHA-256", "content": a.SHA256}},
+		})
+	}
+
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + randomUUID(),
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp":  created.Format(time.RFC3339),
+			"tools":      map[string]any{"components": []map[string]string{{"type": "application", "name": "cpx", "version": Version}}},
+			"component":  map[string]any{"type": "application", "bom-ref": rootRef, "name": info.Project, "version": info.Version},
+			"properties": properties,
+		},
+		"components":   components,
+		"dependencies": []map[string]any{{"ref": rootRef, "dependsOn": dependsOn}},
+	}
+}
+
+// cycloneDXAlg returns the CycloneDX name of a digest algorithm, e.g. SHA-256 for sha256
+func cycloneDXAlg(algo string) string {
+	algo = strings.ToUpper(algo)
+	if rest, ok := strings.CutPrefix(algo, "SHA"); ok && !strings.HasPrefix(rest, "-") {
+		return "SHA-" + rest
+	}
+	return algo
+}
+
+// spdxID returns s with the characters SPDX identifiers do not allow replaced by '-'
+func spdxID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// randomUUID returns a random (version 4) UUID
+func randomUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
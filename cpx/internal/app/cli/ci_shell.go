@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func runCIShell(cmd *cobra.Command, args []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	builder, opts, err := toolchainShellOptions(args[0], verbose)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s Starting a shell in %s for '%s' (exit to leave)%s\n", colors.Cyan, opts.ImageName, opts.TargetName, colors.Reset)
+	return runDockerToolchain(context.Background(), builder, opts)
+}
+
+func runCIExec(cmd *cobra.Command, args []string) error {
+	toolchainName, _ := cmd.Flags().GetString("toolchain")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	builder, opts, err := toolchainShellOptions(toolchainName, verbose)
+	if err != nil {
+		return err
+	}
+	opts.ShellCommand = args
+	err = runDockerToolchain(context.Background(), builder, opts)
+	// Exit with the command's status, like the command itself would
+	var exitErr *build.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	return err
+}
+
+// toolchainShellOptions resolves the image of a Docker toolchain, building or
+// pulling it if needed, and returns the options starting a Shell container
+// with the toolchain's mounts and environment
+func toolchainShellOptions(name string, verbose bool) (build.DockerBuilder, build.DockerBuildOptions, error) {
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return nil, build.DockerBuildOptions{}, fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	tc, err := ciConfig.ResolveToolchain(name)
+	if err != nil {
+		return nil, build.DockerBuildOptions{}, fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+	}
+	if tc == nil {
+		return nil, build.DockerBuildOptions{}, fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", name)
+	}
+	runner := ciConfig.FindRunner(tc.Runner)
+	if runner == nil && tc.Runner != "" {
+		return nil, build.DockerBuildOptions{}, fmt.Errorf("runner '%s' not found for toolchain '%s'", tc.Runner, tc.Name)
+	}
+	if runner == nil || !runner.IsDocker() {
+		return nil, build.DockerBuildOptions{}, fmt.Errorf("toolchain '%s' does not build in Docker", tc.Name)
+	}
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return nil, build.DockerBuildOptions{}, fmt.Errorf("failed to get project root: %w", err)
+	}
+	cacheDir := ciCacheDir(projectRoot)
+	outputDir := ciConfig.GetOutputDir()
+
+	endpoint, err := runnerEndpoint(runner)
+	if err != nil {
+		return nil, build.DockerBuildOptions{}, fmt.Errorf("invalid Docker endpoint: %w", err)
+	}
+	if !endpoint.Remote {
+		warnUnsharedPaths(projectRoot, cacheDir)
+	}
+	imageName, err := resolveDockerImageNew(endpoint, projectRoot, runner, verbose)
+	if err != nil {
+		return nil, build.DockerBuildOptions{}, fmt.Errorf("failed to resolve Docker image: %w", err)
+	}
+	warnEmulatedPlatform(endpoint, runner, imageName)
+
+	options := ToolchainBuildOptions{Verbose: verbose, Cache: ciConfig.Cache, Vcpkg: ciConfig.Vcpkg}
+	opts := toolchainDockerOptions(*tc, runner, endpoint, imageName, projectRoot, cacheDir, outputDir, options)
+	opts.Shell = true
+	return dockerBuilderFor(projectRoot), opts, nil
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// signatureExts are the files signing writes next to an artifact, never signed themselves
+var signatureExts = []string{".sig", ".pem", ".asc"}
+
+// finishArtifacts runs the steps after every toolchain built successfully:
+// signing the artifacts, writing SHA256SUMS (which covers the signatures),
+// signing SHA256SUMS itself and, with upload, pushing it all to storage
+func finishArtifacts(ciConfig *config.ToolchainConfig, outputDir string, toolchains []config.Toolchain, version string, upload bool) error {
+	if ciConfig.Sign != nil {
+		if err := signArtifacts(ciConfig.Sign, outputDir, toolchains); err != nil {
+			return err
+		}
+	}
+	if err := writeChecksums(ciConfig, outputDir, toolchains); err != nil {
+		return err
+	}
+	if ciConfig.Sign != nil {
+		if err := signFile(ciConfig.Sign, filepath.Join(outputDir, checksumsFile)); err != nil {
+			return err
+		}
+	}
+	if upload {
+		return uploadArtifacts(ciConfig, outputDir, toolchains, version)
+	}
+	return nil
+}
+
+// signArtifacts writes a signature (<file>.sig) for the files directly in the
+// output directory of each built toolchain. Result directories are not signed.
+func signArtifacts(sign *config.SignConfig, outputDir string, toolchains []config.Toolchain) error {
+	if !CheckCommandExists(sign.Method) {
+		return fmt.Errorf("%s not found in PATH, required by sign.method in cpx-ci.yaml", sign.Method)
+	}
+	fmt.Printf("\n%s Signing artifacts with %s...%s\n", colors.Cyan, sign.Method, colors.Reset)
+	signed := 0
+	for _, tc := range toolchains {
+		dir := filepath.Join(outputDir, tc.Name)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || e.Name() == checksumsFile || hasSignatureExt(e.Name()) {
+				continue
+			}
+			if err := signFile(sign, filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+			signed++
+		}
+	}
+	fmt.Printf("   Signed %d artifact(s)\n", signed)
+	return nil
+}
+
+// hasSignatureExt returns whether name is a signature or certificate file
+func hasSignatureExt(name string) bool {
+	for _, ext := range signatureExts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// signFile writes the signature of file to file.sig
+func signFile(sign *config.SignConfig, file string) error {
+	cmd := signCommand(sign, file)
+	if sign.PassphraseEnv != "" {
+		passphrase, ok := os.LookupEnv(sign.PassphraseEnv)
+		if !ok {
+			return fmt.Errorf("%s is not set, required by sign.passphrase_env in cpx-ci.yaml", sign.PassphraseEnv)
+		}
+		cmd.Stdin = strings.NewReader(passphrase + "\n")
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to sign %s: %w\n%s", file, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// signCommand returns the command writing the signature of file to file.sig.
+// Keyless cosign signing also writes the signing certificate to file.pem.
+func signCommand(sign *config.SignConfig, file string) *exec.Cmd {
+	switch sign.Method {
+	case "gpg":
+		args := []string{"--batch", "--yes", "--detach-sign"}
+		if sign.Key != "" {
+			args = append(args, "--local-user", sign.Key)
+		}
+		if sign.PassphraseEnv != "" {
+			args = append(args, "--pinentry-mode", "loopback", "--passphrase-fd", "0")
+		}
+		return execCommand("gpg", append(args, "--output", file+".sig", file)...)
+	default:
+		args := []string{"sign-blob", "--yes", "--output-signature", file + ".sig"}
+		if sign.Key != "" {
+			args = append(args, "--key", sign.Key)
+		} else {
+			args = append(args, "--output-certificate", file+".pem")
+		}
+		return execCommand("cosign", append(args, file)...)
+	}
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/build/remote"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// sshExcludes are local paths that are never synced to SSH hosts
+var sshExcludes = []string{".git", "/.cache", "/.cpx", "/.bin", "/build", "/bazel-*"}
+
+// sshWorkDir returns the remote working directory of a project. Relative
+// paths are relative to the remote login directory.
+func sshWorkDir(projectRoot string, runner *config.Runner) string {
+	if runner.WorkDir != "" {
+		// The login directory is home, so ~/ is dropped rather than left for the shell
+		return strings.TrimSuffix(strings.TrimPrefix(runner.WorkDir, "~/"), "/")
+	}
+	return ".cache/cpx/" + filepath.Base(projectRoot)
+}
+
+// sshHost returns the remote host of an SSH runner
+func sshHost(runner *config.Runner) remote.Host {
+	return remote.Host{
+		Address:      runner.Host,
+		User:         runner.User,
+		Port:         runner.Port,
+		IdentityFile: runner.IdentityFile,
+	}
+}
+
+// runSSHBuild syncs the project to an SSH runner, builds the toolchain there
+// and copies the artifacts back into outputDir/<toolchain>. The commands'
+// output is also copied to log when set, including the build output quiet
+// builds do not show.
+func runSSHBuild(tc config.Toolchain, runner *config.Runner, projectRoot, outputDir string, projectType ProjectType, options ToolchainBuildOptions, out, log io.Writer) error {
+	if runner.Host == "" {
+		return fmt.Errorf("SSH runner '%s' has no host specified", runner.Name)
+	}
+	host := sshHost(runner)
+	work := sshWorkDir(projectRoot, runner)
+
+	run := func(step string, cmd *exec.Cmd) error {
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s on %s failed: %w", step, host.Destination(), err)
+		}
+		return nil
+	}
+
+	// One writer per command, so its stdout and stderr are never written concurrently
+	tee := func(w io.Writer) io.Writer {
+		if log == nil {
+			return w
+		}
+		return io.MultiWriter(w, log)
+	}
+
+	fmt.Fprintf(out, "  %s Syncing project to %s:%s...%s\n", colors.Yellow, host.Destination(), work, colors.Reset)
+	mkdir := host.Command("mkdir -p " + remote.Quote(work+"/src"))
+	mkdir.Stderr = tee(out)
+	if err := run("mkdir", mkdir); err != nil {
+		return err
+	}
+	push := host.Push(projectRoot, work+"/src", sshExcludes...)
+	push.Stderr = tee(out)
+	if err := run("rsync", push); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "  %s Building on %s...%s\n", colors.Cyan, host.Destination(), colors.Reset)
+	buildCmd := host.Command(sshBuildScript(tc, runner, projectRoot, work, projectType, options.RunTests, options.RunBenchmarks))
+	if output := tee(out); options.Verbose {
+		buildCmd.Stdout, buildCmd.Stderr = output, output
+	} else {
+		buildCmd.Stdout, buildCmd.Stderr = log, output
+	}
+	if err := run("build", buildCmd); err != nil {
+		return err
+	}
+
+	localOut, err := filepath.Abs(filepath.Join(outputDir, tc.Name))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for output directory: %w", err)
+	}
+	if err := os.MkdirAll(localOut, 0755); err != nil {
+		return fmt.Errorf("failed to create target output directory: %w", err)
+	}
+	fmt.Fprintf(out, "  %s Copying artifacts...%s\n", colors.Yellow, colors.Reset)
+	pull := host.Pull(work+"/out/"+tc.Name, localOut)
+	pull.Stderr = tee(out)
+	if err := run("rsync", pull); err != nil {
+		return err
+	}
+
+	if tc.Hardening {
+		if err := build.FprintHardeningAudit(out, localOut); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sshBuildScript returns the bash script that builds a toolchain on an SSH
+// host. It runs from work, which holds src/, build/<toolchain>/ and out/<toolchain>/.
+func sshBuildScript(tc config.Toolchain, runner *config.Runner, projectRoot, work string, projectType ProjectType, runTests, runBenchmarks bool) string {
+	buildDir := "build/" + tc.Name
+	outDir := "out/" + tc.Name
+
+	var s strings.Builder
+	s.WriteString("set -e\n")
+	fmt.Fprintf(&s, "cd %s\n", remote.Quote(work))
+
+	env := make(map[string]string, len(tc.Env)+2)
+	for k, v := range tc.Env {
+		env[k] = v
+	}
+	if runner.CC != "" {
+		env["CC"] = runner.CC
+	}
+	if runner.CXX != "" {
+		env["CXX"] = runner.CXX
+	}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&s, "export %s=%s\n", k, remote.Quote(env[k]))
+	}
+
+	var copyArtifacts string
+	switch projectType {
+	case ProjectTypeBazel:
+		bazelConfig := "release"
+		if strings.EqualFold(tc.BuildType, "Debug") {
+			bazelConfig = "debug"
+		}
+		args := []string{"bazel", "build", "--config=" + bazelConfig}
+		if tc.Jobs > 0 {
+			args = append(args, fmt.Sprintf("--jobs=%d", tc.Jobs))
+		}
+		args = append(args, tc.BuildOptions...)
+		fmt.Fprintf(&s, "(cd src && %s //...)\n", remote.QuoteAll(args))
+		if runTests {
+			s.WriteString("(cd src && bazel test --config=debug --test_output=errors //...)\n")
+		}
+		if runBenchmarks {
+			s.WriteString("(cd src && bazel run --config=release //bench/...)\n")
+		}
+		copyArtifacts = fmt.Sprintf(`find -L src/bazel-bin/ -maxdepth 3 -type f -perm -u+x ! -name "*.sh" ! -name "*.params" ! -path "*.runfiles*" -exec cp {} %s/ \;`, remote.Quote(outDir))
+
+	case ProjectTypeMeson:
+		buildType := "release"
+		if strings.EqualFold(tc.BuildType, "Debug") {
+			buildType = "debug"
+		}
+		setup := []string{"meson", "setup", buildDir, "src", "--buildtype=" + buildType}
+		if tc.Optimization != "" {
+			setup = append(setup, "-Doptimization="+tc.Optimization)
+		}
+		fmt.Fprintf(&s, "[ -f %s/build.ninja ] || %s\n", remote.Quote(buildDir), remote.QuoteAll(setup))
+		compile := []string{"meson", "compile", "-C", buildDir}
+		if tc.Jobs > 0 {
+			compile = append(compile, "-j", fmt.Sprintf("%d", tc.Jobs))
+		}
+		fmt.Fprintf(&s, "%s\n", remote.QuoteAll(append(compile, tc.BuildOptions...)))
+		if runTests {
+			fmt.Fprintf(&s, "meson test -C %s\n", remote.Quote(buildDir))
+		}
+		if runBenchmarks {
+			fmt.Fprintf(&s, "meson test -C %s --benchmark\n", remote.Quote(buildDir))
+		}
+		copyArtifacts = fmt.Sprintf(`find %s -maxdepth 3 -type f \( -perm -u+x -o -name "*.a" -o -name "*.so" -o -name "*.dylib" \) ! -path "*/meson-*" ! -name "*.p" -exec cp {} %s/ \;`, remote.Quote(buildDir), remote.Quote(outDir))
+
+	default:
+		configure := remote.QuoteAll(append([]string{"cmake"}, nativeCMakeArgs(tc, runner, "src", buildDir, runTests, runBenchmarks)...))
+		if projectType == ProjectTypeVcpkg && runner.CMakeToolchainFile == "" {
+			// Uses the host's vcpkg when VCPKG_ROOT is set in its login environment or the toolchain env
+			configure += ` ${VCPKG_ROOT:+"-DCMAKE_TOOLCHAIN_FILE=$VCPKG_ROOT/scripts/buildsystems/vcpkg.cmake"}`
+		}
+		fmt.Fprintf(&s, "%s\n", configure)
+		fmt.Fprintf(&s, "%s\n", remote.QuoteAll(append([]string{"cmake"}, nativeBuildArgs(tc, projectRoot, buildDir, runBenchmarks)...)))
+		if runTests {
+			fmt.Fprintf(&s, "ctest --test-dir %s --output-on-failure\n", remote.Quote(buildDir))
+		}
+		if runBenchmarks {
+			fmt.Fprintf(&s, "for b in $(find %s -maxdepth 2 -type f -perm -u+x -name \"*_bench\"); do \"$b\"; done\n", remote.Quote(buildDir))
+		}
+		copyArtifacts = fmt.Sprintf(`find %s -maxdepth 2 -type f \( -perm -u+x -o -name "lib*.a" -o -name "lib*.so" -o -name "lib*.dylib" \) ! -path "*/CMakeFiles/*" ! -name "*.sh" ! -name "*.cmake" -exec cp {} %s/ \;`, remote.Quote(buildDir), remote.Quote(outDir))
+	}
+
+	fmt.Fprintf(&s, "rm -rf %[1]s && mkdir -p %[1]s\n", remote.Quote(outDir))
+	s.WriteString(copyArtifacts + "\n")
+	return s.String()
+}
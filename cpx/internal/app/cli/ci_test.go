@@ -1,13 +1,31 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/coverage"
+	"github.com/ozacod/cpx/internal/pkg/packaging"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
 	"github.com/ozacod/cpx/pkg/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestSaveToolchainConfig(t *testing.T) {
@@ -145,3 +163,1542 @@ func TestRunnerTypes(t *testing.T) {
 	assert.False(t, sshRunner.IsNative())
 	assert.False(t, sshRunner.IsDocker())
 }
+
+func TestDescribeRunnerPlatform(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// The image decides, so the host's platform is not reported as native
+	runner := &config.Runner{Name: "ubuntu", Type: "docker", Image: "ubuntu:22.04"}
+	assert.Equal(t, "docker, image platform", describeRunner(runner))
+
+	runner.Platform = "linux/riscv64"
+	assert.Equal(t, "docker, linux/riscv64, emulated", describeRunner(runner))
+
+	runner = &config.Runner{Name: "remote", Type: "docker", Image: "ubuntu:22.04", DockerHost: "ssh://builder"}
+	assert.Equal(t, "docker@ssh://builder, engine platform", describeRunner(runner))
+}
+
+func TestExplainCommandsNative(t *testing.T) {
+	tmpDir := t.TempDir()
+	tc := config.Toolchain{
+		Name:         "native-debug",
+		BuildType:    "Debug",
+		Jobs:         4,
+		CMakeOptions: []string{"-DFOO=ON"},
+	}
+
+	lines := explainCommands(tc, nil, nil, tmpDir, filepath.Join(tmpDir, "cache"), ".bin/ci", true, false)
+	require.Len(t, lines, 2)
+
+	buildDir := filepath.Join(tmpDir, "cache", "native-debug")
+	assert.Contains(t, lines[0], "-B "+buildDir)
+	assert.Contains(t, lines[0], "-DCMAKE_BUILD_TYPE=Debug")
+	assert.Contains(t, lines[0], "-DCMAKE_CXX_FLAGS=-O2")
+	assert.Contains(t, lines[0], "-DBUILD_TESTING=ON")
+	assert.True(t, strings.HasSuffix(lines[0], "-DFOO=ON"))
+	assert.Equal(t, "cmake --build "+buildDir+" --config Debug --parallel 4", lines[1])
+}
+
+func TestSelectToolchains(t *testing.T) {
+	inactive := false
+	all := []config.Toolchain{
+		{Name: "a"},
+		{Name: "b", Active: &inactive},
+		{Name: "c"},
+	}
+
+	active, err := selectToolchains(all, "")
+	require.NoError(t, err)
+	assert.Len(t, active, 2)
+
+	named, err := selectToolchains(all, "b")
+	require.NoError(t, err)
+	require.Len(t, named, 1)
+	assert.Equal(t, "b", named[0].Name)
+
+	_, err = selectToolchains(all, "missing")
+	assert.ErrorContains(t, err, "not found")
+
+	_, err = selectToolchains([]config.Toolchain{{Name: "x", Active: &inactive}}, "")
+	assert.ErrorContains(t, err, "no active toolchains")
+}
+
+func TestFilterToolchainsByTags(t *testing.T) {
+	all := []config.Toolchain{
+		{Name: "linux", Tags: []string{"release", "linux"}},
+		{Name: "arm", Tags: []string{"release", "nightly"}},
+		{Name: "debug"},
+	}
+
+	unfiltered, err := filterToolchainsByTags(all, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, unfiltered, 3)
+
+	release, err := filterToolchainsByTags(all, []string{"release"}, []string{"nightly"})
+	require.NoError(t, err)
+	require.Len(t, release, 1)
+	assert.Equal(t, "linux", release[0].Name)
+
+	excluded, err := filterToolchainsByTags(all, nil, []string{"release"})
+	require.NoError(t, err)
+	require.Len(t, excluded, 1)
+	assert.Equal(t, "debug", excluded[0].Name)
+
+	_, err = filterToolchainsByTags(all, []string{"missing"}, nil)
+	assert.ErrorContains(t, err, "no toolchains match")
+}
+
+func TestBuilderImageTag(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "Dockerfile"), []byte("FROM gcc:13\n"), 0644))
+
+	runner := &config.Runner{Name: "gcc", Type: "docker", Image: "registry:5000/cpx/gcc:latest",
+		Build: &config.RunnerBuild{Args: map[string]string{"GCC_VER": "13"}}}
+	tag, err := builderImageTag(tmpDir, runner)
+	require.NoError(t, err)
+	assert.Regexp(t, `^registry:5000/cpx/gcc:[0-9a-f]{12}$`, tag)
+
+	// Same inputs, same tag
+	again, err := builderImageTag(tmpDir, runner)
+	require.NoError(t, err)
+	assert.Equal(t, tag, again)
+
+	// Build args are part of the hash
+	runner.Build.Args["GCC_VER"] = "14"
+	changed, err := builderImageTag(tmpDir, runner)
+	require.NoError(t, err)
+	assert.NotEqual(t, tag, changed)
+
+	// Without an image the runner name is the repository
+	unnamed := &config.Runner{Name: "Clang", Type: "docker", Build: &config.RunnerBuild{}}
+	tag, err = builderImageTag(tmpDir, unnamed)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(tag, "cpx-clang:"))
+
+	// Pushing needs a repository in a registry, not the cpx-<name> fallback
+	unnamed.Build.Push = true
+	err = pullOrBuildRunnerImage(docker.Endpoint{}, tmpDir, unnamed, tag, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no image repository to push to")
+}
+
+func TestGenerateBakeFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "docker"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "docker", "Dockerfile.gcc"), []byte("FROM gcc:13\n"), 0644))
+
+	runners := []config.Runner{
+		{Name: "pulled", Type: "docker", Image: "ubuntu:22.04"},
+		{Name: "gcc.13", Type: "docker", Image: "cpx-gcc", Platform: "linux/arm64",
+			Build: &config.RunnerBuild{Dockerfile: "docker/Dockerfile.gcc", Args: map[string]string{"GCC_VER": "13"}}},
+	}
+	content, err := generateBakeFile(tmpDir, filepath.Join(tmpDir, ".cache", "ci"), runners)
+	require.NoError(t, err)
+
+	assert.Contains(t, content, `targets = ["gcc-13"]`)
+	assert.Contains(t, content, `target "gcc-13" {`)
+	assert.Contains(t, content, `dockerfile = "docker/Dockerfile.gcc"`)
+	assert.Contains(t, content, `platforms  = ["linux/arm64"]`)
+	assert.Contains(t, content, `GCC_VER = "13"`)
+	assert.Contains(t, content, `cache-to   = ["type=local,dest=.cache/ci/buildx,mode=max"]`)
+	assert.Contains(t, content, `labels     = { "dev.cpx.project" = "`+projectID(tmpDir)+`" }`)
+	assert.NotContains(t, content, "pulled")
+
+	// Registry caches are read after the local one and replace it as the export
+	runners[1].Build.CacheFrom = []string{"type=registry,ref=ghcr.io/acme/cpx-gcc:cache"}
+	runners[1].Build.CacheTo = []string{"type=registry,ref=ghcr.io/acme/cpx-gcc:cache,mode=max"}
+	runners[1].Network = "host"
+	content, err = generateBakeFile(tmpDir, filepath.Join(tmpDir, ".cache", "ci"), runners)
+	require.NoError(t, err)
+	assert.Contains(t, content, `cache-from = ["type=local,src=.cache/ci/buildx", "type=registry,ref=ghcr.io/acme/cpx-gcc:cache"]`)
+	assert.Contains(t, content, `cache-to   = ["type=registry,ref=ghcr.io/acme/cpx-gcc:cache,mode=max"]`)
+
+	args, _, err := runnerImageBuildArgs(tmpDir, &runners[1], "cpx-gcc:tag", false)
+	require.NoError(t, err)
+	assert.Subset(t, args, []string{"--cache-from", "type=registry,ref=ghcr.io/acme/cpx-gcc:cache", "--cache-to", "--load", "--network", "host"})
+	assert.Subset(t, args, []string{"--label", "dev.cpx.project=" + projectID(tmpDir)}, "prune only removes the project's images")
+	assert.NotEqual(t, projectID(tmpDir), projectID(t.TempDir()))
+	assert.Contains(t, content, `network    = "host"`)
+
+	_, err = generateBakeFile(tmpDir, tmpDir, runners[:1])
+	assert.ErrorContains(t, err, "no Docker runners")
+
+	clash := config.Runner{Name: "gcc 13", Type: "docker", Image: "cpx-gcc2", Build: &config.RunnerBuild{Dockerfile: "docker/Dockerfile.gcc"}}
+	_, err = generateBakeFile(tmpDir, tmpDir, append(runners, clash))
+	assert.ErrorContains(t, err, "runners 'gcc.13' and 'gcc 13' both become bake target 'gcc-13'")
+}
+
+func TestTargetRunner(t *testing.T) {
+	runner := &config.Runner{Name: "win", Type: "docker", Target: "windows-amd64"}
+	image, err := runnerImage(t.TempDir(), runner)
+	require.NoError(t, err)
+	assert.Regexp(t, `^cpx-windows-amd64:[0-9a-f]{12}$`, image)
+	assert.True(t, runnerBuildsImage(runner))
+
+	tc := config.Toolchain{Name: "windows-release", CMakeOptions: []string{"-DFOO=ON"}, Hardening: true}
+	opts := toolchainDockerOptions(tc, runner, docker.Endpoint{}, image, "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.Equal(t, "x86_64-w64-mingw32-g++", opts.Env["CXX"])
+	assert.Equal(t, "x64-mingw-static", opts.Triplet)
+	assert.Equal(t, "windows-amd64", opts.CrossTarget)
+	assert.Contains(t, opts.CMakeArgs, "-DCMAKE_SYSTEM_NAME=Windows")
+	assert.Equal(t, "-DFOO=ON", opts.CMakeArgs[len(opts.CMakeArgs)-1])
+	assert.Contains(t, opts.ArtifactPatterns, "*.exe")
+	assert.False(t, opts.Hardening)
+
+	// The toolchain's triplet wins over the preset's, for the prefetch too
+	own := config.Toolchain{Name: "windows-dynamic", Env: map[string]string{"VCPKG_DEFAULT_TRIPLET": "x64-mingw-dynamic"}}
+	opts = toolchainDockerOptions(own, runner, docker.Endpoint{}, image, "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.Equal(t, "x64-mingw-dynamic", opts.Triplet)
+	own.CMakeOptions = []string{"-DVCPKG_TARGET_TRIPLET:STRING=x64-mingw-release", "-DVCPKG_TARGET_TRIPLET_EXTRA=ignored"}
+	opts = toolchainDockerOptions(own, runner, docker.Endpoint{}, image, "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.Equal(t, "x64-mingw-release", opts.Triplet)
+
+	// A runner's own image and compilers win over the preset's
+	runner = &config.Runner{Name: "win", Type: "docker", Target: "windows-amd64", Image: "my/mingw:1", CXX: "g++-win"}
+	image, err = runnerImage(t.TempDir(), runner)
+	require.NoError(t, err)
+	assert.Equal(t, "my/mingw:1", image)
+	assert.False(t, runnerBuildsImage(runner))
+	_, cxx := runnerCompilers(runner)
+	assert.Equal(t, "g++-win", cxx)
+
+	// A build section without a Dockerfile passes its args to the preset's
+	mac := &config.Runner{Name: "mac", Type: "docker", Target: "darwin-arm64",
+		Build: &config.RunnerBuild{Args: map[string]string{"MACOS_SDK_URL": "https://example.com/MacOSX14.0.sdk.tar.xz"}}}
+	image, err = runnerImage(t.TempDir(), mac)
+	require.NoError(t, err)
+	assert.Regexp(t, `^cpx-darwin:[0-9a-f]{12}$`, image)
+	assert.NotNil(t, presetDockerfile(mac))
+	mac.Build.Args["MACOS_SDK_URL"] = "https://example.com/MacOSX15.0.sdk.tar.xz"
+	changed, err := runnerImage(t.TempDir(), mac)
+	require.NoError(t, err)
+	assert.NotEqual(t, image, changed)
+
+	// Emulated presets bring their platform, which the image tag and build use
+	riscv := &config.Runner{Name: "riscv", Type: "docker", Target: "linux-riscv64"}
+	assert.Equal(t, "linux/riscv64", runnerPlatform(riscv))
+	opts = toolchainDockerOptions(tc, riscv, docker.Endpoint{}, "img", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.Equal(t, "linux/riscv64", opts.Platform)
+	assert.True(t, opts.Hardening)
+	riscv.Platform = "linux/arm64"
+	assert.Equal(t, "linux/arm64", runnerPlatform(riscv))
+
+	// Static presets skip the (PIE-based) hardening and verify the linkage
+	musl := &config.Runner{Name: "musl", Type: "docker", Target: "linux-amd64-musl-static"}
+	opts = toolchainDockerOptions(tc, musl, docker.Endpoint{}, "img", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.Equal(t, "x64-linux-musl", opts.Triplet)
+	assert.Contains(t, opts.CMakeArgs, "-DCMAKE_EXE_LINKER_FLAGS=-static")
+	assert.True(t, opts.VerifyStatic)
+	assert.False(t, opts.Hardening)
+
+	_, err = runnerImage(t.TempDir(), &config.Runner{Name: "bad", Type: "docker", Target: "plan9-386"})
+	assert.ErrorContains(t, err, "unknown target")
+
+	content, err := generateBakeFile(t.TempDir(), t.TempDir(), []config.Runner{{Name: "win", Type: "docker", Target: "windows-amd64"}})
+	require.NoError(t, err)
+	assert.Contains(t, content, "dockerfile-inline = <<EOT")
+	assert.Contains(t, content, `$${VCPKG_ROOT}`)
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	w := &prefixWriter{mu: &mu, out: &out, prefix: "[a] "}
+
+	_, err := w.Write([]byte("one\ntw"))
+	require.NoError(t, err)
+	assert.Equal(t, "[a] one\n", out.String())
+
+	_, err = w.Write([]byte("o\r\nthree"))
+	require.NoError(t, err)
+	w.Flush()
+	assert.Equal(t, "[a] one\n[a] two\n[a] three\n", out.String())
+}
+
+func TestSSHBuildScript(t *testing.T) {
+	runner := &config.Runner{Name: "pi", Type: "ssh", Host: "pi.local", CXX: "g++-12"}
+	tc := config.Toolchain{Name: "arm", BuildType: "Release", Jobs: 4, Env: map[string]string{"FLAGS": "-g -O1"}}
+
+	script := sshBuildScript(tc, runner, "/src/proj", ".cache/cpx/proj", ProjectTypeVcpkg, true, false)
+	assert.Contains(t, script, "cd .cache/cpx/proj\n")
+	assert.Contains(t, script, "export CXX=g++-12\n")
+	assert.Contains(t, script, "export FLAGS='-g -O1'\n")
+	assert.Contains(t, script, "cmake -GNinja -B build/arm -S src -DCMAKE_BUILD_TYPE=Release")
+	assert.Contains(t, script, `${VCPKG_ROOT:+"-DCMAKE_TOOLCHAIN_FILE=$VCPKG_ROOT/scripts/buildsystems/vcpkg.cmake"}`)
+	assert.Contains(t, script, "cmake --build build/arm --config Release --parallel 4\n")
+	assert.Contains(t, script, "ctest --test-dir build/arm --output-on-failure\n")
+	assert.Contains(t, script, "rm -rf out/arm && mkdir -p out/arm\n")
+
+	meson := sshBuildScript(tc, runner, "/src/proj", "w", ProjectTypeMeson, false, false)
+	assert.Contains(t, meson, "[ -f build/arm/build.ninja ] || meson setup build/arm src --buildtype=release\n")
+	assert.Contains(t, meson, "meson compile -C build/arm -j 4\n")
+
+	bazel := sshBuildScript(tc, runner, "/src/proj", "w", ProjectTypeBazel, false, false)
+	assert.Contains(t, bazel, "(cd src && bazel build --config=release --jobs=4 //...)\n")
+
+	assert.Equal(t, "builds/proj", sshWorkDir("/src/proj", &config.Runner{WorkDir: "~/builds/proj/"}))
+	assert.Equal(t, ".cache/cpx/proj", sshWorkDir("/src/proj", runner))
+}
+
+func TestCompilerCache(t *testing.T) {
+	runner := &config.Runner{Name: "gcc", Type: "docker", Image: "gcc:13"}
+	tc := config.Toolchain{Name: "linux"}
+
+	opts := toolchainDockerOptions(tc, runner, docker.Endpoint{}, "gcc:13", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.False(t, opts.CCache)
+	assert.Nil(t, opts.SCCache)
+
+	opts = toolchainDockerOptions(tc, runner, docker.Endpoint{}, "gcc:13", "/src", "/cache", ".bin/ci",
+		ToolchainBuildOptions{Cache: &config.CacheConfig{Backend: "ccache"}})
+	assert.True(t, opts.CCache)
+
+	cache := &config.CacheConfig{Backend: "sccache", S3: &config.CacheS3{Bucket: "ci-cache", Region: "eu-west-1"}}
+	opts = toolchainDockerOptions(tc, runner, docker.Endpoint{}, "gcc:13", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{Cache: cache})
+	require.NotNil(t, opts.SCCache)
+	assert.Equal(t, map[string]string{"SCCACHE_BUCKET": "ci-cache", "SCCACHE_REGION": "eu-west-1"}, opts.SCCache.Env)
+	assert.Equal(t, "sccache (s3://ci-cache)", describeCompilerCache(tc, cache))
+
+	// Only credentials set on the host are forwarded, by name
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIA")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	assert.Equal(t, []string{"-e", "AWS_ACCESS_KEY_ID", "-e", "AWS_SECRET_ACCESS_KEY"}, opts.EnvArgs())
+
+	gcs := sccacheOptions(&config.CacheConfig{Backend: "sccache", GCS: &config.CacheGCS{Bucket: "ci"}})
+	assert.Equal(t, "READ_WRITE", gcs.Env["SCCACHE_GCS_RW_MODE"])
+	assert.Equal(t, []string{"CPX_GCS_KEY"}, gcs.PassEnv)
+}
+
+func TestBinarySources(t *testing.T) {
+	runner := &config.Runner{Name: "gcc", Type: "docker", Image: "gcc:13"}
+	vcpkg := &config.VcpkgConfig{BinarySources: []config.VcpkgBinarySource{
+		{Type: "gha"},
+		{Type: "http", URL: "https://cache.example.com/{sha}.zip", Header: "Authorization: Bearer $CACHE_TOKEN", Env: []string{"CACHE_TOKEN"}},
+	}}
+	opts := toolchainDockerOptions(config.Toolchain{Name: "linux"}, runner, docker.Endpoint{}, "gcc:13", "/src", "/cache", ".bin/ci",
+		ToolchainBuildOptions{Vcpkg: vcpkg})
+	require.Len(t, opts.BinarySources, 2)
+	assert.Equal(t, "gha", opts.BinarySources[0].Type)
+	assert.Equal(t, "Authorization: Bearer $CACHE_TOKEN", opts.BinarySources[1].Header)
+	assert.Equal(t, []string{"ACTIONS_CACHE_URL", "ACTIONS_RUNTIME_TOKEN", "CACHE_TOKEN"}, opts.PassEnv)
+}
+
+func TestArtifactKey(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.cpp"), []byte("int main() {}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "vcpkg.json"), []byte(`{"dependencies": []}`), 0644))
+	tc := config.Toolchain{Name: "linux", BuildType: "Release", EnvPassthrough: []string{"CPX_TEST_FLAVOR"}}
+	runner := &config.Runner{Name: "gcc", Type: "docker", Image: "gcc:13"}
+	opts := build.DockerBuildOptions{ProjectRoot: root, OutputDir: "out", CacheDir: filepath.Join(root, "ci-cache")}
+	t.Setenv("CPX_TEST_FLAVOR", "a")
+
+	key, err := artifactKey(opts, tc, runner, "sha256:1")
+	require.NoError(t, err)
+
+	// Caches, outputs and configured build directories are not sources
+	write := func(dir, name string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, dir, name), []byte("generated"), 0644))
+	}
+	write(filepath.Join(".cache", "ci"), "x.o")
+	write(filepath.Join("out", "linux"), "app")
+	write(filepath.Join("ci-cache", "linux"), "x.o")
+	write("build", "CMakeCache.txt")
+	write("build", "app")
+	same, err := artifactKey(opts, tc, runner, "sha256:1")
+	require.NoError(t, err)
+	assert.Equal(t, key, same)
+
+	// The image, the configuration, the passed-through variables and the sources are
+	other, err := artifactKey(opts, tc, runner, "sha256:2")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+	other, err = artifactKey(opts, config.Toolchain{Name: "linux", BuildType: "Debug", EnvPassthrough: tc.EnvPassthrough}, runner, "sha256:1")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+	t.Setenv("CPX_TEST_FLAVOR", "b")
+	other, err = artifactKey(opts, tc, runner, "sha256:1")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+	t.Setenv("CPX_TEST_FLAVOR", "a")
+	require.NoError(t, os.WriteFile(filepath.Join(root, "vcpkg.json"), []byte(`{"dependencies": ["fmt"]}`), 0644))
+	other, err = artifactKey(opts, tc, runner, "sha256:1")
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestCopyTree(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "app"), []byte("bin"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "lib", "libfoo.a"), []byte("ar"), 0644))
+
+	dst := filepath.Join(t.TempDir(), "out")
+	require.NoError(t, copyTree(src, dst))
+	info, err := os.Stat(filepath.Join(dst, "app"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	data, err := os.ReadFile(filepath.Join(dst, "lib", "libfoo.a"))
+	require.NoError(t, err)
+	assert.Equal(t, "ar", string(data))
+}
+
+func TestNameArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app"), []byte("bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "libfoo.a"), []byte("ar"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "test-results"), 0755))
+
+	tc := config.Toolchain{Name: "linux", ArtifactName: "{{name}}-{{version}}-{{target}}{{ext}}"}
+	require.NoError(t, nameArtifacts(tc, dir, "1.0.0"))
+	assert.FileExists(t, filepath.Join(dir, "app-1.0.0-linux"))
+	assert.FileExists(t, filepath.Join(dir, "libfoo-1.0.0-linux.a"))
+	assert.DirExists(t, filepath.Join(dir, "test-results"))
+	assert.NoFileExists(t, filepath.Join(dir, "app"))
+
+	clash := config.Toolchain{Name: "linux", ArtifactName: "{{target}}{{ext}}"}
+	dir = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app"), []byte("bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), []byte("bin"), 0755))
+	assert.ErrorContains(t, nameArtifacts(clash, dir, "1.0.0"), "the same name")
+}
+
+func TestChecksumManifest(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "linux", "test-results"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux", "app"), []byte("bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux", "test-results", "ctest.xml"), []byte("<xml/>"), 0644))
+
+	count, err := writeManifest(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	manifest, err := os.ReadFile(filepath.Join(dir, checksumsFile))
+	require.NoError(t, err)
+	// sha256sum -c compatible
+	assert.Contains(t, string(manifest), "51a1f05af85e342e3c849b47d387086476282d5f50dc240c19216d6edfb1eb5a  linux/app\n")
+
+	check, err := verifyManifest(filepath.Join(dir, checksumsFile))
+	require.NoError(t, err)
+	assert.Equal(t, 2, check.OK)
+	assert.Empty(t, check.Failed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux", "app"), []byte("tampered"), 0755))
+	require.NoError(t, os.Remove(filepath.Join(dir, "linux", "test-results", "ctest.xml")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("new"), 0644))
+	check, err = verifyManifest(filepath.Join(dir, checksumsFile))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"linux/app"}, check.Failed)
+	assert.Equal(t, []string{"linux/test-results/ctest.xml"}, check.Missing)
+	assert.Equal(t, []string{"notes.txt"}, check.Unlisted)
+}
+
+func TestFilterChangedToolchains(t *testing.T) {
+	toolchains := []config.Toolchain{
+		{Name: "all"},
+		{Name: "server", Paths: []string{"apps/server/**", "lib/**"}},
+		{Name: "docs", Paths: []string{"docs/**"}},
+	}
+	names := func(tcs []config.Toolchain) []string {
+		var out []string
+		for _, tc := range tcs {
+			out = append(out, tc.Name)
+		}
+		return out
+	}
+
+	// Only docs changed: toolchains without paths skip, docs watches them
+	affected, skipped := filterChangedToolchains(toolchains, []string{"docs/index.md", "README.md"}, defaultChangesIgnore)
+	assert.Equal(t, []string{"docs"}, names(affected))
+	assert.Equal(t, []string{"all", "server"}, skipped)
+
+	affected, _ = filterChangedToolchains(toolchains, []string{"lib/util.cpp"}, defaultChangesIgnore)
+	assert.Equal(t, []string{"all", "server"}, names(affected))
+
+	affected, _ = filterChangedToolchains(toolchains, []string{"apps/client/main.cpp"}, defaultChangesIgnore)
+	assert.Equal(t, []string{"all"}, names(affected))
+
+	affected, skipped = filterChangedToolchains(toolchains, nil, defaultChangesIgnore)
+	assert.Empty(t, affected)
+	assert.Len(t, skipped, 3)
+
+	// cpx-ci.yaml changes rebuild everything
+	affected, _ = filterChangedToolchains(toolchains, []string{"cpx-ci.yaml"}, defaultChangesIgnore)
+	assert.Len(t, affected, 3)
+
+	// Toolchains depending on an affected one rebuild even if their paths did not change
+	toolchains = append(toolchains,
+		config.Toolchain{Name: "server-image", Paths: []string{"deploy/**"}, DependsOn: []string{"server"}},
+		config.Toolchain{Name: "server-bundle", Paths: []string{"bundle/**"}, DependsOn: []string{"server-image"}})
+	affected, skipped = filterChangedToolchains(toolchains, []string{"apps/server/main.cpp"}, defaultChangesIgnore)
+	assert.Equal(t, []string{"all", "server", "server-image", "server-bundle"}, names(affected))
+	assert.Equal(t, []string{"docs"}, skipped)
+}
+
+func TestDryRunNative(t *testing.T) {
+	tc := config.Toolchain{Name: "native", BuildType: "Debug", Env: map[string]string{"FOO": "a b"}}
+	runner := &config.Runner{Name: "local", Type: "native", CC: "clang"}
+
+	var out bytes.Buffer
+	require.NoError(t, dryRunNative(&out, tc, runner, "/src", "/cache", ToolchainBuildOptions{}))
+	assert.Contains(t, out.String(), "CC=clang 'FOO=a b' cmake \\\n    -GNinja")
+	assert.Contains(t, out.String(), "-B /cache/native")
+	assert.Contains(t, out.String(), "--build /cache/native \\\n    --config Debug")
+}
+
+func TestBuildReport(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "linux", "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux", "app"), nil, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux", "lib", "libcore.a"), nil, 0644))
+	assert.Equal(t, []string{
+		filepath.ToSlash(filepath.Join(dir, "linux", "app")),
+		filepath.ToSlash(filepath.Join(dir, "linux", "lib", "libcore.a")),
+	}, listArtifacts(filepath.Join(dir, "linux")))
+	assert.Empty(t, listArtifacts(filepath.Join(dir, "missing")))
+
+	buildErr := fmt.Errorf("failed to build 'arm': docker run failed")
+	report := newBuildReport([]toolchainResult{
+		{Name: "linux", Runner: "ubuntu", Image: "cpx-linux:latest", Duration: 1500 * time.Millisecond, Artifacts: []string{".bin/ci/linux/app"}},
+		{Name: "arm", Runner: "pi", Err: buildErr},
+		{Name: "wasm", Runner: "emsdk", Skipped: true},
+	}, buildErr)
+
+	var out bytes.Buffer
+	require.NoError(t, writeBuildReport(&out, report))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, false, decoded["success"])
+	assert.Equal(t, buildErr.Error(), decoded["error"])
+
+	toolchains := decoded["toolchains"].([]any)
+	require.Len(t, toolchains, 3)
+	linux := toolchains[0].(map[string]any)
+	assert.Equal(t, "success", linux["status"])
+	assert.Equal(t, 1.5, linux["duration_seconds"])
+	assert.Equal(t, "cpx-linux:latest", linux["image"])
+	assert.Equal(t, []any{".bin/ci/linux/app"}, linux["artifacts"])
+	assert.Equal(t, "failed", toolchains[1].(map[string]any)["status"])
+	assert.Equal(t, "skipped", toolchains[2].(map[string]any)["status"])
+	assert.Equal(t, []any{}, toolchains[2].(map[string]any)["artifacts"])
+}
+
+func TestReadPhases(t *testing.T) {
+	dir := t.TempDir()
+	// As written by cpx_phase; EPOCHREALTIME may use a decimal comma
+	data := "configure 100.000000\nbuild 101,500000\ncopy 131.5\nend 132.0\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, build.PhaseFile), []byte(data), 0644))
+	assert.Equal(t, []build.Phase{
+		{Name: "configure", Duration: 1500 * time.Millisecond},
+		{Name: "build", Duration: 30 * time.Second},
+		{Name: "copy", Duration: 500 * time.Millisecond},
+	}, build.ReadPhases(dir))
+
+	// A failed build never closes its last phase
+	build.StartPhases(dir)
+	build.RecordPhase(dir, "configure")
+	build.RecordPhase(dir, "build")
+	phases := build.ReadPhases(dir)
+	require.Len(t, phases, 1)
+	assert.Equal(t, "configure", phases[0].Name)
+
+	assert.Empty(t, build.ReadPhases(filepath.Join(dir, "missing")))
+}
+
+func TestPrintToolchainSummary(t *testing.T) {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app")
+	require.NoError(t, os.WriteFile(app, make([]byte, 3*1024*1024), 0755))
+
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	failed := printToolchainSummary([]toolchainResult{
+		{Name: "linux", Duration: 42 * time.Second, Cache: cacheMiss, Artifacts: []string{app}, Phases: []build.Phase{
+			{Name: "configure", Duration: 2 * time.Second}, {Name: "build", Duration: 39 * time.Second}, {Name: "copy", Duration: time.Second},
+		}},
+		{Name: "linux-cached", Duration: time.Second, Cache: cacheHit, Artifacts: []string{app}},
+		{Name: "arm", Duration: 3 * time.Second, Err: fmt.Errorf("failed to build 'arm': docker run failed\ndetails")},
+		{Name: "wasm", Skipped: true},
+	})
+	require.NoError(t, w.Close())
+	os.Stdout = old
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(r)
+	require.NoError(t, err)
+	output := buf.String()
+
+	assert.Equal(t, 1, failed)
+	assert.Contains(t, output, "TOOLCHAIN   "+colors.Reset+"  STATUS   TIME  CONFIGURE  BUILD  COPY  CACHE  ARTIFACTS\n")
+	assert.Contains(t, output, "✓ linux       "+colors.Reset+"  success  42s   2s         39s    1s    miss   1 (3.0 MB)\n")
+	assert.Contains(t, output, "✓ linux-cached"+colors.Reset+"  success  1s    -          -      -     hit    1 (3.0 MB)\n")
+	assert.Contains(t, output, "✗ arm         "+colors.Reset+"  failed   3s    -          -      -     -      -\n")
+	assert.Contains(t, output, "✗ arm: failed to build 'arm': docker run failed")
+	assert.NotContains(t, output, "details")
+	assert.Contains(t, output, "- wasm")
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KB", formatSize(1536))
+	assert.Equal(t, "12.4 MB", formatSize(13002342))
+	assert.Equal(t, "2.0 GB", formatSize(2*1024*1024*1024))
+}
+
+func TestBuildLog(t *testing.T) {
+	projectRoot := t.TempDir()
+	dir := filepath.Join(projectRoot, buildLogsDir)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for i := range keptBuildLogs + 2 {
+		name := fmt.Sprintf("linux-20260101-1200%02d.log", i)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "linux-arm-20250101-120000.log"), nil, 0644))
+
+	log, err := openBuildLog(projectRoot, "linux", time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC))
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(log, "%s Building...%s\n", colors.Cyan, colors.Reset)
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	assert.Equal(t, filepath.Join(dir, "linux-20261016-093000.log"), log.path)
+	data, err := os.ReadFile(log.path)
+	require.NoError(t, err)
+	assert.Equal(t, " Building...\n", string(data))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	// The oldest logs of linux are pruned; other toolchains keep theirs
+	assert.Len(t, names, keptBuildLogs+1)
+	assert.NotContains(t, names, "linux-20260101-120000.log")
+	assert.NotContains(t, names, "linux-20260101-120001.log")
+	assert.NotContains(t, names, "linux-20260101-120002.log")
+	assert.Contains(t, names, "linux-arm-20250101-120000.log")
+	assert.Contains(t, names, "linux-20261016-093000.log")
+}
+
+func TestDockerBuildStreams(t *testing.T) {
+	var out, log bytes.Buffer
+	opts := build.DockerBuildOptions{Output: &out, Log: &log}
+	assert.Equal(t, " >&2", opts.QuietRedirect())
+	stdout, stderr := opts.Streams()
+	fmt.Fprint(stdout, "status\n")
+	fmt.Fprint(stderr, "main.cpp:3: error: expected ';'\n")
+	assert.Equal(t, "status\nmain.cpp:3: error: expected ';'\n", out.String(), "compiler errors reach the terminal")
+	assert.Equal(t, out.String(), log.String())
+
+	opts.Verbose = true
+	assert.Empty(t, opts.QuietRedirect())
+	stdout, stderr = opts.Streams()
+	assert.Equal(t, stdout, stderr, "verbose streams share one writer")
+
+	assert.Equal(t, " > /dev/null 2>&1", build.DockerBuildOptions{}.QuietRedirect())
+}
+
+func TestShellScript(t *testing.T) {
+	commands := []string{"cmake -B /tmp/build -DX='a b'", "cmake --build /tmp/build"}
+	script := build.ShellScript("export CC=\"gcc\"\n", commands, nil)
+	assert.True(t, strings.HasPrefix(script, "export CC=\"gcc\"\n"))
+	assert.Contains(t, script, `echo '  cmake -B /tmp/build -DX='\''a b'\'''`)
+	assert.Contains(t, script, "echo 'cmake --build /tmp/build' >> ~/.bash_history\n")
+	assert.True(t, strings.HasSuffix(script, "exec bash -i\n"))
+
+	script = build.ShellScript("export CC=\"gcc\"\n", commands, []string{"cmake", "--version"})
+	assert.Equal(t, "export CC=\"gcc\"\nexec cmake --version\n", script)
+
+	assert.Contains(t, build.DockerBuildOptions{Shell: true}.ShellArgs(), "-i")
+	assert.Empty(t, build.DockerBuildOptions{Shell: true, NoStdin: true}.ShellArgs(), "analyzers and hooks don't attach stdin")
+}
+
+func TestCollectTestsScript(t *testing.T) {
+	dir := t.TempDir()
+	script := "set -e\n" + build.CollectTestsScript("exit_code() { return 3; }; exit_code", "touch "+filepath.Join(dir, "collected"))
+	err := exec.Command("bash", "-c", script).Run()
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitCode(), "the tests' exit status is kept")
+	assert.FileExists(t, filepath.Join(dir, "collected"), "results are collected when tests fail")
+
+	assert.Equal(t, "rm -rf /output/linux/test-results && mkdir -p /output/linux/test-results\n", build.ResultsScript("linux", build.TestResultsDir))
+}
+
+func TestReadBenchResults(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sort_bench.json"), []byte(`{
+  "context": {"num_cpus": 8},
+  "benchmarks": [
+    {"name": "BM_Sort/1024", "run_name": "BM_Sort/1024", "run_type": "iteration", "real_time": 12.5, "time_unit": "us"},
+    {"name": "BM_Hash", "run_name": "BM_Hash", "run_type": "iteration", "real_time": 40, "time_unit": "ns"},
+    {"name": "BM_Hash", "run_name": "BM_Hash", "run_type": "iteration", "real_time": 44, "time_unit": "ns"},
+    {"name": "BM_Hash_mean", "run_name": "BM_Hash", "run_type": "aggregate", "aggregate_name": "mean", "real_time": 42, "time_unit": "ns"},
+    {"name": "BM_Hash_stddev", "run_name": "BM_Hash", "run_type": "aggregate", "aggregate_name": "stddev", "real_time": 2, "time_unit": "ns"}
+  ]
+}`), 0644))
+
+	times, err := readBenchResults(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"BM_Sort/1024": 12500, "BM_Hash": 42}, times)
+
+	times, err = readBenchResults(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, times)
+}
+
+func TestBenchComparison(t *testing.T) {
+	table := benchComparison([]string{"linux", "arm"}, map[string]map[string]float64{
+		"linux": {"BM_Sort": 12500, "BM_Hash": 42},
+		"arm":   {"BM_Sort": 25000},
+	})
+	assert.Equal(t, `| Benchmark | linux    | arm              |
+|-----------|----------|------------------|
+| BM_Hash   | 42.00 ns | -                |
+| BM_Sort   | 12.50 µs | 25.00 µs (2.00x) |
+`, table)
+}
+
+func TestCleanPaths(t *testing.T) {
+	root := t.TempDir()
+	cacheDir, outputDir := filepath.Join(root, ".cache", "ci"), filepath.Join(root, ".bin", "ci")
+	for _, dir := range []string{
+		filepath.Join(cacheDir, "linux", "CMakeFiles"),
+		filepath.Join(cacheDir, "linux", vcpkgCacheDirName),
+		filepath.Join(cacheDir, "arm", ".ccache"),
+		filepath.Join(cacheDir, artifactCacheDir),
+		filepath.Join(outputDir, "linux"),
+	} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "linux", "build.ninja"), []byte("rules"), 0644))
+	toolchains := []config.Toolchain{{Name: "linux"}, {Name: "arm"}}
+
+	assert.ElementsMatch(t, []string{
+		filepath.Join(cacheDir, "linux", "CMakeFiles"),
+		filepath.Join(cacheDir, "linux", "build.ninja"),
+		filepath.Join(cacheDir, "arm", ".ccache"),
+		filepath.Join(outputDir, "linux"),
+		filepath.Join(cacheDir, artifactCacheDir),
+	}, cleanPaths(toolchains, cacheDir, outputDir, true, false, false), "vcpkg caches are kept by default")
+
+	assert.ElementsMatch(t, []string{
+		filepath.Join(cacheDir, "linux", "CMakeFiles"),
+		filepath.Join(cacheDir, "linux", "build.ninja"),
+		filepath.Join(cacheDir, "linux", vcpkgCacheDirName),
+		filepath.Join(outputDir, "linux"),
+	}, cleanPaths(toolchains[:1], cacheDir, outputDir, false, false, true))
+
+	assert.Equal(t, []string{cacheDir, outputDir}, cleanPaths(toolchains, cacheDir, outputDir, true, true, true))
+	assert.Equal(t, int64(5), treeSize(cacheDir))
+}
+
+func TestSplitImageRef(t *testing.T) {
+	repo, tag := splitImageRef("registry:5000/team/cpx-arm:0123456789ab")
+	assert.Equal(t, "registry:5000/team/cpx-arm", repo)
+	assert.Equal(t, "0123456789ab", tag)
+	repo, tag = splitImageRef("registry:5000/cpx-arm")
+	assert.Equal(t, "registry:5000/cpx-arm", repo)
+	assert.Empty(t, tag)
+	assert.True(t, imageHashTag.MatchString("0123456789ab"))
+	assert.False(t, imageHashTag.MatchString("latest"))
+}
+
+func TestContainerUser(t *testing.T) {
+	runner := &config.Runner{Name: "gcc", Type: "docker", DockerUser: "1000:100"}
+	assert.Equal(t, "1000:100", containerUser(runner, docker.Endpoint{}))
+	runner.DockerUser = "root"
+	assert.Empty(t, containerUser(runner, docker.Endpoint{}))
+	runner.DockerUser = ""
+	assert.Empty(t, containerUser(runner, docker.Endpoint{Host: "ssh://builder", Remote: true}))
+
+	opts := build.DockerBuildOptions{}
+	assert.Nil(t, opts.UserArgs())
+	assert.Equal(t, "/root", opts.Home())
+	opts.User = "1000:100"
+	assert.Equal(t, []string{"--user", "1000:100", "-e", "HOME=/tmp", "-e", "USER=cpx"}, opts.UserArgs())
+}
+
+func TestToolchainRunArgs(t *testing.T) {
+	runner := &config.Runner{Name: "gcc", Type: "docker", Image: "gcc:13", DockerUser: "root"}
+	tc := config.Toolchain{Name: "llvm", Resources: &config.Resources{CPUs: 1.5, Memory: "4g"}}
+	opts := toolchainDockerOptions(tc, runner, docker.Endpoint{}, "gcc:13", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.Equal(t, 2, opts.Jobs, "the CPU limit rounded up")
+	assert.Equal(t, []string{"--cpus", "1.5", "--memory", "4g"}, opts.RunArgs())
+
+	runner.Network, runner.ExtraHosts, runner.DNS = "corp-net", []string{"mirror.corp:10.0.0.5"}, []string{"10.0.0.53"}
+	opts = toolchainDockerOptions(tc, runner, docker.Endpoint{}, "gcc:13", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.Equal(t, []string{"--cpus", "1.5", "--memory", "4g", "--network", "corp-net",
+		"--add-host", "mirror.corp:10.0.0.5", "--dns", "10.0.0.53"}, opts.RunArgs())
+
+	tc.Jobs = 6
+	assert.Equal(t, 6, dockerJobs(tc))
+	assert.Equal(t, 0, dockerJobs(config.Toolchain{}))
+}
+
+func TestServiceRunArgs(t *testing.T) {
+	svc := build.Service{Name: "db", Image: "postgres:16", Env: map[string]string{"POSTGRES_USER": "ci", "POSTGRES_PASSWORD": "test"},
+		Ports: []string{"5432:5432"}, HealthCmd: "pg_isready", Command: []string{"postgres", "-c", "fsync=off"}}
+	assert.Equal(t, []string{"run", "-d", "--name", "cpx-linux-1-db", "--network", "cpx-linux-1", "--network-alias", "db",
+		"-e", "POSTGRES_PASSWORD=test", "-e", "POSTGRES_USER=ci", "-p", "5432:5432",
+		"--health-cmd", "pg_isready", "--health-interval", "1s", "postgres:16", "postgres", "-c", "fsync=off"},
+		build.ServiceRunArgs("cpx-linux-1", "cpx-linux-1-db", svc))
+
+	opts := build.DockerBuildOptions{Services: []build.Service{svc}}
+	assert.False(t, opts.UsesServices(), "plain builds do not start services")
+	opts.RunTests = true
+	assert.True(t, opts.UsesServices())
+	opts.DryRun = true
+	assert.False(t, opts.UsesServices())
+
+	opts = build.DockerBuildOptions{Services: []build.Service{svc}, Network: "host"}
+	_, err := build.StartServices(&opts)
+	assert.ErrorContains(t, err, "cannot be used with network 'host'")
+}
+
+func TestSignCommand(t *testing.T) {
+	gpg := signCommand(&config.SignConfig{Method: "gpg", Key: "release@example.com", PassphraseEnv: "GPG_PASSPHRASE"}, "out/app")
+	assert.Equal(t, []string{"gpg", "--batch", "--yes", "--detach-sign", "--local-user", "release@example.com",
+		"--pinentry-mode", "loopback", "--passphrase-fd", "0", "--output", "out/app.sig", "out/app"}, gpg.Args)
+
+	cosign := signCommand(&config.SignConfig{Method: "cosign", Key: "cosign.key"}, "out/app")
+	assert.Equal(t, []string{"cosign", "sign-blob", "--yes", "--output-signature", "out/app.sig", "--key", "cosign.key", "out/app"}, cosign.Args)
+
+	keyless := signCommand(&config.SignConfig{Method: "cosign"}, "out/app")
+	assert.Contains(t, keyless.Args, "--output-certificate")
+
+	assert.True(t, hasSignatureExt("app.sig"))
+	assert.False(t, hasSignatureExt("app.exe"))
+}
+
+func TestInstalledVcpkgPackages(t *testing.T) {
+	status := filepath.Join(t.TempDir(), "status")
+	require.NoError(t, os.WriteFile(status, []byte(`Package: fmt
+Version: 10.2.1
+Port-Version: 0
+Architecture: x64-linux
+Status: install ok installed
+
+Package: zlib
+Version: 1.3.1
+Port-Version: 1
+Architecture: x64-linux
+Status: install ok installed
+
+Package: zlib
+Feature: core
+Architecture: x64-linux
+Status: install ok installed
+
+Package: spdlog
+Version: 1.12.0
+Architecture: x64-linux
+Status: purge ok not-installed
+`), 0644))
+
+	packages, err := installedVcpkgPackages(status)
+	require.NoError(t, err)
+	assert.Equal(t, []vcpkgPackage{
+		{Name: "fmt", Version: "10.2.1", Triplet: "x64-linux"},
+		{Name: "zlib", Version: "1.3.1#1", Triplet: "x64-linux"},
+	}, packages)
+
+	manifest := filepath.Join(t.TempDir(), "vcpkg.json")
+	require.NoError(t, os.WriteFile(manifest, []byte(`{"dependencies": ["fmt", {"name": "zlib", "version>=": "1.3"}]}`), 0644))
+	assert.Equal(t, []vcpkgPackage{{Name: "fmt"}, {Name: "zlib", Version: "1.3"}}, manifestVcpkgPackages(manifest))
+}
+
+func TestSBOMDocuments(t *testing.T) {
+	info := sbomInfo{
+		Project:   "app",
+		Version:   "1.0.0",
+		Toolchain: config.Toolchain{Name: "linux", BuildType: "Release"},
+		Runner:    &config.Runner{Name: "gcc", Type: "docker", Image: "gcc:13"},
+		Image:     "gcc:13",
+		ImageID:   "sha256:abc",
+		Packages:  []vcpkgPackage{{Name: "fmt", Version: "10.2.1", Triplet: "x64-linux"}},
+		Installed: true,
+		Artifacts: []artifactDigest{{Name: "app", SHA256: "51a1f05a"}},
+	}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	spdx, err := json.Marshal(spdxDocument(info, created))
+	require.NoError(t, err)
+	assert.Contains(t, string(spdx), `"spdxVersion":"SPDX-2.3"`)
+	assert.Contains(t, string(spdx), `"name":"fmt"`)
+	assert.Contains(t, string(spdx), `"relationshipType":"BUILD_TOOL_OF"`)
+	assert.Contains(t, string(spdx), `"fileName":"./app"`)
+	assert.Contains(t, string(spdx), `cpx:image_id=sha256:abc`)
+
+	cdx, err := json.Marshal(cycloneDXDocument(info, created))
+	require.NoError(t, err)
+	assert.Contains(t, string(cdx), `"bomFormat":"CycloneDX"`)
+	assert.Contains(t, string(cdx), `"bom-ref":"vcpkg:fmt:x64-linux"`)
+	assert.Contains(t, string(cdx), `{"alg":"SHA-256","content":"abc"}`)
+	assert.Contains(t, string(cdx), `"timestamp":"2026-01-02T03:04:05Z"`)
+}
+
+func TestCollectProvenance(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "Dockerfile"), []byte("FROM gcc:13\n"), 0644))
+	out := filepath.Join(root, ".bin", "ci", "linux")
+	require.NoError(t, os.MkdirAll(out, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(out, "app"), []byte("bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(out, "app.sig"), []byte("sig"), 0644))
+
+	ciConfig := &config.ToolchainConfig{Runners: []config.Runner{
+		{Name: "gcc", Type: "docker", Image: "cpx-gcc", CC: "gcc", Build: &config.RunnerBuild{Args: map[string]string{"GCC": "13"}}},
+	}}
+	tc := config.Toolchain{Name: "linux", Runner: "gcc", BuildType: "Release", CMakeOptions: []string{"-DFOO=ON"}, Env: map[string]string{"TOKEN": "secret"}}
+	statement := collectProvenance(ciConfig, tc, toolchainResult{Duration: time.Minute}, root, out)
+
+	assert.Equal(t, "https://slsa.dev/provenance/v1", statement.PredicateType)
+	require.Len(t, statement.Subject, 1)
+	assert.Equal(t, inTotoSubject{Name: "app", Digest: map[string]string{"sha256": "51a1f05af85e342e3c849b47d387086476282d5f50dc240c19216d6edfb1eb5a"}}, statement.Subject[0])
+
+	params := statement.Predicate.BuildDefinition.ExternalParameters
+	assert.Equal(t, []string{"-DFOO=ON"}, params["cmake_options"])
+	assert.Equal(t, []string{"TOKEN"}, params["env"])
+	assert.Equal(t, []string{"GCC=13"}, params["build_args"])
+	assert.Equal(t, "gcc", params["cc"])
+
+	deps := statement.Predicate.BuildDefinition.ResolvedDependencies
+	require.Len(t, deps, 1) // not a git repository, no image: only the Dockerfile
+	assert.Equal(t, "file:Dockerfile", deps[0].URI)
+
+	require.NoError(t, writeProvenance(statement, out))
+	data, err := os.ReadFile(filepath.Join(out, provenanceFile))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), `"_type": "https://in-toto.io/Statement/v1"`)
+}
+
+func TestPackagePath(t *testing.T) {
+	cases := []struct {
+		file, path string
+		mode       os.FileMode
+		wantMode   os.FileMode
+	}{
+		{"app", "bin/app", 0755, 0755},
+		{"app.exe", "bin/app.exe", 0644, 0755},
+		{"app.dll", "bin/app.dll", 0644, 0755},
+		{"libapp.so.1.2", "lib/libapp.so.1.2", 0755, 0755},
+		{"libapp.a", "lib/libapp.a", 0644, 0644},
+		{"app.pdb", "share/app/app.pdb", 0644, 0644},
+	}
+	for _, c := range cases {
+		path, mode := packagePath(c.file, c.mode, "app")
+		assert.Equal(t, c.path, path, c.file)
+		assert.Equal(t, c.wantMode, mode, c.file)
+	}
+
+	meta := packaging.Metadata{Name: "app", Version: "1.0.0", Arch: "arm64"}
+	assert.Equal(t, "app-1.0.0-linux-arm64.tar.gz", packageFileName("tar.gz", meta, "linux-arm64"))
+	assert.Equal(t, "app_1.0.0_arm64.deb", packageFileName("deb", meta, "linux-arm64"))
+	assert.Equal(t, "app-1.0.0-1.aarch64.rpm", packageFileName("rpm", meta, "linux-arm64"))
+}
+
+func TestPackageToolchains(t *testing.T) {
+	ciConfig := &config.ToolchainConfig{
+		Runners:    []config.Runner{{Name: "arm", Type: "docker", Image: "gcc:13", Platform: "linux/arm64"}},
+		Templates:  []config.Toolchain{{Name: "arm-base", Runner: "arm", BuildType: "Release"}},
+		Toolchains: []config.Toolchain{{Name: "arm-release", Extends: "arm-base"}},
+	}
+
+	// The runner, and so the package's architecture, is inherited through extends
+	for _, pkg := range []*config.PackageConfig{{}, {Toolchains: []string{"arm-release"}}} {
+		toolchains, err := packageToolchains(ciConfig, pkg, "")
+		require.NoError(t, err)
+		require.Len(t, toolchains, 1)
+		assert.Equal(t, "arm", toolchains[0].Runner)
+		target, err := collectPackageTarget(ciConfig, toolchains[0], t.TempDir(), "app")
+		require.NoError(t, err)
+		assert.Equal(t, "arm64", target.Arch)
+		assert.Equal(t, "linux", target.OS)
+	}
+
+	_, err := packageToolchains(ciConfig, &config.PackageConfig{}, "missing")
+	assert.ErrorContains(t, err, "toolchain 'missing' not found")
+}
+
+func TestGithubRepoFromURL(t *testing.T) {
+	for remote, want := range map[string]string{
+		"https://github.com/acme/tool.git": "acme/tool",
+		"https://github.com/acme/tool":     "acme/tool",
+		"git@github.com:acme/tool.git":     "acme/tool",
+		"ssh://git@github.com/acme/tool/":  "acme/tool",
+	} {
+		repo, ok := githubRepoFromURL(remote)
+		assert.True(t, ok, remote)
+		assert.Equal(t, want, repo, remote)
+	}
+	_, ok := githubRepoFromURL("https://gitlab.com/acme/tool.git")
+	assert.False(t, ok)
+}
+
+func TestPublishRelease(t *testing.T) {
+	outputDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(outputDir, "linux", "test-results"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(outputDir, packagesDir), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "SHA256SUMS"), []byte("sums"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "linux", "app"), []byte("binary"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "linux", "test-results", "report.xml"), []byte("<xml/>"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, packagesDir, "app_1.0.0_amd64.deb"), []byte("deb"), 0644))
+	// Left over from before the toolchain was deactivated
+	require.NoError(t, os.MkdirAll(filepath.Join(outputDir, "macos"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "macos", "app"), []byte("stale"), 0755))
+
+	inactive := false
+	assets, err := releaseAssets(outputDir, []config.Toolchain{{Name: "linux"}, {Name: "windows"}, {Name: "macos", Active: &inactive}})
+	require.NoError(t, err)
+	var names []string
+	for _, a := range assets {
+		names = append(names, a.Name)
+	}
+	assert.Equal(t, []string{"SHA256SUMS", "app_1.0.0_amd64.deb", "linux-app"}, names)
+
+	var mu sync.Mutex
+	var calls []string
+	uploads := make(map[string]string)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/tool/releases/tags/v1.0.0":
+			json.NewEncoder(w).Encode(githubRelease{
+				ID: 7, TagName: "v1.0.0", UploadURL: server.URL + "/upload/7{?name,label}",
+				Assets: []githubAsset{{ID: 3, Name: "linux-app"}},
+			})
+		case r.Method == http.MethodPatch:
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, map[string]any{"prerelease": true}, body)
+			json.NewEncoder(w).Encode(githubRelease{ID: 7, TagName: "v1.0.0", UploadURL: server.URL + "/upload/7{?name,label}"})
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost && r.URL.Path == "/upload/7":
+			data, _ := io.ReadAll(r.Body)
+			uploads[r.URL.Query().Get("name")] = string(data)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	gh := &githubClient{apiURL: server.URL, token: "secret", client: server.Client()}
+	release, err := gh.ensureRelease("acme/tool", "v1.0.0", false, true)
+	require.NoError(t, err)
+	for _, a := range assets {
+		require.NoError(t, gh.uploadAsset("acme/tool", release, a))
+	}
+	assert.Equal(t, map[string]string{"SHA256SUMS": "sums", "app_1.0.0_amd64.deb": "deb", "linux-app": "binary"}, uploads)
+	assert.Contains(t, calls, "DELETE /repos/acme/tool/releases/assets/3")
+
+	_, err = gh.ensureRelease("acme/missing", "v1.0.0", false, false)
+	assert.ErrorContains(t, err, "Not Found")
+}
+
+func TestUploadArtifacts(t *testing.T) {
+	s3 := &config.UploadConfig{URL: "s3://releases/app/{{version}}/", Endpoint: "https://minio.example.com"}
+	assert.Equal(t, "s3://releases/app/1.0.0/linux", uploadURL(s3, "linux", "1.0.0"))
+	assert.Equal(t, "s3://releases/app/1.0.0", uploadURL(s3, "", "1.0.0"))
+	gs := &config.UploadConfig{URL: "gs://releases/{{target}}/{{version}}"}
+	assert.Equal(t, "gs://releases/linux/1.0.0", uploadURL(gs, "linux", "1.0.0"))
+	assert.Equal(t, "gs://releases/1.0.0", uploadURL(gs, "", "1.0.0"))
+
+	assert.Equal(t, []string{"aws", "s3", "cp", "--only-show-errors", "--endpoint-url", "https://minio.example.com", "out/app", "s3://releases/app"},
+		uploadCommand(s3, "out/app", "s3://releases/app").Args)
+	assert.Equal(t, []string{"gcloud", "storage", "cp", "--quiet", "out/app", "gs://releases/app"},
+		uploadCommand(gs, "out/app", "gs://releases/app").Args)
+
+	outputDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(outputDir, "linux", "test-results"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "SHA256SUMS"), []byte("sums"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "linux", "app"), []byte("binary"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "linux", "test-results", "report.xml"), []byte("<xml/>"), 0644))
+
+	var mu sync.Mutex
+	uploads := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		data, _ := io.ReadAll(r.Body)
+		uploads[r.URL.Path] = string(data)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	t.Setenv("CPX_TEST_UPLOAD_TOKEN", "secret")
+	ciConfig := &config.ToolchainConfig{Upload: &config.UploadConfig{URL: server.URL + "/app/{{version}}", TokenEnv: "CPX_TEST_UPLOAD_TOKEN"}}
+	toolchains := []config.Toolchain{{Name: "linux"}}
+	assert.True(t, hasUploadDestination(ciConfig, toolchains))
+	assert.False(t, hasUploadDestination(&config.ToolchainConfig{}, toolchains))
+	require.NoError(t, uploadArtifacts(ciConfig, outputDir, toolchains, "1.0.0"))
+	assert.Equal(t, map[string]string{
+		"/app/1.0.0/SHA256SUMS":                    "sums",
+		"/app/1.0.0/linux/app":                     "binary",
+		"/app/1.0.0/linux/test-results/report.xml": "<xml/>",
+	}, uploads)
+}
+
+func TestRuntimeImage(t *testing.T) {
+	files := []packaging.File{
+		{Source: "out/server", Path: "bin/server", Mode: 0755},
+		{Source: "out/libcore.so.1", Path: "lib/libcore.so.1", Mode: 0755},
+		{Source: "out/libcore.a", Path: "lib/libcore.a", Mode: 0644},
+		{Source: "out/server.map", Path: "share/app/server.map", Mode: 0644},
+	}
+	spec, err := runtimeImageContents(files, "")
+	require.NoError(t, err)
+	assert.Equal(t, "server", spec.Executable)
+	assert.Equal(t, []string{"libcore.so.1"}, spec.Libraries)
+
+	spec.Base, spec.Title, spec.Version = "gcr.io/distroless/cc-debian12", "app", "1.0.0"
+	assert.Equal(t, `FROM gcr.io/distroless/cc-debian12
+COPY bin/server /usr/local/bin/server
+COPY lib/libcore.so.1 /usr/local/lib/libcore.so.1
+ENV LD_LIBRARY_PATH=/usr/local/lib
+LABEL org.opencontainers.image.title="app" org.opencontainers.image.version="1.0.0"
+ENTRYPOINT ["/usr/local/bin/server"]
+`, runtimeDockerfile(spec))
+
+	files = append(files, packaging.File{Source: "out/client", Path: "bin/client", Mode: 0755})
+	_, err = runtimeImageContents(files, "")
+	assert.ErrorContains(t, err, "several executables")
+	spec, err = runtimeImageContents(files, "client")
+	require.NoError(t, err)
+	assert.Equal(t, "client", spec.Executable)
+	_, err = runtimeImageContents(files, "missing")
+	assert.ErrorContains(t, err, "not found")
+	_, err = runtimeImageContents(nil, "")
+	assert.ErrorContains(t, err, "no executable")
+}
+
+func TestExportGitHubWorkflow(t *testing.T) {
+	inactive := false
+	ciConfig := &config.ToolchainConfig{
+		Runners: []config.Runner{
+			{Name: "gcc", Type: "docker", Image: "gcc:14"},
+			{Name: "arm", Type: "docker", Image: "gcc:14", Platform: "linux/arm64"},
+		},
+		Toolchains: []config.Toolchain{
+			{Name: "linux-release", Runner: "gcc"},
+			{Name: "linux-arm64", Runner: "arm"},
+			{Name: "windows-msvc"},
+			{Name: "macos-arm64"},
+			{Name: "disabled", Runner: "gcc", Active: &inactive},
+			{Name: "linux-arm64-asan", Extends: "linux-arm64"},
+		},
+	}
+	jobs, err := exportJobs(ciConfig)
+	require.NoError(t, err)
+	assert.Equal(t, []exportJob{
+		{Toolchain: "linux-release", OS: "linux", Docker: true},
+		{Toolchain: "linux-arm64", OS: "linux", Docker: true, QEMU: true},
+		{Toolchain: "windows-msvc", OS: "windows"},
+		{Toolchain: "macos-arm64", OS: "darwin"},
+		{Toolchain: "linux-arm64-asan", OS: "linux", Docker: true, QEMU: true},
+	}, jobs)
+
+	workflow := githubWorkflow(jobs, ".bin/ci")
+	var parsed struct {
+		Jobs map[string]struct {
+			Strategy struct {
+				Matrix struct {
+					Include []map[string]string `yaml:"include"`
+				} `yaml:"matrix"`
+			} `yaml:"strategy"`
+			Steps []map[string]any `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	require.NoError(t, yaml.Unmarshal([]byte(workflow), &parsed))
+	build := parsed.Jobs["build"]
+	assert.Equal(t, []map[string]string{
+		{"toolchain": "linux-release", "os": "ubuntu-latest"},
+		{"toolchain": "linux-arm64", "os": "ubuntu-latest"},
+		{"toolchain": "windows-msvc", "os": "windows-latest"},
+		{"toolchain": "macos-arm64", "os": "macos-latest"},
+		{"toolchain": "linux-arm64-asan", "os": "ubuntu-latest"},
+	}, build.Strategy.Matrix.Include)
+	var uses []string
+	for _, step := range build.Steps {
+		if u, ok := step["uses"].(string); ok {
+			uses = append(uses, u)
+		}
+	}
+	assert.Equal(t, []string{"actions/checkout@v4", "docker/setup-buildx-action@v3", "docker/setup-qemu-action@v3", "actions/cache@v4", "actions/upload-artifact@v4"}, uses)
+	assert.Contains(t, workflow, "path: .bin/ci/${{ matrix.toolchain }}")
+}
+
+func TestExportGitLabAndAzure(t *testing.T) {
+	jobs := []exportJob{
+		{Toolchain: "linux-arm64", OS: "linux", Docker: true, QEMU: true},
+		{Toolchain: "windows-msvc", OS: "windows"},
+		{Toolchain: "macos-arm64", OS: "darwin"},
+	}
+
+	type gitlabJob struct {
+		Extends      string            `yaml:"extends"`
+		Tags         []string          `yaml:"tags"`
+		Variables    map[string]string `yaml:"variables"`
+		BeforeScript []string          `yaml:"before_script"`
+		Script       []string          `yaml:"script"`
+	}
+	var nodes map[string]yaml.Node
+	pipeline := gitlabPipeline(jobs, ".bin/ci")
+	require.NoError(t, yaml.Unmarshal([]byte(pipeline), &nodes))
+	gitlab := make(map[string]gitlabJob)
+	for _, name := range []string{".cpx-docker", "linux-arm64", "windows-msvc", "macos-arm64"} {
+		var job gitlabJob
+		node := nodes[name]
+		require.NoError(t, node.Decode(&job), name)
+		gitlab[name] = job
+	}
+	assert.Equal(t, ".cpx-docker", gitlab["linux-arm64"].Extends)
+	assert.Equal(t, "linux-arm64", gitlab["linux-arm64"].Variables["CPX_TOOLCHAIN"])
+	assert.Contains(t, gitlab[".cpx-docker"].BeforeScript, "docker run --privileged --rm tonistiigi/binfmt --install all")
+	assert.Equal(t, []string{"saas-windows-medium-amd64"}, gitlab["windows-msvc"].Tags)
+	assert.Equal(t, []string{"saas-macos-medium-m1"}, gitlab["macos-arm64"].Tags)
+	assert.Equal(t, []string{`cpx ci build --toolchain "$CPX_TOOLCHAIN"`}, gitlab["macos-arm64"].Script)
+	assert.Equal(t, []string{`cpx ci build --toolchain "$env:CPX_TOOLCHAIN"`}, gitlab["windows-msvc"].Script)
+	assert.Contains(t, pipeline, "      - .bin/ci/$CPX_TOOLCHAIN\n")
+
+	var azure struct {
+		Jobs []struct {
+			Strategy struct {
+				Matrix map[string]map[string]string `yaml:"matrix"`
+			} `yaml:"strategy"`
+			Steps []map[string]any `yaml:"steps"`
+		} `yaml:"jobs"`
+	}
+	pipeline = azurePipeline(jobs, ".bin/ci")
+	require.NoError(t, yaml.Unmarshal([]byte(pipeline), &azure))
+	require.Len(t, azure.Jobs, 1)
+	assert.Equal(t, map[string]map[string]string{
+		"linux_arm64":  {"toolchain": "linux-arm64", "vmImage": "ubuntu-latest"},
+		"windows_msvc": {"toolchain": "windows-msvc", "vmImage": "windows-latest"},
+		"macos_arm64":  {"toolchain": "macos-arm64", "vmImage": "macos-latest"},
+	}, azure.Jobs[0].Strategy.Matrix)
+	assert.Contains(t, pipeline, "      - publish: .bin/ci/$(toolchain)\n")
+	assert.Contains(t, pipeline, "Set up QEMU")
+}
+
+// attemptBuilder fails its first failures builds, and runs until the
+// context ends when hang is set
+type attemptBuilder struct {
+	calls    int
+	failures int
+	hang     bool
+	names    []string
+}
+
+func (b *attemptBuilder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOptions) error {
+	b.calls++
+	b.names = append(b.names, opts.ContainerName)
+	if b.hang {
+		<-ctx.Done()
+		return fmt.Errorf("docker build failed: %w", ctx.Err())
+	}
+	if b.calls <= b.failures {
+		return fmt.Errorf("docker build failed: exit status 1")
+	}
+	return nil
+}
+
+func TestRunDockerAttempts(t *testing.T) {
+	opts := build.DockerBuildOptions{TargetName: "linux-arm64", Output: io.Discard}
+
+	flaky := &attemptBuilder{failures: 2}
+	require.NoError(t, runDockerAttempts(flaky, opts, config.Toolchain{Name: "linux-arm64", Retries: 2}))
+	assert.Equal(t, 3, flaky.calls)
+	assert.Equal(t, []string{"", "", ""}, flaky.names, "containers are only named with a timeout")
+
+	failing := &attemptBuilder{failures: 5}
+	assert.ErrorContains(t, runDockerAttempts(failing, opts, config.Toolchain{Name: "linux-arm64", Retries: 1}), "exit status 1")
+	assert.Equal(t, 2, failing.calls)
+
+	hanging := &attemptBuilder{hang: true}
+	err := runDockerAttempts(hanging, opts, config.Toolchain{Name: "linux-arm64", Timeout: "10ms", Retries: 1})
+	assert.EqualError(t, err, "timed out after 10ms")
+	assert.Equal(t, 2, hanging.calls)
+	assert.Equal(t, build.ContainerName("linux-arm64", 1), hanging.names[0])
+	assert.Equal(t, build.ContainerName("linux-arm64", 2), hanging.names[1])
+	assert.Contains(t, (build.DockerBuildOptions{ContainerName: hanging.names[0]}).RunArgs(), "--name")
+}
+
+func TestBuildToolchainsKeepGoing(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "CMakeLists.txt"), []byte("project(app VERSION 1.0.0)\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cpx-ci.yaml"), []byte(`toolchains:
+  - name: linux
+    runner: missing-linux
+  - name: windows
+    runner: missing-windows
+  - name: package
+    runner: missing-linux
+    depends_on: [linux]
+`), 0644))
+	t.Chdir(dir)
+
+	statuses := func(results []toolchainResult) []string {
+		var s []string
+		for _, r := range results {
+			switch {
+			case r.Skipped:
+				s = append(s, r.Name+":skipped")
+			case r.Err != nil:
+				s = append(s, r.Name+":failed")
+			default:
+				s = append(s, r.Name+":ok")
+			}
+		}
+		return s
+	}
+
+	results, err := buildToolchains(ToolchainBuildOptions{})
+	assert.ErrorContains(t, err, "runner 'missing-linux' not found")
+	assert.Equal(t, []string{"linux:failed", "windows:skipped", "package:skipped"}, statuses(results))
+
+	results, err = buildToolchains(ToolchainBuildOptions{KeepGoing: true})
+	assert.EqualError(t, err, "2 of 3 toolchain(s) failed")
+	assert.Equal(t, []string{"linux:failed", "windows:failed", "package:skipped"}, statuses(results))
+}
+
+func TestRunState(t *testing.T) {
+	cacheDir, outputDir := filepath.Join(t.TempDir(), "cache"), t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(outputDir, "linux"), 0755))
+
+	state := loadRunState(cacheDir, "abc", false)
+	state.complete("linux")
+	state.complete("windows")
+	assert.True(t, state.done("linux", outputDir))
+	assert.False(t, state.done("windows", outputDir), "its artifacts are gone")
+
+	assert.True(t, loadRunState(cacheDir, "abc", true).done("linux", outputDir))
+	assert.False(t, loadRunState(cacheDir, "abc", false).done("linux", outputDir), "without --resume")
+	assert.False(t, loadRunState(cacheDir, "def", true).done("linux", outputDir), "the sources changed")
+
+	state.clear()
+	assert.False(t, loadRunState(cacheDir, "abc", true).done("linux", outputDir))
+	var none *runState
+	assert.False(t, none.done("linux", outputDir))
+}
+
+func TestToolchainSecrets(t *testing.T) {
+	t.Setenv("API_TOKEN", "s3cr3t-token")
+	t.Setenv("CONAN_LOGIN", "builder")
+	runner := &config.Runner{Name: "gcc", Type: "docker", Image: "gcc:13"}
+	tc := config.Toolchain{Name: "linux", Env: map[string]string{"MODE": "ci"}, EnvPassthrough: []string{"CONAN_LOGIN"}, Secrets: []string{"API_TOKEN", "UNSET_TOKEN"}}
+	opts := toolchainDockerOptions(tc, runner, docker.Endpoint{}, "gcc:13", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{})
+	assert.Equal(t, []string{"-e", "CONAN_LOGIN", "-e", "API_TOKEN"}, opts.EnvArgs(), "forwarded by name, only when set")
+	assert.NotContains(t, opts.Env, "API_TOKEN", "never written into the build script")
+
+	var output, log bytes.Buffer
+	opts.Output, opts.Log, opts.Verbose = &output, &log, true
+	stdout, stderr := opts.Streams()
+	fmt.Fprintln(stdout, "login builder with s3cr3t-token")
+	fmt.Fprintln(stderr, "curl -H 'Authorization: s3cr3t-token'")
+	assert.Equal(t, "login builder with ***\ncurl -H 'Authorization: ***'\n", output.String())
+	assert.Equal(t, output.String(), log.String())
+}
+
+func TestCIExplainMasksForwardedEnv(t *testing.T) {
+	t.Setenv("API_TOKEN", "s3cr3t-token")
+	t.Setenv("CONAN_LOGIN", "builder")
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "CMakeLists.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cpx-ci.yaml"), []byte(`runners:
+  - name: ubuntu
+    type: docker
+    image: ubuntu
+toolchains:
+  - name: linux
+    runner: ubuntu
+    env: { CONAN_URL: "https://builder@conan.example.com" }
+    env_passthrough: [CONAN_LOGIN]
+    secrets: [API_TOKEN, UNSET_TOKEN]
+`), 0644))
+	t.Chdir(dir)
+
+	var out bytes.Buffer
+	cmd := CICmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"explain", "linux"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "ubuntu:latest", "the tag docker resolves")
+	assert.Contains(t, out.String(), "CONAN_URL=https://***@conan.example.com")
+	assert.Contains(t, out.String(), "CONAN_LOGIN=***")
+	assert.Contains(t, out.String(), "API_TOKEN=***")
+	assert.Contains(t, out.String(), "UNSET_TOKEN")
+	assert.NotContains(t, out.String(), "s3cr3t-token")
+}
+
+func TestToolchainHooks(t *testing.T) {
+	dir := t.TempDir()
+	tc := config.Toolchain{Name: "linux", BuildType: "Release", Hooks: &config.Hooks{
+		PreBuild:  []config.Hook{{Run: `echo "pre $CPX_TOOLCHAIN $CPX_BUILD_TYPE" >> hooks.log`}},
+		PostBuild: []config.Hook{{Run: `echo "post $CPX_OUTPUT_DIR" >> hooks.log`}},
+		OnFailure: []config.Hook{{Run: `echo "failed: $CPX_ERROR" >> hooks.log`}},
+	}}
+	options := ToolchainBuildOptions{Hooks: &config.Hooks{PreBuild: []config.Hook{{Run: "echo global >> hooks.log"}}}}
+	var out bytes.Buffer
+	readLog := func() string {
+		data, _ := os.ReadFile(filepath.Join(dir, "hooks.log"))
+		os.Remove(filepath.Join(dir, "hooks.log"))
+		return string(data)
+	}
+
+	built := false
+	err := newToolchainHooks(tc, options, dir, filepath.Join(dir, "out"), &out).run(func() error {
+		built = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, built)
+	assert.Equal(t, "global\npre linux Release\npost "+filepath.Join(dir, "out", "linux")+"\n", readLog())
+
+	err = newToolchainHooks(tc, options, dir, filepath.Join(dir, "out"), &out).run(func() error { return fmt.Errorf("compile error") })
+	assert.EqualError(t, err, "compile error")
+	assert.Equal(t, "global\npre linux Release\nfailed: compile error\n", readLog(), "post_build is skipped")
+
+	tc.Hooks.PreBuild = []config.Hook{{Run: "exit 3"}}
+	built = false
+	err = newToolchainHooks(tc, ToolchainBuildOptions{}, dir, filepath.Join(dir, "out"), &out).run(func() error {
+		built = true
+		return nil
+	})
+	assert.ErrorContains(t, err, "pre_build hook 'exit 3' failed")
+	assert.False(t, built, "a failed pre_build hook fails the build")
+	assert.Contains(t, readLog(), "failed: pre_build hook 'exit 3' failed")
+
+	tc.Hooks = &config.Hooks{PreBuild: []config.Hook{{Run: "make codegen", In: "container"}}}
+	err = newToolchainHooks(tc, ToolchainBuildOptions{}, dir, filepath.Join(dir, "out"), &out).run(func() error { return nil })
+	assert.ErrorContains(t, err, "toolchain 'linux' does not build in Docker")
+
+	// Container hooks run without the terminal's stdin
+	out.Reset()
+	runner := &dockerRunner{builder: dockerBuilderFor(dir), opts: build.DockerBuildOptions{ProjectRoot: dir, TargetName: "linux", ImageName: "gcc:13", DryRun: true, Output: &out}}
+	require.NoError(t, runner.Exec(nil, "make codegen", nil))
+	assert.Contains(t, out.String(), "make codegen")
+	assert.NotContains(t, out.String(), " -i ")
+}
+
+func TestPluginRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugin is a shell script")
+	}
+	dir := t.TempDir()
+	plugin := `#!/bin/sh
+echo "$1 $CPX_TOOLCHAIN $CPX_BUILD_TYPE" >> steps.log
+test -f "$CPX_TOOLCHAIN_FILE" || exit 1
+if [ "$1" = collect ]; then mkdir -p "$CPX_OUTPUT_DIR" && echo built > "$CPX_OUTPUT_DIR/app"; fi
+`
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tools"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tools", "k8s-runner"), []byte(plugin), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cpx-ci.yaml"), []byte(`runners:
+  - name: k8s
+    type: plugin
+    plugin: ./tools/k8s-runner
+    options: { namespace: ci }
+toolchains:
+  - name: linux
+    runner: k8s
+  - name: arm
+    runner: k8s
+    build_type: Debug
+parallel: 2
+`), 0644))
+	t.Chdir(dir)
+
+	results, err := buildToolchains(ToolchainBuildOptions{ToolchainName: "linux", RunTests: true})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	steps, err := os.ReadFile("steps.log")
+	require.NoError(t, err)
+	assert.Equal(t, "prepare linux Release\nbuild linux Release\ncollect linux Release\n", string(steps), "no run step without ExecuteAfterBuild")
+	assert.Equal(t, []string{filepath.Join(".bin", "ci", "linux", "app")}, results[0].Artifacts)
+	spec, err := os.ReadFile(filepath.Join(ciCacheDir(dir), "linux", "runner.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(spec), "namespace: ci")
+
+	require.NoError(t, os.Remove("steps.log"))
+	results, err = buildToolchains(ToolchainBuildOptions{})
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+	steps, err = os.ReadFile("steps.log")
+	require.NoError(t, err)
+	assert.Contains(t, string(steps), "collect arm Debug\n", "built in parallel")
+}
+
+func TestCoverageCompiler(t *testing.T) {
+	assert.Equal(t, build.CoverageGCC, coverageCompiler(&config.Runner{Name: "gcc", Type: "docker", Image: "gcc:13"}))
+	assert.Equal(t, build.CoverageClang, coverageCompiler(&config.Runner{Name: "clang", CC: "clang-18", CXX: "clang++-18"}))
+	assert.Equal(t, build.CoverageClang, coverageCompiler(&config.Runner{Name: "clang", CC: "/usr/bin/clang"}))
+
+	tc := config.Toolchain{Name: "linux", Runner: "clang"}
+	runner := &config.Runner{Name: "clang", Type: "docker", Image: "silkeh/clang:18", CXX: "clang++"}
+	opts := toolchainDockerOptions(tc, runner, docker.Endpoint{}, "img", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{RunTests: true, Coverage: true})
+	assert.Equal(t, build.CoverageClang, opts.Coverage)
+	opts = toolchainDockerOptions(tc, runner, docker.Endpoint{}, "img", "/src", "/cache", ".bin/ci", ToolchainBuildOptions{Coverage: true})
+	assert.Empty(t, opts.Coverage, "coverage needs tests")
+}
+
+func TestMergeCICoverage(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // no genhtml
+	root := t.TempDir()
+	outputDir := filepath.Join(root, ".bin", "ci")
+	write := func(toolchain, content string) {
+		dir := filepath.Join(outputDir, toolchain, build.CoverageResultsDir)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, build.CoverageLcovFile), []byte(content), 0644))
+	}
+	// The arm64 tests run the branch the amd64 ones skip
+	write("linux-amd64", "SF:/workspace/src/cpu.cpp\nDA:1,1\nDA:2,1\nDA:3,0\nend_of_record\nSF:/tmp/build/_deps/gtest/gtest.cc\nDA:1,1\nend_of_record\n")
+	write("linux-arm64", "SF:/workspace/src/cpu.cpp\nDA:1,1\nDA:2,0\nDA:3,1\nend_of_record\n")
+	write("linux-stale", "SF:/workspace/src/cpu.cpp\nDA:1,0\nend_of_record\n")
+
+	combined, toolchains, err := mergeCICoverage(root, outputDir, []toolchainResult{
+		{Name: "linux-amd64"},
+		{Name: "linux-arm64", Err: fmt.Errorf("tests failed")},
+		{Name: "linux-stale", Skipped: true},
+		{Name: "macos"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/cpu.cpp"}, combined.Paths())
+	assert.Equal(t, map[int]int64{1: 2, 2: 1, 3: 1}, combined.Files["src/cpu.cpp"].Lines)
+	require.Len(t, toolchains, 3)
+	assert.Equal(t, 2, toolchains[0].Summary.Lines.Hit)
+	assert.False(t, toolchains[2].Found)
+
+	assert.FileExists(t, filepath.Join(outputDir, "linux-amd64", build.CoverageResultsDir, "coverage.lcov"))
+	data, err := os.ReadFile(filepath.Join(outputDir, ciCoverageDir, "coverage.lcov"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "SF:src/cpu.cpp\n")
+	assert.Contains(t, string(data), "LF:3\nLH:3\n")
+
+	require.NoError(t, clearCICoverage(outputDir))
+	assert.NoDirExists(t, filepath.Join(outputDir, "linux-amd64", build.CoverageResultsDir))
+	assert.NoDirExists(t, filepath.Join(outputDir, ciCoverageDir))
+}
+
+func TestPrintCICoverage(t *testing.T) {
+	var out bytes.Buffer
+	printCICoverage(&out, []toolchainCoverage{
+		{Name: "linux-amd64", Found: true, Summary: coverage.Summary{Lines: coverage.Counts{Found: 4, Hit: 3}, Functions: coverage.Counts{Found: 2, Hit: 2}}},
+		{Name: "macos"},
+	}, coverage.Summary{Lines: coverage.Counts{Found: 4, Hit: 4}, Functions: coverage.Counts{Found: 2, Hit: 2}})
+	assert.Equal(t, "\n"+colors.Bold+"Coverage"+colors.Reset+`
+  linux-amd64  lines  75.0%  functions 100.0%  branches    n/a
+  macos        no coverage (collected from CMake Docker toolchains)
+  combined     lines 100.0%  functions 100.0%  branches    n/a
+`, out.String())
+}
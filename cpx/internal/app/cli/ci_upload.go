@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+)
+
+// hasUploadDestination returns whether cpx-ci.yaml sets an upload destination
+// for one of toolchains
+func hasUploadDestination(ciConfig *config.ToolchainConfig, toolchains []config.Toolchain) bool {
+	for _, tc := range toolchains {
+		if toolchainUpload(ciConfig, tc) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// toolchainUpload returns the upload destination of a toolchain: its own, or the global one
+func toolchainUpload(ciConfig *config.ToolchainConfig, tc config.Toolchain) *config.UploadConfig {
+	if tc.Upload != nil {
+		return tc.Upload
+	}
+	return ciConfig.Upload
+}
+
+// uploadURL returns the destination of a toolchain's files: the configured
+// URL with {{version}} and {{target}} replaced, followed by /<toolchain>
+// when it has no {{target}}. An empty target gives the destination of the
+// files shared by all toolchains, such as SHA256SUMS.
+func uploadURL(upload *config.UploadConfig, target, version string) string {
+	dest := strings.ReplaceAll(upload.URL, "{{version}}", version)
+	if !strings.Contains(dest, "{{target}}") && target != "" {
+		dest = strings.TrimSuffix(dest, "/") + "/{{target}}"
+	}
+	scheme, rest, _ := strings.Cut(strings.ReplaceAll(dest, "{{target}}", target), "://")
+	return scheme + "://" + strings.TrimSuffix(path.Clean(rest), "/")
+}
+
+// uploadArtifacts pushes every file under each built toolchain's output
+// directory to its upload destination, and the checksums in the output
+// directory itself to the global one
+func uploadArtifacts(ciConfig *config.ToolchainConfig, outputDir string, toolchains []config.Toolchain, version string) error {
+	fmt.Printf("\n%s Uploading artifacts...%s\n", colors.Cyan, colors.Reset)
+	uploaded := 0
+	for _, tc := range toolchains {
+		upload := toolchainUpload(ciConfig, tc)
+		if upload == nil {
+			fmt.Printf("   %sSkipping %s: no upload destination%s\n", colors.Yellow, tc.Name, colors.Reset)
+			continue
+		}
+		dir := filepath.Join(outputDir, tc.Name)
+		count, err := uploadDir(upload, dir, uploadURL(upload, tc.Name, version), false)
+		if err != nil {
+			return fmt.Errorf("failed to upload artifacts of '%s': %w", tc.Name, err)
+		}
+		uploaded += count
+	}
+	if ciConfig.Upload != nil {
+		count, err := uploadDir(ciConfig.Upload, outputDir, uploadURL(ciConfig.Upload, "", version), true)
+		if err != nil {
+			return fmt.Errorf("failed to upload checksums: %w", err)
+		}
+		uploaded += count
+	}
+	fmt.Printf("   Uploaded %d file(s)\n", uploaded)
+	return nil
+}
+
+// uploadDir uploads the files under dir to dest, keeping their relative
+// paths; with topOnly, only the files directly in dir
+func uploadDir(upload *config.UploadConfig, dir, dest string, topOnly bool) (int, error) {
+	count := 0
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			if topOnly && file != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		target := dest + "/" + filepath.ToSlash(rel)
+		fmt.Printf("   %s -> %s\n", file, target)
+		if err := uploadFile(upload, file, target); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// uploadFile copies a file to an object URL: with the aws CLI for s3://, the
+// gcloud CLI for gs:// and a PUT request for http(s)://
+func uploadFile(upload *config.UploadConfig, file, dest string) error {
+	switch upload.Scheme() {
+	case "s3", "gs":
+		cmd := uploadCommand(upload, file, dest)
+		if !CheckCommandExists(cmd.Args[0]) {
+			return fmt.Errorf("%s not found in PATH, required to upload to %s://", cmd.Args[0], upload.Scheme())
+		}
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to upload %s: %w\n%s", file, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	return putFile(upload, file, dest)
+}
+
+// uploadCommand returns the command copying a file to an s3:// or gs:// URL
+func uploadCommand(upload *config.UploadConfig, file, dest string) *exec.Cmd {
+	if upload.Scheme() == "gs" {
+		return execCommand("gcloud", "storage", "cp", "--quiet", file, dest)
+	}
+	args := []string{"s3", "cp", "--only-show-errors"}
+	if upload.Endpoint != "" {
+		args = append(args, "--endpoint-url", upload.Endpoint)
+	}
+	return execCommand("aws", append(args, file, dest)...)
+}
+
+// putFile sends a file to an HTTP server with a PUT request, authenticated
+// with the bearer token in token_env
+func putFile(upload *config.UploadConfig, file, dest string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, dest, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if upload.TokenEnv != "" {
+		token, ok := os.LookupEnv(upload.TokenEnv)
+		if !ok {
+			return fmt.Errorf("%s is not set, required by upload.token_env in cpx-ci.yaml", upload.TokenEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", file, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to upload %s: %s returned %s", file, dest, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func runCIValidate(_ *cobra.Command, args []string) error {
+	path := "cpx-ci.yaml"
+	if len(args) > 0 {
+		path = args[0]
+	}
+	ciConfig, err := config.ValidateToolchains(path)
+	var problems config.SchemaErrors
+	if errors.As(err, &problems) {
+		for _, p := range problems {
+			fmt.Printf("%s✗ %s%s\n", colors.Red, p, colors.Reset)
+		}
+		return fmt.Errorf("%s has %d problem(s)", path, len(problems))
+	}
+	if err != nil {
+		return fmt.Errorf("%s is invalid: %w", path, err)
+	}
+	fmt.Printf("%s✓ %s is valid: %d runner(s), %d template(s), %d toolchain(s)%s\n", colors.Green, path,
+		len(ciConfig.Runners), len(ciConfig.Templates), len(ciConfig.Toolchains), colors.Reset)
+	return nil
+}
+
+func runCISchema(cmd *cobra.Command, _ []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	schema, err := config.JSONSchema()
+	if err != nil {
+		return fmt.Errorf("failed to generate schema: %w", err)
+	}
+	schema = append(schema, '\n')
+	if output == "" {
+		_, err := os.Stdout.Write(schema)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(output), err)
+	}
+	if err := os.WriteFile(output, schema, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Printf("%s✓ Wrote %s%s\n", colors.Green, output, colors.Reset)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/ozacod/cpx/internal/pkg/build/cmake"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -165,3 +166,25 @@ func TestRequireVcpkgProject(t *testing.T) {
 		})
 	}
 }
+
+func TestLinkCandidatesDev(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("CMakeLists.txt", []byte(`project(demo)
+add_executable(contest src/contest.cpp)
+add_subdirectory(tests)
+`), 0644))
+	require.NoError(t, os.MkdirAll("tests", 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("tests", "CMakeLists.txt"), []byte(`add_executable(unit unit.cpp)
+add_executable(test_data_gen gen.cpp)
+add_test(NAME unit COMMAND unit)
+`), 0644))
+
+	path, _, targets := linkCandidates(true)
+	assert.Equal(t, filepath.Join("tests", "CMakeLists.txt"), path)
+	// test_data_gen builds fixtures and is no test, whatever its name says
+	assert.Equal(t, []cmake.Target{{Name: "unit", Kind: "executable"}}, targets)
+
+	path, _, targets = linkCandidates(false)
+	assert.Equal(t, "CMakeLists.txt", path)
+	assert.Equal(t, []cmake.Target{{Name: "contest", Kind: "executable"}}, targets)
+}
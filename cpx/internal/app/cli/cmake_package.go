@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/templates"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmakeProjectPattern    = regexp.MustCompile(`project\s*\(\s*([A-Za-z0-9_.+-]+)([^)]*)\)`)
+	cmakeVersionPattern    = regexp.MustCompile(`VERSION\s+([0-9][0-9.]*)`)
+	cmakeLibraryPattern    = regexp.MustCompile(`add_library\s*\(\s*([A-Za-z0-9_.+:-]+)([^)]*)\)`)
+	cmakeFindPattern       = regexp.MustCompile(`(?m)^\s*find_package\s*\(\s*([A-Za-z0-9_.+-]+)`)
+	cmakeIncludeDirPattern = regexp.MustCompile(`target_include_directories\s*\(([^)]*)\)`)
+	buildInterfacePattern  = regexp.MustCompile(`\$<BUILD_INTERFACE:[^>]*>`)
+)
+
+// testPackages are found for tests and benchmarks only, so consumers of the
+// library do not need them
+var testPackages = []string{"GTest", "Catch2", "doctest", "benchmark", "nanobench"}
+
+// CMakePackageCmd creates the cmake-package command
+func CMakePackageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cmake-package",
+		Short: "Generate install rules and a CMake config package for a library",
+		Long: `Generate install() rules, a <Project>Config.cmake, a version file and an export
+set for the add_library() targets of the CMakeLists.txt in the current directory,
+so other projects can find_package() the library after 'cmake --install'.
+
+The rules go to cmake/<Project>Install.cmake, included at the end of
+CMakeLists.txt, and the package config template to cmake/<Project>Config.cmake.in.
+Libraries are exported as <Project>::<target>, with aliases of the same names
+for projects that add this one with add_subdirectory() or FetchContent.`,
+		Example: `  cpx cmake-package
+  cpx cmake-package --compatibility SameMinorVersion`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			compatibility, _ := cmd.Flags().GetString("compatibility")
+			force, _ := cmd.Flags().GetBool("force")
+			return runCMakePackage(compatibility, force)
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().String("compatibility", "SameMajorVersion", "Versions a request matches: AnyNewerVersion, SameMajorVersion, SameMinorVersion or ExactVersion")
+	cmd.Flags().Bool("force", false, "Replace previously generated files")
+	return cmd
+}
+
+func runCMakePackage(compatibility string, force bool) error {
+	if !slices.Contains([]string{"AnyNewerVersion", "SameMajorVersion", "SameMinorVersion", "ExactVersion"}, compatibility) {
+		return fmt.Errorf("--compatibility must be AnyNewerVersion, SameMajorVersion, SameMinorVersion or ExactVersion, not '%s'", compatibility)
+	}
+	data, err := os.ReadFile("CMakeLists.txt")
+	if err != nil {
+		return fmt.Errorf("cmake-package requires a CMake project: %w", err)
+	}
+	content := string(data)
+
+	pkg, err := cmakePackage(content)
+	if err != nil {
+		return err
+	}
+	pkg.Compatibility = compatibility
+
+	configPath := filepath.Join("cmake", pkg.Name+"Config.cmake.in")
+	installPath := filepath.Join("cmake", pkg.Name+"Install.cmake")
+	if !force {
+		for _, path := range []string{configPath, installPath} {
+			if CheckFileExists(path) {
+				return fmt.Errorf("%s already exists; use --force to replace it", path)
+			}
+		}
+	}
+	if err := os.MkdirAll("cmake", 0755); err != nil {
+		return fmt.Errorf("failed to create cmake directory: %w", err)
+	}
+	if err := os.WriteFile(configPath, []byte(templates.GenerateCMakePackageConfig(pkg)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", configPath, err)
+	}
+	if err := os.WriteFile(installPath, []byte(templates.GenerateCMakeInstall(pkg)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", installPath, err)
+	}
+	fmt.Printf("%s✓ Wrote %s and %s%s\n", colors.Green, configPath, installPath, colors.Reset)
+
+	include := "include(" + filepath.ToSlash(installPath) + ")"
+	if !strings.Contains(content, include) {
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += "\n# Install rules and CMake package\n" + include + "\n"
+		if err := os.WriteFile("CMakeLists.txt", []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to update CMakeLists.txt: %w", err)
+		}
+		fmt.Printf("%s✓ Included it at the end of CMakeLists.txt%s\n", colors.Green, colors.Reset)
+	}
+
+	for _, dir := range sourceIncludeDirs(content) {
+		fmt.Printf("%sWarning: target_include_directories(%s) exports a source directory; installing fails until it is wrapped as $<BUILD_INTERFACE:...> with $<INSTALL_INTERFACE:include>%s\n", colors.Yellow, dir, colors.Reset)
+	}
+
+	fmt.Printf("\nExported targets: %s\n", strings.Join(prefixAll(pkg.Name+"::", pkg.Targets), ", "))
+	fmt.Printf("Install with:      cmake --install <build dir> --prefix <prefix>\n")
+	fmt.Printf("Consumers use:     find_package(%s CONFIG REQUIRED)\n", pkg.Name)
+	return nil
+}
+
+// cmakePackage describes the package of the library targets in a
+// CMakeLists.txt
+func cmakePackage(content string) (templates.CMakePackage, error) {
+	project := cmakeProjectPattern.FindStringSubmatch(content)
+	if project == nil {
+		return templates.CMakePackage{}, fmt.Errorf("no project() in CMakeLists.txt")
+	}
+	pkg := templates.CMakePackage{Name: project[1], InstallHeaders: CheckFileExists("include")}
+	if version := cmakeVersionPattern.FindStringSubmatch(project[2]); version != nil {
+		pkg.Version, pkg.HasVersion = version[1], true
+	} else {
+		_, pkg.Version = getProjectInfo()
+	}
+
+	for _, m := range cmakeLibraryPattern.FindAllStringSubmatch(content, -1) {
+		target, args := m[1], strings.Fields(m[2])
+		if slices.Contains(args, "IMPORTED") || slices.Contains(args, "ALIAS") {
+			continue
+		}
+		pkg.Targets = append(pkg.Targets, target)
+		if !strings.Contains(content, pkg.Name+"::"+target) {
+			pkg.Aliases = append(pkg.Aliases, target)
+		}
+	}
+	if len(pkg.Targets) == 0 {
+		return pkg, fmt.Errorf("no add_library() targets in CMakeLists.txt; cmake-package is for library projects")
+	}
+
+	for _, m := range cmakeFindPattern.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(testPackages, m[1]) && !slices.Contains(pkg.Dependencies, m[1]) {
+			pkg.Dependencies = append(pkg.Dependencies, m[1])
+		}
+	}
+	return pkg, nil
+}
+
+// sourceIncludeDirs returns the targets of target_include_directories calls
+// exporting source directories outside $<BUILD_INTERFACE:...>, which
+// install(EXPORT) rejects
+func sourceIncludeDirs(content string) []string {
+	var targets []string
+	for _, m := range cmakeIncludeDirPattern.FindAllStringSubmatch(content, -1) {
+		fields := strings.Fields(buildInterfacePattern.ReplaceAllString(m[1], ""))
+		if len(fields) == 0 {
+			continue
+		}
+		exported := false
+		for _, field := range fields[1:] {
+			switch field {
+			case "PUBLIC", "INTERFACE":
+				exported = true
+			case "PRIVATE":
+				exported = false
+			default:
+				if exported && strings.Contains(field, "SOURCE_DIR}") {
+					targets = append(targets, fields[0])
+				}
+			}
+		}
+	}
+	return slices.Compact(targets)
+}
+
+// prefixAll returns names with prefix
+func prefixAll(prefix string, names []string) []string {
+	prefixed := make([]string, len(names))
+	for i, name := range names {
+		prefixed[i] = prefix + name
+	}
+	return prefixed
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCMakePackage(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.Mkdir("include", 0755))
+	cmakeLists := `cmake_minimum_required(VERSION 3.20)
+project(mathlib VERSION 2.1.0 LANGUAGES CXX)
+find_package(fmt CONFIG REQUIRED)
+find_package(GTest CONFIG REQUIRED)
+
+add_library(mathlib STATIC src/mathlib.cpp)
+add_library(mathlib_headers INTERFACE)
+add_library(fmt_shim ALIAS mathlib)
+add_executable(tool src/main.cpp)
+
+target_include_directories(mathlib
+    PUBLIC
+        $<BUILD_INTERFACE:${CMAKE_CURRENT_SOURCE_DIR}/include>
+        $<INSTALL_INTERFACE:include>
+    PRIVATE ${CMAKE_CURRENT_SOURCE_DIR}/src
+)
+target_include_directories(mathlib_headers INTERFACE ${CMAKE_CURRENT_SOURCE_DIR}/include)
+`
+	require.NoError(t, os.WriteFile("CMakeLists.txt", []byte(cmakeLists), 0644))
+
+	require.NoError(t, runCMakePackage("SameMajorVersion", false))
+
+	install, err := os.ReadFile("cmake/mathlibInstall.cmake")
+	require.NoError(t, err)
+	assert.Contains(t, string(install), "install(TARGETS mathlib mathlib_headers\n    EXPORT mathlibTargets")
+	assert.Contains(t, string(install), "NAMESPACE mathlib::")
+	assert.Contains(t, string(install), "VERSION ${PROJECT_VERSION}\n    COMPATIBILITY SameMajorVersion")
+	assert.Contains(t, string(install), "install(DIRECTORY ${PROJECT_SOURCE_DIR}/include/")
+	assert.Contains(t, string(install), "add_library(mathlib::mathlib ALIAS mathlib)")
+	assert.NotContains(t, string(install), "tool")
+
+	config, err := os.ReadFile("cmake/mathlibConfig.cmake.in")
+	require.NoError(t, err)
+	assert.Equal(t, `@PACKAGE_INIT@
+
+include(CMakeFindDependencyMacro)
+find_dependency(fmt)
+
+include("${CMAKE_CURRENT_LIST_DIR}/mathlibTargets.cmake")
+check_required_components(mathlib)
+`, string(config))
+
+	updated, err := os.ReadFile("CMakeLists.txt")
+	require.NoError(t, err)
+	assert.Equal(t, cmakeLists+"\n# Install rules and CMake package\ninclude(cmake/mathlibInstall.cmake)\n", string(updated))
+	assert.Equal(t, []string{"mathlib_headers"}, sourceIncludeDirs(string(updated)))
+
+	assert.ErrorContains(t, runCMakePackage("SameMajorVersion", false), "already exists")
+	require.NoError(t, runCMakePackage("ExactVersion", true))
+	updated2, err := os.ReadFile("CMakeLists.txt")
+	require.NoError(t, err)
+	assert.Equal(t, string(updated), string(updated2), "the include is added once")
+
+	assert.ErrorContains(t, runCMakePackage("Newest", true), "--compatibility")
+}
+
+func TestRunCMakePackageExecutable(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("CMakeLists.txt", []byte("project(app)\nadd_executable(app main.cpp)\n"), 0644))
+	assert.ErrorContains(t, runCMakePackage("SameMajorVersion", false), "no add_library() targets")
+}
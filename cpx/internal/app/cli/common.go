@@ -108,6 +108,31 @@ func CheckFileExists(path string) bool {
 	return err == nil
 }
 
+// findVcpkg returns the vcpkg executable from 1) the cpx config, 2) VCPKG_ROOT
+// or 3) PATH, or "" if there is none
+func findVcpkg() string {
+	exe := "vcpkg"
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	var roots []string
+	if cfg, err := config.Load(); err == nil && cfg.VcpkgRoot != "" {
+		roots = append(roots, cfg.VcpkgRoot)
+	}
+	if root := os.Getenv("VCPKG_ROOT"); root != "" {
+		roots = append(roots, root)
+	}
+	for _, root := range roots {
+		if path := filepath.Join(root, exe); CheckFileExists(path) {
+			return path
+		}
+	}
+	if path, err := execLookPath("vcpkg"); err == nil {
+		return path
+	}
+	return ""
+}
+
 // CheckBuildToolsForProject checks if the required build tools are available for the project type
 // Returns a list of missing tools
 func CheckBuildToolsForProject(projectType ProjectType) []string {
@@ -116,33 +141,7 @@ func CheckBuildToolsForProject(projectType ProjectType) []string {
 	switch projectType {
 	case ProjectTypeVcpkg:
 		// vcpkg projects need vcpkg, cmake, make/ninja, and compilers
-		// Check for vcpkg: 1) cpx config, 2) VCPKG_ROOT env, 3) PATH
-		vcpkgFound := false
-		// First check cpx config
-		if cfg, err := config.LoadGlobal(); err == nil && cfg.VcpkgRoot != "" {
-			// Verify vcpkg executable exists at config path
-			vcpkgPath := filepath.Join(cfg.VcpkgRoot, "vcpkg")
-			if runtime.GOOS == "windows" {
-				vcpkgPath += ".exe"
-			}
-			if _, err := os.Stat(vcpkgPath); err == nil {
-				vcpkgFound = true
-			}
-		}
-		// Then check VCPKG_ROOT environment variable
-		if !vcpkgFound && os.Getenv("VCPKG_ROOT") != "" {
-			vcpkgPath := filepath.Join(os.Getenv("VCPKG_ROOT"), "vcpkg")
-			if runtime.GOOS == "windows" {
-				vcpkgPath += ".exe"
-			}
-			if _, err := os.Stat(vcpkgPath); err == nil {
-				vcpkgFound = true
-			}
-		}
-		// Finally check PATH
-		if !vcpkgFound && CheckCommandExists("vcpkg") {
-			vcpkgFound = true
-		}
+		vcpkgFound := findVcpkg() != ""
 		if !vcpkgFound {
 			missing = append(missing, "vcpkg (run 'cpx config set-vcpkg-root <path>' or set VCPKG_ROOT)")
 		}
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// compdbFile is the file name of a compilation database
+const compdbFile = "compile_commands.json"
+
+// CompdbCmd creates the compdb command
+func CompdbCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compdb",
+		Short: "Write compile_commands.json for clangd and IDEs",
+		Long: `Merge the compilation databases of cpx builds into compile_commands.json at
+the project root, where clangd and IDEs find it.
+
+Builds write a compilation database to their build directory: 'cpx build' to
+.cache/native/<variant> (or builddir for Meson) and each toolchain of
+'cpx ci build' to .cache/ci/<toolchain>. Paths of Docker builds, which compile
+in /workspace and /tmp/build, are rewritten to the project and build
+directories on the host. --target selects the builds to merge, 'native' or
+toolchain names, in order; by default all builds with a database are merged.
+When several builds compile a file, the first one's command is kept.
+
+Bazel projects need hedron_compile_commands instead.`,
+		Example: `  cpx compdb
+  cpx compdb --target linux-release
+  cpx compdb --target native --target linux-arm64`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, _ := cmd.Flags().GetStringSlice("target")
+			output, _ := cmd.Flags().GetString("output")
+			return runCompdb(targets, output)
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().StringSliceP("target", "t", nil, "Builds to merge: native or toolchain names (repeatable)")
+	cmd.Flags().StringP("output", "o", "", "Output file (default: compile_commands.json at the project root)")
+	return cmd
+}
+
+// compdbEntry is a command of a compilation database
+type compdbEntry struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Command   string   `json:"command,omitempty"`
+	Arguments []string `json:"arguments,omitempty"`
+	Output    string   `json:"output,omitempty"`
+}
+
+// compdbSource is the compilation database of a build, with the host
+// directories of the container paths in it
+type compdbSource struct {
+	Target string
+	Path   string
+	Mounts map[string]string // container path -> host path
+}
+
+func runCompdb(targets []string, output string) error {
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	if output == "" {
+		output = filepath.Join(projectRoot, compdbFile)
+	}
+
+	sources, err := compdbSources(projectRoot, targets)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("no compilation database found\n  hint: run 'cpx build' or 'cpx ci build' first")
+	}
+
+	var merged []compdbEntry
+	seen := make(map[string]bool)
+	for _, src := range sources {
+		entries, err := readCompdb(src)
+		if err != nil {
+			return err
+		}
+		added := 0
+		for _, e := range entries {
+			file := e.File
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(e.Directory, file)
+			}
+			if key := filepath.Clean(file); !seen[key] {
+				seen[key] = true
+				merged = append(merged, e)
+				added++
+			}
+		}
+		fmt.Printf("  %s✓ %s: %d of %d file(s)%s\n", colors.Green, src.Target, added, len(entries), colors.Reset)
+	}
+
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", compdbFile, err)
+	}
+	if err := os.WriteFile(output, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	fmt.Printf("%s✓ Wrote %s with %d file(s)%s\n", colors.Green, output, len(merged), colors.Reset)
+	return nil
+}
+
+// compdbSources returns the compilation databases of the named builds, or of
+// every build that has one when none are named
+func compdbSources(projectRoot string, targets []string) ([]compdbSource, error) {
+	var ciConfig *config.ToolchainConfig
+	if CheckFileExists(filepath.Join(projectRoot, "cpx-ci.yaml")) {
+		cfg, err := config.LoadToolchains(filepath.Join(projectRoot, "cpx-ci.yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+		}
+		ciConfig = cfg
+	}
+	cacheDir := configuredCICacheDir(projectRoot)
+
+	source := func(name string) (compdbSource, error) {
+		if name == "native" {
+			return compdbSource{Target: name, Path: nativeCompdb(projectRoot)}, nil
+		}
+		if ciConfig == nil {
+			return compdbSource{}, fmt.Errorf("toolchain '%s' not found: no cpx-ci.yaml", name)
+		}
+		// The runner may be inherited through extends
+		tc, err := ciConfig.ResolveToolchain(name)
+		if err != nil {
+			return compdbSource{}, fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+		}
+		if tc == nil {
+			return compdbSource{}, fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", name)
+		}
+		buildDir := filepath.Join(cacheDir, tc.Name)
+		src := compdbSource{Target: name, Path: filepath.Join(buildDir, compdbFile)}
+		if runner := ciConfig.FindRunner(tc.Runner); runner != nil && runner.IsDocker() {
+			src.Mounts = map[string]string{
+				"/workspace":        projectRoot,
+				"/tmp/build":        buildDir, // CMake
+				"/tmp/builddir":     buildDir, // Meson
+				"/tmp/.vcpkg_cache": filepath.Join(buildDir, ".vcpkg_cache"),
+			}
+		}
+		return src, nil
+	}
+
+	var sources []compdbSource
+	if len(targets) > 0 {
+		for _, name := range targets {
+			src, err := source(name)
+			if err != nil {
+				return nil, err
+			}
+			if src.Path == "" || !CheckFileExists(src.Path) {
+				return nil, fmt.Errorf("no compilation database for '%s'\n  hint: build it first", name)
+			}
+			sources = append(sources, src)
+		}
+		return sources, nil
+	}
+
+	names := []string{"native"}
+	if ciConfig != nil {
+		for _, tc := range ciConfig.Toolchains {
+			names = append(names, tc.Name)
+		}
+	}
+	for _, name := range names {
+		if src, err := source(name); err == nil && src.Path != "" && CheckFileExists(src.Path) {
+			sources = append(sources, src)
+		}
+	}
+	return sources, nil
+}
+
+// nativeCompdb returns the most recently written compilation database of
+// 'cpx build', or "" if there is none
+func nativeCompdb(projectRoot string) string {
+	candidates, _ := filepath.Glob(filepath.Join(projectRoot, ".cache", "native", "*", compdbFile))
+	candidates = append(candidates, filepath.Join(projectRoot, "builddir", compdbFile))
+	latest, latestTime := "", int64(0)
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.ModTime().UnixNano() > latestTime {
+			latest, latestTime = path, info.ModTime().UnixNano()
+		}
+	}
+	return latest
+}
+
+// readCompdb reads a compilation database, rewriting container paths to the
+// host paths they are mounted from
+func readCompdb(src compdbSource) ([]compdbEntry, error) {
+	data, err := os.ReadFile(src.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", src.Path, err)
+	}
+	var entries []compdbEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", src.Path, err)
+	}
+	if len(src.Mounts) == 0 {
+		return entries, nil
+	}
+	rewrite := containerPathRewriter(src.Mounts)
+	for i := range entries {
+		e := &entries[i]
+		e.Directory = rewrite(e.Directory)
+		e.File = rewrite(e.File)
+		e.Command = rewrite(e.Command)
+		e.Output = rewrite(e.Output)
+		for j := range e.Arguments {
+			e.Arguments[j] = rewrite(e.Arguments[j])
+		}
+	}
+	return entries, nil
+}
+
+// containerPathRewriter returns a function replacing the container paths of
+// mounts, at the start of a path or after a flag such as -I, with their host
+// paths. Longer container paths are matched first, so /tmp/builddir is not
+// taken for /tmp/build.
+func containerPathRewriter(mounts map[string]string) func(string) string {
+	paths := make([]string, 0, len(mounts))
+	for p := range mounts {
+		paths = append(paths, p)
+	}
+	slices.SortFunc(paths, func(a, b string) int { return len(b) - len(a) })
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = regexp.QuoteMeta(p)
+	}
+	pattern := regexp.MustCompile(`(^|[^/\w.]|-[A-Za-z]+)(` + strings.Join(quoted, "|") + `)\b`)
+	return func(s string) string {
+		return pattern.ReplaceAllStringFunc(s, func(m string) string {
+			sub := pattern.FindStringSubmatch(m)
+			return sub[1] + filepath.ToSlash(mounts[sub[2]])
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCompdb(t *testing.T, path string, entries []compdbEntry) {
+	t.Helper()
+	data, err := json.Marshal(entries)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func TestRunCompdb(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	require.NoError(t, os.WriteFile("CMakeLists.txt", []byte("project(app)\n"), 0644))
+	require.NoError(t, os.WriteFile("cpx-ci.yaml", []byte(`runners:
+  - name: linux
+    type: docker
+    image: ubuntu:24.04
+toolchains:
+  - name: linux-base
+    runner: linux
+  - name: linux-release
+    extends: linux-base
+`), 0644))
+
+	// The layout of a vcpkg build in .cache/ci/<toolchain>, with its ports installed in .vcpkg_cache
+	writeCompdb(t, filepath.Join(".cache", "ci", "linux-release", compdbFile), []compdbEntry{
+		{Directory: "/tmp/build", File: "/workspace/src/main.cpp", Command: "g++ -I/workspace/include -isystem /tmp/.vcpkg_cache/installed/x64-linux/include -o CMakeFiles/app.o -c /workspace/src/main.cpp"},
+		{Directory: "/tmp/build", File: "/workspace/src/util.cpp", Arguments: []string{"g++", "-I/workspace2/include", "-c", "/workspace/src/util.cpp"}},
+	})
+	nativeDir := filepath.Join(root, ".cache", "native", "debug")
+	writeCompdb(t, filepath.Join(nativeDir, compdbFile), []compdbEntry{
+		{Directory: nativeDir, File: filepath.Join(root, "src", "main.cpp"), Command: "c++ -c main.cpp"},
+	})
+
+	require.NoError(t, runCompdb(nil, ""))
+	data, err := os.ReadFile(filepath.Join(root, compdbFile))
+	require.NoError(t, err)
+	var entries []compdbEntry
+	require.NoError(t, json.Unmarshal(data, &entries))
+	require.Len(t, entries, 2, "main.cpp is taken from the native build only")
+	assert.Equal(t, "c++ -c main.cpp", entries[0].Command)
+
+	buildDir := filepath.Join(root, ".cache", "ci", "linux-release")
+	assert.Equal(t, buildDir, entries[1].Directory)
+	assert.Equal(t, filepath.Join(root, "src", "util.cpp"), entries[1].File)
+	assert.Equal(t, []string{"g++", "-I/workspace2/include", "-c", filepath.Join(root, "src", "util.cpp")}, entries[1].Arguments)
+
+	require.NoError(t, runCompdb([]string{"linux-release"}, "out.json"))
+	data, err = os.ReadFile("out.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &entries))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "g++ -I"+root+"/include -isystem "+buildDir+"/.vcpkg_cache/installed/x64-linux/include -o CMakeFiles/app.o -c "+root+"/src/main.cpp", entries[0].Command)
+
+	assert.ErrorContains(t, runCompdb([]string{"missing"}, ""), "toolchain 'missing' not found")
+}
+
+func TestContainerPathRewriter(t *testing.T) {
+	rewrite := containerPathRewriter(map[string]string{"/tmp/build": "/b", "/tmp/builddir": "/m", "/workspace": "/w"})
+	assert.Equal(t, "/m/src", rewrite("/tmp/builddir/src"))
+	assert.Equal(t, "/b", rewrite("/tmp/build"))
+	assert.Equal(t, "cc -I/w/include -o /b/x.o /w/a.c", rewrite("cc -I/workspace/include -o /tmp/build/x.o /workspace/a.c"))
+	assert.Equal(t, "/opt/workspace/a.c /tmp/buildx", rewrite("/opt/workspace/a.c /tmp/buildx"))
+}
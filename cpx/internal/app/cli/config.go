@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/ozacod/cpx/internal/pkg/utils/colors"
 	"github.com/ozacod/cpx/pkg/config"
@@ -15,12 +16,37 @@ func ConfigCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "config",
 		Short: "Manage cpx configuration",
-		Long:  "Manage cpx configuration. Use subcommands to get or set configuration values.",
-		RunE:  runConfigShow,
+		Long: `Manage the global cpx configuration in ~/.config/cpx/config.yaml
+(%APPDATA%\cpx\config.yaml on Windows). Without a subcommand, every setting is shown.
+
+A project may override any key in a .cpx.yaml at its root (or in any parent
+of the current directory). Precedence, highest first: .cpx.yaml, the global
+config, the defaults. 'get', 'list' and the overview show the effective
+values; --show-origin shows which file set each one. 'set' and 'unset'
+change the global config only.
+
+Keys:
+  vcpkg_root, bcr_root, wrapdb_root  package registries (existing directories)
+  build_type                         debug or release, for cpx build and cpx run
+  jobs                               parallel build jobs, for cpx build
+  color                              auto, always or never
+  docker_host, docker_context        Docker engine of runners that set none
+  registry                           prefix of 'cpx ci image' repositories
+  relocate_wsl_caches                keep CI caches on the WSL filesystem
+  analyze_skip, semgrep_rules,
+  analyzers                          deprecated: use .cpx-quality.yaml`,
+		Example: `  cpx config set vcpkg_root ~/vcpkg
+  cpx config set docker_host ssh://ci@build-box
+  cpx config get color
+  cpx config unset registry
+  cpx config list
+  cpx config --show-origin`,
+		RunE: runConfigShow,
 	}
+	cmd.Flags().Bool("show-origin", false, "Show the file each value comes from")
 
 	getCmd := &cobra.Command{
-		Use:   "get",
+		Use:   "get <key>",
 		Short: "Get config value",
 		Long:  "Get a configuration value by key.",
 		RunE:  runConfigGet,
@@ -28,10 +54,35 @@ func ConfigCmd() *cobra.Command {
 	}
 	cmd.AddCommand(getCmd)
 
+	setCmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set config value",
+		Long:  "Set a configuration value. Directories must exist and are stored as absolute paths.",
+		RunE:  runConfigSet,
+		Args:  cobra.ExactArgs(2),
+	}
+	cmd.AddCommand(setCmd)
+
+	unsetCmd := &cobra.Command{
+		Use:   "unset <key>",
+		Short: "Reset config value to its default",
+		RunE:  runConfigUnset,
+		Args:  cobra.ExactArgs(1),
+	}
+	cmd.AddCommand(unsetCmd)
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List config values as key=value",
+		RunE:  runConfigList,
+		Args:  cobra.NoArgs,
+	}
+	cmd.AddCommand(listCmd)
+
 	setVcpkgRootCmd := &cobra.Command{
 		Use:   "set-vcpkg-root",
 		Short: "Set vcpkg root directory",
-		Long:  "Set the vcpkg root directory path.",
+		Long:  "Set the vcpkg root directory path (same as 'cpx config set vcpkg_root <path>').",
 		RunE:  runConfigSetVcpkgRoot,
 		Args:  cobra.ExactArgs(1),
 	}
@@ -40,7 +91,7 @@ func ConfigCmd() *cobra.Command {
 	setBcrRootCmd := &cobra.Command{
 		Use:   "set-bcr-root",
 		Short: "Set Bazel Central Registry root directory",
-		Long:  "Set the path to the cloned Bazel Central Registry repository.",
+		Long:  "Set the path to the cloned Bazel Central Registry repository (same as 'cpx config set bcr_root <path>').",
 		RunE:  runConfigSetBcrRoot,
 		Args:  cobra.ExactArgs(1),
 	}
@@ -49,7 +100,7 @@ func ConfigCmd() *cobra.Command {
 	setWrapdbRootCmd := &cobra.Command{
 		Use:   "set-wrapdb-root",
 		Short: "Set Meson WrapDB root directory",
-		Long:  "Set the path to the downloaded Meson WrapDB wraps.",
+		Long:  "Set the path to the downloaded Meson WrapDB wraps (same as 'cpx config set wrapdb_root <path>').",
 		RunE:  runConfigSetWrapdbRoot,
 		Args:  cobra.ExactArgs(1),
 	}
@@ -58,7 +109,11 @@ func ConfigCmd() *cobra.Command {
 	return cmd
 }
 
-func runConfigShow(_ *cobra.Command, _ []string) error {
+func runConfigShow(cmd *cobra.Command, _ []string) error {
+	showOrigin, _ := cmd.Flags().GetBool("show-origin")
+	if showOrigin {
+		return showConfigOrigins()
+	}
 	return showConfig()
 }
 
@@ -66,6 +121,18 @@ func runConfigGet(_ *cobra.Command, args []string) error {
 	return getConfig(args[0])
 }
 
+func runConfigSet(_ *cobra.Command, args []string) error {
+	return setConfig(args[0], args[1])
+}
+
+func runConfigUnset(_ *cobra.Command, args []string) error {
+	return unsetConfig(args[0])
+}
+
+func runConfigList(_ *cobra.Command, _ []string) error {
+	return listConfig()
+}
+
 func runConfigSetVcpkgRoot(_ *cobra.Command, args []string) error {
 	return setVcpkgRoot(args[0])
 }
@@ -84,7 +151,7 @@ func showConfig() error {
 		return fmt.Errorf("failed to get config path: %w", err)
 	}
 
-	cfg, err := config.LoadGlobal()
+	cfg, err := config.Load()
 	if err != nil {
 		fmt.Printf("%sCpx Configuration%s\n", colors.Bold, colors.Reset)
 		fmt.Printf("  Config file: %s\n", configPath)
@@ -94,127 +161,161 @@ func showConfig() error {
 
 	fmt.Printf("%sCpx Configuration%s\n", colors.Bold, colors.Reset)
 	fmt.Printf("  Config file: %s\n", configPath)
-	fmt.Printf("  vcpkg_root:  %s\n", cfg.VcpkgRoot)
-	fmt.Printf("  bcr_root:    %s\n", cfg.BcrRoot)
-	fmt.Printf("  wrapdb_root: %s\n", cfg.WrapdbRoot)
+	if projectPath := config.FindProjectConfig(); projectPath != "" {
+		fmt.Printf("  Project file: %s\n", projectPath)
+	}
+	keys := config.SettingKeys()
+	width := 0
+	for _, key := range keys {
+		width = max(width, len(key)+1)
+	}
+	for _, key := range keys {
+		value, _ := cfg.Get(key)
+		fmt.Printf("  %-*s %s\n", width, key+":", value)
+	}
 	return nil
 }
 
-func getConfig(key string) error {
-	cfg, err := config.LoadGlobal()
+// showConfigOrigins prints every effective value with the file that set it,
+// like git config --show-origin
+func showConfigOrigins() error {
+	cfg, origins, err := config.LoadWithOrigins()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
-
-	switch key {
-	case "vcpkg_root", "vcpkg-root":
-		fmt.Println(cfg.VcpkgRoot)
-		return nil
-	case "bcr_root", "bcr-root":
-		fmt.Println(cfg.BcrRoot)
-		return nil
-	case "wrapdb_root", "wrapdb-root":
-		fmt.Println(cfg.WrapdbRoot)
-		return nil
-	default:
-		return fmt.Errorf("unknown config key: %s", key)
+	for _, key := range config.SettingKeys() {
+		value, _ := cfg.Get(key)
+		origin := origins[key]
+		if origin == "" {
+			origin = "default"
+		}
+		fmt.Printf("%s%-40s%s %s=%s\n", colors.Gray, origin, colors.Reset, key, value)
 	}
+	return nil
 }
 
-func setVcpkgRoot(path string) error {
-	// Validate path exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("path does not exist: %s", path)
+func listConfig() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	for _, key := range config.SettingKeys() {
+		value, _ := cfg.Get(key)
+		fmt.Printf("%s=%s\n", key, value)
 	}
+	return nil
+}
 
-	// Check if it looks like a vcpkg directory
-	vcpkgExe := filepath.Join(path, "vcpkg")
-	if runtime.GOOS == "windows" {
-		vcpkgExe += ".exe"
+func getConfig(key string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
 	}
-	if _, err := os.Stat(vcpkgExe); os.IsNotExist(err) {
-		fmt.Printf("%s Warning: %s does not appear to be a vcpkg directory%s\n", colors.Yellow, path, colors.Reset)
-		fmt.Printf("  (vcpkg executable not found at %s)\n", vcpkgExe)
+	value, err := cfg.Get(key)
+	if err != nil {
+		return err
 	}
+	fmt.Println(value)
+	return nil
+}
 
+// setConfig sets a key in the global config, warning when a registry
+// directory does not look like one
+func setConfig(key, value string) error {
 	cfg, err := config.LoadGlobal()
 	if err != nil {
 		cfg = &config.GlobalConfig{}
 	}
-
-	// Convert to absolute path
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+	if err := cfg.Set(key, value); err != nil {
+		return err
 	}
+	key = strings.ReplaceAll(key, "-", "_")
+	value, _ = cfg.Get(key)
 
-	cfg.VcpkgRoot = absPath
+	switch key {
+	case "vcpkg_root":
+		vcpkgExe := filepath.Join(value, "vcpkg")
+		if runtime.GOOS == "windows" {
+			vcpkgExe += ".exe"
+		}
+		if _, err := os.Stat(vcpkgExe); os.IsNotExist(err) {
+			fmt.Printf("%s Warning: %s does not appear to be a vcpkg directory%s\n", colors.Yellow, value, colors.Reset)
+			fmt.Printf("  (vcpkg executable not found at %s)\n", vcpkgExe)
+		}
+	case "bcr_root":
+		modulesDir := filepath.Join(value, "modules")
+		if _, err := os.Stat(modulesDir); os.IsNotExist(err) {
+			fmt.Printf("%s Warning: %s does not appear to be a BCR directory%s\n", colors.Yellow, value, colors.Reset)
+			fmt.Printf("  (modules directory not found at %s)\n", modulesDir)
+		}
+	}
 
 	if err := config.SaveGlobal(cfg); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
-
-	fmt.Printf("%s Set vcpkg_root to %s%s\n", colors.Green, absPath, colors.Reset)
+	fmt.Printf("%s✓ Set %s to %s%s\n", colors.Green, key, value, colors.Reset)
+	warnProjectOverride(key)
 	return nil
 }
 
-func setBcrRoot(path string) error {
-	// Validate path exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("path does not exist: %s", path)
-	}
-
-	// Check if it looks like a BCR directory
-	modulesDir := filepath.Join(path, "modules")
-	if _, err := os.Stat(modulesDir); os.IsNotExist(err) {
-		fmt.Printf("%s Warning: %s does not appear to be a BCR directory%s\n", colors.Yellow, path, colors.Reset)
-		fmt.Printf("  (modules directory not found at %s)\n", modulesDir)
+// warnProjectOverride notes when the current project's .cpx.yaml overrides
+// a key just changed in the global config
+func warnProjectOverride(key string) {
+	if _, origins, err := config.LoadWithOrigins(); err == nil {
+		if origin := origins[key]; origin != "" && filepath.Base(origin) == config.ProjectConfigFile {
+			fmt.Printf("  %snote: %s overrides %s in this project%s\n", colors.Yellow, origin, key, colors.Reset)
+		}
 	}
+}
 
+func unsetConfig(key string) error {
 	cfg, err := config.LoadGlobal()
 	if err != nil {
-		cfg = &config.GlobalConfig{}
+		return fmt.Errorf("failed to load config: %w", err)
 	}
-
-	// Convert to absolute path
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+	if err := cfg.Unset(key); err != nil {
+		return err
 	}
-
-	cfg.BcrRoot = absPath
-
 	if err := config.SaveGlobal(cfg); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
-
-	fmt.Printf("%s✓ Set bcr_root to %s%s\n", colors.Green, absPath, colors.Reset)
+	key = strings.ReplaceAll(key, "-", "_")
+	fmt.Printf("%s✓ Unset %s%s\n", colors.Green, key, colors.Reset)
+	warnProjectOverride(key)
 	return nil
 }
 
+func setVcpkgRoot(path string) error {
+	return setConfig("vcpkg_root", path)
+}
+
+func setBcrRoot(path string) error {
+	return setConfig("bcr_root", path)
+}
+
 func setWrapdbRoot(path string) error {
-	// Validate path exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("path does not exist: %s", path)
-	}
+	return setConfig("wrapdb_root", path)
+}
 
-	cfg, err := config.LoadGlobal()
-	if err != nil {
-		cfg = &config.GlobalConfig{}
+// ApplyColorPreference turns colored output off when the configured color
+// is never, or, by default, when stdout is not a terminal or NO_COLOR is set
+func ApplyColorPreference() {
+	preference := "auto"
+	if cfg, err := config.Load(); err == nil && cfg.Color != "" {
+		preference = cfg.Color
 	}
-
-	// Convert to absolute path
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+	switch preference {
+	case "always":
+		return
+	case "never":
+		colors.Disable()
+		return
 	}
-
-	cfg.WrapdbRoot = absPath
-
-	if err := config.SaveGlobal(cfg); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		colors.Disable()
+		return
+	}
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		colors.Disable()
 	}
-
-	fmt.Printf("%s✓ Set wrapdb_root to %s%s\n", colors.Green, absPath, colors.Reset)
-	return nil
 }
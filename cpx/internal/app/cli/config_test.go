@@ -369,3 +369,49 @@ func TestSetWrapdbRoot(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigSetUnset(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	// Capture output
+	old := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() {
+		w.Close()
+		os.Stdout = old
+	}()
+
+	require.NoError(t, setConfig("color", "never"))
+	require.NoError(t, setConfig("docker-host", "ssh://ci@build-box"))
+	require.NoError(t, setConfig("relocate_wsl_caches", "true"))
+	require.NoError(t, setConfig("wrapdb_root", tmpDir))
+
+	assert.EqualError(t, setConfig("color", "sometimes"), "color must be one of auto, always, never, not 'sometimes'")
+	assert.EqualError(t, setConfig("docker_host", "build-box"), "docker_host must be a DOCKER_HOST value such as unix:///var/run/docker.sock or ssh://user@host, not 'build-box'")
+	assert.EqualError(t, setConfig("docker_context", "remote"), "set either docker_host or docker_context, not both")
+	assert.EqualError(t, setConfig("relocate_wsl_caches", "maybe"), "relocate_wsl_caches must be true or false, not 'maybe'")
+	assert.EqualError(t, setConfig("bcr_root", filepath.Join(tmpDir, "missing")), "path does not exist: "+filepath.Join(tmpDir, "missing"))
+	assert.EqualError(t, setConfig("colour", "never"), "unknown config key: colour (did you mean 'color'?)")
+
+	cfg, err := config.LoadGlobal()
+	require.NoError(t, err)
+	assert.Equal(t, "never", cfg.Color)
+	assert.Equal(t, "ssh://ci@build-box", cfg.DockerHost)
+	assert.Empty(t, cfg.DockerContext)
+	assert.Equal(t, tmpDir, cfg.WrapdbRoot)
+	require.NotNil(t, cfg.RelocateWSLCaches)
+	assert.True(t, *cfg.RelocateWSLCaches)
+
+	require.NoError(t, unsetConfig("docker_host"))
+	require.NoError(t, unsetConfig("relocate-wsl-caches"))
+	assert.Error(t, unsetConfig("unknown_key"))
+	cfg, err = config.LoadGlobal()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.DockerHost)
+	assert.Nil(t, cfg.RelocateWSLCaches)
+	value, err := cfg.Get("relocate_wsl_caches")
+	require.NoError(t, err)
+	assert.Equal(t, "false", value)
+}
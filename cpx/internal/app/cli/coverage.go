@@ -0,0 +1,242 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/bazel"
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/build/vcpkg"
+	"github.com/ozacod/cpx/internal/pkg/coverage"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func CoverageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "coverage",
+		Short: "Measure the test coverage of the project",
+		Long: `Build the tests with coverage instrumentation, run them and write the
+coverage as an lcov report (coverage/coverage.lcov) and an HTML report
+(coverage/html/index.html).
+
+The compiler ($CXX, else c++; $CC, else cc for Bazel) picks the
+instrumentation: clang's source-based coverage, read with llvm-profdata and
+llvm-cov, or gcc's gcov counters, read with gcovr, or lcov and genhtml without
+it. --tool picks another of them.
+
+CMake projects build the tests in .cache/native/coverage, apart from
+'cpx test'; Bazel projects run 'bazel coverage'. Files of build directories
+and dependencies are left out and paths made relative to the project. The
+report is written even when tests fail, and the command then fails.
+
+--min-lines, --min-functions and --min-branches, or the coverage: section
+of .cpx-quality.yaml, make the command fail when a coverage percentage is
+below them, listing the directories with the most uncovered code.`,
+		Example: `  cpx coverage
+  cpx coverage --output reports/coverage --no-html
+  cpx coverage --tool lcov --filter 'Parser.*'
+  cpx coverage --min-lines 80 --min-branches 60`,
+		Args: cobra.NoArgs,
+		RunE: runCoverage,
+	}
+
+	cmd.Flags().BoolP("verbose", "v", false, "Show verbose build, test and coverage tool output")
+	cmd.Flags().String("filter", "", "Filter tests by name (ctest regex or bazel target)")
+	cmd.Flags().String("output", "coverage", "Directory of the lcov and HTML reports")
+	cmd.Flags().String("tool", "", "Coverage tool: llvm-cov, gcovr or lcov (default: by compiler)")
+	cmd.Flags().Bool("no-html", false, "Write only the lcov report")
+	cmd.Flags().Float64("min-lines", 0, "Fail when less than this percentage of lines is covered")
+	cmd.Flags().Float64("min-functions", 0, "Fail when less than this percentage of functions is covered")
+	cmd.Flags().Float64("min-branches", 0, "Fail when less than this percentage of branches is covered")
+
+	return cmd
+}
+
+func runCoverage(cmd *cobra.Command, _ []string) error {
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	filter, _ := cmd.Flags().GetString("filter")
+	output, _ := cmd.Flags().GetString("output")
+	tool, _ := cmd.Flags().GetString("tool")
+	noHTML, _ := cmd.Flags().GetBool("no-html")
+	thresholds, err := coverageThresholds(cmd)
+	if err != nil {
+		return err
+	}
+
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(root, output)
+	}
+	opts := coverage.Options{Root: root, OutputDir: output, HTML: !noHTML, Verbose: verbose}
+	testOpts := build.TestOptions{Verbose: verbose, Filter: filter}
+
+	var testErr error
+	var report coverage.Report
+	switch DetectProjectType() {
+	case ProjectTypeVcpkg:
+		// A configured build keeps its compiler, whatever $CXX is now
+		opts.BuildDir = filepath.Join(root, build.CoverageBuildDir)
+		testOpts.Coverage = coverage.DetectCompiler(cmp.Or(coverage.CachedCompiler(opts.BuildDir), os.Getenv("CXX"), "c++"))
+		if opts.Tool, err = coverage.SelectTool(testOpts.Coverage, tool); err != nil {
+			return err
+		}
+		if err := coverage.Clean(opts.BuildDir); err != nil {
+			return err
+		}
+		fmt.Printf("%sMeasuring coverage with %s (%s instrumentation)...%s\n", colors.Cyan, opts.Tool, testOpts.Coverage, colors.Reset)
+		testErr = vcpkg.New().Test(context.Background(), testOpts)
+		report, err = coverage.CMakeReport(opts)
+	case ProjectTypeBazel:
+		testOpts.Coverage = coverage.DetectCompiler(cmp.Or(os.Getenv("CC"), "cc"))
+		if testOpts.Coverage == build.CoverageClang {
+			if _, err := coverage.SelectTool(testOpts.Coverage, ""); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%sMeasuring coverage with bazel coverage (%s instrumentation)...%s\n", colors.Cyan, testOpts.Coverage, colors.Reset)
+		testErr = bazel.New().Test(context.Background(), testOpts)
+		report, err = coverage.BazelReport(opts)
+	case ProjectTypeMeson:
+		return fmt.Errorf("cpx coverage supports CMake and Bazel projects; for Meson, configure with -Db_coverage=true and run 'ninja coverage'")
+	default:
+		return fmt.Errorf("could not detect project type (no MODULE.bazel or vcpkg.json found)")
+	}
+
+	if err != nil {
+		if testErr != nil {
+			return testErr
+		}
+		return fmt.Errorf("failed to write coverage report: %w", err)
+	}
+	printCoverageSummary(os.Stdout, report.Summary())
+	fmt.Printf("%sCoverage report saved to: %s%s\n", colors.Green, filepath.Join(output, coverage.LcovFile), colors.Reset)
+	if index := filepath.Join(output, coverage.HTMLDir, "index.html"); opts.HTML {
+		if _, err := os.Stat(index); err == nil {
+			fmt.Printf("%sHTML report: %s%s\n", colors.Green, index, colors.Reset)
+		}
+	}
+
+	shortfalls := report.Summary().Check(thresholds)
+	if len(shortfalls) == 0 {
+		return testErr
+	}
+	printCoverageShortfalls(os.Stdout, report, shortfalls)
+	if testErr != nil {
+		return testErr
+	}
+	return coverageGateError(shortfalls)
+}
+
+// coverageGateError returns the error of coverage below its thresholds
+func coverageGateError(shortfalls []coverage.Shortfall) error {
+	var below []string
+	for _, s := range shortfalls {
+		below = append(below, s.String())
+	}
+	return fmt.Errorf("coverage is below the thresholds: %s", strings.Join(below, ", "))
+}
+
+// qualityCoverageThresholds returns the coverage thresholds of the project's
+// .cpx-quality.yaml
+func qualityCoverageThresholds() (coverage.Thresholds, error) {
+	cfg, err := config.LoadQuality(config.FindQualityConfig())
+	if err != nil {
+		return coverage.Thresholds{}, err
+	}
+	return coverage.Thresholds{
+		Lines:     cfg.Coverage.MinLines,
+		Functions: cfg.Coverage.MinFunctions,
+		Branches:  cfg.Coverage.MinBranches,
+	}, nil
+}
+
+// coverageThresholds returns the coverage thresholds of the --min-* flags,
+// else of the project's .cpx-quality.yaml
+func coverageThresholds(cmd *cobra.Command) (coverage.Thresholds, error) {
+	thresholds, err := qualityCoverageThresholds()
+	if err != nil {
+		return thresholds, err
+	}
+	for _, flag := range []struct {
+		name      string
+		threshold *float64
+	}{
+		{"min-lines", &thresholds.Lines},
+		{"min-functions", &thresholds.Functions},
+		{"min-branches", &thresholds.Branches},
+	} {
+		if !cmd.Flags().Changed(flag.name) {
+			continue
+		}
+		value, _ := cmd.Flags().GetFloat64(flag.name)
+		if value < 0 || value > 100 {
+			return coverage.Thresholds{}, fmt.Errorf("--%s must be a percentage from 0 to 100, not %g", flag.name, value)
+		}
+		*flag.threshold = value
+	}
+	return thresholds, nil
+}
+
+// coverageLaggards is the number of directories listed for each coverage
+// below its threshold
+const coverageLaggards = 10
+
+// printCoverageShortfalls prints the coverage below its thresholds and the
+// directories with the most uncovered lines, functions or branches
+func printCoverageShortfalls(w io.Writer, report coverage.Report, shortfalls []coverage.Shortfall) {
+	fmt.Fprintln(w, "Coverage is below the thresholds:")
+	for _, s := range shortfalls {
+		name := strings.ToUpper(s.Kind[:1]) + s.Kind[1:] + ":"
+		if s.Unmeasured {
+			fmt.Fprintf(w, "  %-10s  %6s < %g%% (no %s were measured)\n", name, "n/a", s.Min, s.Kind)
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s  %5.1f%% < %g%%\n", name, s.Percent, s.Min)
+	}
+	dirs := report.ByDirectory()
+	for _, s := range shortfalls {
+		laggards := coverage.Laggards(dirs, s.Kind, coverageLaggards)
+		if len(laggards) == 0 {
+			continue
+		}
+		width := 0
+		for _, dir := range laggards {
+			width = max(width, len(dir.Dir))
+		}
+		fmt.Fprintf(w, "Directories with the most uncovered %s:\n", s.Kind)
+		for _, dir := range laggards {
+			counts := dir.Counts(s.Kind)
+			fmt.Fprintf(w, "  %-*s  %5.1f%% (%d/%d)  %d uncovered\n", width, dir.Dir, counts.Percent(), counts.Hit, counts.Found, counts.Found-counts.Hit)
+		}
+	}
+}
+
+// printCoverageSummary prints the line, function and branch coverage of a
+// summary
+func printCoverageSummary(w io.Writer, s coverage.Summary) {
+	fmt.Fprintln(w, "Coverage:")
+	for _, row := range []struct {
+		name   string
+		counts coverage.Counts
+	}{
+		{"Lines", s.Lines},
+		{"Functions", s.Functions},
+		{"Branches", s.Branches},
+	} {
+		if row.counts.Found == 0 {
+			fmt.Fprintf(w, "  %-10s  %5s\n", row.name+":", "n/a")
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s  %5.1f%% (%d/%d)\n", row.name+":", row.counts.Percent(), row.counts.Hit, row.counts.Found)
+	}
+}
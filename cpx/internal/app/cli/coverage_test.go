@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ozacod/cpx/internal/pkg/coverage"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintCoverageSummary(t *testing.T) {
+	var out bytes.Buffer
+	printCoverageSummary(&out, coverage.Summary{
+		Lines:     coverage.Counts{Found: 400, Hit: 330},
+		Functions: coverage.Counts{Found: 50, Hit: 45},
+	})
+	assert.Equal(t, `Coverage:
+  Lines:       82.5% (330/400)
+  Functions:   90.0% (45/50)
+  Branches:     n/a
+`, out.String())
+}
+
+func TestCoverageThresholds(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile(config.QualityConfigFile, []byte("coverage:\n  min_lines: 80\n  min_branches: 50\n"), 0644))
+
+	cmd := CoverageCmd()
+	require.NoError(t, cmd.ParseFlags([]string{"--min-branches", "60", "--min-functions", "70"}))
+	thresholds, err := coverageThresholds(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, coverage.Thresholds{Lines: 80, Functions: 70, Branches: 60}, thresholds)
+
+	cmd = CoverageCmd()
+	require.NoError(t, cmd.ParseFlags([]string{"--min-lines", "120"}))
+	_, err = coverageThresholds(cmd)
+	assert.EqualError(t, err, "--min-lines must be a percentage from 0 to 100, not 120")
+}
+
+func TestPrintCoverageShortfalls(t *testing.T) {
+	report, err := coverage.ParseLcov(strings.NewReader(`SF:src/net/socket.cpp
+DA:1,1
+DA:2,0
+DA:3,0
+DA:4,0
+end_of_record
+SF:src/net/http.cpp
+DA:1,0
+DA:2,1
+end_of_record
+SF:src/core/app.cpp
+DA:1,1
+DA:2,0
+end_of_record
+SF:main.cpp
+DA:1,1
+end_of_record
+`))
+	require.NoError(t, err)
+	shortfalls := report.Summary().Check(coverage.Thresholds{Lines: 80})
+	require.Len(t, shortfalls, 1)
+
+	var out bytes.Buffer
+	printCoverageShortfalls(&out, report, shortfalls)
+	assert.Equal(t, `Coverage is below the thresholds:
+  Lines:       44.4% < 80%
+Directories with the most uncovered lines:
+  src/net    33.3% (2/6)  4 uncovered
+  src/core   50.0% (1/2)  1 uncovered
+`, out.String())
+
+	// The report has no branch data to check a branch threshold against
+	shortfalls = report.Summary().Check(coverage.Thresholds{Lines: 40, Branches: 60})
+	require.Len(t, shortfalls, 1)
+	out.Reset()
+	printCoverageShortfalls(&out, report, shortfalls)
+	assert.Equal(t, "Coverage is below the thresholds:\n  Branches:      n/a < 60% (no branches were measured)\n", out.String())
+	assert.EqualError(t, coverageGateError(shortfalls), "coverage is below the thresholds: branches not measured (min 60%)")
+}
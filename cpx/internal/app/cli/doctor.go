@@ -0,0 +1,297 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// minCacheSpace is the free disk space below which doctor reports the build
+// caches' disk as too full
+const minCacheSpace = 5 << 30
+
+// DoctorCmd creates the doctor command
+func DoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment cpx builds in",
+		Long: `Check the environment cpx builds in: the container runtime, buildx and QEMU
+emulation, the build tools the project needs, free disk space for the build
+caches and the Dockerfiles cpx-ci.yaml refers to. Every problem comes with a
+fix; the command fails when a required check fails.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor()
+		},
+		Args: cobra.NoArgs,
+	}
+}
+
+// doctorCheck is the outcome of one doctor check
+type doctorCheck struct {
+	name     string
+	found    string // what was found, e.g. a version; "" when the check failed
+	fix      string // how to fix a failed check
+	optional bool   // a failure is only a warning
+}
+
+func runDoctor() error {
+	fmt.Printf("%sContainer runtime%s\n", colors.Bold, colors.Reset)
+	problems := printChecks(printContainerRuntime())
+
+	projectType := DetectProjectType()
+	fmt.Printf("\n%sBuild tools%s (%s project)\n", colors.Bold, colors.Reset, projectType)
+	problems += printChecks(buildToolChecks(projectType))
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	fmt.Printf("\n%sDisk space%s\n", colors.Bold, colors.Reset)
+	problems += printChecks([]doctorCheck{diskSpaceCheck(ciCacheDir(projectRoot))})
+
+	if ciPath := filepath.Join(projectRoot, "cpx-ci.yaml"); CheckFileExists(ciPath) {
+		fmt.Printf("\n%scpx-ci.yaml%s\n", colors.Bold, colors.Reset)
+		problems += printChecks(ciConfigChecks(ciPath, projectRoot))
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found", problems)
+	}
+	fmt.Printf("\n%s No problems found%s\n", colors.Green, colors.Reset)
+	return nil
+}
+
+// printChecks prints checks with the fixes of failed ones and returns how
+// many required checks failed
+func printChecks(checks []doctorCheck) int {
+	problems := 0
+	for _, c := range checks {
+		switch {
+		case c.found != "":
+			fmt.Printf("  %s✓ %s%s %s\n", colors.Green, c.name, colors.Reset, c.found)
+			continue
+		case c.optional:
+			fmt.Printf("  %s- %s%s\n", colors.Yellow, c.name, colors.Reset)
+		default:
+			fmt.Printf("  %s✗ %s%s\n", colors.Red, c.name, colors.Reset)
+			problems++
+		}
+		if c.fix != "" {
+			fmt.Printf("      %sfix: %s%s\n", colors.Gray, c.fix, colors.Reset)
+		}
+	}
+	return problems
+}
+
+// printContainerRuntime reports the engine the docker CLI will talk to and
+// returns the checks of the CLI, the engine, buildx and QEMU emulation
+func printContainerRuntime() []doctorCheck {
+	rt := docker.DetectRuntime()
+	fmt.Printf("  Engine:  %s\n", rt.Name)
+	if rt.Context != "" {
+		fmt.Printf("  Context: %s\n", rt.Context)
+	}
+	if rt.Socket != "" {
+		fmt.Printf("  Socket:  %s\n", rt.Socket)
+	}
+	if docker.IsWSL2() {
+		fmt.Printf("  WSL2:    yes\n")
+	}
+	if rt.Host != "" {
+		fmt.Printf("  %sDefault socket missing; cpx uses DOCKER_HOST=%s%s\n", colors.Gray, rt.Host, colors.Reset)
+	}
+	var checks []doctorCheck
+	if len(rt.SharedPaths) > 0 {
+		fmt.Printf("  VM mounts: %s\n", strings.Join(rt.SharedPaths, ", "))
+		if cwd, err := os.Getwd(); err == nil && len(rt.Unshared(cwd)) > 0 {
+			checks = append(checks, doctorCheck{
+				name: fmt.Sprintf("%s is mounted in the %s VM", cwd, rt.Name),
+				fix:  fmt.Sprintf("add the directory to the %s VM's mounts, or move the project under %s", rt.Name, rt.SharedPaths[0]),
+			})
+		}
+	}
+
+	if !CheckCommandExists("docker") {
+		fix := "install Docker (https://docs.docker.com/get-docker/) or Podman"
+		if CheckCommandExists("podman") {
+			fix = "Podman is installed: install podman-docker, or link docker to podman on PATH"
+		}
+		return append(checks, doctorCheck{name: "docker CLI", fix: fix})
+	}
+	checks = append(checks, doctorCheck{name: "docker CLI", found: toolVersion("docker", "--version")})
+
+	engine := doctorCheck{name: "Engine reachable", fix: "start Docker Desktop, Colima ('colima start') or the docker service ('sudo systemctl start docker'); on Linux, add yourself to the docker group"}
+	out, err := docker.Command("version", "--format", "{{.Server.Version}}").Output()
+	if err != nil {
+		// Without an engine, buildx and QEMU cannot be checked
+		return append(checks, engine)
+	}
+	engine.found = "(server " + strings.TrimSpace(string(out)) + ")"
+	checks = append(checks, engine)
+
+	buildx := doctorCheck{name: "buildx", optional: true, fix: "install the docker buildx plugin; 'cpx ci bake' and runner image caches (cache_from/cache_to) need it"}
+	if out, err := docker.Command("buildx", "version").Output(); err == nil {
+		buildx.found = strings.TrimSpace(firstLine(string(out)))
+	}
+	checks = append(checks, buildx)
+
+	for _, platform := range []string{"linux/amd64", "linux/arm64"} {
+		if !docker.IsEmulated(platform) {
+			continue
+		}
+		registered, known := docker.BinfmtRegistered(platform)
+		if !known {
+			continue
+		}
+		qemu := doctorCheck{name: "QEMU emulation of " + platform, optional: true, fix: "run 'cpx ci setup-qemu' to build for " + platform + " on this machine"}
+		if registered {
+			qemu.found = "(binfmt_misc handler registered)"
+		}
+		checks = append(checks, qemu)
+	}
+	return checks
+}
+
+// buildToolChecks returns the checks of the tools native builds of a project
+// of the given type use; tools it does not need are optional
+func buildToolChecks(projectType ProjectType) []doctorCheck {
+	cmakeBased := projectType == ProjectTypeVcpkg || projectType == ProjectTypeUnknown
+	bazel := projectType == ProjectTypeBazel
+	meson := projectType == ProjectTypeMeson
+
+	checks := []doctorCheck{
+		toolCheck("cmake", !cmakeBased, "install CMake 3.20 or newer (apt install cmake, brew install cmake, winget install Kitware.CMake)", "cmake"),
+		toolCheck("ninja", !cmakeBased && !meson, "install Ninja (apt install ninja-build, brew install ninja)", "ninja"),
+		toolCheck("C compiler", bazel, "install GCC or Clang (apt install build-essential, xcode-select --install)", "cc", "gcc", "clang"),
+		toolCheck("C++ compiler", bazel, "install GCC or Clang (apt install build-essential, xcode-select --install)", "c++", "g++", "clang++"),
+	}
+	if !CheckCommandExists("ninja") && CheckCommandExists("make") && cmakeBased {
+		checks[1] = toolCheck("make (ninja not found)", true, "", "make")
+	}
+
+	vcpkg := doctorCheck{name: "vcpkg", optional: projectType != ProjectTypeVcpkg,
+		fix: "git clone https://github.com/microsoft/vcpkg && ./vcpkg/bootstrap-vcpkg.sh, then 'cpx config set vcpkg_root <path>'"}
+	if path := findVcpkg(); path != "" {
+		vcpkg.found = path
+	}
+	return append(checks,
+		vcpkg,
+		toolCheck("bazel", !bazel, "install Bazelisk (brew install bazelisk, npm install -g @bazel/bazelisk)", "bazel", "bazelisk"),
+		toolCheck("meson", !meson, "install Meson (pip install meson, brew install meson)", "meson"),
+		toolCheck("clang-format", true, "install clang-format for 'cpx fmt'", "clang-format"),
+		toolCheck("clang-tidy", true, "install clang-tidy for 'cpx lint'", "clang-tidy"),
+	)
+}
+
+// toolCheck checks that one of commands is on PATH, reporting the first found
+func toolCheck(name string, optional bool, fix string, commands ...string) doctorCheck {
+	check := doctorCheck{name: name, optional: optional, fix: fix}
+	for _, command := range commands {
+		if path, err := execLookPath(command); err == nil {
+			check.found = path
+			if version := toolVersion(path, "--version"); version != "" {
+				check.found = version
+			}
+			break
+		}
+	}
+	return check
+}
+
+// toolVersion returns the first line a command prints, "" if it fails
+func toolVersion(command string, args ...string) string {
+	out, err := exec.Command(command, args...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(firstLine(strings.TrimSpace(string(out))))
+}
+
+// diskSpaceCheck checks the disk holding the build caches in dir, or its
+// nearest existing parent, has room for them
+func diskSpaceCheck(dir string) doctorCheck {
+	check := doctorCheck{name: "Free space for build caches", fix: "free disk space, e.g. with 'cpx ci clean' and 'cpx ci images prune'"}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return check
+	}
+	for !CheckFileExists(abs) && filepath.Dir(abs) != abs {
+		abs = filepath.Dir(abs)
+	}
+	free, ok := diskFree(abs)
+	if !ok {
+		check.found, check.optional = "(unknown on this system)", true
+		return check
+	}
+	size := fmt.Sprintf("(%s free on %s)", formatSize(int64(free)), abs)
+	if free < minCacheSpace {
+		check.name += " " + size
+		return check
+	}
+	check.found = size
+	return check
+}
+
+// ciConfigChecks checks cpx-ci.yaml loads and the Dockerfiles and runner
+// plugins it refers to exist
+func ciConfigChecks(path, projectRoot string) []doctorCheck {
+	cfg, err := config.LoadToolchains(path)
+	if err != nil {
+		return []doctorCheck{{name: "cpx-ci.yaml is valid", fix: "run 'cpx ci validate' to see the problems with their position"}}
+	}
+	checks := []doctorCheck{{name: "cpx-ci.yaml is valid", found: fmt.Sprintf("(%d runner(s), %d toolchain(s))", len(cfg.Runners), len(cfg.Toolchains))}}
+	for _, r := range cfg.Runners {
+		switch {
+		case r.IsDocker() && r.Build != nil:
+			dockerfile := r.Build.Dockerfile
+			if dockerfile == "" {
+				dockerfile = "Dockerfile"
+			}
+			check := doctorCheck{name: fmt.Sprintf("Dockerfile of runner '%s'", r.Name),
+				fix: fmt.Sprintf("create %s, or set build.dockerfile of runner '%s'", dockerfile, r.Name)}
+			if CheckFileExists(filepath.Join(projectRoot, dockerfile)) {
+				check.found = dockerfile
+			}
+			checks = append(checks, check)
+		case r.IsPlugin() && r.Plugin != "":
+			plugin := r.Plugin
+			if strings.ContainsRune(plugin, '/') && !filepath.IsAbs(plugin) {
+				plugin = filepath.Join(projectRoot, plugin)
+			}
+			check := doctorCheck{name: fmt.Sprintf("plugin of runner '%s'", r.Name),
+				fix: fmt.Sprintf("install %s on PATH, or set the plugin of runner '%s' to its path", r.Plugin, r.Name)}
+			if found, err := exec.LookPath(plugin); err == nil {
+				check.found = found
+			}
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// diskFree returns the free bytes on the filesystem holding path, as df
+// reports them; false where df is unavailable
+func diskFree(path string) (uint64, bool) {
+	out, err := exec.Command("df", "-Pk", path).Output()
+	if err != nil {
+		return 0, false
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, false
+	}
+	kb, err := strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return kb << 10, true
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildToolChecks(t *testing.T) {
+	oldExecLookPath := execLookPath
+	defer func() { execLookPath = oldExecLookPath }()
+	t.Setenv("VCPKG_ROOT", "")
+	t.Setenv("HOME", t.TempDir())
+
+	onPath := map[string]bool{"cmake": true, "ninja": true, "gcc": true, "g++": true}
+	execLookPath = func(file string) (string, error) {
+		if onPath[file] {
+			return "/nonexistent/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	checks := make(map[string]doctorCheck)
+	for _, c := range buildToolChecks(ProjectTypeVcpkg) {
+		checks[c.name] = c
+	}
+	assert.Equal(t, "/nonexistent/bin/cmake", checks["cmake"].found)
+	assert.Equal(t, "/nonexistent/bin/gcc", checks["C compiler"].found, "falls back to the next compiler")
+	assert.Empty(t, checks["vcpkg"].found)
+	assert.False(t, checks["vcpkg"].optional, "vcpkg projects need vcpkg")
+	assert.True(t, checks["bazel"].optional)
+	assert.NotEmpty(t, checks["vcpkg"].fix)
+	assert.Equal(t, 1, printChecks(buildToolChecks(ProjectTypeVcpkg)))
+
+	for _, c := range buildToolChecks(ProjectTypeBazel) {
+		if c.name == "bazel" {
+			assert.False(t, c.optional)
+		}
+		if c.name == "cmake" || c.name == "vcpkg" {
+			assert.True(t, c.optional, c.name)
+		}
+	}
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	check := diskSpaceCheck(t.TempDir() + "/not/created/yet")
+	if _, ok := diskFree("/"); !ok {
+		assert.True(t, check.optional)
+		return
+	}
+	assert.True(t, check.found != "" || check.fix != "")
+}
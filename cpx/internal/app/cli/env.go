@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// envVar is a single variable of the resolved project environment
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// EnvCmd creates the env command
+func EnvCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Print the resolved project environment",
+		Long: `Print the environment cpx uses for this project (VCPKG_ROOT, toolchain file,
+triplet, cache and output directories).
+
+The default output can be evaluated by a POSIX shell:
+  eval "$(cpx env)"`,
+		Example: `  cpx env                 # export VAR="value" lines
+  cpx env --json          # JSON object
+  cpx env --shell pwsh    # PowerShell $env: assignments`,
+		RunE: runEnv,
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().Bool("json", false, "Print as a JSON object")
+	cmd.Flags().String("shell", "sh", "Shell syntax: sh, fish, pwsh")
+	return cmd
+}
+
+// ExecCmd creates the exec command
+func ExecCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "exec -- <command> [args...]",
+		Short: "Run a command with the project environment applied",
+		Long:  "Run an arbitrary command (cmake, ctest, an IDE generator, ...) with the environment printed by 'cpx env' applied.",
+		Example: `  cpx exec -- cmake --preset=default
+  cpx exec -- ctest --test-dir .cache/native/test`,
+		RunE: runExec,
+		Args: cobra.MinimumNArgs(1),
+	}
+}
+
+func runEnv(cmd *cobra.Command, _ []string) error {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	shell, _ := cmd.Flags().GetString("shell")
+
+	vars, err := projectEnv()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		obj := make(map[string]string, len(vars))
+		for _, v := range vars {
+			obj[v.Name] = v.Value
+		}
+		data, err := json.MarshalIndent(obj, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode environment: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for _, v := range vars {
+		line, err := formatEnvVar(shell, v)
+		if err != nil {
+			return err
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// formatEnvVar renders one assignment in the given shell's syntax
+func formatEnvVar(shell string, v envVar) (string, error) {
+	switch shell {
+	case "sh", "bash", "zsh":
+		return fmt.Sprintf("export %s='%s'", v.Name, strings.ReplaceAll(v.Value, "'", `'\''`)), nil
+	case "fish":
+		return fmt.Sprintf("set -gx %s '%s'", v.Name, strings.ReplaceAll(v.Value, "'", `\'`)), nil
+	case "pwsh", "powershell":
+		return fmt.Sprintf("$env:%s = '%s'", v.Name, strings.ReplaceAll(v.Value, "'", "''")), nil
+	default:
+		return "", fmt.Errorf("unknown shell '%s' (use sh, fish or pwsh)", shell)
+	}
+}
+
+func runExec(_ *cobra.Command, args []string) error {
+	vars, err := projectEnv()
+	if err != nil {
+		return err
+	}
+
+	env := os.Environ()
+	for _, v := range vars {
+		env = append(env, v.Name+"="+v.Value)
+	}
+
+	c := exec.Command(args[0], args[1:]...)
+	c.Env = env
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return fmt.Errorf("failed to run %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// projectEnv resolves the environment cpx applies when building this project
+func projectEnv() ([]envVar, error) {
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project root: %w", err)
+	}
+	projectType := DetectProjectType()
+
+	vars := []envVar{
+		{"CPX_PROJECT_ROOT", projectRoot},
+		{"CPX_PROJECT_TYPE", string(projectType)},
+	}
+
+	if projectType == ProjectTypeVcpkg {
+		vcpkgRoot := os.Getenv("VCPKG_ROOT")
+		if vcpkgRoot == "" {
+			cfg, err := config.Load()
+			if err != nil {
+				return nil, fmt.Errorf("failed to load config: %w", err)
+			}
+			vcpkgRoot = cfg.VcpkgRoot
+		}
+		if vcpkgRoot == "" {
+			return nil, fmt.Errorf("vcpkg_root not set in config\n  hint: run cpx config set-vcpkg-root <path>")
+		}
+
+		triplet := os.Getenv("VCPKG_DEFAULT_TRIPLET")
+		if triplet == "" {
+			triplet = hostTriplet(runtime.GOOS, runtime.GOARCH)
+		}
+
+		vars = append(vars,
+			envVar{"VCPKG_ROOT", vcpkgRoot},
+			envVar{"VCPKG_FEATURE_FLAGS", "manifests"},
+			envVar{"VCPKG_DISABLE_REGISTRY_UPDATE", "1"},
+			envVar{"VCPKG_DEFAULT_TRIPLET", triplet},
+			envVar{"VCPKG_INSTALLED_DIR", filepath.Join(projectRoot, ".cache", "native", "vcpkg_installed")},
+			envVar{"CMAKE_TOOLCHAIN_FILE", filepath.Join(vcpkgRoot, "scripts", "buildsystems", "vcpkg.cmake")},
+		)
+	}
+
+	vars = append(vars,
+		envVar{"CPX_BUILD_DIR", filepath.Join(projectRoot, ".cache", "native")},
+		envVar{"CPX_OUTPUT_DIR", filepath.Join(projectRoot, ".bin", "native")},
+		envVar{"CPX_CI_CACHE_DIR", configuredCICacheDir(projectRoot)},
+		envVar{"CPX_CI_OUTPUT_DIR", filepath.Join(projectRoot, ".bin", "ci")},
+	)
+	return vars, nil
+}
+
+// hostTriplet returns vcpkg's default triplet for the host
+func hostTriplet(goos, goarch string) string {
+	arch := map[string]string{
+		"amd64": "x64",
+		"386":   "x86",
+		"arm64": "arm64",
+		"arm":   "arm",
+	}[goarch]
+	if arch == "" {
+		arch = goarch
+	}
+
+	switch goos {
+	case "darwin":
+		return arch + "-osx"
+	case "windows":
+		return arch + "-windows"
+	default:
+		return arch + "-" + goos
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostTriplet(t *testing.T) {
+	tests := []struct {
+		goos, goarch, expected string
+	}{
+		{"linux", "amd64", "x64-linux"},
+		{"linux", "arm64", "arm64-linux"},
+		{"darwin", "arm64", "arm64-osx"},
+		{"darwin", "amd64", "x64-osx"},
+		{"windows", "amd64", "x64-windows"},
+		{"windows", "386", "x86-windows"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, hostTriplet(tt.goos, tt.goarch))
+		})
+	}
+}
+
+func TestFormatEnvVar(t *testing.T) {
+	v := envVar{Name: "VCPKG_ROOT", Value: "/opt/it's vcpkg"}
+
+	tests := []struct {
+		shell    string
+		expected string
+	}{
+		{"sh", `export VCPKG_ROOT='/opt/it'\''s vcpkg'`},
+		{"fish", `set -gx VCPKG_ROOT '/opt/it\'s vcpkg'`},
+		{"pwsh", `$env:VCPKG_ROOT = '/opt/it''s vcpkg'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			line, err := formatEnvVar(tt.shell, v)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, line)
+		})
+	}
+
+	_, err := formatEnvVar("tcsh", v)
+	assert.Error(t, err)
+}
@@ -1,25 +1,81 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/ozacod/cpx/internal/pkg/templates"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
 	"github.com/spf13/cobra"
 )
 
 func FmtCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "fmt",
+		Use:     "fmt [paths...]",
 		Aliases: []string{"format"},
 		Short:   "Format code with clang-format",
-		Long:    "Format code with clang-format. Use --check to verify formatting without modifying files.",
-		RunE:    runFmt,
+		Long: `Format code with clang-format, in the given files and directories or the
+project's source directories (src/, include/, tests/, ...).
+
+--check formats nothing: it prints a diff of the files that need formatting
+and fails if there are any. --changed restricts formatting to the files changed
+since a git ref, including uncommitted and untracked files; without a ref,
+to uncommitted changes. A project without a .clang-format gets one with
+--style as its base style.`,
+		Example: `  cpx fmt
+  cpx fmt --check
+  cpx fmt --changed
+  cpx fmt --check --changed=origin/main`,
+		RunE: runFmt,
 	}
 
-	cmd.Flags().Bool("check", false, "Check formatting without modifying files")
+	cmd.Flags().Bool("check", false, "Check formatting without modifying files, printing a diff")
+	cmd.Flags().String("changed", "", "Only files changed since this git ref (default with no ref: HEAD)")
+	cmd.Flags().Lookup("changed").NoOptDefVal = "HEAD"
+	cmd.Flags().String("style", "Google", "Base style of the .clang-format written when there is none")
 
 	return cmd
 }
 
-func runFmt(cmd *cobra.Command, _ []string) error {
+func runFmt(cmd *cobra.Command, args []string) error {
 	check, _ := cmd.Flags().GetBool("check")
-	return quality.FormatCode(check)
+	changed, _ := cmd.Flags().GetString("changed")
+	style, _ := cmd.Flags().GetString("style")
+
+	if _, found := findClangFormatConfig(); !found {
+		if check {
+			fmt.Printf("%sWarning: no .clang-format found; clang-format checks against its default style. Run 'cpx fmt' to create one%s\n", colors.Yellow, colors.Reset)
+		} else {
+			if err := os.WriteFile(".clang-format", []byte(templates.GenerateClangFormat(style)), 0644); err != nil {
+				return fmt.Errorf("failed to write .clang-format: %w", err)
+			}
+			fmt.Printf("%s✓ Created .clang-format (based on %s style)%s\n", colors.Green, style, colors.Reset)
+		}
+	}
+
+	return quality.FormatCode(quality.FormatOptions{Check: check, Changed: changed, Targets: args})
+}
+
+// findClangFormatConfig returns the .clang-format or _clang-format clang-format
+// uses for files in the current directory, searching its parents like
+// clang-format does
+func findClangFormatConfig() (string, bool) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	for {
+		for _, name := range []string{".clang-format", "_clang-format"} {
+			if path := filepath.Join(dir, name); CheckFileExists(path) {
+				return path, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
 }
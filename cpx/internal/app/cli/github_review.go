@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/internal/pkg/utils/git"
+)
+
+// maxReviewComments bounds the comments of a review; GitHub rejects or
+// truncates very large reviews and they bury the discussion
+const maxReviewComments = 50
+
+// githubEvent is the part of a GitHub Actions event payload cpx uses
+type githubEvent struct {
+	PullRequest *struct {
+		Number int `json:"number"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+}
+
+// githubPullFile is a file a pull request changes
+type githubPullFile struct {
+	Filename string `json:"filename"`
+	Patch    string `json:"patch"`
+}
+
+// githubReviewComment is a comment on a line of a pull request review
+type githubReviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Side string `json:"side"`
+	Body string `json:"body"`
+}
+
+// postAnalysisReview posts the findings of an analysis on the lines a pull
+// request changes as a review of it
+func postAnalysisReview(analysis quality.ComprehensiveAnalysis, pr int) error {
+	token := githubToken()
+	if token == "" {
+		return fmt.Errorf("GITHUB_TOKEN or GH_TOKEN must be set to post a review")
+	}
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	repo, err := githubRepo(projectRoot)
+	if err != nil {
+		return err
+	}
+
+	var event githubEvent
+	if path := os.Getenv("GITHUB_EVENT_PATH"); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			_ = json.Unmarshal(data, &event)
+		}
+	}
+	commit := ""
+	if event.PullRequest != nil && (pr == 0 || pr == event.PullRequest.Number) {
+		pr = event.PullRequest.Number
+		commit = event.PullRequest.Head.SHA
+	}
+	if pr == 0 {
+		return fmt.Errorf("--github-review needs a pull_request event or --pr")
+	}
+
+	gh := newGitHubClient(token)
+	if commit == "" {
+		// The review is of the pull request's head, not what is checked out
+		if commit, err = gh.pullRequestHead(repo, pr); err != nil {
+			return err
+		}
+	}
+	changed, err := gh.pullRequestLines(repo, pr)
+	if err != nil {
+		return err
+	}
+	// The files of pull requests are named relative to the repository root
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if top, err := git.TopLevel(root); err == nil {
+		root = top
+	}
+	analysis.RelativizePaths(root)
+	comments := reviewComments(analysis, changed)
+	if len(comments) == 0 {
+		fmt.Printf("%s✓ No findings on lines pull request #%d changes%s\n", colors.Green, pr, colors.Reset)
+		return nil
+	}
+
+	body := fmt.Sprintf("cpx analyze: %d finding(s) on lines this pull request changes.", len(comments))
+	if len(comments) > maxReviewComments {
+		body += fmt.Sprintf(" The first %d are shown.", maxReviewComments)
+		comments = comments[:maxReviewComments]
+	}
+	review := map[string]any{"commit_id": commit, "event": "COMMENT", "body": body, "comments": comments}
+	if err := gh.do(http.MethodPost, fmt.Sprintf("%s/repos/%s/pulls/%d/reviews", gh.apiURL, repo, pr), review, nil); err != nil {
+		return fmt.Errorf("failed to post review: %w", err)
+	}
+	fmt.Printf("%s✓ Posted %d comment(s) on pull request #%d%s\n", colors.Green, len(comments), pr, colors.Reset)
+	return nil
+}
+
+// pullRequestHead returns the head commit of a pull request
+func (gh *githubClient) pullRequestHead(repo string, pr int) (string, error) {
+	var pull struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := gh.do(http.MethodGet, fmt.Sprintf("%s/repos/%s/pulls/%d", gh.apiURL, repo, pr), nil, &pull); err != nil {
+		return "", fmt.Errorf("failed to get pull request #%d: %w", pr, err)
+	}
+	return pull.Head.SHA, nil
+}
+
+// pullRequestLines returns the lines of the files a pull request changes that
+// a review can comment on, by file
+func (gh *githubClient) pullRequestLines(repo string, pr int) (map[string][][2]int, error) {
+	lines := make(map[string][][2]int)
+	for page := 1; ; page++ {
+		var files []githubPullFile
+		endpoint := fmt.Sprintf("%s/repos/%s/pulls/%d/files?per_page=100&page=%d", gh.apiURL, repo, pr, page)
+		if err := gh.do(http.MethodGet, endpoint, nil, &files); err != nil {
+			return nil, fmt.Errorf("failed to list the files of pull request #%d: %w", pr, err)
+		}
+		for _, f := range files {
+			lines[f.Filename] = patchLines(f.Patch)
+		}
+		if len(files) < 100 {
+			return lines, nil
+		}
+	}
+}
+
+var hunkHeader = regexp.MustCompile(`(?m)^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// patchLines returns the ranges of new lines, first and last, the hunks of a
+// patch show
+func patchLines(patch string) [][2]int {
+	var ranges [][2]int
+	for _, m := range hunkHeader.FindAllStringSubmatch(patch, -1) {
+		start, _ := strconv.Atoi(m[1])
+		count := 1
+		if m[2] != "" {
+			count, _ = strconv.Atoi(m[2])
+		}
+		if count > 0 {
+			ranges = append(ranges, [2]int{start, start + count - 1})
+		}
+	}
+	return ranges
+}
+
+// reviewComments returns a comment for each finding on a line in changed
+func reviewComments(analysis quality.ComprehensiveAnalysis, changed map[string][][2]int) []githubReviewComment {
+	var comments []githubReviewComment
+	for _, tool := range analysis.Tools {
+		for _, r := range tool.Results {
+			for _, lines := range changed[r.File] {
+				if r.Line < lines[0] || r.Line > lines[1] {
+					continue
+				}
+				body := fmt.Sprintf("**%s** (%s): %s", tool.Tool, r.Severity, r.Message)
+				if r.Rule != "" {
+					body += " `" + strings.ReplaceAll(r.Rule, "`", "'") + "`"
+				}
+				comments = append(comments, githubReviewComment{Path: r.File, Line: r.Line, Side: "RIGHT", Body: body})
+				break
+			}
+		}
+	}
+	return comments
+}
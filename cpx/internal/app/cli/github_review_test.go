@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ozacod/cpx/internal/pkg/quality"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchLines(t *testing.T) {
+	patch := "@@ -1,3 +1,4 @@\n #include <x>\n+#include <y>\n int a;\n int b;\n@@ -20 +21 @@ void f() {\n-  g();\n+  h();\n@@ -30,2 +31,0 @@\n-a\n-b"
+	assert.Equal(t, [][2]int{{1, 4}, {21, 21}}, patchLines(patch))
+	assert.Empty(t, patchLines(""))
+}
+
+func TestReviewComments(t *testing.T) {
+	analysis := quality.ComprehensiveAnalysis{Tools: []quality.ToolResults{
+		{Tool: "clang-tidy", Results: []quality.AnalysisResult{
+			{Severity: "warning", File: "src/main.cpp", Line: 3, Message: "use auto", Rule: "modernize-use-auto"},
+			{Severity: "warning", File: "src/main.cpp", Line: 9, Message: "outside the diff"},
+			{Severity: "error", File: "src/other.cpp", Line: 1, Message: "unchanged file"},
+		}},
+		{Tool: "Flawfinder", Results: []quality.AnalysisResult{
+			{Severity: "info", File: "src/main.cpp", Line: 21, Message: "check buffer"},
+		}},
+	}}
+	changed := map[string][][2]int{"src/main.cpp": {{1, 4}, {21, 21}}}
+	assert.Equal(t, []githubReviewComment{
+		{Path: "src/main.cpp", Line: 3, Side: "RIGHT", Body: "**clang-tidy** (warning): use auto `modernize-use-auto`"},
+		{Path: "src/main.cpp", Line: 21, Side: "RIGHT", Body: "**Flawfinder** (info): check buffer"},
+	}, reviewComments(analysis, changed))
+}
+
+func TestPullRequestHead(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/demo/pulls/7" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"number": 7, "head": {"sha": "abc123"}}`))
+	}))
+	defer server.Close()
+	gh := &githubClient{apiURL: server.URL, token: "secret", client: server.Client()}
+
+	commit, err := gh.pullRequestHead("acme/demo", 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", commit)
+	_, err = gh.pullRequestHead("acme/demo", 8)
+	assert.ErrorContains(t, err, "failed to get pull request #8")
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// IdeCmd creates the ide command generating editor configuration
+func IdeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ide",
+		Short: "Generate editor and IDE configuration",
+		Args:  cobra.NoArgs,
+	}
+
+	vscodeCmd := &cobra.Command{
+		Use:   "vscode",
+		Short: "Generate VS Code tasks, launch and C/C++ configurations",
+		Long: `Write .vscode/tasks.json, launch.json and c_cpp_properties.json for the project
+in the current directory.
+
+tasks.json runs cpx build, test, compdb and ci build, with a task per active
+toolchain of cpx-ci.yaml. launch.json debugs the native debug build and, for
+each Docker toolchain, its binary inside the toolchain's container through
+'cpx ci exec' (the image needs gdb). c_cpp_properties.json points IntelliSense
+at the compile_commands.json 'cpx compdb' writes.
+
+Existing files are kept unless --force is given.`,
+		Example: `  cpx ide vscode
+  cpx ide vscode --force`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+			return runIdeVSCode(force)
+		},
+		Args: cobra.NoArgs,
+	}
+	vscodeCmd.Flags().Bool("force", false, "Replace existing VS Code configuration files")
+	cmd.AddCommand(vscodeCmd)
+	return cmd
+}
+
+// vscodeToolchain is a toolchain VS Code tasks and launch configurations are
+// generated for
+type vscodeToolchain struct {
+	Name   string
+	Docker bool
+}
+
+func runIdeVSCode(force bool) error {
+	buildSystem := detectBuildSystem()
+	if buildSystem == "" {
+		return fmt.Errorf("ide vscode requires a CMake, Bazel or Meson project in the current directory")
+	}
+	name, _ := initProjectInfo(buildSystem)
+
+	var toolchains []vscodeToolchain
+	if CheckFileExists("cpx-ci.yaml") {
+		ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+		if err != nil {
+			return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+		}
+		// Toolchains inherit their runner through extends
+		resolved, err := ciConfig.ResolveToolchains()
+		if err != nil {
+			return fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+		}
+		for _, tc := range resolved {
+			if !tc.IsActive() {
+				continue
+			}
+			runner := ciConfig.FindRunner(tc.Runner)
+			toolchains = append(toolchains, vscodeToolchain{Name: tc.Name, Docker: runner != nil && runner.IsDocker()})
+		}
+	}
+
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"tasks.json", vscodeTasks(toolchains)},
+		{"launch.json", vscodeLaunch(name, toolchains, runtime.GOOS)},
+		{"c_cpp_properties.json", vscodeCppProperties()},
+	}
+	if err := os.MkdirAll(".vscode", 0755); err != nil {
+		return fmt.Errorf("failed to create .vscode: %w", err)
+	}
+	for _, f := range files {
+		file := filepath.Join(".vscode", f.name)
+		if CheckFileExists(file) && !force {
+			fmt.Printf("  %s- %s exists, kept (use --force to replace it)%s\n", colors.Gray, file, colors.Reset)
+			continue
+		}
+		data, err := json.MarshalIndent(f.content, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", file, err)
+		}
+		if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		fmt.Printf("  %s✓ %s%s\n", colors.Green, file, colors.Reset)
+	}
+	fmt.Printf("\nRun 'cpx build' or the 'cpx: build' task, then 'cpx compdb' for IntelliSense.\n")
+	return nil
+}
+
+type vscodeTaskGroup struct {
+	Kind      string `json:"kind"`
+	IsDefault bool   `json:"isDefault,omitempty"`
+}
+
+type vscodeTask struct {
+	Label          string           `json:"label"`
+	Type           string           `json:"type"`
+	Command        string           `json:"command"`
+	Args           []string         `json:"args,omitempty"`
+	Group          *vscodeTaskGroup `json:"group,omitempty"`
+	ProblemMatcher []string         `json:"problemMatcher"`
+}
+
+// vscodeTasks returns the tasks.json running cpx commands
+func vscodeTasks(toolchains []vscodeToolchain) map[string]any {
+	task := func(label string, group *vscodeTaskGroup, args ...string) vscodeTask {
+		return vscodeTask{Label: label, Type: "shell", Command: "cpx", Args: args, Group: group, ProblemMatcher: []string{"$gcc"}}
+	}
+	tasks := []vscodeTask{
+		task("cpx: build", &vscodeTaskGroup{Kind: "build", IsDefault: true}, "build"),
+		task("cpx: build (release)", &vscodeTaskGroup{Kind: "build"}, "build", "--release"),
+		task("cpx: test", &vscodeTaskGroup{Kind: "test", IsDefault: true}, "test"),
+		task("cpx: compdb", nil, "compdb"),
+	}
+	if len(toolchains) > 0 {
+		tasks = append(tasks, task("cpx: ci build", &vscodeTaskGroup{Kind: "build"}, "ci", "build"))
+	}
+	for _, tc := range toolchains {
+		tasks = append(tasks, task("cpx: ci build "+tc.Name, &vscodeTaskGroup{Kind: "build"}, "ci", "build", "--toolchain", tc.Name))
+	}
+	return map[string]any{"version": "2.0.0", "tasks": tasks}
+}
+
+type vscodePipeTransport struct {
+	PipeProgram  string   `json:"pipeProgram"`
+	PipeArgs     []string `json:"pipeArgs"`
+	PipeCwd      string   `json:"pipeCwd"`
+	DebuggerPath string   `json:"debuggerPath"`
+}
+
+type vscodeLaunchConfig struct {
+	Name            string               `json:"name"`
+	Type            string               `json:"type"`
+	Request         string               `json:"request"`
+	Program         string               `json:"program"`
+	Args            []string             `json:"args"`
+	Cwd             string               `json:"cwd"`
+	MIMode          string               `json:"MIMode"`
+	PreLaunchTask   string               `json:"preLaunchTask"`
+	PipeTransport   *vscodePipeTransport `json:"pipeTransport,omitempty"`
+	SourceFileMap   map[string]string    `json:"sourceFileMap,omitempty"`
+	StopAtEntry     bool                 `json:"stopAtEntry"`
+	ExternalConsole bool                 `json:"externalConsole"`
+}
+
+// vscodeLaunch returns the launch.json debugging the native debug build of
+// the executable name and, through 'cpx ci exec', the one of each Docker
+// toolchain in its container
+func vscodeLaunch(name string, toolchains []vscodeToolchain, goos string) map[string]any {
+	program := "${workspaceFolder}/.bin/native/" + build.GetOutputDir(false, "", "") + "/" + name
+	miMode := "gdb"
+	switch goos {
+	case "windows":
+		program += ".exe"
+	case "darwin":
+		miMode = "lldb"
+	}
+	configs := []vscodeLaunchConfig{{
+		Name:          "Debug " + name,
+		Type:          "cppdbg",
+		Request:       "launch",
+		Program:       program,
+		Args:          []string{},
+		Cwd:           "${workspaceFolder}",
+		MIMode:        miMode,
+		PreLaunchTask: "cpx: build",
+	}}
+	for _, tc := range toolchains {
+		if !tc.Docker {
+			continue
+		}
+		configs = append(configs, vscodeLaunchConfig{
+			Name:          "Debug " + name + " in " + tc.Name,
+			Type:          "cppdbg",
+			Request:       "launch",
+			Program:       path.Join("/output", tc.Name, name),
+			Args:          []string{},
+			Cwd:           "/workspace",
+			MIMode:        "gdb",
+			PreLaunchTask: "cpx: ci build " + tc.Name,
+			PipeTransport: &vscodePipeTransport{
+				PipeProgram:  "cpx",
+				PipeArgs:     []string{"ci", "exec", "--toolchain", tc.Name, "--"},
+				PipeCwd:      "${workspaceFolder}",
+				DebuggerPath: "gdb",
+			},
+			SourceFileMap: map[string]string{"/workspace": "${workspaceFolder}"},
+		})
+	}
+	return map[string]any{"version": "0.2.0", "configurations": configs}
+}
+
+// vscodeCppProperties returns the c_cpp_properties.json using the
+// compilation database of 'cpx compdb'
+func vscodeCppProperties() map[string]any {
+	return map[string]any{
+		"version": 4,
+		"configurations": []map[string]any{{
+			"name":            "cpx",
+			"compileCommands": "${workspaceFolder}/" + compdbFile,
+		}},
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunIdeVSCode(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("CMakeLists.txt", []byte("project(app VERSION 1.0)\n"), 0644))
+	require.NoError(t, os.WriteFile("cpx-ci.yaml", []byte(`runners:
+  - name: host
+    type: native
+  - name: linux
+    type: docker
+    image: ubuntu:24.04
+toolchains:
+  - name: debug
+    runner: host
+  - name: linux-release
+    runner: linux
+  - name: linux-asan
+    extends: linux-release
+`), 0644))
+
+	require.NoError(t, runIdeVSCode(false))
+
+	var tasks struct {
+		Tasks []vscodeTask `json:"tasks"`
+	}
+	data, err := os.ReadFile(filepath.Join(".vscode", "tasks.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &tasks))
+	var labels []string
+	for _, task := range tasks.Tasks {
+		labels = append(labels, task.Label)
+	}
+	assert.Equal(t, []string{"cpx: build", "cpx: build (release)", "cpx: test", "cpx: compdb", "cpx: ci build", "cpx: ci build debug", "cpx: ci build linux-release", "cpx: ci build linux-asan"}, labels)
+
+	var launch struct {
+		Configurations []vscodeLaunchConfig `json:"configurations"`
+	}
+	data, err = os.ReadFile(filepath.Join(".vscode", "launch.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &launch))
+	require.Len(t, launch.Configurations, 3, "only Docker toolchains are debugged in a container")
+	assert.Equal(t, "cpx: build", launch.Configurations[0].PreLaunchTask)
+	remote := launch.Configurations[1]
+	assert.Equal(t, "/output/linux-release/app", remote.Program)
+	require.NotNil(t, remote.PipeTransport)
+	assert.Equal(t, []string{"ci", "exec", "--toolchain", "linux-release", "--"}, remote.PipeTransport.PipeArgs)
+	assert.Equal(t, "${workspaceFolder}", remote.SourceFileMap["/workspace"])
+	// The runner is inherited through extends
+	assert.Equal(t, "/output/linux-asan/app", launch.Configurations[2].Program)
+
+	data, err = os.ReadFile(filepath.Join(".vscode", "c_cpp_properties.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"compileCommands": "${workspaceFolder}/compile_commands.json"`)
+
+	// Existing files are kept
+	require.NoError(t, os.WriteFile(filepath.Join(".vscode", "tasks.json"), []byte("{}"), 0644))
+	require.NoError(t, runIdeVSCode(false))
+	data, err = os.ReadFile(filepath.Join(".vscode", "tasks.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+}
+
+func TestVSCodeLaunchPlatforms(t *testing.T) {
+	configs := vscodeLaunch("app", nil, "windows")["configurations"].([]vscodeLaunchConfig)
+	assert.Equal(t, "${workspaceFolder}/.bin/native/debug/app.exe", configs[0].Program)
+	configs = vscodeLaunch("app", nil, "darwin")["configurations"].([]vscodeLaunchConfig)
+	assert.Equal(t, "lldb", configs[0].MIMode)
+}
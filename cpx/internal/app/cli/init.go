@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/templates"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+// initDockerfile is the Dockerfile `cpx init --dockerfiles` writes
+const initDockerfile = "dockerfiles/Dockerfile.linux"
+
+// InitCmd creates the init command
+func InitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Set up cpx in an existing project",
+		Long: `Set up cpx in an existing CMake, Bazel or Meson project in the current
+directory: write a vcpkg.json for CMake projects without one, a cpx-ci.yaml
+for the detected build system, and the cpx directories in .gitignore.
+Existing files are kept unless --force is given.
+
+With --dockerfiles, dockerfiles/Dockerfile.linux gets a build image with the
+project's tools, and cpx-ci.yaml a linux toolchain built in it.`,
+		Example: `  cpx init
+  cpx init --dockerfiles`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dockerfiles, _ := cmd.Flags().GetBool("dockerfiles")
+			force, _ := cmd.Flags().GetBool("force")
+			return runInit(dockerfiles, force)
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().Bool("dockerfiles", false, "Also write a Linux build image Dockerfile and a toolchain using it")
+	cmd.Flags().Bool("force", false, "Replace an existing cpx-ci.yaml and Dockerfile")
+	return cmd
+}
+
+func runInit(dockerfiles, force bool) error {
+	buildSystem := detectBuildSystem()
+	if buildSystem == "" {
+		return fmt.Errorf("no CMakeLists.txt, MODULE.bazel, WORKSPACE or meson.build in the current directory\n  hint: create a new project with cpx new")
+	}
+	name, version := initProjectInfo(buildSystem)
+	fmt.Printf("%s Detected %s project '%s'%s\n", colors.Cyan, describeBuildSystem(buildSystem), name, colors.Reset)
+
+	var created []string
+	write := func(path, content string, replace bool) error {
+		if CheckFileExists(path) && !replace {
+			fmt.Printf("  %s- %s exists, kept%s\n", colors.Gray, path, colors.Reset)
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		created = append(created, path)
+		return nil
+	}
+
+	if buildSystem == "vcpkg" {
+		if err := write("vcpkg.json", templates.GenerateVcpkgJSON(name, version, nil), false); err != nil {
+			return err
+		}
+	}
+	if buildSystem == "bazel" && !CheckFileExists("MODULE.bazel") {
+		fmt.Printf("  %sWarning: cpx uses Bazel modules; add a MODULE.bazel (see https://bazel.build/external/migration)%s\n", colors.Yellow, colors.Reset)
+	}
+
+	ciConfig := templates.GenerateCpxCI(buildSystem)
+	if dockerfiles || CheckFileExists(initDockerfile) {
+		if dockerfiles {
+			if err := write(initDockerfile, templates.GenerateLinuxDockerfile(buildSystem), force); err != nil {
+				return err
+			}
+		}
+		ciConfig = templates.GenerateDockerCpxCI(name, buildSystem, initDockerfile)
+	}
+	if err := write("cpx-ci.yaml", ciConfig, force); err != nil {
+		return err
+	}
+	if added, err := ignoreCpxDirs(".gitignore"); err != nil {
+		return err
+	} else if added {
+		created = append(created, ".gitignore")
+	}
+
+	for _, path := range created {
+		fmt.Printf("  %s✓ %s%s\n", colors.Green, path, colors.Reset)
+	}
+	fmt.Printf("\n  cpx ci validate && cpx ci build\n\n")
+	return nil
+}
+
+// detectBuildSystem returns the package manager cpx uses for the build
+// system of the project in the current directory: "vcpkg" for CMake,
+// "bazel" or "meson", or "" for none
+func detectBuildSystem() string {
+	switch {
+	case CheckFileExists("MODULE.bazel") || CheckFileExists("WORKSPACE") || CheckFileExists("WORKSPACE.bazel"):
+		return "bazel"
+	case CheckFileExists("meson.build"):
+		return "meson"
+	case CheckFileExists("CMakeLists.txt") || CheckFileExists("vcpkg.json"):
+		return "vcpkg"
+	}
+	return ""
+}
+
+func describeBuildSystem(buildSystem string) string {
+	switch buildSystem {
+	case "bazel":
+		return "Bazel"
+	case "meson":
+		return "Meson"
+	}
+	if CheckFileExists("vcpkg.json") {
+		return "CMake (vcpkg)"
+	}
+	return "CMake"
+}
+
+// initProjectInfo returns the project's name and version from its build
+// files, falling back to the directory name and 0.1.0
+func initProjectInfo(buildSystem string) (name, version string) {
+	name, version = "", "0.1.0"
+	var pattern *regexp.Regexp
+	var file string
+	switch buildSystem {
+	case "bazel":
+		file, pattern = "MODULE.bazel", regexp.MustCompile(`module\s*\([^)]*?name\s*=\s*"([^"]+)"`)
+	case "meson":
+		file, pattern = "meson.build", regexp.MustCompile(`project\s*\(\s*'([^']+)'`)
+	default:
+		if CheckFileExists("CMakeLists.txt") || CheckFileExists("vcpkg.json") {
+			name, version = getProjectInfo()
+			if name == "Project" {
+				name = ""
+			}
+		}
+	}
+	if pattern != nil {
+		if data, err := os.ReadFile(file); err == nil {
+			if m := pattern.FindStringSubmatch(string(data)); m != nil {
+				name = m[1]
+			}
+		}
+	}
+	if name == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			name = filepath.Base(cwd)
+		}
+	}
+	return name, version
+}
+
+// ignoreCpxDirs adds the directories cpx writes to a .gitignore, reporting
+// whether any was missing
+func ignoreCpxDirs(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	lines := strings.Split(string(data), "\n")
+	var missing []string
+	for _, dir := range []string{".cache/", ".bin/", ".cpx/"} {
+		if !slices.Contains(lines, dir) && !slices.Contains(lines, strings.TrimSuffix(dir, "/")) {
+			missing = append(missing, dir)
+		}
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if content != "" {
+		content += "\n"
+	}
+	content += "# cpx build output, caches and logs\n" + strings.Join(missing, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
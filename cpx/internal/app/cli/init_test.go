@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInit(t *testing.T) {
+	t.Run("cmake", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		require.NoError(t, os.WriteFile("CMakeLists.txt", []byte("cmake_minimum_required(VERSION 3.20)\nproject(widget VERSION 1.4.2)\n"), 0644))
+		require.NoError(t, os.WriteFile(".gitignore", []byte("build/"), 0644))
+
+		require.NoError(t, runInit(false, false))
+
+		manifest, err := os.ReadFile("vcpkg.json")
+		require.NoError(t, err)
+		assert.Contains(t, string(manifest), `"name": "widget"`)
+		assert.Contains(t, string(manifest), `"version": "1.4.2"`)
+		cfg, err := config.LoadToolchains("cpx-ci.yaml")
+		require.NoError(t, err)
+		assert.Len(t, cfg.Toolchains, 2)
+		gitignore, err := os.ReadFile(".gitignore")
+		require.NoError(t, err)
+		assert.Equal(t, "build/\n\n# cpx build output, caches and logs\n.cache/\n.bin/\n.cpx/\n", string(gitignore))
+
+		// Existing files are kept
+		require.NoError(t, os.WriteFile("cpx-ci.yaml", []byte("toolchains: []\n"), 0644))
+		require.NoError(t, runInit(false, false))
+		data, err := os.ReadFile("cpx-ci.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, "toolchains: []\n", string(data))
+		gitignore2, err := os.ReadFile(".gitignore")
+		require.NoError(t, err)
+		assert.Equal(t, string(gitignore), string(gitignore2))
+	})
+
+	t.Run("meson with dockerfiles", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		require.NoError(t, os.WriteFile("meson.build", []byte("project('gadget', 'cpp')\n"), 0644))
+
+		require.NoError(t, runInit(true, false))
+
+		assert.NoFileExists(t, "vcpkg.json")
+		assert.FileExists(t, initDockerfile)
+		cfg, err := config.LoadToolchains("cpx-ci.yaml")
+		require.NoError(t, err)
+		require.Len(t, cfg.Runners, 1)
+		assert.Equal(t, "cpx-gadget-linux", cfg.Runners[0].Image)
+		assert.Equal(t, initDockerfile, cfg.Runners[0].Build.Dockerfile)
+		require.Len(t, cfg.Toolchains, 1)
+		assert.Equal(t, "linux-release", cfg.Toolchains[0].Name)
+	})
+
+	t.Run("no build system", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		assert.ErrorContains(t, runInit(false, false), "no CMakeLists.txt")
+	})
+}
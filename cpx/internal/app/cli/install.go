@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// InstallCmd creates the install command
+func InstallCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install built binaries and libraries into a prefix",
+		Long: `Install the output of 'cpx build' or 'cpx ci build' into --prefix.
+
+CMake builds are installed with 'cmake --install' and the project's install()
+rules, optionally restricted to --component and stripped with --strip. With
+--toolchain, or for Bazel and Meson builds, the artifacts in the output
+directory are copied instead: executables and DLLs to bin/, libraries to lib/
+and other files to share/<name>/. --target copies only the named artifacts.
+
+--destdir, or the DESTDIR environment variable, stages the installation under
+a directory, as in 'make install DESTDIR=...', to package it afterwards.`,
+		Example: `  cpx install --prefix ~/.local
+  cpx install --release --prefix /usr --destdir stage
+  cpx install --toolchain linux-release --prefix /opt/app --target app`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := installOptions{}
+			opts.Prefix, _ = cmd.Flags().GetString("prefix")
+			opts.DestDir, _ = cmd.Flags().GetString("destdir")
+			opts.Release, _ = cmd.Flags().GetBool("release")
+			opts.OptLevel, _ = cmd.Flags().GetString("opt")
+			opts.Toolchain, _ = cmd.Flags().GetString("toolchain")
+			opts.Targets, _ = cmd.Flags().GetStringSlice("target")
+			opts.Components, _ = cmd.Flags().GetStringSlice("component")
+			opts.Strip, _ = cmd.Flags().GetBool("strip")
+			if err := applyBuildDefaults(cmd, &opts.Release, nil); err != nil {
+				return err
+			}
+			if opts.DestDir == "" {
+				opts.DestDir = os.Getenv("DESTDIR")
+			}
+			return runInstall(opts)
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().String("prefix", "/usr/local", "Installation prefix")
+	cmd.Flags().String("destdir", "", "Stage the installation under this directory (default: $DESTDIR)")
+	cmd.Flags().BoolP("release", "r", false, "Install the release build. Default is debug")
+	cmd.Flags().StringP("opt", "O", "", "Install the build of an optimization level: 0,1,2,3,s,fast")
+	cmd.Flags().StringP("toolchain", "t", "", "Install the artifacts of a toolchain (from cpx-ci.yaml)")
+	cmd.Flags().StringSlice("target", nil, "Install only these artifacts (repeatable)")
+	cmd.Flags().StringSlice("component", nil, "Install only these CMake install components (repeatable)")
+	cmd.Flags().Bool("strip", false, "Strip binaries while installing (CMake builds)")
+	return cmd
+}
+
+// installOptions are the options of cpx install
+type installOptions struct {
+	Prefix     string
+	DestDir    string
+	Release    bool
+	OptLevel   string
+	Toolchain  string
+	Targets    []string
+	Components []string
+	Strip      bool
+}
+
+func runInstall(opts installOptions) error {
+	if opts.Prefix == "" {
+		return fmt.Errorf("--prefix must not be empty")
+	}
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	name, _ := getProjectInfo()
+
+	if opts.Toolchain != "" {
+		ciConfig, err := config.LoadToolchains(filepath.Join(projectRoot, "cpx-ci.yaml"))
+		if err != nil {
+			return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+		}
+		if ciConfig.FindToolchain(opts.Toolchain) == nil {
+			return fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", opts.Toolchain)
+		}
+		dir := filepath.Join(ciConfig.GetOutputDir(), opts.Toolchain)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(projectRoot, dir)
+		}
+		return installArtifacts(dir, name, opts, "cpx ci build --toolchain "+opts.Toolchain)
+	}
+
+	variant := build.GetOutputDir(opts.Release, opts.OptLevel, "")
+	buildDir := filepath.Join(projectRoot, ".cache", "native", variant)
+	if len(opts.Targets) == 0 && CheckFileExists(filepath.Join(buildDir, "CMakeCache.txt")) {
+		return cmakeInstall(buildDir, opts)
+	}
+	return installArtifacts(filepath.Join(projectRoot, ".bin", "native", variant), name, opts, "cpx build")
+}
+
+// cmakeInstall runs cmake --install on a build directory, once per component
+func cmakeInstall(buildDir string, opts installOptions) error {
+	if !CheckCommandExists("cmake") {
+		return fmt.Errorf("cmake not found in PATH")
+	}
+	env := os.Environ()
+	if opts.DestDir != "" {
+		destDir, err := filepath.Abs(opts.DestDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve --destdir: %w", err)
+		}
+		env = append(env, "DESTDIR="+destDir)
+	}
+	components := opts.Components
+	if len(components) == 0 {
+		components = []string{""}
+	}
+	for _, component := range components {
+		args := cmakeInstallArgs(buildDir, opts.Prefix, component, opts.Strip)
+		fmt.Printf("%s Installing %s...%s\n", colors.Cyan, cmp.Or(component, filepath.Base(buildDir)), colors.Reset)
+		cmd := exec.Command("cmake", args...)
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("cmake install failed: %w", err)
+		}
+	}
+	fmt.Printf("%s✓ Installed to %s%s\n", colors.Green, installRoot(opts.DestDir, opts.Prefix), colors.Reset)
+	return nil
+}
+
+// cmakeInstallArgs returns the arguments of cmake installing a build directory
+func cmakeInstallArgs(buildDir, prefix, component string, strip bool) []string {
+	args := []string{"--install", buildDir, "--prefix", prefix}
+	if component != "" {
+		args = append(args, "--component", component)
+	}
+	if strip {
+		args = append(args, "--strip")
+	}
+	return args
+}
+
+// installArtifacts copies the artifacts in dir, laid out as in packages, into
+// the prefix under the staging directory
+func installArtifacts(dir, name string, opts installOptions, buildHint string) error {
+	if len(opts.Components) > 0 || opts.Strip {
+		return fmt.Errorf("--component and --strip need a CMake build directory; they do not apply to copied artifacts")
+	}
+	artifacts := artifactDigests(dir)
+	if len(artifacts) == 0 {
+		return fmt.Errorf("no artifacts in %s\n  hint: run '%s' first", dir, buildHint)
+	}
+
+	root := installRoot(opts.DestDir, opts.Prefix)
+	matched := make(map[string]bool)
+	var installed []string
+	for _, a := range artifacts {
+		target, ok := matchArtifact(a.Name, opts.Targets)
+		if !ok {
+			continue
+		}
+		matched[target] = true
+		source := filepath.Join(dir, a.Name)
+		info, err := os.Stat(source)
+		if err != nil {
+			return err
+		}
+		path, mode := packagePath(a.Name, info.Mode(), name)
+		dest := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(dest), err)
+		}
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", source, err)
+		}
+		// Remove first so a running executable or a read-only file is replaced
+		_ = os.Remove(dest)
+		if err := os.WriteFile(dest, data, mode); err != nil {
+			return fmt.Errorf("failed to install %s: %w", dest, err)
+		}
+		installed = append(installed, dest)
+	}
+	for _, target := range opts.Targets {
+		if !matched[target] {
+			return fmt.Errorf("no artifact named '%s' in %s", target, dir)
+		}
+	}
+
+	for _, path := range installed {
+		fmt.Printf("  %s✓ %s%s\n", colors.Green, path, colors.Reset)
+	}
+	fmt.Printf("%s✓ Installed %d file(s) to %s%s\n", colors.Green, len(installed), root, colors.Reset)
+	return nil
+}
+
+// matchArtifact returns the target an artifact file is selected by: any
+// target when none are given, else the one naming it with or without its
+// lib prefix and extensions (app, app.exe, foo, libfoo.so.1)
+func matchArtifact(file string, targets []string) (string, bool) {
+	if len(targets) == 0 {
+		return "", true
+	}
+	base, _, _ := strings.Cut(file, ".")
+	for _, candidate := range []string{file, base, strings.TrimPrefix(base, "lib")} {
+		if slices.Contains(targets, candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// installRoot returns the directory prefix is at when staged under destDir
+func installRoot(destDir, prefix string) string {
+	if destDir == "" {
+		return prefix
+	}
+	return filepath.Join(destDir, strings.TrimPrefix(prefix, filepath.VolumeName(prefix)))
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallArtifacts(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("meson.build", []byte("project('tool', 'cpp')\n"), 0644))
+	out := filepath.Join(".bin", "native", "release")
+	require.NoError(t, os.MkdirAll(out, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(out, "tool"), []byte("exe"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(out, "libcore.so.1"), []byte("so"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(out, "SHA256SUMS"), []byte(""), 0644))
+
+	stage := t.TempDir()
+	require.NoError(t, runInstall(installOptions{Prefix: "/usr", DestDir: stage, Release: true, Targets: []string{"core"}}))
+	assert.FileExists(t, filepath.Join(stage, "usr", "lib", "libcore.so.1"))
+	assert.NoFileExists(t, filepath.Join(stage, "usr", "bin", "tool"), "only --target artifacts are installed")
+
+	prefix := t.TempDir()
+	require.NoError(t, runInstall(installOptions{Prefix: prefix, Release: true}))
+	info, err := os.Stat(filepath.Join(prefix, "bin", "tool"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0111)
+	assert.NoFileExists(t, filepath.Join(prefix, "share", "Project", "SHA256SUMS"))
+
+	assert.ErrorContains(t, runInstall(installOptions{Prefix: prefix, Release: true, Targets: []string{"missing"}}), "no artifact named 'missing'")
+	assert.ErrorContains(t, runInstall(installOptions{Prefix: prefix}), "run 'cpx build' first")
+	assert.ErrorContains(t, runInstall(installOptions{Prefix: prefix, Release: true, Strip: true}), "need a CMake build directory")
+}
+
+func TestCmakeInstallArgs(t *testing.T) {
+	assert.Equal(t, []string{"--install", "b", "--prefix", "/usr"}, cmakeInstallArgs("b", "/usr", "", false))
+	assert.Equal(t, []string{"--install", "b", "--prefix", "/usr", "--component", "dev", "--strip"}, cmakeInstallArgs("b", "/usr", "dev", true))
+}
+
+func TestMatchArtifact(t *testing.T) {
+	for file, want := range map[string]string{"app": "app", "app.exe": "app", "libfoo.so.1": "foo", "foo.lib": "foo"} {
+		got, ok := matchArtifact(file, []string{"app", "foo"})
+		assert.True(t, ok, file)
+		assert.Equal(t, want, got, file)
+	}
+	_, ok := matchArtifact("bar.dll", []string{"app"})
+	assert.False(t, ok)
+	_, ok = matchArtifact("bar.dll", nil)
+	assert.True(t, ok)
+}
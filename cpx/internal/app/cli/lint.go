@@ -10,18 +10,39 @@ func LintCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "lint",
 		Short: "Run clang-tidy static analysis",
-		Long:  "Run clang-tidy static analysis. Use --fix to automatically fix issues.",
+		Long: `Run clang-tidy on the project's translation units with the compilation
+database of the debug build (builddir for Meson, hedron_compile_commands for
+Bazel), configuring the project first if it has none.
+
+Files are linted in parallel, --jobs at a time. --fix applies the suggested
+fixes and --fix-errors applies them even when the code has compile errors;
+fixing lints a file at a time so fixes to a shared header do not conflict.
+--changed restricts linting to the files changed since a git ref, including
+uncommitted and untracked files; without a ref, to uncommitted changes.
+
+Unlike 'cpx analyze', this runs clang-tidy only and writes no report.`,
+		Example: `  cpx lint
+  cpx lint --fix
+  cpx lint --changed=origin/main -j 8`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runLint(cmd, args)
 		},
 	}
 
 	cmd.Flags().Bool("fix", false, "Automatically fix issues")
+	cmd.Flags().Bool("fix-errors", false, "Fix issues even if the code has compile errors")
+	cmd.Flags().IntP("jobs", "j", 0, "Files to lint in parallel (default: number of CPUs)")
+	cmd.Flags().String("changed", "", "Only files changed since this git ref (default with no ref: HEAD)")
+	cmd.Flags().Lookup("changed").NoOptDefVal = "HEAD"
 
 	return cmd
 }
 
 func runLint(cmd *cobra.Command, args []string) error {
-	fix, _ := cmd.Flags().GetBool("fix")
-	return quality.LintCode(fix, vcpkg.New())
+	opts := quality.LintOptions{}
+	opts.Fix, _ = cmd.Flags().GetBool("fix")
+	opts.FixErrors, _ = cmd.Flags().GetBool("fix-errors")
+	opts.Jobs, _ = cmd.Flags().GetInt("jobs")
+	opts.Changed, _ = cmd.Flags().GetString("changed")
+	return quality.LintCode(opts, vcpkg.New())
 }
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/ozacod/cpx/internal/app/cli/tui"
 	"github.com/ozacod/cpx/internal/pkg/build/meson"
+	"github.com/ozacod/cpx/pkg/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -171,3 +173,31 @@ func TestCreateProjectFromTUI_Meson(t *testing.T) {
 	assert.Contains(t, string(content), "project('meson-proj', 'cpp'")
 	assert.Contains(t, string(content), "cpp_std=c++20")
 }
+
+func TestCreateProjectFromTUI_Vcpkg(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("VCPKG_ROOT", "")
+
+	tmpDir := t.TempDir()
+	oldWd, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(oldWd) }()
+	require.NoError(t, os.Chdir(tmpDir))
+
+	projectConfig := tui.ProjectConfig{
+		Name:           "vcpkg-proj",
+		PackageManager: "vcpkg",
+		CppStandard:    20,
+		TestFramework:  "doctest",
+		VCS:            "none",
+	}
+	require.NoError(t, createProjectFromTUI(projectConfig))
+
+	for _, file := range []string{"CMakeLists.txt", "vcpkg.json", "cpx-ci.yaml", ".clang-format", ".clang-tidy", "tests/test_main.cpp"} {
+		assert.FileExists(t, filepath.Join("vcpkg-proj", file))
+	}
+	manifest, _ := os.ReadFile("vcpkg-proj/vcpkg.json")
+	assert.Contains(t, string(manifest), `"name": "vcpkg-proj"`)
+	_, err = config.LoadToolchains("vcpkg-proj/cpx-ci.yaml")
+	assert.NoError(t, err, "the starter cpx-ci.yaml is valid")
+}
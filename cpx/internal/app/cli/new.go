@@ -6,7 +6,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,6 +16,7 @@ import (
 	"github.com/ozacod/cpx/internal/pkg/build/vcpkg"
 	"github.com/ozacod/cpx/internal/pkg/templates"
 	"github.com/ozacod/cpx/internal/pkg/templates/project_templates"
+	"github.com/ozacod/cpx/internal/pkg/templates/remote"
 	"github.com/ozacod/cpx/internal/pkg/utils/colors"
 	"github.com/ozacod/cpx/internal/pkg/utils/git"
 	"github.com/spf13/cobra"
@@ -25,21 +25,41 @@ import (
 // NewCmd creates the new command with interactive TUI
 func NewCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "new",
+		Use:   "new [name]",
 		Short: "Create a new C++ project (interactive)",
-		Long:  "Create a new C++ project using an interactive TUI. This will guide you through the project configuration.",
-		Example: `  cpx new            # launch the interactive creator
-  cpx new --help    # view options`,
+		Long: `Create a new C++ project using an interactive TUI. This will guide you through the project configuration.
+
+With --template, the project is created from a template without questions:
+a built-in template, one added with 'cpx template add', gh:org/repo, a git
+URL, the URL of a .tar.gz or .zip archive, or a local directory. {{project_name}},
+{{namespace}} and the variables of the template's cpx-template.yaml are
+replaced in file contents and names.`,
+		Example: `  cpx new                                   # launch the interactive creator
+  cpx new myapp --template cli              # built-in template
+  cpx new myapp --template gh:acme/cpp-service#v2 --var port=8080`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runNew(cmd, args)
 		},
-		Args: cobra.NoArgs,
+		Args: cobra.MaximumNArgs(1),
 	}
+	cmd.Flags().String("template", "", "Create the project from a template instead of the interactive creator")
+	cmd.Flags().StringArray("var", nil, "Set a template variable (name=value, repeatable)")
 
 	return cmd
 }
 
-func runNew(_ *cobra.Command, _ []string) error {
+func runNew(cmd *cobra.Command, args []string) error {
+	if source, _ := cmd.Flags().GetString("template"); source != "" {
+		if len(args) == 0 {
+			return fmt.Errorf("--template needs a project name: cpx new <name> --template %s", source)
+		}
+		vars, _ := cmd.Flags().GetStringArray("var")
+		return createProjectFromTemplate(args[0], source, vars)
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("a project name needs --template; run 'cpx new' for the interactive creator")
+	}
+
 	// Initialize and run the TUI
 	p := tea.NewProgram(tui.InitialModel())
 	m, err := p.Run()
@@ -65,6 +85,71 @@ func runNew(_ *cobra.Command, _ []string) error {
 	return createProjectFromTUI(config)
 }
 
+// createProjectFromTemplate creates a project from a built-in template, one
+// added with `cpx template add`, or a template source fetched for this
+// project only
+func createProjectFromTemplate(projectName, source string, vars []string) error {
+	if _, err := os.Stat(projectName); err == nil {
+		return fmt.Errorf("directory '%s' already exists", projectName)
+	}
+	values := make(map[string]string)
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid --var '%s': expected name=value", v)
+		}
+		values[name] = value
+	}
+
+	if builtin, ok := project_templates.GetTemplateByName(source); ok && !remote.IsSource(source) {
+		if len(values) > 0 {
+			return fmt.Errorf("built-in template '%s' has no variables", source)
+		}
+		return builtin.Generate(project_templates.TemplateConfig{ProjectName: projectName, PackageManager: "vcpkg", CppStandard: 17})
+	}
+
+	dir, err := remote.Lookup(source)
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		if !remote.IsSource(source) {
+			return fmt.Errorf("template '%s' not found; see 'cpx template list'", source)
+		}
+		tmp, err := os.MkdirTemp("", "cpx-template-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		fmt.Printf("%s Fetching %s...%s\n", colors.Cyan, source, colors.Reset)
+		dir = filepath.Join(tmp, "template")
+		if err := remote.Fetch(source, dir); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := remote.LoadManifest(dir)
+	if err != nil {
+		return err
+	}
+	resolved, err := manifest.Values(projectName, values)
+	if err != nil {
+		return err
+	}
+	if err := manifest.Render(dir, projectName, resolved); err != nil {
+		_ = os.RemoveAll(projectName)
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	gitInitCmd := exec.Command("git", "init")
+	gitInitCmd.Dir = projectName
+	_ = gitInitCmd.Run()
+
+	fmt.Printf("\n%s✓ Project '%s' created successfully!%s\n\n", colors.Green, projectName, colors.Reset)
+	fmt.Printf("  cd %s && cpx build && cpx run\n\n", projectName)
+	return nil
+}
+
 func createProjectFromTUI(config tui.ProjectConfig) error {
 	projectName := config.Name
 
@@ -138,13 +223,6 @@ func createProjectFromTUI(config tui.ProjectConfig) error {
 		cfg.PackageManager = "vcpkg"
 	}
 
-	// Initialize git repository only if VCS is set to git
-	if cfg.VCS == "git" {
-		cmd := exec.Command("git", "init")
-		cmd.Dir = projectName
-		_ = cmd.Run() // Ignore errors silently
-	}
-
 	// Set C++ standard default
 	cppStandard := cfg.CppStandard
 	if cppStandard == 0 {
@@ -271,6 +349,11 @@ func createProjectFromTUI(config tui.ProjectConfig) error {
 		return fmt.Errorf("failed to write .clang-format: %w", err)
 	}
 
+	// Generate .clang-tidy
+	if err := os.WriteFile(filepath.Join(projectName, ".clang-tidy"), []byte(templates.GenerateClangTidy()), 0644); err != nil {
+		return fmt.Errorf("failed to write .clang-tidy: %w", err)
+	}
+
 	// Generate test files if test framework is selected
 	if cfg.TestFramework != "" && cfg.TestFramework != "none" {
 		if err := builder.GenerateBuildTest(context.Background(), projectName, initConfig); err != nil {
@@ -284,7 +367,7 @@ func createProjectFromTUI(config tui.ProjectConfig) error {
 	}
 
 	// Generate cpx-ci.yaml file
-	cpxCI := templates.GenerateCpxCI()
+	cpxCI := templates.GenerateCpxCI(cfg.PackageManager)
 	if err := os.WriteFile(filepath.Join(projectName, "cpx-ci.yaml"), []byte(cpxCI), 0644); err != nil {
 		return fmt.Errorf("failed to write cpx-ci.yaml: %w", err)
 	}
@@ -299,6 +382,13 @@ func createProjectFromTUI(config tui.ProjectConfig) error {
 				_ = setupVcpkgProject(vcpkgBuilder, projectName, projectName, cfg.IsLibrary, []string{})
 			}
 		}
+		// Without vcpkg installed, write the manifest it would have created
+		if !CheckFileExists(filepath.Join(projectName, "vcpkg.json")) {
+			vcpkgJSON := templates.GenerateVcpkgJSON(projectName, projectVersion, nil)
+			if err := os.WriteFile(filepath.Join(projectName, "vcpkg.json"), []byte(vcpkgJSON), 0644); err != nil {
+				return fmt.Errorf("failed to write vcpkg.json: %w", err)
+			}
+		}
 	}
 
 	// Skip CMake-based test/bench generation for Bazel projects
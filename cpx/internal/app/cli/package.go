@@ -0,0 +1,310 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/packaging"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// packagesDir is the directory under the CI output directory holding packages
+const packagesDir = "packages"
+
+// PackageCmd creates the package command
+func PackageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "package",
+		Short: "Build distributable packages from CI artifacts",
+		Long: `Package the artifacts of 'cpx ci build' into tar.gz and zip archives and
+deb and rpm packages, one per toolchain, in <output>/packages.
+
+The formats, the toolchains to package and the deb/rpm metadata come from the
+'package:' section of cpx-ci.yaml; the name and version default to the
+project's. The architecture of each package comes from its runner's platform
+or target, or from the toolchain name (e.g. linux-arm64), and deb and rpm
+packages are only built for Linux toolchains. Building rpm packages requires
+rpmbuild.
+
+Executables and DLLs go to bin/, libraries to lib/ and other files to
+share/<name>/; the project's LICENSE and README files are included as well.`,
+		Example: `  cpx package
+  cpx package --toolchain linux-release
+  cpx package --format deb --format rpm`,
+		RunE: runPackage,
+	}
+	cmd.Flags().StringP("toolchain", "t", "", "Package a single toolchain")
+	cmd.Flags().StringSlice("format", nil, "Package formats (tar.gz, zip, deb, rpm), overriding cpx-ci.yaml")
+	return cmd
+}
+
+// packageTarget is a toolchain's artifacts to package
+type packageTarget struct {
+	Toolchain config.Toolchain
+	OS        string // linux, windows, darwin, ...
+	Arch      string // Go-style architecture
+	Files     []packaging.File
+}
+
+func runPackage(cmd *cobra.Command, _ []string) error {
+	toolchainName, _ := cmd.Flags().GetString("toolchain")
+	formats, _ := cmd.Flags().GetStringSlice("format")
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	pkg := ciConfig.Package
+	if pkg == nil {
+		pkg = &config.PackageConfig{}
+	}
+	if len(formats) == 0 {
+		formats = pkg.GetFormats()
+	}
+	if err := (&config.PackageConfig{Formats: formats}).Validate(); err != nil {
+		return err
+	}
+
+	toolchains, err := packageToolchains(ciConfig, pkg, toolchainName)
+	if err != nil {
+		return err
+	}
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	projectName, projectVersion := getProjectInfo()
+	meta := packaging.Metadata{
+		Name:        pkg.Name,
+		Version:     pkg.Version,
+		Maintainer:  pkg.Maintainer,
+		Description: pkg.Description,
+		Homepage:    pkg.Homepage,
+		License:     pkg.License,
+		Depends:     pkg.Depends,
+		Prefix:      pkg.GetPrefix(),
+	}
+	if meta.Name == "" {
+		meta.Name = projectName
+	}
+	if meta.Version == "" {
+		meta.Version = projectVersion
+	}
+	if meta.Maintainer == "" {
+		meta.Maintainer = "Unknown <unknown@example.com>"
+	}
+	if meta.Description == "" {
+		meta.Description = meta.Name
+	}
+
+	outputDir := ciConfig.GetOutputDir()
+	destDir := filepath.Join(outputDir, packagesDir)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", destDir, err)
+	}
+
+	docs := projectDocs(projectRoot)
+	written := make(map[string]string) // package file -> toolchain
+	var built []string
+	for _, tc := range toolchains {
+		target, err := collectPackageTarget(ciConfig, tc, outputDir, meta.Name)
+		if err != nil {
+			return err
+		}
+		if len(target.Files) == 0 {
+			fmt.Printf("%s⚠ %s has no artifacts in %s; run 'cpx ci build' first%s\n", colors.Yellow, tc.Name, filepath.Join(outputDir, tc.Name), colors.Reset)
+			continue
+		}
+		tcMeta := meta
+		tcMeta.Arch = target.Arch
+		for _, format := range packaging.Formats {
+			if !slices.Contains(formats, format) {
+				continue
+			}
+			if (format == "deb" || format == "rpm") && target.OS != "linux" {
+				fmt.Printf("%s⚠ Skipping %s package of %s: not a Linux toolchain%s\n", colors.Yellow, format, tc.Name, colors.Reset)
+				continue
+			}
+			file := packageFileName(format, tcMeta, tc.Name)
+			if other, ok := written[file]; ok {
+				return fmt.Errorf("toolchains '%s' and '%s' give the same package %s; package them separately with --toolchain", other, tc.Name, file)
+			}
+			written[file] = tc.Name
+
+			fmt.Printf("%s Packaging %s as %s...%s\n", colors.Cyan, tc.Name, file, colors.Reset)
+			if err := writePackage(format, filepath.Join(destDir, file), tcMeta, tc.Name, target.Files, docs); err != nil {
+				return fmt.Errorf("failed to package %s: %w", tc.Name, err)
+			}
+			built = append(built, filepath.Join(destDir, file))
+		}
+	}
+	if len(built) == 0 {
+		return fmt.Errorf("no packages were built")
+	}
+
+	if ciConfig.Sign != nil {
+		if !CheckCommandExists(ciConfig.Sign.Method) {
+			return fmt.Errorf("%s not found in PATH, required by sign.method in cpx-ci.yaml", ciConfig.Sign.Method)
+		}
+		for _, file := range built {
+			if err := signFile(ciConfig.Sign, file); err != nil {
+				return err
+			}
+		}
+	}
+	// Keep the SHA256SUMS of `cpx ci build` covering everything under the output directory
+	if _, err := os.Stat(filepath.Join(outputDir, checksumsFile)); err == nil {
+		if _, err := writeManifest(outputDir); err != nil {
+			return err
+		}
+		if ciConfig.Sign != nil {
+			if err := signFile(ciConfig.Sign, filepath.Join(outputDir, checksumsFile)); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("%s✓ Built %d package(s) in %s%s\n", colors.Green, len(built), destDir, colors.Reset)
+	return nil
+}
+
+// packageToolchains returns the toolchains to package, with extends applied:
+// the one named with --toolchain, those listed in package.toolchains, or every
+// active toolchain
+func packageToolchains(ciConfig *config.ToolchainConfig, pkg *config.PackageConfig, name string) ([]config.Toolchain, error) {
+	names := pkg.Toolchains
+	if name != "" {
+		names = []string{name}
+	}
+	if len(names) == 0 {
+		resolved, err := ciConfig.ResolveToolchains()
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+		}
+		var toolchains []config.Toolchain
+		for _, tc := range resolved {
+			if tc.IsActive() {
+				toolchains = append(toolchains, tc)
+			}
+		}
+		return toolchains, nil
+	}
+	var toolchains []config.Toolchain
+	for _, n := range names {
+		tc, err := ciConfig.ResolveToolchain(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpx-ci.yaml: %w", err)
+		}
+		if tc == nil {
+			return nil, fmt.Errorf("toolchain '%s' not found in cpx-ci.yaml", n)
+		}
+		toolchains = append(toolchains, *tc)
+	}
+	return toolchains, nil
+}
+
+// collectPackageTarget returns the artifacts in a toolchain's output directory
+// with where they go in a package, and the OS and architecture they are for
+func collectPackageTarget(ciConfig *config.ToolchainConfig, tc config.Toolchain, outputDir, name string) (packageTarget, error) {
+	platform, hint := "", tc.Name
+	osFallback, archFallback := runtime.GOOS, runtime.GOARCH
+	if runner := ciConfig.FindRunner(tc.Runner); runner != nil {
+		platform = runnerPlatform(runner)
+		hint += " " + runner.Target
+		if runner.IsDocker() {
+			osFallback = "linux" // Docker runners build Linux binaries unless their target says otherwise
+		}
+	}
+	target := packageTarget{
+		Toolchain: tc,
+		OS:        packaging.OS(platform, hint, osFallback),
+		Arch:      packaging.Arch(platform, hint, archFallback),
+	}
+
+	dir := filepath.Join(outputDir, tc.Name)
+	for _, a := range artifactDigests(dir) {
+		source := filepath.Join(dir, a.Name)
+		info, err := os.Stat(source)
+		if err != nil {
+			return target, err
+		}
+		path, mode := packagePath(a.Name, info.Mode(), name)
+		target.Files = append(target.Files, packaging.File{Source: source, Path: path, Mode: mode})
+	}
+	return target, nil
+}
+
+// packagePath returns where an artifact goes in a package and its permissions:
+// executables and DLLs to bin/, libraries to lib/, anything else to share/<name>/
+func packagePath(file string, mode os.FileMode, name string) (string, os.FileMode) {
+	lower := strings.ToLower(file)
+	switch {
+	case strings.HasSuffix(lower, ".exe") || strings.HasSuffix(lower, ".dll"):
+		return "bin/" + file, 0755
+	case strings.HasSuffix(lower, ".a") || strings.HasSuffix(lower, ".lib") || strings.HasSuffix(lower, ".dylib") ||
+		strings.HasSuffix(lower, ".so") || strings.Contains(lower, ".so."):
+		if mode&0111 != 0 {
+			return "lib/" + file, 0755
+		}
+		return "lib/" + file, 0644
+	case mode&0111 != 0:
+		return "bin/" + file, 0755
+	}
+	return "share/" + name + "/" + file, 0644
+}
+
+// projectDocs returns the project's LICENSE and README files
+func projectDocs(projectRoot string) []string {
+	var docs []string
+	for _, pattern := range []string{"LICENSE*", "LICENCE*", "COPYING*", "README*"} {
+		matches, _ := filepath.Glob(filepath.Join(projectRoot, pattern))
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+				docs = append(docs, m)
+			}
+		}
+	}
+	return docs
+}
+
+// packageFileName returns the file name of a toolchain's package in format
+func packageFileName(format string, meta packaging.Metadata, toolchain string) string {
+	switch format {
+	case "deb":
+		return packaging.DebFileName(meta)
+	case "rpm":
+		return packaging.RPMFileName(meta)
+	}
+	return fmt.Sprintf("%s-%s-%s.%s", meta.Name, meta.Version, toolchain, format)
+}
+
+// writePackage writes a toolchain's package in format. Archives hold the
+// files under a name-version-toolchain directory with the docs at its top;
+// deb and rpm packages install the docs to share/doc/<name>.
+func writePackage(format, dest string, meta packaging.Metadata, toolchain string, files []packaging.File, docs []string) error {
+	files = slices.Clone(files)
+	for _, doc := range docs {
+		path := filepath.Base(doc)
+		if format == "deb" || format == "rpm" {
+			path = "share/doc/" + packaging.DebName(meta.Name) + "/" + path
+		}
+		files = append(files, packaging.File{Source: doc, Path: path, Mode: 0644})
+	}
+	root := fmt.Sprintf("%s-%s-%s", meta.Name, meta.Version, toolchain)
+	switch format {
+	case "zip":
+		return packaging.WriteZip(dest, root, files)
+	case "deb":
+		return packaging.WriteDeb(dest, meta, files)
+	case "rpm":
+		return packaging.WriteRPM(dest, meta, files)
+	}
+	return packaging.WriteTarGz(dest, root, files)
+}
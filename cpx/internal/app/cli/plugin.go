@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// pluginPrefix starts the names of the executables run as `cpx <name>`
+const pluginPrefix = "cpx-"
+
+// PluginCmds returns a command running each cpx-<name> executable on PATH as
+// `cpx <name>`, git-style. The first executable of a name on PATH wins, and
+// plugins never replace a command of root.
+func PluginCmds(root *cobra.Command) []*cobra.Command {
+	taken := map[string]bool{"help": true, "completion": true}
+	for _, c := range root.Commands() {
+		taken[c.Name()] = true
+		for _, alias := range c.Aliases {
+			taken[alias] = true
+		}
+	}
+
+	plugins := findPlugins(filepath.SplitList(os.Getenv("PATH")))
+	var cmds []*cobra.Command
+	for _, name := range slices.Sorted(maps.Keys(plugins)) {
+		if taken[name] {
+			continue
+		}
+		path := plugins[name]
+		cmds = append(cmds, &cobra.Command{
+			Use:                name,
+			Short:              fmt.Sprintf("Run the %s%s plugin (%s)", pluginPrefix, name, path),
+			DisableFlagParsing: true,
+			RunE: func(_ *cobra.Command, args []string) error {
+				return runPlugin(path, args)
+			},
+		})
+	}
+	return cmds
+}
+
+// findPlugins maps plugin names to the first executable of each in dirs
+func findPlugins(dirs []string) map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), pluginPrefix)
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if !ok || name == "" || plugins[name] != "" {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if isExecutable(path) {
+				plugins[name] = path
+			}
+		}
+	}
+	return plugins
+}
+
+// isExecutable returns true if path is a file the user can run
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(path))
+		return ext == ".exe" || ext == ".bat" || ext == ".cmd"
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// runPlugin runs a plugin with args and the project's context in its
+// environment, exiting with the plugin's exit code when it fails
+func runPlugin(path string, args []string) error {
+	c := exec.Command(path, args...)
+	c.Env = append(os.Environ(), pluginEnv()...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		return fmt.Errorf("failed to run %s: %w", path, err)
+	}
+	return nil
+}
+
+// pluginEnv returns the variables telling a plugin about cpx and the project:
+// paths of files that do not exist are left out
+func pluginEnv() []string {
+	env := []string{"CPX_VERSION=" + Version}
+	if exe, err := os.Executable(); err == nil {
+		env = append(env, "CPX_BIN="+exe)
+	}
+	if projectRoot, err := findProjectRoot(); err == nil {
+		env = append(env, "CPX_PROJECT_ROOT="+projectRoot, "CPX_PROJECT_TYPE="+string(DetectProjectType()))
+		if ci := filepath.Join(projectRoot, "cpx-ci.yaml"); CheckFileExists(ci) {
+			env = append(env, "CPX_CI_CONFIG="+ci)
+		}
+	}
+	if global, err := config.GetConfigPath(); err == nil && CheckFileExists(global) {
+		env = append(env, "CPX_CONFIG="+global)
+	}
+	if project := config.FindProjectConfig(); project != "" {
+		env = append(env, "CPX_PROJECT_CONFIG="+project)
+	}
+	return env
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginCmds(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+	first, second := t.TempDir(), t.TempDir()
+	out := filepath.Join(t.TempDir(), "env")
+	script := "#!/bin/sh\necho \"$@ $CPX_PROJECT_ROOT $CPX_CI_CONFIG\" > " + out + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(first, "cpx-deploy"), []byte(script), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(second, "cpx-deploy"), []byte("#!/bin/sh\nexit 1\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(second, "cpx-build"), []byte("#!/bin/sh\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(second, "cpx-notes.txt"), []byte("not executable"), 0644))
+	t.Setenv("PATH", first+string(os.PathListSeparator)+second)
+
+	root := &cobra.Command{Use: "cpx"}
+	root.AddCommand(&cobra.Command{Use: "build"})
+	cmds := PluginCmds(root)
+	require.Len(t, cmds, 1, "built-in commands and non-executables are not plugins")
+	assert.Equal(t, "deploy", cmds[0].Name())
+	assert.Contains(t, cmds[0].Short, filepath.Join(first, "cpx-deploy"), "the first on PATH wins")
+
+	project := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(project, "CMakeLists.txt"), nil, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "cpx-ci.yaml"), nil, 0644))
+	t.Chdir(project)
+	root.AddCommand(cmds...)
+	root.SetArgs([]string{"deploy", "--env", "prod"})
+	require.NoError(t, root.Execute())
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "--env prod "+project+" "+filepath.Join(project, "cpx-ci.yaml"), strings.TrimSpace(string(data)))
+}
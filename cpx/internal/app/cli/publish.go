@@ -0,0 +1,363 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/internal/pkg/utils/git"
+	"github.com/ozacod/cpx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// PublishCmd creates the publish command
+func PublishCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "publish",
+		Short: "Upload CI artifacts to a GitHub Release",
+		Long: `Create or update the GitHub Release of the current tag and upload the
+artifacts of 'cpx ci build' to it: the files in each active toolchain's output
+directory (with their signatures, SBOMs and provenance), the packages of
+'cpx package' and SHA256SUMS. Files directly in a toolchain's directory are
+uploaded as <toolchain>-<file>; assets already on the release are replaced.
+
+The token comes from GITHUB_TOKEN or GH_TOKEN. The tag defaults to the one
+HEAD points at (GITHUB_REF_NAME on tag builds in GitHub Actions), the
+repository to GITHUB_REPOSITORY or the origin remote. GITHUB_API_URL selects
+a GitHub Enterprise server.`,
+		Example: `  cpx publish
+  cpx publish --tag v1.2.0 --draft
+  cpx publish --prerelease --repo acme/tool`,
+		RunE: runPublish,
+	}
+	cmd.Flags().String("tag", "", "Release tag (default: the tag of HEAD)")
+	cmd.Flags().String("repo", "", "GitHub repository as owner/name (default: from the origin remote)")
+	cmd.Flags().Bool("draft", false, "Create the release as a draft")
+	cmd.Flags().Bool("prerelease", false, "Mark the release as a prerelease")
+	return cmd
+}
+
+// githubRelease is the part of a GitHub release cpx uses
+type githubRelease struct {
+	ID        int64         `json:"id"`
+	TagName   string        `json:"tag_name"`
+	HTMLURL   string        `json:"html_url"`
+	UploadURL string        `json:"upload_url"`
+	Assets    []githubAsset `json:"assets"`
+}
+
+// githubAsset is a file attached to a GitHub release
+type githubAsset struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// githubClient calls the GitHub REST API with a token
+type githubClient struct {
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+// releaseAsset is a file to upload and its asset name
+type releaseAsset struct {
+	Path string
+	Name string
+}
+
+func runPublish(cmd *cobra.Command, _ []string) error {
+	tag, _ := cmd.Flags().GetString("tag")
+	repo, _ := cmd.Flags().GetString("repo")
+	draft, _ := cmd.Flags().GetBool("draft")
+	prerelease, _ := cmd.Flags().GetBool("prerelease")
+
+	token := githubToken()
+	if token == "" {
+		return fmt.Errorf("GITHUB_TOKEN or GH_TOKEN must be set to publish a release")
+	}
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		return fmt.Errorf("failed to get project root: %w", err)
+	}
+	if tag == "" {
+		if os.Getenv("GITHUB_REF_TYPE") == "tag" {
+			tag = os.Getenv("GITHUB_REF_NAME")
+		} else if tag, err = git.HeadTag(projectRoot); err != nil {
+			return fmt.Errorf("%w; tag it or pass --tag", err)
+		}
+	}
+	if repo == "" {
+		if repo, err = githubRepo(projectRoot); err != nil {
+			return err
+		}
+	}
+
+	ciConfig, err := config.LoadToolchains("cpx-ci.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to load cpx-ci.yaml: %w", err)
+	}
+	assets, err := releaseAssets(ciConfig.GetOutputDir(), ciConfig.Toolchains)
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return fmt.Errorf("no artifacts in %s; run 'cpx ci build' first", ciConfig.GetOutputDir())
+	}
+
+	gh := newGitHubClient(token)
+
+	fmt.Printf("%s Publishing %d file(s) to %s release %s...%s\n", colors.Cyan, len(assets), repo, tag, colors.Reset)
+	release, err := gh.ensureRelease(repo, tag, draft, prerelease)
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		fmt.Printf("   Uploading %s\n", asset.Name)
+		if err := gh.uploadAsset(repo, release, asset); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%s✓ Published %s%s\n", colors.Green, release.HTMLURL, colors.Reset)
+	return nil
+}
+
+// githubToken returns the GitHub token of GITHUB_TOKEN or GH_TOKEN
+func githubToken() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv("GH_TOKEN")
+}
+
+// githubRepo returns the owner/name of the GitHub repository: GITHUB_REPOSITORY
+// in GitHub Actions, else the one of the origin remote
+func githubRepo(projectRoot string) (string, error) {
+	if repo := os.Getenv("GITHUB_REPOSITORY"); repo != "" {
+		return repo, nil
+	}
+	repo, ok := githubRepoFromURL(git.RemoteURL(projectRoot))
+	if !ok {
+		return "", fmt.Errorf("could not tell the GitHub repository from the origin remote; pass --repo owner/name")
+	}
+	return repo, nil
+}
+
+// githubTimeout bounds a GitHub API call, long enough to upload a large asset
+const githubTimeout = 10 * time.Minute
+
+// newGitHubClient returns a client of the API at GITHUB_API_URL, or of
+// github.com
+func newGitHubClient(token string) *githubClient {
+	apiURL := os.Getenv("GITHUB_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	return &githubClient{apiURL: strings.TrimSuffix(apiURL, "/"), token: token, client: &http.Client{Timeout: githubTimeout}}
+}
+
+var githubRemote = regexp.MustCompile(`github\.com[:/]([^/]+/[^/]+?)(\.git)?/?$`)
+
+// githubRepoFromURL returns the owner/name of a GitHub remote URL
+func githubRepoFromURL(remote string) (string, bool) {
+	m := githubRemote.FindStringSubmatch(remote)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// releaseAssets returns the files to attach to a release: the files directly
+// in the output directory (SHA256SUMS and its signature), in its packages
+// directory, and directly in each active toolchain's directory, named
+// <toolchain>-<file>. Result directories such as test-results are left out,
+// as are the stale outputs of inactive toolchains.
+func releaseAssets(outputDir string, toolchains []config.Toolchain) ([]releaseAsset, error) {
+	var assets []releaseAsset
+	names := make(map[string]string) // asset name -> file
+	add := func(dir, prefix string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			asset := releaseAsset{Path: filepath.Join(dir, e.Name()), Name: prefix + e.Name()}
+			if other, ok := names[asset.Name]; ok {
+				return fmt.Errorf("%s and %s would both be uploaded as %s", other, asset.Path, asset.Name)
+			}
+			names[asset.Name] = asset.Path
+			assets = append(assets, asset)
+		}
+		return nil
+	}
+	if err := add(outputDir, ""); err != nil {
+		return nil, err
+	}
+	if err := add(filepath.Join(outputDir, packagesDir), ""); err != nil {
+		return nil, err
+	}
+	for _, tc := range toolchains {
+		if !tc.IsActive() {
+			continue
+		}
+		if err := add(filepath.Join(outputDir, tc.Name), tc.Name+"-"); err != nil {
+			return nil, err
+		}
+	}
+	return assets, nil
+}
+
+// ensureRelease returns the release of tag, creating it if there is none.
+// The draft and prerelease flags are applied to an existing release too.
+func (gh *githubClient) ensureRelease(repo, tag string, draft, prerelease bool) (*githubRelease, error) {
+	release, err := gh.findRelease(repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil {
+		release = &githubRelease{}
+		body := map[string]any{"tag_name": tag, "name": tag, "draft": draft, "prerelease": prerelease}
+		if err := gh.do(http.MethodPost, gh.apiURL+"/repos/"+repo+"/releases", body, release); err != nil {
+			return nil, fmt.Errorf("failed to create release %s: %w", tag, err)
+		}
+		return release, nil
+	}
+	if draft || prerelease {
+		body := map[string]any{}
+		if draft {
+			body["draft"] = true
+		}
+		if prerelease {
+			body["prerelease"] = true
+		}
+		assets := release.Assets
+		if err := gh.do(http.MethodPatch, fmt.Sprintf("%s/repos/%s/releases/%d", gh.apiURL, repo, release.ID), body, release); err != nil {
+			return nil, fmt.Errorf("failed to update release %s: %w", tag, err)
+		}
+		if release.Assets == nil {
+			release.Assets = assets
+		}
+	}
+	return release, nil
+}
+
+// findRelease returns the release of tag, or nil if there is none. Drafts
+// have no tag yet, so they are looked up in the list of releases.
+func (gh *githubClient) findRelease(repo, tag string) (*githubRelease, error) {
+	var release githubRelease
+	err := gh.do(http.MethodGet, gh.apiURL+"/repos/"+repo+"/releases/tags/"+url.PathEscape(tag), nil, &release)
+	if err == nil {
+		return &release, nil
+	}
+	if !isNotFound(err) {
+		return nil, fmt.Errorf("failed to look up release %s: %w", tag, err)
+	}
+	var releases []githubRelease
+	if err := gh.do(http.MethodGet, gh.apiURL+"/repos/"+repo+"/releases?per_page=100", nil, &releases); err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	for i := range releases {
+		if releases[i].TagName == tag {
+			return &releases[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// uploadAsset attaches a file to a release, replacing an asset of the same name
+func (gh *githubClient) uploadAsset(repo string, release *githubRelease, asset releaseAsset) error {
+	for _, existing := range release.Assets {
+		if existing.Name == asset.Name {
+			if err := gh.do(http.MethodDelete, fmt.Sprintf("%s/repos/%s/releases/assets/%d", gh.apiURL, repo, existing.ID), nil, nil); err != nil {
+				return fmt.Errorf("failed to replace asset %s: %w", asset.Name, err)
+			}
+		}
+	}
+	data, err := os.ReadFile(asset.Path)
+	if err != nil {
+		return err
+	}
+	uploadURL, _, _ := strings.Cut(release.UploadURL, "{") // drop the {?name,label} template
+	req, err := http.NewRequest(http.MethodPost, uploadURL+"?name="+url.QueryEscape(asset.Name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if err := gh.send(req, nil); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", asset.Name, err)
+	}
+	return nil
+}
+
+// githubError is a failed GitHub API call
+type githubError struct {
+	Status  int
+	Message string
+}
+
+func (e *githubError) Error() string {
+	return fmt.Sprintf("GitHub API returned %d: %s", e.Status, e.Message)
+}
+
+func isNotFound(err error) bool {
+	ghErr, ok := err.(*githubError)
+	return ok && ghErr.Status == http.StatusNotFound
+}
+
+// do sends a JSON request to the GitHub API and decodes the response into out
+func (gh *githubClient) do(method, endpoint string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return gh.send(req, out)
+}
+
+// send authenticates and sends a request, decoding a JSON response into out
+func (gh *githubClient) send(req *http.Request, out any) error {
+	req.Header.Set("Authorization", "Bearer "+gh.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	resp, err := gh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return &githubError{Status: resp.StatusCode, Message: apiErr.Message}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/ozacod/cpx/internal/pkg/build/bazel"
 	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
@@ -18,8 +20,11 @@ func RunCmd() *cobra.Command {
 		Long: `Build the project and run the executable. Automatically detects project type:
   - vcpkg/CMake projects: Builds with CMake and runs the binary
   - Bazel projects: Uses bazel run
+  - Meson projects: Builds with Meson and runs the binary
 
-Arguments after -- are passed to the binary.`,
+The executable named after the project runs unless --target names another.
+Arguments after -- are passed to the binary, stdin is passed through, and cpx
+exits with the binary's exit status.`,
 		Example: `  cpx run                 # Debug build by default
   cpx run --release        # Release build, then run
   cpx run --asan           # Run with AddressSanitizer
@@ -29,7 +34,8 @@ Arguments after -- are passed to the binary.`,
 		},
 	}
 
-	cmd.Flags().Bool("release", false, "Build in release mode (-O2). Default is debug")
+	cmd.Flags().BoolP("release", "r", false, "Build in release mode (-O2). Default is debug")
+	cmd.Flags().String("target", "", "Executable to build and run (default: the project's)")
 	cmd.Flags().String("toolchain", "", "Toolchain to run in Docker (from cpx-ci.yaml)")
 	cmd.Flags().StringP("opt", "O", "", "Override optimization level: 0,1,2,3,s,fast")
 	cmd.Flags().Bool("verbose", false, "Show full build output")
@@ -46,6 +52,7 @@ func runRun(cmd *cobra.Command, args []string) error {
 	toolchain, _ := cmd.Flags().GetString("toolchain")
 	optLevel, _ := cmd.Flags().GetString("opt")
 	verbose, _ := cmd.Flags().GetBool("verbose")
+	target, _ := cmd.Flags().GetString("target")
 
 	if toolchain != "" {
 		return runToolchainBuild(ToolchainBuildOptions{
@@ -85,6 +92,10 @@ func runRun(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("only one sanitizer can be used at a time (got %d)", sanitizerCount)
 	}
 
+	if err := applyBuildDefaults(cmd, &release, nil); err != nil {
+		return err
+	}
+
 	projectType := DetectProjectType()
 
 	WarnMissingBuildTools(projectType)
@@ -93,22 +104,28 @@ func runRun(cmd *cobra.Command, args []string) error {
 		Release:   release,
 		OptLevel:  optLevel,
 		Sanitizer: sanitizer,
-		Target:    "",
+		Target:    target,
 		Args:      args,
 		Verbose:   verbose,
 	}
 
+	var builder build.BuildSystem
 	switch projectType {
 	case ProjectTypeBazel:
-		builder := bazel.New()
-		return builder.Run(context.Background(), opts)
+		builder = bazel.New()
 	case ProjectTypeMeson:
-		builder := meson.New()
-		return builder.Run(context.Background(), opts)
+		builder = meson.New()
 	case ProjectTypeVcpkg:
-		builder := vcpkg.New()
-		return builder.Run(context.Background(), opts)
+		builder = vcpkg.New()
 	default:
 		return fmt.Errorf("unsupported project type")
 	}
+
+	err := builder.Run(context.Background(), opts)
+	// Exit with the executable's status, like the executable itself would
+	var exitErr *build.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+	return err
 }
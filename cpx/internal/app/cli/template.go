@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ozacod/cpx/internal/pkg/templates/project_templates"
+	"github.com/ozacod/cpx/internal/pkg/templates/remote"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+// TemplateCmd creates the template command managing the templates of
+// `cpx new --template`
+func TemplateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "template",
+		Short: "Manage project templates for cpx new",
+		Long: `Manage the templates 'cpx new <name> --template <template>' creates projects from.
+
+'add' fetches a template once and caches it under a name in the cpx config
+directory; adding a name again refreshes it. Sources are gh:org/repo, a git
+URL, the URL of a .tar.gz or .zip archive, or a local directory; git sources
+take a branch or tag after '#'.
+
+A template is a directory of files in which {{project_name}}, {{namespace}}
+and the variables of its optional cpx-template.yaml are replaced:
+
+  name: service
+  description: HTTP service with CMake and vcpkg
+  variables:
+    - name: port
+      default: "8080"
+  exclude: ["docs/*.png"]`,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List built-in and added templates",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTemplateList()
+		},
+		Args: cobra.NoArgs,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:     "add <name> <source>",
+		Short:   "Fetch a template and cache it under a name",
+		Example: "  cpx template add service gh:acme/cpp-service#v2\n  cpx template add lib https://example.com/templates/lib.tar.gz",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTemplateAdd(args[0], args[1])
+		},
+		Args: cobra.ExactArgs(2),
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove an added template from the cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := remote.Remove(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("%s✓ Removed template '%s'%s\n", colors.Green, args[0], colors.Reset)
+			return nil
+		},
+		Args: cobra.ExactArgs(1),
+	})
+	return cmd
+}
+
+func runTemplateList() error {
+	fmt.Printf("%sBuilt-in%s\n", colors.Bold, colors.Reset)
+	for _, t := range project_templates.Registry {
+		fmt.Printf("  %-16s %s\n", t.Name, t.Description)
+	}
+
+	entries, err := remote.List()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%sAdded%s\n", colors.Bold, colors.Reset)
+	if len(entries) == 0 {
+		fmt.Printf("  %snone; add one with 'cpx template add <name> <source>'%s\n", colors.Gray, colors.Reset)
+	}
+	for _, e := range entries {
+		fmt.Printf("  %-16s %s %s(%s)%s\n", e.Name, e.Description, colors.Gray, e.Source, colors.Reset)
+	}
+	return nil
+}
+
+func runTemplateAdd(name, source string) error {
+	if _, ok := project_templates.GetTemplateByName(name); ok {
+		return fmt.Errorf("'%s' is a built-in template; choose another name", name)
+	}
+	fmt.Printf("%s Fetching %s...%s\n", colors.Cyan, source, colors.Reset)
+	entry, err := remote.Add(name, source)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s✓ Added template '%s'%s\n", colors.Green, entry.Name, colors.Reset)
+	fmt.Printf("  cpx new <name> --template %s\n", entry.Name)
+	return nil
+}
@@ -112,7 +112,9 @@ func runAddRunnerCmd(_ *cobra.Command, _ []string) error {
 	runner := config.Runner{
 		Name:               result.Name,
 		Type:               result.Type,
+		Target:             result.Target,
 		Image:              result.Image,
+		Platform:           result.Platform,
 		Host:               result.Host,
 		User:               result.User,
 		CC:                 result.CC,
@@ -10,6 +10,7 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
 )
 
 // ToolchainStep represents the current step in the target creation flow
@@ -288,7 +289,7 @@ func (m ToolchainModel) isTextInputStep() bool {
 
 // checkDockerImageExists checks if a Docker image exists locally
 func checkDockerImageExists(image string) bool {
-	cmd := exec.Command("docker", "images", "-q", image)
+	cmd := docker.Command("images", "-q", image)
 	output, err := cmd.Output()
 	if err != nil {
 		return false
@@ -298,7 +299,7 @@ func checkDockerImageExists(image string) bool {
 
 // listDockerImages returns a list of available local Docker images
 func listDockerImages() []DockerImage {
-	cmd := exec.Command("docker", "images", "--format", "{{.Repository}}\t{{.Tag}}\t{{.ID}}\t{{.Size}}\t{{.CreatedSince}}")
+	cmd := docker.Command("images", "--format", "{{.Repository}}\t{{.Tag}}\t{{.ID}}\t{{.Size}}\t{{.CreatedSince}}")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil
@@ -347,7 +348,7 @@ func getImageArchitectures(imageIDs []string) map[string]string {
 
 	// Use docker inspect to get architecture for all images at once
 	args := append([]string{"inspect", "--format", "{{.Id}}\t{{.Architecture}}"}, imageIDs...)
-	cmd := exec.Command("docker", args...)
+	cmd := docker.Command(args...)
 	output, err := cmd.Output()
 	if err != nil {
 		return archMap
@@ -466,7 +467,7 @@ func checkBuildToolsForProject(projectType string) []string {
 
 // checkDockerImageHasCommand checks if a command exists inside a Docker image (with timeout)
 func checkDockerImageHasCommand(image, command string) bool {
-	cmd := exec.Command("docker", "run", "--rm", "--entrypoint", "which", image, command)
+	cmd := docker.Command("run", "--rm", "--entrypoint", "which", image, command)
 	done := make(chan error, 1)
 	go func() {
 		done <- cmd.Run()
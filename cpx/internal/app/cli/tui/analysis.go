@@ -0,0 +1,430 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ozacod/cpx/internal/pkg/utils/glob"
+)
+
+// Finding is a finding of an analysis, as the analysis browser lists it
+type Finding struct {
+	Tool     string
+	Severity string
+	File     string
+	Line     int
+	Column   int
+	Message  string
+	Rule     string
+}
+
+// snippetContext is the number of source lines shown around a finding
+const snippetContext = 3
+
+// detailHeight is the number of lines of the detail pane
+const detailHeight = 2*snippetContext + 5
+
+var (
+	severityStyles = map[string]lipgloss.Style{
+		"error":   errorStyle.Bold(true),
+		"warning": lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500")),
+	}
+	paneStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderTop(true).
+			BorderForeground(dimGray)
+)
+
+// editorFinishedMsg is sent when the editor a finding was opened in exits
+type editorFinishedMsg struct {
+	err error
+}
+
+// AnalysisModel browses the findings of an analysis: a list filtered by tool,
+// severity and file, and a detail pane with the source around the selected
+// finding, which opens in $EDITOR
+type AnalysisModel struct {
+	findings   []Finding
+	visible    []int // indexes of the findings the filters keep
+	tools      []string
+	severities []string
+	tool       int // index of the tool filter in tools, 0 for all
+	severity   int // index of the severity filter in severities, 0 for all
+	fileFilter textinput.Model
+	filtering  bool
+	cursor     int
+	viewport   int
+	viewSize   int
+	width      int
+	sources    map[string][]string // lines of the files read for snippets
+	status     string
+	title      string
+}
+
+// NewAnalysisModel creates a browser of findings
+func NewAnalysisModel(findings []Finding, title string) AnalysisModel {
+	ti := textinput.New()
+	ti.Placeholder = "file substring or glob..."
+	ti.Prompt = "File: "
+	ti.CharLimit = 128
+	ti.Width = 40
+	ti.PromptStyle = inputPromptStyle
+	ti.TextStyle = inputTextStyle
+	ti.Cursor.Style = cursorStyle
+
+	m := AnalysisModel{
+		findings:   findings,
+		tools:      []string{"all"},
+		severities: []string{"all"},
+		fileFilter: ti,
+		viewSize:   10,
+		sources:    make(map[string][]string),
+		title:      title,
+	}
+	for _, f := range findings {
+		if !slices.Contains(m.tools, f.Tool) {
+			m.tools = append(m.tools, f.Tool)
+		}
+		if !slices.Contains(m.severities, f.Severity) {
+			m.severities = append(m.severities, f.Severity)
+		}
+	}
+	m.applyFilters()
+	return m
+}
+
+// Init initializes the model
+func (m AnalysisModel) Init() tea.Cmd {
+	return nil
+}
+
+// keeps reports whether a finding passes the tool, severity and file filters
+func (m AnalysisModel) keeps(f Finding) bool {
+	if m.tool > 0 && f.Tool != m.tools[m.tool] {
+		return false
+	}
+	if m.severity > 0 && f.Severity != m.severities[m.severity] {
+		return false
+	}
+	return matchesFile(strings.TrimSpace(m.fileFilter.Value()), f.File)
+}
+
+// matchesFile reports whether file matches a file filter: a glob when it has
+// glob characters, as in the include and exclude of .cpx-quality.yaml, else a
+// substring. An empty filter matches all files.
+func matchesFile(filter, file string) bool {
+	if filter == "" {
+		return true
+	}
+	file = filepath.ToSlash(file)
+	if strings.ContainsAny(filter, "*?[") {
+		return glob.Match(filter, file) || glob.Match(filter, path.Base(file))
+	}
+	return strings.Contains(file, filter)
+}
+
+// applyFilters recomputes the visible findings, keeping the cursor in range
+func (m *AnalysisModel) applyFilters() {
+	m.visible = m.visible[:0]
+	for i, f := range m.findings {
+		if m.keeps(f) {
+			m.visible = append(m.visible, i)
+		}
+	}
+	m.cursor = min(m.cursor, max(len(m.visible)-1, 0))
+	m.scroll()
+}
+
+// scroll moves the viewport so the cursor is in it
+func (m *AnalysisModel) scroll() {
+	if m.cursor < m.viewport {
+		m.viewport = m.cursor
+	}
+	if m.cursor >= m.viewport+m.viewSize {
+		m.viewport = m.cursor - m.viewSize + 1
+	}
+	m.viewport = max(min(m.viewport, len(m.visible)-m.viewSize), 0)
+}
+
+// Selected returns the finding under the cursor
+func (m AnalysisModel) Selected() (Finding, bool) {
+	if len(m.visible) == 0 {
+		return Finding{}, false
+	}
+	return m.findings[m.visible[m.cursor]], true
+}
+
+// Update handles messages and updates the model
+func (m AnalysisModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		// Title, filters, scroll indicators and help take 7 lines
+		m.viewSize = max(msg.Height-detailHeight-7, 3)
+		m.scroll()
+
+	case editorFinishedMsg:
+		m.status = ""
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Editor failed: %v", msg.err)
+		}
+		// The file may have changed
+		m.sources = make(map[string][]string)
+
+	case tea.KeyMsg:
+		if m.filtering {
+			switch msg.String() {
+			case "enter", "esc":
+				m.filtering = false
+				m.fileFilter.Blur()
+				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+			var cmd tea.Cmd
+			m.fileFilter, cmd = m.fileFilter.Update(msg)
+			m.applyFilters()
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return m, tea.Quit
+
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+
+		case "down", "j":
+			if m.cursor < len(m.visible)-1 {
+				m.cursor++
+			}
+
+		case "pgup", "ctrl+u":
+			m.cursor = max(m.cursor-m.viewSize, 0)
+
+		case "pgdown", "ctrl+d":
+			m.cursor = max(min(m.cursor+m.viewSize, len(m.visible)-1), 0)
+
+		case "home", "g":
+			m.cursor = 0
+
+		case "end", "G":
+			m.cursor = max(len(m.visible)-1, 0)
+
+		case "t":
+			m.tool = (m.tool + 1) % len(m.tools)
+			m.applyFilters()
+
+		case "s":
+			m.severity = (m.severity + 1) % len(m.severities)
+			m.applyFilters()
+
+		case "/", "f":
+			m.filtering = true
+			m.fileFilter.Focus()
+			return m, textinput.Blink
+
+		case "c":
+			m.tool, m.severity = 0, 0
+			m.fileFilter.SetValue("")
+			m.applyFilters()
+
+		case "enter", "e", "o":
+			f, ok := m.Selected()
+			if !ok {
+				return m, nil
+			}
+			return m, tea.ExecProcess(editorCommand(f.File, f.Line), func(err error) tea.Msg {
+				return editorFinishedMsg{err: err}
+			})
+		}
+		m.scroll()
+	}
+
+	return m, nil
+}
+
+// editorCommand returns the command opening file at line in $VISUAL or
+// $EDITOR, vi by default
+func editorCommand(file string, line int) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	fields := strings.Fields(editor)
+	if len(fields) == 0 {
+		fields = []string{"vi"}
+	}
+	args := fields[1:]
+	switch strings.TrimSuffix(filepath.Base(fields[0]), ".exe") {
+	case "code", "code-insiders", "codium", "cursor":
+		args = append(args, "--goto", fmt.Sprintf("%s:%d", file, line))
+	case "zed", "subl":
+		args = append(args, fmt.Sprintf("%s:%d", file, line))
+	default:
+		// vi, vim, nvim, nano, emacs, micro and kak take +line
+		if line > 0 {
+			args = append(args, "+"+strconv.Itoa(line))
+		}
+		args = append(args, file)
+	}
+	return exec.Command(fields[0], args...)
+}
+
+// sourceLines returns the lines of a file, read once
+func (m AnalysisModel) sourceLines(file string) []string {
+	if lines, ok := m.sources[file]; ok {
+		return lines
+	}
+	var lines []string
+	if data, err := os.ReadFile(file); err == nil {
+		lines = strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	}
+	m.sources[file] = lines
+	return lines
+}
+
+// snippet returns the numbered source lines around line, marking it; nil
+// when the file cannot be read or is shorter
+func snippet(lines []string, line, context int) []string {
+	if line < 1 || line > len(lines) {
+		return nil
+	}
+	start := max(line-context, 1)
+	end := min(line+context, len(lines))
+	width := len(strconv.Itoa(end))
+	var out []string
+	for n := start; n <= end; n++ {
+		marker := "  "
+		if n == line {
+			marker = "▸ "
+		}
+		out = append(out, fmt.Sprintf("%s%*d │ %s", marker, width, n, strings.ReplaceAll(lines[n-1], "\t", "    ")))
+	}
+	return out
+}
+
+// location returns the file:line:column of a finding
+func location(f Finding) string {
+	loc := fmt.Sprintf("%s:%d", f.File, f.Line)
+	if f.Column > 0 {
+		loc += fmt.Sprintf(":%d", f.Column)
+	}
+	return loc
+}
+
+// truncate shortens s to width runes, when width is known
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 3 || len(r) <= width {
+		return s
+	}
+	return string(r[:width-3]) + "..."
+}
+
+// renderSeverity renders a severity in its color
+func renderSeverity(severity string) string {
+	label := fmt.Sprintf("%-7s", severity)
+	if style, ok := severityStyles[severity]; ok {
+		return style.Render(label)
+	}
+	return dimStyle.Render(label)
+}
+
+// View renders the UI
+func (m AnalysisModel) View() string {
+	var s strings.Builder
+
+	s.WriteString(cyanBold.Render(m.title) + dimStyle.Render(fmt.Sprintf("  %d of %d findings", len(m.visible), len(m.findings))) + "\n")
+	s.WriteString(fmt.Sprintf("Tool: %s  Severity: %s  ", selectedStyle.Render(m.tools[m.tool]), selectedStyle.Render(m.severities[m.severity])))
+	if m.filtering || m.fileFilter.Value() != "" {
+		s.WriteString(m.fileFilter.View())
+	}
+	s.WriteString("\n\n")
+
+	if len(m.visible) == 0 {
+		s.WriteString(dimStyle.Render("No findings match the filters.") + "\n")
+	} else {
+		if m.viewport > 0 {
+			s.WriteString(dimStyle.Render("  ↑ more above") + "\n")
+		} else {
+			s.WriteString("\n")
+		}
+		end := min(m.viewport+m.viewSize, len(m.visible))
+		for i := m.viewport; i < end; i++ {
+			f := m.findings[m.visible[i]]
+			prefix := "  "
+			loc := truncate(location(f), 40)
+			if i == m.cursor {
+				prefix = selectedStyle.Render("▸ ")
+				loc = selectedStyle.Render(fmt.Sprintf("%-40s", loc))
+			} else {
+				loc = fmt.Sprintf("%-40s", loc)
+			}
+			// The cursor, severity and location take 51 columns
+			s.WriteString(fmt.Sprintf("%s%s %s %s\n", prefix, renderSeverity(f.Severity), loc, truncate(f.Message, m.width-51)))
+		}
+		if end < len(m.visible) {
+			s.WriteString(dimStyle.Render("  ↓ more below") + "\n")
+		} else {
+			s.WriteString("\n")
+		}
+	}
+
+	s.WriteString(m.detailView())
+
+	if m.status != "" {
+		s.WriteString(errorStyle.Render(m.status) + "\n")
+	}
+	if m.filtering {
+		s.WriteString(dimStyle.Render("Type a file filter • Enter/Esc: done"))
+	} else {
+		s.WriteString(dimStyle.Render("↑/↓: move • t: tool • s: severity • /: file • c: clear • Enter/e: open in $EDITOR • q: quit"))
+	}
+	return s.String()
+}
+
+// detailView renders the detail pane of the selected finding
+func (m AnalysisModel) detailView() string {
+	f, ok := m.Selected()
+	if !ok {
+		return ""
+	}
+	var d strings.Builder
+	rule := f.Tool
+	if f.Rule != "" {
+		rule += " " + f.Rule
+	}
+	d.WriteString(questionStyle.Render(location(f)) + "  " + renderSeverity(f.Severity) + " " + dimStyle.Render(rule) + "\n")
+	d.WriteString(f.Message + "\n\n")
+	lines := snippet(m.sourceLines(f.File), f.Line, snippetContext)
+	if lines == nil {
+		d.WriteString(dimStyle.Render("Source not available") + "\n")
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "▸") {
+			d.WriteString(selectedStyle.Render(truncate(line, m.width)) + "\n")
+		} else {
+			d.WriteString(dimStyle.Render(truncate(line, m.width)) + "\n")
+		}
+	}
+	return paneStyle.Render(d.String()) + "\n"
+}
+
+// RunAnalysisBrowser runs the browser of findings until the user quits
+func RunAnalysisBrowser(findings []Finding, title string) error {
+	p := tea.NewProgram(NewAnalysisModel(findings, title), tea.WithAltScreen())
+	_, err := p.Run()
+	return err
+}
@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFindings() []Finding {
+	return []Finding{
+		{Tool: "Cppcheck", Severity: "style", File: "src/main.cpp", Line: 3, Message: "unread variable"},
+		{Tool: "clang-tidy", Severity: "warning", File: "src/util.cpp", Line: 10, Message: "use auto", Rule: "modernize-use-auto"},
+		{Tool: "clang-tidy", Severity: "error", File: "include/util.h", Line: 7, Message: "unknown type name"},
+	}
+}
+
+func press(m AnalysisModel, keys ...string) AnalysisModel {
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		if key == "enter" {
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		}
+		next, _ := m.Update(msg)
+		m = next.(AnalysisModel)
+	}
+	return m
+}
+
+func TestAnalysisModelFilters(t *testing.T) {
+	m := NewAnalysisModel(testFindings(), "Findings")
+	assert.Equal(t, []string{"all", "Cppcheck", "clang-tidy"}, m.tools)
+	assert.Len(t, m.visible, 3)
+
+	m = press(m, "t", "t")
+	assert.Equal(t, []int{1, 2}, m.visible)
+
+	m = press(m, "s", "s")
+	assert.Equal(t, []int{1}, m.visible)
+
+	m = press(m, "c", "/", "u", "t", "i", "l", ".", "h", "enter")
+	assert.False(t, m.filtering)
+	assert.Equal(t, []int{2}, m.visible)
+	f, ok := m.Selected()
+	require.True(t, ok)
+	assert.Equal(t, "include/util.h", f.File)
+}
+
+func TestMatchesFile(t *testing.T) {
+	assert.True(t, matchesFile("", "src/main.cpp"))
+	assert.True(t, matchesFile("src/", "src/main.cpp"))
+	assert.True(t, matchesFile("*.cpp", "src/main.cpp"))
+	assert.True(t, matchesFile("src/*.cpp", "src/main.cpp"))
+	assert.True(t, matchesFile("src/**/*.h", "src/net/http/client.h"))
+	assert.False(t, matchesFile("*.h", "src/main.cpp"))
+}
+
+func TestSnippet(t *testing.T) {
+	lines := []string{"a", "b", "c", "d", "e"}
+	assert.Equal(t, []string{"  1 │ a", "▸ 2 │ b", "  3 │ c", "  4 │ d"}, snippet(lines, 2, 2))
+	assert.Nil(t, snippet(lines, 9, 2))
+	assert.Nil(t, snippet(nil, 1, 2))
+}
+
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "nvim -p")
+	assert.Equal(t, []string{"nvim", "-p", "+12", "src/main.cpp"}, editorCommand("src/main.cpp", 12).Args)
+
+	t.Setenv("EDITOR", "code --wait")
+	assert.Equal(t, []string{"code", "--wait", "--goto", "src/main.cpp:12"}, editorCommand("src/main.cpp", 12).Args)
+
+	t.Setenv("EDITOR", "")
+	assert.Equal(t, "vi", editorCommand("src/main.cpp", 12).Args[0])
+}
@@ -0,0 +1,173 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// LinkTargetItem represents a CMake target that can receive a link entry
+type LinkTargetItem struct {
+	Name string
+	Kind string // "executable" or "library"
+}
+
+// LinkTargetModel lets the user pick which CMake target(s) a new dependency is linked to
+type LinkTargetModel struct {
+	items    []LinkTargetItem
+	library  string
+	cursor   int
+	selected map[int]bool
+	done     bool
+	quitting bool
+}
+
+// NewLinkTargetModel creates a new link target selection model
+func NewLinkTargetModel(items []LinkTargetItem, library string) LinkTargetModel {
+	return LinkTargetModel{
+		items:    items,
+		library:  library,
+		selected: make(map[int]bool),
+	}
+}
+
+// Init initializes the model
+func (m LinkTargetModel) Init() tea.Cmd {
+	return nil
+}
+
+// optionCount returns the number of rows (targets plus "all" and "skip")
+func (m LinkTargetModel) optionCount() int {
+	return len(m.items) + 2
+}
+
+// Update handles messages and updates the model
+func (m LinkTargetModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.quitting = true
+			return m, tea.Quit
+
+		case "enter":
+			m.done = true
+			return m, tea.Quit
+
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+
+		case "down", "j":
+			if m.cursor < m.optionCount()-1 {
+				m.cursor++
+			}
+
+		case " ":
+			// Space toggles individual targets; "all" and "skip" are picked with Enter
+			if m.cursor < len(m.items) {
+				if m.selected[m.cursor] {
+					delete(m.selected, m.cursor)
+				} else {
+					m.selected[m.cursor] = true
+				}
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// View renders the UI
+func (m LinkTargetModel) View() string {
+	if m.quitting || m.done {
+		return ""
+	}
+
+	var s strings.Builder
+	s.WriteString(cyanBold.Render(fmt.Sprintf("Link %s to which target(s)?", m.library)) + "\n\n")
+
+	for i := 0; i < m.optionCount(); i++ {
+		prefix := "  "
+		if i == m.cursor {
+			prefix = "▸ "
+		}
+
+		var label, detail string
+		switch {
+		case i < len(m.items):
+			checkbox := "[ ]"
+			if m.selected[i] {
+				checkbox = greenCheck.Render("[✓]")
+			}
+			label = fmt.Sprintf("%s %s", checkbox, m.items[i].Name)
+			detail = m.items[i].Kind
+		case i == len(m.items):
+			label = "All targets"
+		default:
+			label = "Skip (don't modify CMakeLists.txt)"
+		}
+
+		line := prefix + label
+		if i == m.cursor {
+			line = selectedStyle.Render(line)
+		}
+		if detail != "" {
+			line += " " + dimStyle.Render(detail)
+		}
+		s.WriteString(line + "\n")
+	}
+
+	s.WriteString("\n")
+	if len(m.selected) > 0 {
+		s.WriteString(greenStyle.Render(fmt.Sprintf("%d selected", len(m.selected))) + " • ")
+	}
+	s.WriteString(dimStyle.Render("Space: toggle • Enter: confirm • q: cancel"))
+
+	return s.String()
+}
+
+// Result returns the chosen target names, or nil when the user skipped
+func (m LinkTargetModel) Result() []string {
+	if m.quitting {
+		return nil
+	}
+
+	// Explicit checkbox selection wins over the highlighted row
+	if len(m.selected) > 0 {
+		var names []string
+		for i, item := range m.items {
+			if m.selected[i] {
+				names = append(names, item.Name)
+			}
+		}
+		return names
+	}
+
+	switch {
+	case m.cursor < len(m.items):
+		return []string{m.items[m.cursor].Name}
+	case m.cursor == len(m.items):
+		var names []string
+		for _, item := range m.items {
+			names = append(names, item.Name)
+		}
+		return names
+	default:
+		return nil
+	}
+}
+
+// RunLinkTargetSelection runs the link target picker and returns the selected target names.
+// A nil result means the user chose "skip" or cancelled.
+func RunLinkTargetSelection(items []LinkTargetItem, library string) ([]string, error) {
+	p := tea.NewProgram(NewLinkTargetModel(items, library))
+	finalModel, err := p.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return finalModel.(LinkTargetModel).Result(), nil
+}
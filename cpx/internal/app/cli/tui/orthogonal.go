@@ -7,6 +7,8 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ozacod/cpx/internal/pkg/build/cross"
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
 )
 
 // =========================================
@@ -245,8 +247,10 @@ type AddRunnerStep int
 const (
 	RunnerStepName AddRunnerStep = iota
 	RunnerStepType
+	RunnerStepTarget
 	RunnerStepDockerImage
 	RunnerStepCheckingImage
+	RunnerStepPlatform
 	RunnerStepCompilerCC
 	RunnerStepCompilerCXX
 	RunnerStepCMakeToolchain
@@ -267,13 +271,17 @@ type AddRunnerModel struct {
 	existingNames    map[string]bool
 	name             string
 	runnerType       string
+	target           string
 	image            string
+	platform         string
+	platformOptions  []string
 	host             string
 	user             string
 	cc               string
 	cxx              string
 	cmakeToolchain   string
 	typeOptions      []string
+	targetOptions    []string
 	availableImages  []DockerImage
 	filteredImages   []DockerImage
 	imageCursor      int
@@ -284,7 +292,9 @@ type AddRunnerModel struct {
 type AddRunnerResult struct {
 	Name           string
 	Type           string
+	Target         string
 	Image          string
+	Platform       string
 	Host           string
 	User           string
 	CC             string
@@ -317,6 +327,8 @@ func NewAddRunnerModel(existingNames []string) AddRunnerModel {
 		spinner:          s,
 		existingNames:    existing,
 		typeOptions:      []string{"docker", "ssh"},
+		targetOptions:    append([]string{"none"}, cross.Names()...),
+		platformOptions:  append(docker.PlatformOptions(), "image default"),
 		availableImages:  images,
 		filteredImages:   images,
 		maxVisibleImages: 6,
@@ -344,11 +356,9 @@ func (m AddRunnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, cmd
 		case ImageCheckResult:
 			if msg.Success {
-				// Proceed to compiler settings
-				m.step = RunnerStepCompilerCC
-				m.textInput.Reset()
-				m.textInput.Placeholder = "(optional, e.g. gcc-13)"
-				m.textInput.Focus()
+				// Proceed to platform selection (host platform suggested first)
+				m.step = RunnerStepPlatform
+				m.cursor = 0
 				return m, nil
 			} else {
 				m.errorMsg = msg.Error
@@ -370,10 +380,10 @@ func (m AddRunnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			return m.handleEnter()
 		case "up", "k":
-			if m.step == RunnerStepType {
+			if m.step == RunnerStepType || m.step == RunnerStepTarget || m.step == RunnerStepPlatform {
 				m.cursor--
 				if m.cursor < 0 {
-					m.cursor = len(m.typeOptions) - 1
+					m.cursor = m.optionCount() - 1
 				}
 				return m, nil
 			} else if m.step == RunnerStepDockerImage && len(m.filteredImages) > 0 {
@@ -386,9 +396,9 @@ func (m AddRunnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 		case "down", "j":
-			if m.step == RunnerStepType {
+			if m.step == RunnerStepType || m.step == RunnerStepTarget || m.step == RunnerStepPlatform {
 				m.cursor++
-				if m.cursor >= len(m.typeOptions) {
+				if m.cursor >= m.optionCount() {
 					m.cursor = 0
 				}
 				return m, nil
@@ -432,6 +442,25 @@ func (m AddRunnerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// imageArchLabel marks image architectures that would run emulated on this host
+func imageArchLabel(arch string) string {
+	if docker.IsEmulated("linux/" + arch) {
+		return arch + ", emulated"
+	}
+	return arch
+}
+
+// optionCount returns the number of choices on the current list step
+func (m AddRunnerModel) optionCount() int {
+	switch m.step {
+	case RunnerStepTarget:
+		return len(m.targetOptions)
+	case RunnerStepPlatform:
+		return len(m.platformOptions)
+	}
+	return len(m.typeOptions)
+}
+
 func (m AddRunnerModel) handleEnter() (tea.Model, tea.Cmd) {
 	m.errorMsg = ""
 	value := strings.TrimSpace(m.textInput.Value())
@@ -453,10 +482,8 @@ func (m AddRunnerModel) handleEnter() (tea.Model, tea.Cmd) {
 	case RunnerStepType:
 		m.runnerType = m.typeOptions[m.cursor]
 		if m.runnerType == "docker" {
-			m.step = RunnerStepDockerImage
-			m.textInput.Reset()
-			m.textInput.Placeholder = "gcc:13"
-			m.textInput.Focus()
+			m.step = RunnerStepTarget
+			m.cursor = 0
 		} else if m.runnerType == "ssh" {
 			m.step = RunnerStepSSHHost
 			m.textInput.Reset()
@@ -464,6 +491,19 @@ func (m AddRunnerModel) handleEnter() (tea.Model, tea.Cmd) {
 			m.textInput.Focus()
 		}
 
+	case RunnerStepTarget:
+		if m.cursor > 0 {
+			// Presets bring their own image, compilers and platform
+			m.target = m.targetOptions[m.cursor]
+			m.step = RunnerStepDone
+			m.quitting = true
+			return m, tea.Quit
+		}
+		m.step = RunnerStepDockerImage
+		m.textInput.Reset()
+		m.textInput.Placeholder = "gcc:13"
+		m.textInput.Focus()
+
 	case RunnerStepDockerImage:
 		if len(m.filteredImages) > 0 && m.imageCursor < len(m.filteredImages) {
 			m.image = m.filteredImages[m.imageCursor].FullName()
@@ -477,6 +517,15 @@ func (m AddRunnerModel) handleEnter() (tea.Model, tea.Cmd) {
 		m.checkingStatus = "Checking build tools..."
 		return m, tea.Batch(m.spinner.Tick, checkImageToolsCmd(m.image))
 
+	case RunnerStepPlatform:
+		if m.cursor < len(m.platformOptions)-1 {
+			m.platform = m.platformOptions[m.cursor]
+		}
+		m.step = RunnerStepCompilerCC
+		m.textInput.Reset()
+		m.textInput.Placeholder = "(optional, e.g. gcc-13)"
+		m.textInput.Focus()
+
 	case RunnerStepCompilerCC:
 		m.cc = value // Can be empty
 		m.step = RunnerStepCompilerCXX
@@ -544,9 +593,15 @@ func (m AddRunnerModel) View() string {
 	if m.runnerType != "" {
 		s.WriteString("  " + successStyle.Render("✓") + " Runner type: " + m.runnerType + "\n")
 	}
+	if m.target != "" {
+		s.WriteString("  " + successStyle.Render("✓") + " Target: " + m.target + "\n")
+	}
 	if m.image != "" {
 		s.WriteString("  " + successStyle.Render("✓") + " Docker image: " + m.image + "\n")
 	}
+	if m.platform != "" {
+		s.WriteString("  " + successStyle.Render("✓") + " Platform: " + docker.DescribePlatform(m.platform) + "\n")
+	}
 	if m.host != "" {
 		s.WriteString("  " + successStyle.Render("✓") + " SSH host: " + m.host + "\n")
 	}
@@ -575,6 +630,20 @@ func (m AddRunnerModel) View() string {
 			s.WriteString("  " + cursor + opt + desc + "\n")
 		}
 
+	case RunnerStepTarget:
+		s.WriteString("\n  " + questionStyle.Render("? Target preset") + " " + dimStyle.Render("(cross-compilation image and toolchain)") + "\n")
+		for i, opt := range m.targetOptions {
+			cursor := "  "
+			if m.cursor == i {
+				cursor = selectedStyle.Render("❯ ")
+			}
+			desc := dimStyle.Render(" - use a Docker image")
+			if target, err := cross.Lookup(opt); err == nil {
+				desc = dimStyle.Render(" - " + target.Description)
+			}
+			s.WriteString("  " + cursor + opt + desc + "\n")
+		}
+
 	case RunnerStepDockerImage:
 		s.WriteString("\n  " + questionStyle.Render("? Docker image") + " " + dimStyle.Render("(type to filter, ↑↓ to select, Tab to complete)") + "\n")
 		s.WriteString("  " + m.textInput.View() + "\n")
@@ -590,7 +659,7 @@ func (m AddRunnerModel) View() string {
 				img := m.filteredImages[i]
 				display := img.FullName()
 				if img.Architecture != "" {
-					display += " " + dimStyle.Render("("+img.Architecture+")")
+					display += " " + dimStyle.Render("("+imageArchLabel(img.Architecture)+")")
 				}
 
 				cursor := "  "
@@ -598,7 +667,7 @@ func (m AddRunnerModel) View() string {
 					cursor = selectedStyle.Render("❯ ")
 					s.WriteString("  " + cursor + selectedStyle.Render(img.FullName()))
 					if img.Architecture != "" {
-						s.WriteString(" " + dimStyle.Render("("+img.Architecture+")"))
+						s.WriteString(" " + dimStyle.Render("("+imageArchLabel(img.Architecture)+")"))
 					}
 					s.WriteString("\n")
 				} else {
@@ -617,6 +686,24 @@ func (m AddRunnerModel) View() string {
 	case RunnerStepCheckingImage:
 		s.WriteString("\n  " + m.spinner.View() + " " + m.checkingStatus + "\n")
 
+	case RunnerStepPlatform:
+		s.WriteString("\n  " + questionStyle.Render("? Platform") + "\n")
+		for i, opt := range m.platformOptions {
+			cursor := "  "
+			if m.cursor == i {
+				cursor = selectedStyle.Render("❯ ")
+			}
+			desc := ""
+			if i == len(m.platformOptions)-1 {
+				desc = dimStyle.Render(" - use the image's architecture")
+			} else if docker.IsEmulated(opt) {
+				desc = dimStyle.Render(" - emulated via " + docker.Emulator() + ", slower")
+			} else {
+				desc = dimStyle.Render(" - native")
+			}
+			s.WriteString("  " + cursor + opt + desc + "\n")
+		}
+
 	case RunnerStepSSHHost:
 		s.WriteString("\n  " + questionStyle.Render("? SSH host") + "\n")
 		s.WriteString("  " + m.textInput.View() + "\n")
@@ -665,7 +752,9 @@ func (m AddRunnerModel) GetResult() *AddRunnerResult {
 	return &AddRunnerResult{
 		Name:           m.name,
 		Type:           m.runnerType,
+		Target:         m.target,
 		Image:          m.image,
+		Platform:       m.platform,
 		Host:           m.host,
 		User:           m.user,
 		CC:             m.cc,
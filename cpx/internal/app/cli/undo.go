@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+// addJournalPath is where cpx add records the file changes it made
+var addJournalPath = filepath.Join(".cpx", "add-journal.json")
+
+// journalFile is the state of a single file before and after a cpx add
+type journalFile struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed"`
+	Before  string `json:"before,omitempty"`
+	After   string `json:"after"`
+}
+
+// journalEntry records every file touched by one cpx add invocation
+type journalEntry struct {
+	Package string        `json:"package"`
+	Time    time.Time     `json:"time"`
+	Files   []journalFile `json:"files"`
+}
+
+// addJournal is the on-disk list of cpx add operations, oldest first
+type addJournal struct {
+	Entries []journalEntry `json:"entries"`
+}
+
+// fileSnapshot captures file contents so changes can be diffed after an add
+type fileSnapshot map[string]*string
+
+// UndoCmd creates the undo command
+func UndoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Revert the last 'cpx add'",
+		Long: `Revert the last dependency added with 'cpx add'.
+
+Restores the manifest (vcpkg.json, MODULE.bazel, subprojects/*.wrap) and any
+CMakeLists.txt edits to their state before the add, using the journal in .cpx/.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+			return undoLastAdd(force)
+		},
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().Bool("force", false, "Revert even if the files were modified after the add")
+
+	return cmd
+}
+
+// addTrackedFiles returns the files that cpx add may modify for a dependency
+func addTrackedFiles(name string) []string {
+	return []string{
+		"vcpkg.json",
+		"CMakeLists.txt",
+		filepath.Join("tests", "CMakeLists.txt"),
+		"MODULE.bazel",
+		filepath.Join("subprojects", name+".wrap"),
+	}
+}
+
+// takeSnapshot reads the current contents of the given files (nil when missing)
+func takeSnapshot(paths []string) fileSnapshot {
+	snap := make(fileSnapshot)
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			snap[p] = nil
+			continue
+		}
+		content := string(data)
+		snap[p] = &content
+	}
+	return snap
+}
+
+// recordAdd compares the before snapshot with the current file contents and
+// appends the differences to the journal. Nothing is recorded if no file changed.
+func recordAdd(name string, before fileSnapshot, paths []string) error {
+	after := takeSnapshot(paths)
+
+	entry := journalEntry{Package: name, Time: time.Now()}
+	for _, p := range paths {
+		b, a := before[p], after[p]
+		if a == nil {
+			continue // cpx add never deletes files
+		}
+		if b != nil && *b == *a {
+			continue
+		}
+		f := journalFile{Path: p, Existed: b != nil, After: *a}
+		if b != nil {
+			f.Before = *b
+		}
+		entry.Files = append(entry.Files, f)
+	}
+
+	if len(entry.Files) == 0 {
+		return nil
+	}
+
+	journal, err := loadAddJournal()
+	if err != nil {
+		return err
+	}
+	journal.Entries = append(journal.Entries, entry)
+	return saveAddJournal(journal)
+}
+
+func loadAddJournal() (*addJournal, error) {
+	data, err := os.ReadFile(addJournalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &addJournal{}, nil
+		}
+		return nil, fmt.Errorf("failed to read add journal: %w", err)
+	}
+
+	var journal addJournal
+	if err := json.Unmarshal(data, &journal); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", addJournalPath, err)
+	}
+	return &journal, nil
+}
+
+func saveAddJournal(journal *addJournal) error {
+	if err := os.MkdirAll(filepath.Dir(addJournalPath), 0755); err != nil {
+		return fmt.Errorf("failed to create .cpx directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode add journal: %w", err)
+	}
+	if err := os.WriteFile(addJournalPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write add journal: %w", err)
+	}
+	return nil
+}
+
+// undoLastAdd reverts the most recent journal entry.
+// Unless force is set, files edited after the add are left alone and an error is returned.
+func undoLastAdd(force bool) error {
+	journal, err := loadAddJournal()
+	if err != nil {
+		return err
+	}
+	if len(journal.Entries) == 0 {
+		return fmt.Errorf("nothing to undo (no 'cpx add' recorded in %s)", addJournalPath)
+	}
+
+	entry := journal.Entries[len(journal.Entries)-1]
+
+	if !force {
+		for _, f := range entry.Files {
+			data, err := os.ReadFile(f.Path)
+			if err != nil || string(data) != f.After {
+				return fmt.Errorf("%s was modified after 'cpx add %s'; refusing to undo\n  hint: use --force to restore it anyway", f.Path, entry.Package)
+			}
+		}
+	}
+
+	for _, f := range entry.Files {
+		if f.Existed {
+			if err := os.WriteFile(f.Path, []byte(f.Before), 0644); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", f.Path, err)
+			}
+			fmt.Printf("  %sRestored %s%s\n", colors.Gray, f.Path, colors.Reset)
+		} else {
+			if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", f.Path, err)
+			}
+			fmt.Printf("  %sRemoved %s%s\n", colors.Gray, f.Path, colors.Reset)
+		}
+	}
+
+	journal.Entries = journal.Entries[:len(journal.Entries)-1]
+	if err := saveAddJournal(journal); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s✓ Reverted 'cpx add %s'%s\n", colors.Green, entry.Package, colors.Reset)
+	return nil
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndUndoAdd(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(oldWd) }()
+	require.NoError(t, os.Chdir(tmpDir))
+
+	manifest := `{"name": "demo", "dependencies": []}`
+	cmakeLists := "project(demo)\nadd_executable(demo main.cpp)\n"
+	require.NoError(t, os.WriteFile("vcpkg.json", []byte(manifest), 0644))
+	require.NoError(t, os.WriteFile("CMakeLists.txt", []byte(cmakeLists), 0644))
+
+	tracked := addTrackedFiles("fmt")
+	before := takeSnapshot(tracked)
+
+	// Simulate what cpx add fmt does
+	require.NoError(t, os.WriteFile("vcpkg.json", []byte(`{"name": "demo", "dependencies": ["fmt"]}`), 0644))
+	require.NoError(t, os.WriteFile("CMakeLists.txt", []byte(cmakeLists+"target_link_libraries(demo PRIVATE fmt::fmt)\n"), 0644))
+	require.NoError(t, recordAdd("fmt", before, tracked))
+
+	journal, err := loadAddJournal()
+	require.NoError(t, err)
+	require.Len(t, journal.Entries, 1)
+	assert.Equal(t, "fmt", journal.Entries[0].Package)
+	assert.Len(t, journal.Entries[0].Files, 2)
+
+	require.NoError(t, undoLastAdd(false))
+
+	data, err := os.ReadFile("vcpkg.json")
+	require.NoError(t, err)
+	assert.Equal(t, manifest, string(data))
+	data, err = os.ReadFile("CMakeLists.txt")
+	require.NoError(t, err)
+	assert.Equal(t, cmakeLists, string(data))
+
+	// Journal is now empty
+	assert.Error(t, undoLastAdd(false))
+}
+
+func TestUndoAddRefusesModifiedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(oldWd) }()
+	require.NoError(t, os.Chdir(tmpDir))
+
+	tracked := addTrackedFiles("zlib")
+	before := takeSnapshot(tracked)
+	require.NoError(t, os.MkdirAll("subprojects", 0755))
+	require.NoError(t, os.WriteFile("subprojects/zlib.wrap", []byte("[wrap-file]\n"), 0644))
+	require.NoError(t, recordAdd("zlib", before, tracked))
+
+	// Edit the file after the add
+	require.NoError(t, os.WriteFile("subprojects/zlib.wrap", []byte("[wrap-git]\n"), 0644))
+	assert.Error(t, undoLastAdd(false))
+
+	// --force removes the newly created wrap anyway
+	require.NoError(t, undoLastAdd(true))
+	_, err = os.Stat("subprojects/zlib.wrap")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -160,8 +160,8 @@ func Upgrade(_ []string) {
 
 // runUpgradeVcpkg updates vcpkg by running git pull in its directory
 func runUpgradeVcpkg(_ *cobra.Command, _ []string) error {
-	// Load global config to get vcpkg root
-	cfg, err := config.LoadGlobal()
+	// Load the configuration to get vcpkg root
+	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
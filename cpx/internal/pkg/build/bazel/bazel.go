@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/coverage"
 	"github.com/ozacod/cpx/internal/pkg/templates"
 	"github.com/ozacod/cpx/internal/pkg/utils/colors"
 )
@@ -244,6 +245,12 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 		}
 	}
 
+	// Add hardening flags
+	if opts.Hardening {
+		bazelArgs = append(bazelArgs, hardeningArgs(opts.Optimized())...)
+		optLabel += "+hardened"
+	}
+
 	// Add target or default to //...
 	if opts.Target != "" {
 		bazelArgs = append(bazelArgs, opts.Target)
@@ -275,6 +282,9 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 	} else if opts.Release {
 		outDirName = "release"
 	}
+	if opts.Hardening {
+		outDirName += "-hardened"
+	}
 	outputDir := filepath.Join(".bin", "native", outDirName)
 
 	// Copy artifacts to build/<config>/ directory
@@ -327,14 +337,40 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 
 	fmt.Printf("%s✓ Build successful%s\n", colors.Green, colors.Reset)
 	fmt.Printf("  Artifacts in: %s/\n", outputDir)
+	if opts.Hardening {
+		if err := build.PrintHardeningAudit(outputDir); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// hardeningArgs returns the --copt/--linkopt flags for hardened builds
+func hardeningArgs(optimized bool) []string {
+	compile, link := build.HardeningFlags(build.UsesMSVCFlags(), optimized)
+	var args []string
+	for _, f := range compile {
+		args = append(args, "--copt="+f)
+	}
+	for _, f := range link {
+		args = append(args, "--linkopt="+f)
+	}
+	return args
+}
+
 // Test runs the project's tests with the given options.
 func (b *Builder) Test(ctx context.Context, opts build.TestOptions) error {
 	fmt.Printf("%sRunning Bazel tests...%s\n", colors.Cyan, colors.Reset)
 
 	bazelArgs := []string{"test"}
+	if opts.Coverage != "" {
+		// One lcov report of the workspace's code, without external
+		// repositories
+		bazelArgs = []string{"coverage", "--combined_report=lcov", "--instrumentation_filter=^//"}
+		if opts.Coverage == build.CoverageClang {
+			bazelArgs = append(bazelArgs, "--experimental_generate_llvm_lcov")
+		}
+	}
 
 	// Add filter if provided (bazel target pattern)
 	if opts.Filter != "" {
@@ -355,6 +391,9 @@ func (b *Builder) Test(ctx context.Context, opts build.TestOptions) error {
 	testCmd := execCommand("bazel", bazelArgs...)
 	testCmd.Stdout = os.Stdout
 	testCmd.Stderr = os.Stderr
+	if env := coverage.BazelEnv(opts.Coverage); env != nil {
+		testCmd.Env = append(testCmd.Environ(), env...)
+	}
 
 	if err := testCmd.Run(); err != nil {
 		return fmt.Errorf("bazel test failed: %w", err)
@@ -442,7 +481,7 @@ func (b *Builder) Run(ctx context.Context, opts build.RunOptions) error {
 	runCmd.Stderr = os.Stderr
 	runCmd.Stdin = os.Stdin
 
-	return runCmd.Run()
+	return build.ProgramExit(runCmd.Run())
 }
 
 // findBazelMainTarget tries to find a cc_binary target in BUILD.bazel
@@ -654,6 +693,46 @@ func (b *Builder) AddDependency(ctx context.Context, name string, version string
 	return nil
 }
 
+// AddDevDependency adds a bazel_dep with dev_dependency = True so it is only
+// visible to this module (tests, tooling) and not to downstream consumers.
+func (b *Builder) AddDevDependency(ctx context.Context, name string, version string) error {
+	if version == "" {
+		if err := b.ensureBCRPath(); err != nil {
+			return err
+		}
+
+		latestVersion, err := b.getLatestVersion(name)
+		if err != nil {
+			return fmt.Errorf("module '%s' not found in BCR: %w", name, err)
+		}
+		version = latestVersion
+	}
+
+	modulePath := "MODULE.bazel"
+	content, err := os.ReadFile(modulePath)
+	if err != nil {
+		return fmt.Errorf("failed to read MODULE.bazel: %w", err)
+	}
+
+	depPattern := regexp.MustCompile(fmt.Sprintf(`bazel_dep\s*\(\s*name\s*=\s*"%s"`, regexp.QuoteMeta(name)))
+	if depPattern.Match(content) {
+		return fmt.Errorf("%s is already declared in MODULE.bazel", name)
+	}
+
+	newDep := fmt.Sprintf("\nbazel_dep(name = \"%s\", version = \"%s\", dev_dependency = True)\n", name, version)
+	content = append(content, []byte(newDep)...)
+	if err := os.WriteFile(modulePath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write MODULE.bazel: %w", err)
+	}
+
+	fmt.Printf("%s✓ Added %s@%s to MODULE.bazel (dev dependency)%s\n", colors.Green, name, version, colors.Reset)
+	fmt.Printf("\n%sUSAGE INFO FOR %s:%s\n", colors.Cyan, name, colors.Reset)
+	fmt.Printf("Add this to your test targets in BUILD.bazel:\n\n")
+	fmt.Printf("  deps = [\"@%s//:<target>\"]\n\n", name)
+
+	return nil
+}
+
 // RemoveDependency removes a dependency from the project.
 func (b *Builder) RemoveDependency(ctx context.Context, name string) error {
 	modulePath := "MODULE.bazel"
@@ -901,3 +980,6 @@ func (b *Builder) GenerateBuildBench(ctx context.Context, projectPath string, co
 }
 
 var _ build.BuildSystem = (*Builder)(nil)
+
+// Compile-time check that Builder implements DevDependencyAdder.
+var _ build.DevDependencyAdder = (*Builder)(nil)
@@ -249,6 +249,34 @@ func TestTest(t *testing.T) {
 	assert.Contains(t, capturedArgs[0], "//:main_test")
 }
 
+func TestTestCoverage(t *testing.T) {
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+
+	var capturedArgs [][]string
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		capturedArgs = append(capturedArgs, append([]string{name}, arg...))
+		cs := append([]string{"-test.run=TestHelperProcess", "--", name}, arg...)
+		cmd := exec.Command(os.Args[0], cs...)
+		cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
+		return cmd
+	}
+
+	builder := New()
+	err := builder.Test(context.Background(), build.TestOptions{Coverage: build.CoverageGCC})
+	assert.NoError(t, err)
+
+	require.Len(t, capturedArgs, 1)
+	assert.Equal(t, []string{"bazel", "coverage", "--combined_report=lcov", "--instrumentation_filter=^//", "//..."}, capturedArgs[0][:5])
+	assert.NotContains(t, capturedArgs[0], "--experimental_generate_llvm_lcov")
+
+	capturedArgs = nil
+	err = builder.Test(context.Background(), build.TestOptions{Coverage: build.CoverageClang})
+	assert.NoError(t, err)
+	require.Len(t, capturedArgs, 1)
+	assert.Contains(t, capturedArgs[0], "--experimental_generate_llvm_lcov")
+}
+
 func TestBench(t *testing.T) {
 	// Mock execCommand
 	oldExecCommand := execCommand
@@ -525,3 +553,24 @@ func TestListTargets(t *testing.T) {
 	assert.Contains(t, targets, "//src:main (cc_binary)")
 	assert.Contains(t, targets, "//src:mylib (cc_library)")
 }
+
+func TestDockerBuildEnv(t *testing.T) {
+	t.Setenv("API_TOKEN", "s3cr3t-token")
+	t.Setenv("CONAN_LOGIN", "builder")
+	root := t.TempDir()
+	t.Chdir(root)
+	var out bytes.Buffer
+	opts := build.DockerBuildOptions{ImageName: "img", ProjectRoot: root, OutputDir: ".bin/ci", CacheDir: ".cache/ci",
+		TargetName: "linux", PassEnv: []string{"CONAN_LOGIN", "API_TOKEN"}, Secrets: []string{"API_TOKEN"}, DryRun: true, Output: &out}
+	require.NoError(t, New().RunDockerBuild(context.Background(), opts))
+
+	// env_passthrough and secrets reach the container by name, never by value
+	assert.Contains(t, out.String(), "-e CONAN_LOGIN \\\n    -e API_TOKEN \\\n")
+	assert.NotContains(t, out.String(), "s3cr3t-token")
+
+	opts.CCache = true
+	assert.ErrorContains(t, New().RunDockerBuild(context.Background(), opts), "not supported for Bazel builds")
+	opts.CCache = false
+	opts.CrossTarget = "windows-amd64"
+	assert.ErrorContains(t, New().RunDockerBuild(context.Background(), opts), "target preset 'windows-amd64'")
+}
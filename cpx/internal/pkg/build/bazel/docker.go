@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 
 	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
@@ -14,6 +13,14 @@ import (
 
 // RunDockerBuild implements the DockerBuilder interface for Bazel builds.
 func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOptions) error {
+	if opts.CrossTarget != "" && !opts.Shell {
+		return fmt.Errorf("target preset '%s' configures CMake and vcpkg and is not supported for Bazel builds; use a runner with a Bazel cross toolchain image instead", opts.CrossTarget)
+	}
+	// Bazel's own action cache lives in the persistent output base
+	if (opts.CCache || opts.SCCache != nil) && !opts.Shell {
+		return fmt.Errorf("ccache and sccache are not supported for Bazel builds, which cache actions in their persistent output base; remove 'ccache' or the 'cache' section from cpx-ci.yaml")
+	}
+
 	absProjectRoot, err := filepath.Abs(opts.ProjectRoot)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path for project root: %w", err)
@@ -29,19 +36,15 @@ func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOpti
 	}
 
 	// Create bazel cache directory
-	bazelCacheDir := filepath.Join(absProjectRoot, ".cache", "ci", opts.TargetName)
+	bazelCacheDir := filepath.Join(opts.CacheRoot(), opts.TargetName)
 	if err := os.MkdirAll(bazelCacheDir, 0755); err != nil {
 		return fmt.Errorf("failed to create bazel cache directory: %w", err)
 	}
 
-	// Determine build config
-	bazelConfig := "release"
-	if opts.BuildType == "Debug" || opts.BuildType == "debug" {
-		bazelConfig = "debug"
-	}
+	bazelConfig := dockerBazelConfig(opts)
 
 	// Create bazel repository cache directory
-	bazelRepoCacheDir := filepath.Join(absProjectRoot, ".cache", "ci", "bazel_repo_cache")
+	bazelRepoCacheDir := filepath.Join(opts.CacheRoot(), "bazel_repo_cache")
 	if err := os.MkdirAll(bazelRepoCacheDir, 0755); err != nil {
 		return fmt.Errorf("failed to create bazel repo cache directory: %w", err)
 	}
@@ -57,25 +60,34 @@ func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOpti
 
 	testSection := ""
 	if opts.RunTests {
+		// Each test target's test.xml is collected as <package>/<target>.xml
+		collect := fmt.Sprintf(`find "$BAZEL_OUTPUT_BASE/execroot" -path "*/testlogs/*" -name test.xml 2>/dev/null | while read -r f; do
+    name=${f#*/testlogs/}
+    name=${name%%/test.xml}
+    mkdir -p "/output/%[1]s/%[2]s/$(dirname "$name")"
+    cp "$f" "/output/%[1]s/%[2]s/$name.xml"
+done`, opts.TargetName, build.TestResultsDir)
 		testSection = `
+cpx_phase test
 echo "  Running tests..."
-bazel --output_base="$BAZEL_OUTPUT_BASE" test --config=debug --symlink_prefix=/dev/null --spawn_strategy=local --repository_cache=/bazel-repo-cache --test_output=errors //...
-`
+` + build.ResultsScript(opts.TargetName, build.TestResultsDir) + build.CollectTestsScript(
+			`bazel --output_base="$BAZEL_OUTPUT_BASE" test --config=debug --symlink_prefix=/dev/null --spawn_strategy=local --repository_cache=/bazel-repo-cache --test_output=errors //...`,
+			collect)
 	}
 
 	benchSection := ""
 	if opts.RunBenchmarks {
 		benchSection = `
+cpx_phase bench
 echo "  Running benchmarks..."
-bazel --output_base="$BAZEL_OUTPUT_BASE" run --config=release --symlink_prefix=/dev/null --spawn_strategy=local --repository_cache=/bazel-repo-cache //bench/...
+` + build.ResultsScript(opts.TargetName, build.BenchResultsDir) + build.BenchOutEnv(opts.TargetName, "bench") + ` bazel --output_base="$BAZEL_OUTPUT_BASE" run --config=release --symlink_prefix=/dev/null --spawn_strategy=local --repository_cache=/bazel-repo-cache //bench/...
 `
 	}
 
+	buildFlags := dockerHardeningFlags(opts) + dockerJobsFlag(opts)
+
 	// Handle verbosity
-	bazelQuiet := ""
-	if !opts.Verbose {
-		bazelQuiet = " > /dev/null 2>&1"
-	}
+	bazelQuiet := opts.QuietRedirect()
 
 	buildEcho := "echo \"  Building with Bazel...\""
 	copyEcho := "echo \"  Copying artifacts...\""
@@ -117,11 +129,13 @@ cd - > /dev/null
 
 	buildScript := fmt.Sprintf(`#!/bin/bash
 set -e
-%[1]s%[2]s
-export HOME=/root
+%[12]s%[1]s%[2]s
+export HOME=%[13]s
 BAZEL_OUTPUT_BASE=/bazel-cache
 mkdir -p "$BAZEL_OUTPUT_BASE"
-bazel --output_base="$BAZEL_OUTPUT_BASE" build --config=%[3]s --symlink_prefix=/dev/null --spawn_strategy=local --repository_cache=/bazel-repo-cache //...%[4]s
+cpx_phase build
+bazel --output_base="$BAZEL_OUTPUT_BASE" build --config=%[3]s --symlink_prefix=/dev/null --spawn_strategy=local --repository_cache=/bazel-repo-cache%[11]s //...%[4]s
+cpx_phase copy
 %[5]s
 mkdir -p /output/%[6]s
 find "$BAZEL_OUTPUT_BASE" -path "*/bin/*" -type f -executable \
@@ -134,35 +148,141 @@ find "$BAZEL_OUTPUT_BASE" -path "*/bin/*" -type f \( -name "lib*.a" -o -name "li
     ! -name "*.pic.a" \
     -exec cp {} /output/%[6]s/ \; 2>/dev/null || true
 %[10]s
-%[7]s%[8]s%[9]s
-`, envExports, buildEcho, bazelConfig, bazelQuiet, copyEcho, opts.TargetName, testSection, benchSection, runSection, buildCompleteEcho)
+%[7]s%[8]scpx_phase end
+%[9]s
+`, envExports, buildEcho, bazelConfig, bazelQuiet, copyEcho, opts.TargetName, testSection, benchSection, runSection, buildCompleteEcho, buildFlags, build.PhaseScript("/bazel-cache"), opts.Home())
 
-	fmt.Printf("  %s Running Bazel build in Docker container...%s\n", colors.Cyan, colors.Reset)
+	if opts.Shell {
+		buildScript = build.ShellScript(envExports+"export HOME="+opts.Home()+"\n", b.DescribeDockerBuild(opts), opts.ShellCommand)
+	}
 
-	dockerArgs := []string{"run", "--rm"}
+	dockerArgs := append([]string{"run", "--rm"}, opts.RunArgs()...)
+	dockerArgs = append(dockerArgs, opts.ShellArgs()...)
 	if opts.Platform != "" {
 		dockerArgs = append(dockerArgs, "--platform", opts.Platform)
 	}
 
 	dockerArgs = append(dockerArgs,
-		"-v", absProjectRoot+":/workspace:ro",
-		"-v", absOutputDir+":/output",
-		"-v", bazelCacheDir+":/bazel-cache",
-		"-v", bazelRepoCacheDir+":/bazel-repo-cache",
-		"-w", "/workspace",
-		opts.ImageName,
-		"bash", "-c", buildScript)
-
-	cmd := exec.Command("docker", dockerArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+		"-v", opts.Endpoint.Mount(absProjectRoot, "/workspace", true),
+		"-v", opts.Endpoint.Mount(absOutputDir, "/output", false),
+		"-v", opts.Endpoint.Mount(bazelCacheDir, "/bazel-cache", false),
+		"-v", opts.Endpoint.Mount(bazelRepoCacheDir, "/bazel-repo-cache", false),
+		"-w", "/workspace")
+	dockerArgs = append(dockerArgs, opts.EnvArgs()...)
+	dockerArgs = append(dockerArgs, opts.ImageName, "bash", "-c", buildScript)
+	if opts.DryRun {
+		build.FprintDockerCommand(opts.Stdout(), opts.Endpoint, dockerArgs)
+		return nil
+	}
 
-	if err := cmd.Run(); err != nil {
+	if opts.Shell {
+		return build.RunShell(opts, dockerArgs)
+	}
+
+	fmt.Fprintf(opts.Stdout(), "  %s Running Bazel build in Docker container...%s\n", colors.Cyan, colors.Reset)
+	cmd := opts.Endpoint.Command(dockerArgs...)
+	cmd.Stdout, cmd.Stderr = opts.Streams()
+
+	if err := build.RunContainer(ctx, opts, cmd); err != nil {
 		return fmt.Errorf("docker bazel build failed: %w", err)
 	}
 
+	// Remote builds are audited once their artifacts are downloaded
+	if opts.Hardening && !opts.Endpoint.Remote {
+		if err := build.FprintHardeningAudit(opts.Stdout(), filepath.Join(absOutputDir, opts.TargetName)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PrefetchDockerDependencies fetches all external repositories into the
+// target's Bazel output base and the shared repository cache.
+func (b *Builder) PrefetchDockerDependencies(ctx context.Context, opts build.DockerBuildOptions) error {
+	absProjectRoot, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+	bazelCacheDir := filepath.Join(opts.CacheRoot(), opts.TargetName)
+	bazelRepoCacheDir := filepath.Join(opts.CacheRoot(), "bazel_repo_cache")
+	for _, dir := range []string{bazelCacheDir, bazelRepoCacheDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create bazel cache directory: %w", err)
+		}
+	}
+
+	script := `set -e
+export HOME=` + opts.Home() + `
+bazel --output_base=/bazel-cache fetch --repository_cache=/bazel-repo-cache //...
+`
+	dockerArgs := append([]string{"run", "--rm"}, opts.RunArgs()...)
+	if opts.Platform != "" {
+		dockerArgs = append(dockerArgs, "--platform", opts.Platform)
+	}
+	dockerArgs = append(dockerArgs,
+		"-v", opts.Endpoint.Mount(absProjectRoot, "/workspace", true),
+		"-v", opts.Endpoint.Mount(bazelCacheDir, "/bazel-cache", false),
+		"-v", opts.Endpoint.Mount(bazelRepoCacheDir, "/bazel-repo-cache", false),
+		"-w", "/workspace")
+	dockerArgs = append(dockerArgs, opts.EnvArgs()...)
+	dockerArgs = append(dockerArgs, opts.ImageName, "bash", "-c", script)
+
+	cmd := opts.Endpoint.Command(dockerArgs...)
+	if opts.Verbose {
+		cmd.Stdout = opts.Stdout()
+	}
+	cmd.Stderr = opts.Stderr()
+	defer build.FlushOutput(cmd.Stdout, cmd.Stderr)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("bazel fetch failed: %w", err)
+	}
 	return nil
 }
 
-// Compile-time check that Builder implements DockerBuilder
-var _ build.DockerBuilder = (*Builder)(nil)
+// DescribeDockerBuild returns the Bazel command line RunDockerBuild executes
+// inside the container.
+func (b *Builder) DescribeDockerBuild(opts build.DockerBuildOptions) []string {
+	return []string{fmt.Sprintf("bazel --output_base=/bazel-cache build --config=%s --symlink_prefix=/dev/null --spawn_strategy=local --repository_cache=/bazel-repo-cache%s //...",
+		dockerBazelConfig(opts), dockerHardeningFlags(opts)+dockerJobsFlag(opts))}
+}
+
+// dockerBazelConfig maps the build type to a --config name
+func dockerBazelConfig(opts build.DockerBuildOptions) string {
+	if opts.BuildType == "Debug" || opts.BuildType == "debug" {
+		return "debug"
+	}
+	return "release"
+}
+
+// dockerHardeningFlags returns the --copt/--linkopt flags for hardened builds
+// (containers always build with GCC/Clang)
+func dockerHardeningFlags(opts build.DockerBuildOptions) string {
+	if !opts.Hardening {
+		return ""
+	}
+	var flags string
+	compile, link := build.HardeningFlags(false, dockerBazelConfig(opts) == "release")
+	for _, f := range compile {
+		flags += " --copt=" + f
+	}
+	for _, f := range link {
+		flags += " --linkopt=" + f
+	}
+	return flags
+}
+
+// Compile-time checks that Builder implements DockerBuilder and DockerBuildDescriber
+var (
+	_ build.DockerBuilder        = (*Builder)(nil)
+	_ build.DockerBuildDescriber = (*Builder)(nil)
+	_ build.DockerPrefetcher     = (*Builder)(nil)
+)
+
+// dockerJobsFlag returns the --jobs flag limiting parallel actions, if any
+func dockerJobsFlag(opts build.DockerBuildOptions) string {
+	if opts.Jobs <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" --jobs=%d", opts.Jobs)
+}
@@ -1,8 +1,12 @@
 package cmake
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 )
 
 func GetProjectNameFromCMakeLists() string {
@@ -21,3 +25,184 @@ func GetProjectNameFromCMakeLists() string {
 
 	return ""
 }
+
+// UnsetFlagArgs are the configure arguments removing the C and linker flags from
+// the cache of a reused build directory, so flags an earlier configure set (e.g.
+// for hardening or coverage) are dropped and CMake derives them from CFLAGS and
+// LDFLAGS again. Passing empty values instead would ignore those variables.
+var UnsetFlagArgs = []string{"-UCMAKE_C_FLAGS", "-UCMAKE_EXE_LINKER_FLAGS", "-UCMAKE_SHARED_LINKER_FLAGS"}
+
+// Target represents a target declared with add_executable or add_library
+type Target struct {
+	Name string
+	Kind string // "executable" or "library"
+}
+
+var (
+	projectRe = regexp.MustCompile(`(?m)^\s*project\s*\(\s*([^\s\)]+)`)
+	targetRe  = regexp.MustCompile(`(?m)^\s*add_(executable|library)\s*\(\s*([^\s\)]+)([^\)]*)`)
+)
+
+// ParseTargets returns the targets declared in CMakeLists.txt content.
+// ${PROJECT_NAME} is resolved from the project() call; ALIAS, IMPORTED and
+// INTERFACE targets are skipped since they cannot receive PRIVATE link entries.
+func ParseTargets(content string) []Target {
+	projectName := ""
+	if m := projectRe.FindStringSubmatch(content); len(m) > 1 {
+		projectName = m[1]
+	}
+
+	var targets []Target
+	seen := make(map[string]bool)
+	for _, m := range targetRe.FindAllStringSubmatch(content, -1) {
+		name := m[2]
+		if projectName != "" {
+			name = strings.ReplaceAll(name, "${PROJECT_NAME}", projectName)
+		}
+		rest := strings.Fields(m[3])
+		skip := false
+		for _, arg := range rest {
+			if arg == "ALIAS" || arg == "IMPORTED" || arg == "INTERFACE" {
+				skip = true
+				break
+			}
+		}
+		if skip || seen[name] {
+			continue
+		}
+		seen[name] = true
+		targets = append(targets, Target{Name: name, Kind: m[1]})
+	}
+	return targets
+}
+
+var (
+	addTestRe      = regexp.MustCompile(`(?m)^\s*add_test\s*\(([^\)]*)\)`)
+	discoverTestRe = regexp.MustCompile(`(?m)^\s*(?:gtest_discover_tests|gtest_add_tests|catch_discover_tests|doctest_discover_tests)\s*\(([^\)]*)\)`)
+	targetFileRe   = regexp.MustCompile(`^\$<TARGET_FILE:([^>]+)>$`)
+)
+
+// ParseTestTargets returns the targets CMakeLists.txt content registers with
+// CTest: the commands of add_test() calls that name a target, and the targets
+// of the GoogleTest, Catch2 and doctest discovery functions.
+func ParseTestTargets(content string) []string {
+	projectName := ""
+	if m := projectRe.FindStringSubmatch(content); len(m) > 1 {
+		projectName = m[1]
+	}
+	var targets []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		name = strings.Trim(name, `"`)
+		if m := targetFileRe.FindStringSubmatch(name); m != nil {
+			name = m[1]
+		}
+		if projectName != "" {
+			name = strings.ReplaceAll(name, "${PROJECT_NAME}", projectName)
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			targets = append(targets, name)
+		}
+	}
+
+	for _, m := range addTestRe.FindAllStringSubmatch(content, -1) {
+		args := strings.Fields(m[1])
+		if len(args) > 1 && args[0] != "NAME" {
+			// add_test(<name> <command> ...)
+			add(args[1])
+			continue
+		}
+		for i, arg := range args {
+			if arg == "COMMAND" && i+1 < len(args) {
+				add(args[i+1])
+				break
+			}
+		}
+	}
+	for _, m := range discoverTestRe.FindAllStringSubmatch(content, -1) {
+		args := strings.Fields(m[1])
+		switch {
+		case len(args) > 1 && args[0] == "TARGET":
+			add(args[1])
+		case len(args) > 0:
+			add(args[0])
+		}
+	}
+	return targets
+}
+
+// ParseCTestTargets returns the executables of the tests in the output of
+// 'ctest --show-only=json-v1', by target name
+func ParseCTestTargets(data []byte) ([]string, error) {
+	var info struct {
+		Tests []struct {
+			Command []string `json:"command"`
+		} `json:"tests"`
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse ctest output: %w", err)
+	}
+	var targets []string
+	seen := make(map[string]bool)
+	for _, test := range info.Tests {
+		if len(test.Command) == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(test.Command[0]), ".exe")
+		if !seen[name] {
+			seen[name] = true
+			targets = append(targets, name)
+		}
+	}
+	return targets, nil
+}
+
+// AddLinkLibraries returns content with find_package calls inserted after
+// project() and a target_link_libraries entry appended for each target.
+// Calls that are already present are not duplicated.
+func AddLinkLibraries(content string, targets, findPackages, libraries []string) string {
+	var missingPackages []string
+	for _, fp := range findPackages {
+		if !strings.Contains(content, fp) {
+			missingPackages = append(missingPackages, fp)
+		}
+	}
+
+	if len(missingPackages) > 0 {
+		block := strings.Join(missingPackages, "\n") + "\n"
+		if loc := projectRe.FindStringIndex(content); loc != nil {
+			// Insert after the line containing project()
+			end := strings.Index(content[loc[1]:], "\n")
+			if end == -1 {
+				content += "\n\n" + block
+			} else {
+				pos := loc[1] + end + 1
+				content = content[:pos] + "\n" + block + content[pos:]
+			}
+		} else {
+			content = block + "\n" + content
+		}
+	}
+
+	if len(libraries) == 0 {
+		return content
+	}
+
+	var sb strings.Builder
+	for _, target := range targets {
+		line := fmt.Sprintf("target_link_libraries(%s PRIVATE %s)", target, strings.Join(libraries, " "))
+		if strings.Contains(content, line) {
+			continue
+		}
+		sb.WriteString(line + "\n")
+	}
+	if sb.Len() == 0 {
+		return content
+	}
+
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + "\n" + sb.String()
+}
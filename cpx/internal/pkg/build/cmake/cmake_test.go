@@ -0,0 +1,87 @@
+package cmake
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleCMakeLists = `cmake_minimum_required(VERSION 3.20)
+project(demo VERSION 0.1.0 LANGUAGES CXX)
+
+add_library(demo_core STATIC
+    src/core.cpp
+)
+add_library(demo::core ALIAS demo_core)
+add_library(demo_headers INTERFACE)
+
+add_executable(${PROJECT_NAME}
+    src/main.cpp
+)
+add_executable(demo_tool src/tool.cpp)
+`
+
+func TestParseTargets(t *testing.T) {
+	targets := ParseTargets(sampleCMakeLists)
+	assert.Equal(t, []Target{
+		{Name: "demo_core", Kind: "library"},
+		{Name: "demo", Kind: "executable"},
+		{Name: "demo_tool", Kind: "executable"},
+	}, targets)
+
+	assert.Empty(t, ParseTargets("project(empty)\n"))
+}
+
+func TestAddLinkLibraries(t *testing.T) {
+	findPackages := []string{"find_package(fmt CONFIG REQUIRED)"}
+	libraries := []string{"fmt::fmt"}
+
+	updated := AddLinkLibraries(sampleCMakeLists, []string{"demo", "demo_tool"}, findPackages, libraries)
+
+	// find_package goes right after project()
+	projectIdx := strings.Index(updated, "project(demo")
+	findIdx := strings.Index(updated, "find_package(fmt CONFIG REQUIRED)")
+	firstTargetIdx := strings.Index(updated, "add_library(demo_core")
+	assert.True(t, projectIdx < findIdx && findIdx < firstTargetIdx)
+
+	assert.Contains(t, updated, "target_link_libraries(demo PRIVATE fmt::fmt)\n")
+	assert.Contains(t, updated, "target_link_libraries(demo_tool PRIVATE fmt::fmt)\n")
+	assert.NotContains(t, updated, "target_link_libraries(demo_core")
+
+	// Applying the same change twice is a no-op
+	assert.Equal(t, updated, AddLinkLibraries(updated, []string{"demo", "demo_tool"}, findPackages, libraries))
+}
+
+func TestParseTestTargets(t *testing.T) {
+	content := `project(demo)
+enable_testing()
+add_executable(${PROJECT_NAME}_tests tests/main.cpp)
+add_executable(contest src/contest.cpp)
+add_executable(smoke tests/smoke.cpp)
+add_executable(legacy tests/legacy.cpp)
+add_executable(bench bench/main.cpp)
+gtest_discover_tests(${PROJECT_NAME}_tests)
+add_test(NAME smoke-run COMMAND $<TARGET_FILE:smoke> --quick)
+add_test(legacy-run legacy)
+gtest_add_tests(TARGET "unit" SOURCES tests/unit.cpp)
+`
+	// contest only looks like a test by its name
+	assert.Equal(t, []string{"smoke", "legacy", "demo_tests", "unit"}, ParseTestTargets(content))
+	assert.Empty(t, ParseTestTargets(sampleCMakeLists))
+}
+
+func TestParseCTestTargets(t *testing.T) {
+	data := []byte(`{"kind": "ctestInfo", "version": {"major": 1, "minor": 0}, "tests": [
+		{"name": "Math.Add", "command": ["/src/.cache/native/test/demo_tests", "--gtest_filter=Math.Add"]},
+		{"name": "Math.Sub", "command": ["/src/.cache/native/test/demo_tests", "--gtest_filter=Math.Sub"]},
+		{"name": "smoke", "command": ["C:/src/build/smoke.exe"]},
+		{"name": "disabled"}
+	]}`)
+	targets, err := ParseCTestTargets(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"demo_tests", "smoke"}, targets)
+
+	_, err = ParseCTestTargets([]byte("No tests were found!!!"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,194 @@
+// Package cross provides cross-compilation target presets for Docker runners.
+//
+// A preset bundles what building for another OS or architecture from a
+// Linux container needs: an image recipe, the cross compilers, the CMake
+// system settings, the vcpkg triplet and the file names of the artifacts.
+package cross
+
+import (
+	"embed"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//go:embed dockerfiles
+var dockerfiles embed.FS
+
+// Target is a cross-compilation preset.
+type Target struct {
+	// Name is the value of a runner's `target:` key, e.g. windows-amd64.
+	Name string
+
+	// Description is shown by cpx ci explain.
+	Description string
+
+	// Platform is the Docker platform of the build image, used unless the
+	// runner sets its own. Presets without cross compilers set it and run the
+	// native toolchain under emulation.
+	Platform string
+
+	// CC and CXX are the cross compilers, used unless the runner sets its own.
+	CC  string
+	CXX string
+
+	// Triplet is the vcpkg target triplet.
+	Triplet string
+
+	// CMakeArgs configure CMake for the target system.
+	CMakeArgs []string
+
+	// ConfigureWrapper runs the CMake configure step, e.g. emcmake.
+	ConfigureWrapper string
+
+	// Artifacts are file name patterns collected as artifacts in addition
+	// to Linux executables and libraries, e.g. "*.exe".
+	Artifacts []string
+
+	// Hardening reports whether the hardening flags and audit apply. They
+	// assume dynamically linked ELF binaries (-pie conflicts with -static).
+	Hardening bool
+
+	// VerifyStatic fails the build when an executable has dynamic
+	// dependencies, checked with ldd inside the container.
+	VerifyStatic bool
+
+	// dockerfile names the embedded Dockerfile.<dockerfile> of the build
+	// image, which related targets share (default: Name).
+	dockerfile string
+}
+
+var targets = []Target{
+	{
+		Name:        "windows-amd64",
+		Description: "Windows x86_64 (MinGW-w64)",
+		CC:          "x86_64-w64-mingw32-gcc",
+		CXX:         "x86_64-w64-mingw32-g++",
+		Triplet:     "x64-mingw-static",
+		CMakeArgs: []string{
+			"-DCMAKE_SYSTEM_NAME=Windows",
+			"-DCMAKE_SYSTEM_PROCESSOR=AMD64",
+			"-DCMAKE_RC_COMPILER=x86_64-w64-mingw32-windres",
+			"-DCMAKE_FIND_ROOT_PATH=/usr/x86_64-w64-mingw32",
+			// Link libgcc, libstdc++ and winpthread statically so the .exe runs without MinGW DLLs
+			"-DCMAKE_EXE_LINKER_FLAGS=-static",
+		},
+		Artifacts: []string{"*.exe", "*.dll"},
+	},
+	{
+		Name:        "darwin-arm64",
+		Description: "macOS arm64 (osxcross)",
+		CC:          "oa64-clang",
+		CXX:         "oa64-clang++",
+		Triplet:     "arm64-osx",
+		CMakeArgs:   []string{"-DVCPKG_CHAINLOAD_TOOLCHAIN_FILE=/opt/osxcross/toolchain-arm64.cmake"},
+		Artifacts:   []string{"*.dylib"},
+		dockerfile:  "darwin",
+	},
+	{
+		Name:        "darwin-amd64",
+		Description: "macOS x86_64 (osxcross)",
+		CC:          "o64-clang",
+		CXX:         "o64-clang++",
+		Triplet:     "x64-osx",
+		CMakeArgs:   []string{"-DVCPKG_CHAINLOAD_TOOLCHAIN_FILE=/opt/osxcross/toolchain-x86_64.cmake"},
+		Artifacts:   []string{"*.dylib"},
+		dockerfile:  "darwin",
+	},
+	{
+		Name:             "wasm32-emscripten",
+		Description:      "WebAssembly (Emscripten)",
+		CC:               "emcc",
+		CXX:              "em++",
+		Triplet:          "wasm32-emscripten",
+		CMakeArgs:        []string{"-DVCPKG_CHAINLOAD_TOOLCHAIN_FILE=/emsdk/upstream/emscripten/cmake/Modules/Platform/Emscripten.cmake"},
+		ConfigureWrapper: "emcmake",
+		Artifacts:        []string{"*.wasm", "*.js", "*.html", "*.data"},
+	},
+	{
+		Name:        "freebsd-amd64",
+		Description: "FreeBSD x86_64 (clang + FreeBSD sysroot)",
+		CC:          "x86_64-freebsd-clang",
+		CXX:         "x86_64-freebsd-clang++",
+		Triplet:     "x64-freebsd",
+		CMakeArgs:   []string{"-DVCPKG_CHAINLOAD_TOOLCHAIN_FILE=/opt/freebsd-toolchain.cmake"},
+		Hardening:   true,
+	},
+	{
+		Name:        "linux-amd64-musl-static",
+		Description: "Linux x86_64, fully static (musl)",
+		Platform:    "linux/amd64",
+		Triplet:     "x64-linux-musl",
+		CMakeArgs: []string{
+			"-DCMAKE_EXE_LINKER_FLAGS=-static",
+			"-DBUILD_SHARED_LIBS=OFF",
+		},
+		VerifyStatic: true,
+	},
+	{
+		Name:        "linux-riscv64",
+		Description: "Linux RISC-V 64 (emulated)",
+		Platform:    "linux/riscv64",
+		Triplet:     "riscv64-linux",
+		Hardening:   true,
+		dockerfile:  "linux-emulated",
+	},
+	{
+		Name:        "linux-s390x",
+		Description: "Linux IBM Z (emulated)",
+		Platform:    "linux/s390x",
+		Triplet:     "s390x-linux",
+		Hardening:   true,
+		dockerfile:  "linux-emulated",
+	},
+	{
+		Name:        "linux-ppc64le",
+		Description: "Linux POWER little-endian (emulated)",
+		Platform:    "linux/ppc64le",
+		Triplet:     "ppc64le-linux",
+		Hardening:   true,
+		dockerfile:  "linux-emulated",
+	},
+}
+
+// Lookup returns the preset with the given name.
+func Lookup(name string) (Target, error) {
+	for _, t := range targets {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Target{}, fmt.Errorf("unknown target '%s' (available: %s)", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the names of all presets.
+func Names() []string {
+	names := make([]string, 0, len(targets))
+	for _, t := range targets {
+		names = append(names, t.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Dockerfile returns the Dockerfile of the preset's build image.
+func (t Target) Dockerfile() string {
+	data, err := dockerfiles.ReadFile("dockerfiles/Dockerfile." + t.dockerfileName())
+	if err != nil {
+		// Every preset ships a Dockerfile; see TestTargetsHaveDockerfiles
+		panic(err)
+	}
+	return string(data)
+}
+
+// Image returns the repository the preset's build image is tagged into.
+func (t Target) Image() string {
+	return "cpx-" + t.dockerfileName()
+}
+
+func (t Target) dockerfileName() string {
+	if t.dockerfile != "" {
+		return t.dockerfile
+	}
+	return t.Name
+}
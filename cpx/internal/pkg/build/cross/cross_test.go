@@ -0,0 +1,84 @@
+package cross
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetsHaveDockerfiles(t *testing.T) {
+	for _, target := range targets {
+		t.Run(target.Name, func(t *testing.T) {
+			assert.Contains(t, target.Dockerfile(), "/opt/vcpkg")
+			// Cache mounts need the BuildKit Dockerfile frontend
+			assert.True(t, strings.HasPrefix(target.Dockerfile(), "# syntax=docker/dockerfile:1\n"))
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	target, err := Lookup("windows-amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "x64-mingw-static", target.Triplet)
+	assert.Contains(t, target.Artifacts, "*.exe")
+	assert.False(t, target.Hardening)
+
+	_, err = Lookup("plan9-386")
+	assert.ErrorContains(t, err, "windows-amd64")
+}
+
+func TestDarwinTargetsShareImage(t *testing.T) {
+	arm, err := Lookup("darwin-arm64")
+	require.NoError(t, err)
+	amd, err := Lookup("darwin-amd64")
+	require.NoError(t, err)
+
+	assert.Equal(t, arm.Image(), amd.Image())
+	assert.Equal(t, "arm64-osx", arm.Triplet)
+	assert.Equal(t, "x64-osx", amd.Triplet)
+	assert.Contains(t, arm.Dockerfile(), "MACOS_SDK_URL")
+}
+
+func TestWasmTarget(t *testing.T) {
+	target, err := Lookup("wasm32-emscripten")
+	require.NoError(t, err)
+	assert.Equal(t, "emcmake", target.ConfigureWrapper)
+	assert.Equal(t, "wasm32-emscripten", target.Triplet)
+	assert.Contains(t, target.Artifacts, "*.wasm")
+	assert.Contains(t, target.Artifacts, "*.js")
+}
+
+func TestEmulatedTargets(t *testing.T) {
+	for _, name := range []string{"linux-riscv64", "linux-s390x", "linux-ppc64le"} {
+		t.Run(name, func(t *testing.T) {
+			target, err := Lookup(name)
+			require.NoError(t, err)
+			assert.Equal(t, "linux/"+name[len("linux-"):], target.Platform)
+			assert.Equal(t, "cpx-linux-emulated", target.Image())
+			assert.True(t, target.Hardening)
+			assert.Empty(t, target.CXX)
+		})
+	}
+}
+
+func TestFreeBSDTarget(t *testing.T) {
+	target, err := Lookup("freebsd-amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "x64-freebsd", target.Triplet)
+	assert.Equal(t, "cpx-freebsd-amd64", target.Image())
+	assert.Contains(t, target.Dockerfile(), "FREEBSD_VERSION")
+	assert.True(t, target.Hardening)
+}
+
+func TestMuslStaticTarget(t *testing.T) {
+	target, err := Lookup("linux-amd64-musl-static")
+	require.NoError(t, err)
+	assert.Equal(t, "x64-linux-musl", target.Triplet)
+	assert.Equal(t, "linux/amd64", target.Platform)
+	assert.Contains(t, target.CMakeArgs, "-DCMAKE_EXE_LINKER_FLAGS=-static")
+	assert.True(t, target.VerifyStatic)
+	assert.False(t, target.Hardening)
+	assert.Contains(t, target.Dockerfile(), "x64-linux-musl.cmake")
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// QEMUImage registers QEMU binfmt_misc handlers in the engine's kernel.
+const QEMUImage = "multiarch/qemu-user-static"
+
+// SetupQEMUArgs returns the docker arguments that (re)register QEMU handlers
+// for all architectures. The handlers are persistent (-p yes), so they keep
+// working in containers with their own mount namespace.
+func SetupQEMUArgs() []string {
+	return []string{"run", "--rm", "--privileged", QEMUImage, "--reset", "-p", "yes"}
+}
+
+const binfmtDir = "/proc/sys/fs/binfmt_misc"
+
+// BinfmtRegistered reports whether the engine's kernel can run containers for
+// platform under QEMU. known is false when that cannot be told from this host:
+// engines in a VM (Docker Desktop, Colima, ...) or on another machine.
+func BinfmtRegistered(platform string) (registered, known bool) {
+	if runtime.GOOS != "linux" || os.Getenv("DOCKER_HOST") != "" {
+		return false, false
+	}
+	if rt := DetectRuntime(); rt.Host != "" || rt.Context != "" {
+		return false, false
+	}
+	return binfmtRegistered(binfmtDir, platform)
+}
+
+func binfmtRegistered(dir, platform string) (registered, known bool) {
+	if _, err := os.Stat(filepath.Join(dir, "status")); err != nil {
+		return false, false
+	}
+	_, err := os.Stat(filepath.Join(dir, "qemu-"+qemuArch(platform)))
+	return err == nil, true
+}
+
+// qemuArch returns QEMU's name for the architecture of platform
+func qemuArch(platform string) string {
+	switch arch := platformArch(platform); arch {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	default:
+		return arch
+	}
+}
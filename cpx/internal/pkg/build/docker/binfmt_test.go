@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinfmtRegistered(t *testing.T) {
+	dir := t.TempDir()
+
+	// binfmt_misc not mounted: unknown
+	_, known := binfmtRegistered(dir, "linux/riscv64")
+	assert.False(t, known)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "status"), []byte("enabled\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "qemu-aarch64"), []byte("enabled\n"), 0644))
+
+	registered, known := binfmtRegistered(dir, "linux/arm64")
+	assert.True(t, known)
+	assert.True(t, registered)
+
+	registered, known = binfmtRegistered(dir, "linux/riscv64")
+	assert.True(t, known)
+	assert.False(t, registered)
+}
+
+func TestQEMUArch(t *testing.T) {
+	assert.Equal(t, "x86_64", qemuArch("linux/amd64"))
+	assert.Equal(t, "aarch64", qemuArch("linux/arm64"))
+	assert.Equal(t, "arm", qemuArch("linux/arm/v7"))
+	assert.Equal(t, "s390x", qemuArch("linux/s390x"))
+	assert.Equal(t, "ppc64le", qemuArch("linux/ppc64le"))
+}
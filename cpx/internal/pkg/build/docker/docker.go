@@ -0,0 +1,80 @@
+// Package docker provides helpers for invoking the Docker CLI from build systems.
+package docker
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var execCommand = exec.Command
+
+// Command creates a docker CLI command.
+// On Windows, MSYS/Git Bash path conversion is disabled so container paths
+// such as /workspace are passed through untouched. When the engine was found
+// on a non-default socket, DOCKER_HOST is set accordingly.
+func Command(args ...string) *exec.Cmd {
+	name := "docker"
+	if usesWindowsCLI() {
+		name = "docker.exe"
+	}
+	cmd := execCommand(name, args...)
+
+	var env []string
+	if runtime.GOOS == "windows" {
+		env = append(env, "MSYS_NO_PATHCONV=1", "MSYS2_ARG_CONV_EXCL=*")
+	}
+	// Point the CLI at Colima/Rancher Desktop/Podman when the default socket is missing
+	if rt := DetectRuntime(); rt.Host != "" {
+		env = append(env, "DOCKER_HOST="+rt.Host)
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
+
+// HostPath converts an absolute host path into the form Docker expects on the
+// left-hand side of a bind mount.
+func HostPath(path string) string {
+	if usesWindowsCLI() {
+		return wslPath(path)
+	}
+	return hostPath(runtime.GOOS, path)
+}
+
+func hostPath(goos, path string) string {
+	if goos != "windows" {
+		return path
+	}
+
+	// MSYS/Git Bash style: /c/Users/me -> C:/Users/me
+	if len(path) >= 2 && path[0] == '/' && isDriveLetter(path[1]) && (len(path) == 2 || path[2] == '/') {
+		return strings.ToUpper(path[1:2]) + ":" + "/" + strings.TrimPrefix(path[2:], "/")
+	}
+
+	// Long path prefix: \\?\C:\Users\me -> C:\Users\me
+	path = strings.TrimPrefix(path, `\\?\`)
+
+	// Docker Desktop accepts drive paths and UNC paths with forward slashes
+	path = strings.ReplaceAll(path, `\`, "/")
+	if len(path) >= 2 && isDriveLetter(path[0]) && path[1] == ':' {
+		path = strings.ToUpper(path[:1]) + path[1:]
+	}
+	return path
+}
+
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Mount returns a -v argument value binding hostDir to containerDir.
+// containerDir is always a POSIX path inside the Linux container.
+func Mount(hostDir, containerDir string, readOnly bool) string {
+	mount := HostPath(hostDir) + ":" + containerDir
+	if readOnly {
+		mount += ":ro"
+	}
+	return mount
+}
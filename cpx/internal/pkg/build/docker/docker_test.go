@@ -0,0 +1,31 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		goos     string
+		path     string
+		expected string
+	}{
+		{"Linux unchanged", "linux", "/home/me/proj", "/home/me/proj"},
+		{"macOS unchanged", "darwin", "/Users/me/proj", "/Users/me/proj"},
+		{"Windows drive path", "windows", `C:\Users\me\proj`, "C:/Users/me/proj"},
+		{"Windows lowercase drive", "windows", `d:\src\proj`, "D:/src/proj"},
+		{"Windows long path prefix", "windows", `\\?\C:\Users\me\proj`, "C:/Users/me/proj"},
+		{"Windows UNC path", "windows", `\\server\share\proj`, "//server/share/proj"},
+		{"MSYS path", "windows", "/c/Users/me/proj", "C:/Users/me/proj"},
+		{"MSYS drive root", "windows", "/d", "D:/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, hostPath(tt.goos, tt.path))
+		})
+	}
+}
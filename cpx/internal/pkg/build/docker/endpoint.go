@@ -0,0 +1,200 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Endpoint is the Docker engine a build talks to. The zero value is the
+// engine the docker CLI uses by default (see DetectRuntime).
+type Endpoint struct {
+	// Host is a DOCKER_HOST value, e.g. ssh://ci@arm-box or tcp://10.0.0.5:2376.
+	Host string
+
+	// Context is a docker CLI context name.
+	Context string
+
+	// Remote reports whether the engine runs on another machine, where host
+	// paths cannot be bind mounted. Set by ResolveEndpoint.
+	Remote bool
+
+	// Scope keeps the volumes of builds on a remote engine apart, e.g. per
+	// toolchain, so builds running in parallel never share one.
+	Scope string
+}
+
+// ResolveEndpoint returns the endpoint for a DOCKER_HOST value or CLI
+// context and determines whether it is remote.
+func ResolveEndpoint(host, context string) (Endpoint, error) {
+	e := Endpoint{Host: host, Context: context}
+	if host != "" && context != "" {
+		return e, fmt.Errorf("set either a Docker host or a Docker context, not both")
+	}
+	if context != "" {
+		out, err := Command("context", "inspect", context, "--format", "{{.Endpoints.docker.Host}}").Output()
+		if err != nil {
+			return e, fmt.Errorf("docker context '%s' not found: %w", context, err)
+		}
+		host = strings.TrimSpace(string(out))
+	}
+	e.Remote = isRemoteHost(host)
+	return e, nil
+}
+
+// isRemoteHost reports whether a DOCKER_HOST value points at another machine
+func isRemoteHost(host string) bool {
+	if strings.HasPrefix(host, "ssh://") {
+		return true
+	}
+	for _, scheme := range []string{"tcp://", "http://", "https://"} {
+		if rest, ok := strings.CutPrefix(host, scheme); ok {
+			addr := strings.Split(rest, "/")[0]
+			if i := strings.LastIndex(addr, ":"); i >= 0 {
+				addr = addr[:i]
+			}
+			addr = strings.Trim(addr, "[]")
+			return addr != "localhost" && addr != "127.0.0.1" && addr != "::1"
+		}
+	}
+	return false
+}
+
+// String describes the endpoint for messages, or "" for the default engine.
+func (e Endpoint) String() string {
+	switch {
+	case e.Context != "":
+		return "context " + e.Context
+	case e.Host != "":
+		return e.Host
+	}
+	return ""
+}
+
+// Command creates a docker CLI command against this endpoint.
+func (e Endpoint) Command(args ...string) *exec.Cmd {
+	if e.Context != "" {
+		args = append([]string{"--context", e.Context}, args...)
+	}
+	cmd := Command(args...)
+	if e.Host == "" && e.Context == "" {
+		return cmd
+	}
+
+	// An explicit endpoint replaces the detected one
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	kept := env[:0:0]
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "DOCKER_HOST=") && !strings.HasPrefix(kv, "DOCKER_CONTEXT=") {
+			kept = append(kept, kv)
+		}
+	}
+	if e.Host != "" {
+		kept = append(kept, "DOCKER_HOST="+e.Host)
+	}
+	cmd.Env = kept
+	return cmd
+}
+
+// Mount returns a -v argument value for hostDir. Local engines bind mount the
+// directory, as their VM sees it (see Runtime.VMPath); remote engines use a
+// named volume standing in for it, which Upload and Download keep in sync
+// with the host.
+func (e Endpoint) Mount(hostDir, containerDir string, readOnly bool) string {
+	if !e.Remote {
+		return Mount(DetectRuntime().VMPath(hostDir), containerDir, readOnly)
+	}
+	mount := e.Volume(hostDir) + ":" + containerDir
+	if readOnly {
+		mount += ":ro"
+	}
+	return mount
+}
+
+// Scoped returns the endpoint with its volumes kept apart under scope.
+func (e Endpoint) Scoped(scope string) Endpoint {
+	e.Scope = scope
+	return e
+}
+
+// Volume returns the named volume that stands in for hostDir on a remote
+// engine, one per Scope.
+func (e Endpoint) Volume(hostDir string) string {
+	key := hostDir
+	if e.Scope != "" {
+		key = e.Scope + "\x00" + hostDir
+	}
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("cpx-%x", sum[:6])
+}
+
+// Upload replaces the contents of hostDir's volume with hostDir, streamed as
+// a tar archive through a throwaway container of image.
+func (e Endpoint) Upload(hostDir, image string, excludes ...string) error {
+	tarArgs := []string{"-C", hostDir}
+	for _, ex := range excludes {
+		tarArgs = append(tarArgs, "--exclude", ex)
+	}
+	tarArgs = append(tarArgs, "-cf", "-", ".")
+	pack := exec.Command("tar", tarArgs...)
+
+	unpack := e.Command("run", "--rm", "-i", "-v", e.Volume(hostDir)+":/data", "--entrypoint", "sh", image,
+		"-c", "find /data -mindepth 1 -delete && tar -xf - -C /data")
+	return pipe(pack, unpack)
+}
+
+// Download copies the contents of hostDir's volume back into hostDir.
+func (e Endpoint) Download(hostDir, image string) error {
+	if err := os.MkdirAll(hostDir, 0755); err != nil {
+		return err
+	}
+	pack := e.Command("run", "--rm", "-v", e.Volume(hostDir)+":/data", "--entrypoint", "tar", image, "-cf", "-", "-C", "/data", ".")
+	unpack := exec.Command("tar", "-xf", "-", "-C", hostDir)
+	return pipe(pack, unpack)
+}
+
+// pipe runs from | to
+func pipe(from, to *exec.Cmd) error {
+	r, err := from.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	to.Stdin = r
+	from.Stderr = os.Stderr
+	to.Stderr = os.Stderr
+	if err := from.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", from.Path, err)
+	}
+	runErr := to.Run()
+	waitErr := from.Wait()
+	if runErr != nil {
+		return fmt.Errorf("%s failed: %w", to.Path, runErr)
+	}
+	if waitErr != nil {
+		return fmt.Errorf("%s failed: %w", from.Path, waitErr)
+	}
+	return nil
+}
+
+// ImageID returns the ID of a local image on this endpoint, or "" if it is not present.
+func (e Endpoint) ImageID(image string) string {
+	out, err := e.Command("image", "inspect", "--format", "{{.Id}}", image).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ImagePlatform returns the os/arch of an image on this endpoint, or "" if it cannot be inspected.
+func (e Endpoint) ImagePlatform(image string) string {
+	out, err := e.Command("image", "inspect", "--format", "{{.Os}}/{{.Architecture}}", image).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
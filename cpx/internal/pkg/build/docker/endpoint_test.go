@@ -0,0 +1,72 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRemoteHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     string
+		expected bool
+	}{
+		{"Default engine", "", false},
+		{"Unix socket", "unix:///var/run/docker.sock", false},
+		{"Named pipe", "npipe:////./pipe/docker_engine", false},
+		{"SSH", "ssh://ci@arm-box", true},
+		{"TCP", "tcp://10.0.0.5:2376", true},
+		{"TCP localhost", "tcp://localhost:2375", false},
+		{"TCP loopback", "tcp://127.0.0.1:2375", false},
+		{"TCP IPv6 loopback", "tcp://[::1]:2375", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isRemoteHost(tt.host))
+		})
+	}
+}
+
+func TestResolveEndpointHostAndContext(t *testing.T) {
+	_, err := ResolveEndpoint("ssh://ci@arm-box", "arm")
+	assert.Error(t, err)
+
+	e, err := ResolveEndpoint("ssh://ci@arm-box", "")
+	assert.NoError(t, err)
+	assert.True(t, e.Remote)
+	assert.Equal(t, "ssh://ci@arm-box", e.String())
+}
+
+func TestEndpointMount(t *testing.T) {
+	local := Endpoint{}
+	assert.Equal(t, Mount("/src", "/workspace", true), local.Mount("/src", "/workspace", true))
+
+	remote := Endpoint{Host: "ssh://ci@arm-box", Remote: true}
+	mount := remote.Mount("/src", "/workspace", true)
+	assert.Equal(t, remote.Volume("/src")+":/workspace:ro", mount)
+	assert.Regexp(t, `^cpx-[0-9a-f]{12}$`, remote.Volume("/src"))
+	assert.NotEqual(t, remote.Volume("/src"), remote.Volume("/out"))
+
+	// Toolchains built in parallel get volumes of their own
+	linux, arm := remote.Scoped("linux"), remote.Scoped("arm")
+	assert.NotEqual(t, linux.Volume("/src"), arm.Volume("/src"))
+	assert.NotEqual(t, remote.Volume("/src"), linux.Volume("/src"))
+	assert.Equal(t, linux.Volume("/src"), remote.Scoped("linux").Volume("/src"))
+	assert.Equal(t, "ssh://ci@arm-box", linux.String())
+}
+
+func TestEndpointCommand(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix:///other.sock")
+
+	cmd := Endpoint{Context: "arm"}.Command("ps")
+	assert.Equal(t, []string{"--context", "arm", "ps"}, cmd.Args[1:])
+	assert.False(t, slices.ContainsFunc(cmd.Env, func(kv string) bool { return kv == "DOCKER_HOST=unix:///other.sock" }))
+
+	cmd = Endpoint{Host: "ssh://ci@arm-box"}.Command("ps")
+	assert.Equal(t, []string{"ps"}, cmd.Args[1:])
+	assert.Contains(t, cmd.Env, "DOCKER_HOST=ssh://ci@arm-box")
+	assert.NotContains(t, cmd.Env, "DOCKER_HOST=unix:///other.sock")
+}
@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"runtime"
+	"strings"
+)
+
+// HostPlatform returns the Linux container platform that runs natively on this
+// host (linux/arm64 on Apple Silicon, linux/amd64 on x86-64).
+func HostPlatform() string {
+	return hostPlatform(runtime.GOARCH)
+}
+
+func hostPlatform(goarch string) string {
+	switch goarch {
+	case "arm":
+		return "linux/arm/v7"
+	default:
+		return "linux/" + goarch
+	}
+}
+
+// platformArch returns the architecture part of an os/arch[/variant] platform
+func platformArch(platform string) string {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 {
+		return platform
+	}
+	arch := parts[1]
+	switch arch {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	}
+	return arch
+}
+
+// IsEmulated reports whether containers for platform run under emulation on
+// this host. An empty platform means the engine default, which is native.
+func IsEmulated(platform string) bool {
+	return isEmulated(runtime.GOARCH, platform)
+}
+
+func isEmulated(goarch, platform string) bool {
+	if platform == "" {
+		return false
+	}
+	return platformArch(platform) != platformArch(hostPlatform(goarch))
+}
+
+// Emulator names what runs foreign-architecture containers on this host.
+func Emulator() string {
+	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" {
+		return "Rosetta/QEMU"
+	}
+	return "QEMU"
+}
+
+// DescribePlatform returns platform annotated with whether it is native or
+// emulated on this host, e.g. "linux/amd64, emulated".
+func DescribePlatform(platform string) string {
+	if platform == "" {
+		platform = HostPlatform()
+	}
+	if IsEmulated(platform) {
+		return platform + ", emulated"
+	}
+	return platform + ", native"
+}
+
+// PlatformOptions returns the platforms offered for Docker runners, with the
+// host's native platform first so it is the suggested default.
+func PlatformOptions() []string {
+	options := []string{HostPlatform()}
+	for _, p := range []string{"linux/amd64", "linux/arm64"} {
+		if p != options[0] {
+			options = append(options, p)
+		}
+	}
+	return options
+}
+
+// ImagePlatform returns the os/arch of a local image, or "" if it cannot be inspected.
+func ImagePlatform(image string) string {
+	return Endpoint{}.ImagePlatform(image)
+}
+
+// ImageID returns the content-addressed ID (sha256:...) of a local image, or "" if it is not present.
+func ImageID(image string) string {
+	return Endpoint{}.ImageID(image)
+}
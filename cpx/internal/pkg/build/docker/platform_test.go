@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEmulated(t *testing.T) {
+	tests := []struct {
+		name     string
+		goarch   string
+		platform string
+		expected bool
+	}{
+		{"Engine default", "arm64", "", false},
+		{"Apple Silicon native", "arm64", "linux/arm64", false},
+		{"Apple Silicon amd64", "arm64", "linux/amd64", true},
+		{"Image arch alias", "arm64", "linux/aarch64", false},
+		{"x86-64 native", "amd64", "linux/amd64", false},
+		{"x86-64 arm64", "amd64", "linux/arm64", true},
+		{"ARMv7 host", "arm", "linux/arm/v7", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isEmulated(tt.goarch, tt.platform))
+		})
+	}
+}
+
+func TestPlatformOptions(t *testing.T) {
+	options := PlatformOptions()
+	assert.Equal(t, HostPlatform(), options[0])
+	assert.Contains(t, options, "linux/amd64")
+	assert.Contains(t, options, "linux/arm64")
+}
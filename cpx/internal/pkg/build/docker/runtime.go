@@ -0,0 +1,236 @@
+package docker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Runtime describes the container engine the docker CLI talks to.
+type Runtime struct {
+	// Name is a human readable engine name (Docker Desktop, Colima, ...).
+	Name string
+
+	// Host is the DOCKER_HOST cpx sets for docker commands. Empty when the
+	// CLI default (or the user's own DOCKER_HOST/context) is used.
+	Host string
+
+	// Socket is the detected engine socket, if any.
+	Socket string
+
+	// Context is the active docker CLI context when it is not "default".
+	Context string
+
+	// SharedPaths are the host directories mounted into the engine's VM.
+	// Bind mounts outside these paths show up empty in containers.
+	// Empty means no restriction is known.
+	SharedPaths []string
+}
+
+// Unshared returns the paths that are not visible inside the engine's VM.
+func (r *Runtime) Unshared(paths ...string) []string {
+	if r == nil || len(r.SharedPaths) == 0 {
+		return nil
+	}
+	var missing []string
+	for _, p := range paths {
+		vmPath := r.VMPath(p)
+		shared := false
+		for _, s := range r.SharedPaths {
+			if vmPath == s || strings.HasPrefix(vmPath, strings.TrimSuffix(s, "/")+"/") {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// VMPath returns the path a bind mount of hostPath must name for the engine's
+// VM. The VM mounts the real directories, so host symlinks such as macOS's
+// /tmp -> /private/tmp are resolved; a path that does not exist yet is
+// resolved through its nearest existing parent. Engines without a VM get
+// hostPath unchanged.
+func (r *Runtime) VMPath(hostPath string) string {
+	if r == nil || len(r.SharedPaths) == 0 {
+		return hostPath
+	}
+	return resolveExisting(hostPath)
+}
+
+// resolveExisting evaluates the symlinks of path's longest existing prefix
+func resolveExisting(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(resolveExisting(parent), filepath.Base(path))
+}
+
+var (
+	detectOnce      sync.Once
+	detectedRuntime *Runtime
+)
+
+// DetectRuntime finds the container engine socket. When the default socket is
+// missing but Colima, Rancher Desktop or a Podman machine socket exists, the
+// returned Runtime carries the DOCKER_HOST to use.
+func DetectRuntime() *Runtime {
+	detectOnce.Do(func() {
+		home, _ := os.UserHomeDir()
+		detectedRuntime = detectRuntime(runtime.GOOS, home, os.Getenv, socketExists)
+	})
+	return detectedRuntime
+}
+
+func socketExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// socketCandidate is a well-known engine socket location
+type socketCandidate struct {
+	name   string
+	path   string
+	shared []string
+}
+
+func socketCandidates(goos, home string, getenv func(string) string) []socketCandidate {
+	// podman machine init mounts these on macOS, not just the home directory
+	podmanShared := []string{"/Users", "/private", "/var/folders"}
+	var candidates []socketCandidate
+	if home != "" {
+		candidates = append(candidates,
+			socketCandidate{"Docker Desktop", filepath.Join(home, ".docker", "run", "docker.sock"), nil},
+			socketCandidate{"Colima", filepath.Join(home, ".colima", "default", "docker.sock"), []string{home, "/tmp/colima"}},
+			socketCandidate{"Colima", filepath.Join(home, ".colima", "docker.sock"), []string{home, "/tmp/colima"}},
+			socketCandidate{"Rancher Desktop", filepath.Join(home, ".rd", "docker.sock"), []string{home, "/Volumes", "/var/folders", "/tmp/rancher-desktop"}},
+			socketCandidate{"Podman machine", filepath.Join(home, ".local", "share", "containers", "podman", "machine", "podman.sock"), podmanShared},
+			socketCandidate{"Podman machine", filepath.Join(home, ".local", "share", "containers", "podman", "machine", "qemu", "podman.sock"), podmanShared},
+		)
+	}
+	if goos == "darwin" {
+		if tmp := getenv("TMPDIR"); tmp != "" {
+			candidates = append(candidates,
+				socketCandidate{"Podman machine", filepath.Join(tmp, "podman", "podman-machine-default-api.sock"), podmanShared})
+		}
+	}
+	if goos == "linux" {
+		if xdg := getenv("XDG_RUNTIME_DIR"); xdg != "" {
+			candidates = append(candidates, socketCandidate{"Podman", filepath.Join(xdg, "podman", "podman.sock"), nil})
+		}
+	}
+
+	// VM shared folders only matter on macOS; on Linux the engines run natively
+	if goos != "darwin" {
+		for i := range candidates {
+			candidates[i].shared = nil
+		}
+	}
+	return candidates
+}
+
+func detectRuntime(goos, home string, getenv func(string) string, exists func(string) bool) *Runtime {
+	candidates := socketCandidates(goos, home, getenv)
+
+	// identify names a socket path by matching it against the known locations
+	identify := func(socket string) *Runtime {
+		for _, c := range candidates {
+			if c.path == socket {
+				return &Runtime{Name: c.name, Socket: socket, SharedPaths: c.shared}
+			}
+		}
+		return &Runtime{Name: "Docker", Socket: socket}
+	}
+
+	if host := getenv("DOCKER_HOST"); host != "" {
+		rt := identify(strings.TrimPrefix(host, "unix://"))
+		if rt.Name == "Docker" {
+			rt.Name = "custom (DOCKER_HOST)"
+		}
+		return rt
+	}
+
+	if ctx := currentContext(home, getenv); ctx != "" && ctx != "default" {
+		rt := &Runtime{Name: contextRuntimeName(ctx), Context: ctx}
+		for _, c := range candidates {
+			if c.name == rt.Name && exists(c.path) {
+				rt.Socket = c.path
+				rt.SharedPaths = c.shared
+				break
+			}
+		}
+		return rt
+	}
+
+	if goos == "windows" {
+		return &Runtime{Name: "Docker Desktop"}
+	}
+
+	const defaultSocket = "/var/run/docker.sock"
+	if exists(defaultSocket) {
+		target, err := filepath.EvalSymlinks(defaultSocket)
+		if err == nil && target != defaultSocket {
+			return identify(target)
+		}
+		return &Runtime{Name: "Docker", Socket: defaultSocket}
+	}
+
+	for _, c := range candidates {
+		if exists(c.path) {
+			return &Runtime{Name: c.name, Host: "unix://" + c.path, Socket: c.path, SharedPaths: c.shared}
+		}
+	}
+
+	return &Runtime{Name: "Docker"}
+}
+
+// currentContext reads the active context from the docker CLI config
+func currentContext(home string, getenv func(string) string) string {
+	if ctx := getenv("DOCKER_CONTEXT"); ctx != "" {
+		return ctx
+	}
+	configDir := getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		if home == "" {
+			return ""
+		}
+		configDir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return ""
+	}
+	var cfg struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return ""
+	}
+	return cfg.CurrentContext
+}
+
+// contextRuntimeName maps well-known context names to their engine
+func contextRuntimeName(ctx string) string {
+	switch {
+	case strings.HasPrefix(ctx, "colima"):
+		return "Colima"
+	case ctx == "rancher-desktop":
+		return "Rancher Desktop"
+	case ctx == "desktop-linux":
+		return "Docker Desktop"
+	case strings.HasPrefix(ctx, "podman"):
+		return "Podman machine"
+	default:
+		return "Docker (context " + ctx + ")"
+	}
+}
@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRuntime(t *testing.T) {
+	home := "/Users/me"
+	tests := []struct {
+		name    string
+		goos    string
+		env     map[string]string
+		sockets []string
+		engine  string
+		host    string
+		shared  []string
+	}{
+		{
+			name:    "Default socket",
+			goos:    "linux",
+			sockets: []string{"/var/run/docker.sock"},
+			engine:  "Docker",
+		},
+		{
+			name:    "Colima",
+			goos:    "darwin",
+			sockets: []string{"/Users/me/.colima/default/docker.sock"},
+			engine:  "Colima",
+			host:    "unix:///Users/me/.colima/default/docker.sock",
+			shared:  []string{home, "/tmp/colima"},
+		},
+		{
+			name:    "Rancher Desktop",
+			goos:    "darwin",
+			sockets: []string{"/Users/me/.rd/docker.sock"},
+			engine:  "Rancher Desktop",
+			host:    "unix:///Users/me/.rd/docker.sock",
+			shared:  []string{home, "/Volumes", "/var/folders", "/tmp/rancher-desktop"},
+		},
+		{
+			name:    "Podman machine",
+			goos:    "darwin",
+			env:     map[string]string{"TMPDIR": "/var/folders/xy/T"},
+			sockets: []string{"/var/folders/xy/T/podman/podman-machine-default-api.sock"},
+			engine:  "Podman machine",
+			host:    "unix:///var/folders/xy/T/podman/podman-machine-default-api.sock",
+			shared:  []string{"/Users", "/private", "/var/folders"},
+		},
+		{
+			name:    "DOCKER_HOST is respected",
+			goos:    "darwin",
+			env:     map[string]string{"DOCKER_HOST": "unix:///Users/me/.colima/default/docker.sock"},
+			sockets: []string{"/Users/me/.rd/docker.sock"},
+			engine:  "Colima",
+			shared:  []string{home, "/tmp/colima"},
+		},
+		{
+			name:   "Docker context",
+			goos:   "darwin",
+			env:    map[string]string{"DOCKER_CONTEXT": "rancher-desktop"},
+			engine: "Rancher Desktop",
+		},
+		{
+			name:   "Nothing found",
+			goos:   "linux",
+			engine: "Docker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			exists := func(p string) bool {
+				for _, s := range tt.sockets {
+					if s == p {
+						return true
+					}
+				}
+				return false
+			}
+			rt := detectRuntime(tt.goos, home, getenv, exists)
+			assert.Equal(t, tt.engine, rt.Name)
+			assert.Equal(t, tt.host, rt.Host)
+			assert.Equal(t, tt.shared, rt.SharedPaths)
+		})
+	}
+}
+
+func TestRuntimeUnshared(t *testing.T) {
+	rt := &Runtime{Name: "Colima", SharedPaths: []string{"/Users/me", "/tmp/colima"}}
+	assert.Empty(t, rt.Unshared("/Users/me/proj", "/tmp/colima"))
+	assert.Equal(t, []string{"/Volumes/data/proj", "/Users/meow"}, rt.Unshared("/Volumes/data/proj", "/Users/meow"))
+
+	var none *Runtime
+	assert.Empty(t, none.Unshared("/anything"))
+}
+
+func TestRuntimeVMPath(t *testing.T) {
+	real := t.TempDir()
+	link := filepath.Join(t.TempDir(), "link")
+	require.NoError(t, os.Symlink(real, link))
+	real, err := filepath.EvalSymlinks(real)
+	require.NoError(t, err)
+
+	// Symlinks are resolved for VM engines, also below paths not created yet
+	vm := &Runtime{Name: "Podman machine", SharedPaths: []string{"/Users", "/private"}}
+	assert.Equal(t, real, vm.VMPath(link))
+	assert.Equal(t, filepath.Join(real, ".cache", "ci"), vm.VMPath(filepath.Join(link, ".cache", "ci")))
+	assert.Equal(t, []string{link}, vm.Unshared(link))
+
+	native := &Runtime{Name: "Docker"}
+	assert.Equal(t, link, native.VMPath(link))
+}
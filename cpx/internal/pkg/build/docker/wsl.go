@@ -0,0 +1,83 @@
+package docker
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// procOSRelease is read to detect the WSL kernel
+var procOSRelease = "/proc/sys/kernel/osrelease"
+
+// windowsMountRe matches paths on the Windows drives mounted by WSL (/mnt/c/...)
+var windowsMountRe = regexp.MustCompile(`^/mnt/[a-zA-Z](/|$)`)
+
+var (
+	windowsCLIOnce sync.Once
+	windowsCLI     bool
+)
+
+func kernelRelease() string {
+	data, err := os.ReadFile(procOSRelease)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(string(data)))
+}
+
+// IsWSL reports whether cpx is running inside the Windows Subsystem for Linux.
+func IsWSL() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	return os.Getenv("WSL_DISTRO_NAME") != "" || strings.Contains(kernelRelease(), "microsoft")
+}
+
+// IsWSL2 reports whether cpx is running inside a WSL2 (virtualized kernel) distro.
+func IsWSL2() bool {
+	if !IsWSL() {
+		return false
+	}
+	release := kernelRelease()
+	return strings.Contains(release, "wsl2") || strings.Contains(release, "microsoft-standard")
+}
+
+// OnWindowsMount reports whether path lives on a Windows drive mounted into
+// WSL over 9p (/mnt/c, /mnt/d, ...), which is slow for build I/O.
+func OnWindowsMount(path string) bool {
+	return windowsMountRe.MatchString(filepath.ToSlash(path))
+}
+
+// usesWindowsCLI reports whether the docker on PATH is the Windows docker.exe
+// (WSL interop) rather than the Linux CLI from the Docker Desktop integration.
+// The Windows CLI needs Windows paths for bind mounts.
+func usesWindowsCLI() bool {
+	windowsCLIOnce.Do(func() {
+		if !IsWSL() {
+			return
+		}
+		path, err := exec.LookPath("docker")
+		if err != nil {
+			path, err = exec.LookPath("docker.exe")
+			if err != nil {
+				return
+			}
+		}
+		windowsCLI = strings.HasSuffix(strings.ToLower(path), ".exe")
+	})
+	return windowsCLI
+}
+
+// wslPath converts a WSL path to a Windows path with forward slashes (C:/...).
+// The path is returned unchanged if wslpath fails.
+func wslPath(path string) string {
+	out, err := exec.Command("wslpath", "-m", path).Output()
+	if err != nil {
+		return path
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnWindowsMount(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/mnt/c/Users/me/proj", true},
+		{"/mnt/D/src", true},
+		{"/mnt/c", true},
+		{"/mnt/wsl/proj", false},
+		{"/home/me/proj", false},
+		{"/mnt/cdrom/proj", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, OnWindowsMount(tt.path))
+		})
+	}
+}
+
+func TestIsWSL2(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("WSL detection only applies on Linux")
+	}
+	t.Setenv("WSL_DISTRO_NAME", "")
+
+	orig := procOSRelease
+	defer func() { procOSRelease = orig }()
+
+	tests := []struct {
+		name    string
+		release string
+		wsl     bool
+		wsl2    bool
+	}{
+		{"WSL2", "5.15.153.1-microsoft-standard-WSL2", true, true},
+		{"WSL1", "4.4.0-19041-Microsoft", true, false},
+		{"Linux", "6.8.0-45-generic", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procOSRelease = filepath.Join(t.TempDir(), "osrelease")
+			require.NoError(t, os.WriteFile(procOSRelease, []byte(tt.release+"\n"), 0644))
+			assert.Equal(t, tt.wsl, IsWSL())
+			assert.Equal(t, tt.wsl2, IsWSL2())
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package build
+
+import "fmt"
+
+// CCacheScript returns the build script lines that enable ccache with its
+// cache in dir, a path inside the container's persistent build directory.
+// COMPILER_LAUNCHER is set to "ccache" for CMake's compiler launcher, or left
+// empty when the image has no ccache and the build runs without it.
+func CCacheScript(dir string) string {
+	return fmt.Sprintf(`# Compiler cache
+if command -v ccache > /dev/null 2>&1; then
+    export CCACHE_DIR=%s
+    export CCACHE_BASEDIR=/workspace
+    COMPILER_LAUNCHER=ccache
+    ccache --zero-stats > /dev/null
+else
+    echo "  ccache not found in the image, building without it"
+    COMPILER_LAUNCHER=
+fi
+`, dir)
+}
+
+// CCacheStatsScript returns the build script lines that print ccache's hits
+// and misses for the build.
+func CCacheStatsScript() string {
+	return `if [ -n "$COMPILER_LAUNCHER" ]; then ccache --show-stats; fi
+`
+}
@@ -0,0 +1,41 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// RunContainer runs the docker run command of a build container until it
+// exits or ctx ends. The container is then killed by its ContainerName, since
+// killing the docker client alone leaves it running, and ctx's error is
+// returned.
+func RunContainer(ctx context.Context, opts DockerBuildOptions, cmd *exec.Cmd) error {
+	defer FlushOutput(cmd.Stdout, cmd.Stderr)
+	if ctx.Done() == nil {
+		return cmd.Run()
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-ctx.Done():
+		if opts.ContainerName != "" {
+			_ = opts.Endpoint.Command("kill", opts.ContainerName).Run()
+		}
+		_ = cmd.Process.Kill()
+		<-exited
+		return ctx.Err()
+	}
+}
+
+// ContainerName returns a name for attempt of a target's build container,
+// unique to this process like the names of its services
+func ContainerName(target string, attempt int) string {
+	return fmt.Sprintf("cpx-%s-%d-build-%d", containerNameInvalid.ReplaceAllString(target, "-"), os.Getpid(), attempt)
+}
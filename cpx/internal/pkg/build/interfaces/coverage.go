@@ -0,0 +1,93 @@
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Compiler families TestOptions.Coverage instruments tests with.
+const (
+	// CoverageGCC is gcov's instrumentation: .gcda counter files beside the
+	// object files.
+	CoverageGCC = "gcc"
+
+	// CoverageClang is clang's source-based coverage: .profraw profiles
+	// written where LLVM_PROFILE_FILE says.
+	CoverageClang = "clang"
+)
+
+// CoverageBuildDir is the build directory of the coverage-instrumented tests
+// of CMake projects, apart from that of `cpx test`.
+var CoverageBuildDir = filepath.Join(".cache", "native", "coverage")
+
+// CoverageFlags returns the compile and link flags instrumenting code for
+// coverage with a compiler family, unoptimized so lines map to code.
+func CoverageFlags(compiler string) (compile []string, link []string) {
+	if compiler == CoverageClang {
+		return []string{"-fprofile-instr-generate", "-fcoverage-mapping", "-O0", "-g"},
+			[]string{"-fprofile-instr-generate"}
+	}
+	return []string{"--coverage", "-O0", "-g"}, []string{"--coverage"}
+}
+
+// ProfileFile returns the LLVM_PROFILE_FILE of clang-instrumented tests of a
+// build directory: a profile per process and binary in its profraw directory.
+func ProfileFile(buildDir string) string {
+	return filepath.Join(buildDir, "profraw", "%p-%m.profraw")
+}
+
+// CoverageResultsDir is the directory in a target's output directory
+// receiving the coverage of Docker builds that measure it: gcov's JSON
+// reports (*.gcov.json.gz) for gcc, CoverageLcovFile for clang.
+const CoverageResultsDir = "coverage"
+
+// CoverageLcovFile is the lcov report llvm-cov exports in CoverageResultsDir
+// for clang-instrumented Docker builds.
+const CoverageLcovFile = "llvm-cov.lcov"
+
+// CoverageSetupScript returns the build script lines that remove the coverage
+// of earlier test runs from a container build directory, and for clang point
+// the tests' profiles at its profraw directory.
+func CoverageSetupScript(compiler, buildDir string) string {
+	script := fmt.Sprintf("find %[1]s -name '*.gcda' -delete 2>/dev/null || true\nrm -rf %[1]s/profraw\n", buildDir)
+	if compiler == CoverageClang {
+		script += fmt.Sprintf("export LLVM_PROFILE_FILE=%s/profraw/%%p-%%m.profraw\n", buildDir)
+	}
+	return script
+}
+
+// CoverageCollectScript returns the build script lines, without a final
+// newline, that write the coverage of the tests run in a container build
+// directory to CoverageResultsDir of target, with the gcov or LLVM tools of
+// the image's compiler ($CXX). A missing tool is a warning, not a failed
+// build.
+func CoverageCollectScript(compiler, buildDir, target string) string {
+	dir := fmt.Sprintf("/output/%s/%s", target, CoverageResultsDir)
+	if compiler == CoverageClang {
+		return fmt.Sprintf(`echo " Collecting coverage..."
+case "$CXX" in *clang++*) cpx_llvm_suffix="${CXX##*clang++}" ;; *) cpx_llvm_suffix="" ;; esac
+cpx_profdata=$(command -v "llvm-profdata$cpx_llvm_suffix" || command -v llvm-profdata || true)
+cpx_llvm_cov=$(command -v "llvm-cov$cpx_llvm_suffix" || command -v llvm-cov || true)
+if [ -z "$cpx_profdata" ] || [ -z "$cpx_llvm_cov" ]; then
+    echo "  Warning: llvm-profdata or llvm-cov not found in the image; no coverage collected"
+elif ! ls %[1]s/profraw/*.profraw > /dev/null 2>&1; then
+    echo "  Warning: the tests wrote no coverage profiles"
+else
+    cpx_objects=""
+    for f in $(find %[1]s -type f -perm /111 ! -path '%[1]s/.*' ! -path '*/CMakeFiles/*'); do
+        if grep -qa __llvm_covmap "$f"; then cpx_objects="$cpx_objects -object $f"; fi
+    done
+    { "$cpx_profdata" merge -sparse -o %[1]s/coverage.profdata %[1]s/profraw/*.profraw &&
+      "$cpx_llvm_cov" export -format=lcov -instr-profile=%[1]s/coverage.profdata $cpx_objects > %[2]s/%[3]s; } ||
+        echo "  Warning: llvm-cov failed; no coverage collected"
+fi`, buildDir, dir, CoverageLcovFile)
+	}
+	return fmt.Sprintf(`echo " Collecting coverage..."
+case "$CXX" in *g++*) cpx_gcov="${CXX%%g++*}gcov${CXX##*g++}" ;; *) cpx_gcov=gcov ;; esac
+command -v "$cpx_gcov" > /dev/null || cpx_gcov=gcov
+if ! "$cpx_gcov" --help 2>/dev/null | grep -q -- --json-format; then
+    echo "  Warning: $cpx_gcov cannot write JSON reports (gcc 9 or newer); no coverage collected"
+else
+    (cd %[2]s && find %[1]s -name '*.gcda' ! -path '%[1]s/.*' -exec sh -c '"$0" -b --json-format --hash-filenames --object-directory "$(dirname "$1")" "$1" > /dev/null' "$cpx_gcov" {} \;)
+fi`, buildDir, dir)
+}
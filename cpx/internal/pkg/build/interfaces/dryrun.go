@@ -0,0 +1,71 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
+	"github.com/ozacod/cpx/internal/pkg/build/remote"
+)
+
+// dryRunScriptEnd terminates the heredoc holding a script in FprintCommand
+const dryRunScriptEnd = "CPX_SCRIPT"
+
+// FprintCommand writes args as a command line that can be pasted into a
+// shell, for dry runs. Options and the trailing positional arguments (e.g.
+// image bash) start new lines, so mounts and forwarded variables stand out. A multi-line last argument (a build script) is passed
+// through a quoted heredoc to keep it readable.
+func FprintCommand(w io.Writer, args []string) {
+	if len(args) == 0 {
+		return
+	}
+	fprintCommandLine(w, remote.Quote(args[0]), args[1:])
+}
+
+// FprintDockerCommand writes the docker command endpoint.Command(args...)
+// would run, as FprintCommand does.
+func FprintDockerCommand(w io.Writer, endpoint docker.Endpoint, args []string) {
+	head := "docker"
+	if endpoint.Host != "" {
+		head = "DOCKER_HOST=" + remote.Quote(endpoint.Host) + " docker"
+	}
+	if endpoint.Context != "" {
+		head += " --context " + remote.Quote(endpoint.Context)
+	}
+	fprintCommandLine(w, head, args)
+}
+
+// fprintCommandLine writes head followed by args, formatted as described for FprintCommand
+func fprintCommandLine(w io.Writer, head string, args []string) {
+	script := ""
+	if n := len(args); n > 0 && strings.Contains(args[n-1], "\n") {
+		script = args[n-1]
+		args = args[:n-1]
+	}
+
+	var line strings.Builder
+	line.WriteString(head)
+	isOption := func(i int) bool { return i < len(args) && strings.HasPrefix(args[i], "-") }
+	seenOption, inOptions := false, true
+	for i, arg := range args {
+		newLine := false
+		switch {
+		case isOption(i) && inOptions:
+			seenOption, newLine = true, true
+		case inOptions && seenOption && i > 0 && !isOption(i-1) && i+1 < len(args) && !isOption(i+1):
+			// The first of the trailing positional arguments (e.g. image bash)
+			inOptions, newLine = false, true
+		}
+		if newLine {
+			line.WriteString(" \\\n    ")
+		} else {
+			line.WriteString(" ")
+		}
+		line.WriteString(remote.Quote(arg))
+	}
+	if script != "" {
+		fmt.Fprintf(&line, " \"$(cat <<'%s'\n%s\n%s\n)\"", dryRunScriptEnd, strings.TrimSuffix(script, "\n"), dryRunScriptEnd)
+	}
+	fmt.Fprintln(w, line.String())
+}
@@ -0,0 +1,27 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// ExitError is the error of Run when the project's executable ran and exited
+// with a non-zero status, as opposed to a failed build
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ProgramExit returns the error of running the project's executable, an
+// ExitError if it exited with a non-zero status
+func ProgramExit(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
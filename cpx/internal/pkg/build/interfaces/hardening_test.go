@@ -0,0 +1,72 @@
+package build
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardeningFlags(t *testing.T) {
+	compile, link := HardeningFlags(false, true)
+	assert.Equal(t, []string{"-D_FORTIFY_SOURCE=2", "-fstack-protector-strong", "-fPIE"}, compile)
+	assert.Equal(t, []string{"-pie", "-Wl,-z,relro,-z,now"}, link)
+
+	// _FORTIFY_SOURCE only warns at -O0
+	compile, _ = HardeningFlags(false, false)
+	assert.Equal(t, []string{"-fstack-protector-strong", "-fPIE"}, compile)
+
+	compile, link = HardeningFlags(true, false)
+	assert.Contains(t, compile, "/guard:cf")
+	assert.Contains(t, link, "/NXCOMPAT")
+}
+
+func TestUsesMSVCFlags(t *testing.T) {
+	t.Setenv("CXX", "")
+	t.Setenv("CC", "")
+	assert.True(t, UsesMSVCFlags("cl"))
+	assert.True(t, UsesMSVCFlags(`C:\LLVM\bin\clang-cl.exe`))
+	assert.False(t, UsesMSVCFlags("x86_64-w64-mingw32-g++"))
+	assert.False(t, UsesMSVCFlags("", "clang"))
+	assert.Equal(t, runtime.GOOS == "windows", UsesMSVCFlags())
+
+	t.Setenv("CXX", "/usr/bin/clang++")
+	assert.False(t, UsesMSVCFlags())
+	assert.True(t, UsesMSVCFlags("CL.EXE"))
+}
+
+func TestAuditHardening(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the test binary is only an ELF binary on Linux")
+	}
+
+	audit, err := AuditHardening(os.Args[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.Args[0], audit.Path)
+	// The Go linker always marks the stack non-executable
+	assert.True(t, audit.NX)
+
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "README")
+	require.NoError(t, os.WriteFile(notes, []byte("not a binary"), 0644))
+	_, err = AuditHardening(notes)
+	assert.Error(t, err)
+
+	data, err := os.ReadFile(os.Args[0])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app"), data, 0755))
+
+	var out bytes.Buffer
+	err = FprintHardeningAudit(&out, dir)
+	assert.Contains(t, out.String(), "app")
+	assert.NotContains(t, out.String(), "README")
+	if audit.Passed() {
+		assert.NoError(t, err)
+	} else {
+		assert.EqualError(t, err, "hardening audit failed: 1 binary(ies) missing hardening features")
+	}
+}
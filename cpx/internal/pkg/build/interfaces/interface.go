@@ -6,6 +6,13 @@ package build
 
 import (
 	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
 )
 
 // DockerBuildOptions contains options for Docker-based builds.
@@ -19,6 +26,10 @@ type DockerBuildOptions struct {
 	// OutputDir is the relative path for build artifacts.
 	OutputDir string
 
+	// CacheDir is the host directory for persistent build caches
+	// (default: <ProjectRoot>/.cache/ci).
+	CacheDir string
+
 	// BuildType is the build type (Debug, Release, etc.).
 	BuildType string
 
@@ -46,6 +57,11 @@ type DockerBuildOptions struct {
 	// RunTests runs tests after building.
 	RunTests bool
 
+	// Coverage instruments the tests with the coverage of a compiler family,
+	// CoverageGCC or CoverageClang, and collects it into CoverageResultsDir
+	// (CMake builds only); empty for none.
+	Coverage string
+
 	// RunBenchmarks runs benchmarks after building.
 	RunBenchmarks bool
 
@@ -57,6 +73,250 @@ type DockerBuildOptions struct {
 
 	// Verbose enables verbose output.
 	Verbose bool
+
+	// Hardening injects the hardening compiler and linker flags.
+	Hardening bool
+
+	// CrossTarget names the runner's cross-compilation preset, if any. Its
+	// settings are CMake and vcpkg ones, so only CMake builds support it.
+	CrossTarget string
+
+	// Triplet is the vcpkg target triplet (default: the image's).
+	Triplet string
+
+	// ConfigureWrapper prefixes the CMake configure command (e.g. emcmake).
+	ConfigureWrapper string
+
+	// ArtifactPatterns are file name patterns copied as artifacts in
+	// addition to executables and libraries (e.g. "*.exe" for Windows).
+	ArtifactPatterns []string
+
+	// VerifyStatic fails the build when a produced executable is
+	// dynamically linked.
+	VerifyStatic bool
+
+	// CCache compiles through ccache, with its cache in the persistent build
+	// directory so it survives the container.
+	CCache bool
+
+	// SCCache compiles through sccache instead, possibly with shared remote
+	// storage. It takes precedence over CCache.
+	SCCache *SCCache
+
+	// BinarySources are remote vcpkg binary caches used in addition to the
+	// local one.
+	BinarySources []BinarySource
+
+	// PassEnv names host variables forwarded into the container when set,
+	// e.g. credentials for BinarySources.
+	PassEnv []string
+
+	// Secrets are PassEnv variables whose values are masked in the build's
+	// output and Log.
+	Secrets []string
+
+	// DryRun prints the docker command and build script instead of running
+	// them. The host directories mounted into the container are still created.
+	DryRun bool
+
+	// Shell starts an interactive bash in the build container instead of
+	// building, with the build's mounts and environment.
+	Shell bool
+
+	// ShellCommand, with Shell, runs in place of the interactive bash.
+	ShellCommand []string
+
+	// NoStdin runs a ShellCommand without the terminal's stdin or a TTY,
+	// for commands that are not the user's, such as analyzers and hooks.
+	NoStdin bool
+
+	// User is the uid:gid the build container runs as (default: the image's
+	// user, usually root), so the files it writes into mounted host
+	// directories belong to the host user.
+	User string
+
+	// CPUs and Memory limit the build container (docker run --cpus and
+	// --memory); zero and empty mean no limit.
+	CPUs   float64
+	Memory string
+
+	// Network is the build container's network mode (docker run --network),
+	// ExtraHosts its additional host:ip entries and DNS its DNS servers.
+	Network    string
+	ExtraHosts []string
+	DNS        []string
+
+	// Services are sidecar containers started for tests, benchmarks, runs
+	// and shells (see UsesServices).
+	Services []Service
+
+	// ContainerName names the build container, so RunContainer can stop it
+	// when the build's context ends (default: a name Docker picks).
+	ContainerName string
+
+	// Endpoint is the Docker engine to build on (default: the local engine).
+	Endpoint docker.Endpoint
+
+	// Output receives the build's output. Defaults to os.Stdout/os.Stderr;
+	// parallel builds set it to keep each target's output apart.
+	Output io.Writer
+
+	// Log receives a copy of the build's complete output when set. Quiet
+	// builds send the build tools' output there instead of discarding it. It
+	// must be safe for concurrent use.
+	Log io.Writer
+}
+
+// Stdout returns the writer for the build's standard output.
+func (o DockerBuildOptions) Stdout() io.Writer {
+	return o.mask(o.stdout())
+}
+
+// Stderr returns the writer for the build's error output.
+func (o DockerBuildOptions) Stderr() io.Writer {
+	return o.mask(o.stderr())
+}
+
+func (o DockerBuildOptions) stdout() io.Writer {
+	if o.Output != nil {
+		return o.Output
+	}
+	return os.Stdout
+}
+
+func (o DockerBuildOptions) stderr() io.Writer {
+	if o.Output != nil {
+		return o.Output
+	}
+	return os.Stderr
+}
+
+// Streams returns the writers for the build container's standard and error
+// output, both copied to Log. Quiet builds write the build tools' output to
+// stderr (see QuietRedirect), which with a Log still reaches the terminal so
+// compiler errors are not only in the log. Secrets are masked last, so
+// FlushOutput reaches the writers once the build ends.
+func (o DockerBuildOptions) Streams() (stdout, stderr io.Writer) {
+	if o.Log == nil {
+		return o.Stdout(), o.Stderr()
+	}
+	both := io.MultiWriter(o.stdout(), o.Log)
+	if o.Output != nil {
+		// One writer, so the streams are never copied into Output concurrently
+		stdout = o.mask(both)
+		return stdout, stdout
+	}
+	return o.mask(both), o.mask(io.MultiWriter(o.stderr(), o.Log))
+}
+
+// QuietRedirect returns the shell redirection appended to build tool
+// commands: none for verbose builds, stderr for quiet builds with a Log and
+// /dev/null otherwise.
+func (o DockerBuildOptions) QuietRedirect() string {
+	switch {
+	case o.Verbose:
+		return ""
+	case o.Log != nil:
+		return " >&2"
+	default:
+		return " > /dev/null 2>&1"
+	}
+}
+
+// ShellArgs returns the docker run arguments attaching a Shell container to
+// stdin, and to the terminal when stdin is one, unless NoStdin is set.
+func (o DockerBuildOptions) ShellArgs() []string {
+	if !o.Shell || o.NoStdin {
+		return nil
+	}
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return []string{"-i", "-t"}
+	}
+	return []string{"-i"}
+}
+
+// Home returns HOME in the build container: /root, or the writable /tmp for
+// a User, who has no home directory in the image.
+func (o DockerBuildOptions) Home() string {
+	if o.User != "" {
+		return "/tmp"
+	}
+	return "/root"
+}
+
+// UserArgs returns the docker run arguments running the container as User,
+// with the HOME and USER a uid without a passwd entry lacks (Bazel needs both).
+func (o DockerBuildOptions) UserArgs() []string {
+	if o.User == "" {
+		return nil
+	}
+	return []string{"--user", o.User, "-e", "HOME=" + o.Home(), "-e", "USER=cpx"}
+}
+
+// RunArgs returns the docker run arguments for the build container's user,
+// resource limits and networking.
+func (o DockerBuildOptions) RunArgs() []string {
+	args := o.UserArgs()
+	if o.ContainerName != "" {
+		args = append(args, "--name", o.ContainerName)
+	}
+	if o.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(o.CPUs, 'f', -1, 64))
+	}
+	if o.Memory != "" {
+		args = append(args, "--memory", o.Memory)
+	}
+	if o.Network != "" {
+		args = append(args, "--network", o.Network)
+	}
+	for _, host := range o.ExtraHosts {
+		args = append(args, "--add-host", host)
+	}
+	for _, server := range o.DNS {
+		args = append(args, "--dns", server)
+	}
+	return args
+}
+
+// EnvArgs returns the docker run arguments that forward the PassEnv (and
+// SCCache.PassEnv) variables set on the host, by name only so their values
+// stay out of the arguments.
+func (o DockerBuildOptions) EnvArgs() []string {
+	names := o.PassEnv
+	if o.SCCache != nil {
+		names = append(slices.Clone(names), o.SCCache.PassEnv...)
+	}
+	var args []string
+	for _, name := range names {
+		if os.Getenv(name) != "" {
+			args = append(args, "-e", name)
+		}
+	}
+	return args
+}
+
+// BinarySource is a remote vcpkg binary cache.
+type BinarySource struct {
+	// Type is gha, nuget or http.
+	Type string
+
+	// URL is the NuGet feed or the HTTP URL template.
+	URL string
+
+	// Mode is read, write or readwrite (default).
+	Mode string
+
+	// Header is an HTTP header sent with http requests. The build script
+	// expands $NAME references to forwarded variables.
+	Header string
+}
+
+// CacheRoot returns the host directory holding persistent build caches.
+func (o DockerBuildOptions) CacheRoot() string {
+	if o.CacheDir != "" {
+		return o.CacheDir
+	}
+	return filepath.Join(o.ProjectRoot, ".cache", "ci")
 }
 
 // DockerBuilder defines the interface for Docker-based builds.
@@ -65,6 +325,27 @@ type DockerBuilder interface {
 	RunDockerBuild(ctx context.Context, opts DockerBuildOptions) error
 }
 
+// DockerBuildDescriber is implemented by Docker builders that can report the
+// build commands they run in the container without running them.
+type DockerBuildDescriber interface {
+	// DescribeDockerBuild returns the command lines RunDockerBuild would execute.
+	DescribeDockerBuild(opts DockerBuildOptions) []string
+}
+
+// DockerPrefetcher is implemented by Docker builders that can download a
+// project's dependencies into the target's caches without compiling it.
+type DockerPrefetcher interface {
+	// PrefetchDockerDependencies runs the dependencies-only phase in the container.
+	PrefetchDockerDependencies(ctx context.Context, opts DockerBuildOptions) error
+}
+
+// DevDependencyAdder is implemented by build systems that can scope a
+// dependency to tests only, keeping it out of release builds.
+type DevDependencyAdder interface {
+	// AddDevDependency adds a test-only dependency to the project.
+	AddDevDependency(ctx context.Context, name string, version string) error
+}
+
 // DependencyInfo contains detailed information about a package
 type DependencyInfo struct {
 	Name         string   `json:"name"`
@@ -156,6 +437,9 @@ type BuildOptions struct {
 	// Sanitizer specifies the sanitizer to use (asan, tsan, msan, ubsan).
 	Sanitizer string
 
+	// Hardening injects the hardening compiler and linker flags.
+	Hardening bool
+
 	// Target specifies a specific build target (optional).
 	Target string
 
@@ -172,6 +456,15 @@ type BuildOptions struct {
 	Toolchain string
 }
 
+// Optimized reports whether the build compiles with optimizations: a release
+// build or an optimization level other than 0.
+func (o BuildOptions) Optimized() bool {
+	if o.OptLevel != "" {
+		return o.OptLevel != "0"
+	}
+	return o.Release
+}
+
 // TestOptions contains options for running tests.
 type TestOptions struct {
 	// Verbose enables verbose test output.
@@ -182,6 +475,10 @@ type TestOptions struct {
 
 	// Toolchain specifies a custom toolchain to use.
 	Toolchain string
+
+	// Coverage instruments the tests for coverage with a compiler family,
+	// CoverageGCC or CoverageClang; empty for none.
+	Coverage string
 }
 
 // RunOptions contains options for running the project.
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PhaseFile is the file in a target's persistent build directory holding the
+// start times of the last build's phases (configure, build, test, copy...),
+// one "<phase> <unix seconds>" line each. An "end" line closes the last phase.
+const PhaseFile = ".cpx-phases"
+
+// Phase is a timed step of a build.
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// PhaseScript returns the build script lines that define cpx_phase, which
+// records the start of the phase named by its argument in dir/PhaseFile.
+func PhaseScript(dir string) string {
+	return fmt.Sprintf(`cpx_phase() { echo "$1 ${EPOCHREALTIME:-$(date +%%s)}" >> %[1]s/%[2]s; }
+: > %[1]s/%[2]s
+`, dir, PhaseFile)
+}
+
+// StartPhases clears the phases recorded in dir, for builds timed on the host.
+func StartPhases(dir string) {
+	_ = os.WriteFile(filepath.Join(dir, PhaseFile), nil, 0644)
+}
+
+// RecordPhase records the start of a phase in dir, as cpx_phase does.
+func RecordPhase(dir, name string) {
+	f, err := os.OpenFile(filepath.Join(dir, PhaseFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%s %.6f\n", name, float64(time.Now().UnixMicro())/1e6)
+}
+
+// ReadPhases returns the completed phases recorded in dir, in order. A phase
+// a failed build never finished is left out.
+func ReadPhases(dir string) []Phase {
+	f, err := os.Open(filepath.Join(dir, PhaseFile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	type mark struct {
+		name string
+		at   float64
+	}
+	var marks []mark
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, at, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok {
+			continue
+		}
+		// EPOCHREALTIME uses the locale's decimal separator
+		seconds, err := strconv.ParseFloat(strings.Replace(at, ",", ".", 1), 64)
+		if err != nil {
+			continue
+		}
+		marks = append(marks, mark{name, seconds})
+	}
+
+	var phases []Phase
+	for i := 0; i+1 < len(marks); i++ {
+		d := time.Duration((marks[i+1].at - marks[i].at) * float64(time.Second))
+		phases = append(phases, Phase{Name: marks[i].name, Duration: max(d, 0)})
+	}
+	return phases
+}
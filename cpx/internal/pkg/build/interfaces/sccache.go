@@ -0,0 +1,76 @@
+package build
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SCCacheVersion is the sccache release installed into images that lack it.
+const SCCacheVersion = "0.8.2"
+
+// SCCache configures sccache for a Docker build.
+type SCCache struct {
+	// Env selects and configures the storage, e.g. SCCACHE_BUCKET.
+	// Without storage settings sccache caches on local disk.
+	Env map[string]string
+
+	// PassEnv names host variables forwarded into the container when set,
+	// e.g. credentials, which stay out of the build script and arguments.
+	PassEnv []string
+}
+
+// SCCacheScript returns the build script lines that enable sccache. An image
+// without sccache gets the static release binary, kept in binDir inside the
+// persistent build directory; local disk storage goes to dir. COMPILER_LAUNCHER
+// is set as by CCacheScript.
+func SCCacheScript(s *SCCache, dir, binDir string) string {
+	keys := make([]string, 0, len(s.Env))
+	for k := range s.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var exports strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&exports, "    export %s=\"%s\"\n", k, s.Env[k])
+	}
+
+	release := "sccache-v" + SCCacheVersion + "-$(uname -m)-unknown-linux-musl"
+	return fmt.Sprintf(`# Compiler cache (sccache)
+SCCACHE=$(command -v sccache || true)
+if [ -z "$SCCACHE" ]; then
+    SCCACHE=%[3]s/sccache
+    if [ ! -x "$SCCACHE" ]; then
+        echo "  Installing sccache %[4]s..."
+        mkdir -p %[3]s
+        curl -fsSL "https://github.com/mozilla/sccache/releases/download/v%[4]s/%[5]s.tar.gz" | tar -xz --strip-components=1 -C %[3]s "%[5]s/sccache" || true
+    fi
+fi
+COMPILER_LAUNCHER=
+if [ -x "$SCCACHE" ]; then
+    export SCCACHE_DIR=%[1]s
+%[2]s    if [ -n "$CPX_GCS_KEY" ]; then
+        printf '%%s' "$CPX_GCS_KEY" > /tmp/.sccache-gcs-key.json
+        export SCCACHE_GCS_KEY_PATH=/tmp/.sccache-gcs-key.json
+    fi
+    if "$SCCACHE" --zero-stats > /dev/null; then
+        COMPILER_LAUNCHER=$SCCACHE
+        export PATH="$(dirname "$SCCACHE"):$PATH"
+    else
+        echo "  sccache failed to start, building without it"
+    fi
+else
+    echo "  sccache is not available for $(uname -m), building without it"
+fi
+`, dir, exports.String(), binDir, SCCacheVersion, release)
+}
+
+// SCCacheStatsScript returns the build script lines that print sccache's
+// hits and misses for the build.
+func SCCacheStatsScript() string {
+	return `if [ -n "$COMPILER_LAUNCHER" ]; then
+    "$COMPILER_LAUNCHER" --show-stats | grep -E "^(Compile requests|Cache hits|Cache misses) +[0-9]" | sed "s/^/  sccache: /" || true
+    "$COMPILER_LAUNCHER" --stop-server > /dev/null 2>&1 || true
+fi
+`
+}
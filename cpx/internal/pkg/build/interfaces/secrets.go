@@ -0,0 +1,89 @@
+package build
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"slices"
+)
+
+// secretMask replaces the values of secrets in build output
+var secretMask = []byte("***")
+
+// mask returns w masking the values the Secrets have on the host, or w
+// itself without any
+func (o DockerBuildOptions) mask(w io.Writer) io.Writer {
+	var values [][]byte
+	for _, name := range o.Secrets {
+		if v := os.Getenv(name); v != "" {
+			values = append(values, []byte(v))
+		}
+	}
+	if len(values) == 0 {
+		return w
+	}
+	// Longest first, so a secret containing another is masked whole
+	slices.SortFunc(values, func(a, b []byte) int { return len(b) - len(a) })
+	return &maskWriter{w: w, values: values}
+}
+
+// maskWriter replaces secret values in what it writes with ***. Output ending
+// with the beginning of a value is held back until the next write shows whether
+// the value follows, so a value split across writes (exec copies a command's
+// output in chunks) is masked as well; Flush writes what is still held.
+type maskWriter struct {
+	w      io.Writer
+	values [][]byte
+	held   []byte
+}
+
+func (m *maskWriter) Write(p []byte) (int, error) {
+	masked := append(m.held, p...)
+	for _, v := range m.values {
+		if bytes.Contains(masked, v) {
+			masked = bytes.ReplaceAll(masked, v, secretMask)
+		}
+	}
+	keep := m.partialSuffix(masked)
+	m.held = slices.Clone(masked[len(masked)-keep:])
+	if _, err := m.w.Write(masked[:len(masked)-keep]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// partialSuffix returns the length of the longest end of b that a value
+// starts with, without being the whole value
+func (m *maskWriter) partialSuffix(b []byte) int {
+	longest := 0
+	for _, v := range m.values {
+		for n := min(len(v)-1, len(b)); n > longest; n-- {
+			if bytes.HasSuffix(b, v[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// Flush writes the output held back, once nothing more follows
+func (m *maskWriter) Flush() error {
+	held := m.held
+	m.held = nil
+	if len(held) == 0 {
+		return nil
+	}
+	_, err := m.w.Write(held)
+	return err
+}
+
+// FlushOutput writes the output the masking writers among ws hold back, once
+// the command writing to them has finished
+func FlushOutput(ws ...io.Writer) {
+	for _, w := range ws {
+		if m, ok := w.(*maskWriter); ok {
+			_ = m.Flush()
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskSecretsAcrossWrites(t *testing.T) {
+	t.Setenv("CPX_TEST_TOKEN", "token-s3cr3t")
+	var out bytes.Buffer
+	opts := DockerBuildOptions{Output: &out, Secrets: []string{"CPX_TEST_TOKEN"}}
+
+	stdout, stderr := opts.Streams()
+	fmt.Fprint(stdout, "pushing with tok")
+	fmt.Fprint(stdout, "en-s3cr3t\n")
+	// An unfinished beginning of the value is only written by FlushOutput
+	fmt.Fprint(stdout, "done tok")
+	assert.Equal(t, "pushing with ***\ndone ", out.String())
+	FlushOutput(stdout, stderr)
+	assert.Equal(t, "pushing with ***\ndone tok", out.String())
+
+	// Messages are written whole
+	out.Reset()
+	fmt.Fprintf(opts.Stdout(), "token %s\n", "token-s3cr3t")
+	assert.Equal(t, "token ***\n", out.String())
+}
@@ -0,0 +1,144 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ozacod/cpx/internal/pkg/build/docker"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+)
+
+// Service is a sidecar container (a database, a cache, ...) that the build
+// container reaches by Name while it runs tests, benchmarks or the executable.
+type Service struct {
+	Name  string
+	Image string
+	Env   map[string]string
+
+	// Ports are published to the host (docker run -p), e.g. "5432:5432".
+	Ports []string
+
+	// Command replaces the image's command.
+	Command []string
+
+	// HealthCmd checks the service is ready; the build waits for it, as
+	// for a HEALTHCHECK in the image.
+	HealthCmd string
+}
+
+// serviceHealthTimeout bounds the wait for services to become healthy
+const serviceHealthTimeout = 2 * time.Minute
+
+var containerNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// UsesServices reports whether the build starts its Services: when it runs
+// tests, benchmarks, the executable or a shell, which may talk to them.
+func (o DockerBuildOptions) UsesServices() bool {
+	return len(o.Services) > 0 && !o.DryRun && (o.RunTests || o.RunBenchmarks || o.ExecuteAfterBuild || o.Shell)
+}
+
+// StartServices starts the Services on a network the build container joins:
+// a new one, or the Network the build already uses. It sets opts.Network and
+// waits until the services with a health check are healthy. The returned
+// function removes the services and the network it created.
+func StartServices(opts *DockerBuildOptions) (func(), error) {
+	switch opts.Network {
+	case "host", "none":
+		return nil, fmt.Errorf("services need a container network; they cannot be used with network '%s'", opts.Network)
+	}
+	prefix := fmt.Sprintf("cpx-%s-%d", containerNameInvalid.ReplaceAllString(opts.TargetName, "-"), os.Getpid())
+	network := opts.Network
+	created := network == "" || network == "bridge"
+	if created {
+		// The default bridge network has no DNS for service names
+		network = prefix
+		if out, err := opts.Endpoint.Command("network", "create", network).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("failed to create network %s: %s", network, strings.TrimSpace(string(out)))
+		}
+	}
+
+	var started []string
+	stop := func() {
+		if len(started) > 0 {
+			_ = opts.Endpoint.Command(append([]string{"rm", "-f"}, started...)...).Run()
+		}
+		if created {
+			_ = opts.Endpoint.Command("network", "rm", network).Run()
+		}
+	}
+	for _, svc := range opts.Services {
+		name := prefix + "-" + containerNameInvalid.ReplaceAllString(svc.Name, "-")
+		fmt.Fprintf(opts.Stdout(), "  %s Starting service %s (%s)...%s\n", colors.Cyan, svc.Name, svc.Image, colors.Reset)
+		if out, err := opts.Endpoint.Command(ServiceRunArgs(network, name, svc)...).CombinedOutput(); err != nil {
+			stop()
+			return nil, fmt.Errorf("failed to start service %s: %s", svc.Name, strings.TrimSpace(string(out)))
+		}
+		started = append(started, name)
+	}
+	for i, name := range started {
+		if err := waitHealthy(opts.Endpoint, name); err != nil {
+			stop()
+			return nil, fmt.Errorf("service %s %w", opts.Services[i].Name, err)
+		}
+	}
+
+	opts.Network = network
+	return stop, nil
+}
+
+// ServiceRunArgs returns the docker run arguments starting a service as
+// container name on network, where the build container reaches it by the
+// service's name.
+func ServiceRunArgs(network, name string, svc Service) []string {
+	args := []string{"run", "-d", "--name", name, "--network", network, "--network-alias", svc.Name}
+	keys := make([]string, 0, len(svc.Env))
+	for k := range svc.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-e", k+"="+svc.Env[k])
+	}
+	for _, port := range svc.Ports {
+		args = append(args, "-p", port)
+	}
+	if svc.HealthCmd != "" {
+		args = append(args, "--health-cmd", svc.HealthCmd, "--health-interval", "1s")
+	}
+	args = append(args, svc.Image)
+	return append(args, svc.Command...)
+}
+
+// waitHealthy waits until a service container with a health check is
+// healthy. It fails, with the container's last output, when the container
+// stops or turns unhealthy.
+func waitHealthy(endpoint docker.Endpoint, name string) error {
+	deadline := time.Now().Add(serviceHealthTimeout)
+	for {
+		out, err := endpoint.Command("inspect", "-f", "{{.State.Status}} {{if .State.Health}}{{.State.Health.Status}}{{end}}", name).Output()
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+		state, health, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+		var problem string
+		switch {
+		case state != "running" && state != "created":
+			problem = state
+		case health == "unhealthy":
+			problem = "unhealthy"
+		case state == "running" && (health == "" || health == "healthy"):
+			return nil
+		case time.Now().After(deadline):
+			problem = fmt.Sprintf("not healthy after %s", serviceHealthTimeout)
+		}
+		if problem != "" {
+			logs, _ := endpoint.Command("logs", "--tail", "20", name).CombinedOutput()
+			return fmt.Errorf("%s:\n%s", problem, strings.TrimSpace(string(logs)))
+		}
+		time.Sleep(time.Second)
+	}
+}
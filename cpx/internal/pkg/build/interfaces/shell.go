@@ -0,0 +1,65 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/remote"
+)
+
+// ShellScript returns the script a Shell container runs instead of the build:
+// setup (the build's environment exports), then command, or an interactive
+// bash without one. The bash prints the build commands and has them in its
+// history, so the failing step can be rerun with the up arrow.
+func ShellScript(setup string, commands, command []string) string {
+	var s strings.Builder
+	s.WriteString(setup)
+	if len(command) > 0 {
+		fmt.Fprintf(&s, "exec %s\n", remote.QuoteAll(command))
+		return s.String()
+	}
+	s.WriteString("echo \"cpx: build container shell; the build runs:\"\n")
+	for _, c := range commands {
+		fmt.Fprintf(&s, "echo %s\n", remote.Quote("  "+c))
+		fmt.Fprintf(&s, "echo %s >> ~/.bash_history\n", remote.Quote(c))
+	}
+	s.WriteString("exec bash -i\n")
+	return s.String()
+}
+
+// RunShell runs the docker command of a Shell container attached to the
+// terminal (its output only, with NoStdin), the output of a ShellCommand
+// going to the build's writers. A ShellCommand that fails is an error, an
+// ExitError when it exited with a status of its own; the exit status of the
+// last command typed in an interactive bash is not, unlike docker's own
+// failures (125 and up).
+func RunShell(opts DockerBuildOptions, dockerArgs []string) error {
+	cmd := opts.Endpoint.Command(dockerArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if opts.NoStdin {
+		cmd.Stdin = nil
+	}
+	if len(opts.ShellCommand) > 0 {
+		cmd.Stdout, cmd.Stderr = opts.Stdout(), opts.Stderr()
+	}
+	err := cmd.Run()
+	FlushOutput(cmd.Stdout, cmd.Stderr)
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case len(opts.ShellCommand) > 0:
+		if errors.As(err, &exitErr) && exitErr.ExitCode() < 125 {
+			err = ProgramExit(err)
+		}
+		return fmt.Errorf("%s failed: %w", opts.ShellCommand[0], err)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() < 125:
+		return nil
+	default:
+		return fmt.Errorf("docker shell failed: %w", err)
+	}
+}
@@ -0,0 +1,37 @@
+package build
+
+import "fmt"
+
+// TestResultsDir is the directory in a target's output directory receiving
+// the test result XML (JUnit) of Docker builds that run tests.
+const TestResultsDir = "test-results"
+
+// BenchResultsDir is the directory in a target's output directory receiving
+// the Google Benchmark JSON results of Docker builds that run benchmarks.
+const BenchResultsDir = "bench-results"
+
+// ResultsScript returns the build script lines that empty the container
+// directory receiving the results of target, /output/<target>/<dir>.
+func ResultsScript(target, dir string) string {
+	return fmt.Sprintf("rm -rf /output/%[1]s/%[2]s && mkdir -p /output/%[1]s/%[2]s\n", target, dir)
+}
+
+// BenchOutEnv returns the environment assignments, prefixed to a benchmark
+// command, making Google Benchmark write its JSON results to BenchResultsDir
+// of target, as <name>.json. Unlike --benchmark_out arguments, other
+// benchmark frameworks and plain executables ignore them. name may be a shell
+// expression, e.g. "$(basename "$bench")".
+func BenchOutEnv(target, name string) string {
+	return fmt.Sprintf(`BENCHMARK_OUT="/output/%s/%s/%s.json" BENCHMARK_OUT_FORMAT=json`, target, BenchResultsDir, name)
+}
+
+// CollectTestsScript returns the build script lines that run the test
+// command, then collect its result files, even when tests fail. The script
+// still fails with the tests' exit status.
+func CollectTestsScript(command, collect string) string {
+	return fmt.Sprintf(`cpx_test_status=0
+%s || cpx_test_status=$?
+%s
+[ "$cpx_test_status" -eq 0 ] || exit "$cpx_test_status"
+`, command, collect)
+}
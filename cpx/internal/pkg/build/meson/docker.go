@@ -3,9 +3,9 @@ package meson
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -15,6 +15,10 @@ import (
 
 // RunDockerBuild implements the DockerBuilder interface for Meson builds.
 func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOptions) error {
+	if opts.CrossTarget != "" && !opts.Shell {
+		return fmt.Errorf("target preset '%s' configures CMake and vcpkg and is not supported for Meson builds; use a runner with a Meson cross file in its image instead", opts.CrossTarget)
+	}
+
 	absProjectRoot, err := filepath.Abs(opts.ProjectRoot)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path for project root: %w", err)
@@ -30,7 +34,7 @@ func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOpti
 	}
 
 	// Create persistent build directory
-	hostBuildDir := filepath.Join(opts.ProjectRoot, ".cache", "ci", opts.TargetName)
+	hostBuildDir := filepath.Join(opts.CacheRoot(), opts.TargetName)
 	if err := os.MkdirAll(hostBuildDir, 0755); err != nil {
 		return fmt.Errorf("failed to create build directory: %w", err)
 	}
@@ -39,12 +43,6 @@ func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOpti
 		return fmt.Errorf("failed to get absolute path for build directory: %w", err)
 	}
 
-	// Determine build type
-	buildType := "release"
-	if opts.BuildType == "Debug" || opts.BuildType == "debug" {
-		buildType = "debug"
-	}
-
 	// Create subprojects directory
 	hostSubprojectsDir := filepath.Join(opts.ProjectRoot, "subprojects")
 	if err := os.MkdirAll(hostSubprojectsDir, 0755); err != nil {
@@ -64,9 +62,15 @@ func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOpti
 		}
 	}
 
-	// Build Meson arguments
-	setupArgs := []string{"--buildtype=" + buildType}
-	setupArgs = append(setupArgs, opts.MesonArgs...)
+	// Meson picks up ccache and sccache from PATH by itself; their caches
+	// live in the persistent build directory
+	if opts.SCCache != nil {
+		envExports += build.SCCacheScript(opts.SCCache, "/tmp/builddir/.sccache", "/tmp/builddir/.sccache-bin")
+	} else if opts.CCache {
+		envExports += build.CCacheScript("/tmp/builddir/.ccache")
+	}
+
+	setupArgs := dockerSetupArgs(opts)
 
 	// Detect project name
 	projectName := GetProjectNameFromMesonBuild(opts.ProjectRoot)
@@ -77,29 +81,29 @@ func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOpti
 	testSection := ""
 	if opts.RunTests {
 		testSection = fmt.Sprintf(`
+cpx_phase test
 echo "  Running tests..."
-meson test -C /tmp/builddir -v "%s:"
-`, projectName)
+%s%s`, build.ResultsScript(opts.TargetName, build.TestResultsDir), build.CollectTestsScript(
+			fmt.Sprintf(`meson test -C /tmp/builddir -v "%s:"`, projectName),
+			fmt.Sprintf("cp /tmp/builddir/meson-logs/testlog.junit.xml /output/%s/%s/meson.xml 2>/dev/null || true", opts.TargetName, build.TestResultsDir)))
 	}
 
 	benchSection := ""
 	if opts.RunBenchmarks {
 		benchSection = fmt.Sprintf(`
+cpx_phase bench
 echo "  Running benchmarks..."
-meson test -C /tmp/builddir --benchmark -v "%s:" || true
+%[2]smeson test -C /tmp/builddir --benchmark -v "%[1]s:" || true
 # Also run any manually built benchmark binaries
 for bench in $(find /tmp/builddir -maxdepth 2 -type f -executable -name "*_bench" 2>/dev/null); do
     echo "  Running $bench..."
-    $bench
+    %[3]s $bench
 done
-`, projectName)
+`, projectName, build.ResultsScript(opts.TargetName, build.BenchResultsDir), build.BenchOutEnv(opts.TargetName, `$(basename "$bench")`))
 	}
 
 	// Handle verbosity
-	mesonQuiet := ""
-	if !opts.Verbose {
-		mesonQuiet = " > /dev/null 2>&1"
-	}
+	mesonQuiet := opts.QuietRedirect()
 
 	setupEcho := "echo \"  Configuring Meson...\""
 	buildEcho := "echo \"  Building...\""
@@ -115,6 +119,13 @@ done
 		isVerbose = "true"
 	}
 
+	ccacheStats := ""
+	if opts.SCCache != nil {
+		ccacheStats = build.SCCacheStatsScript()
+	} else if opts.CCache && opts.Verbose {
+		ccacheStats = build.CCacheStatsScript()
+	}
+
 	runSection := ""
 	if opts.ExecuteAfterBuild {
 		runSection = `
@@ -152,7 +163,7 @@ cd - > /dev/null
 	// Arguments for fmt.Sprintf in order of appearance (or referenced by index)
 	// 1: envExports
 	// 2: setupEcho
-	// 3: setupArgs
+	// 3: setup script
 	// 4: mesonQuiet
 	// 5: isVerbose
 	// 6: buildEcho
@@ -163,18 +174,20 @@ cd - > /dev/null
 	// 11: runSection
 	// 12: buildCompleteEcho
 	// 13: projectName
+	// 14: ccacheStats
+	// 15: phaseScript
+	// 16: compileJobs
 	buildScript := fmt.Sprintf(`#!/bin/bash
 set -e
-%[1]s
+%[15]s%[1]s
 mkdir -p /tmp/builddir
+cpx_phase configure
 %[2]s
-if [ ! -f /tmp/builddir/build.ninja ]; then
-    meson setup /tmp/builddir %[3]s%[4]s
-else
-    if [ "%[5]s" = "true" ]; then echo "  Build directory already configured, skipping setup."; fi
-fi
+%[3]s
+cpx_phase build
 %[6]s
-meson compile -C /tmp/builddir%[4]s
+meson compile -C /tmp/builddir%[16]s%[4]s
+%[14]scpx_phase copy
 %[7]s
 mkdir -p /output/%[8]s
 # Recursive find excluding internal dirs
@@ -183,35 +196,160 @@ find /tmp/builddir -maxdepth 3 -type f -perm /111 ! -path "*/meson-*" ! -path "*
 find /tmp/builddir -maxdepth 3 -type f \( -name "*.a" -o -name "*.so" -o -name "*.dylib" \) ! -path "*/meson-*" -exec cp {} /output/%[8]s/ \; 2>/dev/null || true
 if [ "%[5]s" = "true" ]; then ls -la /output/%[8]s/ 2>/dev/null || echo "  (no artifacts found)"; fi
 %[12]s
-%[9]s%[10]s%[11]s
-`, envExports, setupEcho, strings.Join(setupArgs, " "), mesonQuiet, isVerbose, buildEcho, copyEcho, opts.TargetName, testSection, benchSection, runSection, buildCompleteEcho, projectName)
+%[9]s%[10]scpx_phase end
+%[11]s
+`, envExports, setupEcho, dockerSetupScript(setupArgs, mesonQuiet, opts.Verbose), mesonQuiet, isVerbose, buildEcho, copyEcho, opts.TargetName, testSection, benchSection, runSection, buildCompleteEcho, projectName, ccacheStats, build.PhaseScript("/tmp/builddir"), compileJobs(opts))
 
-	fmt.Printf("  %s Running Meson build in Docker container...%s\n", colors.Cyan, colors.Reset)
+	if opts.Shell {
+		buildScript = build.ShellScript(envExports, b.DescribeDockerBuild(opts), opts.ShellCommand)
+	}
 
-	dockerArgs := []string{"run", "--rm"}
+	dockerArgs := append([]string{"run", "--rm"}, opts.RunArgs()...)
+	dockerArgs = append(dockerArgs, opts.ShellArgs()...)
 	if opts.Platform != "" {
 		dockerArgs = append(dockerArgs, "--platform", opts.Platform)
 	}
 
 	dockerArgs = append(dockerArgs,
-		"-v", absProjectRoot+":/workspace:ro",
-		"-v", absBuildDir+":/tmp/builddir",
-		"-v", absSubprojectsDir+":/workspace/subprojects",
-		"-v", absOutputDir+":/output",
-		"-w", "/workspace",
-		opts.ImageName,
-		"bash", "-c", buildScript)
-
-	cmd := exec.Command("docker", dockerArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+		"-v", opts.Endpoint.Mount(absProjectRoot, "/workspace", true),
+		"-v", opts.Endpoint.Mount(absBuildDir, "/tmp/builddir", false),
+		"-v", opts.Endpoint.Mount(absSubprojectsDir, "/workspace/subprojects", false),
+		"-v", opts.Endpoint.Mount(absOutputDir, "/output", false),
+		"-w", "/workspace")
+	dockerArgs = append(dockerArgs, opts.EnvArgs()...)
+	dockerArgs = append(dockerArgs, opts.ImageName, "bash", "-c", buildScript)
+	if opts.DryRun {
+		build.FprintDockerCommand(opts.Stdout(), opts.Endpoint, dockerArgs)
+		return nil
+	}
 
-	if err := cmd.Run(); err != nil {
+	if opts.Shell {
+		return build.RunShell(opts, dockerArgs)
+	}
+
+	fmt.Fprintf(opts.Stdout(), "  %s Running Meson build in Docker container...%s\n", colors.Cyan, colors.Reset)
+	cmd := opts.Endpoint.Command(dockerArgs...)
+	cmd.Stdout, cmd.Stderr = opts.Streams()
+
+	if err := build.RunContainer(ctx, opts, cmd); err != nil {
 		return fmt.Errorf("docker meson build failed: %w", err)
 	}
 
+	// Remote builds are audited once their artifacts are downloaded
+	if opts.Hardening && !opts.Endpoint.Remote {
+		if err := build.FprintHardeningAudit(opts.Stdout(), filepath.Join(absOutputDir, opts.TargetName)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PrefetchDockerDependencies downloads the project's wrap subprojects into
+// the shared subprojects directory without configuring the build.
+func (b *Builder) PrefetchDockerDependencies(ctx context.Context, opts build.DockerBuildOptions) error {
+	absProjectRoot, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+	absSubprojectsDir := filepath.Join(absProjectRoot, "subprojects")
+	if err := os.MkdirAll(absSubprojectsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create subprojects directory: %w", err)
+	}
+
+	dockerArgs := append([]string{"run", "--rm"}, opts.RunArgs()...)
+	if opts.Platform != "" {
+		dockerArgs = append(dockerArgs, "--platform", opts.Platform)
+	}
+	dockerArgs = append(dockerArgs,
+		"-v", opts.Endpoint.Mount(absProjectRoot, "/workspace", true),
+		"-v", opts.Endpoint.Mount(absSubprojectsDir, "/workspace/subprojects", false),
+		"-w", "/workspace")
+	dockerArgs = append(dockerArgs, opts.EnvArgs()...)
+	dockerArgs = append(dockerArgs, opts.ImageName, "meson", "subprojects", "download")
+
+	cmd := opts.Endpoint.Command(dockerArgs...)
+	if opts.Verbose {
+		cmd.Stdout = opts.Stdout()
+	}
+	cmd.Stderr = opts.Stderr()
+	defer build.FlushOutput(cmd.Stdout, cmd.Stderr)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("meson subprojects download failed: %w", err)
+	}
 	return nil
 }
 
-// Compile-time check that Builder implements DockerBuilder
-var _ build.DockerBuilder = (*Builder)(nil)
+// DescribeDockerBuild returns the Meson setup and compile command lines
+// RunDockerBuild executes inside the container.
+func (b *Builder) DescribeDockerBuild(opts build.DockerBuildOptions) []string {
+	return []string{
+		"meson setup /tmp/builddir " + strings.Join(dockerSetupArgs(opts), " "),
+		"meson compile -C /tmp/builddir" + compileJobs(opts),
+	}
+}
+
+// compileJobs returns the meson compile flag limiting parallel jobs, if any
+func compileJobs(opts build.DockerBuildOptions) string {
+	if opts.Jobs <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" -j %d", opts.Jobs)
+}
+
+// dockerSetupArgs returns the `meson setup` arguments for a Docker build
+func dockerSetupArgs(opts build.DockerBuildOptions) []string {
+	// Determine build type
+	buildType := "release"
+	if opts.BuildType == "Debug" || opts.BuildType == "debug" {
+		buildType = "debug"
+	}
+
+	// Build Meson arguments
+	setupArgs := []string{"--buildtype=" + buildType}
+	if opts.Hardening {
+		// Containers always build with GCC/Clang
+		compile, link := build.HardeningFlags(false, buildType == "release")
+		// Double-quoted so bash keeps the single quotes of the Meson arrays
+		for _, opt := range []string{"c_args", "cpp_args"} {
+			setupArgs = append(setupArgs, fmt.Sprintf(`"-D%s=%s"`, opt, mesonArray(compile)))
+		}
+		for _, opt := range []string{"c_link_args", "cpp_link_args"} {
+			setupArgs = append(setupArgs, fmt.Sprintf(`"-D%s=%s"`, opt, mesonArray(link)))
+		}
+	}
+	setupArgs = append(setupArgs, opts.MesonArgs...)
+	return setupArgs
+}
+
+// setupStampFile records the setup arguments of a configured Docker build directory
+const setupStampFile = ".cpx-setup"
+
+// dockerSetupScript returns the script lines configuring /tmp/builddir. A
+// directory configured with other arguments is emptied first, so options such
+// as the hardening flags do not outlive the build that asked for them; the
+// hidden compiler caches and phase file are kept.
+func dockerSetupScript(setupArgs []string, quiet string, verbose bool) string {
+	args := strings.Join(setupArgs, " ")
+	stamp := fmt.Sprintf("%x", sha256.Sum256([]byte(args)))[:16]
+	skipped := ":"
+	if verbose {
+		skipped = `echo "  Build directory already configured, skipping setup."`
+	}
+	return fmt.Sprintf(`if [ -f /tmp/builddir/build.ninja ] && [ "$(cat /tmp/builddir/%[1]s 2>/dev/null)" != "%[2]s" ]; then
+    find /tmp/builddir -mindepth 1 -maxdepth 1 ! -name ".*" -exec rm -rf {} +
+fi
+if [ ! -f /tmp/builddir/build.ninja ]; then
+    meson setup /tmp/builddir %[3]s%[4]s
+    echo "%[2]s" > /tmp/builddir/%[1]s
+else
+    %[5]s
+fi`, setupStampFile, stamp, args, quiet, skipped)
+}
+
+// Compile-time checks that Builder implements DockerBuilder and DockerBuildDescriber
+var (
+	_ build.DockerBuilder        = (*Builder)(nil)
+	_ build.DockerBuildDescriber = (*Builder)(nil)
+	_ build.DockerPrefetcher     = (*Builder)(nil)
+)
@@ -75,6 +75,9 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 	if opts.Sanitizer != "" {
 		optLabel += "+" + opts.Sanitizer
 	}
+	if opts.Hardening {
+		optLabel += "+hardened"
+	}
 
 	// Clean if requested
 	if opts.Clean {
@@ -89,10 +92,7 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 		setupArgs := []string{"setup", buildDir}
 		setupArgs = append(setupArgs, "--buildtype="+buildType)
 		setupArgs = append(setupArgs, "--optimization="+optimization)
-		if opts.OptLevel == "fast" {
-			// Add -ffast-math for -Ofast equivalent
-			setupArgs = append(setupArgs, "-Dc_args=-ffast-math", "-Dcpp_args=-ffast-math")
-		}
+		setupArgs = append(setupArgs, compilerArgs(opts)...)
 		setupCmd := execCommand("meson", setupArgs...)
 		setupCmd.Stdout = os.Stdout
 		setupCmd.Stderr = os.Stderr
@@ -105,9 +105,7 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 		reconfigArgs := []string{"configure", buildDir}
 		reconfigArgs = append(reconfigArgs, "--buildtype="+buildType)
 		reconfigArgs = append(reconfigArgs, "--optimization="+optimization)
-		if opts.OptLevel == "fast" {
-			reconfigArgs = append(reconfigArgs, "-Dc_args=-ffast-math", "-Dcpp_args=-ffast-math")
-		}
+		reconfigArgs = append(reconfigArgs, compilerArgs(opts)...)
 		reconfigCmd := execCommand("meson", reconfigArgs...)
 		reconfigCmd.Stdout = os.Stdout
 		reconfigCmd.Stderr = os.Stderr
@@ -139,6 +137,9 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 	} else if opts.Release {
 		outDirName = "release"
 	}
+	if opts.Hardening {
+		outDirName += "-hardened"
+	}
 	outputDir := filepath.Join(".bin", "native", outDirName)
 
 	// Copy artifacts to output directory
@@ -169,9 +170,50 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 
 	fmt.Printf("%s✓ Build successful%s\n", colors.Green, colors.Reset)
 	fmt.Printf("  Artifacts in: %s/\n", outputDir)
+	if opts.Hardening {
+		if err := build.PrintHardeningAudit(outputDir); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// compilerArgs returns the -Dc_args/-Dcpp_args/-Dc_link_args options for the
+// requested optimization level and hardening
+func compilerArgs(opts build.BuildOptions) []string {
+	var compile, link []string
+	if opts.OptLevel == "fast" {
+		// Add -ffast-math for -Ofast equivalent
+		compile = append(compile, "-ffast-math")
+	}
+	if opts.Hardening {
+		hardCompile, hardLink := build.HardeningFlags(build.UsesMSVCFlags(), opts.Optimized())
+		compile = append(compile, hardCompile...)
+		link = append(link, hardLink...)
+	}
+
+	var args []string
+	if len(compile) > 0 {
+		arr := mesonArray(compile)
+		args = append(args, "-Dc_args="+arr, "-Dcpp_args="+arr)
+	}
+	if len(link) > 0 {
+		arr := mesonArray(link)
+		args = append(args, "-Dc_link_args="+arr, "-Dcpp_link_args="+arr)
+	}
+	return args
+}
+
+// mesonArray formats values as a Meson array option so flags containing
+// commas (-Wl,-z,relro) are not split
+func mesonArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	return "[" + strings.Join(quoted, ",") + "]"
+}
+
 // Test runs the project's tests with the given options.
 func (b *Builder) Test(ctx context.Context, opts build.TestOptions) error {
 	fmt.Printf("%sRunning Meson tests...%s\n", colors.Cyan, colors.Reset)
@@ -281,7 +323,7 @@ func (b *Builder) Run(ctx context.Context, opts build.RunOptions) error {
 	runCmd.Stderr = os.Stderr
 	runCmd.Stdin = os.Stdin
 
-	return runCmd.Run()
+	return build.ProgramExit(runCmd.Run())
 }
 
 // Bench runs the project's benchmarks.
@@ -6,6 +6,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"testing"
 
 	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
@@ -171,6 +173,33 @@ func TestRun(t *testing.T) {
 	assert.Equal(t, "builddir/src/myapp", lastCmd[0])
 }
 
+func TestRunExitStatus(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		if name == filepath.Join("builddir", "src", "myapp") {
+			return exec.Command("sh", "-c", "exit 3")
+		}
+		cs := append([]string{"-test.run=TestHelperProcess", "--", name}, arg...)
+		cmd := exec.Command(os.Args[0], cs...)
+		cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
+		return cmd
+	}
+
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("meson.build", []byte("project('test', 'cpp')"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join("builddir", "src"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join("builddir", "src", "myapp"), []byte(""), 0755))
+
+	err := New().Run(context.Background(), build.RunOptions{Target: "myapp", Verbose: true})
+	var exitErr *build.ExitError
+	require.ErrorAs(t, err, &exitErr, "the executable's status is not a build failure")
+	assert.Equal(t, 3, exitErr.Code)
+}
+
 func TestTest(t *testing.T) {
 	// Mock execCommand
 	oldExecCommand := execCommand
@@ -426,3 +455,50 @@ func TestListTargets(t *testing.T) {
 	assert.Contains(t, targets, "myapp (executable)")
 	assert.Contains(t, targets, "mylib (shared library)")
 }
+
+func TestCompilerArgs(t *testing.T) {
+	// The hardening flags follow the compiler, not the host
+	t.Setenv("CXX", "g++")
+	tests := []struct {
+		name     string
+		opts     build.BuildOptions
+		expected []string
+	}{
+		{"Default", build.BuildOptions{}, nil},
+		{"Ofast", build.BuildOptions{OptLevel: "fast"}, []string{"-Dc_args=['-ffast-math']", "-Dcpp_args=['-ffast-math']"}},
+		{"Hardening", build.BuildOptions{Hardening: true}, []string{
+			"-Dc_args=['-fstack-protector-strong','-fPIE']",
+			"-Dcpp_args=['-fstack-protector-strong','-fPIE']",
+			"-Dc_link_args=['-pie','-Wl,-z,relro,-z,now']",
+			"-Dcpp_link_args=['-pie','-Wl,-z,relro,-z,now']",
+		}},
+		{"HardeningRelease", build.BuildOptions{Release: true, Hardening: true}, []string{
+			"-Dc_args=['-D_FORTIFY_SOURCE=2','-fstack-protector-strong','-fPIE']",
+			"-Dcpp_args=['-D_FORTIFY_SOURCE=2','-fstack-protector-strong','-fPIE']",
+			"-Dc_link_args=['-pie','-Wl,-z,relro,-z,now']",
+			"-Dcpp_link_args=['-pie','-Wl,-z,relro,-z,now']",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, compilerArgs(tt.opts))
+		})
+	}
+}
+
+func TestDockerSetupScript(t *testing.T) {
+	plain := dockerSetupScript(dockerSetupArgs(build.DockerBuildOptions{}), "", false)
+	hardened := dockerSetupScript(dockerSetupArgs(build.DockerBuildOptions{Hardening: true}), "", false)
+	assert.Contains(t, plain, "meson setup /tmp/builddir --buildtype=release\n")
+	assert.Contains(t, hardened, `"-Dc_args=['-D_FORTIFY_SOURCE=2','-fstack-protector-strong','-fPIE']"`)
+
+	// A build directory configured with other arguments is set up afresh,
+	// keeping the hidden caches
+	stamp := func(script string) string {
+		return regexp.MustCompile(`echo "(\w+)" > /tmp/builddir/\.cpx-setup`).FindStringSubmatch(script)[1]
+	}
+	assert.NotEqual(t, stamp(plain), stamp(hardened))
+	assert.Contains(t, plain, `!= "`+stamp(plain)+`" ]; then
+    find /tmp/builddir -mindepth 1 -maxdepth 1 ! -name ".*" -exec rm -rf {} +`)
+}
@@ -0,0 +1,95 @@
+// Package remote provides helpers for building on remote hosts over SSH.
+package remote
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var execCommand = exec.Command
+
+// Host is an SSH build host.
+type Host struct {
+	// Address is the host name or IP.
+	Address string
+
+	// User is the login user; empty uses the SSH config default.
+	User string
+
+	// Port is the SSH port; 0 uses the SSH config default.
+	Port int
+
+	// IdentityFile is the private key to authenticate with (optional).
+	IdentityFile string
+}
+
+// Destination returns user@host, or host when no user is set.
+func (h Host) Destination() string {
+	if h.User == "" {
+		return h.Address
+	}
+	return h.User + "@" + h.Address
+}
+
+// sshOptions returns the options shared by ssh and rsync's remote shell.
+// BatchMode makes a missing key fail instead of prompting for a password.
+func (h Host) sshOptions() []string {
+	opts := []string{"-o", "BatchMode=yes"}
+	if h.Port != 0 {
+		opts = append(opts, "-p", fmt.Sprintf("%d", h.Port))
+	}
+	if h.IdentityFile != "" {
+		opts = append(opts, "-i", h.IdentityFile)
+	}
+	return opts
+}
+
+// Command creates an ssh command that runs script with bash on the host.
+func (h Host) Command(script string) *exec.Cmd {
+	args := append(h.sshOptions(), h.Destination(), "bash -lc "+Quote(script))
+	return execCommand("ssh", args...)
+}
+
+// Push creates an rsync command that mirrors localDir into remoteDir,
+// skipping the given exclude patterns.
+func (h Host) Push(localDir, remoteDir string, excludes ...string) *exec.Cmd {
+	args := h.rsyncArgs()
+	args = append(args, "--delete")
+	for _, e := range excludes {
+		args = append(args, "--exclude", e)
+	}
+	args = append(args, filepath.ToSlash(localDir)+"/", h.Destination()+":"+remoteDir+"/")
+	return execCommand("rsync", args...)
+}
+
+// Pull creates an rsync command that copies remoteDir into localDir.
+func (h Host) Pull(remoteDir, localDir string) *exec.Cmd {
+	args := append(h.rsyncArgs(), h.Destination()+":"+remoteDir+"/", filepath.ToSlash(localDir)+"/")
+	return execCommand("rsync", args...)
+}
+
+func (h Host) rsyncArgs() []string {
+	shell := append([]string{"ssh"}, h.sshOptions()...)
+	return []string{"-az", "-e", strings.Join(shell, " ")}
+}
+
+// Quote quotes s for a POSIX shell.
+func Quote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:,+@%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// QuoteAll quotes each argument and joins them with spaces.
+func QuoteAll(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = Quote(a)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,48 @@
+package remote
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"-DCMAKE_BUILD_TYPE=Release", "-DCMAKE_BUILD_TYPE=Release"},
+		{"", "''"},
+		{"-O2 -fPIE", "'-O2 -fPIE'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Quote(tt.in), tt.in)
+	}
+	assert.Equal(t, "cmake '-DFLAGS=-O2 -g'", QuoteAll([]string{"cmake", "-DFLAGS=-O2 -g"}))
+}
+
+func TestHostCommands(t *testing.T) {
+	var got [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		got = append(got, append([]string{name}, args...))
+		return exec.Command("true")
+	}
+	t.Cleanup(func() { execCommand = exec.Command })
+
+	h := Host{Address: "pi.local", User: "ci", Port: 2222, IdentityFile: "/keys/id"}
+	assert.Equal(t, "ci@pi.local", h.Destination())
+	assert.Equal(t, "pi.local", Host{Address: "pi.local"}.Destination())
+
+	h.Command("echo hi")
+	h.Push("/src/proj", ".cache/cpx/proj/src", ".git")
+	h.Pull(".cache/cpx/proj/out/arm", "/src/proj/.bin/ci/arm")
+
+	assert.Equal(t, []string{"ssh", "-o", "BatchMode=yes", "-p", "2222", "-i", "/keys/id", "ci@pi.local", "bash -lc 'echo hi'"}, got[0])
+	assert.Equal(t, []string{"rsync", "-az", "-e", "ssh -o BatchMode=yes -p 2222 -i /keys/id", "--delete", "--exclude", ".git",
+		"/src/proj/", "ci@pi.local:.cache/cpx/proj/src/"}, got[1])
+	assert.Equal(t, []string{"rsync", "-az", "-e", "ssh -o BatchMode=yes -p 2222 -i /keys/id",
+		"ci@pi.local:.cache/cpx/proj/out/arm/", "/src/proj/.bin/ci/arm/"}, got[2])
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -28,19 +27,8 @@ func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOpti
 		isExe = true // default to executable
 	}
 
-	// Determine build type
-	buildType := opts.BuildType
-	if buildType == "" {
-		buildType = "Release"
-	}
-
-	optLevel := opts.Optimization
-	if optLevel == "" {
-		optLevel = "2"
-	}
-
 	// Create a persistent build directory for this target
-	hostBuildDir := filepath.Join(opts.ProjectRoot, ".cache", "ci", opts.TargetName)
+	hostBuildDir := filepath.Join(opts.CacheRoot(), opts.TargetName)
 	if err := os.MkdirAll(hostBuildDir, 0755); err != nil {
 		return fmt.Errorf("failed to create build directory: %w", err)
 	}
@@ -52,43 +40,10 @@ func (b *Builder) RunDockerBuild(ctx context.Context, opts build.DockerBuildOpti
 
 	containerBuildDir := "/tmp/build"
 
-	// Build CMake arguments
-	cmakeArgs := []string{
-		"-GNinja",
-		"-B", containerBuildDir,
-		"-S", "/workspace",
-		"-DCMAKE_BUILD_TYPE=" + buildType,
-		"-DCMAKE_TOOLCHAIN_FILE=/opt/vcpkg/scripts/buildsystems/vcpkg.cmake",
-	}
-
-	if opts.RunTests {
-		cmakeArgs = append(cmakeArgs, "-DBUILD_TESTING=ON", "-DENABLE_TESTING=ON")
-	}
-
-	if opts.RunBenchmarks {
-		cmakeArgs = append(cmakeArgs, "-DENABLE_BENCHMARKS=ON")
-	}
-
-	cmakeArgs = append(cmakeArgs, "-DCMAKE_CXX_FLAGS=-O"+optLevel)
-	cmakeArgs = append(cmakeArgs, "-DVCPKG_DISABLE_REGISTRY_UPDATE=ON")
-	cmakeArgs = append(cmakeArgs, opts.CMakeArgs...)
-
-	// Build command arguments
-	buildArgs := []string{"--build", containerBuildDir, "--config", buildType}
-	if opts.Jobs > 0 {
-		buildArgs = append(buildArgs, "--parallel", fmt.Sprintf("%d", opts.Jobs))
-	}
-	buildArgs = append(buildArgs, opts.BuildArgs...)
+	cmakeArgs, buildArgs := dockerCMakeArgs(opts, containerBuildDir)
 
 	// Get project name
-	projectName := cmake.GetProjectNameFromCMakeLists()
-	if projectName == "" {
-		projectName = filepath.Base(opts.ProjectRoot)
-	}
-
-	if opts.RunBenchmarks {
-		buildArgs = append(buildArgs, "--target", "all", projectName+"_bench")
-	}
+	projectName := dockerProjectName(opts)
 
 	// Determine artifact copying
 	var copyCommand string
@@ -99,6 +54,28 @@ find %s -maxdepth 2 -type f \( -name "lib*.a" -o -name "lib*.so" -o -name "lib*.
 		copyCommand = fmt.Sprintf(`find %s -maxdepth 2 -type f \( -name "lib*.a" -o -name "lib*.so" -o -name "lib*.dylib" \) ! -path "*/CMakeFiles/*" -exec cp {} /output/%s/ \; 2>/dev/null || true`, containerBuildDir, opts.TargetName)
 	}
 
+	if len(opts.ArtifactPatterns) > 0 {
+		names := make([]string, len(opts.ArtifactPatterns))
+		for i, pattern := range opts.ArtifactPatterns {
+			names[i] = fmt.Sprintf("-name %q", pattern)
+		}
+		copyCommand += fmt.Sprintf(`
+find %s -maxdepth 2 -type f \( %s \) ! -path "*/CMakeFiles/*" -exec cp {} /output/%s/ \; 2>/dev/null || true`, containerBuildDir, strings.Join(names, " -o "), opts.TargetName)
+	}
+
+	if opts.VerifyStatic {
+		// ldd fails on static executables; any listed dependency fails the build
+		copyCommand += fmt.Sprintf(`
+echo " Verifying static linkage..."
+for f in $(find /output/%s -maxdepth 1 -type f -perm /111); do
+    if deps=$(ldd "$f" 2>&1); then
+        echo "  $(basename "$f") is dynamically linked:"
+        echo "$deps"
+        exit 1
+    fi
+done`, opts.TargetName)
+	}
+
 	// Setup vcpkg cache directories
 	vcpkgCacheDir := filepath.Join(absBuildDir, ".vcpkg_cache")
 	for _, subdir := range []string{"installed", "downloads", "buildtrees", "binary"} {
@@ -125,33 +102,51 @@ find %s -maxdepth 2 -type f \( -name "lib*.a" -o -name "lib*.so" -o -name "lib*.
 		}
 	}
 
-	// Build script
-	vcpkgInstalledPath := "/tmp/.vcpkg_cache/installed"
-	vcpkgDownloadsPath := "/tmp/.vcpkg_cache/downloads"
-	vcpkgBuildtreesPath := "/tmp/.vcpkg_cache/buildtrees"
-	binaryCachePath := "/tmp/.vcpkg_cache/binary"
+	// Compiler cache, kept in the persistent build directory
+	cacheSetup := vcpkgCacheEnv + binarySourcesEnv(opts.BinarySources)
+	ccacheStats := ""
+	if opts.SCCache != nil {
+		cacheSetup += build.SCCacheScript(opts.SCCache, containerBuildDir+"/.sccache", containerBuildDir+"/.sccache-bin")
+		ccacheStats = build.SCCacheStatsScript()
+	} else if opts.CCache {
+		cacheSetup += build.CCacheScript(containerBuildDir + "/.ccache")
+		if opts.Verbose {
+			ccacheStats = build.CCacheStatsScript()
+		}
+	}
 
+	// Build script
 	testSection := ""
 	if opts.RunTests {
+		// CTest 3.21+ writes JUnit XML itself, even when tests fail
+		ctest := "ctest --output-on-failure $cpx_junit\n"
+		if opts.Coverage != "" {
+			// Coverage is collected from failed runs too
+			ctest = build.ResultsScript(opts.TargetName, build.CoverageResultsDir) + build.CoverageSetupScript(opts.Coverage, containerBuildDir) +
+				build.CollectTestsScript("ctest --output-on-failure $cpx_junit", build.CoverageCollectScript(opts.Coverage, containerBuildDir, opts.TargetName))
+		}
 		testSection = fmt.Sprintf(`
+cpx_phase test
 echo " Running tests..."
-cd %s
-ctest --output-on-failure
-cd - > /dev/null
-`, containerBuildDir)
+%[3]scd %[1]s
+cpx_junit=""
+if ctest --help | grep -q -- --output-junit; then cpx_junit="--output-junit /output/%[2]s/%[4]s/ctest.xml"; fi
+%[5]scd - > /dev/null
+`, containerBuildDir, opts.TargetName, build.ResultsScript(opts.TargetName, build.TestResultsDir), build.TestResultsDir, ctest)
 	}
 
 	benchSection := ""
 	if opts.RunBenchmarks {
 		benchSection = fmt.Sprintf(`
+cpx_phase bench
 echo " Running benchmarks..."
-cd %s
+%[2]scd %[1]s
 for bench in $(find . -maxdepth 2 -type f -executable -name "*_bench" 2>/dev/null); do
     echo "  Running $bench..."
-    $bench
+    %[3]s $bench
 done
 cd - > /dev/null
-`, containerBuildDir)
+`, containerBuildDir, build.ResultsScript(opts.TargetName, build.BenchResultsDir), build.BenchOutEnv(opts.TargetName, `$(basename "$bench")`))
 	}
 
 	// Execute after build section
@@ -182,22 +177,23 @@ cd - > /dev/null
 	// Determine final steps based on whether we run the executable
 	finalSteps := ""
 	if opts.ExecuteAfterBuild {
-		finalSteps = fmt.Sprintf(`echo " Copying artifacts..."
+		finalSteps = fmt.Sprintf(`cpx_phase copy
+echo " Copying artifacts..."
 mkdir -p /output/%s
 %s
+cpx_phase end
 %s`, opts.TargetName, copyCommand, runSection)
 	} else {
-		finalSteps = fmt.Sprintf(`echo " Copying artifacts..."
+		finalSteps = fmt.Sprintf(`cpx_phase copy
+echo " Copying artifacts..."
 mkdir -p /output/%s
 %s
+cpx_phase end
 echo " Build complete!"`, opts.TargetName, copyCommand)
 	}
 
 	// Handle verbosity for CMake commands
-	cmakeQuiet := ""
-	if !opts.Verbose {
-		cmakeQuiet = " > /dev/null 2>&1"
-	}
+	cmakeQuiet := opts.QuietRedirect()
 
 	configEcho := "echo \"  Configuring CMake (Ninja)...\""
 	buildEcho := "echo \" Building...\""
@@ -208,31 +204,23 @@ echo " Build complete!"`, opts.TargetName, copyCommand)
 
 	buildScript := fmt.Sprintf(`#!/bin/bash
 set -e
-%sexport VCPKG_ROOT=/opt/vcpkg
-export PATH="${VCPKG_ROOT}:${PATH}"
-export VCPKG_FEATURE_FLAGS=manifests
-export X_VCPKG_REGISTRIES_CACHE=/tmp/.vcpkg_cache/registries
-export VCPKG_DISABLE_REGISTRY_UPDATE=1
-export VCPKG_KEEP_ENV_VARS="VCPKG_DISABLE_REGISTRY_UPDATE;VCPKG_FEATURE_FLAGS;VCPKG_INSTALLED_DIR;VCPKG_DOWNLOADS;VCPKG_BUILDTREES_ROOT;VCPKG_BINARY_SOURCES"
-export VCPKG_INSTALLED_DIR=%s
-export VCPKG_DOWNLOADS=%s
-export VCPKG_BUILDTREES_ROOT=%s
-export VCPKG_BINARY_SOURCES="files,%s,readwrite"
-export VCPKG_DISABLE_METRICS=1
-mkdir -p /tmp/.vcpkg_cache
-mkdir -p "$VCPKG_INSTALLED_DIR" "$VCPKG_DOWNLOADS" "$VCPKG_BUILDTREES_ROOT" "%s" "$X_VCPKG_REGISTRIES_CACHE"
-mkdir -p %s
+%s%s%smkdir -p %s
+cpx_phase configure
 %s
-cmake %s%s
+%s %s%s
+cpx_phase build
 %s
 cmake %s%s
-%s%s%s
-`, envExports, vcpkgInstalledPath, vcpkgDownloadsPath, vcpkgBuildtreesPath, binaryCachePath, binaryCachePath, containerBuildDir, configEcho, strings.Join(cmakeArgs, " "), cmakeQuiet, buildEcho, strings.Join(buildArgs, " "), cmakeQuiet, testSection, benchSection, finalSteps)
+%s%s%s%s
+`, build.PhaseScript(containerBuildDir), envExports, cacheSetup, containerBuildDir, configEcho, configureCommand(opts), strings.Join(cmakeArgs, " "), cmakeQuiet, buildEcho, strings.Join(buildArgs, " "), cmakeQuiet, ccacheStats, testSection, benchSection, finalSteps)
 
 	// Run Docker container
-	fmt.Printf("  %s Running build in Docker container...%s\n", colors.Cyan, colors.Reset)
+	if opts.Shell {
+		buildScript = build.ShellScript(envExports+cacheSetup, b.DescribeDockerBuild(opts), opts.ShellCommand)
+	}
 
-	dockerArgs := []string{"run", "--rm"}
+	dockerArgs := append([]string{"run", "--rm"}, opts.RunArgs()...)
+	dockerArgs = append(dockerArgs, opts.ShellArgs()...)
 	if opts.Platform != "" {
 		dockerArgs = append(dockerArgs, "--platform", opts.Platform)
 	}
@@ -243,25 +231,221 @@ cmake %s%s
 	}
 
 	dockerArgs = append(dockerArgs,
-		"-v", absProjectRoot+":/workspace:ro",
-		"-v", absBuildDir+":/tmp/build",
-		"-v", absOutputDir+":/output",
-		"-v", absVcpkgCacheDir+":/tmp/.vcpkg_cache",
-		"-w", "/workspace",
-		opts.ImageName,
-		"bash", "-c", buildScript)
-
-	cmd := exec.Command("docker", dockerArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+		"-v", opts.Endpoint.Mount(absProjectRoot, "/workspace", true),
+		"-v", opts.Endpoint.Mount(absBuildDir, "/tmp/build", false),
+		"-v", opts.Endpoint.Mount(absOutputDir, "/output", false),
+		"-v", opts.Endpoint.Mount(absVcpkgCacheDir, "/tmp/.vcpkg_cache", false),
+		"-w", "/workspace")
+	dockerArgs = append(dockerArgs, opts.EnvArgs()...)
+	dockerArgs = append(dockerArgs, opts.ImageName, "bash", "-c", buildScript)
+	if opts.DryRun {
+		build.FprintDockerCommand(opts.Stdout(), opts.Endpoint, dockerArgs)
+		return nil
+	}
 
-	if err := cmd.Run(); err != nil {
+	if opts.Shell {
+		return build.RunShell(opts, dockerArgs)
+	}
+
+	fmt.Fprintf(opts.Stdout(), "  %s Running build in Docker container...%s\n", colors.Cyan, colors.Reset)
+	cmd := opts.Endpoint.Command(dockerArgs...)
+	cmd.Stdout, cmd.Stderr = opts.Streams()
+
+	if err := build.RunContainer(ctx, opts, cmd); err != nil {
 		return fmt.Errorf("docker run failed: %w", err)
 	}
 
+	// Remote builds are audited once their artifacts are downloaded
+	if opts.Hardening && !opts.Endpoint.Remote {
+		if err := build.FprintHardeningAudit(opts.Stdout(), targetOutputDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PrefetchDockerDependencies installs the manifest dependencies into the
+// target's vcpkg caches without configuring or compiling the project.
+func (b *Builder) PrefetchDockerDependencies(ctx context.Context, opts build.DockerBuildOptions) error {
+	vcpkgCacheDir, err := filepath.Abs(filepath.Join(opts.CacheRoot(), opts.TargetName, ".vcpkg_cache"))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for vcpkg cache directory: %w", err)
+	}
+	if err := os.MkdirAll(vcpkgCacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create vcpkg cache directory: %w", err)
+	}
+	absProjectRoot, err := filepath.Abs(opts.ProjectRoot)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for project root: %w", err)
+	}
+
+	installArgs := []string{"install", "--x-manifest-root=/workspace", "--x-install-root=\"$VCPKG_INSTALLED_DIR\""}
+	if len(testFeatureArgs(opts.ProjectRoot)) > 0 {
+		installArgs = append(installArgs, "--x-feature="+TestFeature)
+	}
+	if opts.Triplet != "" {
+		installArgs = append(installArgs, "--triplet="+opts.Triplet)
+	}
+	if opts.User != "" {
+		installArgs = append(installArgs, userPackagesRoot)
+	}
+	script := fmt.Sprintf("set -e\n%s%svcpkg %s\n", vcpkgCacheEnv, binarySourcesEnv(opts.BinarySources), strings.Join(installArgs, " "))
+
+	dockerArgs := append([]string{"run", "--rm"}, opts.RunArgs()...)
+	if opts.Platform != "" {
+		dockerArgs = append(dockerArgs, "--platform", opts.Platform)
+	}
+	dockerArgs = append(dockerArgs,
+		"-v", opts.Endpoint.Mount(absProjectRoot, "/workspace", true),
+		"-v", opts.Endpoint.Mount(vcpkgCacheDir, "/tmp/.vcpkg_cache", false),
+		"-w", "/workspace")
+	dockerArgs = append(dockerArgs, opts.EnvArgs()...)
+	dockerArgs = append(dockerArgs, opts.ImageName, "bash", "-c", script)
+
+	cmd := opts.Endpoint.Command(dockerArgs...)
+	if opts.Verbose {
+		cmd.Stdout = opts.Stdout()
+	}
+	cmd.Stderr = opts.Stderr()
+	defer build.FlushOutput(cmd.Stdout, cmd.Stderr)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("vcpkg install failed: %w", err)
+	}
 	return nil
 }
 
+// userPackagesRoot moves vcpkg's package staging out of /opt/vcpkg/packages,
+// which containers running as a build.DockerBuildOptions User cannot write
+const userPackagesRoot = "--x-packages-root=/tmp/.vcpkg_cache/packages"
+
+// vcpkgCacheEnv points vcpkg inside the container at the persistent cache mounted at /tmp/.vcpkg_cache
+const vcpkgCacheEnv = `export VCPKG_ROOT=/opt/vcpkg
+export PATH="${VCPKG_ROOT}:${PATH}"
+export VCPKG_FEATURE_FLAGS=manifests
+export X_VCPKG_REGISTRIES_CACHE=/tmp/.vcpkg_cache/registries
+export VCPKG_DISABLE_REGISTRY_UPDATE=1
+export VCPKG_KEEP_ENV_VARS="VCPKG_DISABLE_REGISTRY_UPDATE;VCPKG_FEATURE_FLAGS;VCPKG_INSTALLED_DIR;VCPKG_DOWNLOADS;VCPKG_BUILDTREES_ROOT;VCPKG_BINARY_SOURCES"
+export VCPKG_INSTALLED_DIR=/tmp/.vcpkg_cache/installed
+export VCPKG_DOWNLOADS=/tmp/.vcpkg_cache/downloads
+export VCPKG_BUILDTREES_ROOT=/tmp/.vcpkg_cache/buildtrees
+export VCPKG_BINARY_SOURCES="files,/tmp/.vcpkg_cache/binary,readwrite"
+export VCPKG_DISABLE_METRICS=1
+mkdir -p /tmp/.vcpkg_cache
+mkdir -p "$VCPKG_INSTALLED_DIR" "$VCPKG_DOWNLOADS" "$VCPKG_BUILDTREES_ROOT" /tmp/.vcpkg_cache/binary "$X_VCPKG_REGISTRIES_CACHE"
+`
+
+// DescribeDockerBuild returns the CMake configure and build command lines
+// RunDockerBuild executes inside the container.
+func (b *Builder) DescribeDockerBuild(opts build.DockerBuildOptions) []string {
+	cmakeArgs, buildArgs := dockerCMakeArgs(opts, "/tmp/build")
+	return []string{
+		configureCommand(opts) + " " + strings.Join(cmakeArgs, " "),
+		"cmake " + strings.Join(buildArgs, " "),
+	}
+}
+
+// configureCommand returns the command that runs the CMake configure step
+func configureCommand(opts build.DockerBuildOptions) string {
+	if opts.ConfigureWrapper != "" {
+		return opts.ConfigureWrapper + " cmake"
+	}
+	return "cmake"
+}
+
+// dockerCMakeArgs returns the CMake configure and build arguments for a Docker build
+func dockerCMakeArgs(opts build.DockerBuildOptions, containerBuildDir string) ([]string, []string) {
+	buildType := opts.BuildType
+	if buildType == "" {
+		buildType = "Release"
+	}
+	if opts.Coverage != "" {
+		// Release flags would optimize the instrumented code after ours
+		buildType = "Debug"
+	}
+
+	optLevel := opts.Optimization
+	if optLevel == "" {
+		optLevel = "2"
+	}
+
+	// Build CMake arguments
+	cmakeArgs := []string{
+		"-GNinja",
+		"-B", containerBuildDir,
+		"-S", "/workspace",
+		"-DCMAKE_BUILD_TYPE=" + buildType,
+		"-DCMAKE_TOOLCHAIN_FILE=/opt/vcpkg/scripts/buildsystems/vcpkg.cmake",
+		"-DCMAKE_EXPORT_COMPILE_COMMANDS=ON", // for cpx compdb
+	}
+	if opts.Triplet != "" {
+		cmakeArgs = append(cmakeArgs, "-DVCPKG_TARGET_TRIPLET="+opts.Triplet)
+	}
+	if opts.User != "" {
+		cmakeArgs = append(cmakeArgs, "-DVCPKG_INSTALL_OPTIONS="+userPackagesRoot)
+	}
+
+	if opts.RunTests {
+		cmakeArgs = append(cmakeArgs, "-DBUILD_TESTING=ON", "-DENABLE_TESTING=ON")
+		cmakeArgs = append(cmakeArgs, testFeatureArgs(opts.ProjectRoot)...)
+	}
+
+	if opts.RunBenchmarks {
+		cmakeArgs = append(cmakeArgs, "-DENABLE_BENCHMARKS=ON")
+	}
+
+	cxxFlags := "-O" + optLevel
+	var cFlags, linkFlags []string
+	if opts.Hardening {
+		// Containers always build with GCC/Clang
+		compile, link := build.HardeningFlags(false, buildType != "Debug" && optLevel != "0")
+		cFlags, linkFlags = append(cFlags, compile...), append(linkFlags, link...)
+	}
+	if opts.Coverage != "" {
+		compile, link := build.CoverageFlags(opts.Coverage)
+		cFlags, linkFlags = append(cFlags, compile...), append(linkFlags, link...)
+	}
+	if len(cFlags) > 0 {
+		cxxFlags += " " + strings.Join(cFlags, " ")
+		// The script joins arguments with spaces, so multi-flag values are quoted
+		cmakeArgs = append(cmakeArgs,
+			"'-DCMAKE_C_FLAGS="+strings.Join(cFlags, " ")+"'",
+			"'-DCMAKE_EXE_LINKER_FLAGS="+strings.Join(linkFlags, " ")+"'",
+			"'-DCMAKE_SHARED_LINKER_FLAGS="+strings.Join(linkFlags, " ")+"'")
+	} else {
+		// The build directory is reused: drop an earlier hardened or coverage build's flags
+		cmakeArgs = append(cmakeArgs, cmake.UnsetFlagArgs...)
+	}
+	cmakeArgs = append(cmakeArgs, "'-DCMAKE_CXX_FLAGS="+cxxFlags+"'")
+	if opts.CCache || opts.SCCache != nil {
+		// Set by build.CCacheScript or build.SCCacheScript; empty when the image has no ccache
+		cmakeArgs = append(cmakeArgs,
+			"-DCMAKE_C_COMPILER_LAUNCHER=$COMPILER_LAUNCHER",
+			"-DCMAKE_CXX_COMPILER_LAUNCHER=$COMPILER_LAUNCHER")
+	}
+	cmakeArgs = append(cmakeArgs, "-DVCPKG_DISABLE_REGISTRY_UPDATE=ON")
+	cmakeArgs = append(cmakeArgs, opts.CMakeArgs...)
+
+	// Build command arguments
+	buildArgs := []string{"--build", containerBuildDir, "--config", buildType}
+	if opts.Jobs > 0 {
+		buildArgs = append(buildArgs, "--parallel", fmt.Sprintf("%d", opts.Jobs))
+	}
+	buildArgs = append(buildArgs, opts.BuildArgs...)
+	if opts.RunBenchmarks {
+		buildArgs = append(buildArgs, "--target", "all", dockerProjectName(opts)+"_bench")
+	}
+	return cmakeArgs, buildArgs
+}
+
+// dockerProjectName returns the CMake project name, falling back to the directory name
+func dockerProjectName(opts build.DockerBuildOptions) string {
+	if name := cmake.GetProjectNameFromCMakeLists(); name != "" {
+		return name
+	}
+	return filepath.Base(opts.ProjectRoot)
+}
+
 // detectProjectType detects if the project is an executable or library
 func detectProjectType(projectRoot string) (bool, error) {
 	cmakeListsPath := filepath.Join(projectRoot, "CMakeLists.txt")
@@ -294,5 +478,54 @@ func detectProjectType(projectRoot string) (bool, error) {
 	return true, nil
 }
 
-// Compile-time check that Builder implements DockerBuilder
-var _ build.DockerBuilder = (*Builder)(nil)
+// Compile-time checks that Builder implements DockerBuilder and DockerBuildDescriber
+var (
+	_ build.DockerBuilder        = (*Builder)(nil)
+	_ build.DockerBuildDescriber = (*Builder)(nil)
+	_ build.DockerPrefetcher     = (*Builder)(nil)
+)
+
+// binarySourcesEnv appends remote binary caches to VCPKG_BINARY_SOURCES after
+// the local one. Credentials are forwarded into the container, so the script
+// references them as variables: NuGet feeds get a nuget.config with
+// NUGET_USERNAME/NUGET_PASSWORD when set, HTTP headers are expanded.
+func binarySourcesEnv(sources []build.BinarySource) string {
+	if len(sources) == 0 {
+		return ""
+	}
+	var s strings.Builder
+	s.WriteString("# Remote binary caches\n")
+	for i, src := range sources {
+		mode := src.Mode
+		if mode == "" {
+			mode = "readwrite"
+		}
+		switch src.Type {
+		case "gha":
+			fmt.Fprintf(&s, "export VCPKG_BINARY_SOURCES=\"$VCPKG_BINARY_SOURCES;x-gha,%s\"\n", mode)
+		case "nuget":
+			config := fmt.Sprintf("/tmp/.vcpkg-nuget-%d.config", i)
+			fmt.Fprintf(&s, `if [ -n "$NUGET_PASSWORD" ]; then
+    cat > %[1]s <<EOF
+<?xml version="1.0" encoding="utf-8"?>
+<configuration>
+  <config><add key="defaultPushSource" value="%[2]s" /></config>
+  <packageSources><clear /><add key="cpx" value="%[2]s" /></packageSources>
+  <packageSourceCredentials><cpx><add key="Username" value="$NUGET_USERNAME" /><add key="ClearTextPassword" value="$NUGET_PASSWORD" /></cpx></packageSourceCredentials>
+</configuration>
+EOF
+    export VCPKG_BINARY_SOURCES="$VCPKG_BINARY_SOURCES;nugetconfig,%[1]s,%[3]s"
+else
+    export VCPKG_BINARY_SOURCES="$VCPKG_BINARY_SOURCES;nuget,%[2]s,%[3]s"
+fi
+`, config, src.URL, mode)
+		case "http":
+			source := "http," + src.URL + "," + mode
+			if src.Header != "" {
+				source += "," + src.Header
+			}
+			fmt.Fprintf(&s, "export VCPKG_BINARY_SOURCES=\"$VCPKG_BINARY_SOURCES;%s\"\n", source)
+		}
+	}
+	return s.String()
+}
@@ -0,0 +1,144 @@
+package vcpkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+)
+
+// TestFeature is the vcpkg.json feature holding test-only dependencies
+const TestFeature = "tests"
+
+// readManifest reads and parses a vcpkg.json manifest
+func readManifest(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vcpkg.json: %w", err)
+	}
+
+	var manifest map[string]interface{}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse vcpkg.json: %w", err)
+	}
+	return manifest, nil
+}
+
+// writeManifest encodes and writes a vcpkg.json manifest
+func writeManifest(path string, manifest map[string]interface{}) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode vcpkg.json: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write vcpkg.json: %w", err)
+	}
+	return nil
+}
+
+// dependencyName returns the port name of a manifest dependency entry (string or object)
+func dependencyName(dep interface{}) string {
+	if str, ok := dep.(string); ok {
+		return str
+	}
+	if obj, ok := dep.(map[string]interface{}); ok {
+		if n, ok := obj["name"].(string); ok {
+			return n
+		}
+	}
+	return ""
+}
+
+// AddDevDependency adds a port to the "tests" feature of vcpkg.json so it is
+// only installed when tests are built.
+func (b *Builder) AddDevDependency(ctx context.Context, name string, version string) error {
+	manifest, err := readManifest("vcpkg.json")
+	if err != nil {
+		return err
+	}
+
+	features, _ := manifest["features"].(map[string]interface{})
+	if features == nil {
+		features = make(map[string]interface{})
+	}
+	feature, _ := features[TestFeature].(map[string]interface{})
+	if feature == nil {
+		feature = map[string]interface{}{
+			"description": "Test-only dependencies",
+		}
+	}
+	deps, _ := feature["dependencies"].([]interface{})
+
+	for _, dep := range deps {
+		if dependencyName(dep) == name {
+			return fmt.Errorf("%s is already a test dependency", name)
+		}
+	}
+
+	var entry interface{} = name
+	if version != "" {
+		entry = map[string]interface{}{"name": name, "version>=": version}
+	}
+	feature["dependencies"] = append(deps, entry)
+	features[TestFeature] = feature
+	manifest["features"] = features
+
+	if err := writeManifest("vcpkg.json", manifest); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s✓ Added %s to the '%s' feature in vcpkg.json%s\n", colors.Green, name, TestFeature, colors.Reset)
+	b.printUsageInfo(name)
+	return nil
+}
+
+// manifestHasFeature reports whether the vcpkg.json at path declares the given feature
+func manifestHasFeature(path, feature string) bool {
+	manifest, err := readManifest(path)
+	if err != nil {
+		return false
+	}
+	features, _ := manifest["features"].(map[string]interface{})
+	_, ok := features[feature]
+	return ok
+}
+
+// testFeatureArgs returns the CMake arguments enabling the tests feature when the
+// manifest in projectRoot declares it.
+func testFeatureArgs(projectRoot string) []string {
+	if manifestHasFeature(filepath.Join(projectRoot, "vcpkg.json"), TestFeature) {
+		return []string{"-DVCPKG_MANIFEST_FEATURES=" + TestFeature}
+	}
+	return nil
+}
+
+// removeFromFeatures drops name from every feature's dependency list.
+// Returns true if it was found in any feature.
+func removeFromFeatures(manifest map[string]interface{}, name string) bool {
+	features, _ := manifest["features"].(map[string]interface{})
+	found := false
+	for _, f := range features {
+		feature, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		deps, _ := feature["dependencies"].([]interface{})
+		kept := make([]interface{}, 0, len(deps))
+		for _, dep := range deps {
+			if dependencyName(dep) == name {
+				found = true
+				continue
+			}
+			kept = append(kept, dep)
+		}
+		feature["dependencies"] = kept
+	}
+	return found
+}
+
+// Compile-time check that Builder implements DevDependencyAdder
+var _ build.DevDependencyAdder = (*Builder)(nil)
@@ -33,6 +33,7 @@ var execCommand = exec.Command
 // Builder implements the build.BuildSystem interface for vcpkg.
 type Builder struct {
 	globalConfig *config.GlobalConfig
+	usageCache   map[string]string
 }
 
 // New creates a new vcpkg Builder.
@@ -40,12 +41,12 @@ func New() *Builder {
 	return &Builder{}
 }
 
-// ensureConfig ensures the global config is loaded
+// ensureConfig ensures the configuration (global, then .cpx.yaml) is loaded
 func (b *Builder) ensureConfig() error {
 	if b.globalConfig != nil {
 		return nil
 	}
-	globalConfig, err := config.LoadGlobal()
+	globalConfig, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load global config: %w", err)
 	}
@@ -232,6 +233,9 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 
 	// Determine build output directory based on optimization/release/sanitizer
 	outDirName := build.GetOutputDir(opts.Release, opts.OptLevel, opts.Sanitizer)
+	if opts.Hardening {
+		outDirName += "-hardened"
+	}
 
 	// Use hidden cache directory for build artifacts
 	// .cache/native/<variant>
@@ -260,6 +264,13 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 	cxxFlags += sanCFlags
 	linkerFlags := sanLFlags
 
+	// Add hardening flags
+	if opts.Hardening {
+		hardCFlags, hardLFlags := getHardeningFlags(buildType != "Debug")
+		cxxFlags += hardCFlags
+		linkerFlags = strings.TrimSpace(linkerFlags + hardLFlags)
+	}
+
 	optLabel := "default (-O0)"
 	if opts.Release {
 		optLabel = "-O2 (Release)"
@@ -270,6 +281,9 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 	if opts.Sanitizer != "" {
 		optLabel += "+" + opts.Sanitizer
 	}
+	if opts.Hardening {
+		optLabel += "+hardened"
+	}
 
 	fmt.Printf("\n%s▸ Build%s %s %s(%s)%s %s[opt: %s]%s\n",
 		colors.Cyan, colors.Reset, projectName, colors.Gray, buildType, colors.Reset,
@@ -306,7 +320,7 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 			// Use "default" preset (VCPKG_ROOT is now set from config)
 			// Pass -B explicitly to override preset binaryDir if needed, or ensure it goes to our cache
 			// Also pass VCPKG_INSTALLED_DIR to force shared vcpkg location
-			cmdArgs := []string{"--preset=default", "-B", cacheBuildDir, vcpkgInstallArg}
+			cmdArgs := []string{"--preset=default", "-B", cacheBuildDir, vcpkgInstallArg, "-DCMAKE_EXPORT_COMPILE_COMMANDS=ON"}
 			if cxxFlags != "" {
 				cmdArgs = append(cmdArgs, "-DCMAKE_CXX_FLAGS="+cxxFlags, "-DCMAKE_C_FLAGS="+cxxFlags)
 			}
@@ -321,7 +335,7 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 			}
 		} else {
 			// Fallback to traditional cmake configure
-			cmdArgs := []string{"-B", cacheBuildDir, "-DCMAKE_BUILD_TYPE=" + buildType, vcpkgInstallArg}
+			cmdArgs := []string{"-B", cacheBuildDir, "-DCMAKE_BUILD_TYPE=" + buildType, vcpkgInstallArg, "-DCMAKE_EXPORT_COMPILE_COMMANDS=ON"}
 			if cxxFlags != "" {
 				cmdArgs = append(cmdArgs, "-DCMAKE_CXX_FLAGS="+cxxFlags, "-DCMAKE_C_FLAGS="+cxxFlags)
 			}
@@ -381,7 +395,13 @@ func (b *Builder) Build(ctx context.Context, opts build.BuildOptions) error {
 	}
 
 	fmt.Printf("%s  ✔ Build complete%s %s[%s]%s\n", colors.Green, colors.Reset, colors.Gray, time.Since(buildStart).Round(10*time.Millisecond), colors.Reset)
-	fmt.Printf("  Artifacts in: %s/\n\n", finalBuildDir)
+	fmt.Printf("  Artifacts in: %s/\n", finalBuildDir)
+	if opts.Hardening {
+		if err := build.PrintHardeningAudit(finalBuildDir); err != nil {
+			return err
+		}
+	}
+	fmt.Println()
 	return nil
 }
 
@@ -401,6 +421,9 @@ func (b *Builder) Test(ctx context.Context, opts build.TestOptions) error {
 	// Default to debug for tests if no config specified
 	// Use .cache/native/test for building tests (separate from normal builds)
 	buildDir := filepath.Join(".cache", "native", "test")
+	if opts.Coverage != "" {
+		buildDir = build.CoverageBuildDir
+	}
 
 	// Check if configure is needed
 	needsConfigure := false
@@ -429,13 +452,20 @@ func (b *Builder) Test(ctx context.Context, opts build.TestOptions) error {
 		vcpkgInstalledDir := filepath.Join(cwd, ".cache", "native", "vcpkg_installed")
 		vcpkgInstallArg := "-DVCPKG_INSTALLED_DIR=" + vcpkgInstalledDir
 
-		// Enable testing
-		enableTestingArg := "-DENABLE_TESTING=ON"
+		// Enable testing and install test-only dependencies
+		testArgs := append([]string{vcpkgInstallArg, "-DENABLE_TESTING=ON"}, testFeatureArgs(cwd)...)
+		if opts.Coverage != "" {
+			compile, link := build.CoverageFlags(opts.Coverage)
+			compileFlags, linkFlags := strings.Join(compile, " "), strings.Join(link, " ")
+			testArgs = append(testArgs, "-DCMAKE_BUILD_TYPE=Debug",
+				"-DCMAKE_CXX_FLAGS="+compileFlags, "-DCMAKE_C_FLAGS="+compileFlags,
+				"-DCMAKE_EXE_LINKER_FLAGS="+linkFlags, "-DCMAKE_SHARED_LINKER_FLAGS="+linkFlags)
+		}
 
 		// Check if CMakePresets.json exists, use preset if available
 		if _, err := os.Stat("CMakePresets.json"); err == nil {
 			// Use "default" preset (VCPKG_ROOT is now set from config)
-			cmd := execCommand("cmake", "--preset=default", "-B", buildDir, vcpkgInstallArg, enableTestingArg)
+			cmd := execCommand("cmake", append([]string{"--preset=default", "-B", buildDir}, testArgs...)...)
 			cmd.Env = os.Environ()
 			if err := runCMakeConfigure(cmd, opts.Verbose); err != nil {
 				fmt.Println()
@@ -443,7 +473,7 @@ func (b *Builder) Test(ctx context.Context, opts build.TestOptions) error {
 			}
 		} else {
 			// Fallback to traditional cmake configure
-			cmd := execCommand("cmake", "-B", buildDir, vcpkgInstallArg, enableTestingArg)
+			cmd := execCommand("cmake", append([]string{"-B", buildDir}, testArgs...)...)
 			if err := runCMakeConfigure(cmd, opts.Verbose); err != nil {
 				fmt.Println()
 				return fmt.Errorf("cmake configure failed: %w", err)
@@ -485,6 +515,12 @@ func (b *Builder) Test(ctx context.Context, opts build.TestOptions) error {
 	ctestCmd := execCommand("ctest", ctestArgs...)
 	ctestCmd.Stdout = os.Stdout
 	ctestCmd.Stderr = os.Stderr
+	if opts.Coverage == build.CoverageClang {
+		// Profiles go to the build directory rather than each test's
+		// working directory
+		profileFile, _ := filepath.Abs(build.ProfileFile(buildDir))
+		ctestCmd.Env = append(ctestCmd.Environ(), "LLVM_PROFILE_FILE="+profileFile)
+	}
 
 	if err := ctestCmd.Run(); err != nil {
 		return fmt.Errorf("tests failed: %w", err)
@@ -561,7 +597,7 @@ func (b *Builder) Run(ctx context.Context, opts build.RunOptions) error {
 		// Check if CMakePresets.json exists, use preset if available
 		if _, err := os.Stat("CMakePresets.json"); err == nil {
 			// Use "default" preset (VCPKG_ROOT is now set from config)
-			cmdArgs := []string{"--preset=default", "-B", cacheBuildDir, vcpkgInstallArg}
+			cmdArgs := []string{"--preset=default", "-B", cacheBuildDir, vcpkgInstallArg, "-DCMAKE_EXPORT_COMPILE_COMMANDS=ON"}
 			if cxxFlags != "" {
 				cmdArgs = append(cmdArgs, "-DCMAKE_CXX_FLAGS="+cxxFlags, "-DCMAKE_C_FLAGS="+cxxFlags)
 			}
@@ -576,7 +612,7 @@ func (b *Builder) Run(ctx context.Context, opts build.RunOptions) error {
 			}
 		} else {
 			// Fallback to traditional cmake configure
-			cmdArgs := []string{"-B", cacheBuildDir, "-DCMAKE_BUILD_TYPE=" + buildType, vcpkgInstallArg}
+			cmdArgs := []string{"-B", cacheBuildDir, "-DCMAKE_BUILD_TYPE=" + buildType, vcpkgInstallArg, "-DCMAKE_EXPORT_COMPILE_COMMANDS=ON"}
 			if cxxFlags != "" {
 				cmdArgs = append(cmdArgs, "-DCMAKE_CXX_FLAGS="+cxxFlags, "-DCMAKE_C_FLAGS="+cxxFlags)
 			}
@@ -677,7 +713,7 @@ func (b *Builder) Run(ctx context.Context, opts build.RunOptions) error {
 	runCmd.Stdout = os.Stdout
 	runCmd.Stderr = os.Stderr
 	runCmd.Stdin = os.Stdin
-	return runCmd.Run()
+	return build.ProgramExit(runCmd.Run())
 }
 
 // Bench runs the project's benchmarks.
@@ -877,97 +913,137 @@ func (b *Builder) AddDependency(ctx context.Context, name string, version string
 	return nil
 }
 
-// printUsageInfo fetches and prints usage info from GitHub for vcpkg packages
-func (b *Builder) printUsageInfo(pkgName string) {
+// UsageInfo fetches the usage file for a vcpkg port from GitHub.
+// Results are cached on the builder so repeated lookups don't hit the network.
+func (b *Builder) UsageInfo(pkgName string) string {
+	if content, ok := b.usageCache[pkgName]; ok {
+		return content
+	}
+
+	content := ""
 	resp, err := http.Get(fmt.Sprintf("https://raw.githubusercontent.com/microsoft/vcpkg/master/ports/%s/usage", pkgName))
-	if err != nil || resp.StatusCode != 200 {
-		return
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode == 200 {
+			if data, err := io.ReadAll(resp.Body); err == nil {
+				content = strings.TrimSpace(string(data))
+			}
+		}
 	}
-	defer resp.Body.Close()
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return
+	if b.usageCache == nil {
+		b.usageCache = make(map[string]string)
+	}
+	b.usageCache[pkgName] = content
+	return content
+}
+
+var (
+	usageFindPackageRe = regexp.MustCompile(`find_package\s*\([^\)]*\)`)
+	usageLinkRe        = regexp.MustCompile(`target_link_libraries\s*\(\s*\S+\s+([^\)]*)\)`)
+)
+
+// ParseUsage extracts the find_package calls and link libraries from a vcpkg usage file.
+// Usage files often list alternatives under the same find_package (e.g. fmt::fmt
+// or fmt::fmt-header-only), which must not be linked together, so only the
+// first target_link_libraries of each find_package is kept.
+func ParseUsage(usage string) (findPackages []string, libraries []string) {
+	seen := make(map[string]bool)
+	fpIndexes := usageFindPackageRe.FindAllStringIndex(usage, -1)
+	fpNames := make([]string, len(fpIndexes))
+	for i, loc := range fpIndexes {
+		fp := strings.Join(strings.Fields(usage[loc[0]:loc[1]]), " ")
+		fpNames[i] = fp
+		if !seen[fp] {
+			seen[fp] = true
+			findPackages = append(findPackages, fp)
+		}
+	}
+
+	linked := make(map[string]bool)
+	for _, m := range usageLinkRe.FindAllStringSubmatchIndex(usage, -1) {
+		block := ""
+		for i, loc := range fpIndexes {
+			if loc[0] < m[0] {
+				block = fpNames[i]
+			}
+		}
+		if linked[block] {
+			continue
+		}
+		linked[block] = true
+
+		for _, lib := range strings.Fields(usage[m[2]:m[3]]) {
+			if lib == "PRIVATE" || lib == "PUBLIC" || lib == "INTERFACE" {
+				continue
+			}
+			if !seen[lib] {
+				seen[lib] = true
+				libraries = append(libraries, lib)
+			}
+		}
 	}
+	return findPackages, libraries
+}
 
-	content := strings.TrimSpace(string(data))
-	if content != "" {
-		fmt.Printf("\n%sUSAGE INFO FOR %s:%s\n", colors.Cyan, pkgName, colors.Reset)
-		fmt.Println(content)
-		fmt.Println()
+// printUsageInfo fetches and prints usage info from GitHub for vcpkg packages
+func (b *Builder) printUsageInfo(pkgName string) {
+	content := b.UsageInfo(pkgName)
+	if content == "" {
+		return
 	}
 
+	fmt.Printf("\n%sUSAGE INFO FOR %s:%s\n", colors.Cyan, pkgName, colors.Reset)
+	fmt.Println(content)
+	fmt.Println()
+
 	// Print link to cpx website for more info
 	fmt.Printf("%s📦 Find sample usage and more info at:%s\n", colors.Cyan, colors.Reset)
 	fmt.Printf("   https://cpx-dev.vercel.app/packages#package/%s\n\n", pkgName)
 }
 
 // RemoveDependency removes a dependency from the project.
+// Both top-level dependencies and feature dependencies (e.g. test-only ports) are checked.
 func (b *Builder) RemoveDependency(ctx context.Context, name string) error {
 	// Check for vcpkg.json (Manifest mode)
 	if _, err := os.Stat("vcpkg.json"); err != nil {
 		return fmt.Errorf("vcpkg.json not found - manifest mode required")
 	}
 
-	// Read manifest
-	data, err := os.ReadFile("vcpkg.json")
+	manifest, err := readManifest("vcpkg.json")
 	if err != nil {
-		return fmt.Errorf("failed to read vcpkg.json: %w", err)
-	}
-
-	// Parse JSON
-	var manifest map[string]interface{}
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return fmt.Errorf("failed to parse vcpkg.json: %w", err)
-	}
-
-	// Get dependencies
-	deps, ok := manifest["dependencies"]
-	if !ok {
-		return fmt.Errorf("no dependencies found in vcpkg.json")
-	}
-
-	depList, ok := deps.([]interface{})
-	if !ok {
-		return fmt.Errorf("invalid dependencies format in vcpkg.json")
+		return err
 	}
 
 	// Filter out the dependency
-	newDeps := make([]interface{}, 0, len(depList))
 	found := false
+	if deps, ok := manifest["dependencies"]; ok {
+		depList, ok := deps.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid dependencies format in vcpkg.json")
+		}
 
-	for _, dep := range depList {
-		depName := ""
-		if str, ok := dep.(string); ok {
-			depName = str
-		} else if obj, ok := dep.(map[string]interface{}); ok {
-			if n, ok := obj["name"].(string); ok {
-				depName = n
+		newDeps := make([]interface{}, 0, len(depList))
+		for _, dep := range depList {
+			if dependencyName(dep) == name {
+				found = true
+				continue
 			}
+			newDeps = append(newDeps, dep)
 		}
+		manifest["dependencies"] = newDeps
+	}
 
-		if depName == name {
-			found = true
-			continue
-		}
-		newDeps = append(newDeps, dep)
+	if removeFromFeatures(manifest, name) {
+		found = true
 	}
 
 	if !found {
 		return fmt.Errorf("dependency %s not found in vcpkg.json", name)
 	}
 
-	// Update manifest
-	manifest["dependencies"] = newDeps
-
-	// Write back
-	newData, err := json.MarshalIndent(manifest, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to encode vcpkg.json: %w", err)
-	}
-
-	if err := os.WriteFile("vcpkg.json", newData, 0644); err != nil {
-		return fmt.Errorf("failed to write vcpkg.json: %w", err)
+	if err := writeManifest("vcpkg.json", manifest); err != nil {
+		return err
 	}
 
 	fmt.Printf("%s✓ Removed %s from vcpkg.json%s\n", colors.Green, name, colors.Reset)
@@ -1458,6 +1534,12 @@ func getSanitizerFlags(sanitizer string) (string, string) {
 	return cxxFlags, linkerFlags
 }
 
+// getHardeningFlags returns compiler and linker flags for hardened builds
+func getHardeningFlags(optimized bool) (string, string) {
+	compile, link := build.HardeningFlags(build.UsesMSVCFlags(), optimized)
+	return " " + strings.Join(compile, " "), " " + strings.Join(link, " ")
+}
+
 // ListTargets returns the list of build targets.
 func (b *Builder) ListTargets(ctx context.Context) ([]string, error) {
 	// Look for any configured build directory in .cache/native
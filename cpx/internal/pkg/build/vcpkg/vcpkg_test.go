@@ -1,12 +1,15 @@
 package vcpkg
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/ozacod/cpx/internal/pkg/build/cmake"
 	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
 	"github.com/ozacod/cpx/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -175,6 +178,47 @@ func TestTest(t *testing.T) {
 	assert.True(t, foundCtest, "ctest should be called")
 }
 
+func TestTestCoverage(t *testing.T) {
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+
+	var capturedArgs [][]string
+	var ctestCmd *exec.Cmd
+	mock := mockExecCommand(&capturedArgs)
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		cmd := mock(name, arg...)
+		if name == "ctest" {
+			ctestCmd = cmd
+		}
+		return cmd
+	}
+
+	tmpDir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	defer func() { _ = os.Chdir(oldWd) }()
+	_ = os.Chdir(tmpDir)
+	_ = os.WriteFile(filepath.Join(tmpDir, "vcpkg"), []byte(""), 0755)
+	_ = os.WriteFile("CMakeLists.txt", []byte("project(test)"), 0644)
+
+	builder := setupTestConfig(t, tmpDir)
+	err := builder.Test(context.Background(), build.TestOptions{Verbose: true, Coverage: build.CoverageClang})
+	require.NoError(t, err)
+
+	require.GreaterOrEqual(t, len(capturedArgs), 3)
+	configure := strings.Join(capturedArgs[0], " ")
+	assert.Contains(t, configure, "-B "+build.CoverageBuildDir)
+	assert.Contains(t, configure, "-DCMAKE_BUILD_TYPE=Debug")
+	assert.Contains(t, configure, "-DCMAKE_CXX_FLAGS=-fprofile-instr-generate -fcoverage-mapping -O0 -g")
+	assert.Contains(t, configure, "-DCMAKE_EXE_LINKER_FLAGS=-fprofile-instr-generate")
+	assert.Contains(t, capturedArgs[1], build.CoverageBuildDir)
+
+	ctest := capturedArgs[len(capturedArgs)-1]
+	assert.Equal(t, []string{"ctest", "--test-dir", build.CoverageBuildDir}, ctest[:3])
+	require.NotNil(t, ctestCmd)
+	profileFile, _ := filepath.Abs(build.ProfileFile(build.CoverageBuildDir))
+	assert.Contains(t, ctestCmd.Env, "LLVM_PROFILE_FILE="+profileFile)
+}
+
 func TestRun(t *testing.T) {
 	oldExecCommand := execCommand
 	defer func() { execCommand = oldExecCommand }()
@@ -247,3 +291,142 @@ func TestAddDependency(t *testing.T) {
 	}
 	assert.True(t, foundVcpkgAdd, "vcpkg add port zlib should be called")
 }
+
+func TestParseUsage(t *testing.T) {
+	usage := `The package fmt provides CMake targets:
+
+    find_package(fmt CONFIG REQUIRED)
+    target_link_libraries(main PRIVATE fmt::fmt)
+
+    # Or use the header-only version
+    find_package(fmt CONFIG REQUIRED)
+    target_link_libraries(main PRIVATE fmt::fmt-header-only)`
+
+	findPackages, libraries := ParseUsage(usage)
+	assert.Equal(t, []string{"find_package(fmt CONFIG REQUIRED)"}, findPackages)
+	assert.Equal(t, []string{"fmt::fmt"}, libraries)
+
+	// Separate packages each keep their link set
+	usage = `find_package(ZLIB REQUIRED)
+    target_link_libraries(main PRIVATE ZLIB::ZLIB)
+    find_package(CURL CONFIG REQUIRED)
+    target_link_libraries(main PRIVATE CURL::libcurl)`
+	findPackages, libraries = ParseUsage(usage)
+	assert.Equal(t, []string{"find_package(ZLIB REQUIRED)", "find_package(CURL CONFIG REQUIRED)"}, findPackages)
+	assert.Equal(t, []string{"ZLIB::ZLIB", "CURL::libcurl"}, libraries)
+
+	findPackages, libraries = ParseUsage("")
+	assert.Empty(t, findPackages)
+	assert.Empty(t, libraries)
+}
+
+func TestAddDevDependency(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldWd, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { _ = os.Chdir(oldWd) }()
+	require.NoError(t, os.Chdir(tmpDir))
+
+	require.NoError(t, os.WriteFile("vcpkg.json", []byte(`{"name": "demo", "dependencies": ["fmt"]}`), 0644))
+
+	builder := &Builder{usageCache: map[string]string{"gtest": ""}}
+	require.NoError(t, builder.AddDevDependency(context.Background(), "gtest", ""))
+
+	manifest, err := readManifest("vcpkg.json")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"fmt"}, manifest["dependencies"])
+	feature := manifest["features"].(map[string]interface{})[TestFeature].(map[string]interface{})
+	assert.Equal(t, []interface{}{"gtest"}, feature["dependencies"])
+	assert.Equal(t, []string{"-DVCPKG_MANIFEST_FEATURES=tests"}, testFeatureArgs(tmpDir))
+
+	// Adding twice is rejected
+	assert.Error(t, builder.AddDevDependency(context.Background(), "gtest", ""))
+
+	// RemoveDependency also cleans up feature dependencies
+	require.NoError(t, builder.RemoveDependency(context.Background(), "gtest"))
+	manifest, err = readManifest("vcpkg.json")
+	require.NoError(t, err)
+	feature = manifest["features"].(map[string]interface{})[TestFeature].(map[string]interface{})
+	assert.Empty(t, feature["dependencies"])
+}
+
+func TestDockerCMakeArgsCCache(t *testing.T) {
+	cmakeArgs, _ := dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir()}, "/tmp/build")
+	assert.NotContains(t, strings.Join(cmakeArgs, " "), "LAUNCHER")
+
+	cmakeArgs, _ = dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir(), CCache: true}, "/tmp/build")
+	assert.Contains(t, cmakeArgs, "-DCMAKE_CXX_COMPILER_LAUNCHER=$COMPILER_LAUNCHER")
+	assert.Contains(t, build.CCacheScript("/tmp/build/.ccache"), "export CCACHE_DIR=/tmp/build/.ccache")
+}
+
+func TestDockerCMakeArgsCoverage(t *testing.T) {
+	cmakeArgs, buildArgs := dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir(), BuildType: "Release", RunTests: true, Coverage: build.CoverageClang}, "/tmp/build")
+	assert.Contains(t, cmakeArgs, "-DCMAKE_BUILD_TYPE=Debug")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_CXX_FLAGS=-O2 -fprofile-instr-generate -fcoverage-mapping -O0 -g'")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_EXE_LINKER_FLAGS=-fprofile-instr-generate'")
+	assert.Equal(t, []string{"--build", "/tmp/build", "--config", "Debug"}, buildArgs)
+
+	cmakeArgs, _ = dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir(), Hardening: true, Coverage: build.CoverageGCC}, "/tmp/build")
+	compile, link := build.HardeningFlags(false, false)
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_C_FLAGS="+strings.Join(compile, " ")+" --coverage -O0 -g'")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_SHARED_LINKER_FLAGS="+strings.Join(link, " ")+" --coverage'")
+}
+
+func TestDockerCMakeArgsCoverageThenPlain(t *testing.T) {
+	// Both configures run in the toolchain's reused build directory
+	cmakeArgs, _ := dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir(), RunTests: true, Coverage: build.CoverageGCC}, "/tmp/build")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_C_FLAGS=--coverage -O0 -g'")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_EXE_LINKER_FLAGS=--coverage'")
+
+	cmakeArgs, buildArgs := dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir(), RunTests: true}, "/tmp/build")
+	assert.Contains(t, cmakeArgs, "-DCMAKE_BUILD_TYPE=Release")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_CXX_FLAGS=-O2'")
+	assert.Subset(t, cmakeArgs, cmake.UnsetFlagArgs)
+	assert.NotContains(t, strings.Join(cmakeArgs, " "), "--coverage")
+	assert.Equal(t, []string{"--build", "/tmp/build", "--config", "Release"}, buildArgs)
+}
+
+func TestDockerCMakeArgsHardening(t *testing.T) {
+	cmakeArgs, _ := dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir(), BuildType: "Release", Hardening: true}, "/tmp/build")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_C_FLAGS=-D_FORTIFY_SOURCE=2 -fstack-protector-strong -fPIE'")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_EXE_LINKER_FLAGS=-pie -Wl,-z,relro,-z,now'")
+	assert.NotContains(t, cmakeArgs, "-UCMAKE_C_FLAGS")
+
+	// _FORTIFY_SOURCE does nothing at -O0
+	cmakeArgs, _ = dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir(), BuildType: "Debug", Optimization: "0", Hardening: true}, "/tmp/build")
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_C_FLAGS=-fstack-protector-strong -fPIE'")
+
+	// A later plain build of the same build directory drops the cached flags
+	cmakeArgs, _ = dockerCMakeArgs(build.DockerBuildOptions{ProjectRoot: t.TempDir(), BuildType: "Release"}, "/tmp/build")
+	assert.Subset(t, cmakeArgs, []string{"-UCMAKE_C_FLAGS", "-UCMAKE_EXE_LINKER_FLAGS", "-UCMAKE_SHARED_LINKER_FLAGS"})
+	assert.Contains(t, cmakeArgs, "'-DCMAKE_CXX_FLAGS=-O2'")
+}
+
+func TestBinarySourcesEnv(t *testing.T) {
+	assert.Empty(t, binarySourcesEnv(nil))
+
+	script := binarySourcesEnv([]build.BinarySource{
+		{Type: "gha"},
+		{Type: "nuget", URL: "https://nuget.example.com/index.json", Mode: "read"},
+		{Type: "http", URL: "https://cache.example.com/{sha}.zip", Header: "Authorization: Bearer $TOKEN"},
+	})
+	assert.Contains(t, script, `export VCPKG_BINARY_SOURCES="$VCPKG_BINARY_SOURCES;x-gha,readwrite"`)
+	assert.Contains(t, script, `nugetconfig,/tmp/.vcpkg-nuget-1.config,read"`)
+	assert.Contains(t, script, `nuget,https://nuget.example.com/index.json,read"`)
+	assert.Contains(t, script, `http,https://cache.example.com/{sha}.zip,readwrite,Authorization: Bearer $TOKEN"`)
+	// Credentials are referenced, never inlined
+	assert.Contains(t, script, `value="$NUGET_PASSWORD"`)
+}
+
+func TestDockerBuildBenchmarks(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	var out bytes.Buffer
+	opts := build.DockerBuildOptions{ImageName: "img", ProjectRoot: root, OutputDir: ".bin/ci", CacheDir: ".cache/ci",
+		TargetName: "linux", RunBenchmarks: true, DryRun: true, Output: &out}
+	require.NoError(t, New().RunDockerBuild(context.Background(), opts))
+
+	// Only Google Benchmark reads the variables; other benchmarks get no unknown options
+	assert.Contains(t, out.String(), `BENCHMARK_OUT="/output/linux/bench-results/$(basename "$bench").json" BENCHMARK_OUT_FORMAT=json $bench`)
+	assert.NotContains(t, out.String(), "--benchmark_out")
+}
@@ -0,0 +1,350 @@
+package coverage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+)
+
+var execCommand = exec.Command
+
+var lookPath = exec.LookPath
+
+// Tools are the tools making coverage reports: llvm-cov reads clang's
+// profiles, gcovr and lcov gcc's counters
+var Tools = []string{"llvm-cov", "gcovr", "lcov"}
+
+// LcovFile and HTMLDir are the lcov report and the directory of the HTML
+// report in the output directory
+const (
+	LcovFile = "coverage.lcov"
+	HTMLDir  = "html"
+)
+
+// ignoredFilesRegex matches the files of build and dependency directories,
+// left out of llvm-cov's and gcovr's reports
+const ignoredFilesRegex = `(^|.*/)(\.cache|_deps|vcpkg_installed|external)/.*`
+
+// DetectCompiler returns the compiler family, build.CoverageClang or
+// build.CoverageGCC, of a compiler command such as $CXX or c++
+func DetectCompiler(compiler string) string {
+	output, err := execCommand(compiler, "--version").Output()
+	if err == nil && strings.Contains(strings.ToLower(string(output)), "clang") {
+		return build.CoverageClang
+	}
+	return build.CoverageGCC
+}
+
+// CachedCompiler returns the C++ compiler a CMake build directory is
+// configured with, which changing $CXX does not change, or "" before the
+// first configure
+func CachedCompiler(buildDir string) string {
+	data, err := os.ReadFile(filepath.Join(buildDir, "CMakeCache.txt"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok && strings.HasPrefix(key, "CMAKE_CXX_COMPILER:") {
+			return value
+		}
+	}
+	return ""
+}
+
+// llvmTool returns the command of an LLVM tool: name, else the newest
+// versioned one (llvm-cov-18) installed
+func llvmTool(name string) (string, bool) {
+	if path, err := lookPath(name); err == nil {
+		return path, true
+	}
+	for version := 25; version >= 10; version-- {
+		if path, err := lookPath(fmt.Sprintf("%s-%d", name, version)); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// SelectTool returns the tool making the coverage reports of a compiler
+// family: tool when given, else llvm-cov for clang and gcovr, or lcov
+// without it, for gcc
+func SelectTool(compiler, tool string) (string, error) {
+	if tool != "" && !slices.Contains(Tools, tool) {
+		return "", fmt.Errorf("unknown coverage tool '%s': use %s", tool, strings.Join(Tools, ", "))
+	}
+	if compiler == build.CoverageClang {
+		if tool != "" && tool != "llvm-cov" {
+			return "", fmt.Errorf("%s reads gcc's coverage counters; clang's profiles need llvm-cov", tool)
+		}
+		for _, name := range []string{"llvm-profdata", "llvm-cov"} {
+			if _, ok := llvmTool(name); !ok {
+				return "", fmt.Errorf("%s not found; install LLVM to measure the coverage of clang builds", name)
+			}
+		}
+		return "llvm-cov", nil
+	}
+
+	if tool == "llvm-cov" {
+		return "", fmt.Errorf("llvm-cov reads clang's profiles; use gcovr or lcov with gcc")
+	}
+	candidates := []string{"gcovr", "lcov"}
+	if tool != "" {
+		candidates = []string{tool}
+	}
+	for _, candidate := range candidates {
+		if _, err := lookPath(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found; install gcovr (pip install gcovr) to measure the coverage of gcc builds", strings.Join(candidates, " or "))
+}
+
+// Clean removes the coverage counters and profiles of earlier test runs from
+// a build directory, so a report counts one run
+func Clean(buildDir string) error {
+	if err := os.RemoveAll(filepath.Join(buildDir, "profraw")); err != nil {
+		return fmt.Errorf("failed to remove coverage profiles: %w", err)
+	}
+	err := filepath.WalkDir(buildDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".gcda") {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove coverage counters: %w", err)
+	}
+	return nil
+}
+
+// Options are the options of CMakeReport and BazelReport
+type Options struct {
+	// Root is the project root the report's paths are relative to.
+	Root string
+
+	// BuildDir is the build directory of the instrumented tests, for CMake.
+	BuildDir string
+
+	// OutputDir receives LcovFile and the HTML report.
+	OutputDir string
+
+	// Tool makes the reports, one of Tools, for CMake.
+	Tool string
+
+	// HTML writes the HTML report in HTMLDir of OutputDir.
+	HTML bool
+
+	// Verbose shows the output of the coverage tools.
+	Verbose bool
+}
+
+// run runs a coverage tool in the project root, showing its output when
+// verbose
+func (opts Options) run(name string, args ...string) error {
+	cmd := execCommand(name, args...)
+	cmd.Dir = opts.Root
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if opts.Verbose {
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w\n%s", filepath.Base(name), err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// save normalizes a raw lcov report, writes it as LcovFile and returns it
+func (opts Options) save(raw Report) (Report, error) {
+	report := raw.Normalize(opts.Root)
+	if err := report.SaveLcov(filepath.Join(opts.OutputDir, LcovFile)); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// Write writes a normalized report as LcovFile, and its HTML report when
+// asked and genhtml is installed
+func (opts Options) Write(report Report) error {
+	if err := report.SaveLcov(filepath.Join(opts.OutputDir, LcovFile)); err != nil {
+		return err
+	}
+	return opts.genhtml()
+}
+
+// genhtml writes the HTML report of LcovFile with lcov's genhtml, if
+// installed
+func (opts Options) genhtml() error {
+	if !opts.HTML {
+		return nil
+	}
+	if _, err := lookPath("genhtml"); err != nil {
+		fmt.Printf("%s genhtml not found; skipping the HTML report (install lcov for it)%s\n", colors.Yellow, colors.Reset)
+		return nil
+	}
+	return opts.run("genhtml", filepath.Join(opts.OutputDir, LcovFile), "--branch-coverage", "--quiet", "--output-directory", filepath.Join(opts.OutputDir, HTMLDir))
+}
+
+// CMakeReport writes the lcov and HTML reports of the coverage of the
+// instrumented tests CMake built and ran in BuildDir and returns it
+func CMakeReport(opts Options) (Report, error) {
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return Report{}, fmt.Errorf("failed to create coverage directory: %w", err)
+	}
+	rawLcov := filepath.Join(opts.BuildDir, "coverage-raw.lcov")
+	htmlDir := filepath.Join(opts.OutputDir, HTMLDir)
+	if opts.HTML {
+		if err := os.MkdirAll(htmlDir, 0755); err != nil {
+			return Report{}, fmt.Errorf("failed to create coverage directory: %w", err)
+		}
+	}
+
+	switch opts.Tool {
+	case "llvm-cov":
+		if err := opts.llvmCov(rawLcov, htmlDir); err != nil {
+			return Report{}, err
+		}
+	case "gcovr":
+		args := []string{"--root", opts.Root, "--object-directory", opts.BuildDir, "--exclude", ignoredFilesRegex, "--lcov", rawLcov}
+		if opts.HTML {
+			args = append(args, "--html-details", filepath.Join(htmlDir, "index.html"))
+		}
+		if err := opts.run("gcovr", args...); err != nil {
+			return Report{}, err
+		}
+	case "lcov":
+		if err := opts.run("lcov", "--capture", "--directory", opts.BuildDir, "--output-file", rawLcov, "--rc", "lcov_branch_coverage=1", "--quiet"); err != nil {
+			return Report{}, err
+		}
+	default:
+		return Report{}, fmt.Errorf("unknown coverage tool '%s'", opts.Tool)
+	}
+
+	raw, err := LoadLcov(rawLcov)
+	if err != nil {
+		return Report{}, err
+	}
+	report, err := opts.save(raw)
+	if err != nil {
+		return report, err
+	}
+	if opts.Tool == "lcov" {
+		return report, opts.genhtml()
+	}
+	return report, nil
+}
+
+// llvmCov merges the profiles of the tests' runs and exports their coverage
+// as lcov, and as HTML when asked
+func (opts Options) llvmCov(rawLcov, htmlDir string) error {
+	profiles, _ := filepath.Glob(filepath.Join(opts.BuildDir, "profraw", "*.profraw"))
+	if len(profiles) == 0 {
+		return fmt.Errorf("the tests wrote no coverage profiles to %s", filepath.Join(opts.BuildDir, "profraw"))
+	}
+	profdata, _ := llvmTool("llvm-profdata")
+	llvmCov, _ := llvmTool("llvm-cov")
+	merged := filepath.Join(opts.BuildDir, "coverage.profdata")
+	if err := opts.run(profdata, append([]string{"merge", "-sparse", "-o", merged}, profiles...)...); err != nil {
+		return err
+	}
+
+	binaries, err := testBinaries(opts.BuildDir)
+	if err != nil {
+		return err
+	}
+	args := []string{"-instr-profile=" + merged, "-ignore-filename-regex=" + ignoredFilesRegex, binaries[0]}
+	for _, binary := range binaries[1:] {
+		args = append(args, "-object", binary)
+	}
+
+	var lcov, stderr bytes.Buffer
+	cmd := execCommand(llvmCov, append([]string{"export", "-format=lcov"}, args...)...)
+	cmd.Dir = opts.Root
+	cmd.Stdout, cmd.Stderr = &lcov, &stderr
+	if opts.Verbose {
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("llvm-cov export failed: %w\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := os.WriteFile(rawLcov, lcov.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write coverage report: %w", err)
+	}
+	if opts.HTML {
+		return opts.run(llvmCov, append([]string{"show", "-format=html", "-show-branches=count", "-output-dir=" + htmlDir}, args...)...)
+	}
+	return nil
+}
+
+// testBinaries returns the executables of the tests CTest runs in a build
+// directory
+func testBinaries(buildDir string) ([]string, error) {
+	output, err := execCommand("ctest", "--test-dir", buildDir, "--show-only=json-v1").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tests: %w", err)
+	}
+	var tests struct {
+		Tests []struct {
+			Command []string `json:"command"`
+		} `json:"tests"`
+	}
+	if err := json.Unmarshal(output, &tests); err != nil {
+		return nil, fmt.Errorf("failed to parse the test list: %w", err)
+	}
+	var binaries []string
+	for _, test := range tests.Tests {
+		if len(test.Command) == 0 || slices.Contains(binaries, test.Command[0]) {
+			continue
+		}
+		if info, err := os.Stat(test.Command[0]); err == nil && !info.IsDir() {
+			binaries = append(binaries, test.Command[0])
+		}
+	}
+	if len(binaries) == 0 {
+		return nil, fmt.Errorf("no test executables found in %s", buildDir)
+	}
+	return binaries, nil
+}
+
+// BazelEnv returns the environment `bazel coverage` needs to instrument
+// tests with a compiler family: clang's source-based coverage needs the LLVM
+// tools to read its profiles
+func BazelEnv(compiler string) []string {
+	if compiler != build.CoverageClang {
+		return nil
+	}
+	profdata, _ := llvmTool("llvm-profdata")
+	llvmCov, _ := llvmTool("llvm-cov")
+	return []string{"BAZEL_USE_LLVM_NATIVE_COVERAGE=1", "GCOV=" + profdata, "BAZEL_LLVM_COV=" + llvmCov}
+}
+
+// BazelReport writes the lcov and HTML reports of the combined coverage
+// report of the last `bazel coverage` and returns it
+func BazelReport(opts Options) (Report, error) {
+	output, err := execCommand("bazel", "info", "output_path").Output()
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to locate Bazel's output directory: %w", err)
+	}
+	combined := filepath.Join(strings.TrimSpace(string(output)), "_coverage", "_coverage_report.dat")
+	raw, err := LoadLcov(combined)
+	if err != nil {
+		return Report{}, err
+	}
+	report, err := opts.save(raw)
+	if err != nil {
+		return report, err
+	}
+	return report, opts.genhtml()
+}
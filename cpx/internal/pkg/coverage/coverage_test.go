@@ -0,0 +1,142 @@
+package coverage
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHelperProcess isn't a real test. It's used as a helper process
+// for mocking exec.Command, printing HELPER_OUTPUT.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	_, _ = os.Stdout.WriteString(os.Getenv("HELPER_OUTPUT"))
+	os.Exit(0)
+}
+
+func mockOutput(output string) func(string, ...string) *exec.Cmd {
+	return func(name string, arg ...string) *exec.Cmd {
+		cs := append([]string{"-test.run=TestHelperProcess", "--", name}, arg...)
+		cmd := exec.Command(os.Args[0], cs...)
+		cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1", "HELPER_OUTPUT="+output)
+		return cmd
+	}
+}
+
+func mockLookPath(t *testing.T, installed ...string) {
+	oldLookPath := lookPath
+	t.Cleanup(func() { lookPath = oldLookPath })
+	lookPath = func(name string) (string, error) {
+		for _, tool := range installed {
+			if tool == name {
+				return "/usr/bin/" + name, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+}
+
+func TestDetectCompiler(t *testing.T) {
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+
+	execCommand = mockOutput("Ubuntu clang version 18.1.3\nTarget: x86_64-pc-linux-gnu\n")
+	assert.Equal(t, build.CoverageClang, DetectCompiler("c++"))
+
+	execCommand = mockOutput("g++ (Ubuntu 13.2.0-23ubuntu4) 13.2.0\n")
+	assert.Equal(t, build.CoverageGCC, DetectCompiler("c++"))
+}
+
+func TestSelectTool(t *testing.T) {
+	mockLookPath(t, "llvm-profdata-18", "llvm-cov-18", "lcov")
+
+	tool, err := SelectTool(build.CoverageClang, "")
+	require.NoError(t, err)
+	assert.Equal(t, "llvm-cov", tool)
+	path, ok := llvmTool("llvm-cov")
+	assert.True(t, ok)
+	assert.Equal(t, "/usr/bin/llvm-cov-18", path)
+
+	// gcovr is preferred for gcc, lcov used without it
+	tool, err = SelectTool(build.CoverageGCC, "")
+	require.NoError(t, err)
+	assert.Equal(t, "lcov", tool)
+
+	_, err = SelectTool(build.CoverageGCC, "gcovr")
+	assert.ErrorContains(t, err, "gcovr not found")
+	_, err = SelectTool(build.CoverageGCC, "llvm-cov")
+	assert.ErrorContains(t, err, "use gcovr or lcov with gcc")
+	_, err = SelectTool(build.CoverageClang, "lcov")
+	assert.ErrorContains(t, err, "need llvm-cov")
+	_, err = SelectTool(build.CoverageGCC, "kcov")
+	assert.ErrorContains(t, err, "unknown coverage tool")
+
+	mockLookPath(t, "gcovr", "lcov")
+	tool, err = SelectTool(build.CoverageGCC, "")
+	require.NoError(t, err)
+	assert.Equal(t, "gcovr", tool)
+	_, err = SelectTool(build.CoverageClang, "")
+	assert.ErrorContains(t, err, "llvm-profdata not found")
+}
+
+func TestCachedCompiler(t *testing.T) {
+	buildDir := t.TempDir()
+	assert.Empty(t, CachedCompiler(buildDir))
+
+	cache := "# This is the CMakeCache file.\nCMAKE_BUILD_TYPE:STRING=Debug\nCMAKE_CXX_COMPILER:FILEPATH=/usr/bin/clang++-18\nCMAKE_CXX_COMPILER_AR:FILEPATH=/usr/bin/llvm-ar-18\n"
+	require.NoError(t, os.WriteFile(filepath.Join(buildDir, "CMakeCache.txt"), []byte(cache), 0644))
+	assert.Equal(t, "/usr/bin/clang++-18", CachedCompiler(buildDir))
+}
+
+func TestClean(t *testing.T) {
+	buildDir := t.TempDir()
+	for _, file := range []string{"profraw/1-2.profraw", "CMakeFiles/app.dir/main.cpp.gcda", "CMakeFiles/app.dir/main.cpp.gcno", "CMakeCache.txt"} {
+		path := filepath.Join(buildDir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, nil, 0644))
+	}
+
+	require.NoError(t, Clean(buildDir))
+	assert.NoDirExists(t, filepath.Join(buildDir, "profraw"))
+	assert.NoFileExists(t, filepath.Join(buildDir, "CMakeFiles/app.dir/main.cpp.gcda"))
+	assert.FileExists(t, filepath.Join(buildDir, "CMakeFiles/app.dir/main.cpp.gcno"))
+	assert.FileExists(t, filepath.Join(buildDir, "CMakeCache.txt"))
+
+	assert.NoError(t, Clean(filepath.Join(buildDir, "missing")))
+}
+
+func TestTestBinaries(t *testing.T) {
+	oldExecCommand := execCommand
+	defer func() { execCommand = oldExecCommand }()
+
+	dir := t.TempDir()
+	unitTests := filepath.Join(dir, "unit_tests")
+	require.NoError(t, os.WriteFile(unitTests, nil, 0755))
+
+	execCommand = mockOutput(`{"kind":"ctestInfo","tests":[
+		{"name":"Parser.Parses","command":["` + unitTests + `","--gtest_filter=Parser.Parses"]},
+		{"name":"Parser.Skips","command":["` + unitTests + `","--gtest_filter=Parser.Skips"]},
+		{"name":"gone","command":["` + filepath.Join(dir, "gone") + `"]},
+		{"name":"disabled"}]}`)
+	binaries, err := testBinaries(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{unitTests}, binaries)
+
+	execCommand = mockOutput(`{"tests":[]}`)
+	_, err = testBinaries(dir)
+	assert.ErrorContains(t, err, "no test executables")
+}
+
+func TestBazelEnv(t *testing.T) {
+	mockLookPath(t, "llvm-profdata", "llvm-cov")
+	assert.Nil(t, BazelEnv(build.CoverageGCC))
+	assert.Equal(t, []string{"BAZEL_USE_LLVM_NATIVE_COVERAGE=1", "GCOV=/usr/bin/llvm-profdata", "BAZEL_LLVM_COV=/usr/bin/llvm-cov"}, BazelEnv(build.CoverageClang))
+}
@@ -0,0 +1,127 @@
+package coverage
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+)
+
+// gcovJSON is the part of a gcov JSON report (gcov --json-format, gcc 9+)
+// that is read
+type gcovJSON struct {
+	CurrentWorkingDirectory string `json:"current_working_directory"`
+	Files                   []struct {
+		File      string `json:"file"`
+		Functions []struct {
+			Name           string `json:"name"`
+			StartLine      int    `json:"start_line"`
+			ExecutionCount int64  `json:"execution_count"`
+		} `json:"functions"`
+		Lines []struct {
+			LineNumber int   `json:"line_number"`
+			Count      int64 `json:"count"`
+			Branches   []struct {
+				Count int64 `json:"count"`
+			} `json:"branches"`
+		} `json:"lines"`
+	} `json:"files"`
+}
+
+// ParseGcovJSON reads a gcov JSON report into a report. Relative source
+// paths are made absolute with the directory gcc compiled in. Lines listed
+// more than once, by several instantiations of a template, add up; their
+// branches are told apart by block, the line's occurrence.
+func ParseGcovJSON(reader io.Reader) (Report, error) {
+	var data gcovJSON
+	if err := json.NewDecoder(reader).Decode(&data); err != nil {
+		return Report{}, fmt.Errorf("failed to parse gcov report: %w", err)
+	}
+	report := Report{Files: make(map[string]*FileCoverage)}
+	for _, f := range data.Files {
+		name := f.File
+		if !path.IsAbs(name) && data.CurrentWorkingDirectory != "" {
+			name = path.Join(data.CurrentWorkingDirectory, name)
+		}
+		file := report.file(name)
+		for _, fn := range f.Functions {
+			if existing := file.Functions[fn.Name]; existing != nil {
+				existing.Count += fn.ExecutionCount
+			} else {
+				file.Functions[fn.Name] = &Function{Line: fn.StartLine, Count: fn.ExecutionCount}
+			}
+		}
+		occurrences := make(map[int]int)
+		for _, line := range f.Lines {
+			file.Lines[line.LineNumber] += line.Count
+			block := strconv.Itoa(occurrences[line.LineNumber])
+			occurrences[line.LineNumber]++
+			for i, branch := range line.Branches {
+				count := branch.Count
+				if line.Count == 0 {
+					count = -1
+				}
+				file.merge(&FileCoverage{Branches: map[Branch]int64{{Line: line.LineNumber, Block: block, Branch: strconv.Itoa(i)}: count}})
+			}
+		}
+	}
+	return report, nil
+}
+
+// LoadGcovJSON reads a gcov JSON report, gzipped as gcov writes it or not
+func LoadGcovJSON(path string) (Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to read gcov report: %w", err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return Report{}, fmt.Errorf("failed to read gcov report %s: %w", path, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	report, err := ParseGcovJSON(reader)
+	if err != nil {
+		return Report{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return report, nil
+}
+
+// LoadResults reads the coverage a Docker build collected in a
+// build.CoverageResultsDir: its gcov JSON reports and llvm-cov's lcov
+// report, merged. found is false when the directory holds neither.
+func LoadResults(dir string) (report Report, found bool, err error) {
+	report = Report{Files: make(map[string]*FileCoverage)}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.gcov.json*"))
+	slices.Sort(paths)
+	for _, p := range paths {
+		gcov, err := LoadGcovJSON(p)
+		if err != nil {
+			return report, true, err
+		}
+		report.Merge(gcov)
+		found = true
+	}
+	lcovPath := filepath.Join(dir, build.CoverageLcovFile)
+	if _, statErr := os.Stat(lcovPath); statErr == nil {
+		lcov, err := LoadLcov(lcovPath)
+		if err != nil {
+			return report, true, err
+		}
+		report.Merge(lcov)
+		found = true
+	}
+	return report, found, nil
+}
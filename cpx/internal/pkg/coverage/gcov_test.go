@@ -0,0 +1,80 @@
+package coverage
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	build "github.com/ozacod/cpx/internal/pkg/build/interfaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGcovJSON is a gcov report of a file with a template instantiated twice
+const testGcovJSON = `{"format_version":"1","gcc_version":"13.2.0","current_working_directory":"/workspace/build","data_file":"/tmp/build/CMakeFiles/app.dir/src/parser.cpp.gcda","files":[
+{"file":"/workspace/src/parser.cpp","functions":[
+	{"name":"_Z5parsev","start_line":3,"end_line":8,"execution_count":4,"blocks":4,"blocks_executed":3},
+	{"name":"_Z3maxIiET_S0_S0_","start_line":10,"end_line":10,"execution_count":2,"blocks":2,"blocks_executed":2},
+	{"name":"_Z3maxIdET_S0_S0_","start_line":10,"end_line":10,"execution_count":0,"blocks":2,"blocks_executed":0}],
+ "lines":[
+	{"line_number":3,"count":4,"unexecuted_block":false,"branches":[]},
+	{"line_number":5,"count":4,"unexecuted_block":true,"branches":[{"count":4,"fallthrough":true,"throw":false},{"count":0,"fallthrough":false,"throw":false}]},
+	{"line_number":10,"count":2,"unexecuted_block":false,"branches":[{"count":1,"fallthrough":true,"throw":false},{"count":1,"fallthrough":false,"throw":false}]},
+	{"line_number":10,"count":0,"unexecuted_block":true,"branches":[{"count":0,"fallthrough":true,"throw":false},{"count":0,"fallthrough":false,"throw":false}]}]},
+{"file":"../include/parser.h","functions":[],"lines":[{"line_number":7,"count":1,"branches":[]}]},
+{"file":"/usr/include/c++/13/bits/stl_vector.h","functions":[],"lines":[{"line_number":100,"count":9,"branches":[]}]}]}`
+
+func TestParseGcovJSON(t *testing.T) {
+	report, err := ParseGcovJSON(strings.NewReader(testGcovJSON))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/usr/include/c++/13/bits/stl_vector.h", "/workspace/include/parser.h", "/workspace/src/parser.cpp"}, report.Paths())
+
+	parser := report.Files["/workspace/src/parser.cpp"]
+	assert.Equal(t, map[int]int64{3: 4, 5: 4, 10: 2}, parser.Lines)
+	assert.Equal(t, &Function{Line: 10, Count: 2}, parser.Functions["_Z3maxIiET_S0_S0_"])
+	// Each instantiation's branches are a block of the line; those of one
+	// that never ran were not taken
+	assert.Equal(t, map[Branch]int64{
+		{5, "0", "0"}: 4, {5, "0", "1"}: 0,
+		{10, "0", "0"}: 1, {10, "0", "1"}: 1,
+		{10, "1", "0"}: -1, {10, "1", "1"}: -1,
+	}, parser.Branches)
+	assert.Equal(t, Summary{
+		Lines:     Counts{Found: 3, Hit: 3},
+		Functions: Counts{Found: 3, Hit: 2},
+		Branches:  Counts{Found: 6, Hit: 3},
+	}, parser.Summary())
+
+	_, err = ParseGcovJSON(strings.NewReader("gcov"))
+	assert.ErrorContains(t, err, "failed to parse gcov report")
+}
+
+func TestLoadResults(t *testing.T) {
+	dir := t.TempDir()
+	_, found, err := LoadResults(dir)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// gcov writes gzipped reports
+	f, err := os.Create(filepath.Join(dir, "parser.cpp##0123.gcov.json.gz"))
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(testGcovJSON))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, build.CoverageLcovFile), []byte("SF:/workspace/src/parser.cpp\nDA:4,1\nend_of_record\n"), 0644))
+
+	report, found, err := LoadResults(dir)
+	require.NoError(t, err)
+	assert.True(t, found)
+	normalized := report.Normalize("/workspace")
+	assert.Equal(t, []string{"include/parser.h", "src/parser.cpp"}, normalized.Paths())
+	assert.Equal(t, map[int]int64{3: 4, 4: 1, 5: 4, 10: 2}, normalized.Files["src/parser.cpp"].Lines)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.gcov.json"), []byte("{"), 0644))
+	_, _, err = LoadResults(dir)
+	assert.ErrorContains(t, err, "broken.gcov.json")
+}
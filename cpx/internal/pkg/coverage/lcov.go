@@ -0,0 +1,409 @@
+// Package coverage measures the test coverage of C++ projects: it picks the
+// tool reading the counters of the compiler's instrumentation, reads lcov and
+// gcov JSON reports, and normalizes, merges and writes lcov reports.
+package coverage
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FileCoverage is the coverage of a source file, an lcov record
+type FileCoverage struct {
+	// Path is the file, relative to the project root once normalized.
+	Path string
+
+	// Lines are the execution counts of the instrumented lines, by line
+	// number.
+	Lines map[int]int64
+
+	// Functions are the functions of the file, by name.
+	Functions map[string]*Function
+
+	// Branches are the counts of the branches taken, -1 for those whose
+	// line never ran.
+	Branches map[Branch]int64
+}
+
+// Function is a function of a FileCoverage
+type Function struct {
+	Line  int
+	Count int64
+}
+
+// Branch is a branch of a FileCoverage: its line, block and branch number
+type Branch struct {
+	Line   int
+	Block  string
+	Branch string
+}
+
+// Report is the coverage of the files of a project, by path
+type Report struct {
+	Files map[string]*FileCoverage
+}
+
+// Counts are the number of items, lines, functions or branches, and the
+// number of them covered
+type Counts struct {
+	Found int `json:"found"`
+	Hit   int `json:"hit"`
+}
+
+// Percent returns the percentage of items covered, 100 when there are none
+func (c Counts) Percent() float64 {
+	if c.Found == 0 {
+		return 100
+	}
+	return float64(c.Hit) * 100 / float64(c.Found)
+}
+
+// Add returns the sum of two counts
+func (c Counts) Add(other Counts) Counts {
+	return Counts{Found: c.Found + other.Found, Hit: c.Hit + other.Hit}
+}
+
+// Summary are the coverage counts of a file or report
+type Summary struct {
+	Lines     Counts `json:"lines"`
+	Functions Counts `json:"functions"`
+	Branches  Counts `json:"branches"`
+}
+
+// Add returns the sum of two summaries
+func (s Summary) Add(other Summary) Summary {
+	return Summary{
+		Lines:     s.Lines.Add(other.Lines),
+		Functions: s.Functions.Add(other.Functions),
+		Branches:  s.Branches.Add(other.Branches),
+	}
+}
+
+// Summary counts the lines, functions and branches of a file
+func (f *FileCoverage) Summary() Summary {
+	var s Summary
+	for _, count := range f.Lines {
+		s.Lines.Found++
+		if count > 0 {
+			s.Lines.Hit++
+		}
+	}
+	for _, fn := range f.Functions {
+		s.Functions.Found++
+		if fn.Count > 0 {
+			s.Functions.Hit++
+		}
+	}
+	for _, count := range f.Branches {
+		s.Branches.Found++
+		if count > 0 {
+			s.Branches.Hit++
+		}
+	}
+	return s
+}
+
+// Summary counts the lines, functions and branches of all files of a report
+func (r Report) Summary() Summary {
+	var s Summary
+	for _, f := range r.Files {
+		s = s.Add(f.Summary())
+	}
+	return s
+}
+
+// Paths returns the paths of the files of a report, sorted
+func (r Report) Paths() []string {
+	paths := make([]string, 0, len(r.Files))
+	for path := range r.Files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// newFileCoverage returns the empty coverage of a file
+func newFileCoverage(path string) *FileCoverage {
+	return &FileCoverage{Path: path, Lines: make(map[int]int64), Functions: make(map[string]*Function), Branches: make(map[Branch]int64)}
+}
+
+// file returns the coverage of path in a report, adding it if missing
+func (r *Report) file(path string) *FileCoverage {
+	if r.Files == nil {
+		r.Files = make(map[string]*FileCoverage)
+	}
+	f := r.Files[path]
+	if f == nil {
+		f = newFileCoverage(path)
+		r.Files[path] = f
+	}
+	return f
+}
+
+// merge adds the counts of other to those of f
+func (f *FileCoverage) merge(other *FileCoverage) {
+	for line, count := range other.Lines {
+		f.Lines[line] += count
+	}
+	for name, fn := range other.Functions {
+		if existing := f.Functions[name]; existing != nil {
+			existing.Count += fn.Count
+		} else {
+			f.Functions[name] = &Function{Line: fn.Line, Count: fn.Count}
+		}
+	}
+	for branch, count := range other.Branches {
+		existing, ok := f.Branches[branch]
+		switch {
+		case !ok || existing < 0:
+			f.Branches[branch] = count
+		case count > 0:
+			f.Branches[branch] = existing + count
+		}
+	}
+}
+
+// Merge adds the counts of the files of other to those of r, as if the
+// tests of both ran
+func (r *Report) Merge(other Report) {
+	for path, f := range other.Files {
+		r.file(path).merge(f)
+	}
+}
+
+// ParseLcov reads an lcov tracefile
+func ParseLcov(reader io.Reader) (Report, error) {
+	report := Report{Files: make(map[string]*FileCoverage)}
+	var current *FileCoverage
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, _ := strings.Cut(line, ":")
+		if key == "SF" {
+			current = report.file(value)
+			continue
+		}
+		if key == "end_of_record" {
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		fields := strings.Split(value, ",")
+		var err error
+		switch key {
+		case "DA":
+			var lineNum int
+			var count int64
+			if len(fields) < 2 {
+				err = fmt.Errorf("bad DA")
+			} else if lineNum, err = strconv.Atoi(fields[0]); err == nil {
+				count, err = parseCount(fields[1])
+				current.Lines[lineNum] += max(count, 0)
+			}
+		case "FN":
+			// FN:<line>,<name> or, since lcov 2.2, FN:<line>,<end line>,<name>
+			var lineNum int
+			if len(fields) < 2 {
+				err = fmt.Errorf("bad FN")
+			} else if lineNum, err = strconv.Atoi(fields[0]); err == nil {
+				name := strings.Join(fields[1:], ",")
+				if _, endErr := strconv.Atoi(fields[1]); endErr == nil && len(fields) > 2 {
+					name = strings.Join(fields[2:], ",")
+				}
+				if fn := current.Functions[name]; fn != nil {
+					fn.Line = lineNum
+				} else {
+					current.Functions[name] = &Function{Line: lineNum}
+				}
+			}
+		case "FNDA":
+			var count int64
+			if len(fields) < 2 {
+				err = fmt.Errorf("bad FNDA")
+			} else if count, err = parseCount(fields[0]); err == nil {
+				name := strings.Join(fields[1:], ",")
+				if fn := current.Functions[name]; fn != nil {
+					fn.Count += count
+				} else {
+					current.Functions[name] = &Function{Count: count}
+				}
+			}
+		case "BRDA":
+			var lineNum int
+			if len(fields) < 4 {
+				err = fmt.Errorf("bad BRDA")
+			} else if lineNum, err = strconv.Atoi(fields[0]); err == nil {
+				branch := Branch{Line: lineNum, Block: fields[1], Branch: strings.Join(fields[2:len(fields)-1], ",")}
+				count := int64(-1)
+				if taken := fields[len(fields)-1]; taken != "-" {
+					count, err = parseCount(taken)
+				}
+				current.merge(&FileCoverage{Branches: map[Branch]int64{branch: count}})
+			}
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to parse lcov line %d '%s': %w", n, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return report, fmt.Errorf("failed to read lcov report: %w", err)
+	}
+	return report, nil
+}
+
+// parseCount parses an execution count, which gcov writes as a float for
+// large ones
+func parseCount(s string) (int64, error) {
+	if count, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return count, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return int64(f), err
+}
+
+// LoadLcov reads an lcov tracefile
+func LoadLcov(path string) (Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to open coverage report: %w", err)
+	}
+	defer file.Close()
+	return ParseLcov(file)
+}
+
+// WriteLcov writes a report as an lcov tracefile, files and their items in
+// order
+func (r Report) WriteLcov(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, path := range r.Paths() {
+		f := r.Files[path]
+		s := f.Summary()
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", path)
+
+		names := make([]string, 0, len(f.Functions))
+		for name := range f.Functions {
+			names = append(names, name)
+		}
+		slices.SortFunc(names, func(a, b string) int {
+			return cmp.Or(cmp.Compare(f.Functions[a].Line, f.Functions[b].Line), strings.Compare(a, b))
+		})
+		for _, name := range names {
+			fmt.Fprintf(bw, "FN:%d,%s\n", f.Functions[name].Line, name)
+		}
+		for _, name := range names {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", f.Functions[name].Count, name)
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", s.Functions.Found, s.Functions.Hit)
+
+		branches := make([]Branch, 0, len(f.Branches))
+		for branch := range f.Branches {
+			branches = append(branches, branch)
+		}
+		slices.SortFunc(branches, func(a, b Branch) int {
+			return cmp.Or(cmp.Compare(a.Line, b.Line), compareNumeric(a.Block, b.Block), compareNumeric(a.Branch, b.Branch))
+		})
+		for _, branch := range branches {
+			taken := "-"
+			if count := f.Branches[branch]; count >= 0 {
+				taken = strconv.FormatInt(count, 10)
+			}
+			fmt.Fprintf(bw, "BRDA:%d,%s,%s,%s\n", branch.Line, branch.Block, branch.Branch, taken)
+		}
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", s.Branches.Found, s.Branches.Hit)
+
+		lines := make([]int, 0, len(f.Lines))
+		for line := range f.Lines {
+			lines = append(lines, line)
+		}
+		slices.Sort(lines)
+		for _, line := range lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line, f.Lines[line])
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", s.Lines.Found, s.Lines.Hit)
+	}
+	return bw.Flush()
+}
+
+// compareNumeric compares block or branch numbers as numbers when both are
+func compareNumeric(a, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return cmp.Compare(x, y)
+	}
+	return strings.Compare(a, b)
+}
+
+// SaveLcov writes a report as an lcov tracefile at path
+func (r Report) SaveLcov(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create coverage directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create coverage report: %w", err)
+	}
+	defer file.Close()
+	if err := r.WriteLcov(file); err != nil {
+		return fmt.Errorf("failed to write coverage report: %w", err)
+	}
+	return nil
+}
+
+// ignoredDirs hold build outputs and dependencies rather than the project's
+// sources
+var ignoredDirs = map[string]bool{
+	".cache":          true,
+	".bin":            true,
+	"build":           true,
+	"builddir":        true,
+	"_deps":           true,
+	"vcpkg_installed": true,
+	"external":        true,
+	"subprojects":     true,
+}
+
+// Normalize returns the report of the files in root, outside build and
+// dependency directories, with paths relative to root. Relative paths are
+// relative to root already; files a report has twice are merged.
+func (r Report) Normalize(root string) Report {
+	normalized := Report{Files: make(map[string]*FileCoverage)}
+	for path, f := range r.Files {
+		rel := filepath.Clean(filepath.FromSlash(path))
+		if filepath.IsAbs(rel) || strings.HasPrefix(path, "/") {
+			var err error
+			if rel, err = filepath.Rel(root, rel); err != nil {
+				continue
+			}
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ".." || strings.HasPrefix(rel, "../") || ignoredPath(rel) {
+			continue
+		}
+		normalized.file(rel).merge(f)
+		normalized.Files[rel].Path = rel
+	}
+	return normalized
+}
+
+// ignoredPath reports whether a relative path is in a build or dependency
+// directory
+func ignoredPath(path string) bool {
+	dirs := strings.Split(path, "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		if ignoredDirs[dir] || strings.HasPrefix(dir, "bazel-") || strings.HasPrefix(dir, ".bazel-") {
+			return true
+		}
+	}
+	return false
+}
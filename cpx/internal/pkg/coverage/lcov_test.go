@@ -0,0 +1,160 @@
+package coverage
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLcov = `TN:
+SF:/project/src/parser.cpp
+FN:3,_Z5parsev
+FN:10,20,_Z4skipv
+FNDA:4,_Z5parsev
+FNDA:0,_Z4skipv
+FNF:2
+FNH:1
+BRDA:5,0,0,3
+BRDA:5,0,1,0
+BRDA:12,0,0,-
+BRF:3
+BRH:1
+DA:3,4
+DA:4,4
+DA:5,4
+DA:10,0
+DA:12,0
+LF:5
+LH:3
+end_of_record
+TN:
+SF:/project/.cache/native/vcpkg_installed/x64-linux/include/fmt/core.h
+DA:1,10
+end_of_record
+TN:
+SF:/usr/include/c++/13/vector
+DA:1,10
+end_of_record
+TN:
+SF:include/parser.h
+DA:7,2
+DA:8,0
+end_of_record
+`
+
+func TestParseLcov(t *testing.T) {
+	report, err := ParseLcov(strings.NewReader(testLcov))
+	require.NoError(t, err)
+	require.Len(t, report.Files, 4)
+
+	parser := report.Files["/project/src/parser.cpp"]
+	require.NotNil(t, parser)
+	assert.Equal(t, map[int]int64{3: 4, 4: 4, 5: 4, 10: 0, 12: 0}, parser.Lines)
+	assert.Equal(t, &Function{Line: 3, Count: 4}, parser.Functions["_Z5parsev"])
+	assert.Equal(t, &Function{Line: 10, Count: 0}, parser.Functions["_Z4skipv"])
+	assert.Equal(t, int64(-1), parser.Branches[Branch{Line: 12, Block: "0", Branch: "0"}])
+
+	assert.Equal(t, Summary{
+		Lines:     Counts{Found: 5, Hit: 3},
+		Functions: Counts{Found: 2, Hit: 1},
+		Branches:  Counts{Found: 3, Hit: 1},
+	}, parser.Summary())
+	assert.Equal(t, Counts{Found: 9, Hit: 6}, report.Summary().Lines)
+
+	_, err = ParseLcov(strings.NewReader("SF:a.cpp\nDA:x,1\nend_of_record\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestCountsPercent(t *testing.T) {
+	assert.InDelta(t, 60.0, Counts{Found: 5, Hit: 3}.Percent(), 0.001)
+	assert.InDelta(t, 100.0, Counts{}.Percent(), 0.001)
+}
+
+func TestNormalize(t *testing.T) {
+	report, err := ParseLcov(strings.NewReader(testLcov))
+	require.NoError(t, err)
+
+	normalized := report.Normalize(filepath.FromSlash("/project"))
+	assert.Equal(t, []string{"include/parser.h", "src/parser.cpp"}, normalized.Paths())
+	assert.Equal(t, "src/parser.cpp", normalized.Files["src/parser.cpp"].Path)
+	assert.Equal(t, report.Files["/project/src/parser.cpp"].Summary(), normalized.Files["src/parser.cpp"].Summary())
+}
+
+func TestMerge(t *testing.T) {
+	amd64, err := ParseLcov(strings.NewReader("SF:a.cpp\nFN:1,f\nFNDA:0,f\nBRDA:2,0,0,-\nBRDA:2,0,1,-\nDA:1,0\nDA:2,0\nend_of_record\n"))
+	require.NoError(t, err)
+	arm64, err := ParseLcov(strings.NewReader("SF:a.cpp\nFN:1,f\nFNDA:2,f\nBRDA:2,0,0,1\nBRDA:2,0,1,0\nDA:1,2\nDA:3,1\nend_of_record\nSF:b.cpp\nDA:1,1\nend_of_record\n"))
+	require.NoError(t, err)
+
+	amd64.Merge(arm64)
+	a := amd64.Files["a.cpp"]
+	assert.Equal(t, map[int]int64{1: 2, 2: 0, 3: 1}, a.Lines)
+	assert.Equal(t, int64(2), a.Functions["f"].Count)
+	assert.Equal(t, map[Branch]int64{{2, "0", "0"}: 1, {2, "0", "1"}: 0}, a.Branches)
+	assert.Contains(t, amd64.Files, "b.cpp")
+}
+
+func TestWriteLcov(t *testing.T) {
+	report, err := ParseLcov(strings.NewReader(testLcov))
+	require.NoError(t, err)
+	report = report.Normalize("/project")
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteLcov(&out))
+	assert.Equal(t, `TN:
+SF:include/parser.h
+FNF:0
+FNH:0
+BRF:0
+BRH:0
+DA:7,2
+DA:8,0
+LF:2
+LH:1
+end_of_record
+TN:
+SF:src/parser.cpp
+FN:3,_Z5parsev
+FN:10,_Z4skipv
+FNDA:4,_Z5parsev
+FNDA:0,_Z4skipv
+FNF:2
+FNH:1
+BRDA:5,0,0,3
+BRDA:5,0,1,0
+BRDA:12,0,0,-
+BRF:3
+BRH:1
+DA:3,4
+DA:4,4
+DA:5,4
+DA:10,0
+DA:12,0
+LF:5
+LH:3
+end_of_record
+`, out.String())
+
+	// What it writes reads back the same
+	again, err := ParseLcov(&out)
+	require.NoError(t, err)
+	assert.Equal(t, report, again)
+}
+
+func TestSaveLcov(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", LcovFile)
+	report, err := ParseLcov(strings.NewReader("SF:a.cpp\nDA:1,1\nend_of_record\n"))
+	require.NoError(t, err)
+	require.NoError(t, report.SaveLcov(path))
+
+	loaded, err := LoadLcov(path)
+	require.NoError(t, err)
+	assert.Equal(t, report, loaded)
+
+	_, err = LoadLcov(filepath.Join(t.TempDir(), "missing.lcov"))
+	assert.Error(t, err)
+}
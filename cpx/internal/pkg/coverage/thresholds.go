@@ -0,0 +1,120 @@
+package coverage
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// Kinds are the kinds of coverage a Summary counts
+var Kinds = []string{"lines", "functions", "branches"}
+
+// Counts returns the counts of a kind of coverage, one of Kinds
+func (s Summary) Counts(kind string) Counts {
+	switch kind {
+	case "functions":
+		return s.Functions
+	case "branches":
+		return s.Branches
+	default:
+		return s.Lines
+	}
+}
+
+// Thresholds are the minimum percentages of covered lines, functions and
+// branches; 0 checks nothing
+type Thresholds struct {
+	Lines     float64
+	Functions float64
+	Branches  float64
+}
+
+// Min returns the threshold of a kind of coverage, one of Kinds
+func (t Thresholds) Min(kind string) float64 {
+	switch kind {
+	case "functions":
+		return t.Functions
+	case "branches":
+		return t.Branches
+	default:
+		return t.Lines
+	}
+}
+
+// Shortfall is a kind of coverage below its threshold, or with a threshold
+// but nothing to measure (Unmeasured), as when no branches were recorded
+type Shortfall struct {
+	Kind       string
+	Percent    float64
+	Min        float64
+	Unmeasured bool
+}
+
+// String describes the shortfall, e.g. "lines 72.3% < 80%"
+func (s Shortfall) String() string {
+	if s.Unmeasured {
+		return fmt.Sprintf("%s not measured (min %g%%)", s.Kind, s.Min)
+	}
+	return fmt.Sprintf("%s %.1f%% < %g%%", s.Kind, s.Percent, s.Min)
+}
+
+// Check returns the kinds of coverage of a summary below their thresholds,
+// in the order of Kinds. A kind with a threshold but nothing to measure falls
+// short too, so a missing kind of data cannot pass a gate unnoticed.
+func (s Summary) Check(t Thresholds) []Shortfall {
+	var shortfalls []Shortfall
+	for _, kind := range Kinds {
+		counts, threshold := s.Counts(kind), t.Min(kind)
+		switch {
+		case threshold <= 0:
+		case counts.Found == 0:
+			shortfalls = append(shortfalls, Shortfall{Kind: kind, Min: threshold, Unmeasured: true})
+		case counts.Percent() < threshold:
+			shortfalls = append(shortfalls, Shortfall{Kind: kind, Percent: counts.Percent(), Min: threshold})
+		}
+	}
+	return shortfalls
+}
+
+// DirectoryCoverage is the coverage of the files directly in a directory
+type DirectoryCoverage struct {
+	Dir string
+	Summary
+}
+
+// ByDirectory returns the coverage of the report's files grouped by their
+// directory ("." for the root), by path
+func (r Report) ByDirectory() []DirectoryCoverage {
+	summaries := make(map[string]Summary)
+	for p, file := range r.Files {
+		dir := path.Dir(filepath.ToSlash(p))
+		summaries[dir] = summaries[dir].Add(file.Summary())
+	}
+	dirs := make([]DirectoryCoverage, 0, len(summaries))
+	for dir, summary := range summaries {
+		dirs = append(dirs, DirectoryCoverage{Dir: dir, Summary: summary})
+	}
+	slices.SortFunc(dirs, func(a, b DirectoryCoverage) int { return cmp.Compare(a.Dir, b.Dir) })
+	return dirs
+}
+
+// Laggards returns the directories with uncovered items of a kind, most
+// uncovered first, at most limit of them (0 for all)
+func Laggards(dirs []DirectoryCoverage, kind string, limit int) []DirectoryCoverage {
+	var laggards []DirectoryCoverage
+	for _, dir := range dirs {
+		if counts := dir.Counts(kind); counts.Hit < counts.Found {
+			laggards = append(laggards, dir)
+		}
+	}
+	slices.SortStableFunc(laggards, func(a, b DirectoryCoverage) int {
+		ca, cb := a.Counts(kind), b.Counts(kind)
+		return cmp.Or(cmp.Compare(cb.Found-cb.Hit, ca.Found-ca.Hit), cmp.Compare(ca.Percent(), cb.Percent()))
+	})
+	if limit > 0 && len(laggards) > limit {
+		laggards = laggards[:limit]
+	}
+	return laggards
+}
@@ -0,0 +1,43 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	summary := Summary{
+		Lines:     Counts{Found: 100, Hit: 75},
+		Functions: Counts{Found: 10, Hit: 9},
+	}
+	assert.Empty(t, summary.Check(Thresholds{}))
+	assert.Empty(t, summary.Check(Thresholds{Lines: 75, Functions: 90}))
+	assert.Equal(t, []Shortfall{{Kind: "lines", Percent: 75, Min: 80}}, summary.Check(Thresholds{Lines: 80}))
+
+	// A threshold with nothing to measure falls short
+	shortfalls := summary.Check(Thresholds{Lines: 70, Branches: 60})
+	assert.Equal(t, []Shortfall{{Kind: "branches", Min: 60, Unmeasured: true}}, shortfalls)
+	assert.Equal(t, "branches not measured (min 60%)", shortfalls[0].String())
+	assert.Equal(t, "lines 75.0% < 80%", Shortfall{Kind: "lines", Percent: 75, Min: 80}.String())
+}
+
+func TestByDirectory(t *testing.T) {
+	report, err := ParseLcov(strings.NewReader("SF:src/a.cpp\nDA:1,1\nDA:2,0\nend_of_record\nSF:src/b.cpp\nFN:1,f\nFNDA:0,f\nDA:1,0\nend_of_record\nSF:src/util/c.cpp\nDA:1,1\nend_of_record\nSF:main.cpp\nDA:1,0\nDA:2,0\nend_of_record\n"))
+	require.NoError(t, err)
+
+	dirs := report.ByDirectory()
+	require.Len(t, dirs, 3)
+	assert.Equal(t, []string{".", "src", "src/util"}, []string{dirs[0].Dir, dirs[1].Dir, dirs[2].Dir})
+	assert.Equal(t, Counts{Found: 3, Hit: 1}, dirs[1].Lines)
+	assert.Equal(t, Counts{Found: 1, Hit: 0}, dirs[1].Functions)
+
+	// Most uncovered first, the lower percentage on a tie; covered ones left out
+	laggards := Laggards(dirs, "lines", 0)
+	assert.Equal(t, []string{".", "src"}, []string{laggards[0].Dir, laggards[1].Dir})
+	assert.Len(t, Laggards(dirs, "lines", 1), 1)
+	assert.Len(t, Laggards(dirs, "functions", 0), 1)
+	assert.Empty(t, Laggards(dirs, "branches", 0))
+}
@@ -0,0 +1,157 @@
+package packaging
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var debNameInvalid = regexp.MustCompile(`[^a-z0-9+.-]+`)
+
+// DebName returns a package name Debian accepts: lower case letters,
+// digits, '+', '-' and '.'.
+func DebName(name string) string {
+	return strings.Trim(debNameInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-.+")
+}
+
+// DebFileName returns the conventional file name of a deb, name_version_arch.deb.
+func DebFileName(meta Metadata) string {
+	return fmt.Sprintf("%s_%s_%s.deb", DebName(meta.Name), meta.Version, DebArch(meta.Arch))
+}
+
+// WriteDeb writes a Debian binary package installing files under meta.Prefix.
+func WriteDeb(dest string, meta Metadata, files []File) error {
+	var data, md5sums bytes.Buffer
+	gz := gzip.NewWriter(&data)
+	installed := make([]File, len(files))
+	var size int64
+	for i, f := range files {
+		installed[i] = f
+		installed[i].Path = strings.TrimPrefix(path.Join("/", meta.Prefix, f.Path), "/")
+		sum, n, err := md5File(f.Source)
+		if err != nil {
+			return err
+		}
+		size += n
+		fmt.Fprintf(&md5sums, "%s  %s\n", sum, installed[i].Path)
+	}
+	if err := writeTar(gz, "", installed); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	var control bytes.Buffer
+	gz = gzip.NewWriter(&control)
+	if err := writeControlTar(gz, []byte(debControl(meta, (size+1023)/1024)), md5sums.Bytes()); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return writeFile(dest, func(w io.Writer) error {
+		if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+			return err
+		}
+		for _, m := range []struct {
+			name string
+			data []byte
+		}{
+			{"debian-binary", []byte("2.0\n")},
+			{"control.tar.gz", control.Bytes()},
+			{"data.tar.gz", data.Bytes()},
+		} {
+			if err := writeArMember(w, m.name, m.data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// debControl returns the control file of a deb
+func debControl(meta Metadata, installedKB int64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Package: %s\n", DebName(meta.Name))
+	fmt.Fprintf(&b, "Version: %s\n", meta.Version)
+	fmt.Fprintf(&b, "Architecture: %s\n", DebArch(meta.Arch))
+	fmt.Fprintf(&b, "Maintainer: %s\n", meta.Maintainer)
+	fmt.Fprintf(&b, "Installed-Size: %d\n", installedKB)
+	if len(meta.Depends) > 0 {
+		fmt.Fprintf(&b, "Depends: %s\n", strings.Join(meta.Depends, ", "))
+	}
+	b.WriteString("Section: misc\nPriority: optional\n")
+	if meta.Homepage != "" {
+		fmt.Fprintf(&b, "Homepage: %s\n", meta.Homepage)
+	}
+	// The first line is the synopsis; further lines are indented, blank ones written as " ."
+	lines := strings.Split(strings.TrimSpace(meta.Description), "\n")
+	fmt.Fprintf(&b, "Description: %s\n", lines[0])
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line == "" {
+			line = "."
+		}
+		fmt.Fprintf(&b, " %s\n", line)
+	}
+	return b.String()
+}
+
+// writeControlTar writes the control and md5sums files of a deb as a tar stream
+func writeControlTar(w io.Writer, control, md5sums []byte) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, e := range []struct {
+		name string
+		data []byte
+	}{{"./control", control}, {"./md5sums", md5sums}} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: e.name, Mode: 0644, Size: int64(len(e.data)), ModTime: now}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeArMember writes a member of an ar archive: a 60-byte header, the data
+// and a newline padding it to an even length
+func writeArMember(w io.Writer, name string, data []byte) error {
+	header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, time.Now().Unix(), 0, 0, "100644", len(data))
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data)%2 == 1 {
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+	return nil
+}
+
+// md5File returns the hex MD5 and the size of a file
+func md5File(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := md5.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), n, nil
+}
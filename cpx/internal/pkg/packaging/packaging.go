@@ -0,0 +1,218 @@
+// Package packaging builds distributable packages from CI artifacts:
+// tar.gz and zip archives, and deb and rpm packages.
+package packaging
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Formats are the package formats, in the order they are built.
+var Formats = []string{"tar.gz", "zip", "deb", "rpm"}
+
+// File is a file to package.
+type File struct {
+	// Source is the file on disk.
+	Source string
+
+	// Path is where the file goes, relative to the archive's top directory
+	// or to the install prefix of a deb or rpm, e.g. bin/app.
+	Path string
+
+	// Mode holds the permission bits, e.g. 0755 for executables.
+	Mode os.FileMode
+}
+
+// Metadata describes a deb or rpm package.
+type Metadata struct {
+	Name        string
+	Version     string
+	Arch        string // Go-style architecture, e.g. amd64 or arm64
+	Maintainer  string
+	Description string
+	Homepage    string
+	License     string
+	Depends     []string // deb Depends, rpm Requires
+	Prefix      string   // install prefix, e.g. /usr
+}
+
+// archTokens map the architecture names found in Docker platforms and
+// toolchain names to Go-style architectures.
+var archTokens = map[string]string{
+	"amd64": "amd64", "x86_64": "amd64", "x64": "amd64",
+	"arm64": "arm64", "aarch64": "arm64",
+	"arm": "arm", "armv7": "arm", "armhf": "arm",
+	"386": "386", "i386": "386", "i686": "386", "x86": "386",
+	"riscv64": "riscv64", "ppc64le": "ppc64le", "s390x": "s390x",
+	"wasm": "wasm", "wasm32": "wasm",
+}
+
+// osTokens are the operating systems recognized in platforms and toolchain names.
+var osTokens = []string{"linux", "windows", "darwin", "macos", "freebsd", "wasm", "wasm32"}
+
+var tokenSeparator = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Arch returns the Go-style architecture of a Docker platform (linux/arm64),
+// falling back to the tokens of name (e.g. a toolchain called
+// server-linux-aarch64), and to fallback when neither names one.
+func Arch(platform, name, fallback string) string {
+	for _, s := range []string{platform, name} {
+		tokens := tokenSeparator.Split(strings.ToLower(s), -1)
+		for i, token := range tokens {
+			// Names spanning two tokens (x86_64) are matched before their parts (x86)
+			if i+1 < len(tokens) {
+				if arch, ok := archTokens[token+"_"+tokens[i+1]]; ok {
+					return arch
+				}
+			}
+			if arch, ok := archTokens[token]; ok {
+				return arch
+			}
+		}
+	}
+	return fallback
+}
+
+// OS returns the operating system named by a Docker platform or the tokens
+// of name, and fallback when neither names one.
+func OS(platform, name, fallback string) string {
+	for _, s := range []string{platform, name} {
+		for _, token := range tokenSeparator.Split(strings.ToLower(s), -1) {
+			switch {
+			case token == "macos":
+				return "darwin"
+			case strings.HasPrefix(token, "wasm"):
+				return "wasm"
+			case slices.Contains(osTokens, token):
+				return token
+			}
+		}
+	}
+	return fallback
+}
+
+// DebArch returns the Debian name of an architecture.
+func DebArch(arch string) string {
+	switch arch {
+	case "arm":
+		return "armhf"
+	case "386":
+		return "i386"
+	case "ppc64le":
+		return "ppc64el"
+	}
+	return arch
+}
+
+// RPMArch returns the RPM name of an architecture.
+func RPMArch(arch string) string {
+	switch arch {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "arm":
+		return "armv7hl"
+	case "386":
+		return "i686"
+	}
+	return arch
+}
+
+// WriteTarGz writes a gzip-compressed tarball holding files under the top directory root.
+func WriteTarGz(dest, root string, files []File) error {
+	return writeFile(dest, func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		if err := writeTar(gz, root, files); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+}
+
+// WriteZip writes a zip archive holding files under the top directory root.
+func WriteZip(dest, root string, files []File) error {
+	return writeFile(dest, func(w io.Writer) error {
+		zw := zip.NewWriter(w)
+		for _, f := range files {
+			header := &zip.FileHeader{Name: path.Join(root, f.Path), Method: zip.Deflate, Modified: time.Now()}
+			header.SetMode(f.Mode)
+			fw, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			if err := copyFrom(fw, f.Source); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	})
+}
+
+// writeTar writes files to w as a tar stream under root, with an entry for
+// every parent directory
+func writeTar(w io.Writer, root string, files []File) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		name := path.Join(root, f.Path)
+		for dir := path.Dir(name); dir != "." && dir != "/" && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	for _, dir := range slices.Sorted(maps.Keys(dirs)) {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755, ModTime: now}); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		info, err := os.Stat(f.Source)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Typeflag: tar.TypeReg, Name: path.Join(root, f.Path), Mode: int64(f.Mode.Perm()), Size: info.Size(), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err := copyFrom(tw, f.Source); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeFile creates dest and fills it with write, removing it on failure
+func writeFile(dest string, write func(w io.Writer) error) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if err := write(out); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return out.Close()
+}
+
+// copyFrom copies the content of the file at src to w
+func copyFrom(w io.Writer, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(w, in)
+	return err
+}
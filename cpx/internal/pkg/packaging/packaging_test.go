@@ -0,0 +1,134 @@
+package packaging
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchAndOS(t *testing.T) {
+	assert.Equal(t, "arm64", Arch("linux/arm64", "", "amd64"))
+	assert.Equal(t, "arm64", Arch("", "server-linux-aarch64", "amd64"))
+	assert.Equal(t, "amd64", Arch("", "windows-x64-release", "arm64"))
+	assert.Equal(t, "arm", Arch("linux/arm/v7", "", "amd64"))
+	assert.Equal(t, "riscv64", Arch("", "release", "riscv64"))
+	assert.Equal(t, "amd64", Arch("", "x86_64", "arm64"))
+	assert.Equal(t, "amd64", Arch("", "linux_x86_64", "arm64"))
+	assert.Equal(t, "amd64", Arch("", "linux-x86-64", "arm64"))
+	assert.Equal(t, "386", Arch("", "windows-x86", "amd64"))
+
+	assert.Equal(t, "linux", OS("linux/amd64", "windows", "darwin"))
+	assert.Equal(t, "windows", OS("", "mingw-windows-x64", "linux"))
+	assert.Equal(t, "darwin", OS("", "macos-arm64", "linux"))
+	assert.Equal(t, "linux", OS("", "release", "linux"))
+
+	assert.Equal(t, "armhf", DebArch("arm"))
+	assert.Equal(t, "amd64", DebArch("amd64"))
+	assert.Equal(t, "aarch64", RPMArch("arm64"))
+	assert.Equal(t, "x86_64", RPMArch("amd64"))
+}
+
+func testFiles(t *testing.T) []File {
+	dir := t.TempDir()
+	app := filepath.Join(dir, "app")
+	lib := filepath.Join(dir, "libapp.a")
+	require.NoError(t, os.WriteFile(app, []byte("binary"), 0755))
+	require.NoError(t, os.WriteFile(lib, []byte("archive"), 0644))
+	return []File{
+		{Source: app, Path: "bin/app", Mode: 0755},
+		{Source: lib, Path: "lib/libapp.a", Mode: 0644},
+	}
+}
+
+func TestWriteArchives(t *testing.T) {
+	files := testFiles(t)
+	dest := t.TempDir()
+
+	tarGz := filepath.Join(dest, "app.tar.gz")
+	require.NoError(t, WriteTarGz(tarGz, "app-1.0.0", files))
+	f, err := os.Open(tarGz)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+		if header.Name == "app-1.0.0/bin/app" {
+			assert.Equal(t, int64(0755), header.Mode)
+		}
+	}
+	assert.Equal(t, []string{"app-1.0.0/", "app-1.0.0/bin/", "app-1.0.0/lib/", "app-1.0.0/bin/app", "app-1.0.0/lib/libapp.a"}, names)
+
+	zipFile := filepath.Join(dest, "app.zip")
+	require.NoError(t, WriteZip(zipFile, "app-1.0.0", files))
+	zr, err := zip.OpenReader(zipFile)
+	require.NoError(t, err)
+	defer zr.Close()
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "app-1.0.0/bin/app", zr.File[0].Name)
+	assert.Equal(t, os.FileMode(0755), zr.File[0].Mode().Perm())
+}
+
+func TestWriteDeb(t *testing.T) {
+	meta := Metadata{
+		Name:        "My_App",
+		Version:     "1.2.0",
+		Arch:        "arm64",
+		Maintainer:  "Jane Doe <jane@example.com>",
+		Description: "An app\n\nDoes things.",
+		Depends:     []string{"libc6"},
+		Prefix:      "/usr",
+	}
+	assert.Equal(t, "my-app_1.2.0_arm64.deb", DebFileName(meta))
+
+	control := debControl(meta, 1)
+	assert.Contains(t, control, "Package: my-app\n")
+	assert.Contains(t, control, "Architecture: arm64\n")
+	assert.Contains(t, control, "Depends: libc6\n")
+	assert.Contains(t, control, "Description: An app\n .\n Does things.\n")
+
+	deb := filepath.Join(t.TempDir(), DebFileName(meta))
+	require.NoError(t, WriteDeb(deb, meta, testFiles(t)))
+	data, err := os.ReadFile(deb)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "!<arch>\ndebian-binary   "))
+
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb not installed")
+	}
+	output, err := exec.Command("dpkg-deb", "--contents", deb).CombinedOutput()
+	require.NoError(t, err, string(output))
+	assert.Contains(t, string(output), " usr/bin/app\n")
+	assert.Contains(t, string(output), " usr/lib/libapp.a\n")
+	output, err = exec.Command("dpkg-deb", "--field", deb, "Package", "Version").CombinedOutput()
+	require.NoError(t, err, string(output))
+	assert.Equal(t, "Package: my-app\nVersion: 1.2.0\n", string(output))
+}
+
+func TestRPMSpec(t *testing.T) {
+	meta := Metadata{Name: "My_App", Version: "1.2.0-rc1", Arch: "amd64", Description: "An app", License: "MIT", Depends: []string{"glibc"}, Prefix: "/opt/app"}
+	assert.Equal(t, "my-app-1.2.0_rc1-1.x86_64.rpm", RPMFileName(meta))
+
+	spec := rpmSpec(meta, testFiles(t), "/tmp/staging")
+	assert.Contains(t, spec, "Name: my-app\n")
+	assert.Contains(t, spec, "Version: 1.2.0_rc1\n")
+	assert.Contains(t, spec, "License: MIT\n")
+	assert.Contains(t, spec, "Requires: glibc\n")
+	assert.Contains(t, spec, "cp -a /tmp/staging/. %{buildroot}/\n")
+	assert.Contains(t, spec, "%attr(0755,root,root) /opt/app/bin/app\n")
+	assert.Contains(t, spec, "%attr(0644,root,root) /opt/app/lib/libapp.a\n")
+}
@@ -0,0 +1,100 @@
+package packaging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// RPMVersion returns a version RPM accepts, which may not contain '-'.
+func RPMVersion(version string) string {
+	return strings.ReplaceAll(version, "-", "_")
+}
+
+// RPMFileName returns the conventional file name of an rpm, name-version-release.arch.rpm.
+func RPMFileName(meta Metadata) string {
+	return fmt.Sprintf("%s-%s-1.%s.rpm", DebName(meta.Name), RPMVersion(meta.Version), RPMArch(meta.Arch))
+}
+
+// WriteRPM writes an RPM package installing files under meta.Prefix. It
+// requires rpmbuild.
+func WriteRPM(dest string, meta Metadata, files []File) error {
+	if _, err := exec.LookPath("rpmbuild"); err != nil {
+		return fmt.Errorf("rpmbuild not found in PATH, required to build rpm packages")
+	}
+	topdir, err := os.MkdirTemp("", "cpx-rpm-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(topdir)
+
+	staging := filepath.Join(topdir, "staging")
+	for _, f := range files {
+		target := filepath.Join(staging, filepath.FromSlash(path.Join(meta.Prefix, f.Path)))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := writeFile(target, func(w io.Writer) error { return copyFrom(w, f.Source) }); err != nil {
+			return err
+		}
+		if err := os.Chmod(target, f.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	spec := filepath.Join(topdir, meta.Name+".spec")
+	if err := os.WriteFile(spec, []byte(rpmSpec(meta, files, staging)), 0644); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("rpmbuild", "-bb",
+		"--define", "_topdir "+topdir,
+		"--define", "_rpmdir "+filepath.Join(topdir, "RPMS"),
+		"--define", "_build_name_fmt "+RPMFileName(meta),
+		"--target", RPMArch(meta.Arch),
+		spec)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("rpmbuild failed: %w\n%s", err, strings.TrimSpace(string(output)))
+	}
+	built := filepath.Join(topdir, "RPMS", RPMFileName(meta))
+	return writeFile(dest, func(w io.Writer) error { return copyFrom(w, built) })
+}
+
+// rpmSpec returns the spec file packaging the staged files. The files are
+// copied as they are, so automatic dependencies and debug packages are off.
+func rpmSpec(meta Metadata, files []File, staging string) string {
+	var b strings.Builder
+	// Named as the deb is, which RPM accepts as well
+	fmt.Fprintf(&b, "Name: %s\n", DebName(meta.Name))
+	fmt.Fprintf(&b, "Version: %s\n", RPMVersion(meta.Version))
+	b.WriteString("Release: 1\n")
+	summary, _, _ := strings.Cut(strings.TrimSpace(meta.Description), "\n")
+	fmt.Fprintf(&b, "Summary: %s\n", summary)
+	license := meta.License
+	if license == "" {
+		license = "Proprietary"
+	}
+	fmt.Fprintf(&b, "License: %s\n", license)
+	if meta.Homepage != "" {
+		fmt.Fprintf(&b, "URL: %s\n", meta.Homepage)
+	}
+	if meta.Maintainer != "" {
+		fmt.Fprintf(&b, "Packager: %s\n", meta.Maintainer)
+	}
+	for _, dep := range meta.Depends {
+		fmt.Fprintf(&b, "Requires: %s\n", dep)
+	}
+	b.WriteString("AutoReqProv: no\n")
+	b.WriteString("%define debug_package %{nil}\n")
+	b.WriteString("%define __strip /bin/true\n\n")
+	fmt.Fprintf(&b, "%%description\n%s\n\n", strings.TrimSpace(meta.Description))
+	fmt.Fprintf(&b, "%%install\nmkdir -p %%{buildroot}\ncp -a %s/. %%{buildroot}/\n\n", staging)
+	b.WriteString("%files\n")
+	for _, f := range files {
+		fmt.Fprintf(&b, "%%attr(%04o,root,root) %s\n", f.Mode.Perm(), path.Join("/", meta.Prefix, f.Path))
+	}
+	return b.String()
+}
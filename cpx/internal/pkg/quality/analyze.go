@@ -2,12 +2,15 @@ package quality
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
-	"html/template"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ozacod/cpx/internal/pkg/utils/colors"
@@ -32,6 +35,10 @@ type AnalysisResult struct {
 	Code      string `json:"code,omitempty"`
 	EndLine   int    `json:"end_line,omitempty"`
 	EndColumn int    `json:"end_column,omitempty"`
+
+	// Fingerprint identifies the finding across analyses, by its tool, rule,
+	// file and the code on its line.
+	Fingerprint string `json:"fingerprint,omitempty"`
 }
 
 // ToolResults contains all results from a single tool
@@ -44,17 +51,137 @@ type ToolResults struct {
 
 // ComprehensiveAnalysis contains all results from all tools
 type ComprehensiveAnalysis struct {
-	Timestamp time.Time     `json:"timestamp"`
-	Tools     []ToolResults `json:"tools"`
-	Summary   struct {
+	Timestamp time.Time `json:"timestamp"`
+
+	// Commit is the commit analyzed, and Dirty whether the working tree had
+	// uncommitted changes.
+	Commit string `json:"commit,omitempty"`
+	Dirty  bool   `json:"dirty,omitempty"`
+
+	Tools   []ToolResults `json:"tools"`
+	Summary struct {
 		TotalFindings int            `json:"total_findings"`
 		BySeverity    map[string]int `json:"by_severity"`
 		ByTool        map[string]int `json:"by_tool"`
 	} `json:"summary"`
+
+	// Metrics are the code metrics of the analysis, unless it skipped them.
+	Metrics *MetricsSummary `json:"metrics,omitempty"`
+}
+
+// ReportFormats are the formats RunComprehensiveAnalysis writes reports in
+var ReportFormats = []string{"html", "junit", "checkstyle", "github", "json"}
+
+// AnalyzerNames are the names of the built-in analyzers, as options such as
+// Args know them
+var AnalyzerNames = []string{"cppcheck", "clang-tidy", "flawfinder", "iwyu", "semgrep", "cpplint", "metrics"}
+
+// Report is a report of an analysis
+type Report struct {
+	// Format is one of ReportFormats.
+	Format string
+
+	// Output is the report file. The github format prints to stdout instead.
+	Output string
+}
+
+// AnalyzeOptions are the options of RunComprehensiveAnalysis
+type AnalyzeOptions struct {
+	// Output is the report file. The github format prints to stdout instead.
+	Output string
+
+	// Format is the report format, one of ReportFormats. Default is html.
+	Format string
+
+	// Reports are the reports to write instead of that of Format and
+	// Output.
+	Reports []Report
+
+	SkipCppcheck   bool
+	SkipLint       bool
+	SkipFlawfinder bool
+	SkipIWYU       bool
+	SkipCpplint    bool
+	SkipSemgrep    bool
+	SkipMetrics    bool
+
+	// IWYUFix applies include-what-you-use's suggestions.
+	IWYUFix bool
+
+	// Jobs is the number of clang-tidy processes to run at once, 0 for one
+	// per CPU.
+	Jobs int
+
+	// Metrics are the thresholds of the metrics analyzer.
+	Metrics MetricsThresholds
+
+	// Tools are command tools to run besides the built-in analyzers.
+	Tools []CommandTool
+
+	// SemgrepRulesets are the Semgrep rulesets to run: files, directories or
+	// registry names such as p/c, and BundledSemgrepRules. Default is the
+	// bundled rules.
+	SemgrepRulesets []string
+
+	// Targets are the directories to analyze.
+	Targets []string
+
+	// Args are extra arguments of analyzers, by name: those of
+	// AnalyzerNames or of Tools.
+	Args map[string][]string
+
+	// Include and Exclude are globs of the files whose findings are
+	// reported. Without Include, all files are.
+	Include []string
+	Exclude []string
+
+	// Severities override the severity of findings by rule glob.
+	Severities map[string]string
+
+	// Suppressions is the suppressions file, DefaultSuppressionsFile if empty.
+	Suppressions string
+
+	// Baseline is the baseline file, DefaultBaselineFile if empty. Findings
+	// in it are left out of the report.
+	Baseline string
+
+	// WriteBaseline records all current findings in the baseline file
+	// instead of leaving its findings out.
+	WriteBaseline bool
+
+	// NoHistory leaves the analysis out of the history in HistoryDir.
+	NoHistory bool
+
+	// Container runs the analyzers in a build container instead of on the
+	// host.
+	Container *ContainerAnalysis
 }
 
-// RunComprehensiveAnalysis runs all analysis tools and generates an HTML report
-func RunComprehensiveAnalysis(outputFile string, skipCppcheck, skipLint, skipFlawfinder bool, targets []string, vcpkg VcpkgSetup) error {
+// RunComprehensiveAnalysis runs all analysis tools, writes a report of their
+// findings and returns them
+func RunComprehensiveAnalysis(opts AnalyzeOptions, vcpkg VcpkgSetup) (ComprehensiveAnalysis, error) {
+	reports := opts.Reports
+	if len(reports) == 0 {
+		reports = []Report{{Format: cmp.Or(opts.Format, "html"), Output: opts.Output}}
+	}
+	for _, report := range reports {
+		if !slices.Contains(ReportFormats, report.Format) {
+			return ComprehensiveAnalysis{}, fmt.Errorf("unknown report format '%s': use %s", report.Format, strings.Join(ReportFormats, ", "))
+		}
+	}
+
+	suppressionsFile := cmp.Or(opts.Suppressions, DefaultSuppressionsFile)
+	baselineFile := cmp.Or(opts.Baseline, DefaultBaselineFile)
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ComprehensiveAnalysis{}, err
+	}
+	filter, err := newFindingFilter(cwd, suppressionsFile, baselineFile, opts.WriteBaseline)
+	if err != nil {
+		return ComprehensiveAnalysis{}, err
+	}
+	filter.include, filter.exclude, filter.severities = opts.Include, opts.Exclude, opts.Severities
+
 	fmt.Printf("%sRunning comprehensive code analysis...%s\n", colors.Cyan, colors.Reset)
 
 	analysis := ComprehensiveAnalysis{
@@ -63,46 +190,168 @@ func RunComprehensiveAnalysis(outputFile string, skipCppcheck, skipLint, skipFla
 	}
 	analysis.Summary.BySeverity = make(map[string]int)
 	analysis.Summary.ByTool = make(map[string]int)
+	recordCommit(&analysis, cwd)
 
-	// Run Cppcheck
-	if !skipCppcheck {
-		fmt.Printf("%sRunning Cppcheck...%s\n", colors.Cyan, colors.Reset)
-		cppcheckResults := runCppcheckAnalysis(targets)
-		analysis.Tools = append(analysis.Tools, cppcheckResults)
-		updateSummary(&analysis, cppcheckResults)
+	var tools []ToolResults
+	if opts.Container != nil {
+		fmt.Printf("%sRunning analyzers in the build container...%s\n", colors.Cyan, colors.Reset)
+		if tools, err = opts.Container.run(opts); err != nil {
+			return analysis, err
+		}
+	} else {
+		tools = runHostAnalyzers(opts, vcpkg)
 	}
-
-	// Run clang-tidy
-	if !skipLint {
-		fmt.Printf("%sRunning clang-tidy...%s\n", colors.Cyan, colors.Reset)
-		lintResults := runLintAnalysis(vcpkg)
-		analysis.Tools = append(analysis.Tools, lintResults)
-		updateSummary(&analysis, lintResults)
+	// Metrics are measured on the host, the sources being the same
+	if !opts.SkipMetrics {
+		fmt.Printf("%sMeasuring code metrics...%s\n", colors.Cyan, colors.Reset)
+		results, summary := runMetricsAnalysis(formatFiles(opts.Targets), opts.Metrics)
+		tools = append(tools, results)
+		analysis.Metrics = &summary
 	}
-
-	// Run Flawfinder
-	if !skipFlawfinder {
-		fmt.Printf("%sRunning Flawfinder...%s\n", colors.Cyan, colors.Reset)
-		flawfinderResults := runFlawfinderAnalysis(targets)
-		analysis.Tools = append(analysis.Tools, flawfinderResults)
-		updateSummary(&analysis, flawfinderResults)
+	for _, results := range tools {
+		results = filter.apply(results)
+		analysis.Tools = append(analysis.Tools, results)
+		updateSummary(&analysis, results)
 	}
 
-	// Generate HTML report
-	fmt.Printf("%sGenerating HTML report...%s\n", colors.Cyan, colors.Reset)
-	if err := generateHTMLReport(analysis, outputFile); err != nil {
-		return fmt.Errorf("failed to generate HTML report: %w", err)
+	if opts.WriteBaseline {
+		if err := filter.writeBaseline(baselineFile); err != nil {
+			return analysis, err
+		}
+		fmt.Printf("%sRecorded %d finding(s) in %s; later runs report only new ones%s\n", colors.Green, len(filter.recorded), baselineFile, colors.Reset)
 	}
 
-	fmt.Printf("%sAnalysis complete! Report saved to: %s%s\n", colors.Green, outputFile, colors.Reset)
+	for _, report := range reports {
+		if err := writeReport(analysis, report, cwd); err != nil {
+			return analysis, err
+		}
+	}
+	if !opts.NoHistory {
+		if _, err := SaveHistory(cwd, analysis); err != nil {
+			fmt.Printf("%s Warning: %v%s\n", colors.Yellow, err, colors.Reset)
+		}
+	}
 	fmt.Printf("   Total findings: %d\n", analysis.Summary.TotalFindings)
 	for tool, count := range analysis.Summary.ByTool {
 		fmt.Printf("   %s: %d findings\n", tool, count)
 	}
+	if m := analysis.Metrics; m != nil && m.Files > 0 {
+		fmt.Printf("   Metrics: %d lines of code in %d files, %d functions of complexity %.1f on average (max %d), %d duplicated lines\n",
+			m.Lines, m.Files, m.Functions, m.AverageComplexity, m.MaxComplexity, m.DuplicatedLines)
+	}
+	if filter.suppressed > 0 || filter.baselined > 0 || filter.excluded > 0 {
+		fmt.Printf("   Not reported: %d suppressed, %d in %s, %d in excluded files\n", filter.suppressed, filter.baselined, baselineFile, filter.excluded)
+	}
+
+	return analysis, nil
+}
 
+// writeReport writes a report of an analysis; the paths of the github format
+// are made relative to the root of the git repository root is in, or to root
+func writeReport(analysis ComprehensiveAnalysis, report Report, root string) error {
+	if report.Output != "" {
+		if err := os.MkdirAll(filepath.Dir(report.Output), 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+	}
+	switch report.Format {
+	case "github":
+		// Annotations are matched to the files of the repository by relative paths
+		if top, err := git.TopLevel(root); err == nil {
+			root = top
+		}
+		analysis.RelativizePaths(root)
+		fmt.Print(githubAnnotations(analysis))
+		fmt.Printf("%sAnalysis complete!%s\n", colors.Green, colors.Reset)
+		return nil
+	case "junit":
+		fmt.Printf("%sGenerating JUnit report...%s\n", colors.Cyan, colors.Reset)
+		if err := writeXMLReport(junitReport(analysis), report.Output); err != nil {
+			return fmt.Errorf("failed to generate JUnit report: %w", err)
+		}
+	case "checkstyle":
+		fmt.Printf("%sGenerating Checkstyle report...%s\n", colors.Cyan, colors.Reset)
+		if err := writeXMLReport(newCheckstyleReport(analysis), report.Output); err != nil {
+			return fmt.Errorf("failed to generate Checkstyle report: %w", err)
+		}
+	case "json":
+		fmt.Printf("%sGenerating JSON report...%s\n", colors.Cyan, colors.Reset)
+		if err := writeJSONReport(analysis, report.Output); err != nil {
+			return fmt.Errorf("failed to generate JSON report: %w", err)
+		}
+	default:
+		fmt.Printf("%sGenerating HTML report...%s\n", colors.Cyan, colors.Reset)
+		if err := generateHTMLReport(analysis, report.Output); err != nil {
+			return fmt.Errorf("failed to generate HTML report: %w", err)
+		}
+	}
+	fmt.Printf("%sAnalysis complete! Report saved to: %s%s\n", colors.Green, report.Output, colors.Reset)
 	return nil
 }
 
+// runHostAnalyzers runs the analyzers not skipped by opts on the host, at
+// once, and returns their results in a fixed order
+func runHostAnalyzers(opts AnalyzeOptions, vcpkg VcpkgSetup) []ToolResults {
+	var analyzers []analyzer
+
+	if !opts.SkipCppcheck {
+		analyzers = append(analyzers, analyzer{name: "Cppcheck", run: func(*analyzerProgress) ToolResults {
+			return runCppcheckAnalysis(opts.Targets, opts.Args["cppcheck"])
+		}})
+	}
+	if !opts.SkipLint {
+		analyzers = append(analyzers, analyzer{name: "clang-tidy", run: func(p *analyzerProgress) ToolResults {
+			return runLintAnalysis(vcpkg, opts.Args["clang-tidy"], opts.Jobs, func(done, total int) {
+				p.files("clang-tidy", done, total)
+			})
+		}})
+	}
+	if !opts.SkipFlawfinder {
+		analyzers = append(analyzers, analyzer{name: "Flawfinder", run: func(*analyzerProgress) ToolResults {
+			return runFlawfinderAnalysis(opts.Targets, opts.Args["flawfinder"])
+		}})
+	}
+	if !opts.SkipIWYU {
+		// Its fixes edit the sources the other analyzers read
+		analyzers = append(analyzers, analyzer{name: "include-what-you-use", exclusive: opts.IWYUFix, run: func(*analyzerProgress) ToolResults {
+			return runIWYUAnalysis(opts.IWYUFix, opts.Args["iwyu"])
+		}})
+	}
+	if !opts.SkipSemgrep {
+		analyzers = append(analyzers, analyzer{name: "Semgrep", run: func(*analyzerProgress) ToolResults {
+			return runSemgrepAnalysis(opts.SemgrepRulesets, opts.Targets, opts.Args["semgrep"])
+		}})
+	}
+
+	// cpplint and the command tools
+	commandTools := opts.commandTools()
+	if len(commandTools) > 0 {
+		files := formatFiles(opts.Targets)
+		for _, tool := range commandTools {
+			analyzers = append(analyzers, analyzer{name: tool.Name, run: func(*analyzerProgress) ToolResults {
+				return tool.run(files)
+			}})
+		}
+	}
+	return runAnalyzers(analyzers, &analyzerProgress{out: os.Stdout})
+}
+
+// commandTools returns cpplint, unless skipped, and the tools of opts, with
+// their extra arguments
+func (opts AnalyzeOptions) commandTools() []CommandTool {
+	var tools []CommandTool
+	if !opts.SkipCpplint {
+		tools = append(tools, Cpplint)
+	}
+	tools = append(tools, opts.Tools...)
+	for i, tool := range tools {
+		if args := opts.Args[tool.Name]; len(args) > 0 {
+			tools[i].Command = append(slices.Clip(tool.Command), args...)
+		}
+	}
+	return tools
+}
+
 func updateSummary(analysis *ComprehensiveAnalysis, toolResults ToolResults) {
 	if toolResults.Status == "error" {
 		return
@@ -117,28 +366,29 @@ func updateSummary(analysis *ComprehensiveAnalysis, toolResults ToolResults) {
 	}
 }
 
+// skippedDirs are directories that hold build outputs and external
+// dependencies rather than project sources
+var skippedDirs = map[string]bool{
+	"build":          true,
+	"builddir":       true,
+	"subprojects":    true,
+	"external":       true,
+	".bazel":         true,
+	".cache":         true,
+	"bazel-bin":      true,
+	"bazel-out":      true,
+	"bazel-testlogs": true,
+	"out":            true,
+	"bin":            true,
+	".vcpkg":         true,
+}
+
 // discoverSourceDirectories finds source directories to scan
 // Looks for common directories like src/, include/, lib/, etc.
 // Respects .gitignore by checking if directories contain git-tracked files
 func discoverSourceDirectories(targets []string) []string {
 	var dirs []string
 
-	// Directories to skip (build outputs, external dependencies)
-	skipDirs := map[string]bool{
-		"build":          true,
-		"builddir":       true,
-		"subprojects":    true,
-		"external":       true,
-		".bazel":         true,
-		".cache":         true,
-		"bazel-bin":      true,
-		"bazel-out":      true,
-		"bazel-testlogs": true,
-		"out":            true,
-		"bin":            true,
-		".vcpkg":         true,
-	}
-
 	// Common source directory names
 	commonDirs := []string{"src", "examples", "include", "lib", "libs", "source", "sources", "test", "tests"}
 
@@ -146,7 +396,7 @@ func discoverSourceDirectories(targets []string) []string {
 	if len(targets) > 0 && targets[0] != "." {
 		for _, target := range targets {
 			// Skip directories in the exclude list
-			if skipDirs[target] {
+			if skippedDirs[target] {
 				continue
 			}
 			// Skip bazel-* directories
@@ -219,7 +469,7 @@ func hasCppFiles(dir string) bool {
 	return false
 }
 
-func runCppcheckAnalysis(targets []string) ToolResults {
+func runCppcheckAnalysis(targets, extraArgs []string) ToolResults {
 	result := ToolResults{
 		Tool:    "Cppcheck",
 		Status:  "success",
@@ -285,6 +535,7 @@ func runCppcheckAnalysis(targets []string) ToolResults {
 		args = append(args, "-i"+dir)
 	}
 
+	args = append(args, extraArgs...)
 	args = append(args, sourceDirs...)
 
 	// Run cppcheck - XML will be written directly to the file
@@ -489,7 +740,9 @@ func extractXMLInt(line, attr string) int {
 	return num
 }
 
-func runLintAnalysis(vcpkg VcpkgSetup) ToolResults {
+// runLintAnalysis runs clang-tidy over the project's sources, jobs files at a
+// time (0 for one per CPU), calling progress as files are done
+func runLintAnalysis(vcpkg VcpkgSetup, extraArgs []string, jobs int, progress func(done, total int)) ToolResults {
 	result := ToolResults{
 		Tool:    "clang-tidy",
 		Status:  "success",
@@ -581,18 +834,64 @@ func runLintAnalysis(vcpkg VcpkgSetup) ToolResults {
 	for _, include := range systemIncludes {
 		tidyArgs = append(tidyArgs, "--extra-arg=-isystem"+include)
 	}
-	tidyArgs = append(tidyArgs, files...)
-
-	cmd := exec.Command("clang-tidy", tidyArgs...)
-	output, _ := cmd.CombinedOutput()
+	tidyArgs = append(tidyArgs, extraArgs...)
 
-	// Parse clang-tidy output
-	results := parseClangTidyOutput(string(output))
-	result.Results = results
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	jobs = min(jobs, len(files))
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		done     int
+		next     = make(chan int)
+		findings = make([][]AnalysisResult, len(files))
+	)
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				output, _ := exec.Command("clang-tidy", append(slices.Clip(tidyArgs), files[i])...).CombinedOutput()
+				findings[i] = parseClangTidyOutput(string(output))
+
+				mu.Lock()
+				done++
+				if progress != nil {
+					progress(done, len(files))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
 
+	result.Results = mergeClangTidyFindings(findings)
 	return result
 }
 
+// mergeClangTidyFindings returns the findings of clang-tidy's runs on each
+// file, in order, without the repeats of those in headers several files
+// include
+func mergeClangTidyFindings(findings [][]AnalysisResult) []AnalysisResult {
+	results := []AnalysisResult{}
+	seen := make(map[AnalysisResult]bool)
+	for _, fileFindings := range findings {
+		for _, r := range fileFindings {
+			if !seen[r] {
+				seen[r] = true
+				results = append(results, r)
+			}
+		}
+	}
+	return results
+}
+
 func parseClangTidyOutput(output string) []AnalysisResult {
 	var results []AnalysisResult
 
@@ -687,7 +986,7 @@ func parseClangTidyOutput(output string) []AnalysisResult {
 	return results
 }
 
-func runFlawfinderAnalysis(targets []string) ToolResults {
+func runFlawfinderAnalysis(targets, extraArgs []string) ToolResults {
 	result := ToolResults{
 		Tool:    "Flawfinder",
 		Status:  "success",
@@ -712,6 +1011,7 @@ func runFlawfinderAnalysis(targets []string) ToolResults {
 	// Run flawfinder with CSV output
 	// Pass directories to scan (flawfinder will scan all non-ignored files in those directories)
 	args := []string{"--csv", "-m", "1"}
+	args = append(args, extraArgs...)
 	args = append(args, sourceDirs...)
 
 	cmd := exec.Command("flawfinder", args...)
@@ -847,498 +1147,3 @@ func parseCSVLine(line string) []string {
 
 	return fields
 }
-
-func generateHTMLReport(analysis ComprehensiveAnalysis, outputFile string) error {
-	htmlTemplate := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Cpx Code Analysis Report</title>
-    <style>
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-        }
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
-            background: linear-gradient(135deg, #0a0a1a 0%, #1a1a2e 50%, #16213e 100%);
-            background-attachment: fixed;
-            color: #e2e8f0;
-            padding: 20px;
-            line-height: 1.6;
-            min-height: 100vh;
-        }
-        .container {
-            max-width: 1600px;
-            margin: 0 auto;
-            background: rgba(15, 15, 35, 0.8);
-            backdrop-filter: blur(20px);
-            border: 1px solid rgba(0, 212, 255, 0.2);
-            border-radius: 20px;
-            padding: 40px;
-            box-shadow: 0 20px 60px rgba(0, 0, 0, 0.5);
-        }
-        .header {
-            text-align: center;
-            margin-bottom: 40px;
-            padding-bottom: 30px;
-            border-bottom: 2px solid rgba(0, 212, 255, 0.2);
-        }
-        h1 {
-            background: linear-gradient(135deg, #00d4ff 0%, #00a8cc 100%);
-            -webkit-background-clip: text;
-            -webkit-text-fill-color: transparent;
-            background-clip: text;
-            margin-bottom: 10px;
-            font-size: 3em;
-            font-weight: 800;
-            letter-spacing: -0.02em;
-        }
-        .timestamp {
-            color: #94a3b8;
-            font-size: 0.95em;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            gap: 8px;
-        }
-        .summary {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(180px, 1fr));
-            gap: 20px;
-            margin-bottom: 40px;
-        }
-        .summary-card {
-            background: linear-gradient(135deg, rgba(0, 212, 255, 0.1) 0%, rgba(0, 168, 204, 0.05) 100%);
-            border: 1px solid rgba(0, 212, 255, 0.2);
-            border-radius: 16px;
-            padding: 24px;
-            transition: all 0.3s ease;
-            position: relative;
-            overflow: hidden;
-        }
-        .summary-card::before {
-            content: '';
-            position: absolute;
-            top: 0;
-            left: 0;
-            right: 0;
-            height: 3px;
-            background: linear-gradient(90deg, #00d4ff, #00a8cc);
-        }
-        .summary-card:hover {
-            transform: translateY(-2px);
-            box-shadow: 0 8px 24px rgba(0, 212, 255, 0.2);
-            border-color: rgba(0, 212, 255, 0.4);
-        }
-        .summary-card h3 {
-            color: #94a3b8;
-            font-size: 0.85em;
-            text-transform: uppercase;
-            letter-spacing: 0.05em;
-            margin-bottom: 12px;
-            display: flex;
-            align-items: center;
-            gap: 6px;
-        }
-        .summary-card .value {
-            font-size: 2.5em;
-            font-weight: 800;
-            background: linear-gradient(135deg, #00d4ff 0%, #00a8cc 100%);
-            -webkit-background-clip: text;
-            -webkit-text-fill-color: transparent;
-            background-clip: text;
-        }
-        .tabs-container {
-            margin-top: 40px;
-        }
-        .tabs {
-            display: flex;
-            gap: 12px;
-            margin-bottom: 24px;
-            border-bottom: 2px solid rgba(0, 212, 255, 0.2);
-            overflow-x: auto;
-        }
-        .tab-button {
-            padding: 14px 24px;
-            background: rgba(0, 0, 0, 0.2);
-            border: 1px solid rgba(255, 255, 255, 0.1);
-            border-bottom: none;
-            border-radius: 12px 12px 0 0;
-            color: #94a3b8;
-            font-size: 1em;
-            font-weight: 600;
-            cursor: pointer;
-            transition: all 0.3s ease;
-            display: flex;
-            align-items: center;
-            gap: 10px;
-            white-space: nowrap;
-            position: relative;
-            top: 2px;
-        }
-        .tab-button:hover {
-            background: rgba(0, 212, 255, 0.1);
-            color: #00d4ff;
-            border-color: rgba(0, 212, 255, 0.3);
-        }
-        .tab-button.active {
-            background: linear-gradient(135deg, rgba(0, 212, 255, 0.15) 0%, rgba(0, 168, 204, 0.1) 100%);
-            color: #00d4ff;
-            border-color: rgba(0, 212, 255, 0.3);
-            border-bottom-color: transparent;
-            top: 0;
-        }
-        .tab-content {
-            display: none;
-            background: rgba(0, 0, 0, 0.2);
-            border-radius: 0 12px 12px 12px;
-            padding: 24px;
-            border: 1px solid rgba(255, 255, 255, 0.05);
-            animation: fadeIn 0.3s ease;
-        }
-        .tab-content.active {
-            display: block;
-        }
-        @keyframes fadeIn {
-            from { opacity: 0; transform: translateY(10px); }
-            to { opacity: 1; transform: translateY(0); }
-        }
-        .tool-header {
-            display: flex;
-            justify-content: space-between;
-            align-items: center;
-            padding: 16px 20px;
-            background: linear-gradient(135deg, rgba(0, 212, 255, 0.1) 0%, rgba(0, 168, 204, 0.05) 100%);
-            border: 1px solid rgba(0, 212, 255, 0.2);
-            border-radius: 12px;
-            margin-bottom: 20px;
-        }
-        .tool-header h2 {
-            color: #00d4ff;
-            font-size: 1.4em;
-            font-weight: 700;
-            display: flex;
-            align-items: center;
-            gap: 12px;
-        }
-        .tool-icon {
-            font-size: 1.2em;
-        }
-        .tool-status {
-            padding: 8px 18px;
-            border-radius: 20px;
-            font-size: 0.85em;
-            font-weight: 600;
-            text-transform: uppercase;
-            letter-spacing: 0.05em;
-        }
-        .status-success {
-            background: linear-gradient(135deg, rgba(34, 197, 94, 0.2) 0%, rgba(22, 163, 74, 0.1) 100%);
-            color: #22c55e;
-            border: 1px solid rgba(34, 197, 94, 0.3);
-        }
-        .status-error {
-            background: linear-gradient(135deg, rgba(239, 68, 68, 0.2) 0%, rgba(220, 38, 38, 0.1) 100%);
-            color: #ef4444;
-            border: 1px solid rgba(239, 68, 68, 0.3);
-        }
-        .status-skipped {
-            background: rgba(148, 163, 184, 0.15);
-            color: #94a3b8;
-            border: 1px solid rgba(148, 163, 184, 0.2);
-        }
-        .findings-table {
-            width: 100%;
-            border-collapse: separate;
-            border-spacing: 0;
-            background: rgba(0, 0, 0, 0.3);
-            border-radius: 12px;
-            overflow: hidden;
-            box-shadow: 0 4px 12px rgba(0, 0, 0, 0.3);
-        }
-        .findings-table th {
-            background: linear-gradient(135deg, rgba(0, 212, 255, 0.15) 0%, rgba(0, 168, 204, 0.1) 100%);
-            padding: 16px;
-            text-align: left;
-            color: #00d4ff;
-            font-weight: 700;
-            font-size: 0.9em;
-            text-transform: uppercase;
-            letter-spacing: 0.05em;
-            border-bottom: 2px solid rgba(0, 212, 255, 0.3);
-        }
-        .findings-table th:first-child { border-top-left-radius: 12px; }
-        .findings-table th:last-child { border-top-right-radius: 12px; }
-        .findings-table td {
-            padding: 16px;
-            border-bottom: 1px solid rgba(255, 255, 255, 0.05);
-        }
-        .findings-table tbody tr {
-            transition: all 0.2s ease;
-        }
-        .findings-table tbody tr:nth-child(even) {
-            background: rgba(255, 255, 255, 0.02);
-        }
-        .findings-table tbody tr:hover {
-            background: rgba(0, 212, 255, 0.1);
-            transform: scale(1.01);
-        }
-        .findings-table tbody tr:last-child td:first-child {
-            border-bottom-left-radius: 12px;
-        }
-        .findings-table tbody tr:last-child td:last-child {
-            border-bottom-right-radius: 12px;
-        }
-        .severity {
-            padding: 6px 14px;
-            border-radius: 8px;
-            font-size: 0.8em;
-            font-weight: 700;
-            display: inline-flex;
-            align-items: center;
-            gap: 6px;
-            text-transform: uppercase;
-            letter-spacing: 0.05em;
-        }
-        .severity-error {
-            background: linear-gradient(135deg, rgba(239, 68, 68, 0.25) 0%, rgba(220, 38, 38, 0.15) 100%);
-            color: #ff6b6b;
-            border: 1px solid rgba(239, 68, 68, 0.4);
-        }
-        .severity-warning {
-            background: linear-gradient(135deg, rgba(251, 191, 36, 0.25) 0%, rgba(245, 158, 11, 0.15) 100%);
-            color: #fbbf24;
-            border: 1px solid rgba(251, 191, 36, 0.4);
-        }
-        .severity-info {
-            background: linear-gradient(135deg, rgba(59, 130, 246, 0.25) 0%, rgba(37, 99, 235, 0.15) 100%);
-            color: #60a5fa;
-            border: 1px solid rgba(59, 130, 246, 0.4);
-        }
-        .file-path {
-            font-family: 'Monaco', 'Menlo', 'Ubuntu Mono', 'Courier New', monospace;
-            color: #cbd5e1;
-            font-size: 0.9em;
-            background: rgba(0, 0, 0, 0.3);
-            padding: 4px 8px;
-            border-radius: 6px;
-            display: inline-block;
-        }
-        .line-number {
-            color: #00d4ff;
-            font-weight: 700;
-            font-size: 1.1em;
-            background: rgba(0, 212, 255, 0.15);
-            padding: 4px 10px;
-            border-radius: 6px;
-            display: inline-block;
-            font-family: 'Monaco', 'Menlo', 'Ubuntu Mono', monospace;
-        }
-        .message {
-            color: #e2e8f0;
-            line-height: 1.5;
-            max-width: 500px;
-        }
-        .rule {
-            color: #94a3b8;
-            font-size: 0.85em;
-            font-family: 'Monaco', 'Menlo', 'Ubuntu Mono', monospace;
-            background: rgba(148, 163, 184, 0.1);
-            padding: 4px 8px;
-            border-radius: 6px;
-            display: inline-block;
-        }
-        .no-findings {
-            text-align: center;
-            padding: 60px 40px;
-            color: #22c55e;
-            font-size: 1.3em;
-            background: rgba(34, 197, 94, 0.1);
-            border: 2px dashed rgba(34, 197, 94, 0.3);
-            border-radius: 12px;
-        }
-        .error-message {
-            background: linear-gradient(135deg, rgba(239, 68, 68, 0.15) 0%, rgba(220, 38, 38, 0.1) 100%);
-            border: 1px solid rgba(239, 68, 68, 0.4);
-            border-radius: 12px;
-            padding: 20px;
-            color: #ff6b6b;
-            margin-top: 10px;
-            display: flex;
-            align-items: center;
-            gap: 12px;
-        }
-        .severity-icon {
-            font-size: 1.1em;
-        }
-        @media (max-width: 768px) {
-            .container {
-                padding: 20px;
-            }
-            h1 {
-                font-size: 2em;
-            }
-            .summary {
-                grid-template-columns: 1fr;
-            }
-            .tabs {
-                flex-direction: column;
-                border-bottom: none;
-            }
-            .tab-button {
-                border-radius: 12px;
-                border: 1px solid rgba(255, 255, 255, 0.1);
-                top: 0;
-                margin-bottom: 8px;
-            }
-            .tab-button.active {
-                border-color: rgba(0, 212, 255, 0.3);
-            }
-            .tab-content {
-                border-radius: 12px;
-            }
-            .findings-table {
-                font-size: 0.85em;
-            }
-            .findings-table th,
-            .findings-table td {
-                padding: 10px;
-            }
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>Cpx Code Analysis Report</h1>
-            <div class="timestamp">
-                <span></span>
-                <span>Generated: {{.Timestamp.Format "2006-01-02 15:04:05"}}</span>
-            </div>
-        </div>
-
-        <div class="summary">
-            <div class="summary-card">
-                <h3>Total Findings</h3>
-                <div class="value">{{.Summary.TotalFindings}}</div>
-            </div>
-            {{range $severity, $count := .Summary.BySeverity}}
-            <div class="summary-card">
-                <h3>
-                    {{if eq $severity "error"}}{{else if eq $severity "warning"}}{{else}}{{end}}
-                    {{$severity}}
-                </h3>
-                <div class="value">{{$count}}</div>
-            </div>
-            {{end}}
-        </div>
-
-        <div class="tabs-container">
-            <div class="tabs">
-                {{range $index, $tool := .Tools}}
-                <button class="tab-button {{if eq $index 0}}active{{end}}" onclick="showTab({{$index}})">
-                    <span>
-                        {{if eq $tool.Tool "Cppcheck"}}{{else if eq $tool.Tool "clang-tidy"}}{{else if eq $tool.Tool "Flawfinder"}}{{else}}{{end}}
-                    </span>
-                    <span>{{$tool.Tool}}</span>
-                    {{if gt (len $tool.Results) 0}}
-                    <span style="font-size: 0.85em; opacity: 0.7;">({{len $tool.Results}})</span>
-                    {{end}}
-                </button>
-                {{end}}
-            </div>
-
-            {{range $index, $tool := .Tools}}
-            <div class="tab-content {{if eq $index 0}}active{{end}}" id="tab-{{$index}}">
-                <div class="tool-header">
-                    <h2>
-                        <span class="tool-icon">
-                            {{if eq $tool.Tool "Cppcheck"}}{{else if eq $tool.Tool "clang-tidy"}}{{else if eq $tool.Tool "Flawfinder"}}{{else}}{{end}}
-                        </span>
-                        {{$tool.Tool}}
-                        {{if gt (len $tool.Results) 0}}
-                        <span style="font-size: 0.7em; color: #94a3b8; font-weight: 400;">({{len $tool.Results}} findings)</span>
-                        {{end}}
-                    </h2>
-                    <span class="tool-status status-{{$tool.Status}}">{{$tool.Status}}</span>
-                </div>
-                {{if $tool.Error}}
-                <div class="error-message">
-                    <span></span>
-                    <span>Error: {{$tool.Error}}</span>
-                </div>
-                {{else if eq (len $tool.Results) 0}}
-                <div class="no-findings">No issues found!</div>
-                {{else}}
-                <table class="findings-table">
-                    <thead>
-                        <tr>
-                            <th>Severity</th>
-                            <th>File</th>
-                            <th>Line</th>
-                            <th>Message</th>
-                            <th>Rule</th>
-                        </tr>
-                    </thead>
-                    <tbody>
-                        {{range $tool.Results}}
-                        <tr>
-                            <td>
-                                <span class="severity severity-{{.Severity}}">
-                                    <span class="severity-icon">
-                                        {{if eq .Severity "error"}}{{else if eq .Severity "warning"}}{{else}}{{end}}
-                                    </span>
-                                    {{.Severity}}
-                                </span>
-                            </td>
-                            <td><span class="file-path">{{.File}}</span></td>
-                            <td><span class="line-number">{{.Line}}</span></td>
-                            <td><span class="message">{{.Message}}</span></td>
-                            <td><span class="rule">{{.Rule}}</span></td>
-                        </tr>
-                        {{end}}
-                    </tbody>
-                </table>
-                {{end}}
-            </div>
-            {{end}}
-        </div>
-    </div>
-
-    <script>
-        function showTab(index) {
-            // Hide all tabs
-            const tabs = document.querySelectorAll('.tab-content');
-            const buttons = document.querySelectorAll('.tab-button');
-
-            tabs.forEach(tab => tab.classList.remove('active'));
-            buttons.forEach(btn => btn.classList.remove('active'));
-
-            // Show selected tab
-            document.getElementById('tab-' + index).classList.add('active');
-            buttons[index].classList.add('active');
-        }
-    </script>
-</body>
-</html>`
-
-	tmpl, err := template.New("report").Parse(htmlTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
-	}
-
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer file.Close()
-
-	if err := tmpl.Execute(file, analysis); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
-	}
-
-	return nil
-}
@@ -532,7 +532,7 @@ func TestDiscoverSourceDirectories(t *testing.T) {
 		{
 			name:    "Skip build directory",
 			targets: []string{"build"},
-			// build is in skipDirs, so should return empty
+			// build is in skippedDirs, so should return empty
 		},
 	}
 
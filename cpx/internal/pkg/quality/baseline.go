@@ -0,0 +1,293 @@
+package quality
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/glob"
+)
+
+const (
+	// DefaultBaselineFile records the accepted findings of a project
+	DefaultBaselineFile = ".cpx-baseline.json"
+
+	// DefaultSuppressionsFile lists the rules to suppress, by file
+	DefaultSuppressionsFile = ".cpx-suppressions"
+)
+
+// inlineIgnore is a cpx-ignore comment, with the rules it ignores; without
+// rules it ignores every finding
+var inlineIgnore = regexp.MustCompile(`cpx-ignore(?:\(([^)]*)\))?`)
+
+// baselineEntry is a finding recorded in a baseline
+type baselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Tool        string `json:"tool"`
+	Rule        string `json:"rule,omitempty"`
+	File        string `json:"file"`
+	Message     string `json:"message"`
+}
+
+// analysisBaseline is the file of --write-baseline
+type analysisBaseline struct {
+	Version  int             `json:"version"`
+	Findings []baselineEntry `json:"findings"`
+}
+
+// suppression is a line of a suppressions file: a rule glob and an optional
+// file glob
+type suppression struct {
+	Rule string
+	File string
+}
+
+// findingFilter drops the findings that are suppressed or in the baseline
+type findingFilter struct {
+	root         string
+	suppressions []suppression
+	baseline     map[string]int // fingerprint -> findings it still accepts
+	sources      map[string][]string
+
+	// include and exclude are globs of the files whose findings are kept
+	include []string
+	exclude []string
+
+	// severities override the severity of findings by rule glob
+	severities map[string]string
+
+	// recorded are the findings to write to a new baseline
+	recorded []baselineEntry
+
+	suppressed int
+	baselined  int
+	excluded   int
+}
+
+// newFindingFilter returns the filter of the suppressions file and, unless
+// a new baseline is written, the baseline file of a project. Missing files
+// suppress nothing.
+func newFindingFilter(root, suppressionsFile, baselineFile string, writeBaseline bool) (*findingFilter, error) {
+	f := &findingFilter{root: root, sources: make(map[string][]string)}
+	if suppressionsFile != "" {
+		suppressions, err := readSuppressions(suppressionsFile)
+		if err != nil {
+			return nil, err
+		}
+		f.suppressions = suppressions
+	}
+	if baselineFile != "" && !writeBaseline {
+		data, err := os.ReadFile(baselineFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", baselineFile, err)
+		}
+		if err == nil {
+			var baseline analysisBaseline
+			if err := json.Unmarshal(data, &baseline); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", baselineFile, err)
+			}
+			f.baseline = make(map[string]int)
+			for _, e := range baseline.Findings {
+				f.baseline[e.Fingerprint]++
+			}
+		}
+	}
+	return f, nil
+}
+
+// readSuppressions reads a suppressions file: a rule glob per line, such as
+// modernize-* or strcpy, optionally followed by a file glob or directory.
+// # starts a comment.
+func readSuppressions(file string) ([]suppression, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	var suppressions []suppression
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			suppressions = append(suppressions, suppression{Rule: fields[0]})
+		case 2:
+			suppressions = append(suppressions, suppression{Rule: fields[0], File: fields[1]})
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a rule and an optional file, got %q", file, n, strings.TrimSpace(line))
+		}
+		if _, err := path.Match(fields[0], ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid rule pattern %q", file, n, fields[0])
+		}
+	}
+	return suppressions, nil
+}
+
+// apply drops the findings of a tool in excluded files, suppressed or
+// baselined, and overrides the severity of the others
+func (f *findingFilter) apply(results ToolResults) ToolResults {
+	kept := results.Results[:0:0]
+	for _, r := range results.Results {
+		if f.isExcluded(r) {
+			f.excluded++
+			continue
+		}
+		if severity, ok := f.severity(r); ok {
+			r.Severity = severity
+		}
+		if f.isSuppressed(r) {
+			f.suppressed++
+			continue
+		}
+		fingerprint := f.fingerprint(r)
+		r.Fingerprint = fingerprint
+		f.recorded = append(f.recorded, baselineEntry{
+			Fingerprint: fingerprint,
+			Tool:        r.Tool,
+			Rule:        r.Rule,
+			File:        relativePath(r.File, f.root),
+			Message:     r.Message,
+		})
+		if f.baseline[fingerprint] > 0 {
+			f.baseline[fingerprint]--
+			f.baselined++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	results.Results = kept
+	return results
+}
+
+// isExcluded reports whether a finding is in a file the include globs do not
+// match or an exclude glob matches
+func (f *findingFilter) isExcluded(r AnalysisResult) bool {
+	if len(f.include) == 0 && len(f.exclude) == 0 {
+		return false
+	}
+	file := relativePath(r.File, f.root)
+	if len(f.include) > 0 && !glob.MatchAny(f.include, file) {
+		return true
+	}
+	return glob.MatchAny(f.exclude, file)
+}
+
+// severity returns the overridden severity of a finding: that of the longest
+// rule glob matching its rule, the first in sort order among globs as long
+func (f *findingFilter) severity(r AnalysisResult) (string, bool) {
+	best := ""
+	for pattern := range f.severities {
+		longer := len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best)
+		if longer && ruleMatches(pattern, r) {
+			best = pattern
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return f.severities[best], true
+}
+
+// isSuppressed reports whether a finding is suppressed by the suppressions
+// file or a cpx-ignore comment on its line or the line above
+func (f *findingFilter) isSuppressed(r AnalysisResult) bool {
+	file := relativePath(r.File, f.root)
+	for _, s := range f.suppressions {
+		if !ruleMatches(s.Rule, r) {
+			continue
+		}
+		if s.File == "" {
+			return true
+		}
+		if ok, _ := path.Match(s.File, file); ok || strings.HasPrefix(file, strings.TrimSuffix(s.File, "/")+"/") {
+			return true
+		}
+	}
+
+	lines := f.source(r.File)
+	if r.Line < 1 || r.Line > len(lines) {
+		return false
+	}
+	candidates := []string{lines[r.Line-1]}
+	if r.Line > 1 {
+		if above := strings.TrimSpace(lines[r.Line-2]); strings.HasPrefix(above, "//") || strings.HasPrefix(above, "/*") {
+			candidates = append(candidates, above)
+		}
+	}
+	for _, line := range candidates {
+		for _, m := range inlineIgnore.FindAllStringSubmatch(line, -1) {
+			if m[1] == "" {
+				return true
+			}
+			for _, rule := range strings.Split(m[1], ",") {
+				if ruleMatches(strings.TrimSpace(rule), r) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ruleMatches reports whether a rule glob matches the rule of a finding, or
+// the name after its category for rules such as Flawfinder's "buffer: strcpy"
+func ruleMatches(pattern string, r AnalysisResult) bool {
+	rule := findingRule(r)
+	if ok, _ := path.Match(pattern, rule); ok {
+		return true
+	}
+	if _, name, found := strings.Cut(rule, ": "); found {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+	return false
+}
+
+// fingerprint identifies a finding by its tool, rule, file and the code on its
+// line, so it still matches the baseline when lines above it change
+func (f *findingFilter) fingerprint(r AnalysisResult) string {
+	context := ""
+	if lines := f.source(r.File); r.Line >= 1 && r.Line <= len(lines) {
+		context = strings.Join(strings.Fields(lines[r.Line-1]), " ")
+	}
+	rule := r.Rule
+	if rule == "" {
+		rule = r.Message
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{r.Tool, rule, relativePath(r.File, f.root), context}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// source returns the lines of a file, reading it once
+func (f *findingFilter) source(file string) []string {
+	lines, ok := f.sources[file]
+	if !ok {
+		data, _ := os.ReadFile(file)
+		lines = splitLines(string(data))
+		f.sources[file] = lines
+	}
+	return lines
+}
+
+// writeBaseline writes the findings seen by the filter as a baseline
+func (f *findingFilter) writeBaseline(file string) error {
+	data, err := json.MarshalIndent(analysisBaseline{Version: 1, Findings: f.recorded}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
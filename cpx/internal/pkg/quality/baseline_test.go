@@ -0,0 +1,126 @@
+package quality
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for file, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	}
+}
+
+// withoutFingerprints returns findings with their fingerprints cleared
+func withoutFingerprints(results []AnalysisResult) []AnalysisResult {
+	for i := range results {
+		results[i].Fingerprint = ""
+	}
+	return results
+}
+
+func TestReadSuppressions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{DefaultSuppressionsFile: "# legacy code\nmodernize-*  src/legacy/\n\nstrcpy # flawfinder\n"})
+
+	suppressions, err := readSuppressions(DefaultSuppressionsFile)
+	require.NoError(t, err)
+	assert.Equal(t, []suppression{{Rule: "modernize-*", File: "src/legacy/"}, {Rule: "strcpy"}}, suppressions)
+
+	suppressions, err = readSuppressions("missing")
+	require.NoError(t, err)
+	assert.Empty(t, suppressions)
+
+	writeFiles(t, map[string]string{"bad": "a b c\n"})
+	_, err = readSuppressions("bad")
+	assert.ErrorContains(t, err, "bad:1")
+}
+
+func TestFindingFilterSuppressions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{
+		DefaultSuppressionsFile: "modernize-* src/legacy\nstrcpy\n",
+		"src/main.cpp":          "int a; // cpx-ignore(readability-x, misc-y)\n// cpx-ignore\nint b;\nint c; // cpx-ignore(other)\nint d;\n",
+	})
+	f, err := newFindingFilter(".", DefaultSuppressionsFile, "", false)
+	require.NoError(t, err)
+
+	results := f.apply(ToolResults{Tool: "clang-tidy", Results: []AnalysisResult{
+		{Tool: "clang-tidy", File: "src/main.cpp", Line: 1, Rule: "misc-y"},
+		{Tool: "clang-tidy", File: "src/main.cpp", Line: 3, Rule: "anything"},
+		{Tool: "clang-tidy", File: "src/main.cpp", Line: 4, Rule: "misc-y"},
+		{Tool: "clang-tidy", File: "src/legacy/old.cpp", Line: 1, Rule: "modernize-use-auto"},
+		{Tool: "clang-tidy", File: "src/new.cpp", Line: 1, Rule: "modernize-use-auto"},
+		{Tool: "Flawfinder", File: "src/main.cpp", Line: 5, Rule: "buffer: strcpy"},
+	}})
+	assert.Equal(t, []AnalysisResult{
+		{Tool: "clang-tidy", File: "src/main.cpp", Line: 4, Rule: "misc-y"},
+		{Tool: "clang-tidy", File: "src/new.cpp", Line: 1, Rule: "modernize-use-auto"},
+	}, withoutFingerprints(results.Results))
+	assert.Equal(t, 4, f.suppressed)
+}
+
+func TestFindingFilterBaseline(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{"src/main.cpp": "int a;\nint b;\n"})
+	findings := ToolResults{Tool: "Cppcheck", Results: []AnalysisResult{
+		{Tool: "Cppcheck", File: "src/main.cpp", Line: 1, Rule: "unusedVariable"},
+		{Tool: "Cppcheck", File: "src/main.cpp", Line: 2, Rule: "unusedVariable"},
+	}}
+
+	writer, err := newFindingFilter(".", "", DefaultBaselineFile, true)
+	require.NoError(t, err)
+	assert.Len(t, writer.apply(findings).Results, 2)
+	require.NoError(t, writer.writeBaseline(DefaultBaselineFile))
+
+	// Lines moved down by an edit above still match; a new finding does not
+	writeFiles(t, map[string]string{"src/main.cpp": "#include <x>\nint a;\nint b;\nint c;\n"})
+	reader, err := newFindingFilter(".", "", DefaultBaselineFile, false)
+	require.NoError(t, err)
+	results := reader.apply(ToolResults{Tool: "Cppcheck", Results: []AnalysisResult{
+		{Tool: "Cppcheck", File: "src/main.cpp", Line: 2, Rule: "unusedVariable"},
+		{Tool: "Cppcheck", File: "src/main.cpp", Line: 3, Rule: "unusedVariable"},
+		{Tool: "Cppcheck", File: "src/main.cpp", Line: 4, Rule: "unusedVariable"},
+	}})
+	require.Len(t, results.Results, 1)
+	assert.Equal(t, reader.recorded[2].Fingerprint, results.Results[0].Fingerprint)
+	assert.Equal(t, []AnalysisResult{{Tool: "Cppcheck", File: "src/main.cpp", Line: 4, Rule: "unusedVariable"}}, withoutFingerprints(results.Results))
+	assert.Equal(t, 2, reader.baselined)
+}
+
+func TestFindingFilterFilesAndSeverities(t *testing.T) {
+	t.Chdir(t.TempDir())
+	f, err := newFindingFilter(".", "", "", false)
+	require.NoError(t, err)
+	f.include = []string{"src/**", "include/**"}
+	f.exclude = []string{"src/generated/"}
+	f.severities = map[string]string{"modernize-*": "style", "modernize-use-auto": "info"}
+
+	results := f.apply(ToolResults{Tool: "clang-tidy", Results: []AnalysisResult{
+		{Tool: "clang-tidy", File: "src/main.cpp", Line: 1, Severity: "warning", Rule: "modernize-use-auto"},
+		{Tool: "clang-tidy", File: "include/a.h", Line: 1, Severity: "warning", Rule: "modernize-use-nullptr"},
+		{Tool: "clang-tidy", File: "include/a.h", Line: 2, Severity: "warning", Rule: "bugprone-x"},
+		{Tool: "clang-tidy", File: "src/generated/proto.cpp", Line: 1, Severity: "warning", Rule: "bugprone-x"},
+		{Tool: "clang-tidy", File: "tests/t.cpp", Line: 1, Severity: "warning", Rule: "bugprone-x"},
+	}})
+	assert.Equal(t, []AnalysisResult{
+		{Tool: "clang-tidy", File: "src/main.cpp", Line: 1, Severity: "info", Rule: "modernize-use-auto"},
+		{Tool: "clang-tidy", File: "include/a.h", Line: 1, Severity: "style", Rule: "modernize-use-nullptr"},
+		{Tool: "clang-tidy", File: "include/a.h", Line: 2, Severity: "warning", Rule: "bugprone-x"},
+	}, withoutFingerprints(results.Results))
+	assert.Equal(t, 2, f.excluded)
+
+	// Globs as long are tried in sort order, whatever the map order
+	f.severities = map[string]string{"bugprone-*": "error", "*ugprone-x": "style"}
+	for range 10 {
+		severity, ok := f.severity(AnalysisResult{Tool: "clang-tidy", Rule: "bugprone-x"})
+		require.True(t, ok)
+		assert.Equal(t, "style", severity)
+	}
+}
@@ -0,0 +1,220 @@
+package quality
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/build/remote"
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+)
+
+// containerWorkspace is where build containers mount the project
+const containerWorkspace = "/workspace"
+
+// toolNotFound is printed by the container scripts for a missing analyzer
+const toolNotFound = "cpx: analyzer not found"
+
+// ContainerAnalysis runs the analyzers inside a toolchain's build container,
+// with its compilers, headers and compilation database, so code behind
+// platform #ifdefs is analyzed as that toolchain compiles it
+type ContainerAnalysis struct {
+	// Run runs a bash script in the container, in the project, and returns
+	// its output.
+	Run func(script string) (string, error)
+
+	// CompileDbDir is the container directory of compile_commands.json.
+	CompileDbDir string
+
+	// Rewrite maps the container paths of findings to host paths.
+	Rewrite func(string) string
+}
+
+// run runs the analyzers not skipped by opts in the container
+func (c *ContainerAnalysis) run(opts AnalyzeOptions) ([]ToolResults, error) {
+	var tools []ToolResults
+	compileDb := path.Join(c.CompileDbDir, "compile_commands.json")
+	extraArgs := func(tool string) string {
+		if args := opts.Args[tool]; len(args) > 0 {
+			return " " + remote.QuoteAll(args)
+		}
+		return ""
+	}
+
+	if !opts.SkipCppcheck {
+		fmt.Printf("%sRunning Cppcheck...%s\n", colors.Cyan, colors.Reset)
+		// cppcheck writes its XML to stderr and progress to stdout
+		script := containerToolScript("cppcheck", "cppcheck --enable=all --xml --xml-version=2 --project="+remote.Quote(compileDb)+extraArgs("cppcheck")+" 2>&1 >/dev/null")
+		result, err := c.runTool("Cppcheck", script, func(output string) ([]AnalysisResult, error) {
+			if i := strings.Index(output, "<?xml"); i >= 0 {
+				output = output[i:]
+			}
+			tmp, err := os.CreateTemp("", "cppcheck-*.xml")
+			if err != nil {
+				return nil, fmt.Errorf("failed to create temp file: %w", err)
+			}
+			defer os.Remove(tmp.Name())
+			_, err = tmp.WriteString(output)
+			tmp.Close()
+			if err != nil {
+				return nil, err
+			}
+			return parseCppcheckXML(tmp.Name()), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, result)
+	}
+
+	if !opts.SkipLint {
+		fmt.Printf("%sRunning clang-tidy...%s\n", colors.Cyan, colors.Reset)
+		files, _ := lintSourceFiles()
+		if len(files) == 0 {
+			tools = append(tools, ToolResults{Tool: "clang-tidy", Status: "skipped", Error: "no source files found", Results: []AnalysisResult{}})
+		} else {
+			script := containerToolScript("clang-tidy", "clang-tidy -p "+remote.Quote(c.CompileDbDir)+extraArgs("clang-tidy")+" "+remote.QuoteAll(toSlash(files))+" 2>/dev/null")
+			result, err := c.runTool("clang-tidy", script, func(output string) ([]AnalysisResult, error) {
+				return parseClangTidyOutput(output), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			tools = append(tools, result)
+		}
+	}
+
+	if !opts.SkipFlawfinder {
+		fmt.Printf("%sRunning Flawfinder...%s\n", colors.Cyan, colors.Reset)
+		dirs := discoverSourceDirectories(opts.Targets)
+		if len(dirs) == 0 {
+			tools = append(tools, ToolResults{Tool: "Flawfinder", Status: "skipped", Error: "no source directories found to scan", Results: []AnalysisResult{}})
+		} else {
+			script := containerToolScript("flawfinder", "flawfinder --csv -m 1"+extraArgs("flawfinder")+" "+remote.QuoteAll(toSlash(dirs))+" 2>/dev/null")
+			result, err := c.runTool("Flawfinder", script, func(output string) ([]AnalysisResult, error) {
+				return parseFlawfinderCSV(output), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			tools = append(tools, result)
+		}
+	}
+
+	if !opts.SkipIWYU {
+		fmt.Printf("%sRunning include-what-you-use...%s\n", colors.Cyan, colors.Reset)
+		files, _ := lintSourceFiles()
+		if len(files) == 0 {
+			tools = append(tools, ToolResults{Tool: "include-what-you-use", Status: "skipped", Error: "no source files found", Results: []AnalysisResult{}})
+		} else {
+			// The tool is installed under several names
+			script := fmt.Sprintf("cd %s || exit 1\nfor tool in %s; do\n  command -v $tool >/dev/null 2>&1 && { $tool -j $(nproc) -p %s%s %s 2>/dev/null; exit 0; }\ndone\necho %s\n",
+				containerWorkspace, strings.Join(iwyuTools, " "), remote.Quote(c.CompileDbDir), extraArgs("iwyu"), remote.QuoteAll(toSlash(files)), remote.Quote(toolNotFound))
+			var output string
+			result, err := c.runTool("include-what-you-use", script, func(out string) ([]AnalysisResult, error) {
+				output = out
+				return parseIWYUOutput(out), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			// Fixes are applied on the host, the project being read-only in
+			// the container
+			if opts.IWYUFix && len(result.Results) > 0 {
+				if err := applyIWYUFixes(c.Rewrite(output)); err != nil {
+					result.Status = "error"
+					result.Error = err.Error()
+				}
+			}
+			tools = append(tools, result)
+		}
+	}
+
+	if !opts.SkipSemgrep {
+		fmt.Printf("%sRunning Semgrep...%s\n", colors.Cyan, colors.Reset)
+		dirs := discoverSourceDirectories(opts.Targets)
+		if len(dirs) == 0 {
+			tools = append(tools, ToolResults{Tool: "Semgrep", Status: "skipped", Error: "no source directories found to scan", Results: []AnalysisResult{}})
+		} else {
+			// The bundled rules are written next to the build directory, the
+			// project being read-only
+			const rulesFile = "/tmp/cpx-semgrep.yaml"
+			script := containerToolScript("semgrep", "cat > "+rulesFile+" <<'CPX_SEMGREP_RULES'\n"+semgrepRules+"CPX_SEMGREP_RULES\n"+
+				"semgrep "+remote.QuoteAll(semgrepArgs(opts.SemgrepRulesets, rulesFile, opts.Args["semgrep"], toSlash(dirs)))+" 2>/dev/null")
+			result, err := c.runTool("Semgrep", script, func(output string) ([]AnalysisResult, error) {
+				return parseSemgrepJSON([]byte(output))
+			})
+			if err != nil {
+				return nil, err
+			}
+			tools = append(tools, result)
+		}
+	}
+
+	if commandTools := opts.commandTools(); len(commandTools) > 0 {
+		files := toSlash(formatFiles(opts.Targets))
+		for _, tool := range commandTools {
+			fmt.Printf("%sRunning %s...%s\n", colors.Cyan, tool.Name, colors.Reset)
+			if len(files) == 0 {
+				tools = append(tools, ToolResults{Tool: tool.Name, Status: "skipped", Error: "no source files found", Results: []AnalysisResult{}})
+				continue
+			}
+			redirect := " 2>&1"
+			if tool.structured() {
+				redirect = " 2>/dev/null"
+			}
+			script := containerToolScript(remote.Quote(tool.Command[0]), remote.QuoteAll(tool.Command)+" "+remote.QuoteAll(files)+redirect)
+			result, err := c.runTool(tool.Name, script, tool.parse)
+			if err != nil {
+				return nil, err
+			}
+			tools = append(tools, result)
+		}
+	}
+	return tools, nil
+}
+
+// runTool runs the script of an analyzer and parses its output, rewriting
+// the container paths of the findings
+func (c *ContainerAnalysis) runTool(tool, script string, parse func(string) ([]AnalysisResult, error)) (ToolResults, error) {
+	result := ToolResults{Tool: tool, Status: "success", Results: []AnalysisResult{}}
+	output, err := c.Run(script)
+	if err != nil {
+		return result, fmt.Errorf("failed to run %s in the build container: %w", tool, err)
+	}
+	if strings.Contains(output, toolNotFound) {
+		result.Status = "skipped"
+		result.Error = tool + " not found in the build container's image"
+		return result, nil
+	}
+	findings, err := parse(output)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result, nil
+	}
+	for i := range findings {
+		findings[i].File = c.Rewrite(findings[i].File)
+	}
+	if findings != nil {
+		result.Results = findings
+	}
+	return result, nil
+}
+
+// containerToolScript returns the script running an analyzer command in the
+// project, which succeeds whatever the analyzer finds
+func containerToolScript(tool, command string) string {
+	return fmt.Sprintf("cd %s || exit 1\ncommand -v %s >/dev/null 2>&1 || { echo %s; exit 0; }\n%s || true\n",
+		containerWorkspace, tool, remote.Quote(toolNotFound), command)
+}
+
+// toSlash returns host paths relative to the project as container paths
+func toSlash(paths []string) []string {
+	slashed := make([]string, len(paths))
+	for i, p := range paths {
+		slashed[i] = strings.ReplaceAll(p, `\`, "/")
+	}
+	return slashed
+}
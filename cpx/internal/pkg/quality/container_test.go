@@ -0,0 +1,64 @@
+package quality
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerToolScript(t *testing.T) {
+	assert.Equal(t, `cd /workspace || exit 1
+command -v flawfinder >/dev/null 2>&1 || { echo 'cpx: analyzer not found'; exit 0; }
+flawfinder --csv -m 1 src || true
+`, containerToolScript("flawfinder", "flawfinder --csv -m 1 src"))
+}
+
+func TestContainerAnalysisRun(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{"src/main.cpp": "int main() {}\n"})
+
+	var scripts []string
+	c := &ContainerAnalysis{
+		CompileDbDir: "/tmp/build",
+		Run: func(script string) (string, error) {
+			scripts = append(scripts, script)
+			switch {
+			case strings.Contains(script, "clang-tidy -p"):
+				return "/workspace/src/main.cpp:1:5: warning: use a trailing return type [modernize-use-trailing-return-type]\n", nil
+			case strings.Contains(script, "flawfinder --csv"):
+				return toolNotFound + "\n", nil
+			case strings.Contains(script, "semgrep scan"):
+				return `{"results": [{"check_id": "tmp.cpx-gets", "path": "src/main.cpp", "start": {"line": 1, "col": 14}, "end": {"line": 1, "col": 21}, "extra": {"message": "gets", "severity": "ERROR"}}], "errors": []}`, nil
+			case strings.Contains(script, "cpplint --quiet"):
+				return "/workspace/src/main.cpp:1:  Missing space before {  [whitespace/braces] [5]\n", nil
+			}
+			return "", nil
+		},
+		Rewrite: func(s string) string { return strings.Replace(s, "/workspace", "/host/project", 1) },
+	}
+
+	tools, err := c.run(AnalyzeOptions{SkipCppcheck: true, SkipIWYU: true, Targets: []string{"."}})
+	require.NoError(t, err)
+	require.Len(t, tools, 4)
+	assert.Contains(t, scripts[0], "clang-tidy -p /tmp/build src/main.cpp")
+
+	assert.Equal(t, "success", tools[0].Status)
+	require.Len(t, tools[0].Results, 1)
+	assert.Equal(t, "/host/project/src/main.cpp", tools[0].Results[0].File)
+	assert.Equal(t, "modernize-use-trailing-return-type", tools[0].Results[0].Rule)
+
+	assert.Equal(t, "Flawfinder", tools[1].Tool)
+	assert.Equal(t, "skipped", tools[1].Status)
+
+	assert.Contains(t, scripts[2], "semgrep scan --json --quiet --metrics=off --disable-version-check --config /tmp/cpx-semgrep.yaml src")
+	require.Len(t, tools[2].Results, 1)
+	assert.Equal(t, "cpx-gets", tools[2].Results[0].Rule)
+	assert.Equal(t, "error", tools[2].Results[0].Severity)
+
+	assert.Contains(t, scripts[3], "cpplint --quiet src/main.cpp 2>&1")
+	require.Len(t, tools[3].Results, 1)
+	assert.Equal(t, "/host/project/src/main.cpp", tools[3].Results[0].File)
+	assert.Equal(t, "whitespace/braces", tools[3].Results[0].Rule)
+}
@@ -0,0 +1,83 @@
+package quality
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AnalysisDiff is the comparison of the findings of two analyses
+type AnalysisDiff struct {
+	// New are the findings of the newer analysis the older one does not
+	// have.
+	New []AnalysisResult `json:"new"`
+
+	// Fixed are the findings of the older analysis the newer one does not
+	// have.
+	Fixed []AnalysisResult `json:"fixed"`
+
+	// Persisting are the findings of the newer analysis the older one has
+	// too.
+	Persisting []AnalysisResult `json:"persisting"`
+}
+
+// findingFingerprint returns the fingerprint of a finding: the one recorded
+// in the analysis, else one of its tool, rule, file and message for reports
+// of older versions of cpx
+func findingFingerprint(r AnalysisResult) string {
+	if r.Fingerprint != "" {
+		return r.Fingerprint
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{r.Tool, findingRule(r), filepath.ToSlash(r.File), r.Message}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// findings returns all findings of an analysis
+func (analysis ComprehensiveAnalysis) findings() []AnalysisResult {
+	var results []AnalysisResult
+	for _, tool := range analysis.Tools {
+		results = append(results, tool.Results...)
+	}
+	return results
+}
+
+// DiffAnalyses compares the findings of an older and a newer analysis by
+// fingerprint, so findings whose lines moved still match. A fingerprint
+// counts as many times as findings have it.
+func DiffAnalyses(older, newer ComprehensiveAnalysis) AnalysisDiff {
+	diff := AnalysisDiff{New: []AnalysisResult{}, Fixed: []AnalysisResult{}, Persisting: []AnalysisResult{}}
+	remaining := make(map[string]int)
+	for _, r := range older.findings() {
+		remaining[findingFingerprint(r)]++
+	}
+	for _, r := range newer.findings() {
+		fingerprint := findingFingerprint(r)
+		if remaining[fingerprint] > 0 {
+			remaining[fingerprint]--
+			diff.Persisting = append(diff.Persisting, r)
+		} else {
+			diff.New = append(diff.New, r)
+		}
+	}
+	for _, r := range older.findings() {
+		fingerprint := findingFingerprint(r)
+		if remaining[fingerprint] > 0 {
+			remaining[fingerprint]--
+			diff.Fixed = append(diff.Fixed, r)
+		}
+	}
+	return diff
+}
+
+// LoadCommitAnalysis returns the analysis of a commit recorded in the
+// HistoryDir of root's repository, made without uncommitted changes
+func LoadCommitAnalysis(root, commit string) (ComprehensiveAnalysis, error) {
+	path := filepath.Join(historyDir(root), historyKey(ComprehensiveAnalysis{Commit: commit})+".json")
+	if _, err := os.Stat(path); err != nil {
+		return ComprehensiveAnalysis{}, fmt.Errorf("no analysis of commit %s recorded in %s; run 'cpx analyze' on it first", commit[:min(len(commit), 12)], HistoryDir)
+	}
+	return LoadAnalysis(path)
+}
@@ -0,0 +1,63 @@
+package quality
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffAnalyses(t *testing.T) {
+	older := ComprehensiveAnalysis{Tools: []ToolResults{{Tool: "Cppcheck", Results: []AnalysisResult{
+		{Tool: "Cppcheck", File: "src/a.cpp", Line: 1, Rule: "unusedVariable", Fingerprint: "aa"},
+		{Tool: "Cppcheck", File: "src/a.cpp", Line: 2, Rule: "unusedVariable", Fingerprint: "aa"},
+		{Tool: "Cppcheck", File: "src/b.cpp", Line: 5, Rule: "nullPointer", Fingerprint: "bb"},
+	}}}}
+	newer := ComprehensiveAnalysis{Tools: []ToolResults{{Tool: "Cppcheck", Results: []AnalysisResult{
+		// Moved down by an edit above
+		{Tool: "Cppcheck", File: "src/a.cpp", Line: 3, Rule: "unusedVariable", Fingerprint: "aa"},
+		{Tool: "Cppcheck", File: "src/c.cpp", Line: 9, Rule: "uninitvar", Fingerprint: "cc"},
+	}}}}
+
+	diff := DiffAnalyses(older, newer)
+	require.Len(t, diff.New, 1)
+	assert.Equal(t, "src/c.cpp", diff.New[0].File)
+	require.Len(t, diff.Persisting, 1)
+	assert.Equal(t, 3, diff.Persisting[0].Line)
+	require.Len(t, diff.Fixed, 2)
+	assert.Equal(t, "aa", diff.Fixed[0].Fingerprint)
+	assert.Equal(t, "bb", diff.Fixed[1].Fingerprint)
+
+	diff = DiffAnalyses(newer, newer)
+	assert.Empty(t, diff.New)
+	assert.Empty(t, diff.Fixed)
+	assert.Len(t, diff.Persisting, 2)
+}
+
+func TestFindingFingerprint(t *testing.T) {
+	r := AnalysisResult{Tool: "clang-tidy", File: "src/a.cpp", Line: 4, Message: "use auto", Rule: "modernize-use-auto"}
+	moved := r
+	moved.Line = 10
+	assert.Equal(t, findingFingerprint(r), findingFingerprint(moved))
+	other := r
+	other.File = "src/b.cpp"
+	assert.NotEqual(t, findingFingerprint(r), findingFingerprint(other))
+
+	r.Fingerprint = "recorded"
+	assert.Equal(t, "recorded", findingFingerprint(r))
+}
+
+func TestLoadCommitAnalysis(t *testing.T) {
+	root := t.TempDir()
+	analysis := testAnalysis()
+	analysis.Commit = "0123456789abcdef"
+	_, err := SaveHistory(root, analysis)
+	require.NoError(t, err)
+
+	loaded, err := LoadCommitAnalysis(root, "0123456789abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, analysis.Commit, loaded.Commit)
+
+	_, err = LoadCommitAnalysis(root, "fedcba9876543210")
+	assert.ErrorContains(t, err, "no analysis of commit fedcba987654")
+}
@@ -8,74 +8,278 @@ import (
 	"strings"
 
 	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/ozacod/cpx/internal/pkg/utils/git"
 )
 
+// formatExtensions are the extensions of the files clang-format formats
+var formatExtensions = []string{".cpp", ".hpp", ".c", ".h", ".cc", ".cxx", ".hxx", ".hh", ".ipp", ".cppm", ".ixx"}
+
+// FormatOptions are the options of FormatCode
+type FormatOptions struct {
+	// Check reports files that need formatting, with a diff, instead of
+	// formatting them.
+	Check bool
+
+	// Changed restricts formatting to files changed since this git ref,
+	// including uncommitted and untracked files.
+	Changed string
+
+	// Targets are the directories or files to format. By default the
+	// project's source directories are discovered.
+	Targets []string
+}
+
 // FormatCode formats C++ source files using clang-format
-func FormatCode(checkOnly bool) error {
+func FormatCode(opts FormatOptions) error {
 	// Check if clang-format is available
 	if _, err := exec.LookPath("clang-format"); err != nil {
 		return fmt.Errorf("clang-format not found. Please install it first")
 	}
 
+	files := formatFiles(opts.Targets)
+	if opts.Changed != "" {
+		changed, err := git.ChangedFiles(".", opts.Changed)
+		if err != nil {
+			return fmt.Errorf("failed to list files changed since %s: %w", opts.Changed, err)
+		}
+		files = filterChanged(files, changed)
+	}
+
+	if len(files) == 0 {
+		fmt.Printf("%s No source files found%s\n", colors.Green, colors.Reset)
+		return nil
+	}
+
+	if opts.Check {
+		fmt.Printf("%s Checking formatting of %d files...%s\n", colors.Cyan, len(files), colors.Reset)
+		var unformatted []string
+		for _, file := range files {
+			original, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			formatted, err := exec.Command("clang-format", "-style=file", file).Output()
+			if err != nil {
+				return fmt.Errorf("clang-format failed on %s: %w", file, err)
+			}
+			if string(formatted) == string(original) {
+				continue
+			}
+			unformatted = append(unformatted, file)
+			fmt.Print(unifiedDiff(file, string(original), string(formatted)))
+		}
+		if len(unformatted) > 0 {
+			return fmt.Errorf("%d file(s) need formatting. Run 'cpx fmt' to fix", len(unformatted))
+		}
+		fmt.Printf("%s %d files are formatted%s\n", colors.Green, len(files), colors.Reset)
+		return nil
+	}
+
 	fmt.Printf("%s Formatting code...%s\n", colors.Cyan, colors.Reset)
+	for _, file := range files {
+		cmd := exec.Command("clang-format", "-style=file", "-i", file)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("clang-format failed on %s: %w\n%s", file, err, strings.TrimSpace(string(output)))
+		}
+		fmt.Printf("    %s\n", file)
+	}
 
-	// Find all source files
-	var files []string
-	extensions := []string{".cpp", ".hpp", ".c", ".h", ".cc", ".cxx", ".hxx"}
+	fmt.Printf("%s Formatted %d files%s\n", colors.Green, len(files), colors.Reset)
+	return nil
+}
 
-	for _, dir := range []string{"src", "include", "tests"} {
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			continue
+// formatFiles returns the C/C++ files of targets, files or directories, or
+// of the discovered source directories
+func formatFiles(targets []string) []string {
+	var files, dirs []string
+	for _, target := range targets {
+		if info, err := os.Stat(target); err == nil && !info.IsDir() {
+			if isFormatFile(target) {
+				files = append(files, filepath.Clean(target))
+			}
+		} else {
+			dirs = append(dirs, target)
 		}
-		_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil || info.IsDir() {
+	}
+	if len(targets) > 0 && len(dirs) == 0 {
+		return files
+	}
+
+	for _, dir := range discoverSourceDirectories(dirs) {
+		_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
 				return nil
 			}
-			for _, ext := range extensions {
-				if strings.HasSuffix(path, ext) {
-					files = append(files, path)
-					break
+			if d.IsDir() {
+				name := d.Name()
+				if path != dir && (skippedDirs[name] || strings.HasPrefix(name, "bazel-") || strings.HasPrefix(name, ".")) {
+					return filepath.SkipDir
 				}
+				return nil
+			}
+			if isFormatFile(path) {
+				files = append(files, path)
 			}
 			return nil
 		})
 	}
+	return files
+}
 
-	if len(files) == 0 {
-		fmt.Printf("%s No source files found%s\n", colors.Green, colors.Reset)
-		return nil
+func isFormatFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range formatExtensions {
+		if ext == e {
+			return true
+		}
 	}
+	return false
+}
 
-	// Format each file
-	formatArgs := []string{"-style=file"}
-	if !checkOnly {
-		formatArgs = append(formatArgs, "-i")
-	} else {
-		formatArgs = append(formatArgs, "--dry-run", "--Werror")
+// filterChanged returns the files that are in changed
+func filterChanged(files, changed []string) []string {
+	set := make(map[string]bool, len(changed))
+	for _, file := range changed {
+		set[filepath.Clean(filepath.FromSlash(file))] = true
 	}
-
-	needsFormat := false
+	var filtered []string
 	for _, file := range files {
-		args := append(formatArgs, file)
-		cmd := exec.Command("clang-format", args...)
-		output, err := cmd.CombinedOutput()
-
-		if checkOnly && err != nil {
-			needsFormat = true
-			fmt.Printf("   %s %s needs formatting%s\n", colors.Yellow, file, colors.Reset)
-		} else if !checkOnly {
-			fmt.Printf("    %s\n", file)
+		if set[filepath.Clean(file)] {
+			filtered = append(filtered, file)
 		}
+	}
+	return filtered
+}
 
-		if len(output) > 0 && checkOnly {
-			fmt.Print(string(output))
+// diffContext is the number of unchanged lines around the changes of a hunk
+const diffContext = 3
+
+// maxDiffCells bounds the line comparisons of unifiedDiff; larger files only
+// get a note that they need formatting
+const maxDiffCells = 4_000_000
+
+// unifiedDiff returns the unified diff turning a into b, both the content of
+// file
+func unifiedDiff(file, a, b string) string {
+	x, y := splitLines(a), splitLines(b)
+
+	// Changes from formatting are usually local: compare only what lies
+	// between the common prefix and suffix
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	mx, my := x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+	if len(mx)*len(my) > maxDiffCells {
+		return fmt.Sprintf("%s needs formatting (too many changes to show a diff)\n", file)
+	}
+
+	// ops is the edit script: ' ' keeps a line of both, '-' removes one of
+	// x and '+' adds one of y
+	type op struct {
+		kind byte
+		line string
+	}
+	var ops []op
+	for _, line := range x[:prefix] {
+		ops = append(ops, op{' ', line})
+	}
+	lcs := make([][]int, len(mx)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(my)+1)
+	}
+	for i := len(mx) - 1; i >= 0; i-- {
+		for j := len(my) - 1; j >= 0; j-- {
+			if mx[i] == my[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(mx) || j < len(my) {
+		switch {
+		case i < len(mx) && j < len(my) && mx[i] == my[j]:
+			ops = append(ops, op{' ', mx[i]})
+			i++
+			j++
+		case i < len(mx) && (j == len(my) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', mx[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', my[j]})
+			j++
 		}
 	}
+	for _, line := range x[len(x)-suffix:] {
+		ops = append(ops, op{' ', line})
+	}
 
-	if checkOnly && needsFormat {
-		return fmt.Errorf("some files need formatting. Run 'cpx fmt' to fix")
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s (formatted)\n", file, file)
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are close
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		end := first
+		for k := first; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContext {
+				break
+			}
+		}
+		from, to := max(first-diffContext, 0), min(end+diffContext, len(ops))
+
+		// Line numbers of the hunk in x and y
+		xLine, yLine := 1, 1
+		for _, o := range ops[:from] {
+			if o.kind != '+' {
+				xLine++
+			}
+			if o.kind != '-' {
+				yLine++
+			}
+		}
+		xCount, yCount := 0, 0
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				xCount++
+			}
+			if o.kind != '-' {
+				yCount++
+			}
+		}
+		fmt.Fprintf(&sb, "%s@@ -%d,%d +%d,%d @@%s\n", colors.Cyan, xLine, xCount, yLine, yCount, colors.Reset)
+		for _, o := range ops[from:to] {
+			switch o.kind {
+			case '-':
+				fmt.Fprintf(&sb, "%s-%s%s\n", colors.Red, o.line, colors.Reset)
+			case '+':
+				fmt.Fprintf(&sb, "%s+%s%s\n", colors.Green, o.line, colors.Reset)
+			default:
+				fmt.Fprintf(&sb, " %s\n", o.line)
+			}
+		}
+		start = to
 	}
+	return sb.String()
+}
 
-	fmt.Printf("%s Formatted %d files%s\n", colors.Green, len(files), colors.Reset)
-	return nil
+// splitLines splits s into lines without their line endings
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
 }
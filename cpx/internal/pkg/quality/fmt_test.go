@@ -0,0 +1,48 @@
+package quality
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	colors.Disable()
+	a := "int main() {\nreturn 0;\n}\n// 1\n// 2\n// 3\n// 4\n// 5\n// 6\n// 7\n// 8\nint  x;\n"
+	b := "int main() {\n  return 0;\n}\n// 1\n// 2\n// 3\n// 4\n// 5\n// 6\n// 7\n// 8\nint x;\n"
+	assert.Equal(t, `--- a.cpp
++++ a.cpp (formatted)
+@@ -1,5 +1,5 @@
+ int main() {
+-return 0;
++  return 0;
+ }
+ // 1
+ // 2
+@@ -9,4 +9,4 @@
+ // 6
+ // 7
+ // 8
+-int  x;
++int x;
+`, unifiedDiff("a.cpp", a, b))
+}
+
+func TestFormatFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, file := range []string{"src/main.cpp", "src/util.h", "src/notes.txt", "src/build/gen.cpp", "src/.hidden/x.cpp", "tests/test.cc"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, []byte(""), 0644))
+	}
+
+	files := formatFiles(nil)
+	assert.ElementsMatch(t, []string{filepath.Join("src", "main.cpp"), filepath.Join("src", "util.h"), filepath.Join("tests", "test.cc")}, files)
+	assert.Equal(t, []string{filepath.Join("src", "main.cpp")}, formatFiles([]string{"src/main.cpp", "src/notes.txt"}))
+
+	changed := filterChanged(files, []string{"src/util.h", "README.md"})
+	assert.Equal(t, []string{filepath.Join("src", "util.h")}, changed)
+}
@@ -0,0 +1,118 @@
+package quality
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/git"
+)
+
+// HistoryDir holds a JSON report of each analysis, relative to the root of
+// the git repository (or the project root outside one), for `cpx analyze trend`
+var HistoryDir = filepath.Join(".cpx", "analysis")
+
+// historyTimeFormat names the records of analyses outside git repositories
+const historyTimeFormat = "20060102-150405"
+
+// historyKey returns the name of the record of an analysis: its commit, with
+// -dirty when the working tree had changes, so that later analyses of a
+// commit replace earlier ones, or its time outside git repositories
+func historyKey(analysis ComprehensiveAnalysis) string {
+	if analysis.Commit == "" {
+		return analysis.Timestamp.Format(historyTimeFormat)
+	}
+	key := analysis.Commit[:min(len(analysis.Commit), 12)]
+	if analysis.Dirty {
+		key += "-dirty"
+	}
+	return key
+}
+
+// recordCommit sets the commit an analysis of root ran on, if root is in a
+// git repository
+func recordCommit(analysis *ComprehensiveAnalysis, root string) {
+	if commit, dirty, err := git.HeadCommit(root); err == nil {
+		analysis.Commit, analysis.Dirty = commit, dirty
+	}
+}
+
+// historyDir returns the HistoryDir of the repository root is in, so that
+// analyses run from any of its directories share one history
+func historyDir(root string) string {
+	if top, err := git.TopLevel(root); err == nil {
+		root = top
+	}
+	return filepath.Join(root, HistoryDir)
+}
+
+// SaveHistory records an analysis in the HistoryDir of root's repository and
+// returns the record's path
+func SaveHistory(root string, analysis ComprehensiveAnalysis) (string, error) {
+	dir := historyDir(root)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create analysis history directory: %w", err)
+	}
+	path := filepath.Join(dir, historyKey(analysis)+".json")
+	if err := writeJSONReport(analysis, path); err != nil {
+		return "", fmt.Errorf("failed to record analysis: %w", err)
+	}
+	return path, nil
+}
+
+// LoadHistory returns the analyses recorded in the HistoryDir of root's
+// repository, oldest first. Without history there are none.
+func LoadHistory(root string) ([]ComprehensiveAnalysis, error) {
+	files, err := filepath.Glob(filepath.Join(historyDir(root), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var runs []ComprehensiveAnalysis
+	for _, file := range files {
+		analysis, err := LoadAnalysis(file)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, analysis)
+	}
+	slices.SortStableFunc(runs, func(a, b ComprehensiveAnalysis) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	return runs, nil
+}
+
+// TrendBy are the groupings of finding counts of `cpx analyze trend`
+var TrendBy = []string{"severity", "tool"}
+
+// TrendCounts returns the finding counts of an analysis by severity or tool
+func TrendCounts(analysis ComprehensiveAnalysis, by string) map[string]int {
+	if by == "tool" {
+		return analysis.Summary.ByTool
+	}
+	return analysis.Summary.BySeverity
+}
+
+// TrendSeries returns the severities or tools that runs have findings of:
+// severities worst first, tools by name
+func TrendSeries(runs []ComprehensiveAnalysis, by string) []string {
+	var series []string
+	for _, run := range runs {
+		for name := range TrendCounts(run, by) {
+			if !slices.Contains(series, name) {
+				series = append(series, name)
+			}
+		}
+	}
+	slices.SortFunc(series, func(a, b string) int {
+		if by == "severity" {
+			if c := cmp.Compare(severityRanks[b], severityRanks[a]); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a, b)
+	})
+	return series
+}
@@ -0,0 +1,80 @@
+package quality
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryKey(t *testing.T) {
+	analysis := ComprehensiveAnalysis{Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	assert.Equal(t, "20260102-030405", historyKey(analysis))
+
+	analysis.Commit = "0123456789abcdef0123"
+	assert.Equal(t, "0123456789ab", historyKey(analysis))
+
+	analysis.Dirty = true
+	assert.Equal(t, "0123456789ab-dirty", historyKey(analysis))
+}
+
+func TestSaveAndLoadHistory(t *testing.T) {
+	root := t.TempDir()
+	runs, err := LoadHistory(root)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	newer := testAnalysis()
+	newer.Commit = "bbbbbbbbbbbbbbbb"
+	newer.Timestamp = newer.Timestamp.Add(time.Hour)
+	older := testAnalysis()
+	older.Commit = "aaaaaaaaaaaaaaaa"
+
+	path, err := SaveHistory(root, newer)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, HistoryDir, "bbbbbbbbbbbb.json"), path)
+	_, err = SaveHistory(root, older)
+	require.NoError(t, err)
+	// A later analysis of a commit replaces the earlier one
+	_, err = SaveHistory(root, older)
+	require.NoError(t, err)
+
+	runs, err = LoadHistory(root)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "aaaaaaaaaaaaaaaa", runs[0].Commit)
+	assert.Equal(t, "bbbbbbbbbbbbbbbb", runs[1].Commit)
+}
+
+func TestHistoryInRepository(t *testing.T) {
+	repo := t.TempDir()
+	require.NoError(t, exec.Command("git", "-C", repo, "init", "-q").Run())
+	sub := filepath.Join(repo, "libs", "core")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+
+	// Analyses from any directory of the repository share its history
+	path, err := SaveHistory(sub, testAnalysis())
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(repo, HistoryDir), filepath.Dir(path))
+	runs, err := LoadHistory(repo)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
+
+func TestTrendSeries(t *testing.T) {
+	a := ComprehensiveAnalysis{}
+	a.Summary.BySeverity = map[string]int{"info": 1, "warning": 2}
+	a.Summary.ByTool = map[string]int{"cppcheck": 3}
+	b := ComprehensiveAnalysis{}
+	b.Summary.BySeverity = map[string]int{"error": 1, "style": 4}
+	b.Summary.ByTool = map[string]int{"Semgrep": 5, "clang-tidy": 1}
+
+	runs := []ComprehensiveAnalysis{a, b}
+	assert.Equal(t, []string{"error", "warning", "style", "info"}, TrendSeries(runs, "severity"))
+	assert.Equal(t, []string{"Semgrep", "clang-tidy", "cppcheck"}, TrendSeries(runs, "tool"))
+	assert.Equal(t, 5, TrendCounts(b, "tool")["Semgrep"])
+}
@@ -0,0 +1,738 @@
+package quality
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"os"
+	"slices"
+	"strings"
+)
+
+// htmlContextLines are the source lines the HTML report shows above and
+// below each finding
+const htmlContextLines = 3
+
+// htmlCollapseFindings is the number of findings above which the file groups
+// of the HTML report start collapsed
+const htmlCollapseFindings = 300
+
+// htmlTopFiles is the number of files of the HTML report's chart of the files
+// with the most findings
+const htmlTopFiles = 10
+
+// htmlReport is the data of the HTML report of an analysis
+type htmlReport struct {
+	ComprehensiveAnalysis
+
+	// Groups are the findings by file, the files with the worst and most
+	// findings first.
+	Groups []htmlFileGroup
+
+	// Severities and ToolNames are the values of the report's filters.
+	Severities []string
+	ToolNames  []string
+
+	// SeverityChart, ToolChart and FileChart count the findings by
+	// severity, tool and file.
+	SeverityChart []htmlBar
+	ToolChart     []htmlBar
+	FileChart     []htmlBar
+
+	// Collapsed starts the file groups collapsed, for large reports.
+	Collapsed bool
+}
+
+// htmlFileGroup is the findings of a file
+type htmlFileGroup struct {
+	File     string
+	Findings []htmlFinding
+
+	// Rank is the rank of the worst severity of the findings.
+	Rank int
+}
+
+// htmlFinding is a finding of the HTML report with the source lines around
+// it
+type htmlFinding struct {
+	AnalysisResult
+	Rank    int
+	Context []htmlSourceLine
+}
+
+// htmlSourceLine is a source line of a finding's context
+type htmlSourceLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// htmlBar is a bar of a chart of the HTML report
+type htmlBar struct {
+	Label   string
+	Count   int
+	Percent int
+}
+
+// newHTMLReport groups the findings of an analysis by file, with the source
+// lines around them, and counts them for the report's charts
+func newHTMLReport(analysis ComprehensiveAnalysis) htmlReport {
+	report := htmlReport{ComprehensiveAnalysis: analysis}
+	sources := make(map[string][]string)
+	groups := make(map[string]*htmlFileGroup)
+	var files []string
+	for _, tool := range analysis.Tools {
+		if len(tool.Results) > 0 {
+			report.ToolNames = append(report.ToolNames, tool.Tool)
+		}
+		for _, r := range tool.Results {
+			lines, ok := sources[r.File]
+			if !ok && r.File != "" {
+				if data, err := os.ReadFile(r.File); err == nil {
+					lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+				}
+				sources[r.File] = lines
+			}
+			group := groups[r.File]
+			if group == nil {
+				group = &htmlFileGroup{File: r.File, Rank: -1}
+				groups[r.File] = group
+				files = append(files, r.File)
+			}
+			rank := severityRanks[strings.ToLower(r.Severity)]
+			group.Findings = append(group.Findings, htmlFinding{AnalysisResult: r, Rank: rank, Context: sourceContext(lines, r.Line, htmlContextLines)})
+			group.Rank = max(group.Rank, rank)
+			if !slices.Contains(report.Severities, r.Severity) {
+				report.Severities = append(report.Severities, r.Severity)
+			}
+		}
+	}
+
+	for _, file := range files {
+		group := groups[file]
+		slices.SortStableFunc(group.Findings, func(a, b htmlFinding) int {
+			return cmp.Or(cmp.Compare(b.Rank, a.Rank), cmp.Compare(a.Line, b.Line))
+		})
+		report.Groups = append(report.Groups, *group)
+	}
+	slices.SortStableFunc(report.Groups, func(a, b htmlFileGroup) int {
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), cmp.Compare(len(b.Findings), len(a.Findings)), strings.Compare(a.File, b.File))
+	})
+	slices.SortFunc(report.Severities, func(a, b string) int {
+		return cmp.Or(cmp.Compare(severityRanks[strings.ToLower(b)], severityRanks[strings.ToLower(a)]), strings.Compare(a, b))
+	})
+
+	for _, severity := range report.Severities {
+		report.SeverityChart = append(report.SeverityChart, htmlBar{Label: severity, Count: analysis.Summary.BySeverity[severity]})
+	}
+	for _, tool := range report.ToolNames {
+		report.ToolChart = append(report.ToolChart, htmlBar{Label: tool, Count: analysis.Summary.ByTool[tool]})
+	}
+	byCount := slices.Clone(report.Groups)
+	slices.SortStableFunc(byCount, func(a, b htmlFileGroup) int {
+		return cmp.Compare(len(b.Findings), len(a.Findings))
+	})
+	for _, group := range byCount[:min(len(byCount), htmlTopFiles)] {
+		report.FileChart = append(report.FileChart, htmlBar{Label: group.File, Count: len(group.Findings)})
+	}
+	for _, chart := range [][]htmlBar{report.SeverityChart, report.ToolChart, report.FileChart} {
+		scaleBars(chart)
+	}
+
+	report.Collapsed = analysis.Summary.TotalFindings > htmlCollapseFindings
+	return report
+}
+
+// scaleBars sets the widths of the bars of a chart in percent of the
+// longest one
+func scaleBars(bars []htmlBar) {
+	longest := 0
+	for _, bar := range bars {
+		longest = max(longest, bar.Count)
+	}
+	for i := range bars {
+		if longest > 0 {
+			bars[i].Percent = max(bars[i].Count*100/longest, 1)
+		}
+	}
+}
+
+// sourceContext returns the lines of a source around line, context lines
+// above and below it; none when the source or line is unknown
+func sourceContext(lines []string, line, context int) []htmlSourceLine {
+	if line < 1 || line > len(lines) {
+		return nil
+	}
+	var snippet []htmlSourceLine
+	for n := max(1, line-context); n <= min(len(lines), line+context); n++ {
+		snippet = append(snippet, htmlSourceLine{Number: n, Text: strings.TrimRight(lines[n-1], "\r"), Current: n == line})
+	}
+	return snippet
+}
+
+// generateHTMLReport writes an HTML report of an analysis, with the findings
+// grouped by file and filters, sorting and charts that run in the browser
+func generateHTMLReport(analysis ComprehensiveAnalysis, outputFile string) error {
+	tmpl, err := template.New("report").Parse(htmlReportTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, newHTMLReport(analysis)); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return nil
+}
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Cpx Code Analysis Report</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            background: linear-gradient(135deg, #0a0a1a 0%, #1a1a2e 50%, #16213e 100%);
+            background-attachment: fixed;
+            color: #e2e8f0;
+            padding: 20px;
+            line-height: 1.6;
+            min-height: 100vh;
+        }
+        .container {
+            max-width: 1600px;
+            margin: 0 auto;
+            background: rgba(15, 15, 35, 0.8);
+            backdrop-filter: blur(20px);
+            border: 1px solid rgba(0, 212, 255, 0.2);
+            border-radius: 20px;
+            padding: 40px;
+            box-shadow: 0 20px 60px rgba(0, 0, 0, 0.5);
+        }
+        .header {
+            text-align: center;
+            margin-bottom: 40px;
+            padding-bottom: 30px;
+            border-bottom: 2px solid rgba(0, 212, 255, 0.2);
+        }
+        h1 {
+            background: linear-gradient(135deg, #00d4ff 0%, #00a8cc 100%);
+            -webkit-background-clip: text;
+            -webkit-text-fill-color: transparent;
+            background-clip: text;
+            margin-bottom: 10px;
+            font-size: 3em;
+            font-weight: 800;
+            letter-spacing: -0.02em;
+        }
+        h2 {
+            color: #00d4ff;
+            font-size: 1.3em;
+            font-weight: 700;
+            margin-bottom: 16px;
+        }
+        .timestamp {
+            color: #94a3b8;
+            font-size: 0.95em;
+        }
+        .summary {
+            display: grid;
+            grid-template-columns: repeat(auto-fit, minmax(180px, 1fr));
+            gap: 20px;
+            margin-bottom: 40px;
+        }
+        .summary-card, .panel {
+            background: linear-gradient(135deg, rgba(0, 212, 255, 0.1) 0%, rgba(0, 168, 204, 0.05) 100%);
+            border: 1px solid rgba(0, 212, 255, 0.2);
+            border-radius: 16px;
+            padding: 24px;
+        }
+        .summary-card h3 {
+            color: #94a3b8;
+            font-size: 0.85em;
+            text-transform: uppercase;
+            letter-spacing: 0.05em;
+            margin-bottom: 12px;
+        }
+        .summary-card .value {
+            font-size: 2.5em;
+            font-weight: 800;
+            color: #00d4ff;
+        }
+        .charts {
+            display: grid;
+            grid-template-columns: repeat(auto-fit, minmax(320px, 1fr));
+            gap: 20px;
+            margin-bottom: 40px;
+        }
+        .chart-row {
+            display: grid;
+            grid-template-columns: minmax(90px, 40%) 1fr 50px;
+            gap: 10px;
+            align-items: center;
+            padding: 4px 6px;
+            border-radius: 6px;
+            cursor: pointer;
+            font-size: 0.9em;
+        }
+        .chart-row:hover {
+            background: rgba(0, 212, 255, 0.1);
+        }
+        .chart-label {
+            overflow: hidden;
+            text-overflow: ellipsis;
+            white-space: nowrap;
+            direction: rtl;
+            text-align: left;
+        }
+        .chart-track {
+            background: rgba(0, 0, 0, 0.3);
+            border-radius: 4px;
+            height: 12px;
+        }
+        .chart-bar {
+            background: linear-gradient(90deg, #00d4ff, #00a8cc);
+            border-radius: 4px;
+            height: 12px;
+        }
+        .chart-bar.bar-error { background: #ef4444; }
+        .chart-bar.bar-warning { background: #fbbf24; }
+        .chart-bar.bar-style { background: #a78bfa; }
+        .chart-bar.bar-info { background: #60a5fa; }
+        .chart-count {
+            text-align: right;
+            color: #94a3b8;
+        }
+        .tools-table, .findings-table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+        .tools-table td {
+            padding: 8px 12px;
+            border-bottom: 1px solid rgba(255, 255, 255, 0.05);
+        }
+        .tool-status {
+            padding: 4px 12px;
+            border-radius: 20px;
+            font-size: 0.8em;
+            font-weight: 600;
+            text-transform: uppercase;
+        }
+        .status-success {
+            color: #22c55e;
+            border: 1px solid rgba(34, 197, 94, 0.3);
+        }
+        .status-error {
+            color: #ef4444;
+            border: 1px solid rgba(239, 68, 68, 0.3);
+        }
+        .status-skipped {
+            color: #94a3b8;
+            border: 1px solid rgba(148, 163, 184, 0.2);
+        }
+        .tool-error {
+            color: #ff6b6b;
+            font-size: 0.9em;
+        }
+        .toolbar {
+            position: sticky;
+            top: 0;
+            z-index: 1;
+            display: flex;
+            flex-wrap: wrap;
+            gap: 12px;
+            align-items: center;
+            margin: 40px 0 20px;
+            padding: 16px;
+            background: rgba(15, 15, 35, 0.95);
+            border: 1px solid rgba(0, 212, 255, 0.2);
+            border-radius: 12px;
+        }
+        .toolbar select, .toolbar input, .toolbar button {
+            background: rgba(0, 0, 0, 0.4);
+            color: #e2e8f0;
+            border: 1px solid rgba(0, 212, 255, 0.3);
+            border-radius: 8px;
+            padding: 8px 12px;
+            font-size: 0.9em;
+        }
+        .toolbar input {
+            flex: 1;
+            min-width: 160px;
+        }
+        .toolbar button {
+            cursor: pointer;
+        }
+        .toolbar button:hover {
+            background: rgba(0, 212, 255, 0.15);
+        }
+        .shown {
+            color: #94a3b8;
+            font-size: 0.9em;
+        }
+        .file-group {
+            margin-bottom: 12px;
+            background: rgba(0, 0, 0, 0.2);
+            border: 1px solid rgba(255, 255, 255, 0.05);
+            border-radius: 12px;
+        }
+        .file-group > summary {
+            padding: 12px 16px;
+            cursor: pointer;
+            display: flex;
+            gap: 12px;
+            align-items: center;
+        }
+        .file-group > summary:hover {
+            background: rgba(0, 212, 255, 0.08);
+        }
+        .file-path, .rule, .line-number, pre {
+            font-family: 'Monaco', 'Menlo', 'Ubuntu Mono', 'Courier New', monospace;
+        }
+        .file-path {
+            color: #cbd5e1;
+            flex: 1;
+        }
+        .group-count {
+            color: #94a3b8;
+            font-size: 0.9em;
+        }
+        .findings-table td {
+            padding: 10px 16px;
+            border-top: 1px solid rgba(255, 255, 255, 0.05);
+            vertical-align: top;
+        }
+        .findings-table tr:hover {
+            background: rgba(0, 212, 255, 0.05);
+        }
+        .severity {
+            padding: 3px 10px;
+            border-radius: 8px;
+            font-size: 0.75em;
+            font-weight: 700;
+            text-transform: uppercase;
+            letter-spacing: 0.05em;
+            color: #cbd5e1;
+            border: 1px solid rgba(148, 163, 184, 0.4);
+            white-space: nowrap;
+        }
+        .severity-error {
+            color: #ff6b6b;
+            border-color: rgba(239, 68, 68, 0.4);
+            background: rgba(239, 68, 68, 0.15);
+        }
+        .severity-warning {
+            color: #fbbf24;
+            border-color: rgba(251, 191, 36, 0.4);
+            background: rgba(251, 191, 36, 0.15);
+        }
+        .severity-style {
+            color: #a78bfa;
+            border-color: rgba(167, 139, 250, 0.4);
+            background: rgba(167, 139, 250, 0.15);
+        }
+        .severity-info {
+            color: #60a5fa;
+            border-color: rgba(59, 130, 246, 0.4);
+            background: rgba(59, 130, 246, 0.15);
+        }
+        .line-number {
+            color: #00d4ff;
+            font-weight: 700;
+        }
+        .message summary {
+            cursor: pointer;
+        }
+        .rule {
+            color: #94a3b8;
+            font-size: 0.85em;
+        }
+        pre {
+            margin-top: 10px;
+            padding: 10px 0;
+            background: rgba(0, 0, 0, 0.4);
+            border-radius: 8px;
+            overflow-x: auto;
+            font-size: 0.85em;
+        }
+        pre span {
+            display: block;
+            padding: 0 12px;
+        }
+        pre span.current {
+            background: rgba(251, 191, 36, 0.15);
+            border-left: 3px solid #fbbf24;
+        }
+        pre i {
+            color: #64748b;
+            font-style: normal;
+            user-select: none;
+            display: inline-block;
+            width: 4em;
+        }
+        .no-findings {
+            text-align: center;
+            padding: 60px 40px;
+            color: #22c55e;
+            font-size: 1.3em;
+            background: rgba(34, 197, 94, 0.1);
+            border: 2px dashed rgba(34, 197, 94, 0.3);
+            border-radius: 12px;
+        }
+        @media (max-width: 768px) {
+            .container {
+                padding: 20px;
+            }
+            h1 {
+                font-size: 2em;
+            }
+            .summary, .charts {
+                grid-template-columns: 1fr;
+            }
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>Cpx Code Analysis Report</h1>
+            <div class="timestamp">
+                Generated: {{.Timestamp.Format "2006-01-02 15:04:05"}}{{with .Commit}} · commit {{printf "%.12s" .}}{{end}}{{if .Dirty}} (with uncommitted changes){{end}}
+            </div>
+        </div>
+
+        <div class="summary">
+            <div class="summary-card">
+                <h3>Total Findings</h3>
+                <div class="value">{{.Summary.TotalFindings}}</div>
+            </div>
+            {{range .SeverityChart}}
+            <div class="summary-card">
+                <h3>{{.Label}}</h3>
+                <div class="value">{{.Count}}</div>
+            </div>
+            {{end}}
+            {{with .Metrics}}
+            <div class="summary-card">
+                <h3>Lines of Code</h3>
+                <div class="value">{{.Lines}}</div>
+            </div>
+            <div class="summary-card">
+                <h3>Average Complexity</h3>
+                <div class="value">{{printf "%.1f" .AverageComplexity}}</div>
+            </div>
+            <div class="summary-card">
+                <h3>Duplicated Lines</h3>
+                <div class="value">{{.DuplicatedLines}}</div>
+            </div>
+            {{end}}
+        </div>
+
+        <div class="charts">
+            {{if .SeverityChart}}
+            <div class="panel">
+                <h2>By Severity</h2>
+                {{range .SeverityChart}}
+                <div class="chart-row" data-filter="severity" data-value="{{.Label}}" title="{{.Label}}">
+                    <span class="chart-label">{{.Label}}</span>
+                    <div class="chart-track"><div class="chart-bar bar-{{.Label}}" style="width: {{.Percent}}%"></div></div>
+                    <span class="chart-count">{{.Count}}</span>
+                </div>
+                {{end}}
+            </div>
+            {{end}}
+            {{if .ToolChart}}
+            <div class="panel">
+                <h2>By Tool</h2>
+                {{range .ToolChart}}
+                <div class="chart-row" data-filter="tool" data-value="{{.Label}}" title="{{.Label}}">
+                    <span class="chart-label">{{.Label}}</span>
+                    <div class="chart-track"><div class="chart-bar" style="width: {{.Percent}}%"></div></div>
+                    <span class="chart-count">{{.Count}}</span>
+                </div>
+                {{end}}
+            </div>
+            {{end}}
+            {{if .FileChart}}
+            <div class="panel">
+                <h2>Files With the Most Findings</h2>
+                {{range .FileChart}}
+                <div class="chart-row" data-filter="file" data-value="{{.Label}}" title="{{.Label}}">
+                    <span class="chart-label">{{.Label}}</span>
+                    <div class="chart-track"><div class="chart-bar" style="width: {{.Percent}}%"></div></div>
+                    <span class="chart-count">{{.Count}}</span>
+                </div>
+                {{end}}
+            </div>
+            {{end}}
+            <div class="panel">
+                <h2>Tools</h2>
+                <table class="tools-table">
+                    {{range .Tools}}
+                    <tr>
+                        <td>{{.Tool}}</td>
+                        <td><span class="tool-status status-{{.Status}}">{{.Status}}</span></td>
+                        <td>{{if .Error}}<span class="tool-error">{{.Error}}</span>{{else}}{{len .Results}} findings{{end}}</td>
+                    </tr>
+                    {{end}}
+                </table>
+            </div>
+        </div>
+
+        {{if .Groups}}
+        <div class="toolbar">
+            <select id="filter-severity" aria-label="Severity">
+                <option value="">All severities</option>
+                {{range .Severities}}<option value="{{.}}">{{.}}</option>{{end}}
+            </select>
+            <select id="filter-tool" aria-label="Tool">
+                <option value="">All tools</option>
+                {{range .ToolNames}}<option value="{{.}}">{{.}}</option>{{end}}
+            </select>
+            <input id="filter-file" type="search" placeholder="Filter by file" aria-label="File">
+            <input id="filter-text" type="search" placeholder="Search messages and rules" aria-label="Search">
+            <select id="sort" aria-label="Sort">
+                <option value="severity">Sort by severity</option>
+                <option value="file">Sort by file</option>
+                <option value="count">Sort by finding count</option>
+                <option value="tool">Sort by tool</option>
+            </select>
+            <button id="expand" type="button">Expand all</button>
+            <button id="collapse" type="button">Collapse all</button>
+            <button id="clear" type="button">Clear filters</button>
+            <span class="shown"><span id="shown">{{.Summary.TotalFindings}}</span> of {{.Summary.TotalFindings}} findings</span>
+        </div>
+
+        <div id="groups">
+            {{range .Groups}}
+            <details class="file-group" data-file="{{.File}}" data-rank="{{.Rank}}" data-count="{{len .Findings}}"{{if not $.Collapsed}} open{{end}}>
+                <summary>
+                    <span class="file-path">{{or .File "(no file)"}}</span>
+                    <span class="group-count"><span class="visible">{{len .Findings}}</span> findings</span>
+                </summary>
+                <table class="findings-table">
+                    <tbody>
+                        {{range .Findings}}
+                        <tr class="finding" data-severity="{{.Severity}}" data-tool="{{.Tool}}" data-rank="{{.Rank}}" data-line="{{.Line}}">
+                            <td><span class="severity severity-{{.Severity}}">{{.Severity}}</span></td>
+                            <td><span class="line-number">{{.Line}}</span></td>
+                            <td class="message">
+                                {{if .Context}}
+                                <details>
+                                    <summary>{{.Message}}</summary>
+                                    <pre>{{range .Context}}<span{{if .Current}} class="current"{{end}}><i>{{.Number}}</i>{{.Text}}</span>{{end}}</pre>
+                                </details>
+                                {{else}}
+                                {{.Message}}
+                                {{end}}
+                            </td>
+                            <td><span class="rule">{{.Tool}}{{with .Rule}} · {{.}}{{end}}</span></td>
+                        </tr>
+                        {{end}}
+                    </tbody>
+                </table>
+            </details>
+            {{end}}
+        </div>
+        {{else}}
+        <div class="no-findings">No issues found!</div>
+        {{end}}
+    </div>
+
+    <script>
+        const filters = {
+            severity: document.getElementById('filter-severity'),
+            tool: document.getElementById('filter-tool'),
+            file: document.getElementById('filter-file'),
+            text: document.getElementById('filter-text'),
+        };
+        const groupList = document.getElementById('groups');
+
+        // Shows the findings that match all filters and the files that have any
+        function applyFilters() {
+            const severity = filters.severity.value;
+            const tool = filters.tool.value;
+            const file = filters.file.value.toLowerCase();
+            const text = filters.text.value.toLowerCase();
+            let shown = 0;
+            groupList.querySelectorAll('.file-group').forEach(group => {
+                const fileMatches = !file || group.dataset.file.toLowerCase().includes(file);
+                let visible = 0;
+                group.querySelectorAll('.finding').forEach(row => {
+                    const matches = fileMatches &&
+                        (!severity || row.dataset.severity === severity) &&
+                        (!tool || row.dataset.tool === tool) &&
+                        (!text || row.textContent.toLowerCase().includes(text));
+                    row.hidden = !matches;
+                    if (matches) visible++;
+                });
+                group.hidden = visible === 0;
+                group.querySelector('.visible').textContent = visible;
+                shown += visible;
+            });
+            document.getElementById('shown').textContent = shown;
+        }
+
+        const byFile = (a, b) => a.dataset.file.localeCompare(b.dataset.file);
+        const byLine = (a, b) => a.dataset.line - b.dataset.line;
+        const groupOrders = {
+            severity: (a, b) => b.dataset.rank - a.dataset.rank || b.dataset.count - a.dataset.count || byFile(a, b),
+            file: byFile,
+            count: (a, b) => b.dataset.count - a.dataset.count || byFile(a, b),
+            tool: byFile,
+        };
+        const findingOrders = {
+            severity: (a, b) => b.dataset.rank - a.dataset.rank || byLine(a, b),
+            file: byLine,
+            count: (a, b) => b.dataset.rank - a.dataset.rank || byLine(a, b),
+            tool: (a, b) => a.dataset.tool.localeCompare(b.dataset.tool) || byLine(a, b),
+        };
+
+        // Orders the files and the findings of each file
+        function sortFindings() {
+            const by = document.getElementById('sort').value;
+            const groups = Array.from(groupList.querySelectorAll('.file-group'));
+            groups.forEach(group => {
+                const body = group.querySelector('tbody');
+                Array.from(body.children).sort(findingOrders[by]).forEach(row => body.appendChild(row));
+            });
+            groups.sort(groupOrders[by]).forEach(group => groupList.appendChild(group));
+        }
+
+        if (groupList) {
+            Object.values(filters).forEach(input => input.addEventListener('input', applyFilters));
+            document.getElementById('sort').addEventListener('change', sortFindings);
+            document.getElementById('expand').addEventListener('click', () => {
+                groupList.querySelectorAll('.file-group').forEach(group => group.open = true);
+            });
+            document.getElementById('collapse').addEventListener('click', () => {
+                groupList.querySelectorAll('.file-group').forEach(group => group.open = false);
+            });
+            document.getElementById('clear').addEventListener('click', () => {
+                Object.values(filters).forEach(input => input.value = '');
+                applyFilters();
+            });
+            // Clicking a bar of a chart filters by its severity, tool or file
+            document.querySelectorAll('.chart-row').forEach(row => row.addEventListener('click', () => {
+                const input = filters[row.dataset.filter];
+                input.value = input.value === row.dataset.value ? '' : row.dataset.value;
+                applyFilters();
+                groupList.scrollIntoView({behavior: 'smooth'});
+            }));
+        }
+    </script>
+</body>
+</html>`
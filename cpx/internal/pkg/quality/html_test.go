@@ -0,0 +1,105 @@
+package quality
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func htmlTestAnalysis(t *testing.T) ComprehensiveAnalysis {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.cpp")
+	require.NoError(t, os.WriteFile(source, []byte("#include <cstdio>\n\nint main() {\n    char buf[8];\n    gets(buf);\n    return 0;\n}\n"), 0644))
+	header := filepath.Join(dir, "util.h")
+
+	analysis := ComprehensiveAnalysis{
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Commit:    "0123456789abcdef",
+		Tools: []ToolResults{
+			{Tool: "Cppcheck", Status: "success", Results: []AnalysisResult{
+				{Tool: "Cppcheck", Severity: "style", File: header, Line: 3, Message: "Variable 'x' is unused", Rule: "unusedVariable"},
+				{Tool: "Cppcheck", Severity: "warning", File: source, Line: 4, Message: "Buffer may overflow", Rule: "bufferAccess"},
+			}},
+			{Tool: "Flawfinder", Status: "success", Results: []AnalysisResult{
+				{Tool: "Flawfinder", Severity: "error", File: source, Line: 5, Message: "Never use gets", Rule: "gets"},
+			}},
+			{Tool: "clang-tidy", Status: "skipped", Error: "clang-tidy not found", Results: []AnalysisResult{}},
+		},
+	}
+	analysis.Summary.TotalFindings = 3
+	analysis.Summary.BySeverity = map[string]int{"error": 1, "warning": 1, "style": 1}
+	analysis.Summary.ByTool = map[string]int{"Cppcheck": 2, "Flawfinder": 1}
+	return analysis
+}
+
+func TestNewHTMLReport(t *testing.T) {
+	analysis := htmlTestAnalysis(t)
+	source, header := analysis.Tools[0].Results[1].File, analysis.Tools[0].Results[0].File
+	report := newHTMLReport(analysis)
+
+	// The file with the worst findings comes first, its worst finding first
+	require.Len(t, report.Groups, 2)
+	assert.Equal(t, source, report.Groups[0].File)
+	assert.Equal(t, severityRanks["error"], report.Groups[0].Rank)
+	require.Len(t, report.Groups[0].Findings, 2)
+	assert.Equal(t, "Never use gets", report.Groups[0].Findings[0].Message)
+	assert.Equal(t, "Buffer may overflow", report.Groups[0].Findings[1].Message)
+	assert.Equal(t, header, report.Groups[1].File)
+
+	// The source lines around a finding, without those of missing files
+	context := report.Groups[0].Findings[0].Context
+	require.Len(t, context, 6)
+	assert.Equal(t, htmlSourceLine{Number: 2, Text: ""}, context[0])
+	assert.Equal(t, htmlSourceLine{Number: 5, Text: "    gets(buf);", Current: true}, context[3])
+	assert.Equal(t, htmlSourceLine{Number: 7, Text: "}"}, context[5])
+	assert.Empty(t, report.Groups[1].Findings[0].Context)
+
+	assert.Equal(t, []string{"error", "warning", "style"}, report.Severities)
+	assert.Equal(t, []string{"Cppcheck", "Flawfinder"}, report.ToolNames)
+	assert.Equal(t, []htmlBar{{Label: "Cppcheck", Count: 2, Percent: 100}, {Label: "Flawfinder", Count: 1, Percent: 50}}, report.ToolChart)
+	assert.Equal(t, []htmlBar{{Label: source, Count: 2, Percent: 100}, {Label: header, Count: 1, Percent: 50}}, report.FileChart)
+	assert.False(t, report.Collapsed)
+
+	analysis.Summary.TotalFindings = htmlCollapseFindings + 1
+	assert.True(t, newHTMLReport(analysis).Collapsed)
+}
+
+func TestSourceContext(t *testing.T) {
+	lines := []string{"a", "b\r", "c", "d"}
+	assert.Equal(t, []htmlSourceLine{{1, "a", true}, {2, "b", false}}, sourceContext(lines, 1, 1))
+	assert.Equal(t, []htmlSourceLine{{3, "c", false}, {4, "d", true}}, sourceContext(lines, 4, 1))
+	assert.Nil(t, sourceContext(lines, 0, 1))
+	assert.Nil(t, sourceContext(lines, 5, 1))
+	assert.Nil(t, sourceContext(nil, 1, 1))
+}
+
+func TestGenerateHTMLReportInteractive(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "report.html")
+	require.NoError(t, generateHTMLReport(htmlTestAnalysis(t), outputFile))
+
+	data, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	content := string(data)
+	assert.Contains(t, content, `<option value="warning">warning</option>`)
+	assert.Contains(t, content, `<option value="Flawfinder">Flawfinder</option>`)
+	assert.Contains(t, content, `id="filter-file"`)
+	assert.Contains(t, content, `<details class="file-group"`)
+	assert.Contains(t, content, `data-severity="error" data-tool="Flawfinder"`)
+	assert.Contains(t, content, `<span class="current"><i>5</i>    gets(buf);</span>`)
+	assert.Contains(t, content, "commit 0123456789ab")
+	assert.Contains(t, content, "clang-tidy not found")
+	assert.Equal(t, 2, strings.Count(content, `<details class="file-group"`))
+
+	// A clean analysis says so instead of listing findings
+	clean := ComprehensiveAnalysis{Tools: []ToolResults{{Tool: "Cppcheck", Status: "success"}}}
+	require.NoError(t, generateHTMLReport(clean, outputFile))
+	data, err = os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "No issues found!")
+	assert.NotContains(t, string(data), `<details class="file-group"`)
+}
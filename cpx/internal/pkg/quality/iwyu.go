@@ -0,0 +1,180 @@
+package quality
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+)
+
+// iwyuTools are the names include-what-you-use's driver over a compilation
+// database is installed as, and iwyuFixTools those of its fixer
+var (
+	iwyuTools    = []string{"iwyu_tool.py", "iwyu_tool", "iwyu-tool"}
+	iwyuFixTools = []string{"fix_includes.py", "iwyu-fix-includes", "fix_includes"}
+)
+
+var (
+	iwyuSection = regexp.MustCompile(`^(.+) should (add|remove) these lines:$`)
+	iwyuLines   = regexp.MustCompile(`lines (\d+)-\d+`)
+)
+
+// lookPathAny returns the first of names found in PATH
+func lookPathAny(names []string) (string, bool) {
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// analysisCompileDatabase returns the directory of the compilation database
+// analyzers use: the project's (from 'cpx compdb'), else the debug build's or
+// Meson's
+func analysisCompileDatabase() (string, bool) {
+	for _, dir := range []string{".", filepath.Join(".cache", "native", "debug"), "builddir", "build"} {
+		if _, err := os.Stat(filepath.Join(dir, "compile_commands.json")); err == nil {
+			abs, err := filepath.Abs(dir)
+			return abs, err == nil
+		}
+	}
+	return "", false
+}
+
+// runIWYUAnalysis runs include-what-you-use over the project's translation
+// units, applying its suggestions with fix_includes.py when fix is set.
+// extraArgs go to iwyu_tool.py.
+func runIWYUAnalysis(fix bool, extraArgs []string) ToolResults {
+	result := ToolResults{
+		Tool:    "include-what-you-use",
+		Status:  "success",
+		Results: []AnalysisResult{},
+	}
+
+	tool, ok := lookPathAny(iwyuTools)
+	if !ok {
+		result.Status = "skipped"
+		result.Error = "iwyu_tool.py not found"
+		return result
+	}
+	compileDbDir, ok := analysisCompileDatabase()
+	if !ok {
+		result.Status = "skipped"
+		result.Error = "compile_commands.json not found. Run 'cpx build' first."
+		return result
+	}
+	files, _ := lintSourceFiles()
+	if len(files) == 0 {
+		result.Status = "skipped"
+		result.Error = "no source files found"
+		return result
+	}
+
+	// include-what-you-use exits non-zero whenever it has suggestions
+	args := append([]string{"-j", strconv.Itoa(runtime.NumCPU()), "-p", compileDbDir}, extraArgs...)
+	args = append(args, files...)
+	cmd := exec.Command(tool, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	result.Results = parseIWYUOutput(string(output))
+	if _, ok := err.(*exec.ExitError); err != nil && (!ok || (len(result.Results) == 0 && stderr.Len() > 0)) {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("failed to run include-what-you-use: %v", err)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			result.Error += "\n" + msg
+		}
+		return result
+	}
+
+	if fix && len(result.Results) > 0 {
+		if err := applyIWYUFixes(string(output)); err != nil {
+			result.Status = "error"
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
+
+// applyIWYUFixes applies the suggestions of include-what-you-use output with
+// fix_includes.py
+func applyIWYUFixes(output string) error {
+	fixer, ok := lookPathAny(iwyuFixTools)
+	if !ok {
+		return fmt.Errorf("fix_includes.py not found; it comes with include-what-you-use")
+	}
+	fmt.Printf("%sApplying include-what-you-use fixes...%s\n", colors.Cyan, colors.Reset)
+	cmd := exec.Command(fixer)
+	cmd.Stdin = strings.NewReader(output)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// fix_includes.py exits with the number of files it changed
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok || stderr.Len() > 0 {
+			return fmt.Errorf("fix_includes.py failed: %w\n%s", err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return nil
+}
+
+// parseIWYUOutput parses the suggestions of include-what-you-use: a finding
+// per include or forward declaration to add, at the top of the file, and per
+// one to remove, at its line
+func parseIWYUOutput(output string) []AnalysisResult {
+	var results []AnalysisResult
+	file, action := "", ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := iwyuSection.FindStringSubmatch(line); m != nil {
+			file, action = m[1], m[2]
+			continue
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "The full include-list") || line == "---" {
+			file, action = "", ""
+			continue
+		}
+		if file == "" {
+			continue
+		}
+
+		code, comment, _ := strings.Cut(line, "//")
+		code = strings.TrimSpace(code)
+		switch action {
+		case "add":
+			message := "add " + code
+			if reason := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(comment), "for")); reason != "" {
+				message += " (for " + reason + ")"
+			}
+			results = append(results, AnalysisResult{
+				Tool:     "include-what-you-use",
+				Severity: "style",
+				File:     file,
+				Line:     1,
+				Message:  message,
+				Rule:     "iwyu-add",
+			})
+		case "remove":
+			lineNum := 1
+			if m := iwyuLines.FindStringSubmatch(comment); m != nil {
+				lineNum, _ = strconv.Atoi(m[1])
+			}
+			results = append(results, AnalysisResult{
+				Tool:     "include-what-you-use",
+				Severity: "style",
+				File:     file,
+				Line:     lineNum,
+				Message:  "remove " + strings.TrimSpace(strings.TrimPrefix(code, "- ")),
+				Rule:     "iwyu-remove",
+			})
+		}
+	}
+	return results
+}
@@ -0,0 +1,31 @@
+package quality
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIWYUOutput(t *testing.T) {
+	output := `/p/src/main.cpp should add these lines:
+#include <string>  // for string
+class Foo;
+
+/p/src/main.cpp should remove these lines:
+- #include <vector>  // lines 3-3
+- #include "util.h"  // lines 5-5
+
+The full include-list for /p/src/main.cpp:
+#include <string>  // for string
+---
+
+(/p/src/util.cpp has correct #includes/fwd-decls)
+`
+	assert.Equal(t, []AnalysisResult{
+		{Tool: "include-what-you-use", Severity: "style", File: "/p/src/main.cpp", Line: 1, Message: "add #include <string> (for string)", Rule: "iwyu-add"},
+		{Tool: "include-what-you-use", Severity: "style", File: "/p/src/main.cpp", Line: 1, Message: "add class Foo;", Rule: "iwyu-add"},
+		{Tool: "include-what-you-use", Severity: "style", File: "/p/src/main.cpp", Line: 3, Message: "remove #include <vector>", Rule: "iwyu-remove"},
+		{Tool: "include-what-you-use", Severity: "style", File: "/p/src/main.cpp", Line: 5, Message: `remove #include "util.h"`, Rule: "iwyu-remove"},
+	}, parseIWYUOutput(output))
+	assert.Empty(t, parseIWYUOutput("(/p/src/util.cpp has correct #includes/fwd-decls)\n"))
+}
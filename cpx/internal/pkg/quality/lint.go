@@ -5,14 +5,35 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/ozacod/cpx/internal/pkg/utils/colors"
 	"github.com/ozacod/cpx/internal/pkg/utils/git"
 )
 
-// LintCode runs clang-tidy static analysis
-func LintCode(fix bool, vcpkg VcpkgSetup) error {
+// LintOptions are the options of LintCode
+type LintOptions struct {
+	// Fix applies clang-tidy's suggested fixes.
+	Fix bool
+
+	// FixErrors applies fixes even when the code has compile errors.
+	FixErrors bool
+
+	// Jobs is the number of clang-tidy processes to run at once, 0 for one
+	// per CPU.
+	Jobs int
+
+	// Changed restricts linting to files changed since this git ref,
+	// including uncommitted and untracked files.
+	Changed string
+}
+
+// LintCode runs clang-tidy static analysis on the project's translation
+// units, in parallel
+func LintCode(opts LintOptions, vcpkg VcpkgSetup) error {
 	// Check if clang-tidy is available
 	if _, err := exec.LookPath("clang-tidy"); err != nil {
 		return fmt.Errorf("clang-tidy not found. Please install it first")
@@ -20,10 +41,115 @@ func LintCode(fix bool, vcpkg VcpkgSetup) error {
 
 	fmt.Printf("%s Running static analysis...%s\n", colors.Cyan, colors.Reset)
 
-	// Detect project type and find compile_commands.json
-	var compileDb string
-	var buildDir string
+	buildDir, compileDb, err := lintCompileDatabase(vcpkg)
+	if err != nil {
+		return err
+	}
+
+	files, tracked := lintSourceFiles()
+	if !tracked {
+		fmt.Printf("%s Warning: Not in a git repository. Scanning src/, include/, and current directory.%s\n", colors.Yellow, colors.Reset)
+	}
+	if opts.Changed != "" {
+		changed, err := git.ChangedFiles(".", opts.Changed)
+		if err != nil {
+			return fmt.Errorf("failed to list files changed since %s: %w", opts.Changed, err)
+		}
+		files = filterChanged(files, changed)
+	}
+
+	if len(files) == 0 {
+		fmt.Printf("%s No source files found%s\n", colors.Green, colors.Reset)
+		return nil
+	}
+
+	// Build clang-tidy args
+	var tidyArgs []string
+
+	// If we have a compile database, use it
+	if compileDb != "" {
+		if _, err := os.Stat(compileDb); os.IsNotExist(err) {
+			return fmt.Errorf("compile_commands.json not found at %s\n  Run 'cpx build' first to generate it", compileDb)
+		}
+		absBuildDir, _ := filepath.Abs(buildDir)
+		tidyArgs = append(tidyArgs, "-p", absBuildDir)
+	}
+
+	if opts.FixErrors {
+		tidyArgs = append(tidyArgs, "-fix-errors")
+	} else if opts.Fix {
+		tidyArgs = append(tidyArgs, "-fix")
+	}
+
+	// Get system include paths from the compiler to help clang-tidy find standard headers
+	// This is needed because compile_commands.json might not have all system includes
+	systemIncludes := GetSystemIncludePaths()
+
+	// Add system include paths as extra arguments
+	for _, include := range systemIncludes {
+		tidyArgs = append(tidyArgs, "--extra-arg=-isystem"+include)
+	}
 
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	if opts.Fix || opts.FixErrors {
+		// Translation units fixing the same header would overwrite each
+		// other's edits
+		jobs = 1
+	}
+	jobs = min(jobs, len(files))
+	fmt.Printf("%s Linting %d files (%d at a time)...%s\n", colors.Cyan, len(files), jobs, colors.Reset)
+
+	var (
+		mu                    sync.Mutex
+		wg                    sync.WaitGroup
+		warnings, errorsFound int
+		failed                int
+		next                  = make(chan string)
+	)
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range next {
+				output, err := exec.Command("clang-tidy", append(slices.Clip(tidyArgs), file)...).CombinedOutput()
+				w, e := countDiagnostics(string(output))
+
+				mu.Lock()
+				// Write output to stderr (warnings/errors), a file at a time
+				os.Stderr.Write(output)
+				warnings += w
+				errorsFound += e
+				if err != nil && w+e == 0 {
+					failed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, file := range files {
+		next <- file
+	}
+	close(next)
+	wg.Wait()
+
+	switch {
+	case errorsFound > 0 || warnings > 0:
+		fmt.Printf("%s  Analysis complete: %d warning(s), %d error(s)%s\n", colors.Yellow, warnings, errorsFound, colors.Reset)
+	case failed > 0:
+		fmt.Printf("%s  Analysis failed for %d file(s)%s\n", colors.Yellow, failed, colors.Reset)
+	default:
+		fmt.Printf("%s No issues found!%s\n", colors.Green, colors.Reset)
+	}
+	return nil
+}
+
+// lintCompileDatabase returns the build directory clang-tidy reads the
+// compilation database of and that database, configuring the project if it
+// has none. The database is "" when there is none to use.
+func lintCompileDatabase(vcpkg VcpkgSetup) (buildDir, compileDb string, err error) {
 	// Check for Meson project
 	if _, err := os.Stat("meson.build"); err == nil {
 		buildDir = "builddir"
@@ -38,7 +164,7 @@ func LintCode(fix bool, vcpkg VcpkgSetup) error {
 				cmd.Stdout = os.Stdout
 				cmd.Stderr = os.Stderr
 				if err := cmd.Run(); err != nil {
-					return fmt.Errorf("failed to setup meson project: %w\n  Run 'cpx build' first", err)
+					return "", "", fmt.Errorf("failed to setup meson project: %w\n  Run 'cpx build' first", err)
 				}
 			}
 		}
@@ -64,7 +190,7 @@ func LintCode(fix bool, vcpkg VcpkgSetup) error {
 		// CMake/vcpkg project
 		// Set up vcpkg environment
 		if err := vcpkg.SetupEnv(); err != nil {
-			return fmt.Errorf("failed to setup vcpkg: %w", err)
+			return "", "", fmt.Errorf("failed to setup vcpkg: %w", err)
 		}
 
 		// Use .cache/native/debug for consistency with build command
@@ -87,14 +213,14 @@ func LintCode(fix bool, vcpkg VcpkgSetup) error {
 			// Get vcpkg root for toolchain file
 			vcpkgPath, err := vcpkg.GetPath()
 			if err != nil {
-				return fmt.Errorf("vcpkg not configured: %w", err)
+				return "", "", fmt.Errorf("vcpkg not configured: %w", err)
 			}
 			vcpkgRoot := filepath.Dir(vcpkgPath)
 			toolchainFile := filepath.Join(vcpkgRoot, "scripts", "buildsystems", "vcpkg.cmake")
 
 			// Check if toolchain file exists
 			if _, err := os.Stat(toolchainFile); os.IsNotExist(err) {
-				return fmt.Errorf("vcpkg toolchain file not found: %s\n  Make sure vcpkg is properly installed", toolchainFile)
+				return "", "", fmt.Errorf("vcpkg toolchain file not found: %s\n  Make sure vcpkg is properly installed", toolchainFile)
 			}
 
 			// Configure CMake with vcpkg toolchain
@@ -126,17 +252,21 @@ func LintCode(fix bool, vcpkg VcpkgSetup) error {
 			cmd.Stdout = os.Stdout
 			cmd.Stderr = os.Stderr
 			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("failed to generate compile_commands.json: %w\n  Try running 'cpx build' first to configure the project", err)
+				return "", "", fmt.Errorf("failed to generate compile_commands.json: %w\n  Try running 'cpx build' first to configure the project", err)
 			}
 		}
 	}
 
-	// Find source files (only git-tracked files, respect .gitignore)
-	var files []string
+	return buildDir, compileDb, nil
+}
+
+// lintSourceFiles returns the translation units to lint: the git-tracked ones
+// outside build directories, or those found in the project's directories
+// outside a git repository, reporting whether they are git-tracked
+func lintSourceFiles() (files []string, tracked bool) {
 	trackedFiles, err := git.GetGitTrackedCppFiles()
 	if err != nil {
 		// If not in git repo, fall back to scanning src/include directories
-		fmt.Printf("%s Warning: Not in a git repository. Scanning src/, include/, and current directory.%s\n", colors.Yellow, colors.Reset)
 		for _, dir := range []string{".", "src", "include"} {
 			if _, err := os.Stat(dir); os.IsNotExist(err) {
 				continue
@@ -190,67 +320,28 @@ func LintCode(fix bool, vcpkg VcpkgSetup) error {
 			files = append(files, file)
 		}
 	}
-
-	if len(files) == 0 {
-		fmt.Printf("%s No source files found%s\n", colors.Green, colors.Reset)
-		return nil
-	}
-
-	// Build clang-tidy args
-	var tidyArgs []string
-
-	// If we have a compile database, use it
-	if compileDb != "" {
-		if _, err := os.Stat(compileDb); os.IsNotExist(err) {
-			return fmt.Errorf("compile_commands.json not found at %s\n  Run 'cpx build' first to generate it", compileDb)
+	var units []string
+	for _, file := range files {
+		switch filepath.Ext(file) {
+		case ".cpp", ".cc", ".cxx", ".c++", ".c":
+			units = append(units, file)
 		}
-		absBuildDir, _ := filepath.Abs(buildDir)
-		tidyArgs = append(tidyArgs, "-p", absBuildDir)
 	}
+	return units, err == nil
+}
 
-	if fix {
-		tidyArgs = append(tidyArgs, "-fix")
-	}
-
-	// Get system include paths from the compiler to help clang-tidy find standard headers
-	// This is needed because compile_commands.json might not have all system includes
-	systemIncludes := GetSystemIncludePaths()
-
-	// Add system include paths as extra arguments
-	for _, include := range systemIncludes {
-		tidyArgs = append(tidyArgs, "--extra-arg=-isystem"+include)
-	}
-	tidyArgs = append(tidyArgs, files...)
-
-	cmd := exec.Command("clang-tidy", tidyArgs...)
-	output, err := cmd.CombinedOutput()
-
-	// Write output to stderr (warnings/errors) and stdout (info)
-	os.Stderr.Write(output)
-
-	// Check if output contains warnings or errors
-	outputStr := string(output)
-	hasWarnings := strings.Contains(outputStr, "warning:") ||
-		strings.Contains(outputStr, "error:") ||
-		strings.Contains(outputStr, "note:")
-
-	if err != nil {
-		// clang-tidy returns non-zero on errors or when warnings are treated as errors
-		if hasWarnings {
-			fmt.Printf("%s  Analysis complete with issues found%s\n", colors.Yellow, colors.Reset)
-		} else {
-			fmt.Printf("%s  Analysis failed%s\n", colors.Yellow, colors.Reset)
+// countDiagnostics returns the numbers of warnings and errors in clang-tidy
+// output
+func countDiagnostics(output string) (warnings, errorsFound int) {
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.Contains(line, ": warning: "):
+			warnings++
+		case strings.Contains(line, ": error: "):
+			errorsFound++
 		}
-		return nil
 	}
-
-	if hasWarnings {
-		fmt.Printf("%s  Analysis complete with warnings%s\n", colors.Yellow, colors.Reset)
-		return nil
-	}
-
-	fmt.Printf("%s No issues found!%s\n", colors.Green, colors.Reset)
-	return nil
+	return warnings, errorsFound
 }
 
 // GetSystemIncludePaths gets system include paths from the compiler
@@ -0,0 +1,24 @@
+package quality
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountDiagnostics(t *testing.T) {
+	output := `/p/src/main.cpp:3:5: warning: variable 'x' is not initialized [cppcoreguidelines-init-variables]
+    3 |   int x;
+      |     ^
+/p/src/main.cpp:7:1: error: unknown type name 'foo' [clang-diagnostic-error]
+/p/src/util.cpp:1:1: warning: header guard does not follow preferred style [llvm-header-guard]
+2 warnings and 1 error generated.
+`
+	warnings, errors := countDiagnostics(output)
+	assert.Equal(t, 2, warnings)
+	assert.Equal(t, 1, errors)
+
+	warnings, errors = countDiagnostics("")
+	assert.Zero(t, warnings)
+	assert.Zero(t, errors)
+}
@@ -0,0 +1,503 @@
+package quality
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"slices"
+	"strings"
+)
+
+// MetricsThresholds are the limits past which the metrics analyzer reports
+// findings. A zero limit is the default one; a negative one disables its
+// check.
+type MetricsThresholds struct {
+	// Complexity is the cyclomatic complexity of a function, default 15.
+	Complexity int
+
+	// FunctionLines are the lines of code of a function, default 100.
+	FunctionLines int
+
+	// FileLines are the lines of code of a file, default 1000.
+	FileLines int
+
+	// DuplicateTokens is the length of the shortest duplicated block
+	// reported, in tokens, default 100.
+	DuplicateTokens int
+}
+
+// DefaultMetricsThresholds are the limits of zero MetricsThresholds, those
+// of lizard and PMD's copy-paste detector
+var DefaultMetricsThresholds = MetricsThresholds{Complexity: 15, FunctionLines: 100, FileLines: 1000, DuplicateTokens: 100}
+
+// withDefaults returns the thresholds with zero limits set to the defaults
+func (t MetricsThresholds) withDefaults() MetricsThresholds {
+	return MetricsThresholds{
+		Complexity:      cmp.Or(t.Complexity, DefaultMetricsThresholds.Complexity),
+		FunctionLines:   cmp.Or(t.FunctionLines, DefaultMetricsThresholds.FunctionLines),
+		FileLines:       cmp.Or(t.FileLines, DefaultMetricsThresholds.FileLines),
+		DuplicateTokens: cmp.Or(t.DuplicateTokens, DefaultMetricsThresholds.DuplicateTokens),
+	}
+}
+
+// MetricsSummary are the code metrics of an analysis
+type MetricsSummary struct {
+	Files             int     `json:"files"`
+	Lines             int     `json:"lines"`
+	Functions         int     `json:"functions"`
+	AverageComplexity float64 `json:"average_complexity"`
+	MaxComplexity     int     `json:"max_complexity"`
+	DuplicatedLines   int     `json:"duplicated_lines"`
+}
+
+// cppToken is a token of C/C++ source
+type cppToken struct {
+	text string
+	line int
+}
+
+// cppOperators are the operators of more than one character tokenizeCpp
+// keeps whole; >> is left out as it closes nested templates
+var cppOperators = []string{"&&", "||", "::", "->", "==", "!=", "<=", ">=", "++", "--", "+=", "-=", "*=", "/=", "|=", "&=", "<<"}
+
+// rawStringPrefixes are the identifiers a raw string literal starts with
+var rawStringPrefixes = []string{"R", "u8R", "uR", "UR", "LR"}
+
+// tokenizeCpp returns the tokens of C/C++ source without comments and
+// preprocessor directives, and the number of lines of code, directives
+// included. String and character literals are single tokens.
+func tokenizeCpp(src string) ([]cppToken, int) {
+	var tokens []cppToken
+	codeLines := make(map[int]bool)
+	line, lineStart := 1, true
+	isIdent := func(c byte) bool {
+		return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+	}
+	// literal returns the end of a quoted literal starting at i
+	literal := func(i int) int {
+		quote := src[i]
+		for i++; i < len(src) && src[i] != quote && src[i] != '\n'; i++ {
+			if src[i] == '\\' {
+				i++
+			}
+		}
+		return min(i+1, len(src))
+	}
+	add := func(text string) {
+		tokens = append(tokens, cppToken{text: text, line: line})
+		codeLines[line] = true
+		line += strings.Count(text, "\n")
+		lineStart = false
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = true
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 4
+			}
+			line += strings.Count(src[i:i+end+4], "\n")
+			i += end + 4
+		case c == '#' && lineStart:
+			// A directive continues over escaped newlines
+			for i < len(src) && src[i] != '\n' {
+				if src[i] == '\\' && i+1 < len(src) && src[i+1] == '\n' {
+					codeLines[line] = true
+					line++
+					i++
+				}
+				i++
+			}
+			codeLines[line] = true
+		case c == '"' || c == '\'':
+			end := literal(i)
+			add(src[i:end])
+			i = end
+		case isIdent(c):
+			end := i
+			for end < len(src) && (isIdent(src[end]) || c >= '0' && c <= '9' && (src[end] == '.' || src[end] == '\'')) {
+				end++
+			}
+			if end < len(src) && src[end] == '"' && slices.Contains(rawStringPrefixes, src[i:end]) {
+				// R"delim( ... )delim"
+				if open := strings.IndexByte(src[end:], '('); open >= 0 {
+					delim := ")" + src[end+1:end+open] + `"`
+					if close := strings.Index(src[end+open:], delim); close >= 0 {
+						end += open + close + len(delim)
+					}
+				}
+			}
+			add(src[i:end])
+			i = end
+		default:
+			text := src[i : i+1]
+			for _, op := range cppOperators {
+				if strings.HasPrefix(src[i:], op) {
+					text = op
+					break
+				}
+			}
+			add(text)
+			i += len(text)
+		}
+	}
+	return tokens, len(codeLines)
+}
+
+// cppFunction is a function definition found by findFunctions
+type cppFunction struct {
+	name       string
+	line       int
+	endLine    int
+	complexity int
+	lines      int
+}
+
+// complexityTokens are the tokens adding a path through a function, as lizard
+// counts them
+var complexityTokens = []string{"if", "for", "while", "case", "catch", "&&", "||", "?", "and", "or"}
+
+// notFunctionNames are keywords a parenthesis follows in a declaration that
+// does not name a function
+var notFunctionNames = []string{"if", "for", "while", "switch", "catch", "return", "sizeof", "alignof", "alignas", "decltype", "noexcept", "throw", "requires", "__attribute__", "__declspec", "static_assert"}
+
+// notFunctionKeywords start declarations whose braces are not function bodies
+var notFunctionKeywords = []string{"class", "struct", "union", "enum", "namespace", "typedef", "using"}
+
+// matching returns the index of the token closing the bracket at i, or the
+// last token if it is not closed
+func matching(tokens []cppToken, i int) int {
+	open := tokens[i].text
+	close := map[string]string{"(": ")", "{": "}", "[": "]"}[open]
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].text {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// functionName returns the index of the name of the function a declaration
+// defines, or -1 if it defines none. The name precedes the last parameter
+// list, before an initializer list or trailing return type.
+func functionName(header []cppToken) int {
+	if len(header) == 0 || slices.Contains(notFunctionKeywords, header[0].text) {
+		return -1
+	}
+	name := -1
+	for i := 0; i < len(header); i++ {
+		switch header[i].text {
+		case "->":
+			return name
+		case ":":
+			// A constructor's initializer list, not an access specifier
+			if i > 0 && header[i-1].text == ")" {
+				return name
+			}
+		case "=":
+			// Only operator= is a function
+			if i == 0 || header[i-1].text != "operator" {
+				return -1
+			}
+		case "(":
+			switch {
+			case i > 0 && isName(header[i-1].text) && !slices.Contains(notFunctionNames, header[i-1].text):
+				name = i - 1
+			case i > 1 && header[i-2].text == "operator":
+				name = i - 2
+			}
+			i = matching(header, i)
+		case "[", "{":
+			i = matching(header, i)
+		}
+	}
+	return name
+}
+
+// isName reports whether a token is an identifier
+func isName(text string) bool {
+	c := text[0]
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// qualifiedName returns the name of a function with its class or namespace
+// qualifiers, a destructor's ~ and an operator's symbol
+func qualifiedName(header []cppToken, i int) string {
+	name := header[i].text
+	switch {
+	case name == "operator" && i+1 < len(header):
+		name += header[i+1].text
+		if header[i+1].text == "(" {
+			name += ")"
+		}
+	case i > 0 && header[i-1].text == "operator":
+		// A conversion operator
+		name = "operator " + name
+		i--
+	case i > 0 && header[i-1].text == "~":
+		name = "~" + name
+		i--
+	}
+	for i >= 2 && header[i-1].text == "::" && isName(header[i-2].text) {
+		name = header[i-2].text + "::" + name
+		i -= 2
+	}
+	return name
+}
+
+// isInitializerBrace reports whether the brace after a declaration
+// initializes a member in a constructor's initializer list, as in
+// Foo() : a{1}, rather than opening a body
+func isInitializerBrace(header []cppToken) bool {
+	if len(header) < 2 {
+		return false
+	}
+	last := header[len(header)-1].text
+	if !isName(last) && last != ">" {
+		return false
+	}
+	for i := 0; i < len(header); i++ {
+		switch header[i].text {
+		case "(", "[", "{":
+			i = matching(header, i)
+		case ":":
+			if i > 0 && header[i-1].text == ")" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findFunctions returns the function definitions of tokens, with their
+// cyclomatic complexity and lines of code. Lambdas and local classes count
+// toward the function they are in.
+func findFunctions(tokens []cppToken) []cppFunction {
+	var functions []cppFunction
+	start := 0
+	for i := 0; i < len(tokens); {
+		switch tokens[i].text {
+		case "(", "[":
+			i = matching(tokens, i) + 1
+			continue
+		case ";", "}":
+			start = i + 1
+		case "{":
+			header := tokens[start:i]
+			if isInitializerBrace(header) {
+				i = matching(tokens, i) + 1
+				continue
+			}
+			if name := functionName(header); name >= 0 {
+				end := matching(tokens, i)
+				f := cppFunction{name: qualifiedName(header, name), line: header[name].line, endLine: tokens[end].line, complexity: 1}
+				lines := make(map[int]bool)
+				for _, t := range tokens[start : end+1] {
+					lines[t.line] = true
+				}
+				for _, t := range tokens[i : end+1] {
+					if slices.Contains(complexityTokens, t.text) {
+						f.complexity++
+					}
+				}
+				f.lines = len(lines)
+				functions = append(functions, f)
+				i = end + 1
+				start = i
+				continue
+			}
+			start = i + 1
+		}
+		i++
+	}
+	return functions
+}
+
+// tokenRef is the position of a token in a file of findDuplicates
+type tokenRef struct {
+	file int
+	pos  int
+}
+
+// duplicate is a block of tokens repeating an earlier one
+type duplicate struct {
+	file, start, end          int // token indexes of the repeat
+	srcFile, srcStart, srcEnd int // token indexes of the earlier block
+}
+
+// findDuplicates returns the blocks of at least minTokens tokens of files
+// that repeat an earlier block, with a rolling hash over windows of
+// minTokens tokens
+func findDuplicates(files [][]cppToken, minTokens int) []duplicate {
+	const base = 1000003
+	power := uint64(1)
+	for range minTokens - 1 {
+		power *= base
+	}
+	seen := make(map[uint64]tokenRef)
+	var duplicates []duplicate
+
+	for f, tokens := range files {
+		if len(tokens) < minTokens {
+			continue
+		}
+		ids := make([]uint64, len(tokens))
+		for i, t := range tokens {
+			h := fnv.New64a()
+			h.Write([]byte(t.text))
+			ids[i] = h.Sum64()
+		}
+		var hash uint64
+		for _, id := range ids[:minTokens] {
+			hash = hash*base + id
+		}
+
+		var active *duplicate
+		flush := func() {
+			if active != nil {
+				duplicates = append(duplicates, *active)
+				active = nil
+			}
+		}
+		for i := 0; i+minTokens <= len(tokens); i++ {
+			if i > 0 {
+				hash = (hash-ids[i-1]*power)*base + ids[i+minTokens-1]
+			}
+			ref, ok := seen[hash]
+			if ok && !(ref.file == f && ref.pos+minTokens > i) && sameTokens(files[ref.file][ref.pos:ref.pos+minTokens], tokens[i:i+minTokens]) {
+				if active != nil && active.srcFile == ref.file && active.srcEnd-minTokens+2 == ref.pos {
+					active.end++
+					active.srcEnd++
+				} else {
+					flush()
+					active = &duplicate{file: f, start: i, end: i + minTokens - 1, srcFile: ref.file, srcStart: ref.pos, srcEnd: ref.pos + minTokens - 1}
+				}
+				continue
+			}
+			flush()
+			if !ok {
+				seen[hash] = tokenRef{file: f, pos: i}
+			}
+		}
+		flush()
+	}
+	return duplicates
+}
+
+// sameTokens reports whether two token sequences have the same text
+func sameTokens(a, b []cppToken) bool {
+	return slices.EqualFunc(a, b, func(x, y cppToken) bool { return x.text == y.text })
+}
+
+// runMetricsAnalysis measures the complexity and length of the functions of
+// files, their length and duplicated blocks, and reports those past the
+// thresholds
+func runMetricsAnalysis(files []string, thresholds MetricsThresholds) (ToolResults, MetricsSummary) {
+	result := ToolResults{
+		Tool:    "metrics",
+		Status:  "success",
+		Results: []AnalysisResult{},
+	}
+	var summary MetricsSummary
+	if len(files) == 0 {
+		result.Status = "skipped"
+		result.Error = "no source files found"
+		return result, summary
+	}
+	limits := thresholds.withDefaults()
+
+	// tokens holds those of the files that could be read, in read
+	var tokens [][]cppToken
+	var read []string
+	var totalComplexity int
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		fileTokens, lines := tokenizeCpp(string(data))
+		tokens = append(tokens, fileTokens)
+		read = append(read, file)
+		summary.Files++
+		summary.Lines += lines
+		if limits.FileLines > 0 && lines > limits.FileLines {
+			result.Results = append(result.Results, AnalysisResult{
+				Tool:     "metrics",
+				Severity: "style",
+				File:     file,
+				Line:     1,
+				Message:  fmt.Sprintf("file has %d lines of code, more than %d", lines, limits.FileLines),
+				Rule:     "file-length",
+			})
+		}
+
+		for _, f := range findFunctions(fileTokens) {
+			summary.Functions++
+			totalComplexity += f.complexity
+			summary.MaxComplexity = max(summary.MaxComplexity, f.complexity)
+			if limits.Complexity > 0 && f.complexity > limits.Complexity {
+				result.Results = append(result.Results, AnalysisResult{
+					Tool:     "metrics",
+					Severity: "warning",
+					File:     file,
+					Line:     f.line,
+					EndLine:  f.endLine,
+					Message:  fmt.Sprintf("'%s' has a cyclomatic complexity of %d, more than %d", f.name, f.complexity, limits.Complexity),
+					Rule:     "cyclomatic-complexity",
+				})
+			}
+			if limits.FunctionLines > 0 && f.lines > limits.FunctionLines {
+				result.Results = append(result.Results, AnalysisResult{
+					Tool:     "metrics",
+					Severity: "style",
+					File:     file,
+					Line:     f.line,
+					EndLine:  f.endLine,
+					Message:  fmt.Sprintf("'%s' has %d lines of code, more than %d", f.name, f.lines, limits.FunctionLines),
+					Rule:     "function-length",
+				})
+			}
+		}
+	}
+	if summary.Functions > 0 {
+		summary.AverageComplexity = float64(totalComplexity) / float64(summary.Functions)
+	}
+
+	if limits.DuplicateTokens > 0 {
+		for _, d := range findDuplicates(tokens, limits.DuplicateTokens) {
+			repeat, src := tokens[d.file], tokens[d.srcFile]
+			startLine, endLine := repeat[d.start].line, repeat[d.end].line
+			summary.DuplicatedLines += endLine - startLine + 1
+			result.Results = append(result.Results, AnalysisResult{
+				Tool:     "metrics",
+				Severity: "style",
+				File:     read[d.file],
+				Line:     startLine,
+				EndLine:  endLine,
+				Message:  fmt.Sprintf("lines %d-%d duplicate %s:%d-%d (%d tokens)", startLine, endLine, read[d.srcFile], src[d.srcStart].line, src[d.srcEnd].line, d.end-d.start+1),
+				Rule:     "duplicate-code",
+			})
+		}
+	}
+	return result, summary
+}
@@ -0,0 +1,124 @@
+package quality
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tokenTexts(tokens []cppToken) []string {
+	texts := make([]string, len(tokens))
+	for i, t := range tokens {
+		texts[i] = t.text
+	}
+	return texts
+}
+
+func TestTokenizeCpp(t *testing.T) {
+	src := "#include <vector>\n#define X(a) \\\n  (a)\n\n// comment\nint x = 1'000; /* block\ncomment */ auto s = R\"(a \"b\"\nc)\";\nif (a && b || c->d::e) {}\n"
+	tokens, lines := tokenizeCpp(src)
+	assert.Equal(t, []string{
+		"int", "x", "=", "1'000", ";", "auto", "s", "=", "R\"(a \"b\"\nc)\"", ";",
+		"if", "(", "a", "&&", "b", "||", "c", "->", "d", "::", "e", ")", "{", "}",
+	}, tokenTexts(tokens))
+	assert.Equal(t, 6, tokens[0].line)
+	assert.Equal(t, 7, tokens[5].line)
+	assert.Equal(t, 9, tokens[10].line)
+	// Directives count, blank and comment lines do not
+	assert.Equal(t, 7, lines)
+}
+
+func TestFindFunctions(t *testing.T) {
+	src := `namespace app {
+
+class Widget : public Base {
+public:
+    Widget() : size_{0}, name_("w") {}
+    bool operator==(const Widget& other) const { return size_ == other.size_; }
+    int size() const noexcept { return size_ > 0 ? size_ : 0; }
+private:
+    int size_;
+};
+
+template <typename T>
+T clamp(T v, T lo, T hi) {
+    if (v < lo) {
+        return lo;
+    } else if (v > hi) {
+        return hi;
+    }
+    for (int i = 0; i < 3 && v; i++) {}
+    auto f = [](int x) { return x; };
+    return v;
+}
+
+int Widget::~Widget() {}
+
+} // namespace app
+
+extern "C" {
+int c_api(void) { switch (1) { case 1: case 2: return 0; } return 1; }
+}
+`
+	tokens, _ := tokenizeCpp(src)
+	functions := findFunctions(tokens)
+	require.Len(t, functions, 6)
+
+	names := make([]string, len(functions))
+	for i, f := range functions {
+		names[i] = f.name
+	}
+	assert.Equal(t, []string{"Widget", "operator==", "size", "clamp", "Widget::~Widget", "c_api"}, names)
+	assert.Equal(t, 1, functions[0].complexity)
+	assert.Equal(t, 2, functions[2].complexity)
+	assert.Equal(t, 5, functions[3].complexity)
+	assert.Equal(t, 13, functions[3].line)
+	assert.Equal(t, 22, functions[3].endLine)
+	// The template line is the function's
+	assert.Equal(t, 11, functions[3].lines)
+	assert.Equal(t, 3, functions[5].complexity)
+}
+
+func TestFindDuplicates(t *testing.T) {
+	var body strings.Builder
+	for i := range 10 {
+		fmt.Fprintf(&body, "    total += values[%d] * weights[%d];\n", i, i)
+	}
+	a, _ := tokenizeCpp("int a() {\n" + body.String() + "}\n")
+	b, _ := tokenizeCpp("// copied\nvoid other();\nint b() {\n" + body.String() + "}\n")
+
+	duplicates := findDuplicates([][]cppToken{a, b}, 50)
+	require.Len(t, duplicates, 1)
+	d := duplicates[0]
+	assert.Equal(t, 1, d.file)
+	assert.Equal(t, 0, d.srcFile)
+	// From the parameter lists of the functions to their closing braces
+	assert.Equal(t, 3, b[d.start].line)
+	assert.Equal(t, 14, b[d.end].line)
+	assert.Equal(t, 1, a[d.srcStart].line)
+	assert.Equal(t, d.end-d.start, d.srcEnd-d.srcStart)
+
+	assert.Empty(t, findDuplicates([][]cppToken{a, b}, 500))
+}
+
+func TestRunMetricsAnalysis(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFiles(t, map[string]string{
+		"src/a.cpp": "int f(int a, int b) {\n  if (a) return 1;\n  if (b) return 2;\n  if (a && b) return 3;\n  return 0;\n}\n",
+		"src/b.cpp": "int g() {\n  return 0;\n}\n",
+	})
+
+	result, summary := runMetricsAnalysis([]string{"src/a.cpp", "src/b.cpp"}, MetricsThresholds{Complexity: 3, FunctionLines: 4, FileLines: -1})
+	assert.Equal(t, "success", result.Status)
+	assert.Equal(t, []AnalysisResult{
+		{Tool: "metrics", Severity: "warning", File: "src/a.cpp", Line: 1, EndLine: 6, Message: "'f' has a cyclomatic complexity of 5, more than 3", Rule: "cyclomatic-complexity"},
+		{Tool: "metrics", Severity: "style", File: "src/a.cpp", Line: 1, EndLine: 6, Message: "'f' has 6 lines of code, more than 4", Rule: "function-length"},
+	}, result.Results)
+	assert.Equal(t, MetricsSummary{Files: 2, Lines: 9, Functions: 2, AverageComplexity: 3, MaxComplexity: 5}, summary)
+
+	result, _ = runMetricsAnalysis(nil, MetricsThresholds{})
+	assert.Equal(t, "skipped", result.Status)
+}
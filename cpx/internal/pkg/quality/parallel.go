@@ -0,0 +1,100 @@
+package quality
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/colors"
+)
+
+// analyzer is an analyzer of runAnalyzers
+type analyzer struct {
+	// name names the analyzer in progress reports.
+	name string
+
+	// run runs the analyzer, reporting its progress to p.
+	run func(p *analyzerProgress) ToolResults
+
+	// exclusive runs the analyzer after the others, alone, as it edits
+	// sources.
+	exclusive bool
+}
+
+// runAnalyzers runs analyzers at once, then the exclusive ones one at a time,
+// and returns their results in the order of analyzers
+func runAnalyzers(analyzers []analyzer, p *analyzerProgress) []ToolResults {
+	tools := make([]ToolResults, len(analyzers))
+	run := func(i int) {
+		start := time.Now()
+		tools[i] = analyzers[i].run(p)
+		p.finished(analyzers[i].name, tools[i], time.Since(start))
+	}
+
+	var names []string
+	for _, a := range analyzers {
+		if !a.exclusive {
+			names = append(names, a.name)
+		}
+	}
+	if len(names) > 0 {
+		p.printf(colors.Cyan, "Running %s...", strings.Join(names, ", "))
+	}
+	var wg sync.WaitGroup
+	for i, a := range analyzers {
+		if a.exclusive {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(i)
+		}()
+	}
+	wg.Wait()
+
+	for i, a := range analyzers {
+		if a.exclusive {
+			p.printf(colors.Cyan, "Running %s...", a.name)
+			run(i)
+		}
+	}
+	return tools
+}
+
+// analyzerProgress reports the progress of analyzers running at once, a
+// line at a time
+type analyzerProgress struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// printf prints a line in color
+func (p *analyzerProgress) printf(color, format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, "%s%s%s\n", color, fmt.Sprintf(format, args...), colors.Reset)
+}
+
+// files reports that an analyzer is done with done of total files, at each
+// tenth of them so that large projects print few lines
+func (p *analyzerProgress) files(name string, done, total int) {
+	if done == total || done*10/total != (done-1)*10/total {
+		p.printf(colors.Cyan, "   %s: %d/%d files", name, done, total)
+	}
+}
+
+// finished reports the outcome of an analyzer and how long it ran
+func (p *analyzerProgress) finished(name string, result ToolResults, elapsed time.Duration) {
+	elapsed = elapsed.Round(100 * time.Millisecond)
+	switch result.Status {
+	case "skipped":
+		p.printf(colors.Yellow, "-  %s skipped: %s", name, result.Error)
+	case "error":
+		p.printf(colors.Red, "✗  %s failed after %s: %s", name, elapsed, result.Error)
+	default:
+		p.printf(colors.Green, "✓  %s: %d finding(s) in %s", name, len(result.Results), elapsed)
+	}
+}
@@ -0,0 +1,92 @@
+package quality
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAnalyzers(t *testing.T) {
+	// Each analyzer waits for the other to start, which only analyzers
+	// running at once do
+	var started sync.WaitGroup
+	started.Add(2)
+	waiting := func(tool string, findings int) func(*analyzerProgress) ToolResults {
+		return func(*analyzerProgress) ToolResults {
+			started.Done()
+			started.Wait()
+			return ToolResults{Tool: tool, Status: "success", Results: make([]AnalysisResult, findings)}
+		}
+	}
+	var order []string
+	analyzers := []analyzer{
+		{name: "fixer", exclusive: true, run: func(*analyzerProgress) ToolResults {
+			order = append(order, "fixer")
+			return ToolResults{Tool: "fixer", Status: "skipped", Error: "fixer not found"}
+		}},
+		{name: "slow", run: func(p *analyzerProgress) ToolResults {
+			defer func() { order = append(order, "slow") }()
+			time.Sleep(10 * time.Millisecond)
+			return waiting("slow", 2)(p)
+		}},
+		{name: "fast", run: waiting("fast", 1)},
+	}
+
+	var out bytes.Buffer
+	done := make(chan []ToolResults)
+	go func() { done <- runAnalyzers(analyzers, &analyzerProgress{out: &out}) }()
+	var tools []ToolResults
+	select {
+	case tools = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("analyzers did not run at once")
+	}
+
+	require.Len(t, tools, 3)
+	assert.Equal(t, "fixer", tools[0].Tool)
+	assert.Equal(t, "slow", tools[1].Tool)
+	assert.Equal(t, "fast", tools[2].Tool)
+	// The exclusive analyzer runs after the others
+	assert.Equal(t, []string{"slow", "fixer"}, order)
+
+	output := out.String()
+	assert.Contains(t, output, "Running slow, fast...")
+	assert.Contains(t, output, "slow: 2 finding(s) in")
+	assert.Contains(t, output, "fast: 1 finding(s) in")
+	assert.Contains(t, output, "Running fixer...")
+	assert.Contains(t, output, "fixer skipped: fixer not found")
+}
+
+func TestAnalyzerProgressFiles(t *testing.T) {
+	var out bytes.Buffer
+	p := &analyzerProgress{out: &out}
+	for done := 1; done <= 40; done++ {
+		p.files("clang-tidy", done, 40)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 10)
+	assert.Contains(t, lines[0], "clang-tidy: 4/40 files")
+	assert.Contains(t, lines[9], "clang-tidy: 40/40 files")
+
+	out.Reset()
+	for done := 1; done <= 3; done++ {
+		p.files("clang-tidy", done, 3)
+	}
+	assert.Equal(t, 3, strings.Count(out.String(), "\n"))
+}
+
+func TestMergeClangTidyFindings(t *testing.T) {
+	header := AnalysisResult{Tool: "clang-tidy", Severity: "warning", File: "include/a.h", Line: 3, Message: "m", Rule: "r"}
+	a := AnalysisResult{Tool: "clang-tidy", Severity: "warning", File: "src/a.cpp", Line: 1, Message: "m", Rule: "r"}
+	b := AnalysisResult{Tool: "clang-tidy", Severity: "error", File: "src/b.cpp", Line: 2, Message: "m", Rule: "r"}
+
+	merged := mergeClangTidyFindings([][]AnalysisResult{{header, a}, nil, {header, b}})
+	assert.Equal(t, []AnalysisResult{header, a, b}, merged)
+	assert.Empty(t, mergeClangTidyFindings(nil))
+	assert.NotNil(t, mergeClangTidyFindings(nil))
+}
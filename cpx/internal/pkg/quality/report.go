@@ -0,0 +1,303 @@
+package quality
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// junitTestSuites is a JUnit XML report: a test suite per tool and a failing
+// test case per finding, as Jenkins and GitLab render test results
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport returns the JUnit report of an analysis. A tool without
+// findings gets a passing test case, one that failed to run an error and a
+// skipped one a skipped test case.
+func junitReport(analysis ComprehensiveAnalysis) junitTestSuites {
+	report := junitTestSuites{Name: "cpx analyze"}
+	for _, tool := range analysis.Tools {
+		suite := junitTestSuite{Name: tool.Tool, Timestamp: analysis.Timestamp.Format("2006-01-02T15:04:05")}
+		switch {
+		case tool.Status == "error":
+			suite.Errors = 1
+			suite.Cases = []junitTestCase{{Name: tool.Tool, ClassName: tool.Tool, Error: &junitMessage{Message: tool.Error}}}
+		case tool.Status == "skipped":
+			suite.Skipped = 1
+			suite.Cases = []junitTestCase{{Name: tool.Tool, ClassName: tool.Tool, Skipped: &junitMessage{Message: tool.Error}}}
+		case len(tool.Results) == 0:
+			suite.Cases = []junitTestCase{{Name: tool.Tool, ClassName: tool.Tool}}
+		default:
+			for _, r := range tool.Results {
+				suite.Failures++
+				suite.Cases = append(suite.Cases, junitTestCase{
+					Name:      fmt.Sprintf("%s:%d: %s", r.File, r.Line, findingRule(r)),
+					ClassName: tool.Tool + "." + strings.ReplaceAll(r.File, "/", "."),
+					File:      r.File,
+					Line:      r.Line,
+					Failure: &junitMessage{
+						Message: r.Message,
+						Type:    r.Severity,
+						Text:    fmt.Sprintf("%s:%d:%d: %s: %s [%s]", r.File, r.Line, r.Column, r.Severity, r.Message, findingRule(r)),
+					},
+				})
+			}
+		}
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Suites = append(report.Suites, suite)
+	}
+	return report
+}
+
+// checkstyleReport is a Checkstyle XML report, as Jenkins' warnings plugin and
+// code quality tools read it
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// newCheckstyleReport returns the Checkstyle report of an analysis, with the
+// files in the order their first finding appears
+func newCheckstyleReport(analysis ComprehensiveAnalysis) checkstyleReport {
+	report := checkstyleReport{Version: "4.3"}
+	index := make(map[string]int)
+	for _, tool := range analysis.Tools {
+		for _, r := range tool.Results {
+			i, ok := index[r.File]
+			if !ok {
+				i = len(report.Files)
+				index[r.File] = i
+				report.Files = append(report.Files, checkstyleFile{Name: r.File})
+			}
+			report.Files[i].Errors = append(report.Files[i].Errors, checkstyleError{
+				Line:     r.Line,
+				Column:   r.Column,
+				Severity: checkstyleSeverity(r.Severity),
+				Message:  r.Message,
+				Source:   tool.Tool + "." + findingRule(r),
+			})
+		}
+	}
+	return report
+}
+
+// checkstyleSeverity maps the severity of a finding to error, warning or
+// info, the Checkstyle severities
+func checkstyleSeverity(severity string) string {
+	switch severity {
+	case "error", "warning":
+		return severity
+	default:
+		return "info"
+	}
+}
+
+// githubAnnotations returns the GitHub Actions workflow commands annotating
+// the lines of the findings of an analysis: ::error for errors, ::warning for
+// warnings and ::notice for the rest
+func githubAnnotations(analysis ComprehensiveAnalysis) string {
+	var sb strings.Builder
+	for _, tool := range analysis.Tools {
+		for _, r := range tool.Results {
+			command := "notice"
+			if r.Severity == "error" || r.Severity == "warning" {
+				command = r.Severity
+			}
+			fmt.Fprintf(&sb, "::%s file=%s,line=%d", command, escapeGitHubProperty(r.File), r.Line)
+			if r.Column > 0 {
+				fmt.Fprintf(&sb, ",col=%d", r.Column)
+			}
+			fmt.Fprintf(&sb, ",title=%s::%s\n", escapeGitHubProperty(tool.Tool+": "+findingRule(r)), escapeGitHubData(r.Message))
+		}
+	}
+	return sb.String()
+}
+
+// escapeGitHubData escapes the message of a workflow command
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes a property value of a workflow command
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// RelativizePaths makes the paths of the findings under root relative to it,
+// with forward slashes, as GitHub and other code hosts name files. The
+// results are copied first, so copies of analysis keep their paths.
+func (analysis *ComprehensiveAnalysis) RelativizePaths(root string) {
+	analysis.Tools = slices.Clone(analysis.Tools)
+	for i := range analysis.Tools {
+		analysis.Tools[i].Results = slices.Clone(analysis.Tools[i].Results)
+		for j := range analysis.Tools[i].Results {
+			r := &analysis.Tools[i].Results[j]
+			r.File = relativePath(r.File, root)
+		}
+	}
+}
+
+// relativePath returns a path under root relative to it, with forward
+// slashes; other absolute paths are kept
+func relativePath(file, root string) string {
+	if !filepath.IsAbs(file) {
+		return filepath.ToSlash(filepath.Clean(file))
+	}
+	if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
+
+// severityRanks orders the severities of findings for --fail-on; cppcheck's
+// style, performance and portability rank between warnings and infos
+var severityRanks = map[string]int{
+	"error":       3,
+	"warning":     2,
+	"style":       1,
+	"performance": 1,
+	"portability": 1,
+	"info":        0,
+	"information": 0,
+}
+
+// FailOnSeverities are the severities --fail-on accepts
+var FailOnSeverities = []string{"error", "warning", "style", "info"}
+
+// CheckThresholds returns an error when the analysis has a finding of
+// severity failOn or worse, or more than maxWarnings warnings. An empty
+// failOn or a negative maxWarnings disables its check. With either check
+// enabled, an analyzer that failed or was skipped fails the analysis too,
+// since the findings it would have reported are missing.
+func (analysis ComprehensiveAnalysis) CheckThresholds(failOn string, maxWarnings int) error {
+	threshold, ok := severityRanks[failOn]
+	if failOn != "" && !ok {
+		return fmt.Errorf("unknown severity '%s' for --fail-on: use %s", failOn, strings.Join(FailOnSeverities, ", "))
+	}
+	failing, warnings := 0, 0
+	for _, tool := range analysis.Tools {
+		for _, r := range tool.Results {
+			if failOn != "" && severityRanks[r.Severity] >= threshold {
+				failing++
+			}
+			if r.Severity == "warning" {
+				warnings++
+			}
+		}
+	}
+	if failing > 0 {
+		return fmt.Errorf("%d finding(s) of severity %s or worse", failing, failOn)
+	}
+	if maxWarnings >= 0 && warnings > maxWarnings {
+		return fmt.Errorf("%d warning(s), more than the %d allowed", warnings, maxWarnings)
+	}
+	if failOn != "" || maxWarnings >= 0 {
+		var incomplete []string
+		for _, tool := range analysis.Tools {
+			switch tool.Status {
+			case "error":
+				incomplete = append(incomplete, fmt.Sprintf("%s failed (%s)", tool.Tool, tool.Error))
+			case "skipped":
+				incomplete = append(incomplete, fmt.Sprintf("%s was skipped (%s)", tool.Tool, tool.Error))
+			}
+		}
+		if len(incomplete) > 0 {
+			return fmt.Errorf("analysis incomplete: %s; fix or disable the analyzer", strings.Join(incomplete, ", "))
+		}
+	}
+	return nil
+}
+
+// findingRule returns the rule of a finding, or its severity when the tool
+// reports none
+func findingRule(r AnalysisResult) string {
+	if r.Rule != "" {
+		return r.Rule
+	}
+	return r.Severity
+}
+
+// writeXMLReport writes an XML report to outputFile
+func writeXMLReport(report any, outputFile string) error {
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+// writeJSONReport writes an analysis as JSON to outputFile, the report
+// LoadAnalysis reads
+func writeJSONReport(analysis ComprehensiveAnalysis, outputFile string) error {
+	data, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, append(data, '\n'), 0644)
+}
+
+// LoadAnalysis reads a JSON report of an analysis
+func LoadAnalysis(path string) (ComprehensiveAnalysis, error) {
+	var analysis ComprehensiveAnalysis
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return analysis, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return analysis, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return analysis, nil
+}
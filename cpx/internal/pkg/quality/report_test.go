@@ -0,0 +1,139 @@
+package quality
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAnalysis() ComprehensiveAnalysis {
+	return ComprehensiveAnalysis{
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Tools: []ToolResults{
+			{Tool: "Cppcheck", Status: "success", Results: []AnalysisResult{
+				{Tool: "Cppcheck", Severity: "style", File: "src/main.cpp", Line: 3, Column: 5, Message: "Variable 'x' is assigned a value that is never used.", Rule: "unreadVariable"},
+			}},
+			{Tool: "clang-tidy", Status: "success", Results: []AnalysisResult{
+				{Tool: "clang-tidy", Severity: "warning", File: "src/util.cpp", Line: 10, Column: 1, Message: "use auto", Rule: "modernize-use-auto"},
+				{Tool: "clang-tidy", Severity: "error", File: "src/main.cpp", Line: 7, Column: 2, Message: "unknown type name 'foo'"},
+			}},
+			{Tool: "Flawfinder", Status: "skipped", Error: "flawfinder not found"},
+		},
+	}
+}
+
+func TestJUnitReport(t *testing.T) {
+	report := junitReport(testAnalysis())
+	assert.Equal(t, 4, report.Tests)
+	assert.Equal(t, 3, report.Failures)
+	assert.Equal(t, 0, report.Errors)
+	require.Len(t, report.Suites, 3)
+
+	tidy := report.Suites[1]
+	assert.Equal(t, "clang-tidy", tidy.Name)
+	assert.Equal(t, 2, tidy.Failures)
+	assert.Equal(t, "src/util.cpp:10: modernize-use-auto", tidy.Cases[0].Name)
+	assert.Equal(t, "clang-tidy.src.util.cpp", tidy.Cases[0].ClassName)
+	assert.Equal(t, "src/main.cpp:7: error", tidy.Cases[1].Name)
+	assert.Equal(t, "src/main.cpp:7:2: error: unknown type name 'foo' [error]", tidy.Cases[1].Failure.Text)
+
+	flawfinder := report.Suites[2]
+	assert.Equal(t, 1, flawfinder.Skipped)
+	require.NotNil(t, flawfinder.Cases[0].Skipped)
+	assert.Nil(t, flawfinder.Cases[0].Failure)
+}
+
+func TestCheckstyleReport(t *testing.T) {
+	report := newCheckstyleReport(testAnalysis())
+	require.Len(t, report.Files, 2)
+	assert.Equal(t, "src/main.cpp", report.Files[0].Name)
+	assert.Equal(t, []checkstyleError{
+		{Line: 3, Column: 5, Severity: "info", Message: "Variable 'x' is assigned a value that is never used.", Source: "Cppcheck.unreadVariable"},
+		{Line: 7, Column: 2, Severity: "error", Message: "unknown type name 'foo'", Source: "clang-tidy.error"},
+	}, report.Files[0].Errors)
+	assert.Equal(t, "src/util.cpp", report.Files[1].Name)
+	assert.Equal(t, "warning", report.Files[1].Errors[0].Severity)
+}
+
+func TestWriteXMLReport(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.xml")
+	require.NoError(t, writeXMLReport(newCheckstyleReport(testAnalysis()), file))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `<?xml version="1.0" encoding="UTF-8"?>`)
+	assert.Contains(t, string(data), `<error line="10" column="1" severity="warning" message="use auto" source="clang-tidy.modernize-use-auto"></error>`)
+
+	var parsed checkstyleReport
+	require.NoError(t, xml.Unmarshal(data, &parsed))
+	assert.Len(t, parsed.Files, 2)
+}
+
+func TestLoadAnalysis(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "analyze.json")
+	require.NoError(t, writeJSONReport(testAnalysis(), file))
+
+	analysis, err := LoadAnalysis(file)
+	require.NoError(t, err)
+	assert.True(t, testAnalysis().Timestamp.Equal(analysis.Timestamp))
+	require.Len(t, analysis.Tools, 3)
+	assert.Equal(t, testAnalysis().Tools[1].Results, analysis.Tools[1].Results)
+
+	_, err = LoadAnalysis(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestGitHubAnnotations(t *testing.T) {
+	analysis := testAnalysis()
+	analysis.Tools[1].Results[0].Message = "100% of 2,\nlines"
+	assert.Equal(t, `::notice file=src/main.cpp,line=3,col=5,title=Cppcheck%3A unreadVariable::Variable 'x' is assigned a value that is never used.
+::warning file=src/util.cpp,line=10,col=1,title=clang-tidy%3A modernize-use-auto::100%25 of 2,%0Alines
+::error file=src/main.cpp,line=7,col=2,title=clang-tidy%3A error::unknown type name 'foo'
+`, githubAnnotations(analysis))
+}
+
+func TestRelativizePaths(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "project")
+	analysis := ComprehensiveAnalysis{Tools: []ToolResults{{Results: []AnalysisResult{
+		{File: filepath.Join(root, "src", "main.cpp")},
+		{File: "./src/util.cpp"},
+		{File: filepath.Join(filepath.Dir(root), "other", "x.cpp")},
+	}}}}
+	original := analysis
+	analysis.RelativizePaths(root)
+	results := analysis.Tools[0].Results
+	assert.Equal(t, filepath.Join(root, "src", "main.cpp"), original.Tools[0].Results[0].File, "copies keep their paths")
+	assert.Equal(t, "src/main.cpp", results[0].File)
+	assert.Equal(t, "src/util.cpp", results[1].File)
+	assert.Equal(t, filepath.Join(filepath.Dir(root), "other", "x.cpp"), results[2].File)
+}
+
+func TestCheckThresholds(t *testing.T) {
+	analysis := testAnalysis() // a style finding, a warning and an error
+	analysis.Tools[2].Status = "success"
+
+	assert.NoError(t, analysis.CheckThresholds("", -1))
+	assert.EqualError(t, analysis.CheckThresholds("error", -1), "1 finding(s) of severity error or worse")
+	assert.EqualError(t, analysis.CheckThresholds("warning", -1), "2 finding(s) of severity warning or worse")
+	assert.EqualError(t, analysis.CheckThresholds("info", -1), "3 finding(s) of severity info or worse")
+	assert.NoError(t, analysis.CheckThresholds("", 1))
+	assert.EqualError(t, analysis.CheckThresholds("", 0), "1 warning(s), more than the 0 allowed")
+	assert.Error(t, analysis.CheckThresholds("critical", -1))
+
+	analysis.Tools[1].Results = nil
+	assert.NoError(t, analysis.CheckThresholds("warning", 0))
+	assert.Error(t, analysis.CheckThresholds("style", 0))
+
+	// Gates need every enabled analyzer to have run
+	analysis.Tools[2].Status = "skipped"
+	assert.NoError(t, analysis.CheckThresholds("", -1))
+	assert.EqualError(t, analysis.CheckThresholds("warning", 0), "analysis incomplete: Flawfinder was skipped (flawfinder not found); fix or disable the analyzer")
+	analysis.Tools[2].Status = "success"
+	analysis.Tools[0].Status, analysis.Tools[0].Error = "error", "cppcheck crashed"
+	assert.EqualError(t, analysis.CheckThresholds("error", -1), "analysis incomplete: Cppcheck failed (cppcheck crashed); fix or disable the analyzer")
+}
@@ -0,0 +1,186 @@
+package quality
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// BundledSemgrepRules names the C/C++ rules cpx bundles among the Semgrep
+// rulesets of AnalyzeOptions
+const BundledSemgrepRules = "cpx"
+
+//go:embed rules/semgrep-cpp.yaml
+var semgrepRules string
+
+// bundledSemgrepIDs are the ids of the bundled rules
+var bundledSemgrepIDs = func() []string {
+	var ids []string
+	for _, m := range regexp.MustCompile(`(?m)^  - id: (\S+)$`).FindAllStringSubmatch(semgrepRules, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}()
+
+// semgrepSeverities maps Semgrep's severities to those of findings
+var semgrepSeverities = map[string]string{
+	"ERROR":    "error",
+	"CRITICAL": "error",
+	"HIGH":     "error",
+	"WARNING":  "warning",
+	"MEDIUM":   "warning",
+	"INFO":     "info",
+	"LOW":      "info",
+}
+
+// semgrepOutput is the part of `semgrep --json` output cpx reads
+type semgrepOutput struct {
+	Results []struct {
+		CheckID string          `json:"check_id"`
+		Path    string          `json:"path"`
+		Start   semgrepPosition `json:"start"`
+		End     semgrepPosition `json:"end"`
+		Extra   struct {
+			Message  string `json:"message"`
+			Severity string `json:"severity"`
+		} `json:"extra"`
+	} `json:"results"`
+	Errors []struct {
+		Level   string `json:"level"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type semgrepPosition struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// semgrepArgs returns the arguments of a Semgrep scan of dirs with rulesets,
+// by default the bundled rules, which are read from bundledFile, and
+// extraArgs
+func semgrepArgs(rulesets []string, bundledFile string, extraArgs, dirs []string) []string {
+	if len(rulesets) == 0 {
+		rulesets = []string{BundledSemgrepRules}
+	}
+	args := []string{"scan", "--json", "--quiet", "--metrics=off", "--disable-version-check"}
+	for _, ruleset := range rulesets {
+		if ruleset == BundledSemgrepRules {
+			ruleset = bundledFile
+		}
+		args = append(args, "--config", ruleset)
+	}
+	args = append(args, extraArgs...)
+	return append(args, dirs...)
+}
+
+// runSemgrepAnalysis runs Semgrep with rulesets over the source directories
+// of targets
+func runSemgrepAnalysis(rulesets, targets, extraArgs []string) ToolResults {
+	result := ToolResults{
+		Tool:    "Semgrep",
+		Status:  "success",
+		Results: []AnalysisResult{},
+	}
+
+	if _, err := exec.LookPath("semgrep"); err != nil {
+		result.Status = "skipped"
+		result.Error = "semgrep not found"
+		return result
+	}
+	dirs := discoverSourceDirectories(targets)
+	if len(dirs) == 0 {
+		result.Status = "skipped"
+		result.Error = "no source directories found to scan"
+		return result
+	}
+
+	rules, err := os.CreateTemp("", "cpx-semgrep-*.yaml")
+	if err != nil {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("failed to create temp file: %v", err)
+		return result
+	}
+	defer os.Remove(rules.Name())
+	_, err = rules.WriteString(semgrepRules)
+	rules.Close()
+	if err != nil {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("failed to write Semgrep rules: %v", err)
+		return result
+	}
+
+	// Semgrep exits non-zero on rule errors, which its JSON reports
+	cmd := exec.Command("semgrep", semgrepArgs(rulesets, rules.Name(), extraArgs, dirs)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if len(bytes.TrimSpace(output)) == 0 {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("semgrep failed: %v\n%s", err, strings.TrimSpace(stderr.String()))
+		return result
+	}
+	findings, err := parseSemgrepJSON(output)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	if findings != nil {
+		result.Results = findings
+	}
+	return result
+}
+
+// parseSemgrepJSON parses `semgrep --json` output. A scan without results
+// that had errors fails with the first one.
+func parseSemgrepJSON(data []byte) ([]AnalysisResult, error) {
+	var output semgrepOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse semgrep output: %w", err)
+	}
+	if len(output.Results) == 0 {
+		for _, e := range output.Errors {
+			if strings.EqualFold(e.Level, "error") {
+				return nil, fmt.Errorf("semgrep failed: %s", strings.TrimSpace(e.Message))
+			}
+		}
+	}
+
+	var results []AnalysisResult
+	for _, r := range output.Results {
+		severity, ok := semgrepSeverities[strings.ToUpper(r.Extra.Severity)]
+		if !ok {
+			severity = strings.ToLower(r.Extra.Severity)
+		}
+		results = append(results, AnalysisResult{
+			Tool:      "Semgrep",
+			Severity:  severity,
+			File:      r.Path,
+			Line:      r.Start.Line,
+			Column:    r.Start.Col,
+			EndLine:   r.End.Line,
+			EndColumn: r.End.Col,
+			Message:   strings.TrimSpace(r.Extra.Message),
+			Rule:      semgrepRule(r.CheckID),
+		})
+	}
+	return results, nil
+}
+
+// semgrepRule returns the rule of a check id. Semgrep prefixes the ids of
+// rules from files with the file's directory, which for the bundled rules is
+// a temporary one, so they get their bare id.
+func semgrepRule(checkID string) string {
+	for _, id := range bundledSemgrepIDs {
+		if checkID == id || strings.HasSuffix(checkID, "."+id) {
+			return id
+		}
+	}
+	return checkID
+}
@@ -0,0 +1,58 @@
+package quality
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSemgrepJSON(t *testing.T) {
+	output := `{
+  "results": [
+    {
+      "check_id": "tmp.cpx-unbounded-copy",
+      "path": "src/main.cpp",
+      "start": {"line": 7, "col": 5, "offset": 90},
+      "end": {"line": 7, "col": 24, "offset": 109},
+      "extra": {"message": "Unbounded copy into a buffer", "severity": "WARNING", "lines": "requires login"}
+    },
+    {
+      "check_id": "rules.no-raw-new",
+      "path": "src/util.cpp",
+      "start": {"line": 3, "col": 1},
+      "end": {"line": 3, "col": 10},
+      "extra": {"message": "Use std::make_unique\n", "severity": "INFO"}
+    }
+  ],
+  "errors": []
+}`
+	results, err := parseSemgrepJSON([]byte(output))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, AnalysisResult{
+		Tool:      "Semgrep",
+		Severity:  "warning",
+		File:      "src/main.cpp",
+		Line:      7,
+		Column:    5,
+		EndLine:   7,
+		EndColumn: 24,
+		Message:   "Unbounded copy into a buffer",
+		Rule:      "cpx-unbounded-copy",
+	}, results[0])
+	assert.Equal(t, "rules.no-raw-new", results[1].Rule)
+	assert.Equal(t, "info", results[1].Severity)
+	assert.Equal(t, "Use std::make_unique", results[1].Message)
+
+	_, err = parseSemgrepJSON([]byte(`{"results": [], "errors": [{"level": "error", "message": "Invalid rule schema"}]}`))
+	assert.EqualError(t, err, "semgrep failed: Invalid rule schema")
+}
+
+func TestSemgrepArgs(t *testing.T) {
+	assert.Equal(t, []string{"scan", "--json", "--quiet", "--metrics=off", "--disable-version-check", "--config", "/tmp/r.yaml", "src"},
+		semgrepArgs(nil, "/tmp/r.yaml", nil, []string{"src"}))
+	assert.Equal(t, []string{"scan", "--json", "--quiet", "--metrics=off", "--disable-version-check", "--config", "p/c", "--config", "/tmp/r.yaml", "--exclude-rule", "cpx-weak-random", "src", "include"},
+		semgrepArgs([]string{"p/c", BundledSemgrepRules}, "/tmp/r.yaml", []string{"--exclude-rule", "cpx-weak-random"}, []string{"src", "include"}))
+	assert.Contains(t, bundledSemgrepIDs, "cpx-gets")
+}
@@ -0,0 +1,278 @@
+package quality
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ToolFormats are the output formats of command tools
+var ToolFormats = []string{"regex", "sarif", "jsonl"}
+
+// CommandTool is an analyzer run as a command, such as cpplint or an
+// in-house checker, whose output is parsed into findings
+type CommandTool struct {
+	// Name names the tool in reports and skip lists.
+	Name string
+
+	// Command is run in the project with the files to check appended.
+	Command []string
+
+	// Format is that of the output, one of ToolFormats: lines Pattern
+	// matches, a SARIF log, or a JSON finding per line.
+	Format string
+
+	// Pattern matches a diagnostic of the regex format with the named groups
+	// file, line and message, and optionally column, severity and rule.
+	Pattern *regexp.Regexp
+
+	// Severity is that of findings the output gives none.
+	Severity string
+}
+
+// Cpplint is Google's C++ style checker
+var Cpplint = CommandTool{
+	Name:     "cpplint",
+	Command:  []string{"cpplint", "--quiet"},
+	Format:   "regex",
+	Pattern:  regexp.MustCompile(`^(?P<file>[^:]+):(?P<line>\d+):\s+(?P<message>.*?)\s+\[(?P<rule>[^\]]+)\] \[\d\]$`),
+	Severity: "style",
+}
+
+// NewCommandTool returns the tool of a command whose output has format,
+// regex by default. The pattern of the regex format must have the named
+// groups file, line and message. severity defaults to warning.
+func NewCommandTool(name, command, format, pattern, severity string) (CommandTool, error) {
+	tool := CommandTool{Name: name, Command: strings.Fields(command), Format: format, Severity: severity}
+	if len(tool.Command) == 0 {
+		return tool, fmt.Errorf("tool '%s' has no command", name)
+	}
+	if tool.Format == "" {
+		tool.Format = "regex"
+	}
+	if tool.Severity == "" {
+		tool.Severity = "warning"
+	}
+	switch tool.Format {
+	case "regex":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return tool, fmt.Errorf("invalid pattern of tool '%s': %w", name, err)
+		}
+		for _, group := range []string{"file", "line", "message"} {
+			if re.SubexpIndex(group) < 0 {
+				return tool, fmt.Errorf("pattern of tool '%s' has no (?P<%s>...) group", name, group)
+			}
+		}
+		tool.Pattern = re
+	case "sarif", "jsonl":
+	default:
+		return tool, fmt.Errorf("unknown format '%s' of tool '%s': use %s", format, name, strings.Join(ToolFormats, ", "))
+	}
+	return tool, nil
+}
+
+// structured reports whether the tool's output is JSON, which its stderr
+// would corrupt; regex tools such as cpplint print diagnostics to stderr
+func (t CommandTool) structured() bool {
+	return t.Format == "sarif" || t.Format == "jsonl"
+}
+
+// run runs the tool on the host over files
+func (t CommandTool) run(files []string) ToolResults {
+	result := ToolResults{
+		Tool:    t.Name,
+		Status:  "success",
+		Results: []AnalysisResult{},
+	}
+	if _, err := exec.LookPath(t.Command[0]); err != nil {
+		result.Status = "skipped"
+		result.Error = t.Command[0] + " not found"
+		return result
+	}
+	if len(files) == 0 {
+		result.Status = "skipped"
+		result.Error = "no source files found"
+		return result
+	}
+
+	// Checkers exit non-zero when they find something
+	args := append(t.Command[1:len(t.Command):len(t.Command)], files...)
+	cmd := exec.Command(t.Command[0], args...)
+	var output []byte
+	var err error
+	if t.structured() {
+		output, err = cmd.Output()
+	} else {
+		output, err = cmd.CombinedOutput()
+	}
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("failed to run %s: %v", t.Name, err)
+		return result
+	}
+	findings, err := t.parse(string(output))
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	if findings != nil {
+		result.Results = findings
+	}
+	return result
+}
+
+// parse returns the findings of the tool's output
+func (t CommandTool) parse(output string) ([]AnalysisResult, error) {
+	var results []AnalysisResult
+	var err error
+	switch t.Format {
+	case "sarif":
+		results, err = parseSARIF(output)
+	case "jsonl":
+		results, err = parseJSONLines(output)
+	default:
+		results = t.parseRegex(output)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s output: %w", t.Name, err)
+	}
+	for i := range results {
+		results[i].Tool = t.Name
+		if results[i].Severity == "" {
+			results[i].Severity = t.Severity
+		}
+	}
+	return results, nil
+}
+
+// parseRegex returns the diagnostics the tool's pattern matches; other lines
+// are ignored
+func (t CommandTool) parseRegex(output string) []AnalysisResult {
+	var results []AnalysisResult
+	group := func(m []string, name string) string {
+		if i := t.Pattern.SubexpIndex(name); i >= 0 {
+			return strings.TrimSpace(m[i])
+		}
+		return ""
+	}
+	for _, line := range splitLines(output) {
+		m := t.Pattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		lineNum, _ := strconv.Atoi(group(m, "line"))
+		column, _ := strconv.Atoi(group(m, "column"))
+		results = append(results, AnalysisResult{
+			Severity: strings.ToLower(group(m, "severity")),
+			File:     group(m, "file"),
+			Line:     lineNum,
+			Column:   column,
+			Message:  group(m, "message"),
+			Rule:     group(m, "rule"),
+		})
+	}
+	return results
+}
+
+// parseJSONLines parses a finding per line, an object with the fields of
+// AnalysisResult's JSON: file, line, column, severity, message and rule.
+// Lines that are not objects, such as progress, are ignored.
+func parseJSONLines(output string) ([]AnalysisResult, error) {
+	var results []AnalysisResult
+	for n, line := range splitLines(output) {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var r AnalysisResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		r.Severity = strings.ToLower(r.Severity)
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// sarifLog is the part of a SARIF 2.1 log cpx reads
+type sarifLog struct {
+	Runs []struct {
+		Results []struct {
+			RuleID  string `json:"ruleId"`
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+					Region struct {
+						StartLine   int `json:"startLine"`
+						StartColumn int `json:"startColumn"`
+						EndLine     int `json:"endLine"`
+						EndColumn   int `json:"endColumn"`
+					} `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+// sarifLevels maps SARIF result levels to severities
+var sarifLevels = map[string]string{
+	"error":   "error",
+	"warning": "warning",
+	"note":    "info",
+	"none":    "info",
+}
+
+// parseSARIF parses the results of a SARIF log. Results without a location
+// are ignored, and a result without a level is a warning, as in SARIF.
+func parseSARIF(output string) ([]AnalysisResult, error) {
+	var log sarifLog
+	if err := json.Unmarshal([]byte(output), &log); err != nil {
+		return nil, err
+	}
+	var results []AnalysisResult
+	for _, run := range log.Runs {
+		for _, r := range run.Results {
+			if len(r.Locations) == 0 {
+				continue
+			}
+			location := r.Locations[0].PhysicalLocation
+			severity, ok := sarifLevels[r.Level]
+			if !ok {
+				severity = "warning"
+			}
+			results = append(results, AnalysisResult{
+				Severity:  severity,
+				File:      sarifPath(location.ArtifactLocation.URI),
+				Line:      location.Region.StartLine,
+				Column:    location.Region.StartColumn,
+				EndLine:   location.Region.EndLine,
+				EndColumn: location.Region.EndColumn,
+				Message:   strings.TrimSpace(r.Message.Text),
+				Rule:      r.RuleID,
+			})
+		}
+	}
+	return results, nil
+}
+
+// sarifPath returns the path of an artifact URI: file URIs and relative
+// references are decoded, others kept
+func sarifPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "" && u.Scheme != "file") {
+		return uri
+	}
+	return u.Path
+}
@@ -0,0 +1,114 @@
+package quality
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCpplintParse(t *testing.T) {
+	output := `Done processing src/main.cpp
+src/main.cpp:0:  No copyright message found.  [legal/copyright] [5]
+src/main.cpp:12:  Missing space before {  [whitespace/braces] [5]
+Total errors found: 2
+`
+	results, err := Cpplint.parse(output)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, AnalysisResult{
+		Tool:     "cpplint",
+		Severity: "style",
+		File:     "src/main.cpp",
+		Line:     12,
+		Message:  "Missing space before {",
+		Rule:     "whitespace/braces",
+	}, results[1])
+}
+
+func TestNewCommandTool(t *testing.T) {
+	tool, err := NewCommandTool("checker", "checker --all", "", `^(?P<file>[^:]+):(?P<line>\d+):(?P<column>\d+): (?P<severity>\w+): (?P<message>.*)$`, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"checker", "--all"}, tool.Command)
+	assert.Equal(t, "regex", tool.Format)
+
+	results, err := tool.parse("src/a.cpp:3:7: Error: tab character\r\nnoise\n")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "error", results[0].Severity)
+	assert.Equal(t, 7, results[0].Column)
+	assert.Equal(t, "tab character", results[0].Message)
+
+	tool, err = NewCommandTool("checker", "checker", "regex", `^(?P<file>[^:]+):(?P<line>\d+): (?P<message>.*)$`, "")
+	require.NoError(t, err)
+	results, err = tool.parse("a.h:1: x\n")
+	require.NoError(t, err)
+	assert.Equal(t, "warning", results[0].Severity)
+
+	_, err = NewCommandTool("checker", "checker", "", `^(?P<file>[^:]+): (?P<message>.*)$`, "")
+	assert.EqualError(t, err, "pattern of tool 'checker' has no (?P<line>...) group")
+	_, err = NewCommandTool("checker", " ", "sarif", "", "")
+	assert.EqualError(t, err, "tool 'checker' has no command")
+	_, err = NewCommandTool("checker", "checker", "xml", "", "")
+	assert.EqualError(t, err, "unknown format 'xml' of tool 'checker': use regex, sarif, jsonl")
+}
+
+func TestCommandToolSARIF(t *testing.T) {
+	tool, err := NewCommandTool("house", "house-check --sarif", "sarif", "", "")
+	require.NoError(t, err)
+	output := `{
+  "version": "2.1.0",
+  "runs": [{
+    "tool": {"driver": {"name": "house-check"}},
+    "results": [
+      {
+        "ruleId": "HC001",
+        "level": "error",
+        "message": {"text": "Raw owning pointer"},
+        "locations": [{"physicalLocation": {
+          "artifactLocation": {"uri": "file:///p/src/my%20file.cpp"},
+          "region": {"startLine": 4, "startColumn": 3, "endLine": 4, "endColumn": 12}
+        }}]
+      },
+      {"ruleId": "HC002", "message": {"text": "Missing header guard"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "include/a.h"}, "region": {"startLine": 1}}}]},
+      {"ruleId": "HC003", "level": "note", "message": {"text": "No location"}}
+    ]
+  }]
+}`
+	results, err := tool.parse(output)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, AnalysisResult{
+		Tool:      "house",
+		Severity:  "error",
+		File:      "/p/src/my file.cpp",
+		Line:      4,
+		Column:    3,
+		EndLine:   4,
+		EndColumn: 12,
+		Message:   "Raw owning pointer",
+		Rule:      "HC001",
+	}, results[0])
+	assert.Equal(t, "include/a.h", results[1].File)
+	assert.Equal(t, "warning", results[1].Severity)
+
+	_, err = tool.parse("not json")
+	assert.ErrorContains(t, err, "failed to parse house output")
+}
+
+func TestCommandToolJSONLines(t *testing.T) {
+	tool, err := NewCommandTool("lines", "lines-check", "jsonl", "", "style")
+	require.NoError(t, err)
+	output := `checking 2 files
+{"file": "src/a.cpp", "line": 9, "column": 2, "message": "magic number", "rule": "LC7"}
+{"file": "src/b.cpp", "line": 1, "severity": "ERROR", "message": "banned include"}
+`
+	results, err := tool.parse(output)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, AnalysisResult{Tool: "lines", Severity: "style", File: "src/a.cpp", Line: 9, Column: 2, Message: "magic number", Rule: "LC7"}, results[0])
+	assert.Equal(t, "error", results[1].Severity)
+
+	_, err = tool.parse("{\"file\": 1}\n")
+	assert.ErrorContains(t, err, "line 1")
+}
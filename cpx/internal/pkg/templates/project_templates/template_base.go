@@ -128,8 +128,13 @@ func (h *BaseTemplateHelper) GenerateCommonFiles(config TemplateConfig) error {
 		return err
 	}
 
+	// Generate .clang-tidy
+	if err := h.WriteFile(projectName, ".clang-tidy", templates.GenerateClangTidy()); err != nil {
+		return err
+	}
+
 	// Generate cpx-ci.yaml
-	cpxCI := templates.GenerateCpxCI()
+	cpxCI := templates.GenerateCpxCI(config.PackageManager)
 	if err := h.WriteFile(projectName, "cpx-ci.yaml", cpxCI); err != nil {
 		return err
 	}
@@ -155,8 +160,8 @@ func (h *BaseTemplateHelper) SetupVcpkg(projectName string, dependencies []strin
 	vcpkgBuilder := vcpkg.New()
 	vcpkgPath, err := vcpkgBuilder.GetPath()
 	if err != nil || vcpkgPath == "" {
-		// vcpkg not configured, skip
-		return nil
+		// vcpkg not configured: write the manifest it would have created
+		return h.WriteFile(projectName, "vcpkg.json", templates.GenerateVcpkgJSON(projectName, "0.1.0", dependencies))
 	}
 
 	originalDir, err := os.Getwd()
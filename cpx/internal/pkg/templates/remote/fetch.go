@@ -0,0 +1,235 @@
+// Package remote fetches project templates from git repositories, archives
+// and local directories, and renders them into new projects.
+package remote
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fetchTimeout bounds the download of a template archive
+const fetchTimeout = 5 * time.Minute
+
+// IsSource reports whether s names a template source rather than the name of
+// a built-in or added template
+func IsSource(s string) bool {
+	return strings.HasPrefix(s, "gh:") || strings.Contains(s, "://") || strings.HasPrefix(s, "git@") ||
+		strings.ContainsRune(s, '/') || strings.ContainsRune(s, filepath.Separator)
+}
+
+// Fetch copies the template at source into dir, which must not exist yet.
+// Sources are gh:org/repo, a git URL, an http(s) URL of a .tar.gz, .tgz or
+// .zip archive, or a local directory; git sources take a branch or tag
+// after '#', as in gh:org/repo#v1.
+func Fetch(source, dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("directory '%s' already exists", dir)
+	}
+	switch {
+	case strings.HasPrefix(source, "gh:"):
+		repo, ref, _ := strings.Cut(strings.TrimPrefix(source, "gh:"), "#")
+		if strings.Count(repo, "/") != 1 {
+			return fmt.Errorf("invalid template source '%s': expected gh:org/repo", source)
+		}
+		return cloneGit("https://github.com/"+repo+".git", ref, dir)
+	case isArchive(source):
+		return fetchArchive(source, dir)
+	case strings.Contains(source, "://") || strings.HasPrefix(source, "git@"):
+		url, ref, _ := strings.Cut(source, "#")
+		return cloneGit(url, ref, dir)
+	}
+	if info, err := os.Stat(source); err != nil || !info.IsDir() {
+		return fmt.Errorf("template source '%s' is not a directory, git repository or archive URL", source)
+	}
+	return copyDir(source, dir)
+}
+
+// isArchive reports whether source is the URL of an archive to download
+func isArchive(source string) bool {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return false
+	}
+	path, _, _ := strings.Cut(source, "?")
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz") || strings.HasSuffix(path, ".zip")
+}
+
+// cloneGit shallow-clones url at ref (default: the default branch) into dir
+// without its .git directory
+func cloneGit(url, ref, dir string) error {
+	args := []string{"clone", "--depth", "1", "--quiet"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	cmd := exec.Command("git", append(args, url, dir)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to clone %s: %w\n%s", url, err, strings.TrimSpace(stderr.String()))
+	}
+	return os.RemoveAll(filepath.Join(dir, ".git"))
+}
+
+// fetchArchive downloads and extracts an archive into dir. A single
+// top-level directory, as in GitHub's source archives, is stripped.
+func fetchArchive(url, dir string) error {
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+
+	files := make(map[string]archiveFile)
+	path, _, _ := strings.Cut(url, "?")
+	if strings.HasSuffix(path, ".zip") {
+		err = readZip(data, files)
+	} else {
+		err = readTarGz(data, files)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", url, err)
+	}
+	for name := range files {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("archive entry '%s' is outside the template", name)
+		}
+	}
+	return writeFiles(dir, stripTopDir(files))
+}
+
+// archiveFile is a regular file read from an archive
+type archiveFile struct {
+	data []byte
+	mode os.FileMode
+}
+
+func readTarGz(data []byte, files map[string]archiveFile) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		files[hdr.Name] = archiveFile{data: content, mode: hdr.FileInfo().Mode().Perm()}
+	}
+}
+
+func readZip(data []byte, files map[string]archiveFile) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		files[f.Name] = archiveFile{data: content, mode: f.Mode().Perm()}
+	}
+	return nil
+}
+
+// stripTopDir removes the directory all files are in, if there is one
+func stripTopDir(files map[string]archiveFile) map[string]archiveFile {
+	top := ""
+	for name := range files {
+		first, _, ok := strings.Cut(strings.TrimPrefix(name, "./"), "/")
+		if !ok || (top != "" && first != top) {
+			return files
+		}
+		top = first
+	}
+	stripped := make(map[string]archiveFile, len(files))
+	for name, f := range files {
+		stripped[strings.TrimPrefix(strings.TrimPrefix(name, "./"), top+"/")] = f
+	}
+	return stripped
+}
+
+// writeFiles writes archive files, with local paths, under dir
+func writeFiles(dir string, files map[string]archiveFile) error {
+	for name, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		mode := f.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		if err := os.WriteFile(path, f.data, mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// copyDir copies the regular files of src into dst, without .git
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,156 @@
+package remote
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// indexFile lists the added templates in the cache directory
+const indexFile = "templates.yaml"
+
+// validName matches the names templates can be added under
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Entry is a template added with `cpx template add`
+type Entry struct {
+	Name        string `yaml:"name"`
+	Source      string `yaml:"source"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// CacheDir returns the directory added templates are cached in,
+// <config dir>/templates
+func CacheDir() (string, error) {
+	dir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "templates"), nil
+}
+
+// List returns the added templates, sorted by name
+func List() ([]Entry, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template index: %w", err)
+	}
+	var entries []Entry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse template index: %w", err)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int { return strings.Compare(a.Name, b.Name) })
+	return entries, nil
+}
+
+// Lookup returns the cache directory of an added template, or "" if no
+// template was added under name
+func Lookup(name string) (string, error) {
+	entries, err := List()
+	if err != nil {
+		return "", err
+	}
+	if !slices.ContainsFunc(entries, func(e Entry) bool { return e.Name == name }) {
+		return "", nil
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Add fetches source into the cache under name, replacing a template added
+// under the same name once the fetch succeeded
+func Add(name, source string) (*Entry, error) {
+	if !validName.MatchString(name) || name == indexFile {
+		return nil, fmt.Errorf("invalid template name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create template cache: %w", err)
+	}
+	tmp, err := os.MkdirTemp(dir, ".fetch-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template cache: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	fetched := filepath.Join(tmp, "template")
+	if err := Fetch(source, fetched); err != nil {
+		return nil, err
+	}
+	manifest, err := LoadManifest(fetched)
+	if err != nil {
+		return nil, err
+	}
+	cached := filepath.Join(dir, name)
+	if err := os.RemoveAll(cached); err != nil {
+		return nil, fmt.Errorf("failed to replace template '%s': %w", name, err)
+	}
+	if err := os.Rename(fetched, cached); err != nil {
+		return nil, fmt.Errorf("failed to cache template '%s': %w", name, err)
+	}
+
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		source, _ = filepath.Abs(source)
+	}
+	entry := Entry{Name: name, Source: source, Description: manifest.Description}
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+	entries = slices.DeleteFunc(entries, func(e Entry) bool { return e.Name == name })
+	entries = append(entries, entry)
+	if err := writeIndex(dir, entries); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Remove deletes an added template from the cache
+func Remove(name string) error {
+	entries, err := List()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(entries, func(e Entry) bool { return e.Name == name }) {
+		return fmt.Errorf("template '%s' was not added", name)
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("failed to remove template '%s': %w", name, err)
+	}
+	return writeIndex(dir, slices.DeleteFunc(entries, func(e Entry) bool { return e.Name == name }))
+}
+
+func writeIndex(dir string, entries []Entry) error {
+	slices.SortFunc(entries, func(a, b Entry) int { return strings.Compare(a.Name, b.Name) })
+	data, err := yaml.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to write template index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, indexFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write template index: %w", err)
+	}
+	return nil
+}
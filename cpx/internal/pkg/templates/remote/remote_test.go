@@ -0,0 +1,147 @@
+package remote
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplate(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestRender(t *testing.T) {
+	dir := writeTemplate(t, map[string]string{
+		ManifestFile: `description: service
+variables:
+  - name: port
+    default: "8080"
+  - name: binary
+    default: "{{project_name}}d"
+exclude: ["*.png"]
+`,
+		"include/{{namespace}}/{{project_name}}.hpp": "namespace {{namespace}} { int port = {{port}}; } // {{unknown}}",
+		"CMakeLists.txt": "project({{project_name}})\nadd_executable({{binary}})",
+		"docs/logo.png":  "{{project_name}}",
+		"data.bin":       "\x00{{project_name}}",
+	})
+	m, err := LoadManifest(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "service", m.Description)
+
+	vars, err := m.Values("my-app", map[string]string{"port": "9000"})
+	require.NoError(t, err)
+	target := filepath.Join(t.TempDir(), "my-app")
+	require.NoError(t, m.Render(dir, target, vars))
+
+	header, err := os.ReadFile(filepath.Join(target, "include", "my_app", "my-app.hpp"))
+	require.NoError(t, err)
+	assert.Equal(t, "namespace my_app { int port = 9000; } // {{unknown}}", string(header))
+	cmake, err := os.ReadFile(filepath.Join(target, "CMakeLists.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "project(my-app)\nadd_executable(my-appd)", string(cmake))
+	binary, err := os.ReadFile(filepath.Join(target, "data.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "\x00{{project_name}}", string(binary), "binary files are copied unchanged")
+	assert.NoFileExists(t, filepath.Join(target, "docs", "logo.png"))
+	assert.NoFileExists(t, filepath.Join(target, ManifestFile))
+
+	_, err = m.Values("my-app", map[string]string{"prot": "1"})
+	assert.ErrorContains(t, err, "unknown template variable 'prot'")
+
+	// Neither file names nor values may write outside the project
+	dir = writeTemplate(t, map[string]string{"{{binary}}.txt": "x"})
+	m, err = LoadManifest(dir)
+	require.NoError(t, err)
+	for _, name := range []string{"../escaped", "/tmp/escaped"} {
+		outside := filepath.Join(t.TempDir(), "my-app")
+		err = m.Render(dir, outside, map[string]string{"binary": name})
+		assert.ErrorContains(t, err, "outside the project", name)
+		assert.NoFileExists(t, filepath.Join(filepath.Dir(outside), "escaped.txt"))
+	}
+}
+
+func TestFetchArchive(t *testing.T) {
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{"repo-main/README.md": "{{project_name}}", "repo-main/src/main.cpp": "int main() {}"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, err := zw.Create("../escape.txt")
+	require.NoError(t, err)
+	_, _ = w.Write([]byte("x"))
+	require.NoError(t, zw.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/t.tar.gz":
+			_, _ = w.Write(tgz.Bytes())
+		case "/evil.zip":
+			_, _ = w.Write(zipped.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "t")
+	require.NoError(t, Fetch(server.URL+"/t.tar.gz", dir))
+	assert.FileExists(t, filepath.Join(dir, "README.md"), "the top-level directory is stripped")
+	assert.FileExists(t, filepath.Join(dir, "src", "main.cpp"))
+
+	assert.ErrorContains(t, Fetch(server.URL+"/evil.zip", filepath.Join(t.TempDir(), "e")), "outside the template")
+	assert.ErrorContains(t, Fetch(server.URL+"/missing.zip", filepath.Join(t.TempDir(), "m")), "404")
+	assert.ErrorContains(t, Fetch("gh:acme", filepath.Join(t.TempDir(), "g")), "expected gh:org/repo")
+}
+
+func TestRegistry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	src := writeTemplate(t, map[string]string{ManifestFile: "description: starter\n", "main.cpp": "int main() {}"})
+
+	entry, err := Add("starter", src)
+	require.NoError(t, err)
+	assert.Equal(t, "starter", entry.Description)
+	_, err = Add("starter", src)
+	require.NoError(t, err, "adding a name again refreshes it")
+
+	entries, err := List()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{Name: "starter", Source: src, Description: "starter"}}, entries)
+	dir, err := Lookup("starter")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "main.cpp"))
+
+	_, err = Add("../x", src)
+	assert.ErrorContains(t, err, "invalid template name")
+
+	require.NoError(t, Remove("starter"))
+	dir, err = Lookup("starter")
+	require.NoError(t, err)
+	assert.Empty(t, dir)
+	assert.Error(t, Remove("starter"))
+}
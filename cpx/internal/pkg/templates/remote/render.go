@@ -0,0 +1,154 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ozacod/cpx/internal/pkg/utils/naming"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile describes a template; it is not copied into projects
+const ManifestFile = "cpx-template.yaml"
+
+// Manifest is a template's cpx-template.yaml. Templates without one are
+// copied as they are, with the built-in variables substituted.
+type Manifest struct {
+	Name        string     `yaml:"name,omitempty"`
+	Description string     `yaml:"description,omitempty"`
+	Variables   []Variable `yaml:"variables,omitempty"`
+	Exclude     []string   `yaml:"exclude,omitempty"` // globs of files not copied, e.g. "docs/*.png"
+}
+
+// Variable is a template variable beyond the built-in project_name and
+// namespace, set with `cpx new --var name=value`
+type Variable struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Default     string `yaml:"default,omitempty"` // may use {{project_name}} and {{namespace}}
+}
+
+// LoadManifest reads the manifest of the template in dir, or returns an
+// empty one if it has none
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ManifestFile, err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+	}
+	for i, v := range m.Variables {
+		if v.Name == "" {
+			return nil, fmt.Errorf("%s: variables[%d] needs a name", ManifestFile, i)
+		}
+	}
+	return &m, nil
+}
+
+// Values returns the values of the template's variables for a project:
+// project_name, namespace (project_name as a C++ identifier), then the
+// manifest's variables, each overridden by values
+func (m *Manifest) Values(projectName string, values map[string]string) (map[string]string, error) {
+	vars := map[string]string{
+		"project_name": projectName,
+		"namespace":    naming.SafeIdent(projectName),
+	}
+	if v, ok := values["namespace"]; ok {
+		vars["namespace"] = v
+	}
+	for _, v := range m.Variables {
+		if value, ok := values[v.Name]; ok {
+			vars[v.Name] = value
+			continue
+		}
+		vars[v.Name] = substitute(v.Default, vars)
+	}
+	for name := range values {
+		if _, ok := vars[name]; !ok || name == "project_name" {
+			return nil, fmt.Errorf("unknown template variable '%s'", name)
+		}
+	}
+	return vars, nil
+}
+
+// Render copies the template in dir to target, replacing {{name}} with the
+// value of each variable in file contents and paths. Binary files are
+// copied unchanged, and placeholders of other names are left alone.
+func (m *Manifest) Render(dir, target string, vars map[string]string) error {
+	return filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		if m.excluded(filepath.ToSlash(rel)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rendered := substitute(rel, vars)
+		if !filepath.IsLocal(rendered) {
+			return fmt.Errorf("template path '%s' renders to '%s', outside the project", filepath.ToSlash(rel), rendered)
+		}
+		dst := filepath.Join(target, rendered)
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+		if !bytes.Contains(data, []byte{0}) {
+			data = []byte(substitute(string(data), vars))
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(dst, data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dst, err)
+		}
+		return nil
+	})
+}
+
+// excluded reports whether a template path is left out of projects: .git,
+// the manifest, and paths or file names matching an Exclude glob
+func (m *Manifest) excluded(rel string) bool {
+	if rel == ".git" || rel == ManifestFile {
+		return true
+	}
+	return slices.ContainsFunc(m.Exclude, func(glob string) bool {
+		full, _ := path.Match(glob, rel)
+		base, _ := path.Match(glob, path.Base(rel))
+		return full || base
+	})
+}
+
+// substitute replaces {{name}} with the value of each variable in s
+func substitute(s string, vars map[string]string) string {
+	var pairs []string
+	for name, value := range vars {
+		pairs = append(pairs, "{{"+name+"}}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
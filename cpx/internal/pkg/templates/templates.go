@@ -1,6 +1,7 @@
 package templates
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -432,6 +433,100 @@ FetchContent_MakeAvailable(nanobench)
 	return sb.String()
 }
 
+// CMakePackage describes the CMake config package of a library project
+type CMakePackage struct {
+	Name           string   // package name, as in find_package(<Name>)
+	Version        string   // literal version, used when project() sets none
+	HasVersion     bool     // project() sets a VERSION, used as ${PROJECT_VERSION}
+	Targets        []string // library targets, exported as <Name>::<target>
+	Aliases        []string // targets that need a <Name>::<target> alias for add_subdirectory users
+	Dependencies   []string // packages found with find_package, re-found for consumers
+	Compatibility  string   // write_basic_package_version_file COMPATIBILITY
+	InstallHeaders bool     // install the include/ directory
+}
+
+// GenerateCMakePackageConfig generates cmake/<Name>Config.cmake.in, the
+// package config installed for find_package(<Name> CONFIG)
+func GenerateCMakePackageConfig(pkg CMakePackage) string {
+	var sb strings.Builder
+	sb.WriteString("@PACKAGE_INIT@\n\n")
+	if len(pkg.Dependencies) > 0 {
+		sb.WriteString("include(CMakeFindDependencyMacro)\n")
+		for _, dep := range pkg.Dependencies {
+			fmt.Fprintf(&sb, "find_dependency(%s)\n", dep)
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "include(\"${CMAKE_CURRENT_LIST_DIR}/%sTargets.cmake\")\n", pkg.Name)
+	fmt.Fprintf(&sb, "check_required_components(%s)\n", pkg.Name)
+	return sb.String()
+}
+
+// GenerateCMakeInstall generates cmake/<Name>Install.cmake: the install()
+// rules of the library targets, their export set, and the package config
+// and version files
+func GenerateCMakeInstall(pkg CMakePackage) string {
+	version := "${PROJECT_VERSION}"
+	if !pkg.HasVersion {
+		version = pkg.Version
+	}
+	compatibility := pkg.Compatibility
+	if compatibility == "" {
+		compatibility = "SameMajorVersion"
+	}
+	targets := strings.Join(pkg.Targets, " ")
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `# Install rules and CMake package of %[1]s, generated by 'cpx cmake-package'.
+# Consumers of the installed library use:
+#   find_package(%[1]s CONFIG REQUIRED)
+#   target_link_libraries(app PRIVATE %[1]s::%[2]s)
+include(GNUInstallDirs)
+include(CMakePackageConfigHelpers)
+
+install(TARGETS %[3]s
+    EXPORT %[1]sTargets
+    ARCHIVE DESTINATION ${CMAKE_INSTALL_LIBDIR}
+    LIBRARY DESTINATION ${CMAKE_INSTALL_LIBDIR}
+    RUNTIME DESTINATION ${CMAKE_INSTALL_BINDIR}
+    INCLUDES DESTINATION ${CMAKE_INSTALL_INCLUDEDIR}
+)
+`, pkg.Name, pkg.Targets[0], targets)
+	if pkg.InstallHeaders {
+		sb.WriteString("install(DIRECTORY ${PROJECT_SOURCE_DIR}/include/ DESTINATION ${CMAKE_INSTALL_INCLUDEDIR})\n")
+	}
+	fmt.Fprintf(&sb, `
+install(EXPORT %[1]sTargets
+    FILE %[1]sTargets.cmake
+    NAMESPACE %[1]s::
+    DESTINATION ${CMAKE_INSTALL_LIBDIR}/cmake/%[1]s
+)
+
+configure_package_config_file(
+    ${CMAKE_CURRENT_LIST_DIR}/%[1]sConfig.cmake.in
+    ${CMAKE_CURRENT_BINARY_DIR}/%[1]sConfig.cmake
+    INSTALL_DESTINATION ${CMAKE_INSTALL_LIBDIR}/cmake/%[1]s
+)
+write_basic_package_version_file(
+    ${CMAKE_CURRENT_BINARY_DIR}/%[1]sConfigVersion.cmake
+    VERSION %[2]s
+    COMPATIBILITY %[3]s
+)
+install(FILES
+    ${CMAKE_CURRENT_BINARY_DIR}/%[1]sConfig.cmake
+    ${CMAKE_CURRENT_BINARY_DIR}/%[1]sConfigVersion.cmake
+    DESTINATION ${CMAKE_INSTALL_LIBDIR}/cmake/%[1]s
+)
+`, pkg.Name, version, compatibility)
+	if len(pkg.Aliases) > 0 {
+		sb.WriteString("\n# The same names for projects adding this one with add_subdirectory() or FetchContent\n")
+		for _, target := range pkg.Aliases {
+			fmt.Fprintf(&sb, "add_library(%s::%s ALIAS %s)\n", pkg.Name, target, target)
+		}
+	}
+	return sb.String()
+}
+
 // ============================================================================
 // CONFIGURATION TEMPLATES
 // ============================================================================
@@ -480,6 +575,9 @@ test_results/
 
 # Local Cache
 .cache/
+
+# cpx local state
+.cpx/
 `
 }
 
@@ -518,83 +616,196 @@ IndentCaseLabels: true
 	}
 }
 
-// GenerateCpxCI generates a cpx-ci.yaml file with empty toolchains
-func GenerateCpxCI() string {
-	return `# cpx-ci.yaml - Toolchain configuration
-# This file defines toolchains for building your project
+// GenerateClangTidy generates a .clang-tidy file for `cpx lint`
+func GenerateClangTidy() string {
+	return `Checks: >
+  -*,
+  bugprone-*,
+  clang-analyzer-*,
+  cppcoreguidelines-*,
+  modernize-*,
+  performance-*,
+  readability-*,
+  -modernize-use-trailing-return-type,
+  -readability-magic-numbers,
+  -cppcoreguidelines-avoid-magic-numbers,
+  -readability-identifier-length
+WarningsAsErrors: ''
+HeaderFilterRegex: '(include|src)/.*'
+FormatStyle: file
+`
+}
+
+// GenerateVcpkgJSON generates a vcpkg.json manifest, used when vcpkg is not
+// installed to create one. Dependencies may name features, as in
+// "imgui[glfw-binding]".
+func GenerateVcpkgJSON(projectName, projectVersion string, dependencies []string) string {
+	type dependency struct {
+		Name     string   `json:"name"`
+		Features []string `json:"features,omitempty"`
+	}
+	manifest := struct {
+		Name         string `json:"name"`
+		Version      string `json:"version"`
+		Dependencies []any  `json:"dependencies"`
+	}{Name: vcpkgPortName(projectName), Version: projectVersion, Dependencies: []any{}}
+	for _, dep := range dependencies {
+		name, features, ok := strings.Cut(strings.TrimSuffix(dep, "]"), "[")
+		if !ok {
+			manifest.Dependencies = append(manifest.Dependencies, dep)
+			continue
+		}
+		manifest.Dependencies = append(manifest.Dependencies, dependency{Name: name, Features: strings.Split(features, ",")})
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	return string(data) + "\n"
+}
+
+// vcpkgPortName lowercases a project name and replaces what vcpkg does not
+// allow in manifest names with dashes
+func vcpkgPortName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+		} else if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "-") {
+			sb.WriteRune('-')
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "-")
+}
+
+// GenerateCpxCI generates a starter cpx-ci.yaml for a project using
+// packageManager: CMake projects build natively in Debug and Release, while
+// Bazel and Meson projects, which build in Docker only, start without
+// toolchains
+func GenerateCpxCI(packageManager string) string {
+	header := `# cpx-ci.yaml - toolchains built by 'cpx ci build'
+# 'cpx ci validate' checks this file; 'cpx ci schema' writes a JSON Schema for editors.
+`
+	if packageManager == "bazel" || packageManager == "meson" {
+		return header + `
+runners:
+  # A Docker image with the compilers and ` + packageManager + ` installed
+  # - name: linux
+  #   type: docker
+  #   image: my-` + packageManager + `-image:latest
+  #   platform: linux/amd64
+
+toolchains: []
+  # - name: linux-release
+  #   runner: linux
+  #   build_type: Release
+`
+	}
+	return header + `
+runners:
+  # CMake and the compilers on this machine
+  - name: host
+    type: native
+  # A fully static Linux executable, built in Docker from a built-in preset
+  # - name: linux-static
+  #   type: docker
+  #   target: linux-amd64-musl-static
 
 toolchains:
-  # Example: Pull a pre-built image from registry
-  # - name: "alpine-gcc"
-  #   active: true                 # Set to false to skip this toolchain (default: true)
-  #   runner: "docker"
-  #   docker:
-  #     mode: "pull"              # pull, local, or build
-  #     image: "alpine:latest"
-  #     platform: "linux/amd64"
-  #     pullPolicy: "ifNotPresent" # always, never, ifNotPresent
-  #   # Per-toolchain build configuration (overrides global defaults)
-  #   build_type: "Release"       # Debug, Release, RelWithDebInfo, MinSizeRel
-  #   cmake_options: []           # Additional CMake arguments
-  #   build_options: []           # Additional build arguments (cmake --build args)
-  #   env:                        # Environment variables
-  #     CC: "gcc"
-  #     CXX: "g++"
-
-  # Example: Use a local image (no network)
-  # - name: "my-toolchain"
-  #   runner: "docker"
-  #   docker:
-  #     mode: "local"
-  #     image: "my-toolchain:latest"
-  #   build_type: "Debug"
-
-  # Example: Build from Dockerfile (with content-based caching)
-  # - name: "custom-toolchain"
-  #   runner: "docker"
-  #   docker:
-  #     mode: "build"
-  #     image: "cpx-dev"          # tag for the built image
-  #     platform: "linux/arm64"
-  #     build:
-  #       context: "."
-  #       dockerfile: "Dockerfile"
-  #       args:
-  #         GCC_VER: "13"
-  #   build_type: "RelWithDebInfo"
-  #   cmake_options:
-  #     - "-DENABLE_TESTS=ON"
-
-  # Example: Native build (runs on host, CMake only)
-  # - name: "local-debug"
-  #   runner: "native"
-  #   build_type: "Debug"
-  #   cmake_options:
-  #     - "-DCMAKE_EXPORT_COMPILE_COMMANDS=ON"
-  #   env:
-  #     CC: "clang"
-  #     CXX: "clang++"
-
-# Global build configuration (used as defaults if not specified per-toolchain)
-build:
-  # CMake build type (Debug, Release, RelWithDebInfo, MinSizeRel)
-  # Note: Per-toolchain build_type overrides this
-  type: Release
-
-  # Optimization level (0, 1, 2, 3, s, fast)
-  optimization: 2
-
-  # Number of parallel jobs (0 = auto)
-  jobs: 0
-
-  # Additional CMake arguments (per-toolchain cmake_options overrides this)
-  cmake_args: []
-
-  # Additional build arguments (per-toolchain build_options overrides this)
-  build_args: []
-
-# Output directory for artifacts
-output: .bin/ci
+  - name: debug
+    runner: host
+    build_type: Debug
+  - name: release
+    runner: host
+    build_type: Release
+    optimization: "2"
+  # - name: linux-static
+  #   runner: linux-static
+  #   build_type: Release
+`
+}
+
+// GenerateDockerCpxCI generates a cpx-ci.yaml with a linux runner whose
+// image is built from dockerfile, next to the native toolchains of CMake
+// projects
+func GenerateDockerCpxCI(projectName, packageManager, dockerfile string) string {
+	native := packageManager != "bazel" && packageManager != "meson"
+	var sb strings.Builder
+	sb.WriteString(`# cpx-ci.yaml - toolchains built by 'cpx ci build'
+# 'cpx ci validate' checks this file; 'cpx ci schema' writes a JSON Schema for editors.
+
+runners:
+`)
+	if native {
+		sb.WriteString(`  # CMake and the compilers on this machine
+  - name: host
+    type: native
+`)
+	}
+	fmt.Fprintf(&sb, `  # Built from %s, and rebuilt when it changes
+  - name: linux
+    type: docker
+    image: cpx-%s-linux
+    build:
+      dockerfile: %s
+
+toolchains:
+`, dockerfile, vcpkgPortName(projectName), dockerfile)
+	if native {
+		sb.WriteString(`  - name: debug
+    runner: host
+    build_type: Debug
+  - name: release
+    runner: host
+    build_type: Release
+    optimization: "2"
+`)
+	}
+	sb.WriteString(`  - name: linux-release
+    runner: linux
+    build_type: Release
+`)
+	return sb.String()
+}
+
+// GenerateLinuxDockerfile generates the Dockerfile of a Linux build image
+// with the tools projects using packageManager build with
+func GenerateLinuxDockerfile(packageManager string) string {
+	var packages, tools string
+	switch packageManager {
+	case "bazel":
+		packages = "    build-essential \\\n    git \\\n    curl \\\n    python3"
+		tools = `
+# Bazelisk, as bazel, picks the Bazel version of .bazelversion
+RUN arch="$(dpkg --print-architecture)" && \
+    curl -fsSL -o /usr/local/bin/bazel "https://github.com/bazelbuild/bazelisk/releases/latest/download/bazelisk-linux-${arch}" && \
+    chmod +x /usr/local/bin/bazel
+`
+	case "meson":
+		packages = "    build-essential \\\n    meson \\\n    ninja-build \\\n    pkg-config \\\n    git \\\n    python3"
+	default:
+		packages = "    build-essential \\\n    cmake \\\n    ninja-build \\\n    ccache \\\n    pkg-config \\\n    git \\\n    curl \\\n    tar \\\n    zip \\\n    unzip \\\n    python3"
+		tools = `
+RUN git clone --depth 1 https://github.com/Microsoft/vcpkg.git /opt/vcpkg && \
+    /opt/vcpkg/bootstrap-vcpkg.sh -disableMetrics
+
+ENV VCPKG_ROOT=/opt/vcpkg
+ENV PATH="${VCPKG_ROOT}:${PATH}"
+`
+	}
+	return `# syntax=docker/dockerfile:1
+# Linux build image for 'cpx ci build'. cpx tags it with a hash of this file
+# and rebuilds it when the file changes.
+FROM ubuntu:24.04
+
+ENV DEBIAN_FRONTEND=noninteractive
+
+RUN --mount=type=cache,target=/var/cache/apt,sharing=locked \
+    --mount=type=cache,target=/var/lib/apt/lists,sharing=locked \
+    rm -f /etc/apt/apt.conf.d/docker-clean && \
+    apt-get update && apt-get install -y \
+` + packages + `
+` + tools + `
+WORKDIR /workspace
+
+CMD ["/bin/bash"]
 `
 }
 
@@ -989,6 +1200,7 @@ build/
 
 # Cache
 .cache/
+.cpx/
 
 # Compiled files
 *.o
@@ -1291,6 +1503,7 @@ build/
 
 # Cache
 .cache/
+.cpx/
 
 # Compiled files
 *.o
@@ -1,10 +1,15 @@
 package templates
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/ozacod/cpx/pkg/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGenerateModuleBazel(t *testing.T) {
@@ -728,8 +733,30 @@ func TestGenerateClangFormat(t *testing.T) {
 }
 
 func TestGenerateCpxCI(t *testing.T) {
-	result := GenerateCpxCI()
+	for _, packageManager := range []string{"vcpkg", "bazel", "meson"} {
+		t.Run(packageManager, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(GenerateCpxCI(packageManager)), 0644))
+
+			cfg, err := config.LoadToolchains(path)
+			require.NoError(t, err)
+			if packageManager == "vcpkg" {
+				assert.Len(t, cfg.Toolchains, 2)
+			} else {
+				assert.Empty(t, cfg.Toolchains)
+			}
+		})
+	}
+}
+
+func TestGenerateVcpkgJSON(t *testing.T) {
+	var manifest map[string]any
+	require.NoError(t, json.Unmarshal([]byte(GenerateVcpkgJSON("My_App", "0.1.0", []string{"fmt", "imgui[glfw-binding,opengl3-binding]"})), &manifest))
 
-	assert.Contains(t, result, "toolchains:")
-	assert.Contains(t, result, "build:")
+	assert.Equal(t, "my-app", manifest["name"])
+	assert.Equal(t, "0.1.0", manifest["version"])
+	assert.Equal(t, []any{
+		"fmt",
+		map[string]any{"name": "imgui", "features": []any{"glfw-binding", "opengl3-binding"}},
+	}, manifest["dependencies"])
 }
@@ -1,7 +1,7 @@
 package colors
 
-// ANSI color escape sequences
-const (
+// ANSI color escape sequences, empty once Disable is called
+var (
 	Reset  = "\033[0m"
 	Red    = "\033[31m"
 	Green  = "\033[32m"
@@ -10,3 +10,8 @@ const (
 	Gray   = "\033[90m"
 	Bold   = "\033[1m"
 )
+
+// Disable turns off colored output
+func Disable() {
+	Reset, Red, Green, Yellow, Cyan, Gray, Bold = "", "", "", "", "", "", ""
+}
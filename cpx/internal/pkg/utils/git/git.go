@@ -128,3 +128,93 @@ func FilterGitTrackedFiles(targets []string) ([]string, error) {
 
 	return trackedCppFiles, nil
 }
+
+// ChangedFiles returns the files under dir that differ from base: changes
+// committed since the merge base of base and HEAD, uncommitted changes and
+// untracked files. Paths are relative to dir.
+func ChangedFiles(dir, base string) ([]string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git not found")
+	}
+	if err := gitCommand(dir, "rev-parse", "--verify", "--quiet", base+"^{commit}").Run(); err != nil {
+		return nil, fmt.Errorf("base ref '%s' not found", base)
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, args := range [][]string{
+		{"diff", "--name-only", "--relative", base + "...HEAD"},
+		{"diff", "--name-only", "--relative", "HEAD"},
+		{"ls-files", "--others", "--exclude-standard"},
+	} {
+		output, err := gitCommand(dir, args...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list changed files: %w", err)
+		}
+		for _, file := range strings.Split(string(output), "\n") {
+			if file != "" && !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// HeadCommit returns the commit checked out in dir and whether the working
+// tree has uncommitted changes
+func HeadCommit(dir string) (commit string, dirty bool, err error) {
+	output, err := gitCommand(dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	status, err := gitCommand(dir, "status", "--porcelain").Output()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read working tree status: %w", err)
+	}
+	return strings.TrimSpace(string(output)), len(strings.TrimSpace(string(status))) > 0, nil
+}
+
+// ResolveCommit returns the commit a revision such as a branch, tag or
+// abbreviated hash names in dir's repository
+func ResolveCommit(dir, rev string) (string, error) {
+	output, err := gitCommand(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("commit '%s' not found", rev)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// TopLevel returns the root of the working tree dir is in, joined to dir so
+// that it keeps dir's symlinks and is absolute when dir is
+func TopLevel(dir string) (string, error) {
+	output, err := gitCommand(dir, "rev-parse", "--show-cdup").Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %s", dir)
+	}
+	return filepath.Join(dir, strings.TrimSpace(string(output))), nil
+}
+
+// RemoteURL returns the URL of dir's origin remote, or "" if it has none
+func RemoteURL(dir string) string {
+	output, err := gitCommand(dir, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// HeadTag returns the tag pointing at the commit checked out in dir
+func HeadTag(dir string) (string, error) {
+	output, err := gitCommand(dir, "describe", "--tags", "--exact-match", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("HEAD is not tagged")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -266,3 +267,94 @@ func TestGetGitTrackedCppFiles_EmptyRepo(t *testing.T) {
 	// Should return empty slice
 	assert.Empty(t, files)
 }
+
+func TestChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	run("init", "-q")
+	run("config", "user.email", "test@test.com")
+	run("config", "user.name", "Test User")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "app"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app", "main.cpp"), []byte("int main() {}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# demo"), 0644))
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	run("tag", "base")
+
+	files, err := ChangedFiles(dir, "base")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// Committed, uncommitted and untracked changes all count
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# demo\n"), 0644))
+	run("commit", "-q", "-am", "docs")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app", "main.cpp"), []byte("int main() { return 0; }"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app", "util.cpp"), []byte(""), 0644))
+	files, err = ChangedFiles(dir, "base")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"README.md", "app/main.cpp", "app/util.cpp"}, files)
+
+	// Paths are relative to dir
+	files, err = ChangedFiles(filepath.Join(dir, "app"), "base")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"main.cpp", "util.cpp"}, files)
+
+	_, err = ChangedFiles(dir, "missing")
+	assert.ErrorContains(t, err, "base ref 'missing' not found")
+}
+
+func TestHeadCommit(t *testing.T) {
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q")
+	run("config", "user.email", "test@test.com")
+	run("config", "user.name", "Test User")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.cpp"), []byte("int main() {}"), 0644))
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+
+	commit, dirty, err := HeadCommit(dir)
+	require.NoError(t, err)
+	assert.Equal(t, run("rev-parse", "HEAD"), commit)
+	assert.False(t, dirty)
+	assert.Empty(t, RemoteURL(dir))
+	_, err = HeadTag(dir)
+	assert.Error(t, err)
+	run("tag", "v1.0.0")
+	tag, err := HeadTag(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", tag)
+	resolved, err := ResolveCommit(dir, "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, commit, resolved)
+	_, err = ResolveCommit(dir, "missing")
+	assert.ErrorContains(t, err, "commit 'missing' not found")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.cpp"), []byte("int main() { return 0; }"), 0644))
+	run("remote", "add", "origin", "https://example.com/demo.git")
+	_, dirty, err = HeadCommit(dir)
+	require.NoError(t, err)
+	assert.True(t, dirty)
+	assert.Equal(t, "https://example.com/demo.git", RemoteURL(dir))
+
+	sub := filepath.Join(dir, "libs", "core")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	top, err := TopLevel(sub)
+	require.NoError(t, err)
+	assert.Equal(t, dir, top)
+	_, err = TopLevel(t.TempDir())
+	assert.Error(t, err)
+}
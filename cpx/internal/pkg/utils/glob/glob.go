@@ -0,0 +1,49 @@
+// Package glob matches project paths against globs with "**".
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether a slash-separated path relative to the project
+// matches a glob. "**" matches any number of directories and a trailing "/"
+// matches everything below a directory.
+func Match(pattern, file string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+// MatchAny reports whether a path matches any of patterns
+func MatchAny(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,17 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	assert.True(t, Match("docs/**", "docs/guide/intro.md"))
+	assert.True(t, Match("**/*.md", "README.md"))
+	assert.True(t, Match("**/*.md", "src/lib/NOTES.md"))
+	assert.True(t, Match("apps/server/", "apps/server/main.cpp"))
+	assert.True(t, Match("./src/*.cpp", "src/main.cpp"))
+	assert.False(t, Match("src/*.cpp", "src/net/socket.cpp"))
+	assert.False(t, Match("docs/**", "src/docs.cpp"))
+}
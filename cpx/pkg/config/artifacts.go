@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// artifactNameField matches a {{field}} placeholder of an artifact_name template
+var artifactNameField = regexp.MustCompile(`\{\{\s*(\w*)\s*\}\}`)
+
+// artifactExt matches the file extensions {{ext}} keeps apart, not version suffixes like ".1"
+var artifactExt = regexp.MustCompile(`^\.[A-Za-z]+$`)
+
+// artifactNameFields are the placeholders artifact_name templates may use
+var artifactNameFields = []string{"name", "version", "target", "ext"}
+
+// validateArtifactName checks a template only uses known placeholders
+func validateArtifactName(template string) error {
+	for _, m := range artifactNameField.FindAllStringSubmatch(template, -1) {
+		if !slices.Contains(artifactNameFields, m[1]) {
+			return fmt.Errorf("unknown placeholder %s (use %s)", m[0], "{{"+strings.Join(artifactNameFields, "}}, {{")+"}}")
+		}
+	}
+	if strings.ContainsAny(artifactNameField.ReplaceAllString(template, ""), `/\`) {
+		return fmt.Errorf("must be a file name, not a path")
+	}
+	return nil
+}
+
+// ArtifactFileName returns the name an artifact file gets from the
+// toolchain's `artifact_name` template: {{name}} is the file name without
+// its extension, {{ext}} the extension such as ".exe" (or empty), {{target}}
+// the toolchain name and {{version}} the project version. Without a template
+// the file keeps its name.
+func (t *Toolchain) ArtifactFileName(file, version string) string {
+	if t.ArtifactName == "" {
+		return file
+	}
+	ext := filepath.Ext(file)
+	if !artifactExt.MatchString(ext) {
+		ext = ""
+	}
+	fields := map[string]string{
+		"name":    strings.TrimSuffix(file, ext),
+		"version": version,
+		"target":  t.Name,
+		"ext":     ext,
+	}
+	return artifactNameField.ReplaceAllStringFunc(t.ArtifactName, func(placeholder string) string {
+		return fields[artifactNameField.FindStringSubmatch(placeholder)[1]]
+	})
+}
@@ -0,0 +1,116 @@
+package config
+
+import "fmt"
+
+// CacheConfig is the compiler cache shared by Docker toolchains (cpx-ci.yaml `cache:`)
+type CacheConfig struct {
+	Backend string      `yaml:"backend,omitempty" enum:"ccache,sccache"` // ccache (local) or sccache
+	S3      *CacheS3    `yaml:"s3,omitempty"`                            // sccache storage; local disk if none is set
+	GCS     *CacheGCS   `yaml:"gcs,omitempty"`
+	Redis   *CacheRedis `yaml:"redis,omitempty"`
+}
+
+// CacheS3 stores sccache results in an S3 (or S3-compatible) bucket.
+// Credentials come from AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY on the host.
+type CacheS3 struct {
+	Bucket    string `yaml:"bucket"`
+	Region    string `yaml:"region,omitempty"`
+	Endpoint  string `yaml:"endpoint,omitempty"` // for S3-compatible stores (MinIO, R2, ...)
+	KeyPrefix string `yaml:"key_prefix,omitempty"`
+}
+
+// CacheGCS stores sccache results in a Google Cloud Storage bucket.
+// The service account key is read from CPX_GCS_KEY on the host.
+type CacheGCS struct {
+	Bucket    string `yaml:"bucket"`
+	KeyPrefix string `yaml:"key_prefix,omitempty"`
+	ReadOnly  bool   `yaml:"read_only,omitempty"`
+}
+
+// CacheRedis stores sccache results in Redis.
+// The password is read from SCCACHE_REDIS_PASSWORD on the host.
+type CacheRedis struct {
+	Endpoint  string `yaml:"endpoint"` // e.g. redis://cache.internal:6379
+	KeyPrefix string `yaml:"key_prefix,omitempty"`
+}
+
+// Validate checks the backend and its storage settings
+func (c *CacheConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	storages := 0
+	for _, set := range []bool{c.S3 != nil, c.GCS != nil, c.Redis != nil} {
+		if set {
+			storages++
+		}
+	}
+
+	switch c.Backend {
+	case "ccache":
+		if storages > 0 {
+			return fmt.Errorf("s3, gcs and redis require backend 'sccache'")
+		}
+	case "sccache":
+		if storages > 1 {
+			return fmt.Errorf("only one of s3, gcs and redis can be set")
+		}
+		if c.S3 != nil && c.S3.Bucket == "" {
+			return fmt.Errorf("s3.bucket is required")
+		}
+		if c.GCS != nil && c.GCS.Bucket == "" {
+			return fmt.Errorf("gcs.bucket is required")
+		}
+		if c.Redis != nil && c.Redis.Endpoint == "" {
+			return fmt.Errorf("redis.endpoint is required")
+		}
+	default:
+		return fmt.Errorf("unknown backend '%s' (expected ccache or sccache)", c.Backend)
+	}
+	return nil
+}
+
+// VcpkgConfig configures vcpkg for Docker toolchains (cpx-ci.yaml `vcpkg:`)
+type VcpkgConfig struct {
+	// BinarySources are remote binary caches used in addition to the local one
+	BinarySources []VcpkgBinarySource `yaml:"binary_sources,omitempty"`
+}
+
+// VcpkgBinarySource is a remote vcpkg binary cache. Credentials are
+// forwarded from the host: ACTIONS_CACHE_URL/ACTIONS_RUNTIME_TOKEN for gha,
+// NUGET_USERNAME/NUGET_PASSWORD for nuget, and the Env variables, which
+// Header can reference as $NAME.
+type VcpkgBinarySource struct {
+	Type   string   `yaml:"type" enum:"gha,nuget,http"`                 // gha, nuget or http
+	URL    string   `yaml:"url,omitempty"`                              // nuget feed or http URL template ({name}, {version}, {sha})
+	Mode   string   `yaml:"mode,omitempty" enum:"read,write,readwrite"` // read, write or readwrite (default)
+	Header string   `yaml:"header,omitempty"`                           // http only, e.g. "Authorization: Bearer $CACHE_TOKEN"
+	Env    []string `yaml:"env,omitempty"`                              // extra host variables forwarded into the container
+}
+
+// Validate checks the binary sources
+func (c *VcpkgConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for i, s := range c.BinarySources {
+		switch s.Type {
+		case "gha":
+		case "nuget", "http":
+			if s.URL == "" {
+				return fmt.Errorf("binary_sources[%d]: url is required for %s", i, s.Type)
+			}
+		default:
+			return fmt.Errorf("binary_sources[%d]: unknown type '%s' (expected gha, nuget or http)", i, s.Type)
+		}
+		switch s.Mode {
+		case "", "read", "write", "readwrite":
+		default:
+			return fmt.Errorf("binary_sources[%d]: unknown mode '%s' (expected read, write or readwrite)", i, s.Mode)
+		}
+		if s.Header != "" && s.Type != "http" {
+			return fmt.Errorf("binary_sources[%d]: header is only supported for http", i)
+		}
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package config_test
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ozacod/cpx/pkg/config"
 	"github.com/stretchr/testify/assert"
@@ -121,3 +123,653 @@ func TestSaveGlobalConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveToolchains(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "cpx-ci.yaml")
+	content := `runners:
+  - name: gcc
+    type: docker
+    image: gcc:13
+templates:
+  - name: base
+    runner: gcc
+    optimization: "2"
+    ccache: true
+    resources: { cpus: 4, memory: 8g }
+    services:
+      - { name: db, image: "postgres:16", env: { POSTGRES_PASSWORD: test } }
+      - { name: cache, image: "redis:7" }
+    cmake_options: ["-DBASE=ON"]
+    env:
+      CCACHE: "1"
+      LEVEL: base
+toolchains:
+  - name: linux-release
+    extends: base
+    jobs: 8
+  - name: linux-debug
+    extends: linux-release
+    build_type: Debug
+    resources: { memory: 12g }
+    services:
+      - { name: db, image: "postgres:17" }
+    cmake_options: ["-DDEBUG=ON"]
+    env:
+      LEVEL: debug
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	cfg, err := config.LoadToolchains(path)
+	require.NoError(t, err)
+
+	resolved, err := cfg.ResolveToolchains()
+	require.NoError(t, err)
+	require.Len(t, resolved, 2)
+
+	release := resolved[0]
+	assert.Equal(t, "linux-release", release.Name)
+	assert.Equal(t, "gcc", release.Runner)
+	assert.Equal(t, "Release", release.BuildType)
+	assert.Equal(t, "2", release.Optimization)
+	assert.Equal(t, 8, release.Jobs)
+	assert.Equal(t, []string{"-DBASE=ON"}, release.CMakeOptions)
+
+	debug := resolved[1]
+	assert.Equal(t, "Debug", debug.BuildType)
+	assert.Equal(t, 8, debug.Jobs)
+	assert.Equal(t, []string{"-DBASE=ON", "-DDEBUG=ON"}, debug.CMakeOptions)
+	assert.Equal(t, map[string]string{"CCACHE": "1", "LEVEL": "debug"}, debug.Env)
+	assert.True(t, debug.CCache)
+	assert.Equal(t, &config.Resources{CPUs: 4, Memory: "12g"}, debug.Resources)
+	assert.Equal(t, &config.Resources{CPUs: 4, Memory: "8g"}, release.Resources)
+	assert.Equal(t, []config.Service{{Name: "db", Image: "postgres:17"}, {Name: "cache", Image: "redis:7"}}, debug.Services)
+	assert.Equal(t, "postgres:16", release.Services[0].Image)
+
+	// The raw config is left untouched so saving does not flatten it
+	assert.Equal(t, "", cfg.Toolchains[0].BuildType)
+	assert.Nil(t, cfg.Toolchains[0].CMakeOptions)
+
+	assert.Equal(t, []string{"linux-debug", "linux-release", "base"}, cfg.InheritanceChain("linux-debug"))
+	assert.Nil(t, cfg.InheritanceChain("missing"))
+}
+
+func TestLoadToolchainsInvalidServices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+	for content, msg := range map[string]string{
+		"toolchains:\n  - name: a\n    services: [{ name: db }]\n":                                     "image is required",
+		"toolchains:\n  - name: a\n    services: [{ name: db, image: pg }, { name: db, image: pg }]\n": "defined twice",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := config.LoadToolchains(path)
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func TestResolveToolchainsErrors(t *testing.T) {
+	unknown := &config.ToolchainConfig{Toolchains: []config.Toolchain{
+		{Name: "a", Extends: "missing"},
+	}}
+	_, err := unknown.ResolveToolchains()
+	assert.ErrorContains(t, err, "unknown template or toolchain 'missing'")
+
+	cycle := &config.ToolchainConfig{Toolchains: []config.Toolchain{
+		{Name: "a", Extends: "b"},
+		{Name: "b", Extends: "a"},
+	}}
+	_, err = cycle.ResolveToolchains()
+	assert.ErrorContains(t, err, "inheritance cycle")
+}
+
+func TestResourcesValidate(t *testing.T) {
+	var none *config.Resources
+	assert.NoError(t, none.Validate())
+	assert.NoError(t, (&config.Resources{CPUs: 1.5, Memory: "512m"}).Validate())
+	assert.ErrorContains(t, (&config.Resources{CPUs: -1}).Validate(), "cpus")
+	assert.ErrorContains(t, (&config.Resources{Memory: "4 GB"}).Validate(), "memory")
+}
+
+func TestCacheConfigValidate(t *testing.T) {
+	var none *config.CacheConfig
+	assert.NoError(t, none.Validate())
+	assert.NoError(t, (&config.CacheConfig{Backend: "ccache"}).Validate())
+	assert.NoError(t, (&config.CacheConfig{Backend: "sccache"}).Validate())
+	assert.NoError(t, (&config.CacheConfig{Backend: "sccache", S3: &config.CacheS3{Bucket: "ci"}}).Validate())
+
+	assert.ErrorContains(t, (&config.CacheConfig{Backend: "bazel"}).Validate(), "unknown backend")
+	assert.ErrorContains(t, (&config.CacheConfig{Backend: "ccache", S3: &config.CacheS3{Bucket: "ci"}}).Validate(), "sccache")
+	assert.ErrorContains(t, (&config.CacheConfig{Backend: "sccache", S3: &config.CacheS3{}}).Validate(), "s3.bucket")
+	assert.ErrorContains(t, (&config.CacheConfig{Backend: "sccache",
+		S3: &config.CacheS3{Bucket: "ci"}, Redis: &config.CacheRedis{Endpoint: "redis://cache"}}).Validate(), "only one")
+}
+
+func TestVcpkgConfigValidate(t *testing.T) {
+	valid := &config.VcpkgConfig{BinarySources: []config.VcpkgBinarySource{
+		{Type: "gha"},
+		{Type: "nuget", URL: "https://nuget.pkg.github.com/acme/index.json", Mode: "read"},
+		{Type: "http", URL: "https://cache.example.com/{sha}.zip", Header: "Authorization: Bearer $TOKEN", Env: []string{"TOKEN"}},
+	}}
+	assert.NoError(t, valid.Validate())
+
+	assert.ErrorContains(t, (&config.VcpkgConfig{BinarySources: []config.VcpkgBinarySource{{Type: "s3"}}}).Validate(), "unknown type")
+	assert.ErrorContains(t, (&config.VcpkgConfig{BinarySources: []config.VcpkgBinarySource{{Type: "nuget"}}}).Validate(), "url is required")
+	assert.ErrorContains(t, (&config.VcpkgConfig{BinarySources: []config.VcpkgBinarySource{{Type: "gha", Mode: "rw"}}}).Validate(), "unknown mode")
+	assert.ErrorContains(t, (&config.VcpkgConfig{BinarySources: []config.VcpkgBinarySource{{Type: "gha", Header: "X: 1"}}}).Validate(), "only supported for http")
+}
+
+func TestSortByDependencies(t *testing.T) {
+	all := []config.Toolchain{
+		{Name: "package", DependsOn: []string{"linux", "windows"}},
+		{Name: "linux"},
+		{Name: "windows", DependsOn: []string{"linux"}},
+	}
+	sorted, err := config.SortByDependencies(all, all)
+	require.NoError(t, err)
+	var names []string
+	for _, tc := range sorted {
+		names = append(names, tc.Name)
+	}
+	assert.Equal(t, []string{"linux", "windows", "package"}, names)
+
+	// Dependencies that are not built in this run are ignored
+	sorted, err = config.SortByDependencies(all[:1], all)
+	require.NoError(t, err)
+	assert.Len(t, sorted, 1)
+
+	withDeps := config.WithDependencies(all[:1], all)
+	assert.Len(t, withDeps, 3)
+	assert.Equal(t, "package", withDeps[2].Name)
+
+	_, err = config.SortByDependencies([]config.Toolchain{{Name: "a", DependsOn: []string{"missing"}}}, all)
+	assert.ErrorContains(t, err, "unknown toolchain 'missing'")
+
+	cycle := []config.Toolchain{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"a"}},
+	}
+	_, err = config.SortByDependencies(cycle, cycle)
+	assert.ErrorContains(t, err, "dependency cycle: a -> b -> a")
+}
+
+func TestArtifactFileName(t *testing.T) {
+	tc := config.Toolchain{Name: "linux-x64", ArtifactName: "{{name}}-{{version}}-{{target}}{{ext}}"}
+	assert.Equal(t, "app-1.2.0-linux-x64", tc.ArtifactFileName("app", "1.2.0"))
+	assert.Equal(t, "app-1.2.0-linux-x64.exe", tc.ArtifactFileName("app.exe", "1.2.0"))
+	assert.Equal(t, "libfoo.so.1-1.2.0-linux-x64", tc.ArtifactFileName("libfoo.so.1", "1.2.0"))
+	assert.Equal(t, "app", (&config.Toolchain{Name: "plain"}).ArtifactFileName("app", "1.2.0"))
+
+	path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+	for content, msg := range map[string]string{
+		"toolchains:\n  - name: a\n    artifact_name: \"{{name}}-{{arch}}\"\n": "unknown placeholder {{arch}}",
+		"toolchains:\n  - name: a\n    artifact_name: \"dist/{{name}}\"\n":     "not a path",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := config.LoadToolchains(path)
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func TestSignConfigValidate(t *testing.T) {
+	var none *config.SignConfig
+	assert.NoError(t, none.Validate())
+	assert.NoError(t, (&config.SignConfig{Method: "cosign", Key: "cosign.key"}).Validate())
+	assert.NoError(t, (&config.SignConfig{Method: "gpg", PassphraseEnv: "GPG_PASSPHRASE"}).Validate())
+
+	assert.ErrorContains(t, (&config.SignConfig{}).Validate(), "method is required")
+	assert.ErrorContains(t, (&config.SignConfig{Method: "minisign"}).Validate(), "unknown method")
+	assert.ErrorContains(t, (&config.SignConfig{Method: "cosign", PassphraseEnv: "X"}).Validate(), "only supported for gpg")
+}
+
+func TestUploadConfigValidate(t *testing.T) {
+	var none *config.UploadConfig
+	assert.NoError(t, none.Validate())
+	assert.NoError(t, (&config.UploadConfig{URL: "s3://releases/app/{{version}}", Endpoint: "https://minio.example.com"}).Validate())
+	assert.NoError(t, (&config.UploadConfig{URL: "gs://releases/app/{{target}}"}).Validate())
+	assert.NoError(t, (&config.UploadConfig{URL: "https://files.example.com/app", TokenEnv: "UPLOAD_TOKEN"}).Validate())
+
+	assert.ErrorContains(t, (&config.UploadConfig{}).Validate(), "url is required")
+	assert.ErrorContains(t, (&config.UploadConfig{URL: "ftp://files.example.com"}).Validate(), "unsupported url")
+	assert.ErrorContains(t, (&config.UploadConfig{URL: "s3://"}).Validate(), "invalid url")
+	assert.ErrorContains(t, (&config.UploadConfig{URL: "gs://releases", Endpoint: "https://x"}).Validate(), "only supported for s3")
+	assert.ErrorContains(t, (&config.UploadConfig{URL: "s3://releases", TokenEnv: "X"}).Validate(), "only supported for http")
+}
+
+func TestToolchainAttempts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+	content := `templates:
+  - name: emulated
+    timeout: 45m
+    retries: 2
+toolchains:
+  - name: linux-arm64
+    extends: emulated
+  - name: linux-riscv64
+    extends: emulated
+    timeout: 1h30m
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	cfg, err := config.LoadToolchains(path)
+	require.NoError(t, err)
+	toolchains, err := cfg.ResolveToolchains()
+	require.NoError(t, err)
+	assert.Equal(t, 45*time.Minute, toolchains[0].BuildTimeout())
+	assert.Equal(t, 2, toolchains[0].Retries)
+	assert.Equal(t, 90*time.Minute, toolchains[1].BuildTimeout())
+	assert.Equal(t, 2, toolchains[1].Retries)
+	assert.Zero(t, (&config.Toolchain{}).BuildTimeout())
+
+	for content, msg := range map[string]string{
+		"toolchains:\n  - name: a\n    timeout: 30\n":  "positive duration",
+		"toolchains:\n  - name: a\n    timeout: -5m\n": "positive duration",
+		"toolchains:\n  - name: a\n    retries: -1\n":  "must not be negative",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := config.LoadToolchains(path)
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func TestLoadToolchainsSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+	content := `runners:
+  - name: gcc
+    type: dokcer
+    imge: gcc:13
+toolchains:
+  - name: linux
+    runner: gcc
+    build_type: release
+    jobs: many
+  - runner: gcc
+paralel: 2
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	_, err := config.LoadToolchains(path)
+	var errs config.SchemaErrors
+	require.ErrorAs(t, err, &errs)
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	assert.Equal(t, []string{
+		path + ":3:11: runners[gcc].type: 'dokcer' is not one of docker, ssh, plugin, native, local",
+		path + ":4:5: unknown field 'imge' in runners[gcc] (did you mean 'image'?)",
+		path + ":8:17: toolchains[linux].build_type: 'release' is not one of Debug, Release, RelWithDebInfo, MinSizeRel",
+		path + ":9:11: toolchains[linux].jobs must be an integer, not 'many'",
+		path + ":10:5: toolchains[1].name is required",
+		path + ":11:1: unknown field 'paralel' (did you mean 'parallel'?)",
+	}, got)
+
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	cfg, err := config.LoadToolchains(path)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Toolchains)
+}
+
+func TestValidateToolchains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+	content := `runners:
+  - name: gcc
+    type: docker
+  - name: board
+    type: ssh
+    host: pi.local
+  - name: k8s
+    type: plugin
+toolchains:
+  - name: linux
+    runner: clang
+  - name: arm
+    runner: board
+    depends_on: [linux, windows]
+  - name: linux
+    extends: base
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	_, err := config.ValidateToolchains(path)
+	var errs config.SchemaErrors
+	require.ErrorAs(t, err, &errs)
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	assert.Equal(t, []string{
+		path + ":2:5: docker runner 'gcc' needs an image, a build or a target",
+		path + ":7:5: plugin runner 'k8s' needs a plugin",
+		path + ":11:13: toolchain 'linux' uses unknown runner 'clang'",
+		path + ":14:25: toolchain 'arm' depends on unknown toolchain 'windows'",
+		path + ":15:5: 'linux' is defined twice in templates and toolchains",
+		path + ":15:5: toolchain 'linux' extends unknown template or toolchain 'base'",
+	}, got)
+
+	valid := "runners:\n  - name: gcc\n    type: docker\n    image: gcc:13\ntoolchains:\n  - name: linux\n    runner: gcc\n"
+	require.NoError(t, os.WriteFile(path, []byte(valid), 0644))
+	cfg, err := config.ValidateToolchains(path)
+	require.NoError(t, err)
+	assert.Len(t, cfg.Toolchains, 1)
+}
+
+func TestLoadToolchainsInclude(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cpx-ci.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ci"), 0755))
+	files := map[string]string{
+		"cpx-ci.yaml":     "include: [ci/*.yaml]\ntoolchains:\n  - name: linux-debug\n    extends: linux-base\n    build_type: Debug\n",
+		"ci/runners.yaml": "runners:\n  - name: gcc\n    type: docker\n    image: gcc:13\n",
+		// including the main file again is a no-op
+		"ci/templates.yaml": "include: [../cpx-ci.yaml]\ntemplates:\n  - name: linux-base\n    runner: gcc\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	cfg, err := config.ValidateToolchains(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Runners, 1)
+	require.Len(t, cfg.Templates, 1)
+	tc, err := cfg.ResolveToolchain("linux-debug")
+	require.NoError(t, err)
+	assert.Equal(t, "gcc", tc.Runner)
+
+	// saving keeps included entries in their files
+	cfg.Toolchains = append(cfg.Toolchains, config.Toolchain{Name: "linux-release", Extends: "linux-base"})
+	require.NoError(t, config.SaveToolchains(cfg, path))
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(saved), "linux-release")
+	assert.NotContains(t, string(saved), "gcc")
+	cfg, err = config.LoadToolchains(path)
+	require.NoError(t, err)
+	assert.Len(t, cfg.Runners, 1)
+	assert.Len(t, cfg.Toolchains, 2)
+
+	extra := filepath.Join(dir, "ci", "extra.yaml")
+	require.NoError(t, os.WriteFile(extra, []byte("runners:\n  - name: gcc\n    type: ssh\n"), 0644))
+	_, err = config.LoadToolchains(path)
+	var errs config.SchemaErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, filepath.Join(dir, "ci", "runners.yaml")+":2:5: runners[gcc] is already defined in "+extra, errs[0].Error())
+
+	require.NoError(t, os.WriteFile(extra, []byte("runners:\n  - name: clang\n    type: ssh\n"), 0644))
+	_, err = config.ValidateToolchains(path)
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, extra+":2:5: ssh runner 'clang' needs a host", errs[0].Error())
+
+	// included files only add runners, templates and toolchains
+	require.NoError(t, os.WriteFile(extra, []byte("cache:\n  backend: local\n"), 0644))
+	_, err = config.LoadToolchains(path)
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, extra+":1:1: unknown field 'cache'", errs[0].Error())
+
+	require.NoError(t, os.WriteFile(path, []byte("include: [missing.yaml]\n"), 0644))
+	_, err = config.LoadToolchains(path)
+	assert.ErrorContains(t, err, "matches no files")
+}
+
+func TestApplyProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+	content := `toolchains:
+  - name: linux
+    cmake_options: ["-DWARNINGS=ON"]
+    env: { CC: gcc, ASAN_OPTIONS: "" }
+  - name: windows
+    build_type: MinSizeRel
+profiles:
+  - name: asan
+    build_type: Debug
+    cmake_options: ["-DCMAKE_CXX_FLAGS=-fsanitize=address"]
+    env: { ASAN_OPTIONS: detect_leaks=1 }
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	cfg, err := config.LoadToolchains(path)
+	require.NoError(t, err)
+	toolchains, err := cfg.ResolveToolchains()
+	require.NoError(t, err)
+
+	applied, err := cfg.ApplyProfile(toolchains, "asan")
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	assert.Equal(t, "linux", applied[0].Name)
+	assert.Equal(t, "Debug", applied[0].BuildType)
+	assert.Equal(t, []string{"-DWARNINGS=ON", "-DCMAKE_CXX_FLAGS=-fsanitize=address"}, applied[0].CMakeOptions)
+	assert.Equal(t, map[string]string{"CC": "gcc", "ASAN_OPTIONS": "detect_leaks=1"}, applied[0].Env)
+	assert.Equal(t, "Debug", applied[1].BuildType)
+	assert.Equal(t, "Release", toolchains[0].BuildType, "resolved toolchains are left alone")
+
+	_, err = cfg.ApplyProfile(toolchains, "tsan")
+	assert.EqualError(t, err, "profile 'tsan' not found in cpx-ci.yaml (available: asan)")
+}
+
+func TestJSONSchema(t *testing.T) {
+	data, err := config.JSONSchema()
+	require.NoError(t, err)
+	var schema struct {
+		Properties  map[string]map[string]any `json:"properties"`
+		Definitions map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+			Required   []string                  `json:"required"`
+		} `json:"definitions"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+
+	assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"$ref": "#/definitions/Toolchain"}}, schema.Properties["toolchains"])
+	assert.Equal(t, map[string]any{"type": "integer"}, schema.Properties["parallel"])
+	runner := schema.Definitions["Runner"]
+	assert.Equal(t, []string{"name"}, runner.Required)
+	assert.Equal(t, []any{"docker", "ssh", "plugin", "native", "local"}, runner.Properties["type"]["enum"])
+	formats := schema.Definitions["PackageConfig"].Properties["formats"]["items"].(map[string]any)
+	assert.Equal(t, []any{"tar.gz", "zip", "deb", "rpm"}, formats["enum"])
+	assert.Equal(t, []string{"name", "image"}, schema.Definitions["Service"].Required)
+	assert.Contains(t, schema.Definitions["Toolchain"].Properties, "depends_on")
+}
+
+func TestLoadWithOrigins(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, config.SaveGlobal(&config.GlobalConfig{VcpkgRoot: "/opt/vcpkg", Jobs: 4, Color: "never"}))
+	globalPath, err := config.GetConfigPath()
+	require.NoError(t, err)
+
+	project := t.TempDir()
+	projectPath := filepath.Join(project, config.ProjectConfigFile)
+	require.NoError(t, os.WriteFile(projectPath, []byte("vcpkg_root: vendor/vcpkg\nbuild_type: release\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "src"), 0755))
+	t.Chdir(filepath.Join(project, "src"))
+
+	cfg, origins, err := config.LoadWithOrigins()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(project, "vendor", "vcpkg"), cfg.VcpkgRoot)
+	assert.Equal(t, "release", cfg.BuildType)
+	assert.Equal(t, 4, cfg.Jobs)
+	assert.Equal(t, "never", cfg.Color)
+	assert.Equal(t, projectPath, origins["vcpkg_root"])
+	assert.Equal(t, projectPath, origins["build_type"])
+	assert.Equal(t, globalPath, origins["jobs"])
+	assert.Empty(t, origins["bcr_root"])
+
+	require.NoError(t, os.WriteFile(projectPath, []byte("build_typ: release\n"), 0644))
+	_, err = config.Load()
+	assert.EqualError(t, err, projectPath+":1:1: unknown field 'build_typ' (did you mean 'build_type'?)")
+}
+
+func TestDeprecatedAnalyzerSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	project := t.TempDir()
+	content := "analyze_skip: [flawfinder, Include-What-You-Use]\nsemgrep_rules: [cpx, p/c]\n"
+	require.NoError(t, os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte(content), 0644))
+	t.Chdir(project)
+
+	// Still loaded by strict .cpx.yaml loading, and settable as lists
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	value, err := cfg.Get("semgrep_rules")
+	require.NoError(t, err)
+	assert.Equal(t, "cpx,p/c", value)
+
+	// Folded into the quality config, whose own rulesets win
+	quality := &config.QualityConfig{Disable: []string{"cpplint"}}
+	assert.Equal(t, []string{"analyze_skip", "semgrep_rules"}, quality.MigrateDeprecated(cfg))
+	assert.Equal(t, []string{"cpplint", "flawfinder", "iwyu"}, quality.Disable)
+	assert.Equal(t, []string{"cpx", "p/c"}, quality.SemgrepRules)
+	quality = &config.QualityConfig{SemgrepRules: []string{".semgrep/"}}
+	quality.MigrateDeprecated(cfg)
+	assert.Equal(t, []string{".semgrep/"}, quality.SemgrepRules)
+
+	require.NoError(t, cfg.Set("analyze_skip", "cpplint, iwyu"))
+	assert.Equal(t, []string{"cpplint", "iwyu"}, cfg.AnalyzeSkip)
+	assert.Empty(t, (&config.QualityConfig{}).MigrateDeprecated(&config.GlobalConfig{}))
+
+	// Style checkers become regex tools, unless a tool has their name
+	content = `analyzers:
+  - name: vera++
+    command: vera++ --show-rule
+    pattern: '^(?P<file>[^:]+):(?P<line>\d+): (?P<message>.*)$'
+  - name: lint
+    command: old-lint
+    pattern: '(?P<file>.*):(?P<line>\d+):(?P<message>.*)'
+`
+	require.NoError(t, os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte(content), 0644))
+	cfg, err = config.Load()
+	require.NoError(t, err)
+	value, err = cfg.Get("analyzers")
+	require.NoError(t, err)
+	assert.Equal(t, "vera++,lint", value)
+	assert.EqualError(t, cfg.Set("analyzers", "x"), "analyzers is a list of mappings; set it in .cpx.yaml")
+	quality = &config.QualityConfig{Tools: []config.QualityTool{{Name: "lint", Command: "new-lint", Format: "sarif"}}}
+	assert.Equal(t, []string{"analyzers"}, quality.MigrateDeprecated(cfg))
+	require.Len(t, quality.Tools, 2)
+	assert.Equal(t, "new-lint", quality.Tools[0].Command)
+	assert.Equal(t, config.QualityTool{Name: "vera++", Command: "vera++ --show-rule", Format: "regex", Pattern: `^(?P<file>[^:]+):(?P<line>\d+): (?P<message>.*)$`}, quality.Tools[1])
+	require.NoError(t, quality.Validate())
+
+	require.NoError(t, os.WriteFile(filepath.Join(project, config.ProjectConfigFile), []byte("analyzers:\n  - name: x\n"), 0644))
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "analyzers[x].command is required")
+}
+
+func TestLoadQuality(t *testing.T) {
+	project := t.TempDir()
+	path := filepath.Join(project, config.QualityConfigFile)
+	content := `disable: [flawfinder]
+args:
+  cppcheck: [--std=c++20]
+exclude: [src/generated/]
+severity:
+  modernize-*: style
+metrics:
+  complexity: 10
+  function_lines: 60
+coverage:
+  min_lines: 80
+  min_branches: 62.5
+reports:
+  - format: junit
+    output: reports/junit.xml
+tools:
+  - name: vera++
+    command: vera++ --show-rule
+    pattern: '^(?P<file>[^:]+):(?P<line>\d+): (?P<message>.*)$'
+    severity: style
+  - name: house
+    command: ./check --sarif
+    format: sarif
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "src"), 0755))
+	t.Chdir(filepath.Join(project, "src"))
+	assert.Equal(t, path, config.FindQualityConfig())
+
+	cfg, err := config.LoadQuality(path)
+	require.NoError(t, err)
+	require.Len(t, cfg.Tools, 2)
+	assert.Equal(t, config.QualityTool{Name: "house", Command: "./check --sarif", Format: "sarif"}, cfg.Tools[1])
+	assert.Equal(t, []string{"flawfinder"}, cfg.Disable)
+	assert.Equal(t, map[string][]string{"cppcheck": {"--std=c++20"}}, cfg.Args)
+	assert.Equal(t, map[string]string{"modernize-*": "style"}, cfg.Severity)
+	assert.Equal(t, config.QualityMetrics{Complexity: 10, FunctionLines: 60}, cfg.Metrics)
+	assert.Equal(t, config.QualityCoverage{MinLines: 80, MinBranches: 62.5}, cfg.Coverage)
+	assert.Equal(t, []config.QualityReport{{Format: "junit", Output: "reports/junit.xml"}}, cfg.Reports)
+
+	cfg, err = config.LoadQuality("")
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Tools)
+
+	require.NoError(t, os.WriteFile(path, []byte("tools:\n  - name: x\n    command: x\n    format: xml\n"), 0644))
+	_, err = config.LoadQuality(path)
+	assert.ErrorContains(t, err, "tools[x].format: 'xml' is not one of regex, sarif, jsonl")
+
+	require.NoError(t, os.WriteFile(path, []byte("severity:\n  misc-*: minor\n"), 0644))
+	_, err = config.LoadQuality(path)
+	assert.EqualError(t, err, "invalid "+path+": severity of 'misc-*' must be one of error, warning, style, info, not 'minor'")
+
+	require.NoError(t, os.WriteFile(path, []byte("tools:\n  - name: x\n    command: x\n"), 0644))
+	_, err = config.LoadQuality(path)
+	assert.EqualError(t, err, "invalid "+path+": tools[x]: the regex format needs a pattern")
+
+	require.NoError(t, os.WriteFile(path, []byte("coverage:\n  min_functions: 110\n"), 0644))
+	_, err = config.LoadQuality(path)
+	assert.EqualError(t, err, "invalid "+path+": coverage.min_functions must be a percentage from 0 to 100, not 110")
+}
+
+func TestToolchainSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+	content := `templates:
+  - name: base
+    secrets: [API_TOKEN]
+toolchains:
+  - name: linux
+    extends: base
+    env_passthrough: [CONAN_LOGIN]
+    secrets: [SIGNING_KEY, API_TOKEN]
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	cfg, err := config.LoadToolchains(path)
+	require.NoError(t, err)
+	tc, err := cfg.ResolveToolchain("linux")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"API_TOKEN", "SIGNING_KEY"}, tc.Secrets)
+	assert.Equal(t, []string{"CONAN_LOGIN"}, tc.EnvPassthrough)
+
+	require.NoError(t, os.WriteFile(path, []byte("toolchains:\n  - name: linux\n    secrets: [\"$TOKEN\"]\n"), 0644))
+	_, err = config.LoadToolchains(path)
+	assert.EqualError(t, err, "invalid toolchain 'linux' in cpx-ci.yaml: '$TOKEN' is not an environment variable name")
+}
+
+func TestToolchainHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cpx-ci.yaml")
+	content := `hooks:
+  on_failure:
+    - run: ./scripts/notify.sh
+templates:
+  - name: base
+    hooks:
+      pre_build:
+        - run: ./scripts/codegen.sh
+toolchains:
+  - name: linux
+    extends: base
+    hooks:
+      pre_build:
+        - run: make assets
+      post_build:
+        - run: strip /output/linux/app
+          in: container
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	cfg, err := config.LoadToolchains(path)
+	require.NoError(t, err)
+	tc, err := cfg.ResolveToolchain("linux")
+	require.NoError(t, err)
+	hooks := config.MergeHooks(cfg.Hooks, tc.Hooks)
+	assert.Equal(t, []config.Hook{{Run: "./scripts/codegen.sh"}, {Run: "make assets"}}, hooks.PreBuild)
+	assert.True(t, hooks.PostBuild[0].InContainer())
+	assert.Equal(t, []config.Hook{{Run: "./scripts/notify.sh"}}, hooks.OnFailure)
+
+	require.NoError(t, os.WriteFile(path, []byte("toolchains:\n  - name: linux\n    hooks:\n      post_build:\n        - run: \"\"\n"), 0644))
+	_, err = config.LoadToolchains(path)
+	assert.EqualError(t, err, "invalid hooks for 'linux' in cpx-ci.yaml: post_build[0]: run must not be empty")
+
+	require.NoError(t, os.WriteFile(path, []byte("hooks:\n  pre_build:\n    - run: make\n      in: vm\n"), 0644))
+	_, err = config.LoadToolchains(path)
+	assert.ErrorContains(t, err, "vm")
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SortByDependencies orders toolchains so each comes after the toolchains it
+// `depends_on`, keeping the configured order otherwise. Dependencies outside
+// toolchains are not built in this run and are ignored; unknown names and
+// cycles are errors. all holds every toolchain, to tell the two apart.
+func SortByDependencies(toolchains, all []Toolchain) ([]Toolchain, error) {
+	for _, t := range toolchains {
+		for _, dep := range t.DependsOn {
+			if !slices.ContainsFunc(all, func(o Toolchain) bool { return o.Name == dep }) {
+				return nil, fmt.Errorf("toolchain '%s' depends on unknown toolchain '%s'", t.Name, dep)
+			}
+		}
+	}
+
+	sorted := make([]Toolchain, 0, len(toolchains))
+	state := make(map[string]int, len(toolchains)) // 1: visiting, 2: sorted
+	var visit func(t Toolchain, path []string) error
+	visit = func(t Toolchain, path []string) error {
+		switch state[t.Name] {
+		case 1:
+			start := slices.Index(path, t.Name)
+			return fmt.Errorf("toolchains have a dependency cycle: %s -> %s", strings.Join(path[start:], " -> "), t.Name)
+		case 2:
+			return nil
+		}
+		state[t.Name] = 1
+		for _, dep := range t.DependsOn {
+			if i := slices.IndexFunc(toolchains, func(o Toolchain) bool { return o.Name == dep }); i >= 0 {
+				if err := visit(toolchains[i], append(path, t.Name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[t.Name] = 2
+		sorted = append(sorted, t)
+		return nil
+	}
+	for _, t := range toolchains {
+		if err := visit(t, nil); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// WithDependencies returns toolchains and, before them, every toolchain of all
+// they depend on, directly or not. Cycles are left to SortByDependencies.
+func WithDependencies(toolchains, all []Toolchain) []Toolchain {
+	var out []Toolchain
+	seen := make(map[string]bool)
+	var add func(t Toolchain)
+	add = func(t Toolchain) {
+		if seen[t.Name] {
+			return
+		}
+		seen[t.Name] = true
+		for _, dep := range t.DependsOn {
+			if i := slices.IndexFunc(all, func(o Toolchain) bool { return o.Name == dep }); i >= 0 {
+				add(all[i])
+			}
+		}
+		out = append(out, t)
+	}
+	for _, t := range toolchains {
+		add(t)
+	}
+	return out
+}
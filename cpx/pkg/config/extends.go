@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ResolveToolchains returns every toolchain with `extends` applied.
+//
+// A toolchain may extend an entry of `templates:` or another toolchain
+// (templates are looked up first). Merge semantics, child over parent:
+//   - name and active are never inherited
+//   - runner, build_type, optimization, jobs, paths, depends_on and artifact_name: the child's value wins when set
+//   - cmake_options, build_options and each phase of hooks: parent values first, then the child's
+//   - tags, env_passthrough and secrets: the parent's and the child's
+//   - env: merged key by key, the child's value wins
+//   - resources: cpus and memory each inherited unless the child sets them
+//   - services: the parent's, with those the child defines by the same name replaced
+//   - hardening: enabled if enabled anywhere in the chain
+func (c *ToolchainConfig) ResolveToolchains() ([]Toolchain, error) {
+	resolved := make([]Toolchain, 0, len(c.Toolchains))
+	for _, t := range c.Toolchains {
+		r, err := c.resolve(t, nil)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved, nil
+}
+
+// ResolveToolchain returns the named toolchain with `extends` applied,
+// or nil if no toolchain has that name.
+func (c *ToolchainConfig) ResolveToolchain(name string) (*Toolchain, error) {
+	t := c.FindToolchain(name)
+	if t == nil {
+		return nil, nil
+	}
+	r, err := c.resolve(*t, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// InheritanceChain returns the names a toolchain inherits from, starting with
+// the toolchain itself and ending with the root template or toolchain.
+// A missing parent or a cycle ends the chain.
+func (c *ToolchainConfig) InheritanceChain(name string) []string {
+	t := c.FindToolchain(name)
+	if t == nil {
+		return nil
+	}
+	chain := []string{t.Name}
+	for t.Extends != "" && !slices.Contains(chain, t.Extends) {
+		chain = append(chain, t.Extends)
+		if t = c.findBase(t.Extends); t == nil {
+			break
+		}
+	}
+	return chain
+}
+
+// findBase looks up an `extends` parent in templates, then toolchains
+func (c *ToolchainConfig) findBase(name string) *Toolchain {
+	for i := range c.Templates {
+		if c.Templates[i].Name == name {
+			return &c.Templates[i]
+		}
+	}
+	return c.FindToolchain(name)
+}
+
+func (c *ToolchainConfig) resolve(t Toolchain, chain []string) (Toolchain, error) {
+	if t.Extends == "" {
+		if t.BuildType == "" {
+			t.BuildType = "Release"
+		}
+		return t, nil
+	}
+
+	chain = append(chain, t.Name)
+	if slices.Contains(chain, t.Extends) {
+		return Toolchain{}, fmt.Errorf("toolchain '%s' has an inheritance cycle: %s -> %s",
+			chain[0], strings.Join(chain, " -> "), t.Extends)
+	}
+
+	base := c.findBase(t.Extends)
+	if base == nil {
+		return Toolchain{}, fmt.Errorf("toolchain '%s' extends unknown template or toolchain '%s'", t.Name, t.Extends)
+	}
+
+	parent, err := c.resolve(*base, chain)
+	if err != nil {
+		return Toolchain{}, err
+	}
+	return mergeToolchain(parent, t), nil
+}
+
+// mergeToolchain applies child on top of an already resolved parent
+func mergeToolchain(parent, child Toolchain) Toolchain {
+	out := parent
+	out.Name = child.Name
+	out.Extends = child.Extends
+	out.Active = child.Active
+
+	if child.Runner != "" {
+		out.Runner = child.Runner
+	}
+	if child.BuildType != "" {
+		out.BuildType = child.BuildType
+	}
+	if child.Optimization != "" {
+		out.Optimization = child.Optimization
+	}
+	if child.Jobs != 0 {
+		out.Jobs = child.Jobs
+	}
+	out.Hardening = parent.Hardening || child.Hardening
+	out.CCache = parent.CCache || child.CCache
+	if len(child.Paths) > 0 {
+		out.Paths = child.Paths
+	}
+	if len(child.DependsOn) > 0 {
+		out.DependsOn = child.DependsOn
+	}
+	if child.ArtifactName != "" {
+		out.ArtifactName = child.ArtifactName
+	}
+	if child.Upload != nil {
+		out.Upload = child.Upload
+	}
+	if child.Timeout != "" {
+		out.Timeout = child.Timeout
+	}
+	if child.Retries != 0 {
+		out.Retries = child.Retries
+	}
+	out.Services = slices.Clone(parent.Services)
+	for _, svc := range child.Services {
+		if i := slices.IndexFunc(out.Services, func(s Service) bool { return s.Name == svc.Name }); i >= 0 {
+			out.Services[i] = svc
+		} else {
+			out.Services = append(out.Services, svc)
+		}
+	}
+	if child.Resources != nil {
+		res := Resources{}
+		if parent.Resources != nil {
+			res = *parent.Resources
+		}
+		if child.Resources.CPUs != 0 {
+			res.CPUs = child.Resources.CPUs
+		}
+		if child.Resources.Memory != "" {
+			res.Memory = child.Resources.Memory
+		}
+		out.Resources = &res
+	}
+
+	out.CMakeOptions = append(slices.Clone(parent.CMakeOptions), child.CMakeOptions...)
+	out.BuildOptions = append(slices.Clone(parent.BuildOptions), child.BuildOptions...)
+	out.Tags = union(parent.Tags, child.Tags)
+	out.EnvPassthrough = union(parent.EnvPassthrough, child.EnvPassthrough)
+	out.Secrets = union(parent.Secrets, child.Secrets)
+	out.Hooks = MergeHooks(parent.Hooks, child.Hooks)
+
+	if len(parent.Env) > 0 || len(child.Env) > 0 {
+		out.Env = make(map[string]string, len(parent.Env)+len(child.Env))
+		for k, v := range parent.Env {
+			out.Env[k] = v
+		}
+		for k, v := range child.Env {
+			out.Env[k] = v
+		}
+	}
+	return out
+}
+
+// union returns the values of a, then those of b not in a
+func union(a, b []string) []string {
+	out := slices.Clone(a)
+	for _, v := range b {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -9,11 +9,54 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// GlobalConfig represents the global cpx configuration
+// GlobalConfig represents the global cpx configuration, or a project's
+// .cpx.yaml layered over it (see Load)
 type GlobalConfig struct {
-	VcpkgRoot  string `yaml:"vcpkg_root"`
-	BcrRoot    string `yaml:"bcr_root"`    // Bazel Central Registry path
-	WrapdbRoot string `yaml:"wrapdb_root"` // Meson WrapDB path
+	VcpkgRoot  string `yaml:"vcpkg_root,omitempty" check:"dir"`
+	BcrRoot    string `yaml:"bcr_root,omitempty" check:"dir"`    // Bazel Central Registry path
+	WrapdbRoot string `yaml:"wrapdb_root,omitempty" check:"dir"` // Meson WrapDB path
+
+	// defaults of `cpx build` and `cpx run`, usually set per project in .cpx.yaml
+	BuildType string `yaml:"build_type,omitempty" enum:"debug,release"`
+	Jobs      int    `yaml:"jobs,omitempty"`
+
+	Color         string `yaml:"color,omitempty" enum:"auto,always,never"`  // colored output (default: auto, off when not a terminal or NO_COLOR is set)
+	DockerHost    string `yaml:"docker_host,omitempty" check:"docker_host"` // Docker engine of runners that set none, e.g. ssh://ci@build-box
+	DockerContext string `yaml:"docker_context,omitempty"`                  // docker CLI context of runners that set none
+	Registry      string `yaml:"registry,omitempty"`                        // prefix of `cpx ci image` repositories, e.g. ghcr.io/acme
+
+	// RelocateWSLCaches keeps CI build caches on the WSL ext4 filesystem when the
+	// project lives on a Windows drive (/mnt/c). Unset until the user is asked.
+	RelocateWSLCaches *bool `yaml:"relocate_wsl_caches,omitempty"`
+
+	// Deprecated: analyzers of `cpx analyze`, now disable, semgrep_rules and
+	// tools in .cpx-quality.yaml; still read and folded into it (see
+	// MigrateDeprecated)
+	AnalyzeSkip  []string   `yaml:"analyze_skip,omitempty"`  // analyzers not to run, e.g. [flawfinder, cpplint]
+	SemgrepRules []string   `yaml:"semgrep_rules,omitempty"` // Semgrep rulesets, e.g. [cpx, p/c, .semgrep/]
+	Analyzers    []Analyzer `yaml:"analyzers,omitempty"`     // style checkers run besides the built-in ones
+}
+
+// Analyzer is a style checker of the deprecated analyzers key, a regex tool
+// of .cpx-quality.yaml
+type Analyzer struct {
+	Name string `yaml:"name"`
+
+	// Command is run in the project with the files to check appended; it is
+	// split at spaces.
+	Command string `yaml:"command"`
+
+	// Pattern matches a diagnostic with the named groups file, line and
+	// message, and optionally column, severity and rule.
+	Pattern string `yaml:"pattern"`
+
+	// Severity of diagnostics the pattern gives none (default: warning)
+	Severity string `yaml:"severity,omitempty"`
+}
+
+// String returns the name of the analyzer, as `cpx config get` lists it
+func (a Analyzer) String() string {
+	return a.Name
 }
 
 // GetConfigDir returns the directory where cpx stores its global config
@@ -0,0 +1,57 @@
+package config
+
+import "fmt"
+
+// Hooks are shell commands run around toolchain builds, e.g. to generate
+// code, fetch assets or notify a chat
+type Hooks struct {
+	PreBuild  []Hook `yaml:"pre_build,omitempty"`
+	PostBuild []Hook `yaml:"post_build,omitempty"` // after a successful build, before its artifacts are named
+	OnFailure []Hook `yaml:"on_failure,omitempty"` // after a failed build or hook
+}
+
+// Hook is a command run with sh -c, on the host in the project root or in a
+// Docker toolchain's build container
+type Hook struct {
+	Run string `yaml:"run"`
+	In  string `yaml:"in,omitempty" enum:"host,container"` // default: host
+}
+
+// InContainer returns true if the hook runs in the build container
+func (h Hook) InContainer() bool {
+	return h.In == "container"
+}
+
+// MergeHooks returns the hooks of a, then those of b, for each phase
+func MergeHooks(a, b *Hooks) *Hooks {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return &Hooks{
+		PreBuild:  append(append([]Hook(nil), a.PreBuild...), b.PreBuild...),
+		PostBuild: append(append([]Hook(nil), a.PostBuild...), b.PostBuild...),
+		OnFailure: append(append([]Hook(nil), a.OnFailure...), b.OnFailure...),
+	}
+}
+
+// Validate checks every hook has a command
+func (h *Hooks) Validate() error {
+	if h == nil {
+		return nil
+	}
+	phases := []struct {
+		name  string
+		hooks []Hook
+	}{{"pre_build", h.PreBuild}, {"post_build", h.PostBuild}, {"on_failure", h.OnFailure}}
+	for _, phase := range phases {
+		for i, hook := range phase.hooks {
+			if hook.Run == "" {
+				return fmt.Errorf("%s[%d]: run must not be empty", phase.name, i)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includedConfig is what a file listed under `include:` may define
+type includedConfig struct {
+	Include    []string    `yaml:"include,omitempty"`
+	Runners    []Runner    `yaml:"runners,omitempty"`
+	Templates  []Toolchain `yaml:"templates,omitempty"`
+	Toolchains []Toolchain `yaml:"toolchains,omitempty"`
+}
+
+// entryOrigin is where a runner, template or toolchain was defined
+type entryOrigin struct {
+	file string
+	node *yaml.Node
+}
+
+// includeLists are the keys of the lists an included file adds to
+var includeLists = []string{"runners", "templates", "toolchains"}
+
+// loadIncludes adds the runners, templates and toolchains of the files under
+// `include:` to a config loaded from path, ahead of its own. Patterns are
+// relative to the including file and may be globs; included files may include
+// others, and each file is read once. A name defined both in an included file
+// and elsewhere is an error.
+func (c *ToolchainConfig) loadIncludes(path string, root *yaml.Node) error {
+	own := includedConfig{Runners: c.Runners, Templates: c.Templates, Toolchains: c.Toolchains}
+	c.Runners, c.Templates, c.Toolchains = nil, nil, nil
+	c.file = path
+	c.origins = make(map[string][]entryOrigin)
+
+	loaded := make(map[string]bool)
+	if abs, err := filepath.Abs(path); err == nil {
+		loaded[abs] = true
+	}
+	if err := c.include(path, c.Include, loaded); err != nil {
+		return err
+	}
+	c.addFile(path, root, own)
+	return c.checkIncludedNames()
+}
+
+// include reads the files matching patterns, listed in from
+func (c *ToolchainConfig) include(from string, patterns []string, loaded map[string]bool) error {
+	for _, pattern := range patterns {
+		p := pattern
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(from), p)
+		}
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return fmt.Errorf("invalid include '%s' in %s: %w", pattern, from, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("include '%s' in %s matches no files", pattern, from)
+		}
+		for _, file := range matches {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				return err
+			}
+			if loaded[abs] {
+				continue
+			}
+			loaded[abs] = true
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read include %s: %w", file, err)
+			}
+			var root yaml.Node
+			if err := yaml.Unmarshal(data, &root); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			if errs := checkSchema(file, &root, reflect.TypeFor[includedConfig]()); len(errs) > 0 {
+				return errs
+			}
+			var included includedConfig
+			if err := root.Decode(&included); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			if err := c.include(file, included.Include, loaded); err != nil {
+				return err
+			}
+			c.addFile(file, &root, included)
+		}
+	}
+	return nil
+}
+
+// addFile appends the lists of a file and records where their entries are defined
+func (c *ToolchainConfig) addFile(file string, root *yaml.Node, lists includedConfig) {
+	c.Runners = append(c.Runners, lists.Runners...)
+	c.Templates = append(c.Templates, lists.Templates...)
+	c.Toolchains = append(c.Toolchains, lists.Toolchains...)
+	if len(root.Content) == 0 {
+		return
+	}
+	doc := root.Content[0]
+	for _, key := range includeLists {
+		if list := mappingValue(doc, key); list != doc {
+			for _, node := range list.Content {
+				c.origins[key] = append(c.origins[key], entryOrigin{file: file, node: node})
+			}
+		}
+	}
+}
+
+// checkIncludedNames reports names defined in an included file and again
+// elsewhere; duplicates within the file itself are left to ValidateToolchains
+func (c *ToolchainConfig) checkIncludedNames() error {
+	var errs SchemaErrors
+	for _, key := range includeLists {
+		first := make(map[string]entryOrigin)
+		for i, name := range c.names(key) {
+			origin := c.origin(key, i)
+			prev, ok := first[name]
+			if !ok {
+				first[name] = origin
+				continue
+			}
+			if prev.file != c.file || origin.file != c.file {
+				errs = append(errs, SchemaError{File: origin.file, Line: origin.node.Line, Column: origin.node.Column,
+					Message: fmt.Sprintf("%s[%s] is already defined in %s", key, name, prev.file)})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// names returns the names of the entries of a list
+func (c *ToolchainConfig) names(key string) []string {
+	var names []string
+	switch key {
+	case "runners":
+		for _, r := range c.Runners {
+			names = append(names, r.Name)
+		}
+	case "templates":
+		for _, t := range c.Templates {
+			names = append(names, t.Name)
+		}
+	case "toolchains":
+		for _, t := range c.Toolchains {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// origin returns where the i-th entry of a list was defined, as loaded
+func (c *ToolchainConfig) origin(key string, i int) entryOrigin {
+	if origins := c.origins[key]; i < len(origins) {
+		return origins[i]
+	}
+	return entryOrigin{file: c.file, node: &yaml.Node{}}
+}
+
+// included reports whether the named entry of a list comes from an included
+// file, so SaveToolchains leaves it there
+func (c *ToolchainConfig) included(key, name string) bool {
+	for i, origin := range c.origins[key] {
+		if entryName(origin.node, i) == name {
+			return origin.file != c.file
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFile is the project-local configuration layered over the
+// global one
+const ProjectConfigFile = ".cpx.yaml"
+
+// Load returns the effective configuration: the defaults, overridden by the
+// global config, overridden by the .cpx.yaml of the current project
+func Load() (*GlobalConfig, error) {
+	cfg, _, err := LoadWithOrigins()
+	return cfg, err
+}
+
+// LoadWithOrigins returns the effective configuration with the file each key
+// was set in, "" for defaults. The project file is the nearest .cpx.yaml in
+// the current directory or its parents; relative directories in it are
+// relative to it. Neither file is created.
+func LoadWithOrigins() (*GlobalConfig, map[string]string, error) {
+	cfg := &GlobalConfig{}
+	origins := make(map[string]string)
+
+	globalPath, err := GetConfigPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := os.Stat(globalPath); err == nil {
+		global, err := LoadGlobal()
+		if err != nil {
+			return nil, nil, err
+		}
+		overlay(cfg, global, globalPath, origins)
+	}
+
+	projectPath := FindProjectConfig()
+	if projectPath == "" {
+		return cfg, origins, nil
+	}
+	project, err := loadProjectConfig(projectPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	overlay(cfg, project, projectPath, origins)
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", projectPath, err)
+	}
+	return cfg, origins, nil
+}
+
+// FindProjectConfig returns the path of the nearest .cpx.yaml in the
+// current directory or its parents, or "" if there is none
+func FindProjectConfig() string {
+	return findUp(ProjectConfigFile)
+}
+
+// findUp returns the path of the nearest file named name in the current
+// directory or its parents, or "" if there is none
+func findUp(name string) string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadProjectConfig reads a .cpx.yaml, checked like cpx-ci.yaml
+func loadProjectConfig(path string) (*GlobalConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if errs := checkSchema(path, &root, reflect.TypeFor[GlobalConfig]()); len(errs) > 0 {
+		return nil, errs
+	}
+	var cfg GlobalConfig
+	if err := root.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	v := reflect.ValueOf(&cfg).Elem()
+	for _, f := range schemaFields(reflect.TypeFor[GlobalConfig]()) {
+		if field := v.Field(f.index); f.Check == "dir" && field.String() != "" && !filepath.IsAbs(field.String()) {
+			field.SetString(filepath.Join(filepath.Dir(path), field.String()))
+		}
+	}
+	return &cfg, nil
+}
+
+// overlay copies the keys layer sets onto cfg, recording file as their origin
+func overlay(cfg, layer *GlobalConfig, file string, origins map[string]string) {
+	dst, src := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(layer).Elem()
+	for _, f := range schemaFields(reflect.TypeFor[GlobalConfig]()) {
+		if value := src.Field(f.index); !value.IsZero() {
+			dst.Field(f.index).Set(value)
+			origins[f.Name] = file
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// packageFormats are the formats `cpx package` builds
+var packageFormats = []string{"tar.gz", "zip", "deb", "rpm"}
+
+// PackageConfig describes the packages `cpx package` builds from the
+// artifacts of `cpx ci build` (cpx-ci.yaml `package:`)
+type PackageConfig struct {
+	Name        string   `yaml:"name,omitempty"`                              // package name (default: project name)
+	Version     string   `yaml:"version,omitempty"`                           // package version (default: project version)
+	Formats     []string `yaml:"formats,omitempty" enum:"tar.gz,zip,deb,rpm"` // tar.gz, zip, deb, rpm (default: tar.gz)
+	Toolchains  []string `yaml:"toolchains,omitempty"`                        // toolchains to package (default: all active)
+	Maintainer  string   `yaml:"maintainer,omitempty"`                        // e.g. "Jane Doe <jane@example.com>"
+	Description string   `yaml:"description,omitempty"`                       // first line is the summary
+	Homepage    string   `yaml:"homepage,omitempty"`
+	License     string   `yaml:"license,omitempty"` // SPDX identifier, e.g. MIT
+	Depends     []string `yaml:"depends,omitempty"` // deb Depends and rpm Requires
+	Prefix      string   `yaml:"prefix,omitempty"`  // deb/rpm install prefix (default: /usr)
+}
+
+// GetFormats returns the package formats, tar.gz unless set
+func (p *PackageConfig) GetFormats() []string {
+	if p == nil || len(p.Formats) == 0 {
+		return []string{"tar.gz"}
+	}
+	return p.Formats
+}
+
+// GetPrefix returns the install prefix of deb and rpm packages, /usr unless set
+func (p *PackageConfig) GetPrefix() string {
+	if p == nil || p.Prefix == "" {
+		return "/usr"
+	}
+	return p.Prefix
+}
+
+// Validate checks the package formats
+func (p *PackageConfig) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, f := range p.Formats {
+		if !slices.Contains(packageFormats, f) {
+			return fmt.Errorf("unknown format '%s' (use tar.gz, zip, deb or rpm)", f)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile is a set of build settings applied to every toolchain with
+// `cpx ci build --profile`, e.g. a sanitizer build of all targets
+type Profile struct {
+	Name         string            `yaml:"name"`
+	BuildType    string            `yaml:"build_type,omitempty" enum:"Debug,Release,RelWithDebInfo,MinSizeRel"`
+	Optimization string            `yaml:"optimization,omitempty" enum:"0,1,2,3,s,fast"`
+	CMakeOptions []string          `yaml:"cmake_options,omitempty"`
+	BuildOptions []string          `yaml:"build_options,omitempty"`
+	Env          map[string]string `yaml:"env,omitempty"`
+}
+
+// FindProfile finds a profile by name
+func (c *ToolchainConfig) FindProfile(name string) *Profile {
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == name {
+			return &c.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// ApplyProfile returns resolved toolchains with the named profile applied, as
+// if each extended it: its build type and optimization replace theirs, its
+// cmake and build options come after theirs and its env is merged over theirs.
+func (c *ToolchainConfig) ApplyProfile(toolchains []Toolchain, name string) ([]Toolchain, error) {
+	p := c.FindProfile(name)
+	if p == nil {
+		var names []string
+		for _, p := range c.Profiles {
+			names = append(names, p.Name)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("profile '%s' not found: cpx-ci.yaml has no profiles", name)
+		}
+		return nil, fmt.Errorf("profile '%s' not found in cpx-ci.yaml (available: %s)", name, strings.Join(names, ", "))
+	}
+	out := make([]Toolchain, len(toolchains))
+	for i, t := range toolchains {
+		out[i] = mergeToolchain(t, Toolchain{
+			Name:         t.Name,
+			Extends:      t.Extends,
+			Active:       t.Active,
+			BuildType:    p.BuildType,
+			Optimization: p.Optimization,
+			CMakeOptions: p.CMakeOptions,
+			BuildOptions: p.BuildOptions,
+			Env:          p.Env,
+		})
+	}
+	return out, nil
+}
@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,21 +17,79 @@ import (
 // - runners: execution environments (docker/ssh) with optional compiler settings
 // - toolchains: named build configurations referencing a runner
 type ToolchainConfig struct {
-	Runners    []Runner    `yaml:"runners,omitempty"`
-	Toolchains []Toolchain `yaml:"toolchains,omitempty"`
+	Include    []string       `yaml:"include,omitempty"` // files adding runners, templates and toolchains
+	Runners    []Runner       `yaml:"runners,omitempty"`
+	Templates  []Toolchain    `yaml:"templates,omitempty"` // bases for `extends`, never built
+	Toolchains []Toolchain    `yaml:"toolchains,omitempty"`
+	Profiles   []Profile      `yaml:"profiles,omitempty"`   // settings applied to every toolchain with --profile
+	Hooks      *Hooks         `yaml:"hooks,omitempty"`      // run around every toolchain's build, before its own hooks
+	Parallel   int            `yaml:"parallel,omitempty"`   // Docker toolchains built concurrently (default: 1)
+	KeepGoing  bool           `yaml:"keep_going,omitempty"` // sequential builds continue after a failed toolchain
+	Cache      *CacheConfig   `yaml:"cache,omitempty"`      // compiler cache for Docker toolchains
+	Vcpkg      *VcpkgConfig   `yaml:"vcpkg,omitempty"`      // vcpkg settings for Docker toolchains
+	Changes    *Changes       `yaml:"changes,omitempty"`    // change detection for `cpx ci build --changed-only`
+	Checksums  *Checksums     `yaml:"checksums,omitempty"`  // SHA256SUMS manifests written by `cpx ci build`
+	Sign       *SignConfig    `yaml:"sign,omitempty"`       // signatures of the artifacts of `cpx ci build`
+	SBOM       *SBOMConfig    `yaml:"sbom,omitempty"`       // software bill of materials per toolchain
+	Provenance bool           `yaml:"provenance,omitempty"` // SLSA provenance per toolchain
+	Package    *PackageConfig `yaml:"package,omitempty"`    // packages built by `cpx package`
+	Upload     *UploadConfig  `yaml:"upload,omitempty"`     // destination of `cpx ci build --upload`
+	// runtime images built by `cpx ci image` from a toolchain's executable
+	RuntimeImage *RuntimeImageConfig `yaml:"runtime_image,omitempty"`
+
+	file    string                   // path the config was loaded from
+	origins map[string][]entryOrigin // where each runner, template and toolchain was defined
 }
 
 // Runner defines an execution environment with optional compiler settings
 type Runner struct {
-	Name  string `yaml:"name"`
-	Type  string `yaml:"type,omitempty"`  // docker, ssh (native/local if omitted)
-	Image string `yaml:"image,omitempty"` // for docker
-	Host  string `yaml:"host,omitempty"`  // for ssh
-	User  string `yaml:"user,omitempty"`  // for ssh
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type,omitempty" enum:"docker,ssh,plugin,native,local"` // docker, ssh, plugin (native/local if omitted)
+	Image    string `yaml:"image,omitempty"`                                      // for docker (repository for the built image in build mode)
+	Platform string `yaml:"platform,omitempty"`                                   // for docker, e.g. linux/arm64 (default: host architecture)
+	Host     string `yaml:"host,omitempty"`                                       // for ssh
+	User     string `yaml:"user,omitempty"`                                       // for ssh
+	Port     int    `yaml:"port,omitempty"`                                       // for ssh (default: 22 or ~/.ssh/config)
+	// for ssh: private key and remote working directory (default: ~/.cache/cpx/<project>)
+	IdentityFile string `yaml:"identity_file,omitempty"`
+	WorkDir      string `yaml:"work_dir,omitempty"`
 	// Compiler settings (optional, can be set in runner)
 	CC                 string `yaml:"cc,omitempty"`
 	CXX                string `yaml:"cxx,omitempty"`
 	CMakeToolchainFile string `yaml:"cmake_toolchain_file,omitempty"`
+	// Cross-compilation preset (docker only), e.g. windows-amd64; see cpx ci explain
+	Target string `yaml:"target,omitempty"`
+	// Remote Docker engine (docker only): a DOCKER_HOST value or a docker CLI context
+	DockerHost    string `yaml:"docker_host,omitempty"`
+	DockerContext string `yaml:"docker_context,omitempty"`
+	// Container user (docker only): uid:gid, or root to keep the image's user
+	// (default: the host user for local engines on Linux)
+	DockerUser string `yaml:"docker_user,omitempty"`
+	// Container networking (docker only): --network mode (e.g. host for a
+	// local package mirror), --add-host entries as host:ip, and --dns servers
+	Network    string   `yaml:"network,omitempty"`
+	ExtraHosts []string `yaml:"extra_hosts,omitempty"`
+	DNS        []string `yaml:"dns,omitempty"`
+	// Build the image from a Dockerfile instead of using a pulled image (docker only)
+	Build *RunnerBuild `yaml:"build,omitempty"`
+	// External runner (plugin only): the executable, on PATH or relative to the
+	// project root, run for each build step, and settings passed to it
+	Plugin  string            `yaml:"plugin,omitempty"`
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+// RunnerBuild describes how to build a Docker runner's image
+type RunnerBuild struct {
+	Context    string            `yaml:"context,omitempty"`    // build context (default: ".")
+	Dockerfile string            `yaml:"dockerfile,omitempty"` // relative to the project root (default: "Dockerfile")
+	Args       map[string]string `yaml:"args,omitempty"`       // --build-arg values
+	// BuildKit layer caches, e.g. type=registry,ref=ghcr.io/acme/cpx-gcc:cache
+	// or type=gha, so image layers survive ephemeral CI runners
+	CacheFrom []string `yaml:"cache_from,omitempty"`
+	CacheTo   []string `yaml:"cache_to,omitempty"`
+	// Push the built image to its repository (the runner's image), and pull it
+	// from there before building, so machines share one build per hash
+	Push bool `yaml:"push,omitempty"`
 }
 
 // IsNative returns true if the runner type is native/local (or unspecified)
@@ -45,17 +107,175 @@ func (r *Runner) IsSSH() bool {
 	return r.Type == "ssh"
 }
 
+// IsPlugin returns true if the runner type is plugin
+func (r *Runner) IsPlugin() bool {
+	return r.Type == "plugin"
+}
+
 // Toolchain defines a build configuration (renamed from BuildConfig)
 type Toolchain struct {
 	Name         string            `yaml:"name"`
-	Runner       string            `yaml:"runner,omitempty"` // references Runner.Name
-	Active       *bool             `yaml:"active,omitempty"` // true (default) or false to disable
-	BuildType    string            `yaml:"build_type,omitempty"`
+	Extends      string            `yaml:"extends,omitempty"` // inherit from a template or toolchain
+	Runner       string            `yaml:"runner,omitempty"`  // references Runner.Name
+	Active       *bool             `yaml:"active,omitempty"`  // true (default) or false to disable
+	BuildType    string            `yaml:"build_type,omitempty" enum:"Debug,Release,RelWithDebInfo,MinSizeRel"`
 	CMakeOptions []string          `yaml:"cmake_options,omitempty"`
 	BuildOptions []string          `yaml:"build_options,omitempty"`
 	Env          map[string]string `yaml:"env,omitempty"`
-	Optimization string            `yaml:"optimization,omitempty"` // "0", "1", "2", "3", "s", "fast"
-	Jobs         int               `yaml:"jobs,omitempty"`         // number of parallel jobs
+	Optimization string            `yaml:"optimization,omitempty" enum:"0,1,2,3,s,fast"` // "0", "1", "2", "3", "s", "fast"
+	Jobs         int               `yaml:"jobs,omitempty"`                               // number of parallel jobs
+	Hardening    bool              `yaml:"hardening,omitempty"`                          // FORTIFY_SOURCE, stack protector, PIE, full RELRO
+	CCache       bool              `yaml:"ccache,omitempty"`                             // Docker builds: compiler cache in <cache>/<name>/.ccache
+	Paths        []string          `yaml:"paths,omitempty"`                              // --changed-only: build only when a matching file changed
+	DependsOn    []string          `yaml:"depends_on,omitempty"`                         // toolchains that must build successfully first
+	Tags         []string          `yaml:"tags,omitempty"`                               // --tags / --exclude-tags selection
+	ArtifactName string            `yaml:"artifact_name,omitempty"`                      // e.g. "{{name}}-{{version}}-{{target}}{{ext}}"
+	Resources    *Resources        `yaml:"resources,omitempty"`                          // Docker builds: container CPU and memory limits
+	Services     []Service         `yaml:"services,omitempty"`                           // Docker builds: sidecars for tests and runs
+	Upload       *UploadConfig     `yaml:"upload,omitempty"`                             // --upload destination, overriding the global one
+	Timeout      string            `yaml:"timeout,omitempty"`                            // Docker builds: stop an attempt after this long, e.g. "45m"
+	Retries      int               `yaml:"retries,omitempty"`                            // Docker builds: attempts after a failed or timed out one
+	// Docker builds: host variables forwarded into the container by name, so
+	// their values are never written into the build script; the values of
+	// secrets are also masked in the build output and logs
+	EnvPassthrough []string `yaml:"env_passthrough,omitempty"`
+	Secrets        []string `yaml:"secrets,omitempty"`
+	// commands run before and after the build, after the global hooks
+	Hooks *Hooks `yaml:"hooks,omitempty"`
+}
+
+// BuildTimeout returns how long a build attempt may take, or 0 for no limit
+func (t *Toolchain) BuildTimeout() time.Duration {
+	d, _ := time.ParseDuration(t.Timeout)
+	return d
+}
+
+// validateAttempts checks a toolchain's timeout is a positive duration and
+// its retries are not negative
+func validateAttempts(t Toolchain) error {
+	if t.Timeout != "" {
+		if d, err := time.ParseDuration(t.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("timeout '%s' must be a positive duration such as 30m or 1h30m", t.Timeout)
+		}
+	}
+	if t.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	return nil
+}
+
+// envName matches the name of an environment variable
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateEnvNames checks env_passthrough and secrets name variables
+func validateEnvNames(names []string) error {
+	for _, name := range names {
+		if !envName.MatchString(name) {
+			return fmt.Errorf("'%s' is not an environment variable name", name)
+		}
+	}
+	return nil
+}
+
+// Service is a sidecar container started for a toolchain's tests, benchmarks
+// and runs; the build container reaches it by name
+type Service struct {
+	Name      string            `yaml:"name"`
+	Image     string            `yaml:"image"`
+	Env       map[string]string `yaml:"env,omitempty"`
+	Ports     []string          `yaml:"ports,omitempty"`      // published to the host, e.g. "5432:5432"
+	Command   []string          `yaml:"command,omitempty"`    // replaces the image's command
+	HealthCmd string            `yaml:"health_cmd,omitempty"` // readiness check the build waits for
+}
+
+// validateServices checks services have a unique name and an image
+func validateServices(services []Service) error {
+	seen := make(map[string]bool, len(services))
+	for i, s := range services {
+		switch {
+		case s.Name == "":
+			return fmt.Errorf("services[%d]: name is required", i)
+		case s.Image == "":
+			return fmt.Errorf("service '%s': image is required", s.Name)
+		case seen[s.Name]:
+			return fmt.Errorf("service '%s' is defined twice", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return nil
+}
+
+// Resources limits the containers of a toolchain's Docker builds
+type Resources struct {
+	CPUs   float64 `yaml:"cpus,omitempty"`   // docker run --cpus, e.g. 2 or 1.5; also the default jobs
+	Memory string  `yaml:"memory,omitempty"` // docker run --memory, e.g. 4g or 512m
+}
+
+var memoryLimit = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// Validate checks the limits have the values docker run accepts
+func (r *Resources) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.CPUs < 0 {
+		return fmt.Errorf("cpus must not be negative")
+	}
+	if r.Memory != "" && !memoryLimit.MatchString(r.Memory) {
+		return fmt.Errorf("memory '%s' is not a size like 512m or 4g", r.Memory)
+	}
+	return nil
+}
+
+// Changes configures which changes `cpx ci build --changed-only` rebuilds for
+type Changes struct {
+	Base   string   `yaml:"base,omitempty"`   // git ref to compare against (default: origin/HEAD)
+	Ignore []string `yaml:"ignore,omitempty"` // globs that never trigger a build (default: docs/**, **/*.md)
+}
+
+// Checksums configures the SHA256SUMS manifests `cpx ci build` writes
+type Checksums struct {
+	PerToolchain bool `yaml:"per_toolchain,omitempty"` // also write <output>/<toolchain>/SHA256SUMS
+}
+
+// RuntimeImageConfig describes the images `cpx ci image` wraps a built executable in
+type RuntimeImageConfig struct {
+	Base       string `yaml:"base,omitempty"`       // base image (default: gcr.io/distroless/cc-debian12)
+	Repository string `yaml:"repository,omitempty"` // image repository (default: the project name)
+	Executable string `yaml:"executable,omitempty"` // entrypoint when a toolchain builds several executables
+	Push       bool   `yaml:"push,omitempty"`       // push the image after building it
+}
+
+// GetBase returns the base image, distroless with the C/C++ runtime unless set
+func (r *RuntimeImageConfig) GetBase() string {
+	if r == nil || r.Base == "" {
+		return "gcr.io/distroless/cc-debian12"
+	}
+	return r.Base
+}
+
+// SBOMConfig writes a software bill of materials next to each toolchain's artifacts
+type SBOMConfig struct {
+	Format string `yaml:"format,omitempty" enum:"spdx,cyclonedx"` // spdx (default) or cyclonedx
+}
+
+// GetFormat returns the SBOM format, spdx unless set
+func (s *SBOMConfig) GetFormat() string {
+	if s.Format == "" {
+		return "spdx"
+	}
+	return s.Format
+}
+
+// Validate checks the SBOM format
+func (s *SBOMConfig) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if f := s.GetFormat(); f != "spdx" && f != "cyclonedx" {
+		return fmt.Errorf("unknown format '%s' (use spdx or cyclonedx)", f)
+	}
+	return nil
 }
 
 // IsActive returns whether the toolchain is active (defaults to true if not specified)
@@ -66,6 +286,11 @@ func (t *Toolchain) IsActive() bool {
 	return *t.Active
 }
 
+// HasAnyTag returns whether the toolchain has one of tags
+func (t *Toolchain) HasAnyTag(tags []string) bool {
+	return slices.ContainsFunc(tags, func(tag string) bool { return slices.Contains(t.Tags, tag) })
+}
+
 // LoadToolchains loads the toolchain configuration from cpx-ci.yaml
 func LoadToolchains(path string) (*ToolchainConfig, error) {
 	data, err := os.ReadFile(path)
@@ -73,14 +298,71 @@ func LoadToolchains(path string) (*ToolchainConfig, error) {
 		return nil, err
 	}
 
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse cpx-ci.yaml: %w", err)
+	}
+	if errs := checkSchema(path, &root, reflect.TypeFor[ToolchainConfig]()); len(errs) > 0 {
+		return nil, errs
+	}
 	var config ToolchainConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := root.Decode(&config); err != nil {
 		return nil, fmt.Errorf("failed to parse cpx-ci.yaml: %w", err)
 	}
+	if err := config.loadIncludes(path, &root); err != nil {
+		return nil, err
+	}
+	if err := config.Cache.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cache in cpx-ci.yaml: %w", err)
+	}
+	if err := config.Vcpkg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid vcpkg in cpx-ci.yaml: %w", err)
+	}
+	if err := config.Sign.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sign in cpx-ci.yaml: %w", err)
+	}
+	if err := config.SBOM.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sbom in cpx-ci.yaml: %w", err)
+	}
+	if err := config.Package.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid package in cpx-ci.yaml: %w", err)
+	}
+	if err := config.Upload.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid upload in cpx-ci.yaml: %w", err)
+	}
+	if err := config.Hooks.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid hooks in cpx-ci.yaml: %w", err)
+	}
+
+	for _, list := range [][]Toolchain{config.Templates, config.Toolchains} {
+		for _, t := range list {
+			if err := t.Resources.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid resources for '%s' in cpx-ci.yaml: %w", t.Name, err)
+			}
+			if err := validateServices(t.Services); err != nil {
+				return nil, fmt.Errorf("invalid services for '%s' in cpx-ci.yaml: %w", t.Name, err)
+			}
+			if err := validateArtifactName(t.ArtifactName); err != nil {
+				return nil, fmt.Errorf("invalid artifact_name for '%s' in cpx-ci.yaml: %w", t.Name, err)
+			}
+			if err := t.Upload.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid upload for '%s' in cpx-ci.yaml: %w", t.Name, err)
+			}
+			if err := validateAttempts(t); err != nil {
+				return nil, fmt.Errorf("invalid toolchain '%s' in cpx-ci.yaml: %w", t.Name, err)
+			}
+			if err := validateEnvNames(append(slices.Clone(t.EnvPassthrough), t.Secrets...)); err != nil {
+				return nil, fmt.Errorf("invalid toolchain '%s' in cpx-ci.yaml: %w", t.Name, err)
+			}
+			if err := t.Hooks.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid hooks for '%s' in cpx-ci.yaml: %w", t.Name, err)
+			}
+		}
+	}
 
-	// Set defaults for each toolchain
+	// Set defaults for each toolchain (inherited values are defaulted when resolved)
 	for i := range config.Toolchains {
-		if config.Toolchains[i].BuildType == "" {
+		if config.Toolchains[i].BuildType == "" && config.Toolchains[i].Extends == "" {
 			config.Toolchains[i].BuildType = "Release"
 		}
 	}
@@ -113,9 +395,14 @@ func (c *ToolchainConfig) GetOutputDir() string {
 	return filepath.Join(".bin", "ci")
 }
 
-// SaveToolchains saves the toolchain configuration to cpx-ci.yaml
+// SaveToolchains saves the toolchain configuration to cpx-ci.yaml. Runners,
+// templates and toolchains from included files stay in those files.
 func SaveToolchains(config *ToolchainConfig, path string) error {
-	data, err := yaml.Marshal(config)
+	own := *config
+	own.Runners = slices.DeleteFunc(slices.Clone(config.Runners), func(r Runner) bool { return config.included("runners", r.Name) })
+	own.Templates = slices.DeleteFunc(slices.Clone(config.Templates), func(t Toolchain) bool { return config.included("templates", t.Name) })
+	own.Toolchains = slices.DeleteFunc(slices.Clone(config.Toolchains), func(t Toolchain) bool { return config.included("toolchains", t.Name) })
+	data, err := yaml.Marshal(&own)
 	if err != nil {
 		return fmt.Errorf("failed to marshal cpx-ci.yaml: %w", err)
 	}
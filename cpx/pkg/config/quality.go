@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// QualityConfigFile is the analysis configuration of a project, next to
+// .cpx.yaml at its root
+const QualityConfigFile = ".cpx-quality.yaml"
+
+// QualitySeverities are the severities findings can be overridden to
+var QualitySeverities = []string{"error", "warning", "style", "info"}
+
+// QualityConfig is a project's .cpx-quality.yaml, the defaults of `cpx
+// analyze` that its flags override
+type QualityConfig struct {
+	// Enable lists the analyzers to run, built-in ones or tools; default is
+	// all. Disable lists analyzers not to run.
+	Enable  []string `yaml:"enable,omitempty"`
+	Disable []string `yaml:"disable,omitempty"`
+
+	// Args are extra arguments of analyzers, by name.
+	Args map[string][]string `yaml:"args,omitempty"`
+
+	// SemgrepRules are the Semgrep rulesets, e.g. [cpx, p/c, .semgrep/].
+	SemgrepRules []string `yaml:"semgrep_rules,omitempty"`
+
+	// Include and Exclude are globs of the files whose findings are
+	// reported, e.g. src/** and src/generated/.
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	// Severity overrides the severity of findings by rule glob, e.g.
+	// modernize-*: style.
+	Severity map[string]string `yaml:"severity,omitempty"`
+
+	// Metrics are the thresholds of the metrics analyzer.
+	Metrics QualityMetrics `yaml:"metrics,omitempty"`
+
+	// Coverage are the thresholds of `cpx coverage`.
+	Coverage QualityCoverage `yaml:"coverage,omitempty"`
+
+	// Reports are the reports to write when --format is not given.
+	Reports []QualityReport `yaml:"reports,omitempty"`
+
+	// Tools are in-house checkers `cpx analyze` runs besides the built-in
+	// analyzers.
+	Tools []QualityTool `yaml:"tools,omitempty"`
+}
+
+// MigrateDeprecated folds the deprecated analyze_skip, semgrep_rules and
+// analyzers keys of .cpx.yaml or the global config into q: their analyzers are
+// disabled, their rulesets used unless q names its own and their style
+// checkers added as regex tools unless q has a tool of the same name. It
+// returns the keys that are set.
+func (q *QualityConfig) MigrateDeprecated(g *GlobalConfig) []string {
+	var keys []string
+	if len(g.AnalyzeSkip) > 0 {
+		keys = append(keys, "analyze_skip")
+		for _, name := range g.AnalyzeSkip {
+			name = strings.ToLower(name)
+			if name == "include-what-you-use" {
+				name = "iwyu"
+			}
+			if !slices.Contains(q.Disable, name) {
+				q.Disable = append(q.Disable, name)
+			}
+		}
+	}
+	if len(g.SemgrepRules) > 0 {
+		keys = append(keys, "semgrep_rules")
+		if len(q.SemgrepRules) == 0 {
+			q.SemgrepRules = g.SemgrepRules
+		}
+	}
+	if len(g.Analyzers) > 0 {
+		keys = append(keys, "analyzers")
+		for _, a := range g.Analyzers {
+			if slices.ContainsFunc(q.Tools, func(tool QualityTool) bool { return tool.Name == a.Name }) {
+				continue
+			}
+			q.Tools = append(q.Tools, QualityTool{
+				Name:     a.Name,
+				Command:  a.Command,
+				Format:   "regex",
+				Pattern:  a.Pattern,
+				Severity: a.Severity,
+			})
+		}
+	}
+	return keys
+}
+
+// QualityMetrics are the limits past which the metrics analyzer reports
+// findings; 0 is the default limit and a negative one disables its check
+type QualityMetrics struct {
+	// Complexity is the cyclomatic complexity of a function (default: 15)
+	Complexity int `yaml:"complexity,omitempty"`
+
+	// FunctionLines are the lines of code of a function (default: 100)
+	FunctionLines int `yaml:"function_lines,omitempty"`
+
+	// FileLines are the lines of code of a file (default: 1000)
+	FileLines int `yaml:"file_lines,omitempty"`
+
+	// DuplicateTokens is the length of the shortest duplicated block
+	// reported, in tokens (default: 100)
+	DuplicateTokens int `yaml:"duplicate_tokens,omitempty"`
+}
+
+// QualityCoverage are the minimum percentages of covered lines, functions
+// and branches below which `cpx coverage` fails; 0 checks nothing
+type QualityCoverage struct {
+	MinLines     float64 `yaml:"min_lines,omitempty"`
+	MinFunctions float64 `yaml:"min_functions,omitempty"`
+	MinBranches  float64 `yaml:"min_branches,omitempty"`
+}
+
+// QualityReport is a report of `cpx analyze`
+type QualityReport struct {
+	Format string `yaml:"format" enum:"html,junit,checkstyle,github,json"`
+
+	// Output is the report file (default: that of the format)
+	Output string `yaml:"output,omitempty"`
+}
+
+// QualityTool is a checker run as a command, whose output cpx parses into
+// findings
+type QualityTool struct {
+	Name string `yaml:"name"`
+
+	// Command is run in the project with the files to check appended; it is
+	// split at spaces.
+	Command string `yaml:"command"`
+
+	// Format is that of the command's output: regex (lines Pattern
+	// matches), sarif or jsonl (a JSON finding per line). Default is regex.
+	Format string `yaml:"format,omitempty" enum:"regex,sarif,jsonl"`
+
+	// Pattern matches a diagnostic of the regex format with the named
+	// groups file, line and message, and optionally column, severity and
+	// rule.
+	Pattern string `yaml:"pattern,omitempty"`
+
+	// Severity of findings that have none (default: warning)
+	Severity string `yaml:"severity,omitempty"`
+}
+
+// FindQualityConfig returns the path of the nearest .cpx-quality.yaml in the
+// current directory or its parents, or "" if there is none
+func FindQualityConfig() string {
+	return findUp(QualityConfigFile)
+}
+
+// LoadQuality reads a .cpx-quality.yaml, checked like cpx-ci.yaml. An empty
+// path gives an empty configuration.
+func LoadQuality(path string) (*QualityConfig, error) {
+	cfg := &QualityConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if errs := checkSchema(path, &root, reflect.TypeFor[QualityConfig]()); len(errs) > 0 {
+		return nil, errs
+	}
+	if err := root.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks severity overrides, coverage thresholds, that tool names
+// are unique and that regex tools have a pattern
+func (c *QualityConfig) Validate() error {
+	for rule, severity := range c.Severity {
+		if !slices.Contains(QualitySeverities, severity) {
+			return fmt.Errorf("severity of '%s' must be one of %s, not '%s'", rule, strings.Join(QualitySeverities, ", "), severity)
+		}
+	}
+	for _, threshold := range []struct {
+		key   string
+		value float64
+	}{
+		{"min_lines", c.Coverage.MinLines},
+		{"min_functions", c.Coverage.MinFunctions},
+		{"min_branches", c.Coverage.MinBranches},
+	} {
+		if threshold.value < 0 || threshold.value > 100 {
+			return fmt.Errorf("coverage.%s must be a percentage from 0 to 100, not %g", threshold.key, threshold.value)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, tool := range c.Tools {
+		if seen[tool.Name] {
+			return fmt.Errorf("tools: '%s' is defined twice", tool.Name)
+		}
+		seen[tool.Name] = true
+		if (tool.Format == "" || tool.Format == "regex") && tool.Pattern == "" {
+			return fmt.Errorf("tools[%s]: the regex format needs a pattern", tool.Name)
+		}
+	}
+	return nil
+}
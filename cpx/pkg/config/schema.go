@@ -0,0 +1,315 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaError is a problem at a position in cpx-ci.yaml
+type SchemaError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// SchemaErrors are the problems found in cpx-ci.yaml, in file order
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// schemaChecker collects the problems of a cpx-ci.yaml document
+type schemaChecker struct {
+	file string
+	errs SchemaErrors
+}
+
+func (c *schemaChecker) add(node *yaml.Node, format string, args ...any) {
+	c.errs = append(c.errs, SchemaError{File: c.file, Line: node.Line, Column: node.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkSchema checks a parsed cpx-ci.yaml, or a file it includes, against
+// the struct type t: every key must be a field, fields whose yaml tag has no
+// omitempty are required, and fields with an enum tag only take its values. Values of the wrong kind are
+// reported too, so decoding cannot fail afterwards.
+func checkSchema(file string, root *yaml.Node, t reflect.Type) SchemaErrors {
+	c := &schemaChecker{file: file}
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		c.check(root.Content[0], t, "")
+	}
+	return c.errs
+}
+
+// schemaField is a field of a cpx-ci.yaml section
+type schemaField struct {
+	Name     string // yaml key
+	Type     reflect.Type
+	Required bool
+	Enum     []string
+	Check    string // further check of a `cpx config set` value, e.g. dir
+	index    int
+}
+
+// schemaFields returns the yaml fields of a struct type, in declaration order
+func schemaFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		field := schemaField{Name: name, Type: f.Type, Required: !strings.Contains(opts, "omitempty"), Check: f.Tag.Get("check"), index: i}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			field.Enum = strings.Split(enum, ",")
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func (c *schemaChecker) check(node *yaml.Node, t reflect.Type, where string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			c.add(node, "%s must be a mapping", describe(where))
+			return
+		}
+		fields := schemaFields(t)
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			j := slices.IndexFunc(fields, func(f schemaField) bool { return f.Name == key.Value })
+			if j < 0 {
+				names := make([]string, len(fields))
+				for k, f := range fields {
+					names[k] = f.Name
+				}
+				msg := fmt.Sprintf("unknown field '%s'", key.Value)
+				if where != "" {
+					msg += " in " + where
+				}
+				if guess := closestName(key.Value, names); guess != "" {
+					msg += fmt.Sprintf(" (did you mean '%s'?)", guess)
+				}
+				c.add(key, "%s", msg)
+				continue
+			}
+			seen[key.Value] = true
+			c.checkField(value, fields[j], join(where, key.Value))
+		}
+		for _, f := range fields {
+			if f.Required && !seen[f.Name] {
+				c.add(node, "%s is required", join(where, f.Name))
+			}
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			c.add(node, "%s must be a list", where)
+			return
+		}
+		for i, item := range node.Content {
+			c.check(item, t.Elem(), fmt.Sprintf("%s[%s]", where, entryName(item, i)))
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			c.add(node, "%s must be a mapping", where)
+			return
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			c.check(node.Content[i], t.Elem(), join(where, node.Content[i-1].Value))
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			c.add(node, "%s must be %s", where, scalarKind(t))
+			return
+		}
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			c.add(node, "%s must be %s, not '%s'", where, scalarKind(t), node.Value)
+		}
+	}
+}
+
+// checkField checks a field's value, then that it is one of its enum values
+func (c *schemaChecker) checkField(node *yaml.Node, f schemaField, where string) {
+	c.check(node, f.Type, where)
+	if len(f.Enum) == 0 {
+		return
+	}
+	values := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		values = node.Content
+	}
+	for _, v := range values {
+		if v.Kind == yaml.ScalarNode && v.Tag != "!!null" && !slices.Contains(f.Enum, v.Value) {
+			c.add(v, "%s: '%s' is not one of %s", where, v.Value, strings.Join(f.Enum, ", "))
+		}
+	}
+}
+
+// join appends a key to the path of a value, e.g. toolchains[linux].runner
+func join(where, key string) string {
+	if where == "" {
+		return key
+	}
+	return where + "." + key
+}
+
+// describe names a path for messages, the top level being cpx-ci.yaml itself
+func describe(where string) string {
+	if where == "" {
+		return "cpx-ci.yaml"
+	}
+	return where
+}
+
+// entryName identifies a list entry in paths: its name, else its index
+func entryName(item *yaml.Node, i int) string {
+	if item.Kind == yaml.MappingNode {
+		for j := 0; j+1 < len(item.Content); j += 2 {
+			if item.Content[j].Value == "name" && item.Content[j+1].Kind == yaml.ScalarNode && item.Content[j+1].Value != "" {
+				return item.Content[j+1].Value
+			}
+		}
+	}
+	return fmt.Sprint(i)
+}
+
+// scalarKind names the kind of value a scalar type takes
+func scalarKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	case reflect.Float64:
+		return "a number"
+	}
+	return "a string"
+}
+
+// closestName returns the name within edit distance 2 of s, if any
+func closestName(s string, names []string) string {
+	best, bestDist := "", 3
+	for _, n := range names {
+		if d := editDistance(s, n); d < bestDist {
+			best, bestDist = n, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// JSONSchema returns a JSON Schema (draft-07) of cpx-ci.yaml, generated from
+// the same fields, required fields and enums LoadToolchains checks, for
+// editors with a YAML language server
+func JSONSchema() ([]byte, error) {
+	definitions := make(map[string]any)
+	root := jsonSchemaObject(reflect.TypeFor[ToolchainConfig](), definitions)
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "cpx-ci.yaml"
+	root["description"] = "Runners and toolchains built by cpx ci"
+	root["definitions"] = definitions
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// jsonSchemaObject returns the schema of a struct type, whose struct fields
+// are referenced from definitions
+func jsonSchemaObject(t reflect.Type, definitions map[string]any) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	for _, f := range schemaFields(t) {
+		prop := jsonSchemaType(f.Type, definitions)
+		if len(f.Enum) > 0 {
+			if items, ok := prop["items"].(map[string]any); ok {
+				items["enum"] = f.Enum
+			} else {
+				prop["enum"] = f.Enum
+			}
+		}
+		properties[f.Name] = prop
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonSchemaType returns the schema of a field type
+func jsonSchemaType(t reflect.Type, definitions map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, ok := definitions[t.Name()]; !ok {
+			definitions[t.Name()] = nil // placeholder for recursive types
+			definitions[t.Name()] = jsonSchemaObject(t, definitions)
+		}
+		return map[string]any{"$ref": "#/definitions/" + t.Name()}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": jsonSchemaType(t.Elem(), definitions)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaType(t.Elem(), definitions)}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{"type": "string"}
+}
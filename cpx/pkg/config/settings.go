@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SettingKeys returns the keys of the global config, in file order
+func SettingKeys() []string {
+	var keys []string
+	for _, f := range schemaFields(reflect.TypeFor[GlobalConfig]()) {
+		keys = append(keys, f.Name)
+	}
+	return keys
+}
+
+// setting returns the field of a key, which may use dashes for underscores
+func setting(key string) (schemaField, error) {
+	key = strings.ReplaceAll(key, "-", "_")
+	for _, f := range schemaFields(reflect.TypeFor[GlobalConfig]()) {
+		if f.Name == key {
+			return f, nil
+		}
+	}
+	msg := fmt.Sprintf("unknown config key: %s", key)
+	if guess := closestName(key, SettingKeys()); guess != "" {
+		msg += fmt.Sprintf(" (did you mean '%s'?)", guess)
+	}
+	return schemaField{}, fmt.Errorf("%s", msg)
+}
+
+// Get returns the value of a key as `cpx config get` prints it
+func (c *GlobalConfig) Get(key string) (string, error) {
+	f, err := setting(key)
+	if err != nil {
+		return "", err
+	}
+	v := reflect.ValueOf(c).Elem().Field(f.index)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return fmt.Sprint(reflect.Zero(v.Type().Elem())), nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ","), nil
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// Set parses and checks a value for a key: enum keys take one of their
+// values, directories must exist and are stored as absolute paths, and lists
+// are comma-separated
+func (c *GlobalConfig) Set(key, value string) error {
+	f, err := setting(key)
+	if err != nil {
+		return err
+	}
+	if len(f.Enum) > 0 && !slices.Contains(f.Enum, value) {
+		return fmt.Errorf("%s must be one of %s, not '%s'", f.Name, strings.Join(f.Enum, ", "), value)
+	}
+	switch f.Check {
+	case "dir":
+		if info, err := os.Stat(value); err != nil || !info.IsDir() {
+			return fmt.Errorf("path does not exist: %s", value)
+		}
+		if value, err = filepath.Abs(value); err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+	case "docker_host":
+		if !strings.Contains(value, "://") {
+			return fmt.Errorf("%s must be a DOCKER_HOST value such as unix:///var/run/docker.sock or ssh://user@host, not '%s'", f.Name, value)
+		}
+	}
+
+	field := reflect.ValueOf(c).Elem().Field(f.index)
+	t := f.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	parsed := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, not '%s'", f.Name, value)
+		}
+		parsed.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer, not '%s'", f.Name, value)
+		}
+		parsed.SetInt(int64(n))
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s is a list of mappings; set it in %s", f.Name, ProjectConfigFile)
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		parsed.Set(reflect.ValueOf(items))
+	default:
+		parsed.SetString(value)
+	}
+	if f.Type.Kind() == reflect.Pointer {
+		ptr := reflect.New(t)
+		ptr.Elem().Set(parsed)
+		parsed = ptr
+	}
+	old := reflect.New(f.Type).Elem()
+	old.Set(field)
+	field.Set(parsed)
+	if err := c.Validate(); err != nil {
+		field.Set(old)
+		return err
+	}
+	return nil
+}
+
+// Unset resets a key to its default
+func (c *GlobalConfig) Unset(key string) error {
+	f, err := setting(key)
+	if err != nil {
+		return err
+	}
+	field := reflect.ValueOf(c).Elem().Field(f.index)
+	field.Set(reflect.Zero(f.Type))
+	return nil
+}
+
+// Validate checks settings that depend on each other
+func (c *GlobalConfig) Validate() error {
+	if c.DockerHost != "" && c.DockerContext != "" {
+		return fmt.Errorf("set either docker_host or docker_context, not both")
+	}
+	if c.Jobs < 0 {
+		return fmt.Errorf("jobs must not be negative")
+	}
+	seen := make(map[string]bool)
+	for _, a := range c.Analyzers {
+		if seen[a.Name] {
+			return fmt.Errorf("analyzers: '%s' is defined twice", a.Name)
+		}
+		seen[a.Name] = true
+	}
+	return nil
+}
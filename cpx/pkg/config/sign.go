@@ -0,0 +1,33 @@
+package config
+
+import "fmt"
+
+// SignConfig signs the artifacts of `cpx ci build` (cpx-ci.yaml `sign:`)
+type SignConfig struct {
+	Method string `yaml:"method" enum:"cosign,gpg"` // cosign or gpg
+	// cosign: key file or KMS URI (e.g. awskms:///alias/release), keyless
+	// signing if empty; the key's password is read from COSIGN_PASSWORD.
+	// gpg: key ID or fingerprint, the default key if empty.
+	Key string `yaml:"key,omitempty"`
+	// gpg: host variable holding the key's passphrase
+	PassphraseEnv string `yaml:"passphrase_env,omitempty"`
+}
+
+// Validate checks the signing method and its settings
+func (s *SignConfig) Validate() error {
+	if s == nil {
+		return nil
+	}
+	switch s.Method {
+	case "cosign":
+		if s.PassphraseEnv != "" {
+			return fmt.Errorf("passphrase_env is only supported for gpg (cosign reads COSIGN_PASSWORD)")
+		}
+	case "gpg":
+	case "":
+		return fmt.Errorf("method is required (cosign or gpg)")
+	default:
+		return fmt.Errorf("unknown method '%s' (use cosign or gpg)", s.Method)
+	}
+	return nil
+}
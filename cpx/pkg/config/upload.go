@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// UploadConfig describes where `cpx ci build --upload` pushes artifacts
+// (cpx-ci.yaml `upload:`, globally or per toolchain)
+type UploadConfig struct {
+	// Destination: s3://bucket/prefix, gs://bucket/prefix or an http(s) URL
+	// taking PUT requests. {{version}} and {{target}} are replaced by the
+	// project version and toolchain name; without {{target}}, each
+	// toolchain's files go to <url>/<toolchain>/.
+	URL string `yaml:"url"`
+	// s3: endpoint of S3-compatible storage, e.g. https://minio.example.com
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// http: host variable holding a bearer token
+	TokenEnv string `yaml:"token_env,omitempty"`
+}
+
+// Scheme returns the storage type of the destination: s3, gs, http or https
+func (u *UploadConfig) Scheme() string {
+	scheme, _, _ := strings.Cut(u.URL, "://")
+	return scheme
+}
+
+// Validate checks the destination URL and its settings
+func (u *UploadConfig) Validate() error {
+	if u == nil {
+		return nil
+	}
+	if u.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	parsed, err := url.Parse(strings.NewReplacer("{{version}}", "v", "{{target}}", "t").Replace(u.URL))
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid url '%s'", u.URL)
+	}
+	switch u.Scheme() {
+	case "s3":
+	case "gs":
+		if u.Endpoint != "" {
+			return fmt.Errorf("endpoint is only supported for s3")
+		}
+	case "http", "https":
+		if u.Endpoint != "" {
+			return fmt.Errorf("endpoint is only supported for s3")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported url '%s' (use s3://, gs://, http:// or https://)", u.URL)
+	}
+	if u.TokenEnv != "" {
+		return fmt.Errorf("token_env is only supported for http(s); %s reads credentials from its usual variables", u.Scheme())
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateToolchains checks cpx-ci.yaml more thoroughly than LoadToolchains,
+// which only checks what each section says on its own: names must be unique,
+// toolchains must use defined runners, Docker runners need an image, a build
+// or a target, SSH runners a host and plugin runners a plugin, and extends
+// and depends_on must resolve without cycles. The problems come with their
+// position, in the included file for entries defined there.
+func ValidateToolchains(path string) (*ToolchainConfig, error) {
+	cfg, err := LoadToolchains(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return cfg, nil
+	}
+	c := &schemaChecker{file: path}
+	doc := root.Content[0]
+
+	seen := make(map[string]bool)
+	for i, r := range cfg.Runners {
+		origin := cfg.origin("runners", i)
+		node := origin.node
+		c.file = origin.file
+		switch {
+		case seen[r.Name]:
+			c.add(node, "runner '%s' is defined twice", r.Name)
+		case r.IsDocker() && r.Image == "" && r.Build == nil && r.Target == "":
+			c.add(node, "docker runner '%s' needs an image, a build or a target", r.Name)
+		case r.IsSSH() && r.Host == "":
+			c.add(node, "ssh runner '%s' needs a host", r.Name)
+		case r.IsPlugin() && r.Plugin == "":
+			c.add(node, "plugin runner '%s' needs a plugin", r.Name)
+		}
+		seen[r.Name] = true
+	}
+
+	seen = make(map[string]bool)
+	for _, list := range []struct {
+		key, kind  string
+		toolchains []Toolchain
+	}{{"templates", "template", cfg.Templates}, {"toolchains", "toolchain", cfg.Toolchains}} {
+		for i, t := range list.toolchains {
+			origin := cfg.origin(list.key, i)
+			node := origin.node
+			c.file = origin.file
+			if seen[t.Name] {
+				c.add(node, "'%s' is defined twice in templates and toolchains", t.Name)
+			}
+			seen[t.Name] = true
+			if t.Runner != "" && cfg.FindRunner(t.Runner) == nil {
+				c.add(mappingValue(node, "runner"), "%s '%s' uses unknown runner '%s'", list.kind, t.Name, t.Runner)
+			}
+			if list.kind == "toolchain" {
+				if _, err := cfg.resolve(t, nil); err != nil {
+					c.add(node, "%v", err)
+				}
+				for j, dep := range t.DependsOn {
+					if cfg.FindToolchain(dep) == nil {
+						c.add(mappingValue(node, "depends_on").Content[j], "toolchain '%s' depends on unknown toolchain '%s'", t.Name, dep)
+					}
+				}
+			}
+		}
+	}
+	c.file = path
+	profiles := mappingValue(doc, "profiles")
+	seen = make(map[string]bool)
+	for i, p := range cfg.Profiles {
+		if seen[p.Name] {
+			c.add(profiles.Content[i], "profile '%s' is defined twice", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	if len(c.errs) == 0 {
+		if _, err := SortByDependencies(cfg.Toolchains, cfg.Toolchains); err != nil {
+			c.add(mappingValue(doc, "toolchains"), "%v", err)
+		}
+	}
+	if len(c.errs) > 0 {
+		return nil, c.errs
+	}
+	return cfg, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or the node
+// itself when it has none, so errors still point near the problem
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return node
+}